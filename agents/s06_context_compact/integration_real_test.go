@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
 	"github.com/nickdu2009/learn-claude-code/pkg/loop"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
@@ -22,7 +24,7 @@ func TestIntegrationReal_ManualCompactFixture(t *testing.T) {
 	loadS06Env()
 	skipIfNoS06APIKey(t)
 
-	client, err := newClient()
+	client, model, err := harness.NewClient(config.Provider{})
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
@@ -32,7 +34,9 @@ func TestIntegrationReal_ManualCompactFixture(t *testing.T) {
 	tracePath := enableS06TraceForTest(t)
 
 	registry := tools.New()
-	registerBaseTools(registry)
+	if err := harness.RegisterBaseTools(registry, ".", config.Config{}); err != nil {
+		t.Fatalf("RegisterBaseTools: %v", err)
+	}
 	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
 
 	system := buildS06SystemPrompt(t)
@@ -70,7 +74,7 @@ func TestIntegrationReal_ManualCompactFixture(t *testing.T) {
 			return loop.RunWithContextCompact(ctx, client, model, messages, registry, opts)
 		},
 		client,
-		getModel(),
+		model,
 		history,
 		registry,
 	)
@@ -114,7 +118,7 @@ func TestIntegrationReal_AutoCompactFixture(t *testing.T) {
 	skipIfNoS06APIKey(t)
 	skipIfS06SlowAutoTestDisabled(t)
 
-	client, err := newClient()
+	client, model, err := harness.NewClient(config.Provider{})
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
@@ -124,7 +128,9 @@ func TestIntegrationReal_AutoCompactFixture(t *testing.T) {
 	tracePath := enableS06TraceForTest(t)
 
 	registry := tools.New()
-	registerBaseTools(registry)
+	if err := harness.RegisterBaseTools(registry, ".", config.Config{}); err != nil {
+		t.Fatalf("RegisterBaseTools: %v", err)
+	}
 	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
 
 	system := buildS06SystemPrompt(t)
@@ -152,7 +158,7 @@ func TestIntegrationReal_AutoCompactFixture(t *testing.T) {
 	}
 	for _, turn := range turns {
 		history = append(history, openai.UserMessage(turn))
-		history, err = loop.RunWithContextCompact(ctx, client, getModel(), history, registry, opts)
+		history, err = loop.RunWithContextCompact(ctx, client, model, history, registry, opts)
 		if err != nil {
 			_ = rec.FinishRun(ctx, devtools.RunResult{
 				Status:           "failed",
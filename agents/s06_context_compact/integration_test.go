@@ -14,6 +14,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
 	"github.com/nickdu2009/learn-claude-code/pkg/loop"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
@@ -220,7 +222,9 @@ func runS06FixtureScenarioWithOptions(
 
 	client := newCapturingMockClient(mock)
 	registry := tools.New()
-	registerBaseTools(registry)
+	if err := harness.RegisterBaseTools(registry, ".", config.Config{}); err != nil {
+		t.Fatalf("RegisterBaseTools: %v", err)
+	}
 	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
 
 	cwd, err := os.Getwd()
@@ -1,3 +1,8 @@
+// s06: Context Compact
+// Motto: "Forget the details, keep the thread"
+//
+// 工具结果与旧消息按三层策略压缩（见 loop.RunWithContextCompact）：模型可调用 compact 工具，
+// 用户可输入 /compact 手动压缩。完整的交互式 agent（审批、插件、MCP、会话等）见 go run ./cmd/agent chat。
 package main
 
 import (
@@ -10,11 +15,13 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
 	"github.com/nickdu2009/learn-claude-code/pkg/loop"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 )
 
 const (
@@ -27,25 +34,31 @@ func main() {
 		fmt.Fprintln(os.Stderr, "no .env file found, using system env")
 	}
 
-	client, err := newClient()
+	cwd, err := os.Getwd()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 
-	cwd, err := os.Getwd()
+	repoRoot, err := findRepoRoot(cwd)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 
-	repoRoot, err := findRepoRoot(cwd)
+	cfg, err := config.Load(repoRoot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	// 按 provider 配置（或 AGENT_PROVIDER）选择 LLM 后端，与 cmd/agent 一致
+	client, model, err := harness.NewClient(cfg.Provider)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 
-	model := getModel()
 	system := fmt.Sprintf(
 		"You are a coding agent at %s.\n"+
 			"Use tools to inspect and change the workspace.\n"+
@@ -55,9 +68,14 @@ func main() {
 	)
 
 	registry := tools.New()
-	registerBaseTools(registry)
+	if err := harness.RegisterBaseTools(registry, repoRoot, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	// 本课新增：compact 工具让模型自己决定何时压缩
 	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
 
+	// 估算超过 ThresholdTokens 时自动压缩：完整记录先存入 TranscriptDir，再由模型生成摘要替换旧消息
 	compactOpts := loop.CompactOptions{
 		ThresholdTokens:       50000,
 		KeepRecentToolResults: 3,
@@ -83,6 +101,13 @@ func main() {
 		openai.SystemMessage(system),
 	}
 
+	// /compact 由用户显式触发，与模型调用的 compact 工具互补
+	commands := command.New()
+	commands.Register(loop.CompactCommand(client, model, compactOpts,
+		func() []openai.ChatCompletionMessageParamUnion { return history },
+		func(messages []openai.ChatCompletionMessageParamUnion) { history = messages },
+	))
+
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Printf("%ss06 >> %s", colorCyan, colorReset)
@@ -95,8 +120,17 @@ func main() {
 			break
 		}
 
-		history = append(history, openai.UserMessage(query))
 		ctx := devtools.WithRecorder(context.Background(), rec)
+		if output, handled, err := commands.Dispatch(ctx, query); handled {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "command error:", err)
+			} else {
+				fmt.Println(output)
+			}
+			continue
+		}
+
+		history = append(history, openai.UserMessage(query))
 		history, err = loop.RunWithContextCompact(ctx, client, model, history, registry, compactOpts)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "loop error:", err)
@@ -108,14 +142,6 @@ func main() {
 	}
 }
 
-func registerBaseTools(registry *tools.Registry) {
-	registry.Register(tools.BashToolDef(), tools.BashHandler)
-	registry.Register(tools.ReadFileToolDef(), tools.ReadFileHandler)
-	registry.Register(tools.WriteFileToolDef(), tools.WriteFileHandler)
-	registry.Register(tools.EditFileToolDef(), tools.EditFileHandler)
-	registry.Register(tools.ListDirToolDef(), tools.ListDirHandler)
-}
-
 func printAssistantReply(message openai.ChatCompletionMessageParamUnion) {
 	if message.OfAssistant == nil {
 		return
@@ -147,27 +173,3 @@ func findRepoRoot(start string) (string, error) {
 	}
 	return "", fmt.Errorf("failed to locate repository root from %s", start)
 }
-
-func newClient() (*openai.Client, error) {
-	apiKey := os.Getenv("DASHSCOPE_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("DASHSCOPE_API_KEY is not set")
-	}
-	baseURL := os.Getenv("DASHSCOPE_BASE_URL")
-	if baseURL == "" {
-		return nil, fmt.Errorf("DASHSCOPE_BASE_URL is not set")
-	}
-
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-		option.WithBaseURL(baseURL),
-	)
-	return &client, nil
-}
-
-func getModel() string {
-	if m := os.Getenv("DASHSCOPE_MODEL"); m != "" {
-		return m
-	}
-	return "qwen-long"
-}
@@ -0,0 +1,1621 @@
+// Agent service for embedding the coding agent from other languages.
+//
+// The RPCs mirror the HTTP/WebSocket API in pkg/server: sessions are stored
+// through pkg/session, and the event stream carries the same event types
+// (token, tool_call_delta, tool_start, tool_end, message, follow_up,
+// permission_request, done, error).
+//
+// The Go stubs live next to this file and the service is implemented by
+// server.Server.GRPCService; cmd/agent-server serves it on AGENT_GRPC_ADDR.
+// Regenerate the stubs after changing this file with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	       --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//	       api/proto/agent/v1/agent.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: api/proto/agent/v1/agent.proto
+
+package agentv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED        EventType = 0
+	EventType_EVENT_TYPE_TOKEN              EventType = 1
+	EventType_EVENT_TYPE_TOOL_START         EventType = 2
+	EventType_EVENT_TYPE_TOOL_END           EventType = 3
+	EventType_EVENT_TYPE_MESSAGE            EventType = 4
+	EventType_EVENT_TYPE_FOLLOW_UP          EventType = 5
+	EventType_EVENT_TYPE_PERMISSION_REQUEST EventType = 6
+	EventType_EVENT_TYPE_DONE               EventType = 7
+	EventType_EVENT_TYPE_ERROR              EventType = 8
+	EventType_EVENT_TYPE_TOOL_CALL_DELTA    EventType = 9
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_TOKEN",
+		2: "EVENT_TYPE_TOOL_START",
+		3: "EVENT_TYPE_TOOL_END",
+		4: "EVENT_TYPE_MESSAGE",
+		5: "EVENT_TYPE_FOLLOW_UP",
+		6: "EVENT_TYPE_PERMISSION_REQUEST",
+		7: "EVENT_TYPE_DONE",
+		8: "EVENT_TYPE_ERROR",
+		9: "EVENT_TYPE_TOOL_CALL_DELTA",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED":        0,
+		"EVENT_TYPE_TOKEN":              1,
+		"EVENT_TYPE_TOOL_START":         2,
+		"EVENT_TYPE_TOOL_END":           3,
+		"EVENT_TYPE_MESSAGE":            4,
+		"EVENT_TYPE_FOLLOW_UP":          5,
+		"EVENT_TYPE_PERMISSION_REQUEST": 6,
+		"EVENT_TYPE_DONE":               7,
+		"EVENT_TYPE_ERROR":              8,
+		"EVENT_TYPE_TOOL_CALL_DELTA":    9,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_agent_v1_agent_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_api_proto_agent_v1_agent_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{0}
+}
+
+type StartSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartSessionRequest) Reset() {
+	*x = StartSessionRequest{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartSessionRequest) ProtoMessage() {}
+
+func (x *StartSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartSessionRequest.ProtoReflect.Descriptor instead.
+func (*StartSessionRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartSessionRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+type GetSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSessionRequest) Reset() {
+	*x = GetSessionRequest{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSessionRequest) ProtoMessage() {}
+
+func (x *GetSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSessionRequest.ProtoReflect.Descriptor instead.
+func (*GetSessionRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type Session struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// Set when the session was forked from another one.
+	ParentId      string                 `protobuf:"bytes,3,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Messages      []*Message             `protobuf:"bytes,6,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Session) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Session) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Session) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *Session) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Session) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Session) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type Message struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// system, user, assistant or tool.
+	Role      string      `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
+	Content   string      `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ToolCalls []*ToolCall `protobuf:"bytes,3,rep,name=tool_calls,json=toolCalls,proto3" json:"tool_calls,omitempty"`
+	// For role=tool: the call this message answers.
+	ToolCallId    string `protobuf:"bytes,4,opt,name=tool_call_id,json=toolCallId,proto3" json:"tool_call_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Message) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Message) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Message) GetToolCalls() []*ToolCall {
+	if x != nil {
+		return x.ToolCalls
+	}
+	return nil
+}
+
+func (x *Message) GetToolCallId() string {
+	if x != nil {
+		return x.ToolCallId
+	}
+	return ""
+}
+
+type ToolCall struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// JSON-encoded arguments, exactly as produced by the model.
+	ArgumentsJson string `protobuf:"bytes,3,opt,name=arguments_json,json=argumentsJson,proto3" json:"arguments_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolCall) Reset() {
+	*x = ToolCall{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolCall) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolCall) ProtoMessage() {}
+
+func (x *ToolCall) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolCall.ProtoReflect.Descriptor instead.
+func (*ToolCall) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ToolCall) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ToolCall) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolCall) GetArgumentsJson() string {
+	if x != nil {
+		return x.ArgumentsJson
+	}
+	return ""
+}
+
+type SendMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageRequest) Reset() {
+	*x = SendMessageRequest{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageRequest) ProtoMessage() {}
+
+func (x *SendMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageRequest.ProtoReflect.Descriptor instead.
+func (*SendMessageRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SendMessageRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SendMessageRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type StreamEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamEventsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+// ClientMessage is one message on the Converse stream. The first message must
+// carry session_id and a user message.
+type ClientMessage struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*ClientMessage_UserMessage
+	//	*ClientMessage_Interrupt
+	//	*ClientMessage_PermissionResponse
+	Kind          isClientMessage_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientMessage) Reset() {
+	*x = ClientMessage{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMessage) ProtoMessage() {}
+
+func (x *ClientMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMessage.ProtoReflect.Descriptor instead.
+func (*ClientMessage) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ClientMessage) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ClientMessage) GetKind() isClientMessage_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetUserMessage() string {
+	if x != nil {
+		if x, ok := x.Kind.(*ClientMessage_UserMessage); ok {
+			return x.UserMessage
+		}
+	}
+	return ""
+}
+
+func (x *ClientMessage) GetInterrupt() *Interrupt {
+	if x != nil {
+		if x, ok := x.Kind.(*ClientMessage_Interrupt); ok {
+			return x.Interrupt
+		}
+	}
+	return nil
+}
+
+func (x *ClientMessage) GetPermissionResponse() *PermissionResponse {
+	if x != nil {
+		if x, ok := x.Kind.(*ClientMessage_PermissionResponse); ok {
+			return x.PermissionResponse
+		}
+	}
+	return nil
+}
+
+type isClientMessage_Kind interface {
+	isClientMessage_Kind()
+}
+
+type ClientMessage_UserMessage struct {
+	// Starts a run, or is queued as a follow-up while one is in progress.
+	UserMessage string `protobuf:"bytes,2,opt,name=user_message,json=userMessage,proto3,oneof"`
+}
+
+type ClientMessage_Interrupt struct {
+	Interrupt *Interrupt `protobuf:"bytes,3,opt,name=interrupt,proto3,oneof"`
+}
+
+type ClientMessage_PermissionResponse struct {
+	PermissionResponse *PermissionResponse `protobuf:"bytes,4,opt,name=permission_response,json=permissionResponse,proto3,oneof"`
+}
+
+func (*ClientMessage_UserMessage) isClientMessage_Kind() {}
+
+func (*ClientMessage_Interrupt) isClientMessage_Kind() {}
+
+func (*ClientMessage_PermissionResponse) isClientMessage_Kind() {}
+
+type Interrupt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Interrupt) Reset() {
+	*x = Interrupt{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Interrupt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Interrupt) ProtoMessage() {}
+
+func (x *Interrupt) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Interrupt.ProtoReflect.Descriptor instead.
+func (*Interrupt) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{8}
+}
+
+type PermissionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The id from the PermissionRequest event.
+	RequestId     string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Approved      bool   `protobuf:"varint,2,opt,name=approved,proto3" json:"approved,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PermissionResponse) Reset() {
+	*x = PermissionResponse{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PermissionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PermissionResponse) ProtoMessage() {}
+
+func (x *PermissionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PermissionResponse.ProtoReflect.Descriptor instead.
+func (*PermissionResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PermissionResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PermissionResponse) GetApproved() bool {
+	if x != nil {
+		return x.Approved
+	}
+	return false
+}
+
+type Event struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Type      EventType              `protobuf:"varint,1,opt,name=type,proto3,enum=agent.v1.EventType" json:"type,omitempty"`
+	Time      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	SessionId string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_Token
+	//	*Event_ToolStart
+	//	*Event_ToolEnd
+	//	*Event_Message
+	//	*Event_FollowUp
+	//	*Event_PermissionRequest
+	//	*Event_Done
+	//	*Event_Error
+	//	*Event_ToolCallDelta
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Event) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetToken() *TokenDelta {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Token); ok {
+			return x.Token
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetToolStart() *ToolStart {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ToolStart); ok {
+			return x.ToolStart
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetToolEnd() *ToolEnd {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ToolEnd); ok {
+			return x.ToolEnd
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetMessage() *AssistantMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetFollowUp() *FollowUp {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_FollowUp); ok {
+			return x.FollowUp
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPermissionRequest() *PermissionRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PermissionRequest); ok {
+			return x.PermissionRequest
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetDone() *Done {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Done); ok {
+			return x.Done
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetToolCallDelta() *ToolCallDelta {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ToolCallDelta); ok {
+			return x.ToolCallDelta
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_Token struct {
+	Token *TokenDelta `protobuf:"bytes,4,opt,name=token,proto3,oneof"`
+}
+
+type Event_ToolStart struct {
+	ToolStart *ToolStart `protobuf:"bytes,5,opt,name=tool_start,json=toolStart,proto3,oneof"`
+}
+
+type Event_ToolEnd struct {
+	ToolEnd *ToolEnd `protobuf:"bytes,6,opt,name=tool_end,json=toolEnd,proto3,oneof"`
+}
+
+type Event_Message struct {
+	Message *AssistantMessage `protobuf:"bytes,7,opt,name=message,proto3,oneof"`
+}
+
+type Event_FollowUp struct {
+	FollowUp *FollowUp `protobuf:"bytes,8,opt,name=follow_up,json=followUp,proto3,oneof"`
+}
+
+type Event_PermissionRequest struct {
+	PermissionRequest *PermissionRequest `protobuf:"bytes,9,opt,name=permission_request,json=permissionRequest,proto3,oneof"`
+}
+
+type Event_Done struct {
+	Done *Done `protobuf:"bytes,10,opt,name=done,proto3,oneof"`
+}
+
+type Event_Error struct {
+	Error *Error `protobuf:"bytes,11,opt,name=error,proto3,oneof"`
+}
+
+type Event_ToolCallDelta struct {
+	ToolCallDelta *ToolCallDelta `protobuf:"bytes,12,opt,name=tool_call_delta,json=toolCallDelta,proto3,oneof"`
+}
+
+func (*Event_Token) isEvent_Payload() {}
+
+func (*Event_ToolStart) isEvent_Payload() {}
+
+func (*Event_ToolEnd) isEvent_Payload() {}
+
+func (*Event_Message) isEvent_Payload() {}
+
+func (*Event_FollowUp) isEvent_Payload() {}
+
+func (*Event_PermissionRequest) isEvent_Payload() {}
+
+func (*Event_Done) isEvent_Payload() {}
+
+func (*Event_Error) isEvent_Payload() {}
+
+func (*Event_ToolCallDelta) isEvent_Payload() {}
+
+type TokenDelta struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Delta         string                 `protobuf:"bytes,1,opt,name=delta,proto3" json:"delta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenDelta) Reset() {
+	*x = TokenDelta{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenDelta) ProtoMessage() {}
+
+func (x *TokenDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenDelta.ProtoReflect.Descriptor instead.
+func (*TokenDelta) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TokenDelta) GetDelta() string {
+	if x != nil {
+		return x.Delta
+	}
+	return ""
+}
+
+// A fragment of a tool call's JSON arguments while the model writes them.
+type ToolCallDelta struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tool          string                 `protobuf:"bytes,2,opt,name=tool,proto3" json:"tool,omitempty"`
+	Delta         string                 `protobuf:"bytes,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolCallDelta) Reset() {
+	*x = ToolCallDelta{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolCallDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolCallDelta) ProtoMessage() {}
+
+func (x *ToolCallDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolCallDelta.ProtoReflect.Descriptor instead.
+func (*ToolCallDelta) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ToolCallDelta) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ToolCallDelta) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *ToolCallDelta) GetDelta() string {
+	if x != nil {
+		return x.Delta
+	}
+	return ""
+}
+
+type ToolStart struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tool          string                 `protobuf:"bytes,1,opt,name=tool,proto3" json:"tool,omitempty"`
+	ArgsJson      string                 `protobuf:"bytes,2,opt,name=args_json,json=argsJson,proto3" json:"args_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolStart) Reset() {
+	*x = ToolStart{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolStart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolStart) ProtoMessage() {}
+
+func (x *ToolStart) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolStart.ProtoReflect.Descriptor instead.
+func (*ToolStart) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ToolStart) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *ToolStart) GetArgsJson() string {
+	if x != nil {
+		return x.ArgsJson
+	}
+	return ""
+}
+
+type ToolEnd struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Tool  string                 `protobuf:"bytes,1,opt,name=tool,proto3" json:"tool,omitempty"`
+	// Truncated to 4000 bytes, like the HTTP API.
+	Output        string `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolEnd) Reset() {
+	*x = ToolEnd{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolEnd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolEnd) ProtoMessage() {}
+
+func (x *ToolEnd) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolEnd.ProtoReflect.Descriptor instead.
+func (*ToolEnd) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ToolEnd) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *ToolEnd) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *ToolEnd) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type AssistantMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AssistantMessage) Reset() {
+	*x = AssistantMessage{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssistantMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssistantMessage) ProtoMessage() {}
+
+func (x *AssistantMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssistantMessage.ProtoReflect.Descriptor instead.
+func (*AssistantMessage) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AssistantMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type FollowUp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FollowUp) Reset() {
+	*x = FollowUp{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FollowUp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FollowUp) ProtoMessage() {}
+
+func (x *FollowUp) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FollowUp.ProtoReflect.Descriptor instead.
+func (*FollowUp) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FollowUp) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type PermissionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tool          string                 `protobuf:"bytes,2,opt,name=tool,proto3" json:"tool,omitempty"`
+	Summary       string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
+	Detail        string                 `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PermissionRequest) Reset() {
+	*x = PermissionRequest{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PermissionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PermissionRequest) ProtoMessage() {}
+
+func (x *PermissionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PermissionRequest.ProtoReflect.Descriptor instead.
+func (*PermissionRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PermissionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PermissionRequest) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *PermissionRequest) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *PermissionRequest) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+type Done struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      int32                  `protobuf:"varint,1,opt,name=messages,proto3" json:"messages,omitempty"`
+	Interrupted   bool                   `protobuf:"varint,2,opt,name=interrupted,proto3" json:"interrupted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Done) Reset() {
+	*x = Done{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Done) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Done) ProtoMessage() {}
+
+func (x *Done) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Done.ProtoReflect.Descriptor instead.
+func (*Done) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Done) GetMessages() int32 {
+	if x != nil {
+		return x.Messages
+	}
+	return 0
+}
+
+func (x *Done) GetInterrupted() bool {
+	if x != nil {
+		return x.Interrupted
+	}
+	return false
+}
+
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_agent_v1_agent_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_api_proto_agent_v1_agent_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Error) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_api_proto_agent_v1_agent_proto protoreflect.FileDescriptor
+
+const file_api_proto_agent_v1_agent_proto_rawDesc = "" +
+	"\n" +
+	"\x1eapi/proto/agent/v1/agent.proto\x12\bagent.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"+\n" +
+	"\x13StartSessionRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\"2\n" +
+	"\x11GetSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xf1\x01\n" +
+	"\aSession\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1b\n" +
+	"\tparent_id\x18\x03 \x01(\tR\bparentId\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12-\n" +
+	"\bmessages\x18\x06 \x03(\v2\x11.agent.v1.MessageR\bmessages\"\x8c\x01\n" +
+	"\aMessage\x12\x12\n" +
+	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x121\n" +
+	"\n" +
+	"tool_calls\x18\x03 \x03(\v2\x12.agent.v1.ToolCallR\ttoolCalls\x12 \n" +
+	"\ftool_call_id\x18\x04 \x01(\tR\n" +
+	"toolCallId\"U\n" +
+	"\bToolCall\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12%\n" +
+	"\x0earguments_json\x18\x03 \x01(\tR\rargumentsJson\"M\n" +
+	"\x12SendMessageRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"4\n" +
+	"\x13StreamEventsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xe1\x01\n" +
+	"\rClientMessage\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12#\n" +
+	"\fuser_message\x18\x02 \x01(\tH\x00R\vuserMessage\x123\n" +
+	"\tinterrupt\x18\x03 \x01(\v2\x13.agent.v1.InterruptH\x00R\tinterrupt\x12O\n" +
+	"\x13permission_response\x18\x04 \x01(\v2\x1c.agent.v1.PermissionResponseH\x00R\x12permissionResponseB\x06\n" +
+	"\x04kind\"\v\n" +
+	"\tInterrupt\"O\n" +
+	"\x12PermissionResponse\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x1a\n" +
+	"\bapproved\x18\x02 \x01(\bR\bapproved\"\xe9\x04\n" +
+	"\x05Event\x12'\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x13.agent.v1.EventTypeR\x04type\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\x12,\n" +
+	"\x05token\x18\x04 \x01(\v2\x14.agent.v1.TokenDeltaH\x00R\x05token\x124\n" +
+	"\n" +
+	"tool_start\x18\x05 \x01(\v2\x13.agent.v1.ToolStartH\x00R\ttoolStart\x12.\n" +
+	"\btool_end\x18\x06 \x01(\v2\x11.agent.v1.ToolEndH\x00R\atoolEnd\x126\n" +
+	"\amessage\x18\a \x01(\v2\x1a.agent.v1.AssistantMessageH\x00R\amessage\x121\n" +
+	"\tfollow_up\x18\b \x01(\v2\x12.agent.v1.FollowUpH\x00R\bfollowUp\x12L\n" +
+	"\x12permission_request\x18\t \x01(\v2\x1b.agent.v1.PermissionRequestH\x00R\x11permissionRequest\x12$\n" +
+	"\x04done\x18\n" +
+	" \x01(\v2\x0e.agent.v1.DoneH\x00R\x04done\x12'\n" +
+	"\x05error\x18\v \x01(\v2\x0f.agent.v1.ErrorH\x00R\x05error\x12A\n" +
+	"\x0ftool_call_delta\x18\f \x01(\v2\x17.agent.v1.ToolCallDeltaH\x00R\rtoolCallDeltaB\t\n" +
+	"\apayload\"\"\n" +
+	"\n" +
+	"TokenDelta\x12\x14\n" +
+	"\x05delta\x18\x01 \x01(\tR\x05delta\"I\n" +
+	"\rToolCallDelta\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04tool\x18\x02 \x01(\tR\x04tool\x12\x14\n" +
+	"\x05delta\x18\x03 \x01(\tR\x05delta\"<\n" +
+	"\tToolStart\x12\x12\n" +
+	"\x04tool\x18\x01 \x01(\tR\x04tool\x12\x1b\n" +
+	"\targs_json\x18\x02 \x01(\tR\bargsJson\"K\n" +
+	"\aToolEnd\x12\x12\n" +
+	"\x04tool\x18\x01 \x01(\tR\x04tool\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\",\n" +
+	"\x10AssistantMessage\x12\x18\n" +
+	"\acontent\x18\x01 \x01(\tR\acontent\"$\n" +
+	"\bFollowUp\x12\x18\n" +
+	"\acontent\x18\x01 \x01(\tR\acontent\"i\n" +
+	"\x11PermissionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04tool\x18\x02 \x01(\tR\x04tool\x12\x18\n" +
+	"\asummary\x18\x03 \x01(\tR\asummary\x12\x16\n" +
+	"\x06detail\x18\x04 \x01(\tR\x06detail\"D\n" +
+	"\x04Done\x12\x1a\n" +
+	"\bmessages\x18\x01 \x01(\x05R\bmessages\x12 \n" +
+	"\vinterrupted\x18\x02 \x01(\bR\vinterrupted\"\x1d\n" +
+	"\x05Error\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error*\x91\x02\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10EVENT_TYPE_TOKEN\x10\x01\x12\x19\n" +
+	"\x15EVENT_TYPE_TOOL_START\x10\x02\x12\x17\n" +
+	"\x13EVENT_TYPE_TOOL_END\x10\x03\x12\x16\n" +
+	"\x12EVENT_TYPE_MESSAGE\x10\x04\x12\x18\n" +
+	"\x14EVENT_TYPE_FOLLOW_UP\x10\x05\x12!\n" +
+	"\x1dEVENT_TYPE_PERMISSION_REQUEST\x10\x06\x12\x13\n" +
+	"\x0fEVENT_TYPE_DONE\x10\a\x12\x14\n" +
+	"\x10EVENT_TYPE_ERROR\x10\b\x12\x1e\n" +
+	"\x1aEVENT_TYPE_TOOL_CALL_DELTA\x10\t2\xca\x02\n" +
+	"\fAgentService\x12@\n" +
+	"\fStartSession\x12\x1d.agent.v1.StartSessionRequest\x1a\x11.agent.v1.Session\x12<\n" +
+	"\n" +
+	"GetSession\x12\x1b.agent.v1.GetSessionRequest\x1a\x11.agent.v1.Session\x12>\n" +
+	"\vSendMessage\x12\x1c.agent.v1.SendMessageRequest\x1a\x0f.agent.v1.Event0\x01\x128\n" +
+	"\bConverse\x12\x17.agent.v1.ClientMessage\x1a\x0f.agent.v1.Event(\x010\x01\x12@\n" +
+	"\fStreamEvents\x12\x1d.agent.v1.StreamEventsRequest\x1a\x0f.agent.v1.Event0\x01BDZBgithub.com/nickdu2009/learn-claude-code/api/proto/agent/v1;agentv1b\x06proto3"
+
+var (
+	file_api_proto_agent_v1_agent_proto_rawDescOnce sync.Once
+	file_api_proto_agent_v1_agent_proto_rawDescData []byte
+)
+
+func file_api_proto_agent_v1_agent_proto_rawDescGZIP() []byte {
+	file_api_proto_agent_v1_agent_proto_rawDescOnce.Do(func() {
+		file_api_proto_agent_v1_agent_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_proto_agent_v1_agent_proto_rawDesc), len(file_api_proto_agent_v1_agent_proto_rawDesc)))
+	})
+	return file_api_proto_agent_v1_agent_proto_rawDescData
+}
+
+var file_api_proto_agent_v1_agent_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_proto_agent_v1_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_api_proto_agent_v1_agent_proto_goTypes = []any{
+	(EventType)(0),                // 0: agent.v1.EventType
+	(*StartSessionRequest)(nil),   // 1: agent.v1.StartSessionRequest
+	(*GetSessionRequest)(nil),     // 2: agent.v1.GetSessionRequest
+	(*Session)(nil),               // 3: agent.v1.Session
+	(*Message)(nil),               // 4: agent.v1.Message
+	(*ToolCall)(nil),              // 5: agent.v1.ToolCall
+	(*SendMessageRequest)(nil),    // 6: agent.v1.SendMessageRequest
+	(*StreamEventsRequest)(nil),   // 7: agent.v1.StreamEventsRequest
+	(*ClientMessage)(nil),         // 8: agent.v1.ClientMessage
+	(*Interrupt)(nil),             // 9: agent.v1.Interrupt
+	(*PermissionResponse)(nil),    // 10: agent.v1.PermissionResponse
+	(*Event)(nil),                 // 11: agent.v1.Event
+	(*TokenDelta)(nil),            // 12: agent.v1.TokenDelta
+	(*ToolCallDelta)(nil),         // 13: agent.v1.ToolCallDelta
+	(*ToolStart)(nil),             // 14: agent.v1.ToolStart
+	(*ToolEnd)(nil),               // 15: agent.v1.ToolEnd
+	(*AssistantMessage)(nil),      // 16: agent.v1.AssistantMessage
+	(*FollowUp)(nil),              // 17: agent.v1.FollowUp
+	(*PermissionRequest)(nil),     // 18: agent.v1.PermissionRequest
+	(*Done)(nil),                  // 19: agent.v1.Done
+	(*Error)(nil),                 // 20: agent.v1.Error
+	(*timestamppb.Timestamp)(nil), // 21: google.protobuf.Timestamp
+}
+var file_api_proto_agent_v1_agent_proto_depIdxs = []int32{
+	21, // 0: agent.v1.Session.created_at:type_name -> google.protobuf.Timestamp
+	21, // 1: agent.v1.Session.updated_at:type_name -> google.protobuf.Timestamp
+	4,  // 2: agent.v1.Session.messages:type_name -> agent.v1.Message
+	5,  // 3: agent.v1.Message.tool_calls:type_name -> agent.v1.ToolCall
+	9,  // 4: agent.v1.ClientMessage.interrupt:type_name -> agent.v1.Interrupt
+	10, // 5: agent.v1.ClientMessage.permission_response:type_name -> agent.v1.PermissionResponse
+	0,  // 6: agent.v1.Event.type:type_name -> agent.v1.EventType
+	21, // 7: agent.v1.Event.time:type_name -> google.protobuf.Timestamp
+	12, // 8: agent.v1.Event.token:type_name -> agent.v1.TokenDelta
+	14, // 9: agent.v1.Event.tool_start:type_name -> agent.v1.ToolStart
+	15, // 10: agent.v1.Event.tool_end:type_name -> agent.v1.ToolEnd
+	16, // 11: agent.v1.Event.message:type_name -> agent.v1.AssistantMessage
+	17, // 12: agent.v1.Event.follow_up:type_name -> agent.v1.FollowUp
+	18, // 13: agent.v1.Event.permission_request:type_name -> agent.v1.PermissionRequest
+	19, // 14: agent.v1.Event.done:type_name -> agent.v1.Done
+	20, // 15: agent.v1.Event.error:type_name -> agent.v1.Error
+	13, // 16: agent.v1.Event.tool_call_delta:type_name -> agent.v1.ToolCallDelta
+	1,  // 17: agent.v1.AgentService.StartSession:input_type -> agent.v1.StartSessionRequest
+	2,  // 18: agent.v1.AgentService.GetSession:input_type -> agent.v1.GetSessionRequest
+	6,  // 19: agent.v1.AgentService.SendMessage:input_type -> agent.v1.SendMessageRequest
+	8,  // 20: agent.v1.AgentService.Converse:input_type -> agent.v1.ClientMessage
+	7,  // 21: agent.v1.AgentService.StreamEvents:input_type -> agent.v1.StreamEventsRequest
+	3,  // 22: agent.v1.AgentService.StartSession:output_type -> agent.v1.Session
+	3,  // 23: agent.v1.AgentService.GetSession:output_type -> agent.v1.Session
+	11, // 24: agent.v1.AgentService.SendMessage:output_type -> agent.v1.Event
+	11, // 25: agent.v1.AgentService.Converse:output_type -> agent.v1.Event
+	11, // 26: agent.v1.AgentService.StreamEvents:output_type -> agent.v1.Event
+	22, // [22:27] is the sub-list for method output_type
+	17, // [17:22] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_api_proto_agent_v1_agent_proto_init() }
+func file_api_proto_agent_v1_agent_proto_init() {
+	if File_api_proto_agent_v1_agent_proto != nil {
+		return
+	}
+	file_api_proto_agent_v1_agent_proto_msgTypes[7].OneofWrappers = []any{
+		(*ClientMessage_UserMessage)(nil),
+		(*ClientMessage_Interrupt)(nil),
+		(*ClientMessage_PermissionResponse)(nil),
+	}
+	file_api_proto_agent_v1_agent_proto_msgTypes[10].OneofWrappers = []any{
+		(*Event_Token)(nil),
+		(*Event_ToolStart)(nil),
+		(*Event_ToolEnd)(nil),
+		(*Event_Message)(nil),
+		(*Event_FollowUp)(nil),
+		(*Event_PermissionRequest)(nil),
+		(*Event_Done)(nil),
+		(*Event_Error)(nil),
+		(*Event_ToolCallDelta)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_agent_v1_agent_proto_rawDesc), len(file_api_proto_agent_v1_agent_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_proto_agent_v1_agent_proto_goTypes,
+		DependencyIndexes: file_api_proto_agent_v1_agent_proto_depIdxs,
+		EnumInfos:         file_api_proto_agent_v1_agent_proto_enumTypes,
+		MessageInfos:      file_api_proto_agent_v1_agent_proto_msgTypes,
+	}.Build()
+	File_api_proto_agent_v1_agent_proto = out.File
+	file_api_proto_agent_v1_agent_proto_goTypes = nil
+	file_api_proto_agent_v1_agent_proto_depIdxs = nil
+}
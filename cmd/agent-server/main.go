@@ -0,0 +1,320 @@
+// agent-server exposes the agent loop over HTTP, and optionally gRPC (see
+// pkg/server for the API).
+//
+// Flags:
+//
+//	--debug-llm                     dump every LLM request/response to .agent/debug/ (keys redacted)
+//	--dangerously-skip-permissions  approve every action and allow dangerous commands without asking
+//	                                (containers/CI only; also "dangerously_skip_permissions" in
+//	                                ~/.agent/settings.json). Every tool call is then written to the
+//	                                audit log in .audit/.
+//
+// Environment:
+//
+//	AGENT_SERVER_ADDR  listen address (default 127.0.0.1:8080)
+//	AGENT_GRPC_ADDR    when set, also serve agent.v1.AgentService (api/proto/agent/v1) on this address
+//	AGENT_SANDBOX      bash backend, see pkg/sandbox
+//	AGENT_PROVIDER     LLM backend: qwen (default) or azure, see pkg/provider
+//	AGENT_METRICS      1 serves Prometheus metrics on GET /metrics, see pkg/metrics
+//	AGENT_KEYCHAIN     off skips reading API keys from the OS keychain, see pkg/credentials
+//
+// The "server" section of .agent/config.json lists the users of a shared
+// server; each user's API token is read from the variable in its token_env.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	agentv1 "github.com/nickdu2009/learn-claude-code/api/proto/agent/v1"
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/credentials"
+	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/i18n"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/metrics"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/repomap"
+	"github.com/nickdu2009/learn-claude-code/pkg/server"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go/option"
+	"google.golang.org/grpc"
+)
+
+const defaultAddr = "127.0.0.1:8080"
+
+func main() {
+	debugLLM := flag.Bool("debug-llm", false, "dump every LLM request/response to "+llm.DefaultDebugDir)
+	skipPermissions := flag.Bool("dangerously-skip-permissions", false, "approve every action without asking (containers/CI only)")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "no .env file found, using system env")
+	}
+	// Keys missing from the environment and .env come from the OS keychain.
+	if !credentials.Disabled() {
+		if _, err := credentials.LoadEnv(credentials.Keychain(), credentials.Keys...); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+	if err := run(*debugLLM, *skipPermissions); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(debugLLM, skipPermissions bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return err
+	}
+	skipPermissions = skipPermissions || cfg.DangerouslySkipPermissions
+	i18n.SetLang(i18n.Detect(cfg.Language))
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.IgnoredSettings, strings.Join(cfg.Ignored, ", ")))
+	}
+	var clientOpts []option.RequestOption
+	if debugLLM {
+		dumper, err := llm.NewDebugDumper(filepath.Join(cwd, llm.DefaultDebugDir))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("dumping LLM calls to %s\n", dumper.Dir())
+		clientOpts = append(clientOpts, option.WithMiddleware(dumper.Middleware))
+	}
+	// The backend (DashScope by default, or Azure OpenAI) comes from the
+	// "provider" section of .agent/config.json or AGENT_PROVIDER.
+	client, model, err := provider.New(cfg.Provider, clientOpts...)
+	if err != nil {
+		return err
+	}
+
+	registry := tools.New()
+	if err := harness.RegisterBaseTools(registry, cwd, cfg); err != nil {
+		return err
+	}
+	optional := harness.RegisterTools(registry, cwd, cfg, nil)
+	defer optional.Close()
+	// Approvals go to the WebSocket client that started the run; without one
+	// they are denied.
+	approver := permission.Contextual(nil)
+	if skipPermissions {
+		approver = audit.RecordingApprover(permission.Bypass)
+	}
+	registry.Register(tools.ReplaceInFilesToolDef(), tools.NewReplaceInFilesHandler(approver))
+	// Plugins and the project's MCP servers run only in a project the user
+	// trusts (agent trust), as it was when trusted.
+	extensions, err := harness.RegisterExtensions(registry, cwd, cfg, harness.TrustProject(cwd, cfg, nil), approver)
+	if err != nil {
+		return err
+	}
+	defer extensions.Close()
+	if cfg.IsolateNetwork {
+		registry = registry.WithMiddleware(tools.NetworkGate(approver))
+	}
+	registry = harness.CheckOutput(registry, cfg)
+	// Nothing runs unrecorded while permission checks are off: without an
+	// audit log the server refuses to start. Dangerous commands are not
+	// blocked either, as the sandbox is the container or CI job itself.
+	if skipPermissions {
+		logger, err := audit.Open(filepath.Join(cwd, audit.DefaultDir), audit.NewSessionID())
+		if err != nil {
+			return fmt.Errorf("--dangerously-skip-permissions requires the audit log: %w", err)
+		}
+		defer logger.Close()
+		registry, _ = harness.SkipPermissions(registry.WithMiddleware(logger.Middleware()))
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.SkipWarning))
+		fmt.Fprintf(os.Stderr, "audit log: %s\n", logger.Path())
+	}
+
+	repo, err := session.NewFileRepository(filepath.Join(cwd, session.DefaultDir))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The repo map is kept up to date by watching the work tree, so every new
+	// session starts from the current code without a full rescan.
+	systemPrompt := "You are a coding agent at {{cwd}}. Use tools to solve tasks. Act, don't explain.\n\n{{env}}"
+	var promptVars func() map[string]string
+	if budget := repomap.MaxTokensFromEnv(); budget > 0 {
+		watcher := fileindex.NewWatcher(cwd, fileindex.DefaultPollInterval)
+		repoMap := repomap.NewMap(cwd)
+		repoMap.Watch(watcher)
+		if _, err := watcher.Poll(ctx); err != nil {
+			return err
+		}
+		go watcher.Run(ctx)
+		systemPrompt += "\n\n{{repo_map}}"
+		promptVars = func() map[string]string {
+			return map[string]string{"repo_map": repoMap.Render(repomap.Options{MaxTokens: budget})}
+		}
+	}
+
+	users, err := serverUsers(cfg.Server, cfg.Budget)
+	if err != nil {
+		return err
+	}
+
+	var reg *metrics.Registry
+	if enabled, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("AGENT_METRICS"))); enabled {
+		reg = metrics.NewRegistry()
+	}
+
+	// The system prompt and repo map are the same on every call: let the
+	// provider cache them.
+	var interceptors []llm.Interceptor
+	if cache, ok := provider.PromptCache(cfg.Provider); ok {
+		interceptors = append(interceptors, cache)
+	}
+
+	// Calls to a model that keeps failing stop for a cool-down (and go to
+	// the fallbacks meanwhile) instead of waiting out every retry.
+	breaker := harness.Breaker(cfg.Provider)
+
+	srv, err := server.New(server.Config{
+		Client:         client,
+		Model:          model,
+		Fallbacks:      provider.Fallbacks(cfg.Provider, clientOpts...),
+		Interceptors:   interceptors,
+		CircuitBreaker: &breaker,
+		Registry:       registry,
+		Sessions:       session.NewService(repo),
+		Runner:         loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd),
+		SystemPrompt:   systemPrompt,
+		WorkDir:        cwd,
+		PromptVars:     promptVars,
+		Metrics:        reg,
+		BaseContext:    llm.WithCapabilities(devtools.WithRecorder(ctx, devtools.NewRecorderFromEnv()), provider.Capabilities(cfg.Provider)),
+		Users:          users,
+	})
+	if err != nil {
+		return err
+	}
+
+	addr := strings.TrimSpace(os.Getenv("AGENT_SERVER_ADDR"))
+	if addr == "" {
+		addr = defaultAddr
+	}
+	if len(users) == 0 && !isLoopback(addr) {
+		fmt.Fprintf(os.Stderr, "warning: %s is reachable from other machines and no users are configured: anyone can run commands\n", addr)
+	}
+	httpServer := &http.Server{Addr: addr, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 2)
+	go func() {
+		fmt.Printf("agent-server listening on http://%s\n", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+	// The gRPC API shares sessions, runs and users with the HTTP one.
+	if grpcAddr := strings.TrimSpace(os.Getenv("AGENT_GRPC_ADDR")); grpcAddr != "" {
+		if len(users) == 0 && !isLoopback(grpcAddr) {
+			fmt.Fprintf(os.Stderr, "warning: %s is reachable from other machines and no users are configured: anyone can run commands\n", grpcAddr)
+		}
+		grpcServer, listener, err := listenGRPC(grpcAddr, srv)
+		if err != nil {
+			_ = httpServer.Close()
+			return err
+		}
+		// Streams end with BaseContext, so GracefulStop does not hang.
+		defer grpcServer.GracefulStop()
+		go func() {
+			fmt.Printf("agent-server listening on grpc://%s\n", listener.Addr())
+			errCh <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			stop()
+			_ = httpServer.Close()
+			return err
+		}
+	case <-ctx.Done():
+		fmt.Println("\nshutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// SSE connections never finish on their own; Shutdown's deadline closes them.
+	_ = httpServer.Shutdown(shutdownCtx)
+	srv.Wait()
+	return nil
+}
+
+// listenGRPC listens on addr and returns a gRPC server offering srv's
+// agent.v1.AgentService, ready to Serve the listener.
+func listenGRPC(addr string, srv *server.Server) (*grpc.Server, net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	grpcServer := grpc.NewServer()
+	agentv1.RegisterAgentServiceServer(grpcServer, srv.GRPCService())
+	return grpcServer, listener, nil
+}
+
+// serverUsers reads the API tokens of the configured users.
+// serverUsers reads the users' tokens from the environment. Users whose
+// budget sets no prices are charged the project's.
+func serverUsers(cfg config.Server, prices config.Budget) ([]server.User, error) {
+	users := make([]server.User, 0, len(cfg.Users))
+	for _, u := range cfg.Users {
+		token := strings.TrimSpace(os.Getenv(u.TokenEnv))
+		if token == "" {
+			return nil, fmt.Errorf("server user %q: %s is not set", u.Name, u.TokenEnv)
+		}
+		if u.Budget.Prices == nil && u.Budget.InputCostPer1K == 0 && u.Budget.OutputCostPer1K == 0 {
+			u.Budget.Prices = prices.Prices
+			u.Budget.InputCostPer1K, u.Budget.OutputCostPer1K = prices.InputCostPer1K, prices.OutputCostPer1K
+		}
+		users = append(users, server.User{
+			Name:              u.Name,
+			Token:             token,
+			MessagesPerMinute: u.MessagesPerMinute,
+			MaxTokensPerDay:   u.MaxTokensPerDay,
+			Budget:            u.Budget,
+		})
+	}
+	return users, nil
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
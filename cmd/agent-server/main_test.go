@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	agentv1 "github.com/nickdu2009/learn-claude-code/api/proto/agent/v1"
+	"github.com/nickdu2009/learn-claude-code/pkg/server"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestListenGRPC_ServesTheAgentService(t *testing.T) {
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.New(server.Config{Sessions: session.NewService(repo), SystemPrompt: "You are a test agent."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer, listener, err := listenGRPC("127.0.0.1:0", srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	created, err := agentv1.NewAgentServiceClient(conn).StartSession(ctx, &agentv1.StartSessionRequest{Title: "embedded"})
+	if err != nil || created.GetTitle() != "embedded" || len(created.GetMessages()) != 1 {
+		t.Fatalf("StartSession = %v, %v", created, err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/changelog"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+)
+
+// runChangelog prints the changelog section of the commits after since, or
+// adds it to CHANGELOG.md.
+func runChangelog(since, version string, write, asJSON bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if since == "" {
+		if since, err = changelog.LatestTag(ctx, cwd); err != nil {
+			return err
+		}
+	}
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	opts := changelog.Options{Client: client, Model: model, Dir: cwd, Since: since, Version: version}
+	if host, ok := forge.FromConfig(cfg, cwd); ok {
+		opts.Forge = host
+	}
+	result, err := changelog.Generate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, reason := range result.Unverified {
+		fmt.Fprintln(os.Stderr, "dropped reference:", reason)
+	}
+
+	switch {
+	case asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case write:
+		path := filepath.Join(cwd, "CHANGELOG.md")
+		doc, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(changelog.Prepend(string(doc), changelog.Format(result))), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "added %d entries to %s\n", len(result.Entries), path)
+		return nil
+	}
+	fmt.Print(changelog.Format(result))
+	return nil
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/checkpoint"
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/envinfo"
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/i18n"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/mention"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/profile"
+	"github.com/nickdu2009/learn-claude-code/pkg/prompts"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/readline"
+	"github.com/nickdu2009/learn-claude-code/pkg/recap"
+	"github.com/nickdu2009/learn-claude-code/pkg/repomap"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/snapshot"
+	"github.com/nickdu2009/learn-claude-code/pkg/sysprompt"
+	"github.com/nickdu2009/learn-claude-code/pkg/telemetry"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	colorCyan  = "\033[36m"
+	colorReset = "\033[0m"
+)
+
+// readOnlyInstructions join the system prompt in read-only mode (--read-only
+// or a read-only profile).
+const readOnlyInstructions = "You are in read-only mode: only tools that do not change the workspace are available, " +
+	"and bash refuses commands that may write. Investigate and report your findings; do not try to work around the restriction."
+
+// runChat runs the interactive REPL in the current directory until the user
+// quits. Startup errors are printed in the language of the config and
+// reported as a failure.
+func runChat(skipPermissions, readOnly bool, profileName string) (bool, error) {
+	fail := func(err error) (bool, error) {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.Error, err))
+		return true, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fail(err)
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return fail(err)
+	}
+	client, model, err := harness.NewClient(cfg.Provider)
+	if err != nil {
+		return fail(err)
+	}
+	skipPermissions = skipPermissions || cfg.DangerouslySkipPermissions
+	// Prompts, warnings and approval dialogs follow the language setting,
+	// or LANG without one.
+	i18n.SetLang(i18n.Detect(cfg.Language))
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.IgnoredSettings, strings.Join(cfg.Ignored, ", ")))
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return fail(err)
+	}
+
+	// One watcher keeps the file list, the repo map and the code index
+	// current without rescanning the work tree.
+	watcher := fileindex.NewWatcher(cwd, fileindex.DefaultPollInterval)
+	files := fileindex.New(cwd)
+	files.Watch(watcher)
+	repoMap := repomap.NewMap(cwd)
+	repoMap.Watch(watcher)
+	if _, err := watcher.Poll(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.FileWatcherError, err))
+	}
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go watcher.Run(watchCtx)
+
+	// The environment snapshot spares the model a round of uname and git
+	// status at the start.
+	env := envinfo.Gather(context.Background(), cwd)
+	mapBudget := repomap.MaxTokensFromEnv()
+	registry := tools.New()
+	if err := harness.RegisterBaseTools(registry, cwd, cfg); err != nil {
+		return fail(err)
+	}
+	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
+	optional := harness.RegisterTools(registry, cwd, cfg, watcher)
+	defer optional.Close()
+	// Plugin tools count as "other" in the anonymous usage counters.
+	stats := recorder(cfg, "repl")
+	defer stats.Flush(context.Background())
+	registry = registry.WithMiddleware(telemetry.Middleware(telemetry.ToolNames(registry)...))
+	// Files changed on disk since the model read them are not overwritten.
+	registry = registry.WithMiddleware(tools.NewReadTracker().Middleware())
+	// Long-running tools report progress on a stderr status line.
+	statusLine := tools.NewStatusLine(os.Stderr)
+	registry = registry.WithMiddleware(statusLine.Middleware())
+	// A tool call repeated verbatim is not run again; the model is told to
+	// try something else.
+	repeats := tools.NewRepeatGuard(tools.DefaultMaxRepeats)
+	registry = registry.WithMiddleware(repeats.Middleware())
+	registry = harness.CheckOutput(registry, cfg)
+	// The audit log records every tool call and feeds the summary at the end
+	// of each turn. Skipping permissions requires it.
+	auditPath := ""
+	sessionID := audit.NewSessionID()
+	if logger, err := audit.Open(filepath.Join(cwd, audit.DefaultDir), sessionID); err != nil {
+		if skipPermissions {
+			fmt.Fprintln(os.Stderr, i18n.T(i18n.AuditRequired, err))
+			return true, nil
+		}
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.AuditDisabled, err))
+	} else {
+		defer logger.Close()
+		auditPath = logger.Path()
+		registry = registry.WithMiddleware(logger.Middleware())
+	}
+	input := readline.New(os.Stdin, os.Stdout)
+	// Esc while a tool runs cancels just that call: its partial output,
+	// marked [cancelled by user], becomes the result and the turn goes on.
+	canceller := tools.NewToolCanceller(input.WatchEsc)
+	// File writes are shown as a diff to answer with [y]es/[n]o/[a]lways/[e]dit.
+	prompter := permission.NewPrompter(os.Stdin, os.Stdout)
+	var approver permission.Approver = prompter
+	prompt := i18n.T(i18n.Prompt)
+	if skipPermissions {
+		registry, approver = harness.SkipPermissions(registry)
+		prompt = i18n.T(i18n.PromptSkipPermissions)
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.SkipWarning))
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.AuditLogPath, auditPath))
+	} else {
+		approver = harness.RememberApprovals(cwd, cfg, approver)
+		// Esc is not watched while a question waits for its answer.
+		approver = canceller.Approver(approver)
+	}
+	// The profile picks the model, extra system prompt, tools and approval
+	// policy; approvals go through Contextual to the approver of the turn.
+	// A profile that skips approvals needs the audit log like the flag.
+	ask := approver
+	approver = permission.Contextual(ask)
+	profiles := profile.New(cfg.Profiles, func(p config.Profile) error {
+		if p.Permission == config.ProfileSkip && auditPath == "" {
+			return errors.New("skipping permissions requires the audit log")
+		}
+		return nil
+	})
+	if err := profiles.Use(profileName); err != nil {
+		return fail(err)
+	}
+	if _, p := profiles.Active(); p.Permission == config.ProfileSkip && !skipPermissions {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.SkipWarning))
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.AuditLogPath, auditPath))
+	}
+
+	// The system prompt is built from layers every turn, so edits to
+	// AGENT.md and profile switches apply on the next one; /prompt shows it.
+	layers := sysprompt.New(
+		sysprompt.Static("core", fmt.Sprintf(
+			"You are a coding agent at %s.\n"+
+				"Use tools to inspect and change the workspace.\n"+
+				"When the context gets large or the task changes phases, use the compact tool to compress history while preserving continuity.\n"+
+				"Prefer tools over prose.",
+			cwd,
+		)),
+		sysprompt.Layer{Name: "environment", Text: func() string {
+			text := env.String()
+			// The repo map tells the model where the code is before it looks.
+			if mapBudget > 0 {
+				if m := repoMap.Render(repomap.Options{MaxTokens: mapBudget}); m != "" {
+					text += "\n\n" + m
+				}
+			}
+			return text
+		}},
+		sysprompt.Project(cwd),
+		sysprompt.User(),
+		sysprompt.Layer{Name: "profile", Text: func() string {
+			_, p := profiles.Active()
+			return p.SystemPrompt
+		}},
+		sysprompt.Layer{Name: "mode", Text: func() string {
+			if _, p := profiles.Active(); readOnly || p.Permission == config.ProfileReadOnly {
+				return readOnlyInstructions
+			}
+			return ""
+		}},
+	)
+	system := layers.Build()
+
+	writeGate := tools.NewWriteGate(audit.RecordingApprover(approver))
+	registry = registry.WithMiddleware(writeGate.Middleware())
+	// Files are copied to .checkpoints/<session>/ before a write tool changes
+	// them; restore_file and /restore bring them back without git.
+	checkpoints, err := checkpoint.Open(filepath.Join(cwd, checkpoint.DefaultDir, sessionID))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.Warning, err))
+	} else {
+		registry.Register(tools.RestoreFileToolDef(), tools.NewRestoreFileHandler(checkpoints))
+		registry = registry.WithMiddleware(checkpoints.Middleware())
+	}
+	if cfg.IsolateNetwork {
+		registry = registry.WithMiddleware(tools.NetworkGate(audit.RecordingApprover(approver)))
+	}
+	// With the forge's token set, the model can read issues and open pull
+	// requests, the latter on approval.
+	if host, ok := forge.FromConfig(cfg, cwd); ok {
+		registry.Register(tools.GetIssueToolDef(), tools.NewGetIssueHandler(host))
+		registry.Register(tools.ListPRsToolDef(), tools.NewListPRsHandler(host))
+		registry.Register(tools.CreatePRToolDef(), tools.NewCreatePRHandler(host, audit.RecordingApprover(approver)))
+	}
+	// A cloned repository may ship any program as a plugin or MCP server:
+	// the user is asked to trust the project first, and again after they
+	// change.
+	extensions, err := harness.RegisterExtensions(registry, cwd, cfg, harness.TrustProject(cwd, cfg, prompter), audit.RecordingApprover(approver))
+	if err != nil {
+		return fail(err)
+	}
+	defer extensions.Close()
+	if len(extensions.Plugins) > 0 {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.Plugins, strings.Join(extensions.Plugins, ", ")))
+	}
+	if len(extensions.MCPTools) > 0 {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.MCPTools, strings.Join(extensions.MCPTools, ", ")))
+	}
+	if readOnly {
+		registry = tools.ReadOnly(registry)
+		prompt = i18n.T(i18n.PromptReadOnly)
+	}
+	// Analysis tools such as go_vet reuse their result while the arguments
+	// and the workspace files stay the same.
+	registry = registry.WithMiddleware(tools.NewResultCache().Middleware(nil))
+	// Innermost, so approvals do not count as tool run time.
+	registry = registry.WithMiddleware(canceller.Middleware())
+
+	compactOpts := loop.CompactOptions{
+		ThresholdTokens:       50000,
+		KeepRecentToolResults: 3,
+		KeepRecentMessages:    6,
+		TranscriptDir:         filepath.Join(cwd, ".transcripts"),
+		SummaryCharLimit:      80000,
+		SummaryTimeout:        90 * time.Second,
+	}
+
+	// The run ID goes into the logs, audit records, LLM dumps and metrics,
+	// and is printed at exit to find this run again.
+	runID := trace.NewRunID()
+	defer func() { fmt.Fprintln(os.Stderr, i18n.T(i18n.RunID, runID)) }()
+
+	rec := devtools.NewRecorderFromEnv()
+	_ = rec.BeginRun(context.Background(), devtools.RunMeta{
+		Kind:  "main",
+		Title: "agent chat",
+	})
+	defer func() {
+		_ = rec.FinishRun(context.Background(), devtools.RunResult{
+			Status:           "completed",
+			CompletionReason: "normal",
+		})
+	}()
+
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(system),
+	}
+
+	// /compact compresses the history on request, besides the compact tool
+	// the model calls.
+	commands := command.New()
+	commands.Register(loop.CompactCommand(client, model, compactOpts,
+		func() []openai.ChatCompletionMessageParamUnion { return history },
+		func(messages []openai.ChatCompletionMessageParamUnion) { history = messages },
+	))
+
+	// /cost shows the tokens and estimated cost per model (budget.prices).
+	usage := budget.New(budget.Limits{}, budget.PricingFromConfig(cfg.Budget))
+	commands.Register(usage.CostCommand())
+	if checkpoints != nil {
+		commands.Register(checkpoints.RestoreCommand())
+	}
+
+	// A git snapshot before the first change of each turn, leaving the
+	// user's index, stash and branches alone, lets /undo revert the turn.
+	var snapshots *snapshot.Manager
+	if snapshot.Available(context.Background(), cwd) {
+		snapshots = snapshot.New(cwd)
+		commands.Register(snapshots.UndoCommand())
+	}
+	// AGENT_TEST_COMMAND runs the tests after edits and feeds failures back
+	// (fix until green); AGENT_REVIEW has a reviewer model check the diff of
+	// the turn against the request.
+	runTurn := loop.RunWithSnapshots(snapshots, loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(func(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		return loop.RunWithContextCompact(ctx, client, model, messages, registry, compactOpts)
+	}, cwd), cwd))
+
+	// The conversation is saved to .sessions/ after every turn; /fork N
+	// branches it at message N and continues in the fork.
+	var sessions *session.Service
+	var sessionTitle, currentSession string
+	if repo, err := session.NewFileRepository(filepath.Join(cwd, session.DefaultDir)); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.Warning, err))
+	} else {
+		sessions = session.NewService(repo)
+		commands.Register(sessions.ForkCommand(func() string { return currentSession }, func(fork session.Session) {
+			currentSession, history = fork.ID, fork.Messages
+		}))
+	}
+
+	commands.Register(layers.Command(tokens.Default()))
+
+	commands.Register(profiles.Command(func(_ string, p config.Profile) string {
+		if p.Permission == config.ProfileSkip {
+			return i18n.T(i18n.SkipWarning)
+		}
+		return ""
+	}))
+
+	// /template fills a prompt template of .agent/prompts/ and sends it, e.g.
+	// /template fix-bug issue=123.
+	var templated string
+	commands.Register(prompts.Command(filepath.Join(cwd, prompts.DefaultDir), func(message string) { templated = message }))
+
+	// The breaker keeps its state across turns, so the interceptors are
+	// made once.
+	interceptors := harness.Interceptors(cfg.Provider)
+
+	// @ opens a fuzzy file picker that honours .gitignore.
+	input.SetPicker(func(query string) []string { return files.Search(query, 20) })
+	// cd in bash carries over to later calls and shows in the prompt.
+	workDir := tools.NewWorkDir(cwd)
+	for {
+		active, current := profiles.Active()
+		line, err := input.ReadLine(colorCyan + promptWithDir(promptWithProfile(prompt, active), cwd, workDir.Dir()) + colorReset)
+		if err != nil {
+			break
+		}
+
+		query := strings.TrimSpace(line)
+		if query == "" || query == "q" || query == "exit" {
+			break
+		}
+
+		ctx := devtools.WithRecorder(budget.WithTracker(trace.WithRun(context.Background(), runID), usage), rec)
+		ctx = llm.WithInterceptors(llm.WithCapabilities(ctx, provider.Capabilities(cfg.Provider)), interceptors...)
+		ctx = tools.WithWorkDir(ctx, workDir)
+		ctx = tools.WithProgressHandler(ctx, statusLine.Update)
+		ctx = permission.WithApprover(ctx, profile.Approver(current, ask))
+		ctx = stats.Context(ctx)
+		if output, handled, err := commands.Dispatch(ctx, query); handled {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, i18n.T(i18n.CommandError, err))
+			} else {
+				fmt.Println(output)
+			}
+			if templated == "" {
+				continue
+			}
+			query, templated = templated, ""
+		}
+
+		// @path mentions are inlined, saving a read_file round trip.
+		expanded, inclusions := mention.Expand(query, mention.Options{Root: cwd})
+		for _, inc := range inclusions {
+			switch {
+			case inc.Skipped != "":
+				fmt.Fprintln(os.Stderr, i18n.T(i18n.MentionSkipped, inc.Path, inc.Skipped))
+			case inc.Truncated:
+				fmt.Println(i18n.T(i18n.MentionTruncated, inc.Path, inc.Bytes))
+			default:
+				fmt.Println(i18n.T(i18n.MentionIncluded, inc.Path, inc.Bytes))
+			}
+		}
+
+		repeats.Reset()
+		// Code, AGENT.md or the profile changed: use the rebuilt prompt.
+		if fresh := layers.Build(); fresh != system {
+			system = fresh
+			history[0] = openai.SystemMessage(system)
+		}
+		history = append(history, openai.UserMessage(expanded))
+		turn := recap.Begin(ctx, cwd, auditPath)
+		history, err = runTurn(turn.Context(ctx), client, profile.Model(current, model), history, profile.Tools(current, registry))
+		// Turns that changed the workspace end with the files changed, the
+		// commands run and the tokens spent.
+		summary := turn.End(ctx)
+		stats.Run(err)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T(i18n.LoopError, err, runID))
+			if summary.Mutated() {
+				fmt.Println(summary)
+			}
+			continue
+		}
+
+		if sessions != nil {
+			if sessionTitle == "" {
+				sessionTitle = session.MessagePreview(openai.UserMessage(query), 60)
+			}
+			if err := saveSession(sessions, &currentSession, sessionTitle, history); err != nil {
+				fmt.Fprintln(os.Stderr, i18n.T(i18n.Warning, err))
+			}
+		}
+
+		printAssistantReply(history[len(history)-1])
+		if summary.Mutated() {
+			fmt.Println()
+			fmt.Println(summary)
+		}
+		fmt.Println()
+	}
+	return false, nil
+}
+
+// saveSession saves history to the session *id, creating it first when *id
+// is empty.
+func saveSession(sessions *session.Service, id *string, title string, history []openai.ChatCompletionMessageParamUnion) error {
+	if *id != "" {
+		_, err := sessions.SaveMessages(*id, history)
+		return err
+	}
+	sess, err := sessions.Create(title, history)
+	if err != nil {
+		return err
+	}
+	*id = sess.ID
+	return nil
+}
+
+func printAssistantReply(message openai.ChatCompletionMessageParamUnion) {
+	if message.OfAssistant == nil {
+		return
+	}
+	content := message.OfAssistant.Content
+	if content.OfString.Value != "" {
+		fmt.Println(content.OfString.Value)
+	}
+	for _, part := range content.OfArrayOfContentParts {
+		if part.OfText != nil {
+			fmt.Println(part.OfText.Text)
+		}
+	}
+}
+
+// promptWithProfile puts the active profile into the prompt, as in
+// "agent (reviewer) >> ".
+func promptWithProfile(prompt, name string) string {
+	if name == "" {
+		return prompt
+	}
+	return strings.TrimSuffix(prompt, ">> ") + "(" + name + ") >> "
+}
+
+// promptWithDir puts the current directory relative to root into the
+// prompt, as in "agent pkg/loop >> ".
+func promptWithDir(prompt, root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return prompt
+	}
+	return strings.TrimSuffix(prompt, ">> ") + filepath.ToSlash(rel) + " >> "
+}
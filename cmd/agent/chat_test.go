@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/openai/openai-go"
+)
+
+func TestSaveSession_CreatesThenUpdatesTheSession(t *testing.T) {
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := session.NewService(repo)
+	history := []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("sys"), openai.UserMessage("hi"), openai.AssistantMessage("hello")}
+
+	var id string
+	if err := saveSession(sessions, &id, "hi", history); err != nil || id == "" {
+		t.Fatalf("saveSession = %q, %v", id, err)
+	}
+	first := id
+	history = append(history, openai.UserMessage("again"), openai.AssistantMessage("sure"))
+	if err := saveSession(sessions, &id, "hi", history); err != nil || id != first {
+		t.Fatalf("saveSession = %q, %v, want the session %q updated", id, err, first)
+	}
+	sess, err := sessions.Get(id)
+	if err != nil || len(sess.Messages) != 5 || sess.Title != "hi" {
+		t.Fatalf("session = %+v, %v", sess, err)
+	}
+	if list, _ := sessions.List(); len(list) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(list))
+	}
+}
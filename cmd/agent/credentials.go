@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/credentials"
+)
+
+// runCredentials manages the API keys kept in the OS keychain.
+func runCredentials(args []string) error {
+	store := credentials.Keychain()
+	if len(args) == 0 || args[0] == "status" {
+		return credentialStatus(store)
+	}
+	switch args[0] {
+	case "set":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		if err := checkKeyName(args[1]); err != nil {
+			return err
+		}
+		secret, err := readSecret(args[1])
+		if err != nil {
+			return err
+		}
+		if secret == "" {
+			return errors.New("empty secret, nothing stored")
+		}
+		if err := store.Set(args[1], secret); err != nil {
+			return err
+		}
+		fmt.Printf("stored %s in the keychain\n", args[1])
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		if err := store.Delete(args[1]); err != nil {
+			return fmt.Errorf("%s: %w", args[1], err)
+		}
+		fmt.Printf("deleted %s from the keychain\n", args[1])
+		return nil
+	case "storage-key":
+		// Losing the key loses every encrypted session, so never replace one.
+		switch _, err := store.Get(atrest.KeyEnv); {
+		case err == nil:
+			return fmt.Errorf("%s is already in the keychain; delete it first only if nothing is encrypted with it", atrest.KeyEnv)
+		case !errors.Is(err, credentials.ErrNotFound):
+			return err
+		}
+		if err := store.Set(atrest.KeyEnv, atrest.NewKey()); err != nil {
+			return err
+		}
+		fmt.Printf("stored a new %s in the keychain; sessions and audit logs are now encrypted\n", atrest.KeyEnv)
+		return nil
+	case "import":
+		path := ".env"
+		if len(args) == 2 {
+			path = args[1]
+		}
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return err
+		}
+		imported := 0
+		for _, name := range credentials.Keys {
+			if strings.TrimSpace(values[name]) == "" {
+				continue
+			}
+			if err := store.Set(name, values[name]); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			fmt.Printf("imported %s\n", name)
+			imported++
+		}
+		if imported > 0 {
+			fmt.Printf("now remove these keys from %s\n", path)
+		}
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+func credentialStatus(store credentials.Store) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tENVIRONMENT\tKEYCHAIN")
+	for _, name := range credentials.Keys {
+		env := "-"
+		if strings.TrimSpace(os.Getenv(name)) != "" {
+			env = "set"
+		}
+		keychain := "stored"
+		switch _, err := store.Get(name); {
+		case errors.Is(err, credentials.ErrNotFound):
+			keychain = "-"
+		case errors.Is(err, credentials.ErrUnsupported):
+			keychain = "unavailable"
+		case err != nil:
+			keychain = err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, env, keychain)
+	}
+	return w.Flush()
+}
+
+func checkKeyName(name string) error {
+	if !slices.Contains(credentials.Keys, name) {
+		return fmt.Errorf("unknown key %s; one of %s", name, strings.Join(credentials.Keys, ", "))
+	}
+	return nil
+}
+
+// readSecret prompts for the secret without echo on a terminal and reads the
+// first line of stdin otherwise.
+func readSecret(name string) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "%s: ", name)
+		if restore := noEcho(); restore != nil {
+			defer func() {
+				restore()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// noEcho turns terminal echo off and returns a function turning it back on,
+// or nil when stty is unavailable.
+func noEcho() func() {
+	cmd := exec.Command("stty", "-echo")
+	cmd.Stdin = os.Stdin
+	if cmd.Run() != nil {
+		return nil
+	}
+	return func() {
+		cmd := exec.Command("stty", "echo")
+		cmd.Stdin = os.Stdin
+		_ = cmd.Run()
+	}
+}
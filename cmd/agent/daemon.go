@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/daemon"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+func runDaemon(httpAddr string, maxTurns int) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return err
+	}
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeTools()
+	repo, err := session.NewFileRepository(filepath.Join(cwd, session.DefaultDir))
+	if err != nil {
+		return err
+	}
+	stats := recorder(cfg, "daemon")
+	defer stats.Flush(context.Background())
+	d, err := daemon.New(daemon.Config{
+		Client:       client,
+		Model:        model,
+		Registry:     registry,
+		Sessions:     session.NewService(repo),
+		SystemPrompt: fmt.Sprintf("You are a coding agent at %s. Use tools to solve tasks. Act, don't explain.", cwd),
+		MaxTurns:     maxTurns,
+		Runner:       notifier(cwd, cfg).Runner("daemon", stats.Runner(loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd))),
+		Logf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// baseTools has warned when the project is not trusted.
+	schedules := cfg.Schedules
+	if ok, _ := projectTrusted(cwd, cfg); !ok {
+		schedules = nil
+	}
+	jobs, err := daemon.JobsFromConfig(schedules, cwd)
+	if err != nil {
+		return err
+	}
+
+	// Tasks run commands without asking, so TCP is served on loopback only
+	// and to holders of the token.
+	token := strings.TrimSpace(os.Getenv("AGENT_DAEMON_TOKEN"))
+	if httpAddr != "" {
+		if !isLoopback(httpAddr) {
+			return fmt.Errorf("refusing to serve -http on %s: tasks run commands without approval; use a loopback address such as 127.0.0.1:8090", httpAddr)
+		}
+		if token == "" {
+			return errors.New("-http needs AGENT_DAEMON_TOKEN, which clients send as a bearer token")
+		}
+	}
+
+	socket := filepath.Join(cwd, daemon.DefaultSocket)
+	ln, err := daemon.Listen(socket)
+	if err != nil {
+		return err
+	}
+	servers := map[net.Listener]*http.Server{
+		ln: {Handler: d.Handler(), ReadHeaderTimeout: 10 * time.Second},
+	}
+	if httpAddr != "" {
+		tcp, err := net.Listen("tcp", httpAddr)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		servers[tcp] = &http.Server{Handler: daemon.RequireToken(token, d.Handler()), ReadHeaderTimeout: 10 * time.Second}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = harness.Context(ctx, cfg.Provider)
+	errCh := make(chan error, len(servers))
+	for ln, srv := range servers {
+		fmt.Printf("daemon listening on %s %s\n", ln.Addr().Network(), ln.Addr())
+		go func() { errCh <- srv.Serve(ln) }()
+	}
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	scheduled := make(chan struct{})
+	go func() {
+		d.Schedule(ctx, jobs)
+		close(scheduled)
+	}()
+	for _, job := range jobs {
+		fmt.Printf("schedule %s: %s, next run %s\n", job.Name, job.Schedule, job.Schedule.Next(time.Now()).Format(time.DateTime))
+	}
+
+	select {
+	case err = <-errCh:
+		stop()
+	case <-ctx.Done():
+		fmt.Println("\nshutting down...")
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Tails never finish on their own; Shutdown's deadline closes them.
+	for _, srv := range servers {
+		_ = srv.Shutdown(shutdownCtx)
+	}
+	<-done
+	<-scheduled
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runTask is the thin client of a daemon running in the current directory,
+// or at AGENT_DAEMON_URL.
+func runTask(args []string) (failed bool, err error) {
+	if len(args) == 0 {
+		return false, errors.New(usage)
+	}
+	client, err := daemonClient()
+	if err != nil {
+		return false, err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "submit":
+		fs := flag.NewFlagSet("task submit", flag.ExitOnError)
+		sessionName := fs.String("session", "", "continue the named session instead of starting a new one")
+		wait := fs.Bool("wait", false, "tail the task until it finishes")
+		_ = fs.Parse(args[1:])
+		prompt := strings.Join(fs.Args(), " ")
+		if strings.TrimSpace(prompt) == "" {
+			return false, errors.New(usage)
+		}
+		task, err := client.Submit(ctx, daemon.Submission{Prompt: prompt, Session: *sessionName})
+		if err != nil {
+			return false, err
+		}
+		if !*wait {
+			fmt.Println(task.ID)
+			return false, nil
+		}
+		fmt.Fprintf(os.Stderr, "%s queued\n", task.ID)
+		return tailTask(ctx, client, task.ID)
+	case "list":
+		tasks, err := client.Tasks(ctx)
+		if err != nil {
+			return false, err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tSESSION\tPROMPT")
+		for _, task := range tasks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.ID, task.Status, task.Session, preview(task.Prompt, 60))
+		}
+		return false, w.Flush()
+	case "tail":
+		if len(args) != 2 {
+			return false, errors.New(usage)
+		}
+		return tailTask(ctx, client, args[1])
+	case "cancel":
+		if len(args) != 2 {
+			return false, errors.New(usage)
+		}
+		task, err := client.Cancel(ctx, args[1])
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("%s %s\n", task.ID, task.Status)
+		return false, nil
+	default:
+		return false, errors.New(usage)
+	}
+}
+
+func daemonClient() (*daemon.Client, error) {
+	if url := strings.TrimSpace(os.Getenv("AGENT_DAEMON_URL")); url != "" {
+		return daemon.NewHTTPClient(url, strings.TrimSpace(os.Getenv("AGENT_DAEMON_TOKEN"))), nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(cwd, daemon.DefaultSocket)
+	if _, err := os.Stat(socket); err != nil {
+		return nil, fmt.Errorf("no daemon in this directory (%s): start one with `agent daemon`", daemon.DefaultSocket)
+	}
+	return daemon.Dial(socket), nil
+}
+
+// tailTask prints the progress of a task until it finishes and reports
+// whether it did not succeed.
+func tailTask(ctx context.Context, client *daemon.Client, id string) (bool, error) {
+	status := ""
+	// Tool calls are shown while the model writes them: the command of a
+	// bash call appears as it forms.
+	var (
+		forming = make(map[string]string)
+		shown   = make(map[string]int)
+		formed  int
+	)
+	err := client.Tail(ctx, id, func(e daemon.Event) {
+		switch e.Type {
+		case daemon.EventStarted:
+			fmt.Fprintf(os.Stderr, "%s started\n", id)
+		case daemon.EventToken:
+			fmt.Print(e.Data["delta"])
+		case daemon.EventToolCall:
+			call, _ := e.Data["id"].(string)
+			if _, started := forming[call]; !started {
+				fmt.Fprintf(os.Stderr, "\n> %v ", e.Data["tool"])
+				formed++
+			}
+			delta, _ := e.Data["delta"].(string)
+			forming[call] += delta
+			if command, ok := loop.PartialString(forming[call], "command"); ok && len(command) > shown[call] {
+				fmt.Fprint(os.Stderr, command[shown[call]:])
+				shown[call] = len(command)
+			}
+		case daemon.EventToolStart:
+			if formed > 0 {
+				formed--
+				fmt.Fprintln(os.Stderr)
+				break
+			}
+			fmt.Fprintf(os.Stderr, "\n> %v\n", e.Data["tool"])
+		case daemon.EventFinished:
+			status, _ = e.Data["status"].(string)
+			fmt.Println()
+			if msg, ok := e.Data["error"].(string); ok {
+				fmt.Fprintln(os.Stderr, "error:", msg)
+			}
+			fmt.Fprintf(os.Stderr, "%s %s\n", id, status)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return status != daemon.StatusDone, nil
+}
+
+// isLoopback reports whether addr only accepts connections from this
+// machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func preview(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "..."
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/convdiff"
+	"github.com/nickdu2009/learn-claude-code/pkg/replay"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+)
+
+// runDiff compares two recorded conversations. Each source is a file or
+// the ID of a session in .sessions/; models price them with budget.prices.
+func runDiff(sourceA, sourceB, modelA, modelB string, asJSON bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	var transcripts [2]convdiff.Transcript
+	for i, source := range []string{sourceA, sourceB} {
+		messages, err := replay.Load(sessionPath(cwd, source))
+		if err != nil {
+			return err
+		}
+		transcripts[i] = convdiff.Transcript{Label: source, Model: []string{modelA, modelB}[i], Messages: messages}
+	}
+
+	diff := convdiff.Compare(transcripts[0], transcripts[1], tokens.Default(), budget.PricingFromConfig(cfg.Budget))
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// sessionPath returns source when it is a file, else the path of the
+// session with that ID.
+func sessionPath(cwd, source string) string {
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		return filepath.Join(cwd, session.DefaultDir, source+".json")
+	}
+	return source
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/codereview"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/precommit"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+)
+
+// runHook runs the git hook named by args[0], or installs the hooks.
+func runHook(args []string) (failed bool, err error) {
+	if len(args) == 0 {
+		return false, errors.New(usage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "install":
+		fs := flag.NewFlagSet("hook install", flag.ExitOnError)
+		force := fs.Bool("force", false, "replace an existing pre-commit hook")
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 0 {
+			return false, errors.New(usage)
+		}
+		path, err := precommit.Install(ctx, cwd, *force)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("installed %s\n", path)
+		return false, nil
+	case "pre-commit":
+		if len(args) != 1 {
+			return false, errors.New(usage)
+		}
+		return runPreCommit(ctx, cwd)
+	}
+	return false, errors.New(usage)
+}
+
+// runPreCommit checks the staged changes and reports failed when a rule is
+// broken, which makes git abort the commit. Git shows the hook's output, so
+// everything goes to stderr.
+func runPreCommit(ctx context.Context, cwd string) (bool, error) {
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return false, err
+	}
+	rules, err := precommit.Rules(cfg.Hooks.PreCommit)
+	if err != nil {
+		return false, err
+	}
+	diff, err := codereview.StagedDiff(ctx, cwd)
+	if err != nil || strings.TrimSpace(diff) == "" {
+		return false, err
+	}
+
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return false, fmt.Errorf("%w (skip the check with git commit --no-verify)", err)
+	}
+	if m := strings.TrimSpace(cfg.Hooks.PreCommit.Model); m != "" {
+		model = m
+	}
+	result, err := precommit.Check(ctx, diff, precommit.Options{Client: client, Model: model, Rules: rules})
+	if err != nil {
+		return false, fmt.Errorf("pre-commit check: %w (skip it with git commit --no-verify)", err)
+	}
+	fmt.Fprint(os.Stderr, precommit.Format(result))
+	return !result.Passed(), nil
+}
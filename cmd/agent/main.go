@@ -0,0 +1,750 @@
+// agent runs the coding agent: interactively in a chat, or
+// non-interactively for scripts, CI and editors.
+//
+// Usage:
+//
+//	agent chat [-dangerously-skip-permissions] [-read-only] [-profile NAME]
+//	agent batch [flags] tasks.jsonl
+//	agent eval [flags] [suite-dir]
+//	agent review [--staged | --pr N [--post]] [--json]
+//	agent watch --on-change "go test ./..." [-interval 1s] [-max-turns N]
+//	agent daemon [-http ADDR] [-max-turns N]
+//	agent task submit [-session NAME] [-wait] PROMPT...
+//	agent task list | tail ID | cancel ID
+//	agent credentials [status | set KEY | delete KEY | import [.env] | storage-key]
+//	agent stdio [-max-turns N]
+//	agent run [--input-format F] [--output-format F] [-max-turns N] [PROMPT...]
+//	agent replay [-exec] [-from N] [-no-pause] SESSION
+//	agent diff [-model-a M] [-model-b M] [-json] SESSION_A SESSION_B
+//	agent changelog [--since REF] [--version NAME] [--write] [--json]
+//	agent sessions export [-o FILE] SESSION | import FILE
+//	agent stats tools [-since D] [-json]
+//	agent hook pre-commit | install [--force]
+//	agent trust [--revoke]
+//
+// chat reads requests at a prompt and works on the current directory, asking
+// before it writes files or runs programs beyond the workspace. Type a
+// /command (/compact, /cost, /undo, /restore, /fork, /prompt, /profile,
+// /template) or q to quit; @path inlines a file. Flags:
+//
+//	-dangerously-skip-permissions  approve every action and allow dangerous
+//	                               commands (disposable containers and CI only;
+//	                               also dangerously_skip_permissions in the
+//	                               user's settings). Needs the audit log.
+//	-read-only                     offer only tools that leave the workspace
+//	                               unchanged; bash refuses writing commands
+//	-profile NAME                  start with this profile of the config
+//
+// batch runs every prompt in tasks.jsonl as an independent session (see
+// pkg/batch for the file format) and writes <id>.json per task plus
+// report.json to the output directory. Flags:
+//
+//	-c N          run up to N tasks at once (default 1). Tasks share the work
+//	              tree, so parallel tasks should not edit the same files.
+//	-o DIR        output directory (default .batch/<timestamp>)
+//	-max-turns N  model calls allowed per task (default 30, 0 for no limit)
+//	-schema FILE  JSON schema every reply must match; the parsed reply is
+//	              written as "output". Tasks may set their own output_schema.
+//
+// eval runs the benchmark suite in suite-dir (default evals/, see pkg/evals)
+// and prints a score table; it exits 1 unless every task passes. Flags:
+//
+//	-replay  answer from the golden transcripts instead of the model
+//	-update  record the transcripts of passing runs as golden
+//	-keep    keep each task's temporary directory
+//	-json    write the report as JSON to stdout instead of a table
+//
+// review asks the model for a code review of the uncommitted changes, the
+// staged changes (--staged) or a pull request (--pr N) on GitHub, GitLab or
+// Gitea (see the github and forge sections of .agent/config.json), with
+// read-only tools only. Findings (file,
+// line, severity, suggestion) are printed, or with --post submitted as a PR
+// review with inline comments. Check out the PR branch first so the tools see
+// the same code as the diff.
+//
+// watch polls the work tree and runs the --on-change command after every
+// burst of changes. When it fails, an agent turn starts with the failure
+// output and may edit files without asking; the command then runs once more
+// to verify the fix. Edits made by the agent never trigger another turn.
+//
+// daemon serves a task queue for the current directory on the Unix socket
+// .agent/daemon.sock, and with -http on a loopback TCP address to clients
+// sending AGENT_DAEMON_TOKEN as a bearer token (see pkg/daemon). Tasks run one
+// at a time; tasks submitted with the same -session continue one
+// conversation. task is its client: submit prints the task ID, or with -wait
+// streams its progress like tail does. Set AGENT_DAEMON_URL, and
+// AGENT_DAEMON_TOKEN, to reach a daemon over HTTP instead of the socket. The daemon also submits the tasks of the
+// "schedules" section of .agent/config.json on their cron schedules and
+// delivers each finished one to the schedule's webhook or log directory.
+//
+// credentials manages the API keys kept in the OS keychain (see
+// pkg/credentials): set prompts for a key without echo, import copies the
+// keys found in a .env file, storage-key generates the AGENT_STORAGE_KEY that
+// encrypts sessions and audit logs (see pkg/atrest). Every subcommand reads
+// keys missing from the environment and .env from the keychain, unless
+// AGENT_KEYCHAIN=off.
+//
+// stdio (also --stdio) lets an editor drive one conversation over
+// line-delimited JSON-RPC on stdin and stdout (see pkg/stdio). Approval
+// requests and file writes, shown as diffs, go to the editor.
+//
+// run embeds the agent in a pipeline (see pkg/pipeline). The prompt is the
+// arguments or, without any, stdin. --input-format stream-json reads one
+// {"type":"user","content":"..."} message per line of stdin instead, all in
+// one conversation; --output-format stream-json writes every token, tool
+// call and result as a JSON line rather than just the final reply. Both
+// formats default to text. run exits 1 when a turn fails.
+//
+// replay prints a recorded conversation (a session file, a session ID from
+// .sessions/ or an eval transcript) one assistant message at a time, with
+// the tool calls it made and their recorded results. On a terminal it
+// pauses after each step. With -exec every tool call runs again in a
+// scratch worktree of the repository (a copy of the directory outside git)
+// and results that differ from the recording are flagged; the changes the
+// replay made are shown at the end.
+//
+// diff compares two recorded conversations (session files, session IDs or
+// eval transcripts), e.g. the same task run on two models or with two
+// prompts: the message where they diverge, their tool calls aligned with
+// the ones only one side made marked -/+, how often each called every tool,
+// and the token and cost difference. Tokens are estimated from the
+// transcripts; -model-a and -model-b pick the budget.prices entry for each
+// side. -json prints the comparison as JSON (see pkg/convdiff).
+//
+// changelog asks the model for a CHANGELOG section (Keep a Changelog
+// categories) of the commits after --since, by default the latest tag, up to
+// HEAD (see pkg/changelog). The model sees every commit with its changed
+// files and may read their diffs. Every commit and pull request an entry
+// refers to is then checked: commits must be part of the range, pull
+// requests must exist on the configured forge or, without a token, be
+// mentioned by a commit. References that fail are dropped and reported on
+// stderr. --write adds the section to the top of CHANGELOG.md instead of
+// printing it.
+//
+// sessions export packs a stored session into one gzip-compressed tar file
+// (default <id>.agent-session.tar.gz) to move it to another machine or attach
+// it to a bug report: the transcript, the project configuration, the audit
+// log and the file checkpoints of the session (see pkg/bundle). The bundle
+// is plaintext even when AGENT_STORAGE_KEY encrypts the stores. sessions
+// import restores a bundle into the current directory, encrypting with the
+// local key, and keeps the configuration under .agent/imported/ for
+// reference; it refuses to replace a session with the same ID.
+//
+// stats tools reads the audit logs in .audit/, written by agent chat and
+// cmd/agent-server sessions, and prints for every tool how often the model
+// called it and in how many sessions, how often it failed (an error or a
+// non-zero exit), its average output size and its average and 95th
+// percentile duration, most called first. Tools that failed at least half
+// of at least 5 calls are listed with their last error (see
+// pkg/toolstats). -since 168h counts only the last week; -json prints the
+// report as JSON.
+//
+// hook pre-commit checks the staged changes against the rules in the
+// hooks.pre_commit section of .agent/config.json (by default: no TODOs,
+// tests updated, no secrets) with the model named there, usually a cheap
+// one, and exits 1 with what to fix when a rule is broken. Secrets are also
+// found without the model and are masked before the diff is sent. hook
+// install makes it the repository's pre-commit hook; it keeps an existing
+// hook unless --force is given.
+//
+// trust lets the commands run the plugins in .agent/tools and the MCP
+// servers of .agent/config.json (see pkg/trust). A cloned repository could
+// ship anything there, so they are skipped with a warning until the
+// project is trusted, and again after any of them changes. MCP servers of
+// ~/.agent/settings.json and .agent/settings.local.json always start.
+// --revoke withdraws the trust.
+//
+// Except in stdio mode, tools that need approval are not registered or deny
+// every request: nobody is there to answer.
+// batch, daemon, run and watch tell the "webhooks" of .agent/config.json
+// when each run starts, asks for a permission it is denied, completes and
+// fails, with the files it changed (see pkg/notify).
+// The "budget" section of .agent/config.json caps each batch task separately.
+// When .agent/settings.local.json opts in to telemetry, batch, daemon, run,
+// stdio and watch post anonymous usage counters to its endpoint at exit
+// (see pkg/telemetry); AGENT_TELEMETRY=off turns that off.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/batch"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/codereview"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/credentials"
+	"github.com/nickdu2009/learn-claude-code/pkg/evals"
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/jsonschema"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/notify"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/pipeline"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/telemetry"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/nickdu2009/learn-claude-code/pkg/watch"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	usage           = "usage: agent chat [-dangerously-skip-permissions] [-read-only] [-profile NAME]\n       agent batch [-c N] [-o DIR] [-max-turns N] [-schema FILE] tasks.jsonl\n       agent eval [-replay] [-update] [-keep] [-json] [suite-dir]\n       agent review [--staged | --pr N [--post]] [--json]\n       agent watch --on-change CMD [-interval D] [-max-turns N]\n       agent daemon [-http ADDR] [-max-turns N]\n       agent task submit [-session NAME] [-wait] PROMPT... | list | tail ID | cancel ID\n       agent credentials [status | set KEY | delete KEY | import [FILE] | storage-key]\n       agent stdio [-max-turns N]\n       agent run [--input-format text|stream-json] [--output-format text|stream-json] [-max-turns N] [PROMPT...]\n       agent replay [-exec] [-from N] [-no-pause] SESSION\n       agent diff [-model-a M] [-model-b M] [-json] SESSION_A SESSION_B\n       agent changelog [--since REF] [--version NAME] [--write] [--json]\n       agent sessions export [-o FILE] SESSION | import FILE\n       agent stats tools [-since D] [-json]\n       agent hook pre-commit | install [--force]\n       agent trust [--revoke]"
+	defaultEvalsDir = "evals"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var run func() (failed bool, err error)
+	loadKeychain := true
+	switch os.Args[1] {
+	case "chat":
+		fs := flag.NewFlagSet("chat", flag.ExitOnError)
+		skipPermissions := fs.Bool("dangerously-skip-permissions", false, "approve every action without asking (containers/CI only)")
+		readOnly := fs.Bool("read-only", false, "register only non-mutating tools and refuse bash commands that may write")
+		profileName := fs.String("profile", "", "start with this profile from the config's profiles")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return runChat(*skipPermissions, *readOnly, *profileName) }
+	case "batch":
+		fs := flag.NewFlagSet("batch", flag.ExitOnError)
+		concurrency := fs.Int("c", 1, "tasks to run at once")
+		outDir := fs.String("o", "", "output directory (default "+batch.DefaultDir+"/<timestamp>)")
+		maxTurns := fs.Int("max-turns", 30, "model calls allowed per task (0 for no limit)")
+		schema := fs.String("schema", "", "JSON schema file every reply must match")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return runBatch(fs.Arg(0), *outDir, *concurrency, *maxTurns, *schema) }
+	case "eval":
+		fs := flag.NewFlagSet("eval", flag.ExitOnError)
+		replay := fs.Bool("replay", false, "answer from the golden transcripts instead of the model")
+		update := fs.Bool("update", false, "record passing runs as golden transcripts")
+		keep := fs.Bool("keep", false, "keep each task's temporary directory")
+		asJSON := fs.Bool("json", false, "write the report as JSON")
+		_ = fs.Parse(os.Args[2:])
+		dir := defaultEvalsDir
+		switch fs.NArg() {
+		case 0:
+		case 1:
+			dir = fs.Arg(0)
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if *replay && *update {
+			fmt.Fprintln(os.Stderr, "-replay and -update cannot be combined")
+			os.Exit(2)
+		}
+		run = func() (bool, error) {
+			return runEval(dir, evals.Runner{Replay: *replay, Update: *update, KeepWorkDirs: *keep}, *asJSON)
+		}
+	case "review":
+		fs := flag.NewFlagSet("review", flag.ExitOnError)
+		staged := fs.Bool("staged", false, "review the staged changes")
+		pr := fs.Int("pr", 0, "review GitHub pull request N")
+		post := fs.Bool("post", false, "post the findings as a review on the pull request")
+		asJSON := fs.Bool("json", false, "print the findings as JSON")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 || (*staged && *pr > 0) || (*post && *pr <= 0) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runReview(*staged, *pr, *post, *asJSON) }
+	case "watch":
+		fs := flag.NewFlagSet("watch", flag.ExitOnError)
+		command := fs.String("on-change", "", "check to run after changes, e.g. \"go test ./...\"")
+		interval := fs.Duration("interval", watch.DefaultInterval, "how often to poll the work tree")
+		maxTurns := fs.Int("max-turns", 30, "model calls allowed per fix (0 for no limit)")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 || *command == "" {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runWatch(*command, *interval, *maxTurns) }
+	case "daemon":
+		fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+		httpAddr := fs.String("http", "", "also serve HTTP on this loopback address, e.g. 127.0.0.1:8090 (needs AGENT_DAEMON_TOKEN)")
+		maxTurns := fs.Int("max-turns", 30, "model calls allowed per task (0 for no limit)")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runDaemon(*httpAddr, *maxTurns) }
+	case "task":
+		run = func() (bool, error) { return runTask(os.Args[2:]) }
+	case "credentials":
+		// status should tell the environment and the keychain apart.
+		loadKeychain = false
+		run = func() (bool, error) { return false, runCredentials(os.Args[2:]) }
+	case "stdio", "--stdio":
+		fs := flag.NewFlagSet("stdio", flag.ExitOnError)
+		maxTurns := fs.Int("max-turns", 0, "model calls allowed per prompt (0 for no limit)")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runStdio(*maxTurns) }
+	case "run":
+		fs := flag.NewFlagSet("run", flag.ExitOnError)
+		inputFormat := fs.String("input-format", pipeline.FormatText, "text or stream-json (one JSON user message per line)")
+		outputFormat := fs.String("output-format", pipeline.FormatText, "text or stream-json (one JSON event per line)")
+		maxTurns := fs.Int("max-turns", 30, "model calls allowed per message (0 for no limit)")
+		_ = fs.Parse(os.Args[2:])
+		if pipeline.CheckFormat(*inputFormat) != nil || pipeline.CheckFormat(*outputFormat) != nil ||
+			(fs.NArg() > 0 && *inputFormat == pipeline.FormatStreamJSON) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) {
+			return runPipeline(strings.Join(fs.Args(), " "), *inputFormat, *outputFormat, *maxTurns)
+		}
+	case "replay":
+		fs := flag.NewFlagSet("replay", flag.ExitOnError)
+		execute := fs.Bool("exec", false, "run the tool calls again in a scratch workspace")
+		from := fs.Int("from", 1, "first step to show")
+		noPause := fs.Bool("no-pause", false, "do not wait between steps")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runReplay(fs.Arg(0), *execute, *from, !*noPause) }
+	case "diff":
+		fs := flag.NewFlagSet("diff", flag.ExitOnError)
+		modelA := fs.String("model-a", "", "model that produced the first conversation, for pricing")
+		modelB := fs.String("model-b", "", "model that produced the second conversation, for pricing")
+		asJSON := fs.Bool("json", false, "print the comparison as JSON")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		loadKeychain = false
+		run = func() (bool, error) { return false, runDiff(fs.Arg(0), fs.Arg(1), *modelA, *modelB, *asJSON) }
+	case "changelog":
+		fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+		since := fs.String("since", "", "tag or commit the release starts after (default: the latest tag)")
+		version := fs.String("version", "", "version heading the section (default Unreleased)")
+		write := fs.Bool("write", false, "add the section to the top of CHANGELOG.md")
+		asJSON := fs.Bool("json", false, "print the entries as JSON")
+		_ = fs.Parse(os.Args[2:])
+		if fs.NArg() != 0 || (*write && *asJSON) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run = func() (bool, error) { return false, runChangelog(*since, *version, *write, *asJSON) }
+	case "sessions":
+		run = func() (bool, error) { return false, runSessions(os.Args[2:]) }
+	case "stats":
+		run = func() (bool, error) { return false, runStats(os.Args[2:]) }
+	case "hook":
+		run = func() (bool, error) { return runHook(os.Args[2:]) }
+	case "trust":
+		run = func() (bool, error) { return false, runTrust(os.Args[2:]) }
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "no .env file found, using system env")
+	}
+	if loadKeychain && !credentials.Disabled() {
+		if _, err := credentials.LoadEnv(credentials.Keychain(), credentials.Keys...); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+	failed, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runBatch reports whether any task failed.
+func runBatch(path, outDir string, concurrency, maxTurns int, schemaPath string) (bool, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return false, err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return false, err
+	}
+	var schema map[string]any
+	if schemaPath != "" {
+		data, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return false, fmt.Errorf("%s: %w", schemaPath, err)
+		}
+		if err := jsonschema.Check(schema); err != nil {
+			return false, fmt.Errorf("%s: %w", schemaPath, err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	tasks, err := batch.ReadTasks(f)
+	f.Close()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(tasks) == 0 {
+		return false, fmt.Errorf("%s: no tasks", path)
+	}
+	if outDir == "" {
+		outDir = filepath.Join(cwd, batch.DefaultDir, time.Now().Format("20060102-150405"))
+	}
+
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return false, err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer closeTools()
+	systemPrompt := fmt.Sprintf("You are a coding agent at %s. Use tools to solve tasks. Act, don't explain.", cwd)
+	notifications := notifier(cwd, cfg)
+	stats := recorder(cfg, "batch")
+	defer stats.Flush(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = harness.Context(ctx, cfg.Provider)
+	ctx, printRunID := withRunID(ctx)
+	defer printRunID()
+
+	fmt.Printf("running %d tasks (concurrency %d), results in %s\n", len(tasks), max(concurrency, 1), outDir)
+	report, err := batch.Run(ctx, tasks, batch.Options{
+		OutDir:       outDir,
+		Concurrency:  concurrency,
+		MaxTurns:     maxTurns,
+		OutputSchema: schema,
+		Budget:       cfg.Budget,
+		NewAgent: func(task batch.Task, opts ...agent.Option) (*agent.Agent, error) {
+			return agent.New(append(opts,
+				agent.WithRunner(notifications.Runner("batch "+task.ID, stats.Runner(loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd)))),
+				agent.WithClient(client),
+				agent.WithModel(model),
+				agent.WithTools(registry),
+				agent.WithSystemPrompt(systemPrompt),
+			)...)
+		},
+		OnDone: func(res batch.Result) {
+			line := fmt.Sprintf("[%s] %s (%s, %d tokens%s)", res.Status, res.ID,
+				time.Duration(res.DurationMS)*time.Millisecond, res.PromptTokens+res.CompletionTokens, costSuffix(res.Cost))
+			if res.Error != "" {
+				line += ": " + res.Error
+			}
+			fmt.Println(line)
+		},
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return false, err
+	}
+	fmt.Printf("%d/%d succeeded, %d tokens%s, report: %s\n",
+		report.Succeeded, report.Total, report.PromptTokens+report.CompletionTokens, costSuffix(report.Cost), filepath.Join(outDir, batch.ReportFile))
+	return report.Failed > 0, nil
+}
+
+// costSuffix formats an estimated cost for a summary line; without prices
+// (see budget.prices in .agent/config.json) there is none.
+func costSuffix(cost float64) string {
+	if cost <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", cost≈%.4f", cost)
+}
+
+// runEval reports whether any task failed.
+func runEval(dir string, runner evals.Runner, asJSON bool) (bool, error) {
+	tasks, err := evals.LoadTasks(dir)
+	if err != nil {
+		return false, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return false, err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer closeTools()
+	runner.Tools = registry
+	runner.SystemPrompt = "You are a coding agent. The task's files are in the current directory. Use tools to solve tasks. Act, don't explain."
+	if !runner.Replay {
+		if runner.Client, runner.Model, err = provider.New(cfg.Provider); err != nil {
+			return false, err
+		}
+		runner.Pricing = budget.PricingFromConfig(cfg.Budget)
+	}
+	if !asJSON {
+		runner.OnScore = func(s evals.Score) {
+			result := "FAIL"
+			if s.Passed {
+				result = "PASS"
+			}
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", result, s.Task)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = harness.Context(ctx, cfg.Provider)
+	ctx, printRunID := withRunID(ctx)
+	defer printRunID()
+	report, err := runner.Run(ctx, tasks)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return false, err
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return false, err
+		}
+	} else if err := report.WriteText(os.Stdout); err != nil {
+		return false, err
+	}
+	return report.Passed < report.Total, nil
+}
+
+func runReview(staged bool, prNumber int, post, asJSON bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		diff     string
+		host     forge.Forge
+		commitID string
+	)
+	switch {
+	case prNumber > 0:
+		var ok bool
+		if host, ok = forge.FromConfig(cfg, cwd); !ok {
+			return fmt.Errorf("--pr needs a token in %s", forge.TokenEnv(cfg))
+		}
+		pr, err := host.GetPR(ctx, "", prNumber)
+		if err != nil {
+			return err
+		}
+		commitID = pr.HeadSHA
+		if diff, err = host.PRDiff(ctx, "", prNumber); err != nil {
+			return err
+		}
+	case staged:
+		diff, err = codereview.StagedDiff(ctx, cwd)
+	default:
+		diff, err = loop.GitDiff(ctx, cwd)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	result, err := codereview.Run(ctx, diff, codereview.Options{Client: client, Model: model})
+	if err != nil {
+		return err
+	}
+	if post {
+		if err := codereview.PostReview(ctx, host, "", prNumber, diff, commitID, result); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "posted %d findings to pull request #%d\n", len(result.Findings), prNumber)
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	fmt.Print(codereview.Format(result))
+	return nil
+}
+
+func runWatch(command string, interval time.Duration, maxTurns int) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return err
+	}
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeTools()
+	stats := recorder(cfg, "watch")
+	defer stats.Flush(context.Background())
+	a, err := agent.New(
+		agent.WithClient(client),
+		agent.WithModel(model),
+		agent.WithTools(registry),
+		agent.WithSystemPrompt(fmt.Sprintf("You are a coding agent at %s. Use tools to solve tasks. Act, don't explain.", cwd)),
+		agent.WithMaxTurns(maxTurns),
+		agent.WithRunner(notifier(cwd, cfg).Runner("watch", stats.Runner(loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd)))),
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = harness.Context(ctx, cfg.Provider)
+	ctx, printRunID := withRunID(ctx)
+	defer printRunID()
+	fmt.Printf("watching %s, running %q on change (Ctrl-C to stop)\n", cwd, command)
+	return watch.Run(ctx, watch.Options{
+		Root:     cwd,
+		Command:  command,
+		Interval: interval,
+		Fix: func(ctx context.Context, prompt string) error {
+			fmt.Println("check failed, asking the agent to fix it...")
+			// Every failure starts a fresh conversation about the current code.
+			a.Reset()
+			_, err := a.Stream(ctx, prompt, func(token string) { fmt.Print(token) })
+			fmt.Println()
+			return err
+		},
+		OnCheck: func(c watch.Check) {
+			switch {
+			case c.FixErr != nil:
+				fmt.Fprintln(os.Stderr, "agent error:", c.FixErr)
+			case c.AfterFix && c.Passed:
+				fmt.Println("fixed: check passes")
+			case c.AfterFix:
+				fmt.Printf("still failing after the fix:\n%s\nwaiting for the next change\n", c.Output)
+			case c.Passed:
+				fmt.Printf("%s ok (%d changed)\n", c.At.Format("15:04:05"), len(c.Changes))
+			default:
+				fmt.Printf("%s FAIL (%d changed)\n%s\n", c.At.Format("15:04:05"), len(c.Changes), c.Output)
+			}
+		},
+	})
+}
+
+// baseTools registers the tools that work without a human to approve them,
+// plus the extensions of the project (see harness.RegisterExtensions); the
+// returned func stops them. Paths resolve against the process working
+// directory at call time; bash commands run within cfg.Limits, offline with
+// cfg.IsolateNetwork unless the run has an approver to let them out.
+func baseTools(cwd string, cfg config.Config) (*tools.Registry, func(), error) {
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: ignoring %s in the project config; set these in ~/.agent/settings.json or .agent/settings.local.json instead\n", strings.Join(cfg.Ignored, ", "))
+	}
+	registry := tools.New()
+	if err := harness.RegisterBaseTools(registry, cwd, cfg); err != nil {
+		return nil, nil, err
+	}
+	optional := harness.RegisterTools(registry, cwd, cfg, nil)
+	// Plugins, writes through sql_query, MCP tools and network access that
+	// ask for approval are denied unless the run has an approver (stdio
+	// mode); the webhooks of a notified run hear about it.
+	approver := permission.Contextual(notify.Approver(permission.DenyAll))
+	builtin := telemetry.ToolNames(registry)
+	extensions, err := harness.RegisterExtensions(registry, cwd, cfg, trusted(cwd, cfg), approver)
+	if err != nil {
+		optional.Close()
+		return nil, nil, err
+	}
+	if cfg.IsolateNetwork {
+		registry = registry.WithMiddleware(tools.NetworkGate(approver))
+	}
+	registry = harness.CheckOutput(registry.WithMiddleware(telemetry.Middleware(builtin...)), cfg)
+	return registry, func() {
+		extensions.Close()
+		optional.Close()
+	}, nil
+}
+
+// withRunID starts a trace run for a subcommand. The returned func prints
+// its ID to stderr, for the deferred call at exit, so the run can be found
+// in the logs, audit records, LLM dumps and metrics.
+func withRunID(ctx context.Context) (context.Context, func()) {
+	runID := trace.NewRunID()
+	return trace.WithRun(ctx, runID), func() { fmt.Fprintf(os.Stderr, "run id: %s\n", runID) }
+}
+
+// recorder returns the telemetry recorder of command, nil unless the local
+// settings opt in.
+func recorder(cfg config.Config, command string) *telemetry.Recorder {
+	return telemetry.New(cfg.Telemetry, command, provider.Name(cfg.Provider), func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+	})
+}
+
+// notifier returns the notifier for the webhooks in cfg, nil without any
+// or when the project is not trusted.
+func notifier(cwd string, cfg config.Config) *notify.Notifier {
+	webhooks := cfg.Webhooks
+	if ok, _ := projectTrusted(cwd, cfg); !ok {
+		webhooks = nil
+	}
+	return notify.New(webhooks, cwd, func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+	})
+}
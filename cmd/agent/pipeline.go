@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/pipeline"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+// runPipeline runs prompt, or the messages on stdin when it is empty, and
+// writes the results to stdout in outputFormat. Stdout carries nothing
+// else; diagnostics go to stderr.
+func runPipeline(prompt, inputFormat, outputFormat string, maxTurns int) (bool, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return false, err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return false, err
+	}
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return false, err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer closeTools()
+
+	stats := recorder(cfg, "run")
+	defer stats.Flush(context.Background())
+
+	var input io.Reader = os.Stdin
+	if prompt != "" {
+		input = strings.NewReader(prompt)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = harness.Context(ctx, cfg.Provider)
+	ctx, printRunID := withRunID(ctx)
+	defer printRunID()
+	return pipeline.Run(ctx, pipeline.Config{
+		Client:       client,
+		Model:        model,
+		Registry:     registry,
+		SystemPrompt: fmt.Sprintf("You are a coding agent at %s. Use tools to solve tasks. Act, don't explain.", cwd),
+		MaxTurns:     maxTurns,
+		Runner:       notifier(cwd, cfg).Runner("run", stats.Runner(loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd))),
+		InputFormat:  inputFormat,
+		OutputFormat: outputFormat,
+	}, input, os.Stdout)
+}
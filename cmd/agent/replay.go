@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/orchestrator"
+	"github.com/nickdu2009/learn-claude-code/pkg/replay"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+const maxReplayLines = 20
+
+// runReplay prints a recorded conversation step by step. source is a file
+// or the ID of a session in .sessions/.
+func runReplay(source string, execute bool, from int, pause bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path := sessionPath(cwd, source)
+	messages, err := replay.Load(path)
+	if err != nil {
+		return err
+	}
+	steps := replay.Steps(messages)
+	if len(steps) == 0 {
+		return fmt.Errorf("%s: no assistant messages", path)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var registry *tools.Registry
+	if execute {
+		cfg, err := config.Load(cwd)
+		if err != nil {
+			return err
+		}
+		ws, err := scratchWorkspace(ctx, cwd)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if ws.Diff != nil {
+				if diff, err := ws.Diff(context.Background()); err == nil && diff != "" {
+					fmt.Printf("── changes made by the replay ──\n%s", diff)
+				}
+			}
+			_ = ws.Cleanup()
+		}()
+		// The file tools resolve paths against the working directory.
+		if err := os.Chdir(ws.Dir); err != nil {
+			return err
+		}
+		defer os.Chdir(cwd)
+		var closeTools func()
+		if registry, closeTools, err = baseTools(ws.Dir, cfg); err != nil {
+			return err
+		}
+		defer closeTools()
+		fmt.Printf("re-executing tool calls in %s\n", ws.Dir)
+	}
+
+	info, err := os.Stdin.Stat()
+	pause = pause && err == nil && info.Mode()&os.ModeCharDevice != 0
+	input := bufio.NewReader(os.Stdin)
+	diverged := 0
+	for _, step := range steps {
+		if step.Number < from {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Printf("── step %d/%d (message %d) ──\n", step.Number, len(steps), step.Message)
+		if step.Prompt != "" {
+			printBlock("user", step.Prompt)
+		}
+		if step.Text != "" {
+			printBlock("assistant", step.Text)
+		}
+		var results []replay.Result
+		if registry != nil {
+			results = replay.Execute(ctx, registry, step)
+		}
+		for i, call := range step.Calls {
+			fmt.Printf("→ %s %s\n", call.Name, call.Arguments)
+			if call.Missing {
+				fmt.Println("  recorded: (no result, the run ended here)")
+			} else {
+				printBlock("  recorded", call.Recorded)
+			}
+			if results == nil {
+				continue
+			}
+			output := results[i].Output
+			if results[i].Err != nil {
+				output = "error: " + results[i].Err.Error()
+			}
+			label := "  replayed (same)"
+			if results[i].Diverged {
+				label = "  replayed (DIVERGED)"
+				diverged++
+			}
+			printBlock(label, output)
+		}
+
+		if pause && step.Number < len(steps) {
+			fmt.Fprint(os.Stderr, "[enter] next  [c] continue  [q] quit: ")
+			line, err := input.ReadString('\n')
+			if err != nil {
+				return nil
+			}
+			switch strings.TrimSpace(line) {
+			case "c":
+				pause = false
+			case "q":
+				return nil
+			}
+		}
+	}
+	if execute {
+		fmt.Printf("%d tool call(s) diverged from the recording\n", diverged)
+	}
+	return nil
+}
+
+// scratchWorkspace returns a worktree of the repository at HEAD, or a copy
+// of dir when it is not a git repository.
+func scratchWorkspace(ctx context.Context, dir string) (orchestrator.Workspace, error) {
+	if ws, err := (orchestrator.GitWorktrees{Repo: dir}).Create(ctx, "replay"); err == nil {
+		return ws, nil
+	}
+	return orchestrator.TempDirs{Root: dir}.Create(ctx, "replay")
+}
+
+// printBlock prints text under label, indented and cut to maxReplayLines.
+func printBlock(label, text string) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	fmt.Printf("%s:\n", label)
+	indent := strings.Repeat(" ", len(label)-len(strings.TrimLeft(label, " "))+4)
+	for i, line := range lines {
+		if i == maxReplayLines {
+			fmt.Printf("%s… (%d more lines)\n", indent, len(lines)-i)
+			break
+		}
+		fmt.Println(indent + line)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/bundle"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+// bundleSuffix names exported bundles when -o is not given.
+const bundleSuffix = ".agent-session.tar.gz"
+
+// runSessions exports a stored session as a bundle or imports one.
+func runSessions(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("sessions export", flag.ExitOnError)
+		out := fs.String("o", "", "bundle file to write (default <id>"+bundleSuffix+")")
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return errors.New(usage)
+		}
+		id := fs.Arg(0)
+		cfg, err := config.Load(cwd)
+		if err != nil {
+			return err
+		}
+		path := *out
+		if path == "" {
+			path = id + bundleSuffix
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		m, err := bundle.Export(f, cwd, id, cfg)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+			return err
+		}
+		fmt.Printf("exported session %s to %s: %s\n", m.Session, path, bundleSummary(m))
+		fmt.Println("the bundle is not encrypted; it holds the whole transcript and tool output")
+		return nil
+	case "import":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		m, err := bundle.Import(f, cwd)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[1], err)
+		}
+		fmt.Printf("imported session %s: %s\n", m.Session, bundleSummary(m))
+		fmt.Printf("replay it with agent replay %s or continue it with agent task submit -session %s; its config snapshot is in %s\n", m.Session, m.Session, bundle.ImportedConfigDir)
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+func bundleSummary(m bundle.Manifest) string {
+	return fmt.Sprintf("%d messages, %d audit records, %d checkpoints", m.Messages, m.AuditRecords, m.Checkpoints)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/toolstats"
+)
+
+// runStats prints usage statistics gathered from the audit logs.
+func runStats(args []string) error {
+	if len(args) == 0 || args[0] != "tools" {
+		return errors.New(usage)
+	}
+	fs := flag.NewFlagSet("stats tools", flag.ExitOnError)
+	since := fs.Duration("since", 0, "only count calls made within this duration, e.g. 168h")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args[1:])
+	if fs.NArg() != 0 {
+		return errors.New(usage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	report, err := toolstats.Load(filepath.Join(cwd, audit.DefaultDir), from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: skipped audit logs:", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
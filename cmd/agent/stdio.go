@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/stdio"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+// runStdio serves the JSON-RPC protocol of pkg/stdio on stdin and stdout.
+// Stdout carries nothing else; diagnostics go to stderr.
+func runStdio(maxTurns int) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	if err := tools.SetAdditionalDirectories(cfg.Workspace.Directories(cwd)); err != nil {
+		return err
+	}
+	client, model, err := provider.New(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	registry, closeTools, err := baseTools(cwd, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeTools()
+	// The client can answer approvals, so writes are shown to it as diffs
+	// and the tools that need approval are available.
+	approver := permission.Contextual(nil)
+	registry.Register(tools.ReplaceInFilesToolDef(), tools.NewReplaceInFilesHandler(approver))
+	registry = registry.WithMiddleware(tools.NewWriteGate(approver).Middleware())
+	stats := recorder(cfg, "stdio")
+	defer stats.Flush(context.Background())
+
+	return stdio.Serve(harness.Context(context.Background(), cfg.Provider), stdio.Config{
+		Client:       client,
+		Model:        model,
+		Registry:     registry,
+		SystemPrompt: fmt.Sprintf("You are a coding agent at %s. Use tools to solve tasks. Act, don't explain.", cwd),
+		MaxTurns:     maxTurns,
+		Runner:       stats.Runner(loop.WithReviewFromEnv(loop.WithFixUntilGreenFromEnv(loop.Run, cwd), cwd)),
+	}, os.Stdin, os.Stdout)
+}
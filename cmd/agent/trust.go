@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/harness"
+	"github.com/nickdu2009/learn-claude-code/pkg/trust"
+)
+
+// runTrust trusts the current project, as it is now, to run its plugins and
+// the MCP servers, schedules and webhooks of its config, or with --revoke
+// forgets that trust.
+func runTrust(args []string) error {
+	fs := flag.NewFlagSet("trust", flag.ExitOnError)
+	revoke := fs.Bool("revoke", false, "stop trusting the project")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New(usage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if *revoke {
+		return trust.Revoke(cwd)
+	}
+	cfg, err := config.Load(cwd)
+	if err != nil {
+		return err
+	}
+	fp, err := trust.Fingerprint(cwd, cfg)
+	if err != nil {
+		return err
+	}
+	if fp == "" {
+		fmt.Println("nothing to trust: the project has no plugins, MCP servers, schedules or webhooks")
+		return nil
+	}
+	programs, err := trust.Programs(cwd, cfg)
+	if err != nil {
+		return err
+	}
+	if err := trust.Grant(cwd, fp); err != nil {
+		return err
+	}
+	fmt.Printf("trusted %s to run:\n", cwd)
+	for _, program := range programs {
+		fmt.Printf("  %s\n", program)
+	}
+	return nil
+}
+
+// trusted reports whether the plugins and the project MCP servers,
+// schedules and webhooks of the project at cwd may run, warning when they
+// may not. Nobody is asked: trust is granted with `agent trust`.
+func trusted(cwd string, cfg config.Config) bool {
+	return harness.TrustProject(cwd, cfg, nil)
+}
+
+// projectTrusted is trusted without the warning, for the parts of the
+// project config checked after baseTools has warned.
+func projectTrusted(cwd string, cfg config.Config) (bool, error) {
+	fp, err := trust.Fingerprint(cwd, cfg)
+	if err != nil {
+		return false, err
+	}
+	return trust.Trusted(cwd, fp), nil
+}
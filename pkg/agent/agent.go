@@ -0,0 +1,207 @@
+// Package agent embeds the coding agent as a library:
+//
+//	a, err := agent.New(
+//		agent.WithModel("qwen-plus"),
+//		agent.WithTools(registry),
+//		agent.WithSystemPrompt("You are a coding agent."),
+//		agent.WithMaxTurns(20),
+//	)
+//	reply, err := a.Run(ctx, "fix the failing test")
+//
+// An Agent keeps its conversation between calls; use Reset to start over.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/jsonschema"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/qwen"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// PermissionCallback decides whether a side-effecting tool call may proceed.
+type PermissionCallback func(ctx context.Context, req permission.Request) (bool, error)
+
+// Agent runs the agent loop over a persistent conversation. It is safe for
+// concurrent use; calls are serialized.
+type Agent struct {
+	client       *openai.Client
+	model        string
+	registry     *tools.Registry
+	systemPrompt string
+	maxTurns     int
+	approver     permission.Approver
+	runner       loop.AgentRunner
+	outputSchema map[string]any
+
+	mu       sync.Mutex
+	messages []openai.ChatCompletionMessageParamUnion
+}
+
+// Option configures an Agent.
+type Option func(*Agent)
+
+// WithClient sets the OpenAI-compatible client. Defaults to qwen.NewClient.
+func WithClient(client *openai.Client) Option {
+	return func(a *Agent) {
+		a.client = client
+	}
+}
+
+// WithModel sets the chat model. Defaults to qwen.Model.
+func WithModel(model string) Option {
+	return func(a *Agent) {
+		a.model = model
+	}
+}
+
+// WithTools sets the tools the model may call. Defaults to none.
+func WithTools(registry *tools.Registry) Option {
+	return func(a *Agent) {
+		a.registry = registry
+	}
+}
+
+// WithSystemPrompt sets the system message that starts every conversation.
+func WithSystemPrompt(prompt string) Option {
+	return func(a *Agent) {
+		a.systemPrompt = prompt
+	}
+}
+
+// WithMaxTurns limits each Run to n model calls; n <= 0 means no limit.
+func WithMaxTurns(n int) Option {
+	return func(a *Agent) {
+		a.maxTurns = n
+	}
+}
+
+// WithPermissionCallback answers approval requests from tools built with
+// permission.Contextual, e.g. tools.NewSQLQueryHandler.
+func WithPermissionCallback(cb PermissionCallback) Option {
+	return func(a *Agent) {
+		if cb != nil {
+			a.approver = permission.ApproverFunc(cb)
+		}
+	}
+}
+
+// WithRunner replaces the agent loop, e.g. with a wrapper such as
+// loop.RunWithReview. Defaults to loop.Run.
+func WithRunner(runner loop.AgentRunner) Option {
+	return func(a *Agent) {
+		a.runner = runner
+	}
+}
+
+// WithOutputSchema makes every reply a JSON value matching schema, for
+// callers that parse the result (see loop.RunWithOutputSchema).
+func WithOutputSchema(schema map[string]any) Option {
+	return func(a *Agent) {
+		a.outputSchema = schema
+	}
+}
+
+func New(opts ...Option) (*Agent, error) {
+	a := &Agent{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.client == nil {
+		client, err := qwen.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("create client: %w", err)
+		}
+		a.client = client
+	}
+	if strings.TrimSpace(a.model) == "" {
+		a.model = qwen.Model()
+	}
+	if a.registry == nil {
+		a.registry = tools.New()
+	}
+	if a.runner == nil {
+		a.runner = loop.Run
+	}
+	if a.outputSchema != nil {
+		if err := jsonschema.Check(a.outputSchema); err != nil {
+			return nil, fmt.Errorf("output schema: %w", err)
+		}
+		a.runner = loop.RunWithOutputSchema(loop.OutputOptions{Schema: a.outputSchema, Runner: a.runner})
+	}
+	a.messages = a.initialMessages()
+	return a, nil
+}
+
+// Run sends prompt as the next user message and returns the final assistant
+// reply. On error the conversation keeps whatever the loop completed.
+func (a *Agent) Run(ctx context.Context, prompt string) (string, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("prompt is required")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.maxTurns > 0 {
+		ctx = loop.WithMaxTurns(ctx, a.maxTurns)
+	}
+	if a.approver != nil {
+		ctx = permission.WithApprover(ctx, a.approver)
+	}
+
+	messages := append(a.messages, openai.UserMessage(prompt))
+	history, err := a.runner(ctx, a.client, a.model, messages, a.registry)
+	if len(history) > 0 {
+		a.messages = history
+	} else {
+		a.messages = messages
+	}
+	if err != nil {
+		return "", err
+	}
+	return finalText(a.messages), nil
+}
+
+// Stream is like Run but passes assistant text deltas to onToken as they
+// arrive.
+func (a *Agent) Stream(ctx context.Context, prompt string, onToken loop.TokenHandler) (string, error) {
+	return a.Run(loop.WithTokenHandler(ctx, onToken), prompt)
+}
+
+// Messages returns a copy of the conversation so far.
+func (a *Agent) Messages() []openai.ChatCompletionMessageParamUnion {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]openai.ChatCompletionMessageParamUnion(nil), a.messages...)
+}
+
+// Reset drops the conversation, keeping only the system prompt.
+func (a *Agent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = a.initialMessages()
+}
+
+func (a *Agent) initialMessages() []openai.ChatCompletionMessageParamUnion {
+	if strings.TrimSpace(a.systemPrompt) == "" {
+		return nil
+	}
+	return []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(a.systemPrompt)}
+}
+
+func finalText(messages []openai.ChatCompletionMessageParamUnion) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	if last := messages[len(messages)-1]; last.OfAssistant != nil {
+		return last.OfAssistant.Content.OfString.Value
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestAgent_RunKeepsConversation(t *testing.T) {
+	client := newTestClient(t, stopResponse("first"), stopResponse("second"))
+	a, err := New(WithClient(client), WithModel("mock-model"), WithSystemPrompt("be brief"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if reply, err := a.Run(context.Background(), "one"); err != nil || reply != "first" {
+		t.Fatalf("Run = %q, %v", reply, err)
+	}
+	if reply, err := a.Run(context.Background(), "two"); err != nil || reply != "second" {
+		t.Fatalf("Run = %q, %v", reply, err)
+	}
+	// system, user, assistant, user, assistant
+	if got := len(a.Messages()); got != 5 {
+		t.Fatalf("messages = %d, want 5", got)
+	}
+
+	a.Reset()
+	if got := len(a.Messages()); got != 1 {
+		t.Fatalf("messages after Reset = %d, want system only", got)
+	}
+}
+
+func TestAgent_MaxTurnsStopsToolLoop(t *testing.T) {
+	client := newTestClient(t, toolCallResponse("call-1", "noop"), toolCallResponse("call-2", "noop"))
+	registry := tools.New()
+	registry.Register(toolDef("noop"), func(context.Context, map[string]any) (string, error) { return "ok", nil })
+
+	a, err := New(WithClient(client), WithTools(registry), WithMaxTurns(1))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := a.Run(context.Background(), "go"); !errors.Is(err, loop.ErrMaxTurns) {
+		t.Fatalf("expected ErrMaxTurns, got %v", err)
+	}
+}
+
+func TestAgent_PermissionCallbackAnswersTools(t *testing.T) {
+	guard := permission.Contextual(permission.DenyAll)
+	registry := tools.New()
+	registry.Register(toolDef("guarded"), func(ctx context.Context, _ map[string]any) (string, error) {
+		if ok, _ := guard.Approve(ctx, permission.Request{Tool: "guarded"}); ok {
+			return "approved", nil
+		}
+		return "denied", nil
+	})
+
+	var asked []string
+	a, err := New(
+		WithClient(newTestClient(t)),
+		WithTools(registry),
+		WithPermissionCallback(func(_ context.Context, req permission.Request) (bool, error) {
+			asked = append(asked, req.Tool)
+			return true, nil
+		}),
+		WithRunner(func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			output, err := registry.Dispatch(ctx, "guarded", nil)
+			return append(messages, openai.AssistantMessage(output)), err
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	reply, err := a.Run(context.Background(), "go")
+	if err != nil || reply != "approved" {
+		t.Fatalf("Run = %q, %v", reply, err)
+	}
+	if len(asked) != 1 || asked[0] != "guarded" {
+		t.Fatalf("callback calls = %v", asked)
+	}
+}
+
+func TestAgent_RunRejectsEmptyPrompt(t *testing.T) {
+	a, err := New(WithClient(newTestClient(t)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := a.Run(context.Background(), "  "); err == nil {
+		t.Fatal("expected error for empty prompt")
+	}
+}
+
+func newTestClient(t *testing.T, responses ...map[string]any) *openai.Client {
+	t.Helper()
+
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(responses) == 0 {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	t.Cleanup(srv.Close)
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(srv.URL+"/v1/"),
+		option.WithMaxRetries(0),
+	)
+	return &client
+}
+
+func stopResponse(content string) map[string]any {
+	return completion("stop", map[string]any{"role": "assistant", "content": content})
+}
+
+func toolCallResponse(id, name string) map[string]any {
+	return completion("tool_calls", map[string]any{
+		"role":    "assistant",
+		"content": "",
+		"tool_calls": []map[string]any{{
+			"id":       id,
+			"type":     "function",
+			"function": map[string]any{"name": name, "arguments": "{}"},
+		}},
+	})
+}
+
+func completion(finishReason string, message map[string]any) map[string]any {
+	return map[string]any{
+		"id":      "mock-id",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   "mock-model",
+		"choices": []map[string]any{{"index": 0, "finish_reason": finishReason, "message": message}},
+	}
+}
+
+func toolDef(name string) openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: name}}
+}
@@ -0,0 +1,108 @@
+// Package atrest encrypts what the agent keeps on disk, stored sessions and
+// audit logs, with AES-256-GCM. Transcripts routinely hold proprietary
+// source code and command output.
+//
+// Encryption is on when AGENT_STORAGE_KEY holds a key (base64 of 32 bytes,
+// see NewKey); like the API keys it is read from the OS keychain when the
+// environment lacks it. Sealed data is text: a "agent-sealed:v1:" prefix
+// followed by base64 of nonce and ciphertext, so it also fits on one line of
+// a JSONL file. Open passes unsealed data through, so files written before
+// encryption was turned on stay readable.
+package atrest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyEnv is the environment variable (and keychain entry) holding the key.
+const KeyEnv = "AGENT_STORAGE_KEY"
+
+const prefix = "agent-sealed:v1:"
+
+// ErrNoKey is returned when opening sealed data without a key.
+var ErrNoKey = errors.New("data is encrypted; set " + KeyEnv + " or store it in the keychain")
+
+// Cipher seals and opens data. A nil *Cipher leaves data in plaintext.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewKey returns a fresh random key in the form KeyEnv expects.
+func NewKey() string {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// New returns a cipher for key, base64 of 32 bytes.
+func New(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("%s must be base64 of 32 bytes", KeyEnv)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// FromEnv returns the cipher for KeyEnv, or nil when it is unset.
+func FromEnv() (*Cipher, error) {
+	key := strings.TrimSpace(os.Getenv(KeyEnv))
+	if key == "" {
+		return nil, nil
+	}
+	return New(key)
+}
+
+// Seal encrypts plain. A nil cipher returns plain unchanged.
+func (c *Cipher) Seal(plain []byte) []byte {
+	if c == nil {
+		return plain
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	sealed := c.aead.Seal(nonce, nonce, plain, nil)
+	out := make([]byte, len(prefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, prefix)
+	base64.StdEncoding.Encode(out[len(prefix):], sealed)
+	return out
+}
+
+// Open decrypts data written by Seal and returns anything else unchanged.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrNoKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)[len(prefix):]))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("corrupt encrypted data")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: wrong %s or corrupt data", KeyEnv)
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data was written by Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(prefix))
+}
@@ -0,0 +1,49 @@
+package atrest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCipher_SealsOpensAndPassesPlaintextThrough(t *testing.T) {
+	c, err := New(NewKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte(`{"messages":["func secret() {}"]}`)
+	sealed := c.Seal(plain)
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("secret")) || bytes.ContainsRune(sealed, '\n') {
+		t.Fatalf("sealed = %s", sealed)
+	}
+	if got, err := c.Open(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open = %s, %v", got, err)
+	}
+	if got, err := c.Open(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("plaintext should pass through: %s, %v", got, err)
+	}
+
+	var none *Cipher
+	if got := none.Seal(plain); !bytes.Equal(got, plain) {
+		t.Fatalf("nil cipher sealed: %s", got)
+	}
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("nil cipher opening sealed data: %v", err)
+	}
+	other, _ := New(NewKey())
+	if _, err := other.Open(sealed); err == nil || !strings.Contains(err.Error(), "wrong") {
+		t.Fatalf("wrong key: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	if c, err := FromEnv(); c != nil || err != nil {
+		t.Fatalf("unset key = %v, %v", c, err)
+	}
+	t.Setenv(KeyEnv, "c2hvcnQ=")
+	if _, err := FromEnv(); err == nil || !strings.Contains(err.Error(), "32 bytes") {
+		t.Fatalf("short key: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+// Package audit keeps an append-only JSONL record of every tool the agent
+// executes, one file per session, so its effect on the machine can always be
+// reconstructed afterwards.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+)
+
+const (
+	// DefaultDir is relative to the project root.
+	DefaultDir           = ".audit"
+	defaultMaxOutputSize = 4000
+	redactedValue        = "[REDACTED]"
+)
+
+var sensitiveArgFragments = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "cookie"}
+
+// Record is one executed tool call. RunID and SpanID locate it in the agent
+// run that made it (see package trace).
+type Record struct {
+	Time       time.Time      `json:"time"`
+	Session    string         `json:"session"`
+	RunID      string         `json:"run_id,omitempty"`
+	SpanID     string         `json:"span_id,omitempty"`
+	Tool       string         `json:"tool"`
+	Args       map[string]any `json:"args"`
+	Output     string         `json:"output"`
+	OutputSize int            `json:"output_size"`
+	Truncated  bool           `json:"truncated,omitempty"`
+	// Status is "ok" or "error" (the handler returned an error).
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	DurationMS int64      `json:"duration_ms"`
+	Approvals  []Decision `json:"approvals,omitempty"`
+}
+
+// Decision is a user approval answered while the tool ran.
+type Decision struct {
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	Approved bool   `json:"approved"`
+	// Bypassed is set when permission checks were skipped and nobody was asked.
+	Bypassed bool   `json:"bypassed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Logger appends records to <dir>/<session>.jsonl. The file is opened in
+// append-only mode and never rewritten. When AGENT_STORAGE_KEY is set each
+// line is encrypted on its own (see package atrest).
+type Logger struct {
+	session   string
+	path      string
+	maxOutput int
+	cipher    *atrest.Cipher
+
+	mu   sync.Mutex
+	file *os.File
+	now  func() time.Time
+}
+
+// Open creates (or continues) the audit file for session under dir.
+func Open(dir, session string) (*Logger, error) {
+	if strings.TrimSpace(session) == "" {
+		return nil, fmt.Errorf("audit session id is required")
+	}
+	if strings.ContainsAny(session, `/\`) {
+		return nil, fmt.Errorf("invalid audit session id %q", session)
+	}
+	c, err := atrest.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+	path := filepath.Join(dir, session+".jsonl")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &Logger{session: session, path: path, maxOutput: defaultMaxOutputSize, cipher: c, file: file, now: time.Now}, nil
+}
+
+// NewSessionID returns a sortable, unique session identifier.
+func NewSessionID() string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:])
+}
+
+func (l *Logger) Path() string { return l.path }
+
+// ReadRecords decodes the JSON lines written by a Logger, decrypting them
+// with AGENT_STORAGE_KEY when they are encrypted.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	c, err := atrest.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	lines := bufio.NewReader(r)
+	for {
+		line, readErr := lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			data, err := c.Open(line)
+			if err != nil {
+				return records, fmt.Errorf("decode audit record: %w", err)
+			}
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return records, fmt.Errorf("decode audit record: %w", err)
+			}
+			records = append(records, rec)
+		}
+		if readErr == io.EOF {
+			return records, nil
+		} else if readErr != nil {
+			return records, fmt.Errorf("read audit log: %w", readErr)
+		}
+	}
+}
+
+// Write appends one record as a single JSON line.
+func (l *Logger) Write(rec Record) error {
+	rec.Session = l.session
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode audit record: %w", err)
+	}
+	data = append(l.cipher.Seal(data), '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return nil
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Middleware records every dispatched tool call. Failing to write the audit
+// record fails the tool call, so nothing runs unrecorded without the model
+// (and user) seeing it.
+func (l *Logger) Middleware() tools.Middleware {
+	return func(name string, next tools.Handler) tools.Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, exitStatus := tools.WithExitStatus(ctx)
+			decisions := &decisionLog{}
+			ctx = context.WithValue(ctx, decisionKey{}, decisions)
+
+			start := l.now()
+			output, err := next(ctx, args)
+
+			ids := trace.FromContext(ctx)
+			rec := Record{
+				Time:       start.UTC(),
+				RunID:      ids.RunID,
+				SpanID:     ids.SpanID,
+				Tool:       name,
+				Args:       redactArgs(args),
+				OutputSize: len(output),
+				Status:     "ok",
+				DurationMS: l.now().Sub(start).Milliseconds(),
+				Approvals:  decisions.list(),
+			}
+			rec.Output, rec.Truncated = truncate(output, l.maxOutput)
+			if err != nil {
+				rec.Status = "error"
+				rec.Error = err.Error()
+			}
+			if code, ok := exitStatus(); ok {
+				rec.ExitCode = &code
+			}
+
+			if writeErr := l.Write(rec); writeErr != nil {
+				if err != nil {
+					return output, err
+				}
+				return output, fmt.Errorf("tool ran but audit failed: %w", writeErr)
+			}
+			return output, err
+		}
+	}
+}
+
+type decisionKey struct{}
+
+type decisionLog struct {
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+func (d *decisionLog) add(decision Decision) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.decisions = append(d.decisions, decision)
+}
+
+func (d *decisionLog) list() []Decision {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Decision(nil), d.decisions...)
+}
+
+// RecordingApprover wraps an approver so its decisions are attached to the
+// audit record of the tool call that asked. File-change reviews pass through
+// to inner when it is a permission.Reviewer.
+func RecordingApprover(inner permission.Approver) permission.Approver {
+	if inner == nil {
+		inner = permission.DenyAll
+	}
+	return recordingApprover{inner: inner}
+}
+
+type recordingApprover struct {
+	inner permission.Approver
+}
+
+func (r recordingApprover) Approve(ctx context.Context, req permission.Request) (bool, error) {
+	approved, err := r.inner.Approve(ctx, req)
+	r.record(ctx, req, approved, err)
+	return approved, err
+}
+
+func (r recordingApprover) Review(ctx context.Context, req permission.Request) (permission.Verdict, error) {
+	verdict, err := permission.Review(ctx, r.inner, req)
+	r.record(ctx, req, verdict.Approved, err)
+	return verdict, err
+}
+
+func (r recordingApprover) record(ctx context.Context, req permission.Request, approved bool, err error) {
+	if log, ok := ctx.Value(decisionKey{}).(*decisionLog); ok {
+		decision := Decision{Summary: req.Summary, Detail: req.Detail, Approved: approved && err == nil, Bypassed: r.inner == permission.Bypass}
+		if err != nil {
+			decision.Error = err.Error()
+		}
+		log.add(decision)
+	}
+}
+
+func redactArgs(args map[string]any) map[string]any {
+	out := make(map[string]any, len(args))
+	for key, value := range args {
+		lower := strings.ToLower(key)
+		sensitive := false
+		for _, fragment := range sensitiveArgFragments {
+			if strings.Contains(lower, fragment) {
+				sensitive = true
+				break
+			}
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			out[key] = redactArgs(v)
+		default:
+			if sensitive {
+				out[key] = redactedValue
+			} else {
+				out[key] = value
+			}
+		}
+	}
+	return out
+}
+
+func truncate(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	return s[:limit], true
+}
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestMiddleware_RecordsEveryCall(t *testing.T) {
+	logger, err := Open(t.TempDir(), "session-1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer logger.Close()
+	logger.maxOutput = 5
+
+	approver := RecordingApprover(permission.AllowAll)
+	registry := tools.New()
+	registry.Register(toolDef("write"), func(ctx context.Context, _ map[string]any) (string, error) {
+		if _, err := approver.Approve(ctx, permission.Request{Tool: "write", Summary: "write file"}); err != nil {
+			return "", err
+		}
+		return "written successfully", nil
+	})
+	registry.Register(toolDef("fail"), func(context.Context, map[string]any) (string, error) {
+		return "", errors.New("boom")
+	})
+	registry.Register(tools.BashToolDef(), tools.NewBashHandler(sandbox.Local{}))
+	audited := registry.WithMiddleware(logger.Middleware())
+
+	ctx := trace.WithSpan(trace.WithRun(context.Background(), "run-1"))
+	_, _ = audited.Dispatch(ctx, "write", map[string]any{"path": "a.txt", "api_key": "sk-123"})
+	_, _ = audited.Dispatch(ctx, "fail", map[string]any{})
+	_, _ = audited.Dispatch(ctx, "bash", map[string]any{"command": "exit 3"})
+
+	records := readRecords(t, logger.Path())
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	write := records[0]
+	if write.Session != "session-1" || write.RunID != "run-1" || write.SpanID != trace.FromContext(ctx).SpanID || write.Output != "writt" || !write.Truncated || write.OutputSize != 20 {
+		t.Fatalf("unexpected write record: %+v", write)
+	}
+	if write.Args["api_key"] != redactedValue || write.Args["path"] != "a.txt" {
+		t.Fatalf("args not redacted: %+v", write.Args)
+	}
+	if len(write.Approvals) != 1 || !write.Approvals[0].Approved {
+		t.Fatalf("approval decision missing: %+v", write.Approvals)
+	}
+
+	if records[1].Status != "error" || records[1].Error != "boom" {
+		t.Fatalf("unexpected error record: %+v", records[1])
+	}
+	if records[2].ExitCode == nil || *records[2].ExitCode != 3 {
+		t.Fatalf("expected bash exit code 3, got %+v", records[2].ExitCode)
+	}
+}
+
+func TestRecordingApprover_MarksBypassedDecisions(t *testing.T) {
+	logger, err := Open(t.TempDir(), "session-1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer logger.Close()
+
+	approver := RecordingApprover(permission.Bypass)
+	registry := tools.New()
+	registry.Register(toolDef("write"), func(ctx context.Context, _ map[string]any) (string, error) {
+		verdict, err := permission.Review(ctx, approver, permission.Request{Tool: "write", Summary: "modify a.txt"})
+		if err != nil || !verdict.Approved {
+			return "", fmt.Errorf("not approved: %+v, %v", verdict, err)
+		}
+		return "ok", nil
+	})
+	if _, err := registry.WithMiddleware(logger.Middleware()).Dispatch(context.Background(), "write", map[string]any{}); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	records := readRecords(t, logger.Path())
+	if len(records) != 1 || len(records[0].Approvals) != 1 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if d := records[0].Approvals[0]; !d.Approved || !d.Bypassed {
+		t.Fatalf("decision not marked bypassed: %+v", d)
+	}
+}
+
+func TestOpen_AppendsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		logger, err := Open(dir, "s")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if err := logger.Write(Record{Tool: "t"}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		_ = logger.Close()
+	}
+
+	logger, _ := Open(dir, "s")
+	defer logger.Close()
+	if got := len(readRecords(t, logger.Path())); got != 2 {
+		t.Fatalf("expected 2 records after reopen, got %d", got)
+	}
+	if _, err := Open(dir, "../escape"); err == nil {
+		t.Fatal("expected invalid session id error")
+	}
+}
+
+func TestOpen_EncryptsLinesWithStorageKey(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := Open(dir, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = logger.Write(Record{Tool: "before"})
+	_ = logger.Close()
+
+	t.Setenv(atrest.KeyEnv, atrest.NewKey())
+	logger, err = Open(dir, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = logger.Write(Record{Tool: "bash", Args: map[string]any{"command": "cat secret.go"}})
+	_ = logger.Close()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil || bytes.Contains(data, []byte("secret.go")) {
+		t.Fatalf("audit log holds plaintext: %s, %v", data, err)
+	}
+	// A log continued after the key was set mixes both kinds of lines.
+	records, err := ReadRecords(bytes.NewReader(data))
+	if err != nil || len(records) != 2 || records[0].Tool != "before" || records[1].Args["command"] != "cat secret.go" {
+		t.Fatalf("ReadRecords = %+v, %v", records, err)
+	}
+
+	t.Setenv(atrest.KeyEnv, "")
+	if _, err := ReadRecords(bytes.NewReader(data)); !errors.Is(err, atrest.ErrNoKey) {
+		t.Fatalf("reading without the key: %v", err)
+	}
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit file: %v", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func toolDef(name string) openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type:     "function",
+		Function: shared.FunctionDefinitionParam{Name: name, Parameters: openai.FunctionParameters{"type": "object"}},
+	}
+}
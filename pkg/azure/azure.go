@@ -0,0 +1,162 @@
+// Package azure points the OpenAI client at an Azure OpenAI resource:
+// requests are routed to deployments, carry the api-version query parameter
+// and authenticate with an API key or a Microsoft Entra ID (AAD) token.
+//
+// openai-go ships an azure package, but it depends on the Azure SDK; this one
+// does the same routing with a request middleware and fetches AAD tokens
+// through a small TokenSource instead.
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// DefaultAPIVersion is the GA data-plane API version used when none is set.
+const DefaultAPIVersion = "2024-10-21"
+
+// deploymentRoutes carry a JSON body whose "model" names the deployment.
+var deploymentRoutes = map[string]bool{
+	"/openai/chat/completions": true,
+	"/openai/completions":      true,
+	"/openai/embeddings":       true,
+}
+
+// Config describes an Azure OpenAI resource.
+type Config struct {
+	// Endpoint is the resource URL, e.g. https://my-resource.openai.azure.com.
+	Endpoint   string
+	APIVersion string
+	// Deployment is the default deployment; Model returns it so requests
+	// that do not ask for anything else go there.
+	Deployment string
+	// Deployments maps model names to deployment names, for requests (e.g.
+	// embeddings) that name a model rather than a deployment. Models not in
+	// the map are used as deployment names unchanged.
+	Deployments map[string]string
+	// APIKey authenticates with the api-key header. When empty, Tokens must
+	// supply AAD bearer tokens.
+	APIKey string
+	Tokens TokenSource
+}
+
+// ConfigFromEnv reads AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_VERSION,
+// AZURE_OPENAI_DEPLOYMENT and the credentials: AZURE_OPENAI_API_KEY, or
+// AZURE_OPENAI_AD_TOKEN for a fixed AAD token, or AZURE_OPENAI_AUTH=aad to
+// fetch tokens with the Azure CLI.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Endpoint:   strings.TrimSpace(os.Getenv("AZURE_OPENAI_ENDPOINT")),
+		APIVersion: strings.TrimSpace(os.Getenv("AZURE_OPENAI_API_VERSION")),
+		Deployment: strings.TrimSpace(os.Getenv("AZURE_OPENAI_DEPLOYMENT")),
+		APIKey:     strings.TrimSpace(os.Getenv("AZURE_OPENAI_API_KEY")),
+	}
+	if token := strings.TrimSpace(os.Getenv("AZURE_OPENAI_AD_TOKEN")); token != "" {
+		cfg.Tokens = StaticToken(token)
+	} else if strings.EqualFold(strings.TrimSpace(os.Getenv("AZURE_OPENAI_AUTH")), "aad") {
+		cfg.Tokens = CLITokenSource()
+	}
+	return cfg
+}
+
+// Validate reports missing settings.
+func (c Config) Validate() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("azure endpoint is not set (AZURE_OPENAI_ENDPOINT)")
+	}
+	if _, err := url.ParseRequestURI(c.Endpoint); err != nil {
+		return fmt.Errorf("invalid azure endpoint %q: %w", c.Endpoint, err)
+	}
+	if c.Deployment == "" {
+		return fmt.Errorf("azure deployment is not set (AZURE_OPENAI_DEPLOYMENT)")
+	}
+	if c.APIKey == "" && c.Tokens == nil {
+		return fmt.Errorf("azure credentials are not set (AZURE_OPENAI_API_KEY, AZURE_OPENAI_AD_TOKEN or AZURE_OPENAI_AUTH=aad)")
+	}
+	return nil
+}
+
+// Model returns the name to send as the request model: the default deployment.
+func (c Config) Model() string {
+	return c.Deployment
+}
+
+// NewClient creates an OpenAI client for the Azure resource. Extra options,
+// e.g. option.WithMiddleware, are applied after the defaults.
+func NewClient(cfg Config, opts ...option.RequestOption) (*openai.Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	apiVersion := cfg.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAPIVersion
+	}
+
+	defaults := []option.RequestOption{
+		option.WithBaseURL(strings.TrimSuffix(cfg.Endpoint, "/") + "/openai/"),
+		option.WithQueryAdd("api-version", apiVersion),
+		// Never send an OPENAI_API_KEY picked up from the environment.
+		option.WithHeaderDel("authorization"),
+		option.WithMiddleware(routeDeployments(cfg.Deployments)),
+	}
+	if cfg.APIKey != "" {
+		defaults = append(defaults, option.WithHeader("api-key", cfg.APIKey))
+	} else {
+		defaults = append(defaults, option.WithMiddleware(bearer(newCachedTokens(cfg.Tokens))))
+	}
+	client := openai.NewClient(append(defaults, opts...)...)
+	return &client, nil
+}
+
+// routeDeployments rewrites /openai/<route> to
+// /openai/deployments/<deployment>/<route>, taking the deployment from the
+// request's model.
+func routeDeployments(deployments map[string]string) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !deploymentRoutes[req.URL.Path] || req.Body == nil {
+			return next(req)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("azure: read model from request: %w", err)
+		}
+		deployment := payload.Model
+		if mapped, ok := deployments[deployment]; ok {
+			deployment = mapped
+		}
+		if deployment == "" {
+			return nil, fmt.Errorf("azure: request has no model to route to a deployment")
+		}
+		req.URL.Path = strings.Replace(req.URL.Path, "/openai/", "/openai/deployments/"+url.PathEscape(deployment)+"/", 1)
+		req.URL.RawPath = ""
+		return next(req)
+	}
+}
+
+func bearer(tokens TokenSource) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		token, err := tokens.Token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("azure: get AAD token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Value)
+		return next(req)
+	}
+}
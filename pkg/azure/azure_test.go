@@ -0,0 +1,146 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+type capturedRequest struct {
+	path, apiVersion, apiKey, auth string
+}
+
+func fakeAzure(t *testing.T, got *[]capturedRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = append(*got, capturedRequest{
+			path:       r.URL.Path,
+			apiVersion: r.URL.Query().Get("api-version"),
+			apiKey:     r.Header.Get("api-key"),
+			auth:       r.Header.Get("Authorization"),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "m",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "hi"}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func chat(t *testing.T, client *openai.Client, model string) {
+	t.Helper()
+	_, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    model,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")},
+	})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+}
+
+func TestNewClient_RoutesToDeploymentsWithAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-should-not-leak")
+	var got []capturedRequest
+	server := fakeAzure(t, &got)
+
+	cfg := Config{
+		Endpoint:    server.URL,
+		Deployment:  "gpt4o-prod",
+		Deployments: map[string]string{"gpt-4o-mini": "mini-eastus"},
+		APIKey:      "azure-key",
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	chat(t, client, cfg.Model())
+	chat(t, client, "gpt-4o-mini")
+
+	want := []string{"/openai/deployments/gpt4o-prod/chat/completions", "/openai/deployments/mini-eastus/chat/completions"}
+	for i, req := range got {
+		if req.path != want[i] || req.apiVersion != DefaultAPIVersion || req.apiKey != "azure-key" || req.auth != "" {
+			t.Errorf("request %d = %+v, want path %s with api-key only", i, req, want[i])
+		}
+	}
+}
+
+func TestNewClient_AADTokensAreCached(t *testing.T) {
+	var got []capturedRequest
+	server := fakeAzure(t, &got)
+
+	calls := 0
+	client, err := NewClient(Config{
+		Endpoint:   server.URL + "/",
+		APIVersion: "2025-01-01-preview",
+		Deployment: "d",
+		Tokens: TokenSourceFunc(func(context.Context) (Token, error) {
+			calls++
+			return Token{Value: "aad-token", ExpiresAt: time.Now().Add(time.Hour)}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	chat(t, client, "d")
+	chat(t, client, "d")
+
+	if calls != 1 {
+		t.Fatalf("token fetched %d times, want 1", calls)
+	}
+	for _, req := range got {
+		if req.auth != "Bearer aad-token" || req.apiKey != "" || req.apiVersion != "2025-01-01-preview" || req.path != "/openai/deployments/d/chat/completions" {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+}
+
+func TestCachedTokens_RefreshesBeforeExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	calls := 0
+	cache := newCachedTokens(TokenSourceFunc(func(context.Context) (Token, error) {
+		calls++
+		return Token{Value: "t", ExpiresAt: now.Add(10 * time.Minute)}, nil
+	}))
+	cache.now = func() time.Time { return now }
+
+	_, _ = cache.Token(context.Background())
+	now = now.Add(4 * time.Minute)
+	_, _ = cache.Token(context.Background())
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1 while the token is fresh", calls)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = cache.Token(context.Background())
+	if calls != 2 {
+		t.Fatalf("calls = %d, want a refresh within %s of expiry", calls, refreshBefore)
+	}
+}
+
+func TestParseCLIToken(t *testing.T) {
+	token, err := parseCLIToken([]byte(`{"accessToken":"abc","expiresOn":"2024-01-01 10:00:00.000000","expires_on":1704103200}`))
+	if err != nil {
+		t.Fatalf("parseCLIToken: %v", err)
+	}
+	if token.Value != "abc" || token.ExpiresAt.Unix() != 1704103200 {
+		t.Fatalf("token = %+v", token)
+	}
+	if _, err := parseCLIToken([]byte(`{}`)); err == nil {
+		t.Fatal("expected an error for a missing access token")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (Config{Endpoint: "https://x.openai.azure.com", Deployment: "d"}).Validate(); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+	if err := (Config{Endpoint: "https://x.openai.azure.com", Deployment: "d", APIKey: "k"}).Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenResource is the AAD resource Azure OpenAI tokens are issued for.
+const tokenResource = "https://cognitiveservices.azure.com"
+
+// refreshBefore renews cached tokens this long before they expire.
+const refreshBefore = 5 * time.Minute
+
+// Token is an AAD access token. A zero ExpiresAt never expires.
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// TokenSource supplies AAD bearer tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenSourceFunc adapts a function to the TokenSource interface.
+type TokenSourceFunc func(ctx context.Context) (Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// StaticToken always returns token, e.g. one obtained by a CI pipeline.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (Token, error) {
+		return Token{Value: token}, nil
+	})
+}
+
+// CLITokenSource gets tokens from the Azure CLI (az account get-access-token),
+// using whatever identity `az login` established.
+func CLITokenSource() TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (Token, error) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "az", "account", "get-access-token",
+			"--resource", tokenResource, "--output", "json").Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return Token{}, fmt.Errorf("az account get-access-token: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return Token{}, fmt.Errorf("az account get-access-token: %w", err)
+		}
+		return parseCLIToken(out)
+	})
+}
+
+// parseCLIToken reads az's JSON: newer versions report expires_on as Unix
+// seconds, older ones only expiresOn in local time.
+func parseCLIToken(data []byte) (Token, error) {
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   string `json:"expiresOn"`
+		ExpiresOnTS any    `json:"expires_on"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return Token{}, fmt.Errorf("parse az token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return Token{}, fmt.Errorf("az returned no access token")
+	}
+	token := Token{Value: resp.AccessToken}
+	switch v := resp.ExpiresOnTS.(type) {
+	case float64:
+		token.ExpiresAt = time.Unix(int64(v), 0)
+	case string:
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			token.ExpiresAt = time.Unix(secs, 0)
+		}
+	}
+	if token.ExpiresAt.IsZero() && resp.ExpiresOn != "" {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", resp.ExpiresOn, time.Local); err == nil {
+			token.ExpiresAt = t
+		}
+	}
+	return token, nil
+}
+
+// cachedTokens reuses a token until shortly before it expires.
+type cachedTokens struct {
+	source TokenSource
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token Token
+}
+
+func newCachedTokens(source TokenSource) *cachedTokens {
+	return &cachedTokens{source: source, now: time.Now}
+}
+
+func (c *cachedTokens) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.Value != "" && (c.token.ExpiresAt.IsZero() || c.now().Add(refreshBefore).Before(c.token.ExpiresAt)) {
+		return c.token, nil
+	}
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	c.token = token
+	return token, nil
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
 )
 
 const (
@@ -156,8 +157,7 @@ func (m *Manager) execute(parentCtx context.Context, taskID string, command stri
 	}
 	defer cancel()
 
-	cmd := exec.CommandContext(runCtx, "bash", "-c", command)
-	cmd.Dir = m.workdir
+	cmd := sandbox.LocalFromEnv().Command(runCtx, command, m.workdir)
 	output, err := cmd.CombinedOutput()
 
 	status := StatusCompleted
@@ -0,0 +1,277 @@
+// Package batch runs a file of prompts, each as an independent agent session,
+// for evaluation sweeps and bulk changes. Every task gets its own result file
+// and the run ends with an aggregate report.
+//
+// Tasks are JSON lines:
+//
+//	{"id": "rename-config", "prompt": "Rename Config.Foo to Config.Bar"}
+//	{"prompt": "Add a test for ParseDuration", "max_turns": 10}
+//	{"prompt": "Is main.go covered by tests?", "output_schema": {"type": "object", "required": ["covered"], "properties": {"covered": {"type": "boolean"}}}}
+//
+// A task with an output schema replies with JSON matching it, which the
+// result also holds as output.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/jsonschema"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// DefaultDir holds one subdirectory per batch run.
+	DefaultDir = ".batch"
+	// ReportFile is the aggregate report written to the output directory.
+	ReportFile = "report.json"
+)
+
+// Task is one prompt to run.
+type Task struct {
+	ID     string `json:"id,omitempty"`
+	Prompt string `json:"prompt"`
+	// MaxTurns overrides Options.MaxTurns for this task.
+	MaxTurns int `json:"max_turns,omitempty"`
+	// OutputSchema overrides Options.OutputSchema for this task.
+	OutputSchema map[string]any `json:"output_schema,omitempty"`
+}
+
+// Result is what one task produced; it is written to <id>.json.
+type Result struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	// Status is "ok" or "error".
+	Status           string                                   `json:"status"`
+	Reply            string                                   `json:"reply,omitempty"`
+	Output           json.RawMessage                          `json:"output,omitempty"`
+	Error            string                                   `json:"error,omitempty"`
+	StartedAt        time.Time                                `json:"started_at"`
+	DurationMS       int64                                    `json:"duration_ms"`
+	PromptTokens     int64                                    `json:"prompt_tokens"`
+	CompletionTokens int64                                    `json:"completion_tokens"`
+	Cost             float64                                  `json:"cost,omitempty"`
+	Models           []budget.ModelUsage                      `json:"models,omitempty"`
+	Messages         []openai.ChatCompletionMessageParamUnion `json:"messages,omitempty"`
+}
+
+// Report aggregates a batch run.
+type Report struct {
+	StartedAt        time.Time           `json:"started_at"`
+	DurationMS       int64               `json:"duration_ms"`
+	Total            int                 `json:"total"`
+	Succeeded        int                 `json:"succeeded"`
+	Failed           int                 `json:"failed"`
+	PromptTokens     int64               `json:"prompt_tokens"`
+	CompletionTokens int64               `json:"completion_tokens"`
+	Cost             float64             `json:"cost,omitempty"`
+	Models           []budget.ModelUsage `json:"models,omitempty"`
+	Tasks            []TaskSummary       `json:"tasks"`
+}
+
+// TaskSummary is one line of the report; the full result is in File.
+type TaskSummary struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Tokens     int64  `json:"tokens"`
+	File       string `json:"file"`
+}
+
+// Options configure a batch run.
+type Options struct {
+	// OutDir receives <id>.json per task and ReportFile. Required.
+	OutDir string
+	// Concurrency is how many tasks run at once; <= 0 means 1.
+	Concurrency int
+	// MaxTurns limits each task; <= 0 means no limit.
+	MaxTurns int
+	// OutputSchema, when set, is the JSON schema every task's reply must
+	// match.
+	OutputSchema map[string]any
+	// Budget caps each task like the project budget caps an interactive task.
+	Budget config.Budget
+	// NewAgent creates a fresh agent for a task. The options already include
+	// WithMaxTurns and WithOutputSchema for the task.
+	NewAgent func(task Task, opts ...agent.Option) (*agent.Agent, error)
+	// OnDone, if set, is called after each task finishes.
+	OnDone func(Result)
+}
+
+// ReadTasks parses JSON lines; blank lines and lines starting with # are
+// skipped. Tasks without an id are named task-<line>; ids must be unique.
+func ReadTasks(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var task Task
+		if err := json.Unmarshal([]byte(text), &task); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(task.Prompt) == "" {
+			return nil, fmt.Errorf("line %d: prompt is required", line)
+		}
+		task.ID = strings.TrimSpace(task.ID)
+		if task.ID == "" {
+			task.ID = fmt.Sprintf("task-%d", line)
+		}
+		if strings.ContainsAny(task.ID, `/\`) || strings.HasPrefix(task.ID, ".") {
+			return nil, fmt.Errorf("line %d: invalid task id %q", line, task.ID)
+		}
+		if task.OutputSchema != nil {
+			if err := jsonschema.Check(task.OutputSchema); err != nil {
+				return nil, fmt.Errorf("line %d: output_schema: %w", line, err)
+			}
+		}
+		if seen[task.ID] {
+			return nil, fmt.Errorf("line %d: duplicate task id %q", line, task.ID)
+		}
+		seen[task.ID] = true
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Run executes tasks and writes their results and the report to opts.OutDir.
+// A failing task does not stop the others; the returned error is only for
+// problems writing the output or a canceled ctx.
+func Run(ctx context.Context, tasks []Task, opts Options) (Report, error) {
+	if opts.NewAgent == nil {
+		return Report{}, fmt.Errorf("batch: NewAgent is required")
+	}
+	if strings.TrimSpace(opts.OutDir) == "" {
+		return Report{}, fmt.Errorf("batch: output directory is required")
+	}
+	if err := os.MkdirAll(opts.OutDir, 0o755); err != nil {
+		return Report{}, fmt.Errorf("create output dir: %w", err)
+	}
+	if err := opts.Budget.Validate(); err != nil {
+		return Report{}, err
+	}
+	concurrency := max(opts.Concurrency, 1)
+
+	report := Report{StartedAt: time.Now().UTC(), Total: len(tasks)}
+	results := make([]Result, len(tasks))
+	writeErrs := make([]error, len(tasks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			results[i] = Result{ID: task.ID, Prompt: task.Prompt, Status: "error", Error: "not started: " + ctx.Err().Error(), StartedAt: time.Now().UTC()}
+			writeErrs[i] = writeJSON(filepath.Join(opts.OutDir, task.ID+".json"), results[i])
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runTask(ctx, task, opts)
+			writeErrs[i] = writeJSON(filepath.Join(opts.OutDir, task.ID+".json"), results[i])
+			if opts.OnDone != nil {
+				opts.OnDone(results[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		if res.Status == "ok" {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		report.PromptTokens += res.PromptTokens
+		report.CompletionTokens += res.CompletionTokens
+		report.Cost += res.Cost
+		report.Models = budget.MergeModels(report.Models, res.Models)
+		report.Tasks = append(report.Tasks, TaskSummary{
+			ID:         res.ID,
+			Status:     res.Status,
+			Error:      res.Error,
+			DurationMS: res.DurationMS,
+			Tokens:     res.PromptTokens + res.CompletionTokens,
+			File:       res.ID + ".json",
+		})
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	writeErrs = append(writeErrs, writeJSON(filepath.Join(opts.OutDir, ReportFile), report))
+	return report, errors.Join(append(writeErrs, ctx.Err())...)
+}
+
+// runTask runs one task in a fresh agent with its own token tracker.
+func runTask(ctx context.Context, task Task, opts Options) Result {
+	res := Result{ID: task.ID, Prompt: task.Prompt, StartedAt: time.Now().UTC()}
+	// The budget was validated by Run, so FromConfig cannot fail here.
+	tracker, ok, _ := budget.FromConfig(opts.Budget)
+	if !ok {
+		tracker = budget.New(budget.Limits{}, budget.PricingFromConfig(opts.Budget))
+	}
+
+	maxTurns := opts.MaxTurns
+	if task.MaxTurns > 0 {
+		maxTurns = task.MaxTurns
+	}
+	agentOpts := []agent.Option{agent.WithMaxTurns(maxTurns)}
+	schema := opts.OutputSchema
+	if task.OutputSchema != nil {
+		schema = task.OutputSchema
+	}
+	if schema != nil {
+		agentOpts = append(agentOpts, agent.WithOutputSchema(schema))
+	}
+	a, err := opts.NewAgent(task, agentOpts...)
+	if err == nil {
+		res.Reply, err = a.Run(budget.WithTracker(ctx, tracker), task.Prompt)
+		res.Messages = a.Messages()
+	}
+	if err == nil && schema != nil {
+		res.Output = json.RawMessage(res.Reply)
+	}
+
+	usage := tracker.Usage()
+	res.PromptTokens, res.CompletionTokens, res.Cost = usage.PromptTokens, usage.CompletionTokens, usage.Cost
+	res.Models = usage.Models
+	res.DurationMS = time.Since(res.StartedAt).Milliseconds()
+	res.Status = "ok"
+	if err != nil {
+		res.Status, res.Error = "error", err.Error()
+	}
+	return res
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
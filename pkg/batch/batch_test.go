@@ -0,0 +1,156 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestReadTasks(t *testing.T) {
+	input := `# comment
+{"id": "a", "prompt": "first"}
+
+{"prompt": "second", "max_turns": 3}
+`
+	tasks, err := ReadTasks(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadTasks: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "a" || tasks[1].ID != "task-4" || tasks[1].MaxTurns != 3 {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+
+	for _, bad := range []string{
+		`{"id": "a"}`,
+		`{"id": "a", "prompt": "x"}` + "\n" + `{"id": "a", "prompt": "y"}`,
+		`{"id": "../escape", "prompt": "x"}`,
+		`not json`,
+		`{"prompt": "x", "output_schema": {"type": "text"}}`,
+	} {
+		if _, err := ReadTasks(strings.NewReader(bad)); err == nil {
+			t.Fatalf("ReadTasks(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestRun_WritesResultsAndReport(t *testing.T) {
+	dir := t.TempDir()
+	tasks := []Task{{ID: "ok", Prompt: "say hi"}, {ID: "bad", Prompt: "fail"}}
+
+	report, err := Run(context.Background(), tasks, Options{
+		OutDir:      dir,
+		Concurrency: 2,
+		NewAgent:    stubAgent(nil),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Total != 2 || report.Succeeded != 1 || report.Failed != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.PromptTokens != 20 || report.CompletionTokens != 10 {
+		t.Fatalf("report tokens = %d/%d, want 20/10", report.PromptTokens, report.CompletionTokens)
+	}
+
+	var ok Result
+	readJSON(t, filepath.Join(dir, "ok.json"), &ok)
+	if ok.Status != "ok" || ok.Reply != "hi from say hi" || len(ok.Messages) != 2 {
+		t.Fatalf("ok result = %+v", ok)
+	}
+	var bad Result
+	readJSON(t, filepath.Join(dir, "bad.json"), &bad)
+	if bad.Status != "error" || !strings.Contains(bad.Error, "boom") {
+		t.Fatalf("bad result = %+v", bad)
+	}
+	var onDisk Report
+	readJSON(t, filepath.Join(dir, ReportFile), &onDisk)
+	if len(onDisk.Tasks) != 2 || onDisk.Tasks[0].ID != "ok" || onDisk.Tasks[0].File != "ok.json" {
+		t.Fatalf("report on disk = %+v", onDisk)
+	}
+}
+
+func TestRun_LimitsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	gate := func() {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+	}
+	tasks := []Task{{ID: "1", Prompt: "a"}, {ID: "2", Prompt: "b"}, {ID: "3", Prompt: "c"}, {ID: "4", Prompt: "d"}}
+
+	report, err := Run(context.Background(), tasks, Options{OutDir: t.TempDir(), Concurrency: 2, NewAgent: stubAgent(gate)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Succeeded != 4 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", got)
+	}
+}
+
+func TestRun_CanceledContextSkipsRemainingTasks(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := Run(ctx, []Task{{ID: "a", Prompt: "x"}}, Options{OutDir: dir, NewAgent: stubAgent(nil)})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if report.Failed != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ReportFile)); err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+}
+
+// stubAgent builds agents whose loop replies "hi from <prompt>", records
+// 10+5 tokens, and fails prompts containing "fail".
+func stubAgent(during func()) func(Task, ...agent.Option) (*agent.Agent, error) {
+	return func(task Task, opts ...agent.Option) (*agent.Agent, error) {
+		client := openai.NewClient(option.WithAPIKey("test"))
+		runner := func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			if during != nil {
+				during()
+			}
+			budget.TrackerFrom(ctx).Record(10, 5)
+			if strings.Contains(task.Prompt, "fail") {
+				return messages, errors.New("boom")
+			}
+			return append(messages, openai.AssistantMessage("hi from "+task.Prompt)), nil
+		}
+		return agent.New(append(opts, agent.WithClient(&client), agent.WithRunner(runner))...)
+	}
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+}
@@ -0,0 +1,386 @@
+// Package budget tracks per-task resource usage (tokens, estimated cost and
+// wall-clock time) against configured limits. A Tracker travels in the
+// context so loop.Run can enforce it without changing its signature.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+// ErrExceeded is matched by every *ExceededError.
+var ErrExceeded = errors.New("budget exceeded")
+
+// Limits are the per-task thresholds; zero disables a limit.
+type Limits struct {
+	MaxTokens   int64
+	MaxCost     float64
+	MaxDuration time.Duration
+}
+
+// Pricing converts token counts into an estimated cost. Models holds the
+// prices of individual models (see config.Budget.Prices); other models cost
+// InputPer1K and OutputPer1K.
+type Pricing struct {
+	InputPer1K  float64
+	OutputPer1K float64
+	Models      map[string]config.Price
+}
+
+// PricingFromConfig returns the prices configured in cfg.
+func PricingFromConfig(cfg config.Budget) Pricing {
+	return Pricing{InputPer1K: cfg.InputCostPer1K, OutputPer1K: cfg.OutputCostPer1K, Models: cfg.Prices}
+}
+
+// For returns the price of model: an exact entry, else the longest entry
+// that is a prefix of model, else the default price. ok is false when no
+// price is known at all.
+func (p Pricing) For(model string) (price config.Price, ok bool) {
+	if price, ok := p.Models[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range p.Models {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best != "" {
+		return p.Models[best], true
+	}
+	price = config.Price{InputPer1K: p.InputPer1K, OutputPer1K: p.OutputPer1K}
+	return price, price != (config.Price{})
+}
+
+// Usage is a snapshot of what a task has consumed so far.
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	// CachedTokens is the part of PromptTokens read from the provider's
+	// prompt cache, and Saved what that saved over the full input price.
+	CachedTokens int64
+	Cost         float64
+	Saved        float64
+	Elapsed      time.Duration
+	// Models breaks the tokens and cost down by model, sorted by name.
+	Models []ModelUsage
+}
+
+// ModelUsage is the usage of one model. Priced is false when no price is
+// known for it, so its tokens add nothing to the cost.
+type ModelUsage struct {
+	Model            string  `json:"model"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CachedTokens     int64   `json:"cached_tokens,omitempty"`
+	Cost             float64 `json:"cost"`
+	Saved            float64 `json:"saved,omitempty"`
+	Priced           bool    `json:"priced"`
+}
+
+func (u Usage) TotalTokens() int64 { return u.PromptTokens + u.CompletionTokens }
+
+// Unpriced returns the models used without a known price.
+func (u Usage) Unpriced() []string {
+	var models []string
+	for _, m := range u.Models {
+		if !m.Priced {
+			models = append(models, m.Model)
+		}
+	}
+	return models
+}
+
+// CostString renders the estimated cost, noting models without a price.
+func (u Usage) CostString() string {
+	unpriced := u.Unpriced()
+	if len(unpriced) == 0 {
+		return fmt.Sprintf("%.4f", u.Cost)
+	}
+	cost := fmt.Sprintf("%.4f", u.Cost)
+	if len(unpriced) == len(u.Models) {
+		cost = "n/a"
+	}
+	for i, model := range unpriced {
+		if model == "" {
+			unpriced[i] = "unnamed model"
+		}
+	}
+	return cost + " (no price for " + strings.Join(unpriced, ", ") + ")"
+}
+
+// CacheString renders the prompt cache hits and their savings, or "" when
+// nothing was read from the cache.
+func (u Usage) CacheString() string {
+	if u.CachedTokens == 0 {
+		return ""
+	}
+	if u.Saved > 0 {
+		return fmt.Sprintf("cached %d prompt tokens, saved≈%.4f", u.CachedTokens, u.Saved)
+	}
+	return fmt.Sprintf("cached %d prompt tokens", u.CachedTokens)
+}
+
+// Summary renders the usage for display to the user.
+func (u Usage) Summary() string {
+	summary := fmt.Sprintf("tokens=%d (prompt %d, completion %d) cost≈%s elapsed=%s",
+		u.TotalTokens(), u.PromptTokens, u.CompletionTokens, u.CostString(), u.Elapsed.Round(time.Second))
+	if cache := u.CacheString(); cache != "" {
+		summary += " (" + cache + ")"
+	}
+	return summary
+}
+
+// Sub returns the usage accumulated since earlier, a snapshot of the same
+// tracker.
+func (u Usage) Sub(earlier Usage) Usage {
+	before := make(map[string]ModelUsage, len(earlier.Models))
+	for _, m := range earlier.Models {
+		before[m.Model] = m
+	}
+	diff := Usage{
+		PromptTokens:     u.PromptTokens - earlier.PromptTokens,
+		CompletionTokens: u.CompletionTokens - earlier.CompletionTokens,
+		CachedTokens:     u.CachedTokens - earlier.CachedTokens,
+		Cost:             u.Cost - earlier.Cost,
+		Saved:            u.Saved - earlier.Saved,
+		Elapsed:          u.Elapsed - earlier.Elapsed,
+	}
+	for _, m := range u.Models {
+		b := before[m.Model]
+		m.PromptTokens -= b.PromptTokens
+		m.CompletionTokens -= b.CompletionTokens
+		m.CachedTokens -= b.CachedTokens
+		m.Cost -= b.Cost
+		m.Saved -= b.Saved
+		if m.PromptTokens != 0 || m.CompletionTokens != 0 {
+			diff.Models = append(diff.Models, m)
+		}
+	}
+	return diff
+}
+
+// ExceededError reports which limit was crossed and by how much.
+type ExceededError struct {
+	Limit string
+	Max   string
+	Used  string
+	Usage Usage
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("budget exceeded: %s %s > %s", e.Limit, e.Used, e.Max)
+}
+
+func (e *ExceededError) Is(target error) bool { return target == ErrExceeded }
+
+// Tracker accumulates usage for one task. A nil *Tracker records nothing and
+// never reports an overage.
+type Tracker struct {
+	limits  Limits
+	pricing Pricing
+	start   time.Time
+	now     func() time.Time
+
+	mu     sync.Mutex
+	models map[string]*ModelUsage
+}
+
+// New starts a tracker; the wall-clock budget starts now.
+func New(limits Limits, pricing Pricing) *Tracker {
+	return &Tracker{limits: limits, pricing: pricing, start: time.Now(), now: time.Now}
+}
+
+// FromConfig builds a tracker from the project config. ok is false when the
+// config sets no limit at all.
+func FromConfig(cfg config.Budget) (tracker *Tracker, ok bool, err error) {
+	duration, err := cfg.Duration()
+	if err != nil {
+		return nil, false, err
+	}
+	limits := Limits{MaxTokens: cfg.MaxTokens, MaxCost: cfg.MaxCost, MaxDuration: duration}
+	if limits == (Limits{}) {
+		return nil, false, nil
+	}
+	return New(limits, PricingFromConfig(cfg)), true, nil
+}
+
+// Record adds the token usage of one call to an unnamed model, which costs
+// the default price.
+func (t *Tracker) Record(promptTokens, completionTokens int64) {
+	t.RecordModel("", promptTokens, completionTokens)
+}
+
+// RecordModel adds the token usage of one call to model.
+func (t *Tracker) RecordModel(model string, promptTokens, completionTokens int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.models == nil {
+		t.models = make(map[string]*ModelUsage)
+	}
+	m, ok := t.models[model]
+	if !ok {
+		m = &ModelUsage{Model: model}
+		t.models[model] = m
+	}
+	m.PromptTokens += promptTokens
+	m.CompletionTokens += completionTokens
+}
+
+// RecordCached notes that cachedTokens of the prompt tokens recorded for
+// model were read from the provider's prompt cache, which prices them at
+// config.Price.CachedInputPer1K.
+func (t *Tracker) RecordCached(model string, cachedTokens int64) {
+	if t == nil || cachedTokens <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m, ok := t.models[model]; ok {
+		m.CachedTokens += cachedTokens
+	}
+}
+
+// Usage returns the current totals.
+func (t *Tracker) Usage() Usage {
+	if t == nil {
+		return Usage{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := Usage{Elapsed: t.now().Sub(t.start)}
+	for _, m := range t.models {
+		price, priced := t.pricing.For(m.Model)
+		model := *m
+		model.Priced = priced
+		cachedPrice := price.InputPer1K
+		if price.CachedInputPer1K > 0 {
+			cachedPrice = price.CachedInputPer1K
+		}
+		model.Cost = float64(m.PromptTokens-m.CachedTokens)/1000*price.InputPer1K +
+			float64(m.CachedTokens)/1000*cachedPrice +
+			float64(m.CompletionTokens)/1000*price.OutputPer1K
+		model.Saved = float64(m.CachedTokens) / 1000 * (price.InputPer1K - cachedPrice)
+		usage.PromptTokens += model.PromptTokens
+		usage.CompletionTokens += model.CompletionTokens
+		usage.CachedTokens += model.CachedTokens
+		usage.Cost += model.Cost
+		usage.Saved += model.Saved
+		usage.Models = append(usage.Models, model)
+	}
+	slices.SortFunc(usage.Models, func(a, b ModelUsage) int { return strings.Compare(a.Model, b.Model) })
+	return usage
+}
+
+// Check returns an *ExceededError for the first limit that has been crossed.
+func (t *Tracker) Check() error {
+	if t == nil {
+		return nil
+	}
+	usage := t.Usage()
+	switch {
+	case t.limits.MaxTokens > 0 && usage.TotalTokens() > t.limits.MaxTokens:
+		return &ExceededError{
+			Limit: "tokens",
+			Max:   fmt.Sprintf("%d", t.limits.MaxTokens),
+			Used:  fmt.Sprintf("%d", usage.TotalTokens()),
+			Usage: usage,
+		}
+	case t.limits.MaxCost > 0 && usage.Cost > t.limits.MaxCost:
+		return &ExceededError{
+			Limit: "cost",
+			Max:   fmt.Sprintf("%.4f", t.limits.MaxCost),
+			Used:  fmt.Sprintf("%.4f", usage.Cost),
+			Usage: usage,
+		}
+	case t.limits.MaxDuration > 0 && usage.Elapsed > t.limits.MaxDuration:
+		return &ExceededError{
+			Limit: "time",
+			Max:   t.limits.MaxDuration.String(),
+			Used:  usage.Elapsed.Round(time.Second).String(),
+			Usage: usage,
+		}
+	}
+	return nil
+}
+
+type trackerKey struct{}
+
+// WithTracker attaches t to ctx.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// TrackerFrom returns the tracker attached to ctx, or nil.
+func TrackerFrom(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// CostCommand returns the /cost command, which shows what t has recorded so
+// far by model.
+func (t *Tracker) CostCommand() command.Command {
+	return command.Command{
+		Name:        "cost",
+		Description: "show the tokens and estimated cost of this session by model",
+		Run: func(context.Context, []string) (string, error) {
+			usage := t.Usage()
+			if len(usage.Models) == 0 {
+				return "No model calls yet.", nil
+			}
+			var b strings.Builder
+			for _, m := range usage.Models {
+				cost := "no price"
+				if m.Priced {
+					cost = fmt.Sprintf("%.4f", m.Cost)
+				}
+				name := m.Model
+				if name == "" {
+					name = "unnamed model"
+				}
+				cache := ""
+				if m.CachedTokens > 0 {
+					cache = fmt.Sprintf(", %d cached", m.CachedTokens)
+				}
+				fmt.Fprintf(&b, "%s: %d tokens (prompt %d%s, completion %d), cost≈%s\n",
+					name, m.PromptTokens+m.CompletionTokens, m.PromptTokens, cache, m.CompletionTokens, cost)
+			}
+			fmt.Fprintf(&b, "total: %d tokens, cost≈%s", usage.TotalTokens(), usage.CostString())
+			if cache := usage.CacheString(); cache != "" {
+				fmt.Fprintf(&b, "\nprompt cache: %s", cache)
+			}
+			return b.String(), nil
+		},
+	}
+}
+
+// MergeModels adds the per-model usage of b to a, e.g. to total several
+// tasks.
+func MergeModels(a, b []ModelUsage) []ModelUsage {
+	merged := slices.Clone(a)
+	for _, m := range b {
+		i := slices.IndexFunc(merged, func(x ModelUsage) bool { return x.Model == m.Model })
+		if i < 0 {
+			merged = append(merged, m)
+			continue
+		}
+		merged[i].PromptTokens += m.PromptTokens
+		merged[i].CompletionTokens += m.CompletionTokens
+		merged[i].CachedTokens += m.CachedTokens
+		merged[i].Cost += m.Cost
+		merged[i].Saved += m.Saved
+	}
+	slices.SortFunc(merged, func(a, b ModelUsage) int { return strings.Compare(a.Model, b.Model) })
+	return merged
+}
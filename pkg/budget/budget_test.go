@@ -0,0 +1,121 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+func TestTracker_CheckReportsFirstCrossedLimit(t *testing.T) {
+	tracker := New(Limits{MaxTokens: 1000, MaxCost: 0.01}, Pricing{InputPer1K: 0.002, OutputPer1K: 0.006})
+
+	tracker.Record(400, 100)
+	if err := tracker.Check(); err != nil {
+		t.Fatalf("unexpected overage: %v", err)
+	}
+
+	tracker.Record(600, 100)
+	err := tracker.Check()
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrExceeded) {
+		t.Fatalf("expected ExceededError, got %v", err)
+	}
+	if exceeded.Limit != "tokens" || exceeded.Usage.TotalTokens() != 1200 {
+		t.Fatalf("unexpected overage: %+v", exceeded)
+	}
+}
+
+func TestTracker_CostAndTimeLimits(t *testing.T) {
+	tracker := New(Limits{MaxCost: 0.01}, Pricing{OutputPer1K: 0.02})
+	tracker.Record(0, 600)
+	if err := tracker.Check(); err == nil || err.(*ExceededError).Limit != "cost" {
+		t.Fatalf("expected cost overage, got %v", err)
+	}
+
+	now := time.Now()
+	timed := New(Limits{MaxDuration: time.Minute}, Pricing{})
+	timed.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if err := timed.Check(); err == nil || err.(*ExceededError).Limit != "time" {
+		t.Fatalf("expected time overage, got %v", err)
+	}
+}
+
+func TestTracker_NilIsNoop(t *testing.T) {
+	var tracker *Tracker
+	tracker.Record(1, 1)
+	if err := tracker.Check(); err != nil {
+		t.Fatalf("nil tracker should never exceed, got %v", err)
+	}
+	if TrackerFrom(context.Background()) != nil {
+		t.Fatal("expected no tracker in empty context")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, ok, err := FromConfig(config.Budget{}); ok || err != nil {
+		t.Fatalf("empty config should disable budget, ok=%v err=%v", ok, err)
+	}
+	tracker, ok, err := FromConfig(config.Budget{MaxDuration: "5m"})
+	if err != nil || !ok || tracker.limits.MaxDuration != 5*time.Minute {
+		t.Fatalf("unexpected tracker: %+v ok=%v err=%v", tracker, ok, err)
+	}
+}
+
+func TestTracker_PricesEachModel(t *testing.T) {
+	tracker := New(Limits{}, PricingFromConfig(config.Budget{
+		InputCostPer1K: 1,
+		Prices: map[string]config.Price{
+			"gpt-4o":      {InputPer1K: 0.0025, OutputPer1K: 0.01},
+			"gpt-4o-mini": {InputPer1K: 0.00015, OutputPer1K: 0.0006},
+		},
+	}))
+	tracker.RecordModel("gpt-4o-2024-08-06", 2000, 1000)
+	tracker.RecordModel("gpt-4o-mini", 1000, 1000)
+	tracker.RecordModel("qwen-plus", 1000, 0)
+	before := tracker.Usage()
+	tracker.RecordModel("gpt-4o-mini", 1000, 0)
+
+	usage := tracker.Usage()
+	if len(usage.Models) != 3 || usage.Models[0].Model != "gpt-4o-2024-08-06" || usage.Models[0].Cost != 0.015 {
+		t.Fatalf("models = %+v", usage.Models)
+	}
+	if got := usage.Cost; got < 1.01590-1e-9 || got > 1.01590+1e-9 {
+		t.Fatalf("cost = %v", got)
+	}
+	if delta := usage.Sub(before); len(delta.Models) != 1 || delta.PromptTokens != 1000 || delta.Models[0].Model != "gpt-4o-mini" {
+		t.Fatalf("delta = %+v", delta)
+	}
+
+	unpriced := New(Limits{}, Pricing{Models: map[string]config.Price{"gpt-4o": {InputPer1K: 1}}})
+	unpriced.RecordModel("qwen-plus", 10, 10)
+	if got := unpriced.Usage().CostString(); got != "n/a (no price for qwen-plus)" {
+		t.Fatalf("CostString = %q", got)
+	}
+}
+
+func TestTracker_PricesCachedTokens(t *testing.T) {
+	tracker := New(Limits{}, PricingFromConfig(config.Budget{
+		Prices: map[string]config.Price{
+			"qwen-plus": {InputPer1K: 1, OutputPer1K: 2, CachedInputPer1K: 0.1},
+		},
+	}))
+	tracker.RecordModel("qwen-plus", 3000, 1000)
+	tracker.RecordCached("qwen-plus", 2000)
+
+	usage := tracker.Usage()
+	if usage.CachedTokens != 2000 || usage.Models[0].CachedTokens != 2000 {
+		t.Fatalf("cached tokens = %d, %+v", usage.CachedTokens, usage.Models)
+	}
+	if got := usage.Cost; got < 3.2-1e-9 || got > 3.2+1e-9 {
+		t.Fatalf("cost = %v, want 3.2", got)
+	}
+	if got := usage.Saved; got < 1.8-1e-9 || got > 1.8+1e-9 {
+		t.Fatalf("saved = %v, want 1.8", got)
+	}
+	if got := usage.CacheString(); got != "cached 2000 prompt tokens, saved≈1.8000" {
+		t.Fatalf("CacheString = %q", got)
+	}
+}
@@ -0,0 +1,279 @@
+// Package bundle packs a stored session into one portable archive, to move
+// it to another machine or attach it to a bug report, and unpacks it again.
+//
+// A bundle is a gzip-compressed tar file:
+//
+//	manifest.json        Manifest
+//	session.json         the session with its transcript
+//	config.json          the project configuration at export time
+//	audit.jsonl          the audit log named after the session, if any
+//	checkpoints/...      the file checkpoints of the session, if any
+//
+// Everything in a bundle is plaintext, even when the stores are encrypted
+// (see package atrest); import encrypts again with the local key.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/checkpoint"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+)
+
+// Format identifies the bundle layout in the manifest.
+const Format = "agent-session-bundle/v1"
+
+// ImportedConfigDir keeps the config snapshots of imported bundles,
+// relative to the project root. They are for reference and never loaded.
+const ImportedConfigDir = ".agent/imported"
+
+const (
+	manifestName   = "manifest.json"
+	sessionName    = "session.json"
+	configName     = "config.json"
+	auditName      = "audit.jsonl"
+	checkpointsDir = "checkpoints/"
+	// maxEntrySize bounds each decompressed entry on import.
+	maxEntrySize = 256 << 20
+)
+
+// Manifest describes a bundle.
+type Manifest struct {
+	Format     string    `json:"format"`
+	Session    string    `json:"session"`
+	Title      string    `json:"title,omitempty"`
+	Messages   int       `json:"messages"`
+	ExportedAt time.Time `json:"exported_at"`
+	// AuditRecords and Checkpoints count what the bundle carries besides the
+	// transcript.
+	AuditRecords int `json:"audit_records"`
+	Checkpoints  int `json:"checkpoints"`
+}
+
+// Export writes session id of the project at root, with cfg as the config
+// snapshot, as a bundle to w.
+func Export(w io.Writer, root, id string, cfg config.Config) (Manifest, error) {
+	repo, err := session.NewFileRepository(filepath.Join(root, session.DefaultDir))
+	if err != nil {
+		return Manifest{}, err
+	}
+	sess, err := repo.Get(id)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m := Manifest{Format: Format, Session: sess.ID, Title: sess.Title, Messages: len(sess.Messages), ExportedAt: time.Now().UTC()}
+
+	files := map[string][]byte{}
+	if files[sessionName], err = json.MarshalIndent(sess, "", "  "); err != nil {
+		return Manifest{}, err
+	}
+	if files[configName], err = json.MarshalIndent(cfg, "", "  "); err != nil {
+		return Manifest{}, err
+	}
+
+	if f, err := os.Open(filepath.Join(root, audit.DefaultDir, id+".jsonl")); err == nil {
+		records, err := audit.ReadRecords(f)
+		f.Close()
+		if err != nil {
+			return Manifest{}, fmt.Errorf("audit log: %w", err)
+		}
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return Manifest{}, err
+			}
+		}
+		files[auditName], m.AuditRecords = b.Bytes(), len(records)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, err
+	}
+
+	dir := filepath.Join(root, checkpoint.DefaultDir, id)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, err
+	}
+	var checkpointNames []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return Manifest{}, err
+		}
+		files[checkpointsDir+entry.Name()] = data
+		checkpointNames = append(checkpointNames, checkpointsDir+entry.Name())
+	}
+	if len(entries) > 0 {
+		store, err := checkpoint.Open(dir)
+		if err != nil {
+			return Manifest{}, err
+		}
+		m.Checkpoints = len(store.Entries())
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	order := append([]string{sessionName, configName, auditName}, checkpointNames...)
+	if err := writeEntry(tw, manifestName, manifest, m.ExportedAt); err != nil {
+		return Manifest{}, err
+	}
+	for _, name := range order {
+		if data, ok := files[name]; ok {
+			if err := writeEntry(tw, name, data, m.ExportedAt); err != nil {
+				return Manifest{}, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return m, gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import restores the bundle in r into the project at root: the session,
+// its audit log and checkpoints, and the config snapshot under
+// ImportedConfigDir. It refuses to replace an existing session.
+func Import(r io.Reader, root string) (Manifest, error) {
+	files, err := readEntries(r)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(files[manifestName], &m); err != nil || m.Format != Format {
+		return Manifest{}, fmt.Errorf("not a session bundle (want format %s)", Format)
+	}
+	var sess session.Session
+	if err := json.Unmarshal(files[sessionName], &sess); err != nil {
+		return Manifest{}, fmt.Errorf("bundle session: %w", err)
+	}
+	if sess.ID != m.Session {
+		return Manifest{}, fmt.Errorf("bundle session %q does not match its manifest (%q)", sess.ID, m.Session)
+	}
+
+	repo, err := session.NewFileRepository(filepath.Join(root, session.DefaultDir))
+	if err != nil {
+		return Manifest{}, err
+	}
+	if _, err := repo.Get(sess.ID); err == nil {
+		return Manifest{}, fmt.Errorf("session %s already exists", sess.ID)
+	} else if !errors.Is(err, session.ErrNotFound) {
+		return Manifest{}, err
+	}
+
+	if data, ok := files[auditName]; ok {
+		records, err := audit.ReadRecords(bytes.NewReader(data))
+		if err != nil {
+			return Manifest{}, err
+		}
+		logger, err := audit.Open(filepath.Join(root, audit.DefaultDir), sess.ID)
+		if err != nil {
+			return Manifest{}, err
+		}
+		for _, rec := range records {
+			if err := logger.Write(rec); err != nil {
+				logger.Close()
+				return Manifest{}, err
+			}
+		}
+		if err := logger.Close(); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	dir := filepath.Join(root, checkpoint.DefaultDir, sess.ID)
+	for name, data := range files {
+		base, ok := strings.CutPrefix(name, checkpointsDir)
+		if !ok {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return Manifest{}, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, base), data, 0o600); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	if data, ok := files[configName]; ok {
+		configDir := filepath.Join(root, ImportedConfigDir)
+		if err := os.MkdirAll(configDir, 0o700); err != nil {
+			return Manifest{}, err
+		}
+		if err := os.WriteFile(filepath.Join(configDir, sess.ID+".config.json"), data, 0o600); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	// The session goes last so a failed import leaves no session behind.
+	return m, repo.Save(sess)
+}
+
+// readEntries reads the known entries of a bundle into memory.
+func readEntries(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a session bundle: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !knownEntry(hdr.Name) {
+			return nil, fmt.Errorf("bundle: unexpected entry %q", hdr.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if len(data) > maxEntrySize {
+			return nil, fmt.Errorf("bundle: %s is larger than %d MiB", hdr.Name, maxEntrySize>>20)
+		}
+		files[hdr.Name] = data
+	}
+}
+
+// knownEntry reports whether name belongs in a bundle. Checkpoint files
+// must sit directly in checkpoints/ so import cannot write elsewhere.
+func knownEntry(name string) bool {
+	switch name {
+	case manifestName, sessionName, configName, auditName:
+		return true
+	}
+	base, ok := strings.CutPrefix(name, checkpointsDir)
+	return ok && base != "" && path.Base(base) == base && base != ".." && !strings.Contains(base, `\`)
+}
@@ -0,0 +1,98 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/checkpoint"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/openai/openai-go"
+)
+
+func TestExportImport_MovesSessionAuditAndCheckpoints(t *testing.T) {
+	src := t.TempDir()
+	repo, err := session.NewFileRepository(filepath.Join(src, session.DefaultDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	messages := []openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix it"), openai.AssistantMessage("done")}
+	if err := repo.Save(session.Session{ID: "s1", Title: "fix", CreatedAt: now, UpdatedAt: now, Messages: messages}); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := audit.Open(filepath.Join(src, audit.DefaultDir), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = logger.Write(audit.Record{Tool: "bash", Args: map[string]any{"command": "go test"}})
+	_ = logger.Close()
+	store, err := checkpoint.Open(filepath.Join(src, checkpoint.DefaultDir, "s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := filepath.Join(src, "main.go")
+	_ = os.WriteFile(edited, []byte("package main"), 0o644)
+	if _, err := store.Save(edited, "write_file"); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	m, err := Export(&archive, src, "s1", config.Config{Language: "zh"})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if m.Messages != 2 || m.AuditRecords != 1 || m.Checkpoints != 1 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	dst := t.TempDir()
+	data := archive.Bytes()
+	if _, err := Import(bytes.NewReader(data), dst); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	imported, err := session.NewFileRepository(filepath.Join(dst, session.DefaultDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := imported.Get("s1"); err != nil || got.Title != "fix" || len(got.Messages) != 2 {
+		t.Fatalf("imported session = %+v, %v", got, err)
+	}
+	f, err := os.Open(filepath.Join(dst, audit.DefaultDir, "s1.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if records, err := audit.ReadRecords(f); err != nil || len(records) != 1 || records[0].Args["command"] != "go test" {
+		t.Fatalf("imported audit = %+v, %v", records, err)
+	}
+	if store, err := checkpoint.Open(filepath.Join(dst, checkpoint.DefaultDir, "s1")); err != nil || len(store.Entries()) != 1 {
+		t.Fatalf("imported checkpoints: %v", err)
+	}
+	if cfg, err := os.ReadFile(filepath.Join(dst, ImportedConfigDir, "s1.config.json")); err != nil || !strings.Contains(string(cfg), `"language": "zh"`) {
+		t.Fatalf("config snapshot = %s, %v", cfg, err)
+	}
+
+	if _, err := Import(bytes.NewReader(data), dst); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("second import: %v", err)
+	}
+}
+
+func TestImport_RejectsEntriesOutsideTheBundleLayout(t *testing.T) {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	_ = writeEntry(tw, "checkpoints/../../evil", []byte("x"), time.Now())
+	_ = tw.Close()
+	_ = gz.Close()
+	if _, err := Import(&b, t.TempDir()); err == nil || !strings.Contains(err.Error(), "unexpected entry") {
+		t.Fatalf("Import: %v", err)
+	}
+}
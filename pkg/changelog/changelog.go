@@ -0,0 +1,458 @@
+// Package changelog writes a CHANGELOG section from the git history. The
+// model gets every commit since a tag with its changed files, a tool to look
+// at a commit's diff and an add_entry tool; the entries it adds, grouped by
+// category, are the result. A verification pass then checks that every
+// commit and pull request an entry refers to exists, and drops the ones that
+// do not.
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	// maxPromptLength keeps the commit list within the model's context.
+	maxPromptLength = 60000
+	// maxShowLength bounds the output of show_commit.
+	maxShowLength   = 20000
+	maxBodyLength   = 500
+	defaultMaxTurns = 40
+)
+
+// Categories of Keep a Changelog, in the order they are rendered.
+const (
+	CategoryAdded      = "Added"
+	CategoryChanged    = "Changed"
+	CategoryDeprecated = "Deprecated"
+	CategoryRemoved    = "Removed"
+	CategoryFixed      = "Fixed"
+	CategorySecurity   = "Security"
+)
+
+var categories = []string{CategoryAdded, CategoryChanged, CategoryDeprecated, CategoryRemoved, CategoryFixed, CategorySecurity}
+
+const systemPrompt = `You are a release manager writing the changelog of a software project.
+You are given the commits of the release with the files each one changed. Use show_commit to read a
+commit's diff when its message does not make the change clear.
+
+Record every change a user of the project would notice with add_entry: one entry per change, written
+for users in the imperative ("Add ...", "Fix ..."), in the category it belongs to. Merge commits that
+belong to one change into one entry. Skip purely internal changes such as refactorings, test-only and
+CI changes. Refer to the commits of each entry by their hash, and to pull requests as #N only when a
+commit message mentions them.
+
+When you are done, reply with one sentence summing up the release.`
+
+// Commit is a commit of the range with the files it changed.
+type Commit struct {
+	SHA     string     `json:"sha"`
+	Author  string     `json:"author"`
+	Subject string     `json:"subject"`
+	Body    string     `json:"body,omitempty"`
+	Files   []FileStat `json:"files,omitempty"`
+}
+
+// FileStat is the line count of a file's change; binary files have -1.
+type FileStat struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+}
+
+// Entry is one line of the changelog. Refs are commit hashes and pull
+// requests ("#12").
+type Entry struct {
+	Category string   `json:"category"`
+	Text     string   `json:"text"`
+	Refs     []string `json:"refs,omitempty"`
+}
+
+// Result is a generated changelog section.
+type Result struct {
+	Version string    `json:"version"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	// Entries are ordered by category, then in the order they were added.
+	Entries []Entry `json:"entries"`
+	// Unverified are the references the verification pass dropped, with
+	// the reason.
+	Unverified []string `json:"unverified,omitempty"`
+}
+
+// Options configure Generate.
+type Options struct {
+	Client *openai.Client
+	Model  string
+	// Dir is the git work tree; empty means the working directory.
+	Dir string
+	// Since is the tag or commit the release starts after.
+	Since string
+	// Version heads the section (default "Unreleased").
+	Version string
+	// Forge, when set, is asked whether referenced pull requests exist;
+	// without one a pull request must be mentioned by a commit of the range.
+	Forge forge.Forge
+	// MaxTurns limits the model calls (default 40).
+	MaxTurns int
+}
+
+// Generate writes the changelog of the commits after opts.Since up to HEAD.
+func Generate(ctx context.Context, opts Options) (Result, error) {
+	if opts.MaxTurns <= 0 {
+		opts.MaxTurns = defaultMaxTurns
+	}
+	if strings.TrimSpace(opts.Version) == "" {
+		opts.Version = "Unreleased"
+	}
+	commits, err := Commits(ctx, opts.Dir, opts.Since)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(commits) == 0 {
+		return Result{}, fmt.Errorf("no commits since %s", opts.Since)
+	}
+
+	collector := &entries{}
+	registry := tools.New()
+	registry.Register(showCommitToolDef(), showCommitHandler(opts.Dir, commits))
+	registry.Register(addEntryToolDef(), collector.handle)
+	a, err := agent.New(
+		agent.WithClient(opts.Client),
+		agent.WithModel(opts.Model),
+		agent.WithTools(registry),
+		agent.WithSystemPrompt(systemPrompt),
+		agent.WithMaxTurns(opts.MaxTurns),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	summary, err := a.Run(ctx, changelogPrompt(opts.Since, commits))
+	result := Result{Version: opts.Version, Date: time.Now(), Summary: strings.TrimSpace(summary), Entries: collector.sorted()}
+	if err != nil {
+		return result, err
+	}
+	checker := &RefChecker{Dir: opts.Dir, Commits: commits, Forge: opts.Forge}
+	result.Entries, result.Unverified = checker.Verify(ctx, result.Entries)
+	return result, nil
+}
+
+// Commits lists the commits after since up to HEAD, newest first.
+func Commits(ctx context.Context, dir, since string) ([]Commit, error) {
+	if strings.TrimSpace(since) == "" {
+		return nil, fmt.Errorf("no starting point: pass a tag or commit")
+	}
+	// Records start with \x1e; the fields of the header are split by \x1f
+	// and --numstat lines follow the body.
+	out, err := git(ctx, dir, "log", "--format=%x1e%H%x1f%an%x1f%s%x1f%b%x1f", "--numstat", since+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(record, "\x1f", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		c := Commit{SHA: fields[0], Author: fields[1], Subject: fields[2], Body: strings.TrimSpace(fields[3])}
+		for _, line := range strings.Split(strings.TrimSpace(fields[4]), "\n") {
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			c.Files = append(c.Files, FileStat{Path: parts[2], Added: numstat(parts[0]), Deleted: numstat(parts[1])})
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+func numstat(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1 // "-" for binary files
+	}
+	return n
+}
+
+// LatestTag returns the newest tag reachable from HEAD, the usual start of
+// the next release.
+func LatestTag(ctx context.Context, dir string) (string, error) {
+	out, err := git(ctx, dir, "describe", "--tags", "--abbrev=0")
+	if err != nil {
+		return "", fmt.Errorf("no tag to start from, pass --since: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func changelogPrompt(since string, commits []Commit) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Write the changelog of the %d commits since %s, newest first:\n", len(commits), since)
+	for _, c := range commits {
+		if b.Len() > maxPromptLength {
+			b.WriteString("\n(The list was truncated; the remaining commits are older.)\n")
+			break
+		}
+		fmt.Fprintf(&b, "\n%s %s (%s)\n", c.SHA[:min(len(c.SHA), 12)], c.Subject, c.Author)
+		if body := c.Body; body != "" {
+			if len(body) > maxBodyLength {
+				body = body[:maxBodyLength] + "..."
+			}
+			fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(body, "\n", "\n  "))
+		}
+		for _, f := range c.Files {
+			if f.Added < 0 {
+				fmt.Fprintf(&b, "  %s (binary)\n", f.Path)
+				continue
+			}
+			fmt.Fprintf(&b, "  %s +%d -%d\n", f.Path, f.Added, f.Deleted)
+		}
+	}
+	return b.String()
+}
+
+func showCommitToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "show_commit",
+			Description: openai.String("Show the message and diff of one commit of the release."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"sha": map[string]any{"type": "string", "description": "Commit hash, at least 7 characters."},
+				},
+				"required": []string{"sha"},
+			},
+		},
+	}
+}
+
+// showCommitHandler shows commits of the range only.
+func showCommitHandler(dir string, commits []Commit) tools.Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		sha, _ := args["sha"].(string)
+		c, ok := findCommit(commits, sha)
+		if !ok {
+			return "", fmt.Errorf("%q is not a commit of the release", sha)
+		}
+		out, err := git(ctx, dir, "show", "--stat", "--patch", c.SHA)
+		if err != nil {
+			return "", err
+		}
+		if len(out) > maxShowLength {
+			out = out[:maxShowLength] + "\n... (diff truncated)"
+		}
+		return out, nil
+	}
+}
+
+// findCommit looks sha up by prefix.
+func findCommit(commits []Commit, sha string) (Commit, bool) {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if len(sha) < 7 {
+		return Commit{}, false
+	}
+	for _, c := range commits {
+		if strings.HasPrefix(c.SHA, sha) {
+			return c, true
+		}
+	}
+	return Commit{}, false
+}
+
+func addEntryToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "add_entry",
+			Description: openai.String("Add one changelog entry. Call once per user-visible change."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"category": map[string]any{"type": "string", "enum": categories},
+					"text":     map[string]any{"type": "string", "description": "The change, for users, in one sentence."},
+					"refs": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Commit hashes of the change, and pull requests as #N.",
+					},
+				},
+				"required": []string{"category", "text", "refs"},
+			},
+		},
+	}
+}
+
+type entries struct {
+	mu   sync.Mutex
+	list []Entry
+}
+
+func (e *entries) handle(_ context.Context, args map[string]any) (string, error) {
+	var entry Entry
+	entry.Category, _ = args["category"].(string)
+	entry.Text, _ = args["text"].(string)
+	entry.Text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(entry.Text), "- "))
+	if !slices.Contains(categories, entry.Category) {
+		return "", fmt.Errorf("invalid 'category' %q: want one of %s", entry.Category, strings.Join(categories, ", "))
+	}
+	if entry.Text == "" {
+		return "", fmt.Errorf("'text' is required")
+	}
+	refs, _ := args["refs"].([]any)
+	for _, raw := range refs {
+		if ref, ok := raw.(string); ok && strings.TrimSpace(ref) != "" && !slices.Contains(entry.Refs, strings.TrimSpace(ref)) {
+			entry.Refs = append(entry.Refs, strings.TrimSpace(ref))
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, entry)
+	return fmt.Sprintf("Added entry %d.", len(e.list)), nil
+}
+
+func (e *entries) sorted() []Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := append([]Entry(nil), e.list...)
+	slices.SortStableFunc(list, func(a, b Entry) int {
+		return slices.Index(categories, a.Category) - slices.Index(categories, b.Category)
+	})
+	return list
+}
+
+var prRef = regexp.MustCompile(`^[#!](\d+)$`)
+
+// RefChecker verifies the references of changelog entries.
+type RefChecker struct {
+	Dir string
+	// Commits is the release; commit references must be among them.
+	Commits []Commit
+	// Forge, when set, is asked whether pull requests exist; otherwise a
+	// commit of the release must mention them.
+	Forge forge.Forge
+}
+
+// Check reports why ref does not point at a commit of the release or an
+// existing pull request, or nil when it does.
+func (c *RefChecker) Check(ctx context.Context, ref string) error {
+	if m := prRef.FindStringSubmatch(ref); m != nil {
+		number, _ := strconv.Atoi(m[1])
+		if c.Forge != nil {
+			if _, err := c.Forge.GetPR(ctx, "", number); err != nil {
+				return fmt.Errorf("pull request #%d: %w", number, err)
+			}
+			return nil
+		}
+		mention := regexp.MustCompile(`(^|[^\w&])[#!]` + m[1] + `\b`)
+		for _, commit := range c.Commits {
+			if mention.MatchString(commit.Subject) || mention.MatchString(commit.Body) {
+				return nil
+			}
+		}
+		return fmt.Errorf("pull request #%d is not mentioned by any commit of the release", number)
+	}
+	if _, ok := findCommit(c.Commits, ref); ok {
+		return nil
+	}
+	if _, err := git(ctx, c.Dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+		return fmt.Errorf("commit %s is not part of the release", ref)
+	}
+	return fmt.Errorf("no commit %s", ref)
+}
+
+// Verify checks every reference of entries and returns the entries without
+// the references that failed, and the failures. Commit references are
+// normalized to the short hash.
+func (c *RefChecker) Verify(ctx context.Context, entries []Entry) ([]Entry, []string) {
+	var unverified []string
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		out[i] = Entry{Category: e.Category, Text: e.Text}
+		for _, ref := range e.Refs {
+			if err := c.Check(ctx, ref); err != nil {
+				unverified = append(unverified, err.Error())
+				continue
+			}
+			if commit, ok := findCommit(c.Commits, ref); ok {
+				ref = commit.SHA[:7]
+			}
+			if !slices.Contains(out[i].Refs, ref) {
+				out[i].Refs = append(out[i].Refs, ref)
+			}
+		}
+	}
+	return out, unverified
+}
+
+// Format renders result as a Keep a Changelog section in Markdown.
+func Format(result Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## [%s] - %s\n", result.Version, result.Date.Format("2006-01-02"))
+	for _, category := range categories {
+		first := true
+		for _, e := range result.Entries {
+			if e.Category != category {
+				continue
+			}
+			if first {
+				fmt.Fprintf(&b, "\n### %s\n\n", category)
+				first = false
+			}
+			line := "- " + e.Text
+			if len(e.Refs) > 0 {
+				line += " (" + strings.Join(e.Refs, ", ") + ")"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	if len(result.Entries) == 0 {
+		b.WriteString("\nNo user-visible changes.\n")
+	}
+	return b.String()
+}
+
+// Prepend inserts section into the changelog document doc, after its title
+// and introduction, before the newest existing section. An empty doc gets a
+// title.
+func Prepend(doc, section string) string {
+	if strings.TrimSpace(doc) == "" {
+		return "# Changelog\n\n" + section
+	}
+	if strings.HasPrefix(doc, "## ") {
+		return section + "\n" + doc
+	}
+	at := strings.Index(doc, "\n## ")
+	if at < 0 {
+		return strings.TrimRight(doc, "\n") + "\n\n" + section
+	}
+	return doc[:at+1] + section + "\n" + doc[at+1:]
+}
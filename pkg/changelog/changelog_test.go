@@ -0,0 +1,197 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/github"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestCommits_ParsesLogAndNumstat(t *testing.T) {
+	repo := initRepo(t)
+	commit(t, repo, "calc.go", "package calc\n\nfunc Div() {}\n", "Add Div (#12)\n\nDivides two numbers.")
+	commit(t, repo, "calc.go", "package calc\n", "Remove Div")
+
+	commits, err := Commits(context.Background(), repo, "v1.0.0")
+	if err != nil {
+		t.Fatalf("Commits: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "Remove Div" || commits[1].Body != "Divides two numbers." {
+		t.Fatalf("commits = %+v", commits)
+	}
+	if f := commits[1].Files; len(f) != 1 || f[0].Path != "calc.go" || f[0].Added != 3 || f[0].Deleted != 0 {
+		t.Fatalf("files = %+v", f)
+	}
+	if tag, err := LatestTag(context.Background(), repo); err != nil || tag != "v1.0.0" {
+		t.Fatalf("LatestTag = %q, %v", tag, err)
+	}
+	if _, err := Commits(context.Background(), repo, "v9"); err == nil {
+		t.Fatal("expected an error for an unknown tag")
+	}
+}
+
+func TestGenerate_DropsUnverifiedRefs(t *testing.T) {
+	repo := initRepo(t)
+	add := commit(t, repo, "calc.go", "package calc\n\nfunc Div() {}\n", "Add Div (#12)")
+	fix := commit(t, repo, "calc.go", "package calc\n\nfunc Div() error { return nil }\n", "Return an error on division by zero")
+	client := scriptedClient(t,
+		toolCalls(map[string]any{"name": "show_commit", "arguments": `{"sha": "` + fix[:8] + `"}`}),
+		toolCalls(
+			entry(`{"category": "Fixed", "text": "Return an error instead of panicking on division by zero.", "refs": ["`+fix[:10]+`", "#99"]}`),
+			entry(`{"category": "Added", "text": "- Add Div.", "refs": ["`+add+`", "#12", "0000000deadbeef"]}`),
+			entry(`{"category": "Improved", "text": "ignored", "refs": []}`),
+		),
+		reply("Adds division."),
+	)
+
+	result, err := Generate(context.Background(), Options{Client: client, Model: "m", Dir: repo, Since: "v1.0.0", Version: "1.1.0"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(result.Entries) != 2 || result.Entries[0].Category != CategoryAdded || result.Entries[0].Text != "Add Div." {
+		t.Fatalf("entries = %+v", result.Entries)
+	}
+	if refs := result.Entries[0].Refs; len(refs) != 2 || refs[0] != add[:7] || refs[1] != "#12" {
+		t.Fatalf("added refs = %v", refs)
+	}
+	if refs := result.Entries[1].Refs; len(refs) != 1 || refs[0] != fix[:7] {
+		t.Fatalf("fixed refs = %v", refs)
+	}
+	if len(result.Unverified) != 2 || result.Unverified[0] != "no commit 0000000deadbeef" || !strings.Contains(result.Unverified[1], "#99") {
+		t.Fatalf("unverified = %v", result.Unverified)
+	}
+
+	result.Date = time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	want := "## [1.1.0] - 2026-10-15\n\n### Added\n\n- Add Div. (" + add[:7] + ", #12)\n\n### Fixed\n\n- Return an error instead of panicking on division by zero. (" + fix[:7] + ")\n"
+	if got := Format(result); got != want {
+		t.Fatalf("Format:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRefChecker_AsksForgeAndRejectsOutsideCommits(t *testing.T) {
+	repo := initRepo(t)
+	base := strings.TrimSpace(gitOut(t, repo, "rev-parse", "HEAD"))
+	commits := []Commit{{SHA: commit(t, repo, "a.txt", "a\n", "Change a")}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/pulls/5", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"number": 5}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	checker := &RefChecker{Dir: repo, Commits: commits, Forge: forge.GitHub(github.NewClient(srv.URL, "tok", "acme/widgets"))}
+
+	if err := checker.Check(context.Background(), "#5"); err != nil {
+		t.Fatalf("#5: %v", err)
+	}
+	if err := checker.Check(context.Background(), "#6"); err == nil {
+		t.Fatal("#6 should not exist")
+	}
+	if err := checker.Check(context.Background(), base[:7]); err == nil || !strings.Contains(err.Error(), "not part of the release") {
+		t.Fatalf("commit before the release: %v", err)
+	}
+}
+
+func TestPrepend(t *testing.T) {
+	section := "## [1.1.0] - 2026-10-15\n\n### Added\n\n- New.\n"
+	cases := map[string]string{
+		"": "# Changelog\n\n" + section,
+		"# Changelog\n\nAll notable changes.\n\n## [1.0.0] - 2026-01-01\n\n- Old.\n": "# Changelog\n\nAll notable changes.\n\n" + section + "\n## [1.0.0] - 2026-01-01\n\n- Old.\n",
+		"## [1.0.0] - 2026-01-01\n": section + "\n## [1.0.0] - 2026-01-01\n",
+		"# Changelog\n":             "# Changelog\n\n" + section,
+	}
+	for doc, want := range cases {
+		if got := Prepend(doc, section); got != want {
+			t.Errorf("Prepend(%q) = %q; want %q", doc, got, want)
+		}
+	}
+}
+
+// initRepo creates a git repository with one commit tagged v1.0.0.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	gitOut(t, repo, "init", "-q")
+	commit(t, repo, "README", "widgets\n", "init")
+	gitOut(t, repo, "tag", "v1.0.0")
+	return repo
+}
+
+// commit writes name and commits it, returning the commit hash.
+func commit(t *testing.T, repo, name, content, message string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitOut(t, repo, "add", "-A")
+	gitOut(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", message)
+	return strings.TrimSpace(gitOut(t, repo, "rev-parse", "HEAD"))
+}
+
+func gitOut(t *testing.T, repo string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repo
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func entry(args string) map[string]any {
+	return map[string]any{"name": "add_entry", "arguments": args}
+}
+
+func toolCalls(functions ...map[string]any) map[string]any {
+	calls := make([]map[string]any, len(functions))
+	for i, fn := range functions {
+		calls[i] = map[string]any{"id": "call-" + string(rune('a'+i)), "type": "function", "function": fn}
+	}
+	return completion("tool_calls", map[string]any{"role": "assistant", "content": "", "tool_calls": calls})
+}
+
+func reply(content string) map[string]any {
+	return completion("stop", map[string]any{"role": "assistant", "content": content})
+}
+
+func completion(finishReason string, message map[string]any) map[string]any {
+	return map[string]any{
+		"id": "mock-id", "object": "chat.completion", "created": 0, "model": "mock-model",
+		"choices": []map[string]any{{"index": 0, "finish_reason": finishReason, "message": message}},
+	}
+}
+
+func scriptedClient(t *testing.T, responses ...map[string]any) *openai.Client {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(responses) == 0 {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	t.Cleanup(srv.Close)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(srv.URL+"/v1/"), option.WithMaxRetries(0))
+	return &client
+}
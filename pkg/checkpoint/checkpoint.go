@@ -0,0 +1,270 @@
+// Package checkpoint copies files aside before the agent edits them so each
+// file can be reverted individually, independent of git.
+//
+// A session's checkpoints live in one directory: every saved original is
+// stored as <seq>.orig, and index.jsonl lists the entries in order.
+package checkpoint
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+const (
+	// DefaultDir is relative to the project root; sessions are subdirectories.
+	DefaultDir = ".checkpoints"
+	indexFile  = "index.jsonl"
+)
+
+// ErrNoCheckpoint is returned when a file has no checkpoint to restore.
+var ErrNoCheckpoint = errors.New("no checkpoint for file")
+
+// DefaultEditTools are the tools whose target file is checkpointed.
+var DefaultEditTools = []string{"write_file", "edit_file", "multi_edit"}
+
+// Entry is one saved original.
+type Entry struct {
+	Seq  int       `json:"seq"`
+	Path string    `json:"path"`
+	Tool string    `json:"tool,omitempty"`
+	Time time.Time `json:"time"`
+	// Existed is false when the file did not exist yet; restoring deletes it.
+	Existed bool `json:"existed"`
+}
+
+// Store manages the checkpoints of one session.
+type Store struct {
+	dir string
+
+	mu      sync.Mutex
+	entries []Entry
+	seq     int
+}
+
+// Open loads (or creates) the checkpoint directory of a session.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create checkpoint dir: %w", err)
+	}
+	s := &Store{dir: dir}
+
+	file, err := os.Open(filepath.Join(dir, indexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("open checkpoint index: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("decode checkpoint index: %w", err)
+		}
+		s.entries = append(s.entries, entry)
+		s.seq = max(s.seq, entry.Seq)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read checkpoint index: %w", err)
+	}
+	return s, nil
+}
+
+// Entries returns the checkpoints that can still be restored, oldest first.
+func (s *Store) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.entries)
+}
+
+// Save copies the current content of path (an absolute path) aside.
+func (s *Store) Save(path, tool string) (Entry, error) {
+	data, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return Entry{}, fmt.Errorf("read original: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := Entry{Seq: s.seq + 1, Path: path, Tool: tool, Time: time.Now().UTC(), Existed: existed}
+	if existed {
+		if err := os.WriteFile(s.blobPath(entry.Seq), data, 0o600); err != nil {
+			return Entry{}, fmt.Errorf("write checkpoint: %w", err)
+		}
+	}
+	entries := append(slices.Clone(s.entries), entry)
+	if err := s.writeIndex(entries); err != nil {
+		return Entry{}, err
+	}
+	s.entries, s.seq = entries, entry.Seq
+	return entry, nil
+}
+
+// Restore reverts path to its most recent checkpoint and drops that
+// checkpoint, so repeated calls step further back.
+func (s *Store) Restore(path string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.lastIndex(path)
+	if i < 0 {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNoCheckpoint, path)
+	}
+	entry := s.entries[i]
+
+	if entry.Existed {
+		data, err := os.ReadFile(s.blobPath(entry.Seq))
+		if err != nil {
+			return Entry{}, fmt.Errorf("read checkpoint: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return Entry{}, err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return Entry{}, fmt.Errorf("restore %s: %w", path, err)
+		}
+	} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return Entry{}, fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	entries := slices.Delete(slices.Clone(s.entries), i, i+1)
+	if err := s.writeIndex(entries); err != nil {
+		return Entry{}, err
+	}
+	s.entries = entries
+	_ = os.Remove(s.blobPath(entry.Seq))
+	return entry, nil
+}
+
+// RestoreFile implements tools.FileRestorer.
+func (s *Store) RestoreFile(path string) (string, error) {
+	entry, err := s.Restore(path)
+	if err != nil {
+		return "", err
+	}
+	if !entry.Existed {
+		return fmt.Sprintf("Removed %s (it did not exist before %s).", path, entry.Tool), nil
+	}
+	return fmt.Sprintf("Restored %s to its content before %s at %s.", path, entry.Tool, entry.Time.Local().Format(time.TimeOnly)), nil
+}
+
+// Middleware checkpoints the target of each edit tool call before it runs.
+// If the checkpoint cannot be written the edit is refused.
+func (s *Store) Middleware(editTools ...string) tools.Middleware {
+	if len(editTools) == 0 {
+		editTools = DefaultEditTools
+	}
+	return func(name string, next tools.Handler) tools.Handler {
+		if !slices.Contains(editTools, name) {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			raw, _ := args["path"].(string)
+			if raw == "" {
+				return next(ctx, args)
+			}
+			path, err := tools.ResolveWorkspacePath(ctx, raw)
+			if err != nil {
+				return next(ctx, args) // let the tool report the invalid path
+			}
+			if _, err := s.Save(path, name); err != nil {
+				return "", fmt.Errorf("checkpoint before %s: %w", name, err)
+			}
+			return next(ctx, args)
+		}
+	}
+}
+
+// RestoreCommand returns the /restore slash command. Without arguments it
+// lists the files that can be restored.
+func (s *Store) RestoreCommand() command.Command {
+	return command.Command{
+		Name:        "restore",
+		Usage:       "[path]",
+		Description: "revert a file to its content before the last agent edit",
+		Run: func(ctx context.Context, args []string) (string, error) {
+			if len(args) == 0 {
+				return s.describe(), nil
+			}
+			path, err := tools.ResolveWorkspacePath(ctx, args[0])
+			if err != nil {
+				return "", err
+			}
+			return s.RestoreFile(path)
+		},
+	}
+}
+
+func (s *Store) describe() string {
+	entries := s.Entries()
+	if len(entries) == 0 {
+		return "No checkpoints."
+	}
+	counts := make(map[string]int)
+	var order []string
+	for _, e := range entries {
+		if counts[e.Path] == 0 {
+			order = append(order, e.Path)
+		}
+		counts[e.Path]++
+	}
+
+	var b strings.Builder
+	b.WriteString("Checkpointed files:")
+	for _, path := range order {
+		fmt.Fprintf(&b, "\n  %s (%d checkpoint(s))", path, counts[path])
+	}
+	return b.String()
+}
+
+func (s *Store) lastIndex(path string) int {
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) blobPath(seq int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.orig", seq))
+}
+
+func (s *Store) writeIndex(entries []Entry) error {
+	tmp, err := os.CreateTemp(s.dir, indexFile+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("write checkpoint index: %w", err)
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return fmt.Errorf("write checkpoint index: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write checkpoint index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, indexFile)); err != nil {
+		return fmt.Errorf("write checkpoint index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+func TestStore_RestoreStepsBack(t *testing.T) {
+	work := t.TempDir()
+	target := filepath.Join(work, "main.go")
+	writeFile(t, target, "v1")
+
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mustSave(t, store, target)
+	writeFile(t, target, "v2")
+	mustSave(t, store, target)
+	writeFile(t, target, "v3")
+
+	if _, err := store.Restore(target); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readFile(t, target); got != "v2" {
+		t.Fatalf("after first restore = %q, want v2", got)
+	}
+	if _, err := store.Restore(target); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readFile(t, target); got != "v1" {
+		t.Fatalf("after second restore = %q, want v1", got)
+	}
+	if _, err := store.Restore(target); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("expected ErrNoCheckpoint, got %v", err)
+	}
+}
+
+func TestStore_RestoreRemovesNewFileAndSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "new.txt")
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mustSave(t, store, target)
+	writeFile(t, target, "created by agent")
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(reopened.Entries()) != 1 {
+		t.Fatalf("entries after reopen = %+v", reopened.Entries())
+	}
+	if _, err := reopened.Restore(target); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("new file should be removed, stat err = %v", err)
+	}
+}
+
+func TestStore_MiddlewareCheckpointsEdits(t *testing.T) {
+	work := t.TempDir()
+	t.Chdir(work)
+	target := filepath.Join(work, "a.txt")
+	writeFile(t, target, "before")
+
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	registry := tools.New()
+	registry.Register(tools.WriteFileToolDef(), tools.WriteFileHandler)
+	registry.Register(tools.ReadFileToolDef(), tools.ReadFileHandler)
+	wrapped := registry.WithMiddleware(store.Middleware())
+
+	if _, err := wrapped.Dispatch(context.Background(), "write_file", map[string]any{"path": target, "content": "after"}); err != nil {
+		t.Fatalf("write_file: %v", err)
+	}
+	if _, err := wrapped.Dispatch(context.Background(), "read_file", map[string]any{"path": target}); err != nil {
+		t.Fatalf("read_file: %v", err)
+	}
+	entries := store.Entries()
+	if len(entries) != 1 || entries[0].Tool != "write_file" {
+		t.Fatalf("expected one write_file checkpoint, got %+v", entries)
+	}
+
+	output, err := store.RestoreCommand().Run(context.Background(), []string{"a.txt"})
+	if err != nil {
+		t.Fatalf("/restore: %v", err)
+	}
+	if got := readFile(t, target); got != "before" {
+		t.Fatalf("file = %q after %q", got, output)
+	}
+}
+
+func mustSave(t *testing.T, store *Store, path string) {
+	t.Helper()
+	if _, err := store.Save(path, "write_file"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,215 @@
+// Package codereview reviews a diff with the model. The reviewer gets the
+// diff, read-only tools to look at the surrounding code, and a report_finding
+// tool; the findings it reports are the structured result.
+package codereview
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	// maxDiffLength keeps the prompt within the model's context.
+	maxDiffLength   = 60000
+	defaultMaxTurns = 30
+)
+
+// Severities, most severe first.
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityNit      = "nit"
+)
+
+var severityRank = map[string]int{SeverityCritical: 0, SeverityMajor: 1, SeverityMinor: 2, SeverityNit: 3}
+
+const systemPrompt = `You are a senior engineer reviewing a code change. You are given a unified diff.
+Use the read-only tools to read the surrounding code when the diff alone is not enough.
+
+Look for bugs, security problems, race conditions, missing error handling, missing tests and
+unclear code. Report each problem with the report_finding tool, pointing at the file and the line
+on the new side of the diff. Do not report style preferences that the surrounding code does not follow.
+
+When you are done, reply with a short overall summary of the change and its risk.`
+
+// Finding is one problem the reviewer reported.
+type Finding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line,omitempty"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Result is a finished review; Findings are sorted by severity, then file
+// and line.
+type Result struct {
+	Summary  string    `json:"summary"`
+	Findings []Finding `json:"findings"`
+}
+
+// Options configure a review.
+type Options struct {
+	Client *openai.Client
+	Model  string
+	// MaxTurns limits the reviewer's model calls (default 30).
+	MaxTurns int
+}
+
+// Run reviews diff. Relative paths in the tools resolve against the working
+// directory, which should hold the new version of the code.
+func Run(ctx context.Context, diff string, opts Options) (Result, error) {
+	if strings.TrimSpace(diff) == "" {
+		return Result{}, fmt.Errorf("nothing to review: the diff is empty")
+	}
+	if opts.MaxTurns <= 0 {
+		opts.MaxTurns = defaultMaxTurns
+	}
+
+	collector := &findings{}
+	registry := tools.New()
+	registry.Register(tools.ReadFileToolDef(), tools.ReadFileHandler)
+	registry.Register(tools.ListDirToolDef(), tools.ListDirHandler)
+	registry.Register(tools.ListFilesToolDef(), tools.ListFilesHandler)
+	registry.Register(tools.GrepToolDef(), tools.GrepHandler)
+	registry.Register(reportFindingToolDef(), collector.handle)
+
+	a, err := agent.New(
+		agent.WithClient(opts.Client),
+		agent.WithModel(opts.Model),
+		agent.WithTools(registry),
+		agent.WithSystemPrompt(systemPrompt),
+		agent.WithMaxTurns(opts.MaxTurns),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	summary, err := a.Run(ctx, reviewPrompt(diff))
+	result := Result{Summary: strings.TrimSpace(summary), Findings: collector.sorted()}
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func reviewPrompt(diff string) string {
+	note := ""
+	if len(diff) > maxDiffLength {
+		diff = diff[:maxDiffLength]
+		note = "\n(The diff was truncated; read the remaining files with the tools.)"
+	}
+	return "Review this diff:\n\n```diff\n" + diff + "\n```" + note
+}
+
+func reportFindingToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "report_finding",
+			Description: openai.String("Record one review finding. Call once per problem."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"file":       map[string]any{"type": "string", "description": "Path as shown in the diff."},
+					"line":       map[string]any{"type": "integer", "description": "Line number on the new side of the diff; omit for file-level findings."},
+					"severity":   map[string]any{"type": "string", "enum": []string{SeverityCritical, SeverityMajor, SeverityMinor, SeverityNit}},
+					"message":    map[string]any{"type": "string", "description": "What is wrong and why it matters."},
+					"suggestion": map[string]any{"type": "string", "description": "Concrete fix, optionally as code."},
+				},
+				"required": []string{"file", "severity", "message"},
+			},
+		},
+	}
+}
+
+type findings struct {
+	mu   sync.Mutex
+	list []Finding
+}
+
+func (f *findings) handle(_ context.Context, args map[string]any) (string, error) {
+	var finding Finding
+	finding.File, _ = args["file"].(string)
+	finding.Severity, _ = args["severity"].(string)
+	finding.Message, _ = args["message"].(string)
+	finding.Suggestion, _ = args["suggestion"].(string)
+	if line, ok := args["line"].(float64); ok && line > 0 {
+		finding.Line = int(line)
+	}
+	finding.File = strings.TrimPrefix(strings.TrimSpace(finding.File), "b/")
+	if finding.File == "" || strings.TrimSpace(finding.Message) == "" {
+		return "", fmt.Errorf("'file' and 'message' are required")
+	}
+	if _, ok := severityRank[finding.Severity]; !ok {
+		return "", fmt.Errorf("invalid 'severity' %q: want critical, major, minor or nit", finding.Severity)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = append(f.list, finding)
+	return fmt.Sprintf("Recorded finding %d.", len(f.list)), nil
+}
+
+func (f *findings) sorted() []Finding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := append([]Finding(nil), f.list...)
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return list
+}
+
+// StagedDiff returns the changes staged in the git index at dir.
+func StagedDiff(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--cached")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff --cached: %w", err)
+	}
+	return string(out), nil
+}
+
+// Format renders result for a terminal.
+func Format(result Result) string {
+	var b strings.Builder
+	if len(result.Findings) == 0 {
+		b.WriteString("No findings.\n")
+	}
+	for _, f := range result.Findings {
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		fmt.Fprintf(&b, "[%s] %s\n  %s\n", f.Severity, location, indent(f.Message))
+		if f.Suggestion != "" {
+			fmt.Fprintf(&b, "  suggestion: %s\n", indent(f.Suggestion))
+		}
+	}
+	if result.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", result.Summary)
+	}
+	return b.String()
+}
+
+func indent(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n  ")
+}
@@ -0,0 +1,144 @@
+package codereview
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/github"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const sampleDiff = `diff --git a/calc.go b/calc.go
+index 1111111..2222222 100644
+--- a/calc.go
++++ b/calc.go
+@@ -3,4 +3,5 @@ package calc
+ func Div(a, b int) int {
+-	return a / b
++	// TODO: handle zero
++	return a / b
+ }
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package calc
+`
+
+func TestNewLines(t *testing.T) {
+	lines := NewLines(sampleDiff)
+	for _, n := range []int{3, 4, 5, 6} {
+		if !lines["calc.go"][n] {
+			t.Fatalf("calc.go:%d should be commentable, got %v", n, lines["calc.go"])
+		}
+	}
+	if lines["calc.go"][7] || len(lines) != 1 {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+}
+
+func TestRun_CollectsFindingsSortedBySeverity(t *testing.T) {
+	client := scriptedClient(t,
+		toolCalls(
+			finding(`{"file": "b/calc.go", "line": 5, "severity": "minor", "message": "TODO left in code"}`),
+			finding(`{"file": "calc.go", "line": 5, "severity": "critical", "message": "division by zero panics", "suggestion": "return an error when b == 0"}`),
+			finding(`{"file": "calc.go", "severity": "bogus", "message": "ignored"}`),
+		),
+		reply("Small change; one crash risk."),
+	)
+
+	result, err := Run(context.Background(), sampleDiff, Options{Client: client, Model: "m"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Findings) != 2 || result.Findings[0].Severity != SeverityCritical || result.Findings[1].File != "calc.go" {
+		t.Fatalf("findings = %+v", result.Findings)
+	}
+	if result.Summary != "Small change; one crash risk." {
+		t.Fatalf("summary = %q", result.Summary)
+	}
+	out := Format(result)
+	if !strings.Contains(out, "[critical] calc.go:5") || !strings.Contains(out, "suggestion: return an error") {
+		t.Fatalf("Format = %q", out)
+	}
+
+	if _, err := Run(context.Background(), " ", Options{Client: client}); err == nil {
+		t.Fatal("expected an error for an empty diff")
+	}
+}
+
+func TestPostReview_SplitsInlineAndGeneralFindings(t *testing.T) {
+	var got github.Review
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/acme/widgets/pulls/4/reviews", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	result := Result{Summary: "Looks risky.", Findings: []Finding{
+		{File: "calc.go", Line: 5, Severity: SeverityCritical, Message: "division by zero"},
+		{File: "calc.go", Line: 40, Severity: SeverityMinor, Message: "outside the diff"},
+	}}
+	err := PostReview(context.Background(), forge.GitHub(github.NewClient(srv.URL, "tok", "acme/widgets")), "", 4, sampleDiff, "abc123", result)
+	if err != nil {
+		t.Fatalf("PostReview: %v", err)
+	}
+	if got.Event != "COMMENT" || got.CommitID != "abc123" || len(got.Comments) != 1 || got.Comments[0].Line != 5 {
+		t.Fatalf("review = %+v", got)
+	}
+	if !strings.Contains(got.Body, "Looks risky.") || !strings.Contains(got.Body, "`calc.go:40` **minor**: outside the diff") {
+		t.Fatalf("body = %q", got.Body)
+	}
+}
+
+func finding(args string) map[string]any {
+	return map[string]any{"name": "report_finding", "arguments": args}
+}
+
+func toolCalls(functions ...map[string]any) map[string]any {
+	calls := make([]map[string]any, len(functions))
+	for i, fn := range functions {
+		calls[i] = map[string]any{"id": "call-" + string(rune('a'+i)), "type": "function", "function": fn}
+	}
+	return completion("tool_calls", map[string]any{"role": "assistant", "content": "", "tool_calls": calls})
+}
+
+func reply(content string) map[string]any {
+	return completion("stop", map[string]any{"role": "assistant", "content": content})
+}
+
+func completion(finishReason string, message map[string]any) map[string]any {
+	return map[string]any{
+		"id": "mock-id", "object": "chat.completion", "created": 0, "model": "mock-model",
+		"choices": []map[string]any{{"index": 0, "finish_reason": finishReason, "message": message}},
+	}
+}
+
+func scriptedClient(t *testing.T, responses ...map[string]any) *openai.Client {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(responses) == 0 {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	t.Cleanup(srv.Close)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(srv.URL+"/v1/"), option.WithMaxRetries(0))
+	return &client
+}
@@ -0,0 +1,91 @@
+package codereview
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+)
+
+// NewLines maps each file of a unified diff to the line numbers on the new
+// side that forges accept inline comments on: added and context lines.
+func NewLines(diff string) map[string]map[int]bool {
+	lines := make(map[string]map[int]bool)
+	var file, prev string
+	next := 0
+	for _, line := range strings.Split(diff, "\n") {
+		header := strings.HasPrefix(prev, "--- ")
+		prev = line
+		switch {
+		case header && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+			next = 0
+		case strings.HasPrefix(line, "diff "):
+			file, next = "", 0
+		case strings.HasPrefix(line, "@@"):
+			next = hunkStart(line)
+		case file == "" || next == 0:
+		case strings.HasPrefix(line, "+"), strings.HasPrefix(line, " "):
+			if lines[file] == nil {
+				lines[file] = make(map[int]bool)
+			}
+			lines[file][next] = true
+			next++
+		}
+	}
+	return lines
+}
+
+// hunkStart parses the new-side start of "@@ -a,b +c,d @@".
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	for _, field := range fields {
+		if strings.HasPrefix(field, "+") {
+			start, _, _ := strings.Cut(field[1:], ",")
+			n, err := strconv.Atoi(start)
+			if err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// PostReview submits result as review comments on pull request number.
+// Findings on lines in the diff become inline comments; the rest are listed
+// in the review body with the summary.
+func PostReview(ctx context.Context, client forge.Forge, repo string, number int, diff, commitID string, result Result) error {
+	inDiff := NewLines(diff)
+	review := forge.Review{CommitID: commitID}
+	var body strings.Builder
+	body.WriteString(result.Summary)
+	var general []string
+	for _, f := range result.Findings {
+		text := fmt.Sprintf("**%s**: %s", f.Severity, strings.TrimSpace(f.Message))
+		if f.Suggestion != "" {
+			text += "\n\nSuggestion: " + strings.TrimSpace(f.Suggestion)
+		}
+		if f.Line > 0 && inDiff[f.File][f.Line] {
+			review.Comments = append(review.Comments, forge.ReviewComment{Path: f.File, Line: f.Line, Body: text})
+			continue
+		}
+		location := "`" + f.File + "`"
+		if f.Line > 0 {
+			location = fmt.Sprintf("`%s:%d`", f.File, f.Line)
+		}
+		general = append(general, "- "+location+" "+strings.ReplaceAll(text, "\n", "\n  "))
+	}
+	if len(general) > 0 {
+		body.WriteString("\n\n" + strings.Join(general, "\n"))
+	}
+	if len(result.Findings) == 0 {
+		body.WriteString("\n\nNo findings.")
+	}
+	review.Body = strings.TrimSpace(body.String())
+	return client.CreateReview(ctx, repo, number, review)
+}
@@ -0,0 +1,86 @@
+// Package command dispatches interactive slash commands (/undo, /help, ...)
+// typed at the agent prompt. Lines that do not start with "/" are left for
+// the model.
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Handler runs a command with its whitespace-separated arguments and returns
+// the text to show the user.
+type Handler func(ctx context.Context, args []string) (string, error)
+
+// Command describes one slash command. Name is given without the slash.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         Handler
+}
+
+// Registry holds the available commands. /help is always available.
+type Registry struct {
+	commands map[string]Command
+}
+
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{commands: make(map[string]Command)}
+}
+
+// Register adds or replaces a command.
+func (r *Registry) Register(cmd Command) {
+	r.commands[strings.TrimPrefix(cmd.Name, "/")] = cmd
+}
+
+// IsCommand reports whether line should be handled as a slash command.
+func IsCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "/")
+}
+
+// Dispatch runs the command in line. handled is false when line is not a
+// slash command and should be sent to the model instead.
+func (r *Registry) Dispatch(ctx context.Context, line string) (output string, handled bool, err error) {
+	if !IsCommand(line) {
+		return "", false, nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "/"))
+	if len(fields) == 0 {
+		return r.help(), true, nil
+	}
+
+	name, args := fields[0], fields[1:]
+	if name == "help" {
+		return r.help(), true, nil
+	}
+	cmd, ok := r.commands[name]
+	if !ok {
+		return "", true, fmt.Errorf("unknown command /%s (try /help)", name)
+	}
+	output, err = cmd.Run(ctx, args)
+	return output, true, err
+}
+
+func (r *Registry) help() string {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:\n  /help  show this list")
+	for _, name := range names {
+		cmd := r.commands[name]
+		usage := "/" + name
+		if cmd.Usage != "" {
+			usage += " " + cmd.Usage
+		}
+		fmt.Fprintf(&b, "\n  %s  %s", usage, cmd.Description)
+	}
+	return b.String()
+}
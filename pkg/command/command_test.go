@@ -0,0 +1,37 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Dispatch(t *testing.T) {
+	reg := New()
+	reg.Register(Command{
+		Name:        "echo",
+		Usage:       "<text>",
+		Description: "print text",
+		Run: func(_ context.Context, args []string) (string, error) {
+			return strings.Join(args, " "), nil
+		},
+	})
+
+	output, handled, err := reg.Dispatch(context.Background(), " /echo hello  world ")
+	if err != nil || !handled || output != "hello world" {
+		t.Fatalf("Dispatch = %q, %v, %v", output, handled, err)
+	}
+
+	if _, handled, _ := reg.Dispatch(context.Background(), "fix the bug"); handled {
+		t.Fatal("plain prompts must not be handled")
+	}
+
+	if _, handled, err := reg.Dispatch(context.Background(), "/nope"); !handled || err == nil {
+		t.Fatalf("unknown command should be handled with an error, got %v %v", handled, err)
+	}
+
+	help, _, _ := reg.Dispatch(context.Background(), "/help")
+	if !strings.Contains(help, "/echo <text>  print text") {
+		t.Fatalf("help missing command:\n%s", help)
+	}
+}
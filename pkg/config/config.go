@@ -0,0 +1,1110 @@
+// Package config loads the optional per-project agent configuration file
+// (.agent/config.json by default, or the path in AGENT_CONFIG).
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/cron"
+	"github.com/nickdu2009/learn-claude-code/pkg/i18n"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+)
+
+const DefaultRelativePath = ".agent/config.json"
+
+// LocalSettingsRelativePath is where the agent records the user's own
+// decisions, such as "always allow" answers. It is merged into the config
+// on Load and should not be committed.
+const LocalSettingsRelativePath = ".agent/settings.local.json"
+
+// UserSettingsRelativePath is the user's settings for every project,
+// relative to the home directory. It takes the same keys as the local
+// settings.
+const UserSettingsRelativePath = ".agent/settings.json"
+
+// Config is the project configuration. Every section is optional.
+type Config struct {
+	Databases map[string]Database `json:"databases,omitempty"`
+	Budget    Budget              `json:"budget"`
+	Provider  Provider            `json:"provider"`
+	GitHub    GitHub              `json:"github"`
+	Forge     Forge               `json:"forge"`
+	Hooks     Hooks               `json:"hooks"`
+	// Schedules are tasks the daemon submits by itself.
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// Webhooks are told when unattended runs start, need a permission,
+	// complete and fail.
+	Webhooks  []Webhook `json:"webhooks,omitempty"`
+	Server    Server    `json:"server"`
+	Workspace Workspace `json:"workspace"`
+	// HTTPRequest enables the http_request tool for the hosts it lists.
+	HTTPRequest HTTPRequest `json:"http_request"`
+	// Limits caps the resources of every command the bash tool runs.
+	Limits sandbox.Limits `json:"limits"`
+	// IsolateNetwork runs bash commands without network access (see
+	// sandbox.Offline); the user can grant it per command.
+	IsolateNetwork bool `json:"isolate_network,omitempty"`
+	// FailureHints appends the class of error and remedies to the output
+	// of commands that fail for a common reason (see tools.FailureHints).
+	FailureHints bool `json:"failure_hints,omitempty"`
+	// Memory gives the agent memory_write and memory_search, facts about
+	// the project kept across sessions (see pkg/memory).
+	Memory bool `json:"memory,omitempty"`
+	// OutputProcessors clean up tool output before the model sees it, by
+	// tool name or "*" for the others (see tools.OutputProcessors).
+	OutputProcessors map[string][]string `json:"output_processors,omitempty"`
+	// Permissions also takes the rules of the local settings file.
+	Permissions Permissions `json:"permissions"`
+	// DangerouslySkipPermissions disables every approval prompt and the
+	// dangerous-command check, like the --dangerously-skip-permissions flag.
+	// Meant for containers and CI only.
+	// It counts only from the user or local settings, see Settings.
+	DangerouslySkipPermissions bool `json:"dangerously_skip_permissions,omitempty"`
+	// MCP connects Model Context Protocol servers whose tools join the
+	// built-in ones.
+	MCP MCP `json:"mcp"`
+	// Profiles are named agent setups chosen with --profile or /profile.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// Language of the REPL's prompts, warnings and permission dialogs: "en"
+	// or "zh". Empty follows LC_ALL, LC_MESSAGES or LANG.
+	Language string `json:"language,omitempty"`
+	// Telemetry comes from the local settings only, see Settings.
+	Telemetry Telemetry `json:"-"`
+	// Ignored names the keys of the project config that Load dropped
+	// because only the user's settings may set them.
+	Ignored []string `json:"-"`
+}
+
+// HTTPRequest configures the http_request tool, which is only offered when
+// AllowedHosts lists at least one host.
+type HTTPRequest struct {
+	// AllowedHosts are the hosts the tool may reach, also through
+	// redirects: "localhost", "localhost:8080" (that port only) or
+	// "*.example.com" (any subdomain).
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+}
+
+func (h HTTPRequest) Validate() error {
+	for i, host := range h.AllowedHosts {
+		if strings.TrimSpace(host) == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("http_request allowed_hosts %d: want a host or host:port, got %q", i, host)
+		}
+	}
+	return nil
+}
+
+// Workspace widens what the file tools may reach. They are confined to the
+// project root otherwise.
+type Workspace struct {
+	// AdditionalDirectories are reachable too; relative entries are taken
+	// from the project root.
+	AdditionalDirectories []string `json:"additional_directories,omitempty"`
+}
+
+func (w Workspace) Validate() error {
+	for i, dir := range w.AdditionalDirectories {
+		if strings.TrimSpace(dir) == "" {
+			return fmt.Errorf("workspace additional_directories %d: path is required", i)
+		}
+	}
+	return nil
+}
+
+// Directories returns AdditionalDirectories with relative entries joined
+// to root.
+func (w Workspace) Directories(root string) []string {
+	dirs := make([]string, 0, len(w.AdditionalDirectories))
+	for _, dir := range w.AdditionalDirectories {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// Permissions lists the requests approved without asking.
+type Permissions struct {
+	Allow []permission.Rule `json:"allow,omitempty"`
+}
+
+func (p Permissions) Validate() error {
+	for i, rule := range p.Allow {
+		if strings.TrimSpace(rule.Tool) == "" {
+			return fmt.Errorf("permissions allow %d: tool is required", i)
+		}
+	}
+	return nil
+}
+
+// Profile permission policies.
+const (
+	ProfileAsk      = "ask"
+	ProfileReadOnly = "read-only"
+	ProfileSkip     = "skip"
+)
+
+// Profile is a named agent setup, such as "reviewer" or "docs-writer".
+// Empty fields keep the defaults.
+type Profile struct {
+	Description string `json:"description,omitempty"`
+	Model       string `json:"model,omitempty"`
+	// SystemPrompt is appended to the built-in system prompt.
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Tools limits the agent to the named tools.
+	Tools []string `json:"tools,omitempty"`
+	// Permission is ProfileAsk (the default), ProfileReadOnly, which also
+	// keeps only the read-only tools, or ProfileSkip, which approves
+	// everything like --dangerously-skip-permissions. ProfileSkip counts
+	// only in profiles from the user or local settings.
+	Permission string `json:"permission,omitempty"`
+	// Allow adds rules approved without asking while the profile is active,
+	// again only in profiles from the user or local settings.
+	Allow []permission.Rule `json:"allow,omitempty"`
+}
+
+func (p Profile) Validate() error {
+	switch p.Permission {
+	case "", ProfileAsk, ProfileReadOnly, ProfileSkip:
+	default:
+		return fmt.Errorf("permission %q: want %s, %s or %s", p.Permission, ProfileAsk, ProfileReadOnly, ProfileSkip)
+	}
+	for i, tool := range p.Tools {
+		if strings.TrimSpace(tool) == "" {
+			return fmt.Errorf("tools %d: name is required", i)
+		}
+	}
+	return Permissions{Allow: p.Allow}.Validate()
+}
+
+func validateProfiles(profiles map[string]Profile) error {
+	for name, p := range profiles {
+		if name == "" || strings.ContainsFunc(name, unicode.IsSpace) {
+			return fmt.Errorf("profile name %q must be a single word", name)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Settings is the content of the user and local settings files.
+type Settings struct {
+	// Permissions are merged into the project's, which may not allow
+	// anything itself.
+	Permissions Permissions `json:"permissions"`
+	// Profiles replace the project profiles of the same name. Only these
+	// may use ProfileSkip or allow rules.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// MCP servers replace the project servers of the same name and start
+	// without the project being trusted.
+	MCP MCP `json:"mcp,omitzero"`
+	// DangerouslySkipPermissions is read from the user and local settings
+	// and not from the project config, so a repository cannot switch off
+	// the approvals of whoever clones it.
+	DangerouslySkipPermissions bool `json:"dangerously_skip_permissions,omitempty"`
+	// Telemetry is read from here and not from the project config, so a
+	// repository cannot opt in whoever clones it.
+	Telemetry Telemetry `json:"telemetry,omitzero"`
+	// Provider holds the provider keys that choose where model requests,
+	// and the keys they carry, are sent.
+	Provider ProviderSettings `json:"provider,omitzero"`
+	// GitHub sets where the github tools send their requests and which
+	// variable holds the token they carry.
+	GitHub Endpoint `json:"github,omitzero"`
+	// Forge does the same for the GitLab or Gitea the forge section of the
+	// project config selects.
+	Forge Endpoint `json:"forge,omitzero"`
+}
+
+// Endpoint is the API URL and token variable of a service the tools call.
+// Only the user and local settings may set them: from the project config, a
+// cloned repository could have the user's token sent to its own server.
+type Endpoint struct {
+	APIURL   string `json:"api_url,omitempty"`
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+func (e Endpoint) apply(apiURL, tokenEnv *string) {
+	if e.APIURL != "" {
+		*apiURL = e.APIURL
+	}
+	if e.TokenEnv != "" {
+		*tokenEnv = e.TokenEnv
+	}
+}
+
+// ProviderSettings are the provider keys only the user and local settings
+// may set: from the project config, a cloned repository could send the
+// user's keys to a server of its own.
+type ProviderSettings struct {
+	// Fallbacks replace the fallback chain of the project config. Only
+	// these may have a BaseURL or an APIKeyEnv.
+	Fallbacks []FallbackModel `json:"fallbacks,omitempty"`
+	// Azure and Gemini set the endpoints of those backends.
+	Azure  ProviderEndpoint `json:"azure,omitzero"`
+	Gemini ProviderEndpoint `json:"gemini,omitzero"`
+}
+
+// ProviderEndpoint is the service URL of a backend, see Azure.Endpoint and
+// Gemini.Endpoint.
+type ProviderEndpoint struct {
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Telemetry opts in to anonymous usage counters (see pkg/telemetry).
+type Telemetry struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Endpoint receives the counters as JSON in a POST request.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+func (t Telemetry) Validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	u, err := url.Parse(strings.TrimSpace(t.Endpoint))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("telemetry endpoint %q must be an http(s) URL", t.Endpoint)
+	}
+	return nil
+}
+
+// MCP configures the Model Context Protocol servers (see pkg/mcp).
+type MCP struct {
+	// Servers are started by name; a server's tools may be namespaced with
+	// its name as <server>__<tool>.
+	Servers map[string]MCPServer `json:"servers,omitempty"`
+	// Conflicts is what happens to a server tool named like a tool that is
+	// already registered (built in, a plugin or from a server earlier in
+	// name order): "namespace" (the default) registers it as
+	// <server>__<tool>, "skip" drops it, "error" refuses to start.
+	Conflicts string `json:"conflicts,omitempty"`
+}
+
+var outputProcessorNames = []string{"strip_ansi", "collapse_progress", "dedupe_lines"}
+
+var (
+	mcpConflicts      = []string{"namespace", "skip", "error"}
+	mcpServerNameExpr = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,31}$`)
+)
+
+func (m MCP) Validate() error {
+	if m.Conflicts != "" && !slices.Contains(mcpConflicts, m.Conflicts) {
+		return fmt.Errorf("mcp conflicts %q: want %s", m.Conflicts, strings.Join(mcpConflicts, ", "))
+	}
+	for name, server := range m.Servers {
+		if !mcpServerNameExpr.MatchString(name) || strings.Contains(name, "__") {
+			return fmt.Errorf("mcp server name %q: use up to 32 letters, digits, - and single _", name)
+		}
+		if strings.TrimSpace(server.Command) == "" {
+			return fmt.Errorf("mcp server %q: command is required", name)
+		}
+	}
+	return nil
+}
+
+// MCPServer is a server run as a subprocess speaking MCP on stdin and
+// stdout.
+type MCPServer struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Env is added to the agent's environment; values may reference
+	// environment variables as ${NAME} so secrets stay out of the file.
+	Env map[string]string `json:"env,omitempty"`
+	// Project is set by Load for servers of the project config, which
+	// start only in a trusted project (see package trust). Servers of the
+	// user and local settings start regardless.
+	Project bool `json:"-"`
+}
+
+// UserServers returns m without the servers of the project config.
+func (m MCP) UserServers() MCP {
+	user := MCP{Conflicts: m.Conflicts}
+	for name, server := range m.Servers {
+		if !server.Project {
+			if user.Servers == nil {
+				user.Servers = map[string]MCPServer{}
+			}
+			user.Servers[name] = server
+		}
+	}
+	return user
+}
+
+// Database declares a named database/sql connection. DSN may reference
+// environment variables as ${NAME} so secrets stay out of the file.
+type Database struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+func (d Database) Validate() error {
+	if strings.TrimSpace(d.Driver) == "" {
+		return fmt.Errorf("database driver is required")
+	}
+	if strings.TrimSpace(d.DSN) == "" {
+		return fmt.Errorf("database dsn is required")
+	}
+	return nil
+}
+
+// Provider selects the LLM backend. Credentials stay in the environment.
+type Provider struct {
+	// Name is "qwen" (the default, DashScope), "azure", "gemini",
+	// "deepseek" or "openrouter".
+	Name       string     `json:"name,omitempty"`
+	Azure      Azure      `json:"azure"`
+	Gemini     Gemini     `json:"gemini"`
+	OpenRouter OpenRouter `json:"openrouter"`
+	// Fallbacks are tried in order when the primary model is unavailable.
+	Fallbacks []FallbackModel `json:"fallbacks,omitempty"`
+	// PromptCache is "auto" (the default), "explicit" or "off". Explicit
+	// marks the system prompt as cacheable on every call; auto does so for
+	// qwen only, since Azure OpenAI caches long prompts by itself.
+	PromptCache string `json:"prompt_cache,omitempty"`
+	// Capabilities overrides what models are assumed to support, keyed by
+	// model name or name prefix; the longest matching key wins.
+	Capabilities map[string]Capabilities `json:"capabilities,omitempty"`
+	// CircuitBreaker tunes when calls to a failing model stop for a while.
+	CircuitBreaker CircuitBreaker `json:"circuit_breaker"`
+}
+
+// CircuitBreaker configures the per-model circuit breaker (see llm.Breaker).
+type CircuitBreaker struct {
+	// Failures is how many consecutive provider failures open the circuit
+	// (default 3).
+	Failures int `json:"failures,omitempty"`
+	// CoolDown is a Go duration such as "1m": how long calls to the model
+	// fail fast before one is tried again (default 30s).
+	CoolDown string `json:"cool_down,omitempty"`
+}
+
+func (c CircuitBreaker) Validate() error {
+	if c.Failures < 0 {
+		return fmt.Errorf("circuit_breaker failures must not be negative")
+	}
+	if _, err := c.CoolDownDuration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CoolDownDuration parses CoolDown; an empty string means the default.
+func (c CircuitBreaker) CoolDownDuration() (time.Duration, error) {
+	if strings.TrimSpace(c.CoolDown) == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(c.CoolDown))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid circuit_breaker cool_down %q", c.CoolDown)
+	}
+	return d, nil
+}
+
+// Capabilities overrides the capabilities of matching models (see
+// llm.Capabilities); unset fields keep the built-in value.
+type Capabilities struct {
+	Tools             *bool `json:"tools,omitempty"`
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+	Vision            *bool `json:"vision,omitempty"`
+	JSONMode          *bool `json:"json_mode,omitempty"`
+	JSONSchema        *bool `json:"json_schema,omitempty"`
+	MaxContextTokens  *int  `json:"max_context_tokens,omitempty"`
+}
+
+// FallbackModel is one entry of the fallback chain. Without BaseURL it is
+// another model on the primary provider; with one it is any OpenAI-compatible
+// endpoint, e.g. a local Ollama at http://localhost:11434/v1. Entries with
+// a BaseURL or an APIKeyEnv count only from the user or local settings, see
+// ProviderSettings.
+type FallbackModel struct {
+	Model   string `json:"model"`
+	BaseURL string `json:"base_url,omitempty"`
+	// APIKeyEnv names the environment variable holding the endpoint's key.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+// Azure points the agent at an Azure OpenAI resource. Deployments maps
+// model names to deployment names; Deployment is the one used by default.
+// Endpoint counts only from the user or local settings, where the key goes
+// too, or else comes from AZURE_OPENAI_ENDPOINT.
+type Azure struct {
+	Endpoint    string            `json:"endpoint,omitempty"`
+	APIVersion  string            `json:"api_version,omitempty"`
+	Deployment  string            `json:"deployment,omitempty"`
+	Deployments map[string]string `json:"deployments,omitempty"`
+	// Auth is "api_key" (the default, AZURE_OPENAI_API_KEY) or "aad" for
+	// Microsoft Entra ID tokens from AZURE_OPENAI_AD_TOKEN or the Azure CLI.
+	Auth string `json:"auth,omitempty"`
+}
+
+// Gemini points the agent at Google's Gemini models: on Vertex AI when
+// Project is set (OAuth tokens from GOOGLE_OAUTH_ACCESS_TOKEN or gcloud),
+// through the Gemini API with GEMINI_API_KEY otherwise.
+type Gemini struct {
+	Project  string `json:"project,omitempty"`
+	Location string `json:"location,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Endpoint overrides the service URL, e.g. for a private endpoint. It
+	// counts only from the user or local settings, like Azure.Endpoint.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// OpenRouter selects the model and routes its requests among the providers
+// hosting it. Provider names are OpenRouter's, e.g. "deepinfra".
+type OpenRouter struct {
+	Model string `json:"model,omitempty"`
+	// Order lists the providers to try first; with AllowFallbacks false the
+	// request is pinned to them.
+	Order          []string `json:"order,omitempty"`
+	AllowFallbacks *bool    `json:"allow_fallbacks,omitempty"`
+	Only           []string `json:"only,omitempty"`
+	Ignore         []string `json:"ignore,omitempty"`
+	// Sort is "price", "throughput" or "latency".
+	Sort              string `json:"sort,omitempty"`
+	RequireParameters bool   `json:"require_parameters,omitempty"`
+	// DataCollection is "allow" or "deny".
+	DataCollection string `json:"data_collection,omitempty"`
+}
+
+func (o OpenRouter) Validate() error {
+	switch strings.TrimSpace(o.Sort) {
+	case "", "price", "throughput", "latency":
+	default:
+		return fmt.Errorf("unknown openrouter sort %q (want price, throughput or latency)", o.Sort)
+	}
+	switch strings.TrimSpace(o.DataCollection) {
+	case "", "allow", "deny":
+	default:
+		return fmt.Errorf("unknown openrouter data_collection %q (want allow or deny)", o.DataCollection)
+	}
+	if o.AllowFallbacks != nil && !*o.AllowFallbacks && len(o.Order) == 0 && len(o.Only) == 0 {
+		return fmt.Errorf("openrouter allow_fallbacks false needs order or only to pin the providers")
+	}
+	return nil
+}
+
+func (p Provider) Validate() error {
+	switch strings.ToLower(strings.TrimSpace(p.Name)) {
+	case "", "qwen", "azure", "gemini", "deepseek", "openrouter":
+	default:
+		return fmt.Errorf("unknown provider %q (want qwen, azure, gemini, deepseek or openrouter)", p.Name)
+	}
+	if err := p.OpenRouter.Validate(); err != nil {
+		return err
+	}
+	if err := p.CircuitBreaker.Validate(); err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(p.Azure.Auth)) {
+	case "", "api_key", "aad":
+	default:
+		return fmt.Errorf("unknown azure auth %q (want api_key or aad)", p.Azure.Auth)
+	}
+	switch strings.ToLower(strings.TrimSpace(p.PromptCache)) {
+	case "", "auto", "explicit", "off":
+	default:
+		return fmt.Errorf("unknown prompt_cache %q (want auto, explicit or off)", p.PromptCache)
+	}
+	for i, fb := range p.Fallbacks {
+		if strings.TrimSpace(fb.Model) == "" {
+			return fmt.Errorf("fallback %d: model is required", i)
+		}
+	}
+	for model, caps := range p.Capabilities {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("capabilities: model name is required")
+		}
+		if caps.MaxContextTokens != nil && *caps.MaxContextTokens < 0 {
+			return fmt.Errorf("capabilities %q: max_context_tokens must not be negative", model)
+		}
+	}
+	return nil
+}
+
+// GitHub configures the github tools. The token stays in the environment;
+// APIURL and TokenEnv, which decide where it goes, count only from the user
+// or local settings (see Endpoint).
+type GitHub struct {
+	// Repo is "owner/name"; empty means the repository of the origin remote.
+	Repo string `json:"repo,omitempty"`
+	// APIURL is the REST endpoint (default https://api.github.com); set it
+	// for GitHub Enterprise, e.g. https://ghe.example.com/api/v3.
+	APIURL string `json:"api_url,omitempty"`
+	// TokenEnv names the environment variable holding the token
+	// (default GITHUB_TOKEN).
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+func (g GitHub) Validate() error {
+	if repo := strings.TrimSpace(g.Repo); repo != "" {
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("github repo %q must be owner/name", g.Repo)
+		}
+	}
+	return nil
+}
+
+// Forge points the issue and pull request tools at a GitLab or Gitea
+// instance instead of GitHub, which the github section configures. The token
+// stays in the environment; APIURL and TokenEnv count only from the user or
+// local settings (see Endpoint).
+type Forge struct {
+	// Type is "github" (the default), "gitlab" or "gitea".
+	Type string `json:"type,omitempty"`
+	// Repo is the project path, "owner/name" or for GitLab also
+	// "group/subgroup/name"; empty means the project of the origin remote.
+	Repo string `json:"repo,omitempty"`
+	// APIURL is the REST endpoint, e.g. https://gitlab.example.com/api/v4
+	// or https://gitea.example.com/api/v1. GitLab defaults to gitlab.com;
+	// Gitea has no default.
+	APIURL string `json:"api_url,omitempty"`
+	// TokenEnv names the environment variable holding the token
+	// (default GITLAB_TOKEN or GITEA_TOKEN).
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+func (f Forge) Validate() error {
+	kind := strings.ToLower(strings.TrimSpace(f.Type))
+	switch kind {
+	case "", "github":
+		if f.Repo != "" || f.APIURL != "" || f.TokenEnv != "" {
+			return fmt.Errorf("forge type github is configured in the github section")
+		}
+		return nil
+	case "gitlab", "gitea":
+	default:
+		return fmt.Errorf("unknown forge type %q (want github, gitlab or gitea)", f.Type)
+	}
+	if repo := strings.Trim(strings.TrimSpace(f.Repo), "/"); repo != "" {
+		parts := strings.Split(repo, "/")
+		if len(parts) < 2 || (kind == "gitea" && len(parts) != 2) || slices.Contains(parts, "") {
+			return fmt.Errorf("forge repo %q must be owner/name", f.Repo)
+		}
+	}
+	return nil
+}
+
+// Hooks configures the git hooks run by cmd/agent hook.
+type Hooks struct {
+	PreCommit PreCommit `json:"pre_commit"`
+}
+
+// PreCommit configures the pre-commit check of the staged changes.
+type PreCommit struct {
+	// Model checks the rules; a small, cheap model is enough. Empty means
+	// the provider's default model.
+	Model string `json:"model,omitempty"`
+	// Rules replace the built-in rules (no-todo, tests-updated,
+	// no-secrets). A rule with a built-in ID and no text keeps the built-in
+	// text.
+	Rules []HookRule `json:"rules,omitempty"`
+}
+
+// HookRule is one rule of a hook, stated for the model in plain words.
+type HookRule struct {
+	ID   string `json:"id"`
+	Rule string `json:"rule,omitempty"`
+}
+
+func (p PreCommit) Validate() error {
+	seen := make(map[string]bool)
+	for i, rule := range p.Rules {
+		id := strings.TrimSpace(rule.ID)
+		if id == "" {
+			return fmt.Errorf("hooks pre_commit rule %d: id is required", i)
+		}
+		if seen[id] {
+			return fmt.Errorf("hooks pre_commit rule %q is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// Schedule is a task the daemon (cmd/agent daemon) submits on a cron
+// schedule, e.g. "update dependencies and run tests" every Monday. Each
+// finished run is delivered to the webhook, the log directory or both.
+type Schedule struct {
+	// Name identifies the schedule in task lists and log file names.
+	Name string `json:"name"`
+	// Cron is a five-field expression in local time, e.g. "0 9 * * mon",
+	// or a macro such as @daily (see pkg/cron).
+	Cron   string `json:"cron"`
+	Prompt string `json:"prompt"`
+	// Session continues the named conversation on every run; empty starts
+	// a new one each time.
+	Session string `json:"session,omitempty"`
+	// Webhook receives every finished task as JSON in a POST request.
+	Webhook string `json:"webhook,omitempty"`
+	// LogDir receives every finished task as <name>-<time>.json; relative
+	// paths are joined to the project root.
+	LogDir string `json:"log_dir,omitempty"`
+}
+
+func (s Schedule) Validate() error {
+	name := strings.TrimSpace(s.Name)
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("schedule name %q must be a non-empty file name", s.Name)
+	}
+	if _, err := cron.Parse(s.Cron); err != nil {
+		return fmt.Errorf("schedule %q: %w", name, err)
+	}
+	if strings.TrimSpace(s.Prompt) == "" {
+		return fmt.Errorf("schedule %q: prompt is required", name)
+	}
+	if hook := strings.TrimSpace(s.Webhook); hook != "" {
+		u, err := url.Parse(hook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("schedule %q: webhook %q must be an http(s) URL", name, s.Webhook)
+		}
+	}
+	return nil
+}
+
+// Webhook receives notifications about unattended runs (see pkg/notify).
+type Webhook struct {
+	URL string `json:"url,omitempty"`
+	// URLEnv names an environment variable holding the URL instead, for
+	// URLs that embed a secret such as Slack's.
+	URLEnv string `json:"url_env,omitempty"`
+	// Format is "json" (the default, the event as is) or "slack" (an
+	// incoming-webhook message).
+	Format string `json:"format,omitempty"`
+	// Events limits the notifications to run_started,
+	// permission_requested, run_completed and run_failed; empty means all.
+	Events []string `json:"events,omitempty"`
+}
+
+var webhookEvents = []string{"run_started", "permission_requested", "run_completed", "run_failed"}
+
+func (w Webhook) Validate() error {
+	hasURL, hasEnv := strings.TrimSpace(w.URL) != "", strings.TrimSpace(w.URLEnv) != ""
+	if hasURL == hasEnv {
+		return fmt.Errorf("webhook: set exactly one of url and url_env")
+	}
+	if hasURL {
+		u, err := url.Parse(strings.TrimSpace(w.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url %q must be an http(s) URL", w.URL)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(w.Format)) {
+	case "", "json", "slack":
+	default:
+		return fmt.Errorf("unknown webhook format %q (want json or slack)", w.Format)
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return fmt.Errorf("unknown webhook event %q (want %s)", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// Server configures cmd/agent-server for a team. Without users the API is
+// open to anyone who can reach it, which only suits a loopback address.
+type Server struct {
+	Users []ServerUser `json:"users,omitempty"`
+}
+
+// ServerUser is one API client of the server. The API token stays in the
+// environment.
+type ServerUser struct {
+	Name string `json:"name"`
+	// TokenEnv names the environment variable holding the user's token.
+	TokenEnv string `json:"token_env"`
+	// MessagesPerMinute limits how fast the user can send messages.
+	MessagesPerMinute int `json:"messages_per_minute,omitempty"`
+	// MaxTokensPerDay caps the tokens of all the user's runs per UTC day.
+	MaxTokensPerDay int64 `json:"max_tokens_per_day,omitempty"`
+	// Budget caps each run of the user.
+	Budget Budget `json:"budget"`
+}
+
+func (s Server) Validate() error {
+	seen := make(map[string]bool)
+	for _, u := range s.Users {
+		name := strings.TrimSpace(u.Name)
+		if name == "" {
+			return fmt.Errorf("server user name is required")
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate server user %q", name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(u.TokenEnv) == "" {
+			return fmt.Errorf("server user %q: token_env is required", name)
+		}
+		if u.MessagesPerMinute < 0 || u.MaxTokensPerDay < 0 {
+			return fmt.Errorf("server user %q: limits must not be negative", name)
+		}
+		if err := u.Budget.Validate(); err != nil {
+			return fmt.Errorf("server user %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Budget caps a single task. Zero values disable the corresponding limit.
+// Costs are estimated from token counts and the per-1K-token prices: Prices
+// by model, InputCostPer1K and OutputCostPer1K for models not listed there.
+type Budget struct {
+	MaxTokens int64   `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"`
+	// MaxDuration is a Go duration string such as "15m".
+	MaxDuration     string  `json:"max_duration,omitempty"`
+	InputCostPer1K  float64 `json:"input_cost_per_1k,omitempty"`
+	OutputCostPer1K float64 `json:"output_cost_per_1k,omitempty"`
+	// Prices maps model names to their prices. A name also covers the
+	// models it is a prefix of, e.g. "gpt-4o" covers "gpt-4o-2024-08-06";
+	// the longest match wins.
+	Prices map[string]Price `json:"prices,omitempty"`
+}
+
+// Price is what a model costs per 1K tokens, in any currency as long as it
+// is the same everywhere.
+type Price struct {
+	InputPer1K  float64 `json:"input_per_1k"`
+	OutputPer1K float64 `json:"output_per_1k"`
+	// CachedInputPer1K is the price of prompt tokens served from the
+	// provider's prompt cache; zero means they cost InputPer1K.
+	CachedInputPer1K float64 `json:"cached_input_per_1k,omitempty"`
+}
+
+func (b Budget) Validate() error {
+	if b.MaxTokens < 0 || b.MaxCost < 0 || b.InputCostPer1K < 0 || b.OutputCostPer1K < 0 {
+		return fmt.Errorf("budget values must not be negative")
+	}
+	for model, price := range b.Prices {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("budget prices: model name is required")
+		}
+		if price.InputPer1K < 0 || price.OutputPer1K < 0 || price.CachedInputPer1K < 0 {
+			return fmt.Errorf("budget prices: %s: prices must not be negative", model)
+		}
+	}
+	if _, err := b.Duration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Duration parses MaxDuration; an empty string means no limit.
+func (b Budget) Duration() (time.Duration, error) {
+	if strings.TrimSpace(b.MaxDuration) == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(b.MaxDuration))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid budget max_duration %q", b.MaxDuration)
+	}
+	return d, nil
+}
+
+// Path returns the config file location for a project root.
+func Path(root string) string {
+	if p := strings.TrimSpace(os.Getenv("AGENT_CONFIG")); p != "" {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(root, p)
+	}
+	return filepath.Join(root, DefaultRelativePath)
+}
+
+// Load reads the project config under root and merges the user settings
+// and then the local settings into it. A missing file yields an empty
+// Config. Keys of the project config that would loosen permissions are
+// dropped and listed in Ignored.
+func Load(root string) (Config, error) {
+	var cfg Config
+	path := Path(root)
+	if err := readJSON(path, &cfg); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	cfg.Ignored = cfg.dropPermissive()
+	for name, server := range cfg.MCP.Servers {
+		server.Project = true
+		cfg.MCP.Servers[name] = server
+	}
+
+	user, err := LoadUserSettings()
+	if err != nil {
+		return Config{}, err
+	}
+	settings, err := LoadSettings(root)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DangerouslySkipPermissions = user.DangerouslySkipPermissions || settings.DangerouslySkipPermissions
+	for _, s := range []Settings{user, settings} {
+		for _, rule := range s.Permissions.Allow {
+			cfg.Permissions.Allow = addRule(cfg.Permissions.Allow, rule)
+		}
+		for name, p := range s.Profiles {
+			if cfg.Profiles == nil {
+				cfg.Profiles = map[string]Profile{}
+			}
+			cfg.Profiles[name] = p
+		}
+		for name, server := range s.MCP.Servers {
+			if cfg.MCP.Servers == nil {
+				cfg.MCP.Servers = map[string]MCPServer{}
+			}
+			cfg.MCP.Servers[name] = server
+		}
+		if s.MCP.Conflicts != "" {
+			cfg.MCP.Conflicts = s.MCP.Conflicts
+		}
+		if len(s.Provider.Fallbacks) > 0 {
+			cfg.Provider.Fallbacks = s.Provider.Fallbacks
+		}
+		if s.Provider.Azure.Endpoint != "" {
+			cfg.Provider.Azure.Endpoint = s.Provider.Azure.Endpoint
+		}
+		if s.Provider.Gemini.Endpoint != "" {
+			cfg.Provider.Gemini.Endpoint = s.Provider.Gemini.Endpoint
+		}
+		s.GitHub.apply(&cfg.GitHub.APIURL, &cfg.GitHub.TokenEnv)
+		s.Forge.apply(&cfg.Forge.APIURL, &cfg.Forge.TokenEnv)
+	}
+	if strings.EqualFold(strings.TrimSpace(cfg.Forge.Type), "gitea") && strings.TrimSpace(cfg.Forge.APIURL) == "" {
+		return Config{}, fmt.Errorf("forge type gitea needs forge.api_url, e.g. https://gitea.example.com/api/v1, in %s or %s", "~/"+UserSettingsRelativePath, LocalSettingsRelativePath)
+	}
+	cfg.Telemetry = settings.Telemetry
+	return cfg, nil
+}
+
+// dropPermissive clears the settings a cloned repository must not be able
+// to turn on and returns their keys.
+func (c *Config) dropPermissive() []string {
+	var dropped []string
+	if c.DangerouslySkipPermissions {
+		c.DangerouslySkipPermissions = false
+		dropped = append(dropped, "dangerously_skip_permissions")
+	}
+	if len(c.Permissions.Allow) > 0 {
+		c.Permissions.Allow = nil
+		dropped = append(dropped, "permissions.allow")
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		p := c.Profiles[name]
+		if p.Permission == ProfileSkip {
+			p.Permission = ProfileAsk
+			dropped = append(dropped, "profiles."+name+".permission")
+		}
+		if len(p.Allow) > 0 {
+			p.Allow = nil
+			dropped = append(dropped, "profiles."+name+".allow")
+		}
+		c.Profiles[name] = p
+	}
+	var fallbacks []FallbackModel
+	for i, fb := range c.Provider.Fallbacks {
+		if fb.BaseURL != "" || fb.APIKeyEnv != "" {
+			dropped = append(dropped, fmt.Sprintf("provider.fallbacks.%d", i))
+			continue
+		}
+		fallbacks = append(fallbacks, fb)
+	}
+	c.Provider.Fallbacks = fallbacks
+	if c.Provider.Azure.Endpoint != "" {
+		c.Provider.Azure.Endpoint = ""
+		dropped = append(dropped, "provider.azure.endpoint")
+	}
+	if c.Provider.Gemini.Endpoint != "" {
+		c.Provider.Gemini.Endpoint = ""
+		dropped = append(dropped, "provider.gemini.endpoint")
+	}
+	if c.GitHub.APIURL != "" {
+		c.GitHub.APIURL = ""
+		dropped = append(dropped, "github.api_url")
+	}
+	if c.GitHub.TokenEnv != "" {
+		c.GitHub.TokenEnv = ""
+		dropped = append(dropped, "github.token_env")
+	}
+	if c.Forge.APIURL != "" {
+		c.Forge.APIURL = ""
+		dropped = append(dropped, "forge.api_url")
+	}
+	if c.Forge.TokenEnv != "" {
+		c.Forge.TokenEnv = ""
+		dropped = append(dropped, "forge.token_env")
+	}
+	return dropped
+}
+
+// LoadSettings reads the local settings file under root. A missing file
+// yields empty Settings.
+func LoadSettings(root string) (Settings, error) {
+	return loadSettings(filepath.Join(root, LocalSettingsRelativePath))
+}
+
+// LoadUserSettings reads the user settings file in the home directory.
+// A missing file, or home directory, yields empty Settings.
+func LoadUserSettings() (Settings, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Settings{}, nil
+	}
+	return loadSettings(filepath.Join(home, UserSettingsRelativePath))
+}
+
+func loadSettings(path string) (Settings, error) {
+	var settings Settings
+	if err := readJSON(path, &settings); err != nil {
+		return Settings{}, err
+	}
+	if err := settings.Permissions.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+	if err := settings.Telemetry.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+	if err := validateProfiles(settings.Profiles); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+	if err := settings.MCP.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+	if err := (Provider{Fallbacks: settings.Provider.Fallbacks}).Validate(); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// AddAllowRule records rule in the local settings file under root, so it
+// applies to later sessions too.
+func AddAllowRule(root string, rule permission.Rule) error {
+	settings, err := LoadSettings(root)
+	if err != nil {
+		return err
+	}
+	settings.Permissions.Allow = addRule(settings.Permissions.Allow, rule)
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, LocalSettingsRelativePath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write settings %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write settings %s: %w", path, err)
+	}
+	return nil
+}
+
+func addRule(rules []permission.Rule, rule permission.Rule) []permission.Rule {
+	for _, r := range rules {
+		if r == rule {
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// readJSON decodes the file at path into v, leaving v alone when the file
+// does not exist.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) Validate() error {
+	for name, db := range c.Databases {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("database name is required")
+		}
+		if err := db.Validate(); err != nil {
+			return fmt.Errorf("database %q: %w", name, err)
+		}
+	}
+	for tool, names := range c.OutputProcessors {
+		for _, name := range names {
+			if !slices.Contains(outputProcessorNames, name) {
+				return fmt.Errorf("output_processors %q: unknown processor %q, want %s", tool, name, strings.Join(outputProcessorNames, ", "))
+			}
+		}
+	}
+	if err := c.Provider.Validate(); err != nil {
+		return err
+	}
+	if err := c.GitHub.Validate(); err != nil {
+		return err
+	}
+	if err := c.Forge.Validate(); err != nil {
+		return err
+	}
+	if err := c.Hooks.PreCommit.Validate(); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, s := range c.Schedules {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if names[strings.TrimSpace(s.Name)] {
+			return fmt.Errorf("schedule %q is listed twice", s.Name)
+		}
+		names[strings.TrimSpace(s.Name)] = true
+	}
+	for _, w := range c.Webhooks {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Server.Validate(); err != nil {
+		return err
+	}
+	if err := c.Limits.Validate(); err != nil {
+		return err
+	}
+	if err := c.Workspace.Validate(); err != nil {
+		return err
+	}
+	if err := c.HTTPRequest.Validate(); err != nil {
+		return err
+	}
+	if err := c.Permissions.Validate(); err != nil {
+		return err
+	}
+	if err := c.MCP.Validate(); err != nil {
+		return err
+	}
+	if err := validateProfiles(c.Profiles); err != nil {
+		return err
+	}
+	if c.Language != "" && !i18n.Supported(i18n.Lang(c.Language)) {
+		return fmt.Errorf("language %q: want %q or %q", c.Language, i18n.English, i18n.Chinese)
+	}
+	return c.Budget.Validate()
+}
@@ -0,0 +1,348 @@
+// Package convdiff compares two conversations, typically the same task run
+// on two models or with two prompts, for A/B evaluation: where they diverge,
+// how their tool calls differ and what each cost.
+//
+// Saved sessions do not keep the usage the provider reported, so token
+// counts are estimated from the transcript (see tokens.CountMessages): each
+// assistant message is one model call whose prompt is every message before
+// it.
+package convdiff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+)
+
+const previewRunes = 120
+
+// Transcript is one side of a comparison.
+type Transcript struct {
+	// Label names the transcript in the output, e.g. its session ID.
+	Label string
+	// Model prices the transcript; empty uses the default price.
+	Model    string
+	Messages []openai.ChatCompletionMessageParamUnion
+}
+
+// Side summarizes one transcript.
+type Side struct {
+	Label      string `json:"label"`
+	Model      string `json:"model,omitempty"`
+	Messages   int    `json:"messages"`
+	ModelCalls int    `json:"model_calls"`
+	ToolCalls  int    `json:"tool_calls"`
+	// PromptTokens and CompletionTokens are estimates.
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	// Priced is false when no price is known for Model.
+	Priced bool `json:"priced"`
+	// Final is the last assistant reply.
+	Final string `json:"final,omitempty"`
+}
+
+// Divergence is the first message where the transcripts differ.
+type Divergence struct {
+	// Message is the index of the message in both transcripts.
+	Message int `json:"message"`
+	// A and B preview the differing messages; one is empty when its
+	// transcript ended there.
+	A     string `json:"a"`
+	B     string `json:"b"`
+	RoleA string `json:"role_a,omitempty"`
+	RoleB string `json:"role_b,omitempty"`
+}
+
+// Call is a tool call with its arguments.
+type Call struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func (c Call) String() string { return c.Name + " " + c.Arguments }
+
+// Change is one entry of the aligned tool call sequences.
+type Change struct {
+	// Op is "same", "removed" (only in A) or "added" (only in B).
+	Op   string `json:"op"`
+	Call Call   `json:"call"`
+}
+
+// Change operations.
+const (
+	OpSame    = "same"
+	OpRemoved = "removed"
+	OpAdded   = "added"
+)
+
+// ToolCount is how often each transcript called a tool.
+type ToolCount struct {
+	Tool string `json:"tool"`
+	A    int    `json:"a"`
+	B    int    `json:"b"`
+}
+
+// Delta is B minus A.
+type Delta struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	ModelCalls       int     `json:"model_calls"`
+	ToolCalls        int     `json:"tool_calls"`
+}
+
+// Diff is the structured comparison of transcripts A and B.
+type Diff struct {
+	A Side `json:"a"`
+	B Side `json:"b"`
+	// Divergence is nil when the transcripts are identical.
+	Divergence *Divergence `json:"divergence,omitempty"`
+	// ToolCalls aligns the tool calls of both transcripts, in order.
+	ToolCalls  []Change    `json:"tool_calls"`
+	ToolCounts []ToolCount `json:"tool_counts"`
+	Delta      Delta       `json:"delta"`
+}
+
+// Compare diffs a against b, estimating tokens with counter and costs with
+// pricing.
+func Compare(a, b Transcript, counter tokens.Counter, pricing budget.Pricing) Diff {
+	d := Diff{
+		A: summarize(a, counter, pricing),
+		B: summarize(b, counter, pricing),
+	}
+	d.Divergence = divergence(a.Messages, b.Messages)
+
+	callsA, callsB := calls(a.Messages), calls(b.Messages)
+	d.ToolCalls = align(callsA, callsB)
+	d.ToolCounts = toolCounts(callsA, callsB)
+	d.Delta = Delta{
+		PromptTokens:     d.B.PromptTokens - d.A.PromptTokens,
+		CompletionTokens: d.B.CompletionTokens - d.A.CompletionTokens,
+		Cost:             d.B.Cost - d.A.Cost,
+		ModelCalls:       d.B.ModelCalls - d.A.ModelCalls,
+		ToolCalls:        d.B.ToolCalls - d.A.ToolCalls,
+	}
+	return d
+}
+
+func summarize(t Transcript, counter tokens.Counter, pricing budget.Pricing) Side {
+	side := Side{Label: t.Label, Model: t.Model, Messages: len(t.Messages)}
+	for i, msg := range t.Messages {
+		if msg.OfAssistant == nil {
+			continue
+		}
+		side.ModelCalls++
+		side.ToolCalls += len(msg.OfAssistant.ToolCalls)
+		side.PromptTokens += int64(tokens.CountMessages(counter, t.Messages[:i]))
+		side.CompletionTokens += int64(tokens.CountMessages(counter, t.Messages[i:i+1]))
+		if text := msg.OfAssistant.Content.OfString.Value; text != "" {
+			side.Final = text
+		}
+	}
+	price, ok := pricing.For(t.Model)
+	side.Priced = ok
+	side.Cost = float64(side.PromptTokens)/1000*price.InputPer1K + float64(side.CompletionTokens)/1000*price.OutputPer1K
+	return side
+}
+
+func divergence(a, b []openai.ChatCompletionMessageParamUnion) *Divergence {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		if i < len(a) && i < len(b) && messageKey(a[i]) == messageKey(b[i]) {
+			continue
+		}
+		d := &Divergence{Message: i}
+		if i < len(a) {
+			d.A, d.RoleA = session.MessagePreview(a[i], previewRunes), session.MessageRole(a[i])
+		}
+		if i < len(b) {
+			d.B, d.RoleB = session.MessagePreview(b[i], previewRunes), session.MessageRole(b[i])
+		}
+		return d
+	}
+	return nil
+}
+
+// messageKey identifies a message by its role, text and tool calls. Tool
+// call IDs differ between runs and are left out.
+func messageKey(msg openai.ChatCompletionMessageParamUnion) string {
+	var b strings.Builder
+	b.WriteString(session.MessageRole(msg))
+	switch {
+	case msg.OfSystem != nil:
+		b.WriteString(msg.OfSystem.Content.OfString.Value)
+	case msg.OfUser != nil:
+		b.WriteString(msg.OfUser.Content.OfString.Value)
+		for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				b.WriteString(part.OfText.Text)
+			}
+		}
+	case msg.OfAssistant != nil:
+		b.WriteString(msg.OfAssistant.Content.OfString.Value)
+		for _, tc := range msg.OfAssistant.ToolCalls {
+			b.WriteString("\x00" + callOf(tc).String())
+		}
+	case msg.OfTool != nil:
+		b.WriteString(msg.OfTool.Content.OfString.Value)
+	}
+	return b.String()
+}
+
+func calls(messages []openai.ChatCompletionMessageParamUnion) []Call {
+	var out []Call
+	for _, msg := range messages {
+		if msg.OfAssistant == nil {
+			continue
+		}
+		for _, tc := range msg.OfAssistant.ToolCalls {
+			out = append(out, callOf(tc))
+		}
+	}
+	return out
+}
+
+func callOf(tc openai.ChatCompletionMessageToolCallParam) Call {
+	return Call{Name: tc.Function.Name, Arguments: strings.TrimSpace(tc.Function.Arguments)}
+}
+
+// align returns the longest common subsequence of a and b as "same"
+// entries, with the calls only one side made in between.
+func align(a, b []Call) []Change {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			changes = append(changes, Change{Op: OpSame, Call: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changes = append(changes, Change{Op: OpRemoved, Call: a[i]})
+			i++
+		default:
+			changes = append(changes, Change{Op: OpAdded, Call: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		changes = append(changes, Change{Op: OpRemoved, Call: a[i]})
+	}
+	for ; j < len(b); j++ {
+		changes = append(changes, Change{Op: OpAdded, Call: b[j]})
+	}
+	return changes
+}
+
+func toolCounts(a, b []Call) []ToolCount {
+	counts := map[string]*ToolCount{}
+	count := func(name string) *ToolCount {
+		if counts[name] == nil {
+			counts[name] = &ToolCount{Tool: name}
+		}
+		return counts[name]
+	}
+	for _, c := range a {
+		count(c.Name).A++
+	}
+	for _, c := range b {
+		count(c.Name).B++
+	}
+	out := make([]ToolCount, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, *c)
+	}
+	slices.SortFunc(out, func(x, y ToolCount) int { return strings.Compare(x.Tool, y.Tool) })
+	return out
+}
+
+// String renders the diff for a terminal.
+func (d Diff) String() string {
+	var b strings.Builder
+	for _, side := range []struct {
+		name string
+		Side
+	}{{"A", d.A}, {"B", d.B}} {
+		fmt.Fprintf(&b, "%s: %s", side.name, side.Label)
+		if side.Model != "" {
+			fmt.Fprintf(&b, " (%s)", side.Model)
+		}
+		fmt.Fprintf(&b, " — %d messages, %d model calls, %d tool calls, ~%d tokens (prompt %d, completion %d), cost≈%s\n",
+			side.Messages, side.ModelCalls, side.ToolCalls, side.PromptTokens+side.CompletionTokens,
+			side.PromptTokens, side.CompletionTokens, costString(side.Cost, side.Priced))
+	}
+
+	if d.Divergence == nil {
+		b.WriteString("\nthe conversations are identical\n")
+	} else {
+		fmt.Fprintf(&b, "\ndiverged at message %d:\n", d.Divergence.Message)
+		fmt.Fprintf(&b, "  A %s\n  B %s\n", previewLine(d.Divergence.RoleA, d.Divergence.A), previewLine(d.Divergence.RoleB, d.Divergence.B))
+	}
+
+	if len(d.ToolCalls) > 0 {
+		b.WriteString("\ntool calls:\n")
+		for _, c := range d.ToolCalls {
+			mark := " "
+			switch c.Op {
+			case OpRemoved:
+				mark = "-"
+			case OpAdded:
+				mark = "+"
+			}
+			fmt.Fprintf(&b, "  %s %s\n", mark, preview(c.Call.String()))
+		}
+		b.WriteString("\ntool counts (A → B):\n")
+		for _, c := range d.ToolCounts {
+			fmt.Fprintf(&b, "  %-20s %d → %d\n", c.Tool, c.A, c.B)
+		}
+	}
+
+	fmt.Fprintf(&b, "\ndelta (B − A): tokens %+d (prompt %+d, completion %+d), model calls %+d, tool calls %+d",
+		d.Delta.PromptTokens+d.Delta.CompletionTokens, d.Delta.PromptTokens, d.Delta.CompletionTokens, d.Delta.ModelCalls, d.Delta.ToolCalls)
+	if d.A.Priced && d.B.Priced {
+		fmt.Fprintf(&b, ", cost %+.4f", d.Delta.Cost)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func costString(cost float64, priced bool) string {
+	if !priced {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.4f", cost)
+}
+
+func previewLine(role, text string) string {
+	if role == "" {
+		return "(no message: the conversation ended)"
+	}
+	return role + ": " + text
+}
+
+func preview(text string) string {
+	if runes := []rune(text); len(runes) > previewRunes {
+		return string(runes[:previewRunes-1]) + "…"
+	}
+	return text
+}
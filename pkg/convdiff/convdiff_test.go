@@ -0,0 +1,78 @@
+package convdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+)
+
+func toolCall(id, name, args string) openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+			ID:       id,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: name, Arguments: args},
+		}},
+	}}
+}
+
+func TestCompare_FindsDivergenceToolCallsAndDeltas(t *testing.T) {
+	a := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("fix the test"),
+		toolCall("a1", "read_file", `{"path":"x.go"}`),
+		openai.ToolMessage("package x", "a1"),
+		toolCall("a2", "bash", `{"command":"go test"}`),
+		openai.ToolMessage("ok", "a2"),
+		openai.AssistantMessage("Fixed."),
+	}
+	// Same first call under another ID, then a different route.
+	b := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("fix the test"),
+		toolCall("b1", "read_file", `{"path":"x.go"}`),
+		openai.ToolMessage("package x", "b1"),
+		toolCall("b2", "grep", `{"pattern":"Test"}`),
+		openai.ToolMessage("x_test.go:3", "b2"),
+		toolCall("b3", "bash", `{"command":"go test"}`),
+		openai.ToolMessage("ok", "b3"),
+		openai.AssistantMessage("Fixed it."),
+	}
+	pricing := budget.Pricing{Models: map[string]config.Price{"big": {InputPer1K: 1, OutputPer1K: 2}, "small": {InputPer1K: 0.1, OutputPer1K: 0.2}}}
+
+	d := Compare(Transcript{Label: "a", Model: "big", Messages: a}, Transcript{Label: "b", Model: "small", Messages: b}, tokens.Heuristic{}, pricing)
+
+	if d.Divergence == nil || d.Divergence.Message != 4 || d.Divergence.A != "[tool calls: bash]" || d.Divergence.B != "[tool calls: grep]" {
+		t.Fatalf("divergence = %+v", d.Divergence)
+	}
+	var ops []string
+	for _, c := range d.ToolCalls {
+		ops = append(ops, c.Op+":"+c.Call.Name)
+	}
+	if got := strings.Join(ops, ","); got != "same:read_file,added:grep,same:bash" {
+		t.Fatalf("tool calls = %s", got)
+	}
+	if len(d.ToolCounts) != 3 || d.ToolCounts[1] != (ToolCount{Tool: "grep", A: 0, B: 1}) {
+		t.Fatalf("tool counts = %+v", d.ToolCounts)
+	}
+	if d.A.ModelCalls != 3 || d.B.ModelCalls != 4 || d.Delta.ModelCalls != 1 || d.Delta.ToolCalls != 1 || d.B.Final != "Fixed it." {
+		t.Fatalf("sides = %+v / %+v, delta %+v", d.A, d.B, d.Delta)
+	}
+	if d.Delta.PromptTokens <= 0 || !d.A.Priced || d.Delta.Cost >= 0 {
+		t.Fatalf("B should read more tokens on the cheaper model: %+v", d.Delta)
+	}
+
+	out := d.String()
+	for _, want := range []string{"diverged at message 4:", "  + grep {\"pattern\":\"Test\"}", "grep                 0 → 1", "model calls +1"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if same := Compare(Transcript{Messages: a}, Transcript{Messages: a}, tokens.Heuristic{}, budget.Pricing{}); same.Divergence != nil || same.A.Priced {
+		t.Fatalf("identical transcripts: %+v", same)
+	}
+}
@@ -0,0 +1,134 @@
+// Package credentials keeps API keys in the OS keychain (macOS Keychain,
+// the Secret Service on Linux, Windows Credential Manager) so they need not
+// sit in a plaintext .env file.
+//
+// Keys are stored under Service with the environment variable name as the
+// account. LoadEnv copies them into the environment at startup, so code that
+// reads DASHSCOPE_API_KEY and friends works unchanged.
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Service is the keychain service name the keys are stored under.
+const Service = "learn-claude-code"
+
+// Keys are the environment variables LoadEnv looks up by default.
+var Keys = []string{
+	"DASHSCOPE_API_KEY",
+	"DASHSCOPE_API_KEYS",
+	"OPENAI_API_KEY",
+	"AZURE_OPENAI_API_KEY",
+	"GEMINI_API_KEY",
+	"DEEPSEEK_API_KEY",
+	"OPENROUTER_API_KEY",
+	"GITHUB_TOKEN",
+	"GITLAB_TOKEN",
+	"GITEA_TOKEN",
+	// AGENT_STORAGE_KEY encrypts sessions and audit logs, see pkg/atrest.
+	"AGENT_STORAGE_KEY",
+}
+
+var (
+	// ErrNotFound is returned by Get and Delete for a key that is not stored.
+	ErrNotFound = errors.New("credential not found")
+	// ErrUnsupported means there is no keychain to talk to, e.g. Linux
+	// without secret-tool.
+	ErrUnsupported = errors.New("no OS keychain available")
+)
+
+// commandTimeout bounds one keychain call; a locked keychain may prompt.
+const commandTimeout = 30 * time.Second
+
+// Store keeps named secrets.
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, secret string) error
+	Delete(name string) error
+}
+
+// LoadEnv sets every variable in names that is unset or empty from store
+// and returns the names it set. The environment and .env take precedence,
+// so CI keeps working without a keychain. A missing keychain is not an
+// error; the first other failure stops the lookup.
+func LoadEnv(store Store, names ...string) ([]string, error) {
+	var loaded []string
+	for _, name := range names {
+		if strings.TrimSpace(os.Getenv(name)) != "" {
+			continue
+		}
+		secret, err := store.Get(name)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			continue
+		case errors.Is(err, ErrUnsupported):
+			return loaded, nil
+		case err != nil:
+			return loaded, fmt.Errorf("read %s from the keychain: %w", name, err)
+		}
+		if err := os.Setenv(name, secret); err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, name)
+	}
+	return loaded, nil
+}
+
+// Disabled reports whether AGENT_KEYCHAIN turns the keychain off ("0",
+// "false" or "off"), e.g. on servers without a desktop session.
+func Disabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_KEYCHAIN"))) {
+	case "0", "false", "off":
+		return true
+	}
+	return false
+}
+
+// runFunc runs a keychain tool with stdin and returns its stdout.
+type runFunc func(ctx context.Context, stdin, name string, args ...string) (string, error)
+
+// exitError is a keychain tool that ran and failed.
+type exitError struct {
+	code   int
+	stderr string
+}
+
+func (e *exitError) Error() string {
+	if e.stderr != "" {
+		return fmt.Sprintf("exit status %d: %s", e.code, e.stderr)
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func runCommand(ctx context.Context, stdin, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return "", &exitError{code: exit.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", ErrUnsupported
+	}
+	return stdout.String(), err
+}
+
+// unsupported is the Store of a platform without a keychain.
+type unsupported struct{}
+
+func (unsupported) Get(string) (string, error) { return "", ErrUnsupported }
+func (unsupported) Set(string, string) error   { return ErrUnsupported }
+func (unsupported) Delete(string) error        { return ErrUnsupported }
@@ -0,0 +1,61 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type mapStore map[string]string
+
+func (m mapStore) Get(name string) (string, error) {
+	secret, ok := m[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m mapStore) Set(name, secret string) error { m[name] = secret; return nil }
+func (m mapStore) Delete(name string) error      { delete(m, name); return nil }
+
+func TestLoadEnv_FillsOnlyUnsetVariables(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "")
+	t.Setenv("GITHUB_TOKEN", "from-env")
+	t.Setenv("OPENAI_API_KEY", "")
+	os.Unsetenv("OPENAI_API_KEY")
+
+	store := mapStore{"DASHSCOPE_API_KEY": "sk-keychain", "GITHUB_TOKEN": "gh-keychain"}
+	loaded, err := LoadEnv(store, "DASHSCOPE_API_KEY", "GITHUB_TOKEN", "OPENAI_API_KEY")
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, []string{"DASHSCOPE_API_KEY"}) {
+		t.Fatalf("loaded = %v", loaded)
+	}
+	if os.Getenv("DASHSCOPE_API_KEY") != "sk-keychain" || os.Getenv("GITHUB_TOKEN") != "from-env" {
+		t.Fatalf("env: DASHSCOPE_API_KEY=%q GITHUB_TOKEN=%q", os.Getenv("DASHSCOPE_API_KEY"), os.Getenv("GITHUB_TOKEN"))
+	}
+	if _, ok := os.LookupEnv("OPENAI_API_KEY"); ok {
+		t.Fatal("OPENAI_API_KEY should stay unset")
+	}
+}
+
+func TestLoadEnv_UnsupportedKeychainIsNotAnError(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "")
+	if loaded, err := LoadEnv(unsupported{}, Keys...); err != nil || len(loaded) != 0 {
+		t.Fatalf("LoadEnv = %v, %v", loaded, err)
+	}
+}
+
+type failingStore struct{ mapStore }
+
+func (failingStore) Get(string) (string, error) { return "", errors.New("keychain locked") }
+
+func TestLoadEnv_ReportsKeychainErrors(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "")
+	if _, err := LoadEnv(failingStore{}, "DASHSCOPE_API_KEY"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) for a missing item.
+const errSecItemNotFound = 44
+
+// Keychain returns the login keychain through security(1).
+func Keychain() Store {
+	return securityTool{run: runCommand}
+}
+
+type securityTool struct {
+	run runFunc
+}
+
+func (s securityTool) Get(name string) (string, error) {
+	out, err := s.run(context.Background(), "", "security", "find-generic-password", "-s", Service, "-a", name, "-w")
+	if err != nil {
+		return "", notFound(err)
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+// Set updates an existing item in place (-U). security(1) only takes the
+// password as an argument, so it is briefly visible in the process list.
+func (s securityTool) Set(name, secret string) error {
+	_, err := s.run(context.Background(), "", "security", "add-generic-password", "-U", "-s", Service, "-a", name, "-l", Service+" "+name, "-w", secret)
+	return err
+}
+
+func (s securityTool) Delete(name string) error {
+	_, err := s.run(context.Background(), "", "security", "delete-generic-password", "-s", Service, "-a", name)
+	return notFound(err)
+}
+
+func notFound(err error) error {
+	var exit *exitError
+	if errors.As(err, &exit) && exit.code == errSecItemNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Keychain returns the Secret Service (GNOME Keyring, KWallet) through
+// secret-tool, from libsecret-tools.
+func Keychain() Store {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return unsupported{}
+	}
+	return secretTool{run: runCommand}
+}
+
+type secretTool struct {
+	run runFunc
+}
+
+func attributes(name string) []string {
+	return []string{"service", Service, "account", name}
+}
+
+func (s secretTool) Get(name string) (string, error) {
+	out, err := s.run(context.Background(), "", "secret-tool", append([]string{"lookup"}, attributes(name)...)...)
+	// lookup exits 1 without output when nothing matches.
+	var exit *exitError
+	if errors.As(err, &exit) && exit.code == 1 && exit.stderr == "" {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+// Set passes the secret on stdin so it never shows up in the process list.
+func (s secretTool) Set(name, secret string) error {
+	args := append([]string{"store", "--label", Service + " " + name}, attributes(name)...)
+	_, err := s.run(context.Background(), secret, "secret-tool", args...)
+	return err
+}
+
+func (s secretTool) Delete(name string) error {
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	_, err := s.run(context.Background(), "", "secret-tool", append([]string{"clear"}, attributes(name)...)...)
+	return err
+}
@@ -0,0 +1,55 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSecretTool_RoundTrip(t *testing.T) {
+	stored := map[string]string{}
+	var calls []string
+	tool := secretTool{run: func(_ context.Context, stdin, name string, args ...string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		key := args[len(args)-1]
+		switch args[0] {
+		case "store":
+			stored[key] = stdin
+		case "lookup":
+			secret, ok := stored[key]
+			if !ok {
+				return "", &exitError{code: 1}
+			}
+			return secret + "\n", nil
+		case "clear":
+			delete(stored, key)
+		}
+		return "", nil
+	}}
+
+	if _, err := tool.Get("DASHSCOPE_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Set = %v, want ErrNotFound", err)
+	}
+	if err := tool.Set("DASHSCOPE_API_KEY", "sk-secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if secret, err := tool.Get("DASHSCOPE_API_KEY"); err != nil || secret != "sk-secret" {
+		t.Fatalf("Get = %q, %v", secret, err)
+	}
+	if err := tool.Delete("DASHSCOPE_API_KEY"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := tool.Delete("DASHSCOPE_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+
+	for _, call := range calls {
+		if strings.Contains(call, "sk-secret") {
+			t.Fatalf("secret passed as an argument: %q", call)
+		}
+	}
+	if calls[1] != "secret-tool store --label learn-claude-code DASHSCOPE_API_KEY service learn-claude-code account DASHSCOPE_API_KEY" {
+		t.Fatalf("store call = %q", calls[1])
+	}
+}
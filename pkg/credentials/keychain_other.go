@@ -0,0 +1,8 @@
+//go:build !darwin && !linux && !windows
+
+package credentials
+
+// Keychain reports ErrUnsupported on platforms without a known keychain.
+func Keychain() Store {
+	return unsupported{}
+}
@@ -0,0 +1,54 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Wednesday.
+	from := time.Date(2026, 10, 14, 10, 30, 15, 0, time.UTC)
+	cases := map[string]time.Time{
+		"* * * * *":              time.Date(2026, 10, 14, 10, 31, 0, 0, time.UTC),
+		"0 9 * * mon":            time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		"*/15 8-18 * * 1-5":      time.Date(2026, 10, 14, 10, 45, 0, 0, time.UTC),
+		"0 3 1,15 * *":           time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC),
+		"@monthly":               time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		"@weekly":                time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		"0 0 1 jan *":            time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 * * 7":             time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		"30 10 14 10 *":          time.Date(2027, 10, 14, 10, 30, 0, 0, time.UTC),
+		"0 0 29 2 *":             time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"5/20 * * * *":           time.Date(2026, 10, 14, 10, 45, 0, 0, time.UTC),
+		"0 0 13 * fri":           time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), // either day field
+		"0 0 30 2 *":             {},
+		"0 6 * NOV-DEC SAT,SUN ": time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC),
+	}
+	for expr, want := range cases {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q.Next = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestParse_RejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "0 0 * dec-feb *", "@sometimes", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNext_KeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	s, _ := Parse("@daily")
+	got := s.Next(time.Date(2026, 10, 14, 23, 0, 0, 0, loc))
+	if !got.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, loc)) || got.Location() != loc {
+		t.Fatalf("Next = %v", got)
+	}
+}
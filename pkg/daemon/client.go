@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Listen opens the daemon's Unix socket at path. A socket left behind by a
+// daemon that exited is replaced; one that still answers is an error, so a
+// workspace never has two daemons.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Anyone who can connect can run commands as this user.
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Client talks to a daemon.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// Dial returns a client for the daemon listening on the Unix socket at path.
+func Dial(path string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+	return &Client{baseURL: "http://daemon", http: &http.Client{Transport: transport}}
+}
+
+// NewHTTPClient returns a client for a daemon serving TCP at baseURL, which
+// requires token (see RequireToken).
+func NewHTTPClient(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), token: token, http: http.DefaultClient}
+}
+
+// Submit queues a task.
+func (c *Client) Submit(ctx context.Context, s Submission) (Task, error) {
+	var task Task
+	err := c.do(ctx, http.MethodPost, "/tasks", s, &task)
+	return task, err
+}
+
+// Task fetches the task with id.
+func (c *Client) Task(ctx context.Context, id string) (Task, error) {
+	var task Task
+	err := c.do(ctx, http.MethodGet, "/tasks/"+id, nil, &task)
+	return task, err
+}
+
+// Tasks lists the daemon's tasks, oldest first.
+func (c *Client) Tasks(ctx context.Context) ([]Task, error) {
+	var tasks []Task
+	err := c.do(ctx, http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+// Cancel drops a queued task or interrupts a running one.
+func (c *Client) Cancel(ctx context.Context, id string) (Task, error) {
+	var task Task
+	err := c.do(ctx, http.MethodPost, "/tasks/"+id+"/cancel", nil, &task)
+	return task, err
+}
+
+// Tail calls fn with the events of task id, from the first one, until the
+// task finishes or ctx is done.
+func (c *Client) Tail(ctx context.Context, id string, fn func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/tasks/"+id+"/events", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestBodyBytes)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		fn(event)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, maxRequestBodyBytes)).Decode(&body)
+	if body.Error == "" {
+		body.Error = resp.Status
+	}
+	return fmt.Errorf("daemon: %s", body.Error)
+}
@@ -0,0 +1,577 @@
+// Package daemon runs agent tasks in the background for one workspace.
+// Clients submit prompts over HTTP, served on a Unix socket and optionally
+// on TCP, where RequireToken guards it:
+//
+//	POST /tasks               queue a task: {"prompt": "...", "session": "name"}
+//	GET  /tasks               list tasks, oldest first
+//	GET  /tasks/{id}          fetch a task
+//	POST /tasks/{id}/cancel   drop a queued task or interrupt the running one
+//	GET  /tasks/{id}/events   server-sent events: the task's history so far,
+//	                          then live progress until it finishes
+//
+// Tasks run one at a time, in submission order, because the tools act on the
+// process working directory. Tasks naming the same session continue one
+// conversation, persisted through pkg/session; unnamed tasks start fresh.
+// The queue itself lives in memory and is lost when the daemon stops.
+//
+// The daemon also submits tasks on cron schedules; see Daemon.Schedule.
+package daemon
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// DefaultSocket is the socket path, relative to the workspace, that the
+// daemon listens on and clients dial.
+const DefaultSocket = ".agent/daemon.sock"
+
+const (
+	maxRequestBodyBytes = 1 << 20
+	maxEventOutputBytes = 4000
+	// maxFinishedTasks bounds how many finished tasks are remembered.
+	maxFinishedTasks = 200
+)
+
+// Task statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Event types streamed over GET /tasks/{id}/events.
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventToken     = "token"
+	EventToolCall  = "tool_call_delta"
+	EventToolStart = "tool_start"
+	EventToolEnd   = "tool_end"
+	EventFinished  = "finished"
+)
+
+// Task is a submitted prompt and, once it has run, its outcome.
+type Task struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	// Session names the conversation the task continues; empty starts a new
+	// one. SessionID is the stored session it ran in.
+	Session   string `json:"session,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Schedule names the schedule that submitted the task, if any.
+	Schedule   string     `json:"schedule,omitempty"`
+	Status     string     `json:"status"`
+	Reply      string     `json:"reply,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the task will not change any more.
+func (t Task) Finished() bool {
+	return t.Status == StatusDone || t.Status == StatusFailed || t.Status == StatusCancelled
+}
+
+// Event is one progress event of a task.
+type Event struct {
+	Type string         `json:"type"`
+	Time time.Time      `json:"time"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Config wires the daemon to the agent.
+type Config struct {
+	Client       *openai.Client
+	Model        string
+	Registry     *tools.Registry
+	Sessions     *session.Service
+	SystemPrompt string
+	// MaxTurns limits the model calls of one task; 0 means no limit.
+	MaxTurns int
+	// Runner defaults to loop.Run.
+	Runner loop.AgentRunner
+	// Logf reports what happens in the background, such as scheduled runs
+	// and failed deliveries; nil discards it.
+	Logf func(format string, args ...any)
+}
+
+// Daemon queues tasks and runs them one at a time; see Run.
+type Daemon struct {
+	cfg  Config
+	wake chan struct{}
+
+	mu       sync.Mutex
+	seq      int
+	tasks    map[string]*entry
+	order    []string
+	queue    []string
+	sessions map[string]string // session name -> stored session ID
+}
+
+// entry is a task with its event log, guarded by Daemon.mu. changed is
+// closed and replaced whenever an event is appended, waking every tail.
+type entry struct {
+	task    Task
+	cancel  context.CancelFunc
+	events  []Event
+	changed chan struct{}
+}
+
+var errNotFound = errors.New("task not found")
+
+func New(cfg Config) (*Daemon, error) {
+	if cfg.Sessions == nil {
+		return nil, fmt.Errorf("session service is required")
+	}
+	if cfg.Registry == nil {
+		cfg.Registry = tools.New()
+	}
+	if cfg.Runner == nil {
+		cfg.Runner = loop.Run
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
+	return &Daemon{
+		cfg:      cfg,
+		wake:     make(chan struct{}, 1),
+		tasks:    make(map[string]*entry),
+		sessions: make(map[string]string),
+	}, nil
+}
+
+// Submit queues prompt, to run in the named session ("" for a new one).
+func (d *Daemon) Submit(prompt, sessionName string) (Task, error) {
+	return d.submit(prompt, sessionName, "")
+}
+
+func (d *Daemon) submit(prompt, sessionName, schedule string) (Task, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return Task{}, fmt.Errorf("prompt is required")
+	}
+	d.mu.Lock()
+	d.seq++
+	e := &entry{
+		task: Task{
+			ID:       fmt.Sprintf("task-%d", d.seq),
+			Prompt:   prompt,
+			Session:  strings.TrimSpace(sessionName),
+			Schedule: schedule,
+			Status:   StatusQueued,
+			QueuedAt: time.Now().UTC(),
+		},
+		changed: make(chan struct{}),
+	}
+	d.tasks[e.task.ID] = e
+	d.order = append(d.order, e.task.ID)
+	d.queue = append(d.queue, e.task.ID)
+	d.publishLocked(e, EventQueued, map[string]any{"position": len(d.queue)})
+	task := e.task
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return task, nil
+}
+
+// Get returns the task with id.
+func (d *Daemon) Get(id string) (Task, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e := d.tasks[id]
+	if e == nil {
+		return Task{}, fmt.Errorf("%w: %s", errNotFound, id)
+	}
+	return e.task, nil
+}
+
+// List returns the remembered tasks, oldest first.
+func (d *Daemon) List() []Task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]Task, 0, len(d.order))
+	for _, id := range d.order {
+		list = append(list, d.tasks[id].task)
+	}
+	return list
+}
+
+// Cancel drops a queued task or interrupts the running one. Cancelling a
+// finished task is an error.
+func (d *Daemon) Cancel(id string) (Task, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e := d.tasks[id]
+	switch {
+	case e == nil:
+		return Task{}, fmt.Errorf("%w: %s", errNotFound, id)
+	case e.task.Status == StatusQueued:
+		d.queue = slices.DeleteFunc(d.queue, func(queued string) bool { return queued == id })
+		d.finishLocked(e, StatusCancelled, "", nil)
+	case e.task.Status == StatusRunning:
+		// The worker records the cancellation when the run returns.
+		e.cancel()
+	default:
+		return Task{}, fmt.Errorf("task %s has already finished", id)
+	}
+	return e.task, nil
+}
+
+// Tail calls fn with every event of task id, starting with those already
+// recorded, until the task finishes or ctx is done.
+func (d *Daemon) Tail(ctx context.Context, id string, fn func(Event)) error {
+	next := 0
+	for {
+		d.mu.Lock()
+		e := d.tasks[id]
+		if e == nil {
+			d.mu.Unlock()
+			return fmt.Errorf("%w: %s", errNotFound, id)
+		}
+		events := e.events[next:]
+		next = len(e.events)
+		finished, changed := e.task.Finished(), e.changed
+		d.mu.Unlock()
+
+		for _, event := range events {
+			fn(event)
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Run works through the queue until ctx is done; cancelling ctx interrupts
+// the running task. Queued tasks are left unrun.
+func (d *Daemon) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if e, runCtx, cancel := d.next(ctx); e != nil {
+			reply, err := d.runTask(runCtx, e)
+			d.finish(e, reply, err, runCtx.Err() != nil)
+			cancel()
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-d.wake:
+		}
+	}
+}
+
+// next pops the oldest queued task and marks it running with a context
+// Cancel can interrupt.
+func (d *Daemon) next(ctx context.Context) (*entry, context.Context, context.CancelFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.queue) == 0 {
+		return nil, nil, nil
+	}
+	e := d.tasks[d.queue[0]]
+	d.queue = d.queue[1:]
+	now := time.Now().UTC()
+	e.task.Status, e.task.StartedAt = StatusRunning, &now
+	runCtx, cancel := context.WithCancel(ctx)
+	e.cancel = cancel
+	d.publishLocked(e, EventStarted, nil)
+	return e, runCtx, cancel
+}
+
+func (d *Daemon) finish(e *entry, reply string, err error, interrupted bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case err == nil:
+		d.finishLocked(e, StatusDone, reply, nil)
+	case interrupted:
+		d.finishLocked(e, StatusCancelled, reply, err)
+	default:
+		d.finishLocked(e, StatusFailed, reply, err)
+	}
+}
+
+// runTask runs the agent on the task's session and saves the conversation,
+// including the part that ran before an error.
+func (d *Daemon) runTask(ctx context.Context, e *entry) (string, error) {
+	sess, err := d.session(e.task.Session)
+	if err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	e.task.SessionID = sess.ID
+	d.mu.Unlock()
+
+	messages := append(sess.Messages, openai.UserMessage(e.task.Prompt))
+	ctx = loop.WithTokenHandler(ctx, func(delta string) {
+		d.publish(e, EventToken, map[string]any{"delta": delta})
+	})
+	ctx = loop.WithToolCallHandler(ctx, func(delta loop.ToolCallDelta) {
+		d.publish(e, EventToolCall, map[string]any{"id": delta.ID, "tool": delta.Name, "delta": delta.Fragment})
+	})
+	if d.cfg.MaxTurns > 0 {
+		ctx = loop.WithMaxTurns(ctx, d.cfg.MaxTurns)
+	}
+	registry := d.cfg.Registry.WithMiddleware(d.toolEvents(e), tools.NewRepeatGuard(tools.DefaultMaxRepeats).Middleware())
+	history, runErr := d.cfg.Runner(ctx, d.cfg.Client, d.cfg.Model, messages, registry)
+	if len(history) == 0 {
+		history = messages
+	}
+	if _, err := d.cfg.Sessions.SaveMessages(sess.ID, history); err != nil {
+		runErr = errors.Join(runErr, err)
+	}
+
+	reply := ""
+	if last := history[len(history)-1]; len(history) > len(messages) && last.OfAssistant != nil {
+		reply = last.OfAssistant.Content.OfString.Value
+	}
+	return reply, runErr
+}
+
+// session returns the stored session called name, creating it when needed.
+// Names are looked up among stored session titles so a restarted daemon
+// picks its conversations up again.
+func (d *Daemon) session(name string) (session.Session, error) {
+	if name != "" {
+		d.mu.Lock()
+		id := d.sessions[name]
+		d.mu.Unlock()
+		if id != "" {
+			sess, err := d.cfg.Sessions.Get(id)
+			if !errors.Is(err, session.ErrNotFound) {
+				return sess, err
+			}
+		} else {
+			stored, err := d.cfg.Sessions.List()
+			if err != nil {
+				return session.Session{}, err
+			}
+			var found *session.Session
+			for i := range stored {
+				if stored[i].Title == name && (found == nil || stored[i].UpdatedAt.After(found.UpdatedAt)) {
+					found = &stored[i]
+				}
+			}
+			if found != nil {
+				d.remember(name, found.ID)
+				return *found, nil
+			}
+		}
+	}
+
+	var messages []openai.ChatCompletionMessageParamUnion
+	if strings.TrimSpace(d.cfg.SystemPrompt) != "" {
+		messages = append(messages, openai.SystemMessage(d.cfg.SystemPrompt))
+	}
+	sess, err := d.cfg.Sessions.Create(name, messages)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if name != "" {
+		d.remember(name, sess.ID)
+	}
+	return sess, nil
+}
+
+func (d *Daemon) remember(name, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[name] = id
+}
+
+func (d *Daemon) toolEvents(e *entry) tools.Middleware {
+	return func(name string, next tools.Handler) tools.Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			d.publish(e, EventToolStart, map[string]any{"tool": name, "args": args})
+			output, err := next(ctx, args)
+
+			data := map[string]any{"tool": name, "output": truncate(output, maxEventOutputBytes)}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			d.publish(e, EventToolEnd, data)
+			return output, err
+		}
+	}
+}
+
+func (d *Daemon) publish(e *entry, eventType string, data map[string]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.publishLocked(e, eventType, data)
+}
+
+func (d *Daemon) publishLocked(e *entry, eventType string, data map[string]any) {
+	e.events = append(e.events, Event{Type: eventType, Time: time.Now().UTC(), Data: data})
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// finishLocked records the outcome of e, publishes the finished event and
+// forgets the oldest finished tasks beyond maxFinishedTasks.
+func (d *Daemon) finishLocked(e *entry, status, reply string, err error) {
+	now := time.Now().UTC()
+	e.task.Status, e.task.Reply, e.task.FinishedAt = status, reply, &now
+	e.cancel = nil
+	data := map[string]any{"status": status}
+	if reply != "" {
+		data["reply"] = reply
+	}
+	if err != nil {
+		e.task.Error = err.Error()
+		data["error"] = e.task.Error
+	}
+	d.publishLocked(e, EventFinished, data)
+
+	finished := 0
+	for _, id := range d.order {
+		if d.tasks[id].task.Finished() {
+			finished++
+		}
+	}
+	d.order = slices.DeleteFunc(d.order, func(id string) bool {
+		if finished <= maxFinishedTasks || !d.tasks[id].task.Finished() {
+			return false
+		}
+		finished--
+		delete(d.tasks, id)
+		return true
+	})
+}
+
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", d.handleSubmit)
+	mux.HandleFunc("GET /tasks", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, d.List())
+	})
+	mux.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		task, err := d.Get(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, task)
+	})
+	mux.HandleFunc("POST /tasks/{id}/cancel", d.handleCancel)
+	mux.HandleFunc("GET /tasks/{id}/events", d.handleEvents)
+	return mux
+}
+
+// RequireToken rejects the requests to next that lack "Authorization: Bearer
+// <token>". The daemon's TCP listener is served through it; the Unix socket
+// is protected by its file mode instead.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agent-daemon"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Submission is the body of POST /tasks.
+type Submission struct {
+	Prompt  string `json:"prompt"`
+	Session string `json:"session,omitempty"`
+}
+
+func (d *Daemon) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// A web page can POST text/plain to a local port without a preflight;
+	// insisting on JSON keeps it from queueing tasks.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type must be application/json"))
+		return
+	}
+	var body Submission
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err := decoder.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	task, err := d.Submit(body.Prompt, body.Session)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, task)
+}
+
+func (d *Daemon) handleCancel(w http.ResponseWriter, r *http.Request) {
+	task, err := d.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusOK, task)
+	}
+}
+
+func (d *Daemon) handleEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := d.Get(id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	_ = d.Tail(r.Context(), id, func(event Event) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		flusher.Flush()
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "\n... (truncated)"
+}
@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestDaemon_RunsTasksInOrderAndContinuesNamedSessions(t *testing.T) {
+	var running, overlapped atomic.Int32
+	client, sessions := startDaemon(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		if running.Add(1) > 1 {
+			overlapped.Store(1)
+		}
+		defer running.Add(-1)
+		loop.TokenHandlerFrom(ctx)("working")
+		reply := session.MessagePreview(messages[len(messages)-1], 0) + " done"
+		return append(messages, openai.AssistantMessage(reply)), nil
+	})
+
+	ctx := context.Background()
+	if _, err := client.Submit(ctx, Submission{Prompt: "one", Session: "nightly"}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	_, _ = client.Submit(ctx, Submission{Prompt: "other"})
+	last, _ := client.Submit(ctx, Submission{Prompt: "two", Session: "nightly"})
+
+	var types []string
+	if err := client.Tail(ctx, last.ID, func(e Event) { types = append(types, e.Type) }); err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if strings.Join(types, ",") != "queued,started,token,finished" {
+		t.Fatalf("events = %v", types)
+	}
+
+	tasks, err := client.Tasks(ctx)
+	if err != nil || len(tasks) != 3 {
+		t.Fatalf("Tasks = %+v, %v", tasks, err)
+	}
+	for _, task := range tasks {
+		if task.Status != StatusDone || task.Reply != task.Prompt+" done" {
+			t.Fatalf("task = %+v", task)
+		}
+	}
+	if overlapped.Load() != 0 {
+		t.Fatal("tasks ran concurrently")
+	}
+	if tasks[0].SessionID != tasks[2].SessionID || tasks[0].SessionID == tasks[1].SessionID {
+		t.Fatalf("sessions: %s, %s, %s", tasks[0].SessionID, tasks[1].SessionID, tasks[2].SessionID)
+	}
+	stored, _ := sessions.Get(tasks[2].SessionID)
+	if stored.Title != "nightly" || len(stored.Messages) != 5 {
+		t.Fatalf("stored session = %q with %d messages, want system + two exchanges", stored.Title, len(stored.Messages))
+	}
+}
+
+func TestDaemon_CancelsQueuedAndRunningTasks(t *testing.T) {
+	started := make(chan struct{})
+	client, _ := startDaemon(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		close(started)
+		<-ctx.Done()
+		return messages, ctx.Err()
+	})
+
+	ctx := context.Background()
+	running, _ := client.Submit(ctx, Submission{Prompt: "block"})
+	queued, _ := client.Submit(ctx, Submission{Prompt: "never runs"})
+	<-started
+
+	if task, err := client.Cancel(ctx, queued.ID); err != nil || task.Status != StatusCancelled {
+		t.Fatalf("Cancel queued = %+v, %v", task, err)
+	}
+	if _, err := client.Cancel(ctx, running.ID); err != nil {
+		t.Fatalf("Cancel running: %v", err)
+	}
+	if err := client.Tail(ctx, running.ID, func(Event) {}); err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	task, _ := client.Task(ctx, running.ID)
+	if task.Status != StatusCancelled || task.FinishedAt == nil {
+		t.Fatalf("running task after cancel = %+v", task)
+	}
+	if _, err := client.Cancel(ctx, running.ID); err == nil || !strings.Contains(err.Error(), "already finished") {
+		t.Fatalf("second Cancel = %v", err)
+	}
+	if _, err := client.Task(ctx, "task-99"); err == nil {
+		t.Fatal("expected an error for an unknown task")
+	}
+}
+
+func TestHandler_RequiresJSONAndToken(t *testing.T) {
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	d, err := New(Config{Sessions: session.NewService(repo)})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	srv := httptest.NewServer(RequireToken("secret", d.Handler()))
+	defer srv.Close()
+
+	post := func(token, contentType string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/tasks", strings.NewReader(`{"prompt":"rm -rf ~"}`))
+		req.Header.Set("Content-Type", contentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("", "application/json"); code != http.StatusUnauthorized {
+		t.Fatalf("without a token = %d", code)
+	}
+	if code := post("guess", "application/json"); code != http.StatusUnauthorized {
+		t.Fatalf("with a wrong token = %d", code)
+	}
+	if code := post("secret", "text/plain"); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("text/plain = %d", code)
+	}
+	if len(d.List()) != 0 {
+		t.Fatalf("tasks queued: %+v", d.List())
+	}
+
+	client := NewHTTPClient(srv.URL, "secret")
+	if _, err := client.Submit(context.Background(), Submission{Prompt: "hi"}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if _, err := NewHTTPClient(srv.URL, "").Tasks(context.Background()); err == nil {
+		t.Fatal("listed tasks without the token")
+	}
+}
+
+func TestListen_RefusesRunningDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if _, err := Listen(path); err == nil {
+		t.Fatal("expected an error while the first listener is open")
+	}
+	ln.Close()
+	ln, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen after close: %v", err)
+	}
+	ln.Close()
+}
+
+func startDaemon(t *testing.T, runner loop.AgentRunner) (*Client, *session.Service) {
+	t.Helper()
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	sessions := session.NewService(repo)
+	d, err := New(Config{Sessions: sessions, SystemPrompt: "sys", Runner: runner})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "d.sock")
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{Handler: d.Handler()}
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+		<-done
+	})
+	return Dial(path), sessions
+}
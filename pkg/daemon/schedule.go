@@ -0,0 +1,184 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/cron"
+)
+
+const webhookTimeout = 30 * time.Second
+
+// Job is a task the daemon submits on a cron schedule.
+type Job struct {
+	Name     string
+	Schedule cron.Schedule
+	Prompt   string
+	Session  string
+	// Webhook, when set, receives every finished task as JSON in a POST
+	// request.
+	Webhook string
+	// LogDir, when set, receives every finished task as
+	// <name>-<time>.json.
+	LogDir string
+}
+
+// JobsFromConfig turns the schedules of the project config into jobs.
+// Relative log directories are joined to root.
+func JobsFromConfig(schedules []config.Schedule, root string) ([]Job, error) {
+	jobs := make([]Job, 0, len(schedules))
+	for _, s := range schedules {
+		spec, err := cron.Parse(s.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		logDir := strings.TrimSpace(s.LogDir)
+		if logDir != "" && !filepath.IsAbs(logDir) {
+			logDir = filepath.Join(root, logDir)
+		}
+		jobs = append(jobs, Job{
+			Name:     strings.TrimSpace(s.Name),
+			Schedule: spec,
+			Prompt:   s.Prompt,
+			Session:  strings.TrimSpace(s.Session),
+			Webhook:  strings.TrimSpace(s.Webhook),
+			LogDir:   logDir,
+		})
+	}
+	return jobs, nil
+}
+
+// Schedule submits every job whenever its schedule fires, until ctx is
+// done, and delivers each finished task. A job whose previous run has not
+// finished skips its turn instead of queueing up behind itself. Schedule
+// returns once the pending deliveries are done.
+func (d *Daemon) Schedule(ctx context.Context, jobs []Job) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.runJob(ctx, job, &wg)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Daemon) runJob(ctx context.Context, job Job, wg *sync.WaitGroup) {
+	last := ""
+	for {
+		now := time.Now()
+		next := job.Schedule.Next(now)
+		if next.IsZero() {
+			d.cfg.Logf("schedule %s: %q never fires", job.Name, job.Schedule)
+			return
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if last != "" {
+			if task, err := d.Get(last); err == nil && !task.Finished() {
+				d.cfg.Logf("schedule %s: %s is still %s, skipping this run", job.Name, last, task.Status)
+				continue
+			}
+		}
+		task, err := d.fire(ctx, job, wg)
+		if err != nil {
+			d.cfg.Logf("schedule %s: %v", job.Name, err)
+			continue
+		}
+		last = task.ID
+	}
+}
+
+// fire submits job and delivers its task in the background once it
+// finishes. A task still running when ctx is done is not delivered.
+func (d *Daemon) fire(ctx context.Context, job Job, wg *sync.WaitGroup) (Task, error) {
+	task, err := d.submit(job.Prompt, job.Session, job.Name)
+	if err != nil {
+		return Task{}, err
+	}
+	d.cfg.Logf("schedule %s: submitted %s", job.Name, task.ID)
+	if job.Webhook == "" && job.LogDir == "" {
+		return task, nil
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := d.Tail(ctx, task.ID, func(Event) {}); err != nil {
+			return
+		}
+		finished, err := d.Get(task.ID)
+		if err == nil {
+			err = deliver(ctx, job, finished)
+		}
+		if err != nil {
+			d.cfg.Logf("schedule %s: deliver %s: %v", job.Name, task.ID, err)
+		}
+	}()
+	return task, nil
+}
+
+// deliver posts task to the job's webhook and writes it to its log
+// directory, whichever are set.
+func deliver(ctx context.Context, job Job, task Task) error {
+	body, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if job.LogDir != "" {
+		finished := task.QueuedAt
+		if task.FinishedAt != nil {
+			finished = *task.FinishedAt
+		}
+		path := filepath.Join(job.LogDir, fmt.Sprintf("%s-%s.json", job.Name, finished.Format("20060102-150405")))
+		if err := os.MkdirAll(job.LogDir, 0o755); err != nil {
+			errs = append(errs, err)
+		} else if err := os.WriteFile(path, append(body, '\n'), 0o644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if job.Webhook != "" {
+		if err := postWebhook(ctx, job.Webhook, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestFire_DeliversFinishedTaskToWebhookAndLogDir(t *testing.T) {
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	d, err := New(Config{Sessions: session.NewService(repo), Runner: func(_ context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		return append(messages, openai.AssistantMessage("all tests pass")), nil
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	delivered := make(chan Task, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var task Task
+		_ = json.NewDecoder(r.Body).Decode(&task)
+		delivered <- task
+	}))
+	defer hook.Close()
+
+	root := t.TempDir()
+	jobs, err := JobsFromConfig([]config.Schedule{{Name: "deps", Cron: "0 9 * * mon", Prompt: "Update dependencies.", Webhook: hook.URL, LogDir: "logs"}}, root)
+	if err != nil || len(jobs) != 1 || jobs[0].LogDir != filepath.Join(root, "logs") {
+		t.Fatalf("JobsFromConfig = %+v, %v", jobs, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	var wg sync.WaitGroup
+	submitted, err := d.fire(ctx, jobs[0], &wg)
+	if err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	task := <-delivered
+	wg.Wait()
+	if task.ID != submitted.ID || task.Schedule != "deps" || task.Status != StatusDone || task.Reply != "all tests pass" {
+		t.Fatalf("delivered %+v", task)
+	}
+	logs, _ := os.ReadDir(filepath.Join(root, "logs"))
+	if len(logs) != 1 || filepath.Ext(logs[0].Name()) != ".json" {
+		t.Fatalf("log dir = %v", logs)
+	}
+	if listed := d.List(); len(listed) != 1 || listed[0].Schedule != "deps" {
+		t.Fatalf("List = %+v", listed)
+	}
+}
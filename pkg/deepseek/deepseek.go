@@ -0,0 +1,45 @@
+// Package deepseek creates a client for DeepSeek's API, which speaks the
+// OpenAI chat completions protocol.
+package deepseek
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	defaultBaseURL = "https://api.deepseek.com/v1"
+	defaultModel   = "deepseek-chat"
+)
+
+// NewClient creates an OpenAI client pointed at DeepSeek.
+// Required env var: DEEPSEEK_API_KEY; DEEPSEEK_BASE_URL overrides the
+// endpoint. Extra options, e.g. option.WithMiddleware, are applied after the
+// defaults.
+func NewClient(opts ...option.RequestOption) (*openai.Client, error) {
+	key := strings.TrimSpace(os.Getenv("DEEPSEEK_API_KEY"))
+	if key == "" {
+		return nil, fmt.Errorf("DEEPSEEK_API_KEY is not set")
+	}
+	baseURL := strings.TrimSpace(os.Getenv("DEEPSEEK_BASE_URL"))
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	defaults := []option.RequestOption{option.WithBaseURL(baseURL), option.WithAPIKey(key)}
+	client := openai.NewClient(append(defaults, opts...)...)
+	return &client, nil
+}
+
+// Model returns the model name from env, falling back to deepseek-chat.
+// deepseek-reasoner thinks before it answers; its reasoning is not sent
+// back in later turns.
+func Model() string {
+	if m := strings.TrimSpace(os.Getenv("DEEPSEEK_MODEL")); m != "" {
+		return m
+	}
+	return defaultModel
+}
@@ -0,0 +1,27 @@
+package deepseek
+
+import (
+	"testing"
+)
+
+func TestNewClient_RequiresAPIKey(t *testing.T) {
+	t.Setenv("DEEPSEEK_API_KEY", "")
+	if _, err := NewClient(); err == nil {
+		t.Fatal("expected an error without DEEPSEEK_API_KEY")
+	}
+	t.Setenv("DEEPSEEK_API_KEY", "sk-test")
+	if client, err := NewClient(); err != nil || client == nil {
+		t.Fatalf("NewClient = %v, %v", client, err)
+	}
+}
+
+func TestModel_DefaultsToChat(t *testing.T) {
+	t.Setenv("DEEPSEEK_MODEL", "")
+	if got := Model(); got != "deepseek-chat" {
+		t.Fatalf("Model = %q", got)
+	}
+	t.Setenv("DEEPSEEK_MODEL", "deepseek-reasoner")
+	if got := Model(); got != "deepseek-reasoner" {
+		t.Fatalf("Model = %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+// Package envinfo gathers a snapshot of the working environment (OS, shell,
+// Go toolchain, git state, date) at session start so it can be put in the
+// system prompt instead of the model spending its first tool calls on uname
+// and git status.
+package envinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds each external command; a slow git or go is skipped.
+const probeTimeout = 3 * time.Second
+
+// Snapshot is the gathered environment. Fields that could not be determined
+// are empty.
+type Snapshot struct {
+	OS        string
+	Arch      string
+	Shell     string
+	GoVersion string
+	WorkDir   string
+	GitBranch string
+	// GitStatus summarizes the work tree, e.g. "clean" or "2 modified, 1 untracked".
+	GitStatus string
+	Date      string
+}
+
+// Gather probes the environment of dir. It never fails; missing tools leave
+// their fields empty.
+func Gather(ctx context.Context, dir string) Snapshot {
+	s := Snapshot{
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Shell:   shellName(),
+		WorkDir: dir,
+		Date:    time.Now().Format("2006-01-02 (Monday)"),
+	}
+	if out, err := probe(ctx, dir, "go", "env", "GOVERSION"); err == nil {
+		s.GoVersion = strings.TrimSpace(out)
+	}
+	if out, err := probe(ctx, dir, "git", "status", "--porcelain=v1", "--branch"); err == nil {
+		s.GitBranch, s.GitStatus = summarizeGitStatus(out)
+	}
+	return s
+}
+
+func shellName() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return filepath.Base(shell)
+	}
+	if runtime.GOOS == "windows" {
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return filepath.Base(comspec)
+		}
+	}
+	return ""
+}
+
+func probe(ctx context.Context, dir, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// summarizeGitStatus turns `git status --porcelain=v1 --branch` output into a
+// branch name and a one-line summary.
+func summarizeGitStatus(out string) (branch, summary string) {
+	var staged, modified, untracked, conflicted int
+	var tracking string
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if rest, ok := strings.CutPrefix(line, "## "); ok {
+			branch, tracking = parseBranchLine(rest)
+			continue
+		}
+		if len(line) < 2 {
+			continue
+		}
+		x, y := line[0], line[1]
+		switch {
+		case x == '?' && y == '?':
+			untracked++
+		case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+			conflicted++
+		default:
+			if x != ' ' {
+				staged++
+			}
+			if y != ' ' {
+				modified++
+			}
+		}
+	}
+
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{staged, "staged"}, {modified, "modified"}, {untracked, "untracked"}, {conflicted, "conflicted"}} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	summary = "clean"
+	if len(parts) > 0 {
+		summary = strings.Join(parts, ", ")
+	}
+	if tracking != "" {
+		summary += " (" + tracking + ")"
+	}
+	return branch, summary
+}
+
+// parseBranchLine parses "main...origin/main [ahead 1, behind 2]" or
+// "No commits yet on main".
+func parseBranchLine(line string) (branch, tracking string) {
+	if rest, ok := strings.CutPrefix(line, "No commits yet on "); ok {
+		return rest, "no commits yet"
+	}
+	if i := strings.Index(line, " ["); i >= 0 && strings.HasSuffix(line, "]") {
+		tracking = line[i+2 : len(line)-1]
+		line = line[:i]
+	}
+	branch, _, _ = strings.Cut(line, "...")
+	return branch, tracking
+}
+
+// String formats the snapshot as a block for the system prompt.
+func (s Snapshot) String() string {
+	var b strings.Builder
+	b.WriteString("Environment (gathered at session start; re-check only if you change it):\n")
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", label, value)
+		}
+	}
+	line("OS", s.OS+"/"+s.Arch)
+	line("Shell", s.Shell)
+	line("Go", s.GoVersion)
+	line("Working directory", s.WorkDir)
+	if s.GitBranch != "" {
+		line("Git", "branch "+s.GitBranch+", "+s.GitStatus)
+	}
+	line("Date", s.Date)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Vars returns the template variables Expand substitutes.
+func (s Snapshot) Vars() map[string]string {
+	return map[string]string{
+		"env":        s.String(),
+		"os":         s.OS,
+		"arch":       s.Arch,
+		"shell":      s.Shell,
+		"go_version": s.GoVersion,
+		"cwd":        s.WorkDir,
+		"git_branch": s.GitBranch,
+		"git_status": s.GitStatus,
+		"date":       s.Date,
+	}
+}
+
+// Expand replaces {{name}} variables in prompt (see Vars); {{env}} is the
+// whole snapshot block. Unknown variables are left as they are.
+func (s Snapshot) Expand(prompt string) string {
+	vars := s.Vars()
+	pairs := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		pairs = append(pairs, "{{"+name+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(prompt)
+}
@@ -0,0 +1,60 @@
+package envinfo
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeGitStatus(t *testing.T) {
+	tests := []struct {
+		out, branch, summary string
+	}{
+		{"## main...origin/main\n", "main", "clean"},
+		{"## feature...origin/feature [ahead 2]\n M a.go\nM  b.go\nMM c.go\n?? new.txt\n", "feature", "2 staged, 2 modified, 1 untracked (ahead 2)"},
+		{"## No commits yet on main\n?? x\n", "main", "1 untracked (no commits yet)"},
+		{"## HEAD (no branch)\nUU conflict.go\n", "HEAD (no branch)", "1 conflicted"},
+	}
+	for _, tt := range tests {
+		branch, summary := summarizeGitStatus(tt.out)
+		if branch != tt.branch || summary != tt.summary {
+			t.Errorf("summarizeGitStatus(%q) = %q, %q; want %q, %q", tt.out, branch, summary, tt.branch, tt.summary)
+		}
+	}
+}
+
+func TestSnapshot_Expand(t *testing.T) {
+	s := Snapshot{OS: "linux", Arch: "amd64", Shell: "zsh", GoVersion: "go1.25.5", WorkDir: "/repo",
+		GitBranch: "main", GitStatus: "clean", Date: "2026-01-02 (Friday)"}
+
+	got := s.Expand("You are at {{cwd}} on {{os}}. {{unknown}}\n\n{{env}}")
+	for _, want := range []string{
+		"You are at /repo on linux. {{unknown}}",
+		"- OS: linux/amd64",
+		"- Git: branch main, clean",
+		"- Date: 2026-01-02 (Friday)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expand() missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGather_InGitRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "-C", dir, "init", "-q", "-b", "trunk").CombinedOutput(); err != nil {
+		t.Skipf("git init: %v\n%s", err, out)
+	}
+
+	s := Gather(context.Background(), dir)
+	if s.GitBranch != "trunk" || !strings.HasPrefix(s.GitStatus, "clean") {
+		t.Fatalf("git fields = %q, %q; want trunk, clean", s.GitBranch, s.GitStatus)
+	}
+	if s.OS == "" || s.Date == "" || s.WorkDir != dir {
+		t.Fatalf("incomplete snapshot: %+v", s)
+	}
+}
@@ -0,0 +1,346 @@
+// Package evals is a benchmark suite for the agent. A task is a prompt plus
+// two shell scripts: setup prepares an empty temporary directory, and assert
+// decides afterwards whether the agent succeeded (exit status 0 passes).
+// Each task file is JSON:
+//
+//	{
+//	  "name": "fix-failing-test",
+//	  "prompt": "The tests fail. Fix the bug, not the test.",
+//	  "setup": "cp -r \"$EVAL_TASK_DIR/fixtures/calc/.\" .",
+//	  "assert": "go test ./...",
+//	  "max_turns": 20
+//	}
+//
+// Passing runs can be recorded as golden transcripts (Runner.Update) and
+// replayed later without a model (Runner.Replay), which re-executes the
+// recorded tool calls and checks that the tools still produce a passing tree.
+package evals
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// GoldenDir is where golden transcripts live, next to the task files.
+	GoldenDir = "golden"
+	// DefaultTimeout bounds a task that sets no timeout.
+	DefaultTimeout = 10 * time.Minute
+	// maxAssertOutput keeps the tail of the assert script's output.
+	maxAssertOutput = 4 << 10
+)
+
+// Task is one benchmark case.
+type Task struct {
+	Name     string `json:"name"`
+	Prompt   string `json:"prompt"`
+	Setup    string `json:"setup,omitempty"`
+	Assert   string `json:"assert"`
+	MaxTurns int    `json:"max_turns,omitempty"`
+	// Timeout is a Go duration such as "5m"; empty means DefaultTimeout.
+	Timeout string `json:"timeout,omitempty"`
+
+	// Dir is the directory of the task file, exported to the scripts as
+	// EVAL_TASK_DIR so setup can copy fixtures.
+	Dir string `json:"-"`
+	// Golden is the transcript path used by Replay and Update.
+	Golden string `json:"-"`
+}
+
+// LoadTasks reads every *.json file in dir, sorted by name. Names default to
+// the file name without extension.
+func LoadTasks(dir string) ([]Task, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if task.Name == "" {
+			task.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		if err := task.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if seen[task.Name] {
+			return nil, fmt.Errorf("%s: duplicate task name %q", path, task.Name)
+		}
+		seen[task.Name] = true
+		task.Dir = abs
+		task.Golden = filepath.Join(abs, GoldenDir, task.Name+".json")
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no tasks in %s", dir)
+	}
+	return tasks, nil
+}
+
+func (t Task) validate() error {
+	if strings.ContainsAny(t.Name, `/\`) || strings.HasPrefix(t.Name, ".") {
+		return fmt.Errorf("invalid task name %q", t.Name)
+	}
+	if strings.TrimSpace(t.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if strings.TrimSpace(t.Assert) == "" {
+		return fmt.Errorf("assert is required")
+	}
+	if _, err := t.timeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t Task) timeout() (time.Duration, error) {
+	if strings.TrimSpace(t.Timeout) == "" {
+		return DefaultTimeout, nil
+	}
+	d, err := time.ParseDuration(t.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", t.Timeout)
+	}
+	return d, nil
+}
+
+// Score is the outcome of one task.
+type Score struct {
+	Task             string `json:"task"`
+	Passed           bool   `json:"passed"`
+	Turns            int    `json:"turns"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	// Cost is estimated from Runner.Pricing.
+	Cost       float64 `json:"cost,omitempty"`
+	DurationMS int64   `json:"duration_ms"`
+	// Error is set when setup failed or the agent stopped with an error;
+	// the assert script still decides Passed after an agent error.
+	Error        string `json:"error,omitempty"`
+	AssertOutput string `json:"assert_output,omitempty"`
+	// Replayed is true when the golden transcript stood in for the model.
+	Replayed bool `json:"replayed,omitempty"`
+
+	Transcript []openai.ChatCompletionMessageParamUnion `json:"-"`
+}
+
+// Report is the scoring output of a suite run.
+type Report struct {
+	Passed int     `json:"passed"`
+	Total  int     `json:"total"`
+	Cost   float64 `json:"cost,omitempty"`
+	Scores []Score `json:"scores"`
+}
+
+// WriteText prints a table of scores followed by the pass rate.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tRESULT\tTURNS\tTOKENS\tTIME\tERROR")
+	for _, s := range r.Scores {
+		result := "FAIL"
+		if s.Passed {
+			result = "PASS"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", s.Task, result, s.Turns,
+			s.PromptTokens+s.CompletionTokens, (time.Duration(s.DurationMS) * time.Millisecond).Round(time.Millisecond), firstLine(s.Error))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if r.Cost > 0 {
+		_, err := fmt.Fprintf(w, "%d/%d passed, cost≈%.4f\n", r.Passed, r.Total, r.Cost)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d/%d passed\n", r.Passed, r.Total)
+	return err
+}
+
+// Runner executes tasks one at a time. Tools resolve paths against the
+// process working directory, so Run changes into each task's directory and
+// must not run concurrently with anything else that depends on it.
+type Runner struct {
+	Client       *openai.Client
+	Model        string
+	Tools        *tools.Registry
+	SystemPrompt string
+	// Pricing estimates the cost of each task.
+	Pricing budget.Pricing
+	// Replay answers from each task's golden transcript instead of Client;
+	// tasks without one fail.
+	Replay bool
+	// Update writes the transcript of every passing (non-replayed) run to
+	// the task's golden path.
+	Update bool
+	// KeepWorkDirs leaves the temporary directories behind for debugging.
+	KeepWorkDirs bool
+	// OnScore, if set, is called after each task.
+	OnScore func(Score)
+}
+
+// Run executes tasks in order. Failing tasks are scored, not returned as
+// errors; the error is for problems outside any task.
+func (r *Runner) Run(ctx context.Context, tasks []Task) (Report, error) {
+	origDir, err := os.Getwd()
+	if err != nil {
+		return Report{}, err
+	}
+	defer os.Chdir(origDir)
+
+	report := Report{Total: len(tasks)}
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		score := r.runTask(ctx, task)
+		if err := os.Chdir(origDir); err != nil {
+			return report, err
+		}
+		if score.Passed {
+			report.Passed++
+		}
+		report.Cost += score.Cost
+		if r.Update && score.Passed && !score.Replayed {
+			if err := SaveTranscript(task.Golden, score.Transcript); err != nil {
+				return report, err
+			}
+		}
+		report.Scores = append(report.Scores, score)
+		if r.OnScore != nil {
+			r.OnScore(score)
+		}
+	}
+	return report, nil
+}
+
+func (r *Runner) runTask(ctx context.Context, task Task) (score Score) {
+	score = Score{Task: task.Name, Replayed: r.Replay}
+	start := time.Now()
+	defer func() { score.DurationMS = time.Since(start).Milliseconds() }()
+
+	timeout, err := task.timeout()
+	if err != nil {
+		score.Error = err.Error()
+		return score
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	workDir, err := os.MkdirTemp("", "eval-"+task.Name+"-")
+	if err != nil {
+		score.Error = err.Error()
+		return score
+	}
+	if !r.KeepWorkDirs {
+		defer os.RemoveAll(workDir)
+	}
+	if out, err := runScript(ctx, task, workDir, task.Setup); err != nil {
+		score.Error = fmt.Sprintf("setup: %v\n%s", err, out)
+		return score
+	}
+
+	client := r.Client
+	if r.Replay {
+		golden, err := LoadTranscript(task.Golden)
+		if err != nil {
+			score.Error = fmt.Sprintf("replay: %v", err)
+			return score
+		}
+		client = ReplayClient(golden)
+	}
+	a, err := agent.New(
+		agent.WithClient(client),
+		agent.WithModel(r.Model),
+		agent.WithTools(r.Tools),
+		agent.WithSystemPrompt(r.SystemPrompt),
+		agent.WithMaxTurns(task.MaxTurns),
+	)
+	if err != nil {
+		score.Error = err.Error()
+		return score
+	}
+
+	tracker := budget.New(budget.Limits{}, r.Pricing)
+	if err := os.Chdir(workDir); err != nil {
+		score.Error = err.Error()
+		return score
+	}
+	if _, err := a.Run(budget.WithTracker(ctx, tracker), task.Prompt); err != nil {
+		score.Error = err.Error()
+	}
+	score.Transcript = a.Messages()
+	score.Turns = countTurns(score.Transcript)
+	usage := tracker.Usage()
+	score.PromptTokens, score.CompletionTokens, score.Cost = usage.PromptTokens, usage.CompletionTokens, usage.Cost
+
+	out, err := runScript(ctx, task, workDir, task.Assert)
+	score.AssertOutput = tail(out, maxAssertOutput)
+	score.Passed = err == nil
+	return score
+}
+
+// runScript runs script with bash in dir; an empty script does nothing.
+func runScript(ctx context.Context, task Task, dir, script string) (string, error) {
+	if strings.TrimSpace(script) == "" {
+		return "", nil
+	}
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "EVAL_TASK_DIR="+task.Dir, "EVAL_WORK_DIR="+dir)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
+
+// countTurns counts assistant messages, i.e. model calls that were answered.
+func countTurns(messages []openai.ChatCompletionMessageParamUnion) int {
+	n := 0
+	for _, m := range messages {
+		if m.OfAssistant != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "…" + s[len(s)-n:]
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
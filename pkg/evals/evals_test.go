@@ -0,0 +1,155 @@
+package evals
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestLoadTasks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "b.json"), `{"prompt": "second", "assert": "true"}`)
+	writeFile(t, filepath.Join(dir, "a.json"), `{"name": "first", "prompt": "first", "assert": "true", "timeout": "1m"}`)
+
+	tasks, err := LoadTasks(dir)
+	if err != nil {
+		t.Fatalf("LoadTasks: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "first" || tasks[1].Name != "b" {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+	if want := filepath.Join(dir, GoldenDir, "b.json"); tasks[1].Golden != want || tasks[1].Dir != dir {
+		t.Fatalf("golden = %q, dir = %q", tasks[1].Golden, tasks[1].Dir)
+	}
+
+	for _, bad := range []string{
+		`{"prompt": "x"}`,
+		`{"assert": "true"}`,
+		`{"prompt": "x", "assert": "true", "timeout": "soon"}`,
+	} {
+		badDir := t.TempDir()
+		writeFile(t, filepath.Join(badDir, "t.json"), bad)
+		if _, err := LoadTasks(badDir); err == nil {
+			t.Fatalf("LoadTasks(%s) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestRunner_RecordsGoldenAndReplaysIt(t *testing.T) {
+	dir := t.TempDir()
+	task := Task{
+		Name:   "hello",
+		Prompt: "write hello.txt",
+		Setup:  "echo seed > seed.txt",
+		Assert: `test -f seed.txt && grep -q hi hello.txt`,
+		Dir:    dir,
+		Golden: filepath.Join(dir, GoldenDir, "hello.json"),
+	}
+
+	// A scripted "model" run with Update records the golden transcript.
+	recorder := &Runner{Client: ReplayClient(writeHelloTranscript()), Model: "m", Tools: fileTools(), Update: true}
+	report, err := recorder.Run(context.Background(), []Task{task})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Passed != 1 || report.Scores[0].Turns != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if _, err := os.Stat(task.Golden); err != nil {
+		t.Fatalf("golden not written: %v", err)
+	}
+
+	replayer := &Runner{Model: "m", Tools: fileTools(), Replay: true}
+	report, err = replayer.Run(context.Background(), []Task{task})
+	if err != nil {
+		t.Fatalf("Run replay: %v", err)
+	}
+	if score := report.Scores[0]; !score.Passed || !score.Replayed {
+		t.Fatalf("replay score = %+v", score)
+	}
+}
+
+func TestRunner_ScoresFailures(t *testing.T) {
+	runner := &Runner{Client: ReplayClient(writeHelloTranscript()), Model: "m", Tools: fileTools()}
+	tasks := []Task{
+		{Name: "setup-fails", Prompt: "p", Setup: "echo broken >&2; exit 3", Assert: "true"},
+		{Name: "assert-fails", Prompt: "p", Assert: "echo missing; exit 1"},
+		{Name: "no-golden", Prompt: "p", Assert: "true"},
+	}
+
+	report, err := runner.Run(context.Background(), tasks[:2])
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Passed != 0 || report.Total != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if s := report.Scores[0]; !strings.Contains(s.Error, "setup") || !strings.Contains(s.Error, "broken") {
+		t.Fatalf("setup score = %+v", s)
+	}
+	if s := report.Scores[1]; s.AssertOutput != "missing\n" {
+		t.Fatalf("assert score = %+v", s)
+	}
+
+	runner = &Runner{Model: "m", Tools: fileTools(), Replay: true}
+	tasks[2].Golden = filepath.Join(t.TempDir(), "absent.json")
+	report, err = runner.Run(context.Background(), tasks[2:])
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if s := report.Scores[0]; s.Passed || !strings.Contains(s.Error, "replay") {
+		t.Fatalf("missing golden score = %+v", s)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil || !strings.Contains(out.String(), "no-golden  FAIL") || !strings.Contains(out.String(), "0/1 passed") {
+		t.Fatalf("WriteText = %q, %v", out.String(), err)
+	}
+}
+
+func TestRunner_RestoresWorkingDir(t *testing.T) {
+	before, _ := os.Getwd()
+	runner := &Runner{Client: ReplayClient(writeHelloTranscript()), Model: "m", Tools: fileTools()}
+	if _, err := runner.Run(context.Background(), []Task{{Name: "t", Prompt: "p", Assert: "true"}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if after, _ := os.Getwd(); after != before {
+		t.Fatalf("working dir = %q, want %q", after, before)
+	}
+}
+
+func writeHelloTranscript() []openai.ChatCompletionMessageParamUnion {
+	call := openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+			ID: "call-1",
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      "write_file",
+				Arguments: `{"path": "hello.txt", "content": "hi\n"}`,
+			},
+		}},
+	}
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("write hello.txt"),
+		{OfAssistant: &call},
+		openai.ToolMessage("ok", "call-1"),
+		openai.AssistantMessage("done"),
+	}
+}
+
+func fileTools() *tools.Registry {
+	registry := tools.New()
+	registry.Register(tools.WriteFileToolDef(), tools.WriteFileHandler)
+	return registry
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
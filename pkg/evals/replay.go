@@ -0,0 +1,109 @@
+package evals
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// LoadTranscript reads a golden transcript written by SaveTranscript.
+func LoadTranscript(path string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var messages []openai.ChatCompletionMessageParamUnion
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// SaveTranscript writes messages as indented JSON, creating parent dirs.
+func SaveTranscript(path string, messages []openai.ChatCompletionMessageParamUnion) error {
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReplayClient returns a client that answers each chat completion with the
+// next assistant message of transcript, without touching the network. Tool
+// calls in those messages are executed for real by the agent loop, so a
+// replay checks the tools and the assert script, not the model. Streaming
+// requests are not supported.
+func ReplayClient(transcript []openai.ChatCompletionMessageParamUnion) *openai.Client {
+	var replies []*openai.ChatCompletionAssistantMessageParam
+	for _, m := range transcript {
+		if m.OfAssistant != nil {
+			replies = append(replies, m.OfAssistant)
+		}
+	}
+	r := &replayer{replies: replies}
+	client := openai.NewClient(
+		option.WithBaseURL("http://replay.invalid/"),
+		option.WithAPIKey("replay"),
+		option.WithMaxRetries(0),
+		option.WithMiddleware(r.serve),
+	)
+	return &client
+}
+
+type replayer struct {
+	mu      sync.Mutex
+	replies []*openai.ChatCompletionAssistantMessageParam
+	next    int
+}
+
+func (r *replayer) serve(req *http.Request, _ option.MiddlewareNext) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.replies) {
+		return jsonResponse(req, http.StatusBadRequest, map[string]any{
+			"error": map[string]any{"message": "golden transcript has no more assistant messages", "type": "replay_exhausted"},
+		})
+	}
+	reply := r.replies[r.next]
+	r.next++
+
+	message, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+	finish := "stop"
+	if len(reply.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	return jsonResponse(req, http.StatusOK, map[string]any{
+		"id":      fmt.Sprintf("replay-%d", r.next),
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   "replay",
+		"choices": []map[string]any{{"index": 0, "finish_reason": finish, "message": json.RawMessage(message)}},
+	})
+}
+
+func jsonResponse(req *http.Request, status int, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
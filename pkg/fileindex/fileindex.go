@@ -0,0 +1,230 @@
+// Package fileindex lists project files the way git sees them (respecting
+// .gitignore) and ranks them against fuzzy queries for pickers.
+package fileindex
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxFiles bounds the index for very large trees.
+	maxFiles = 50000
+	// refreshAfter is how long an Index reuses its listing.
+	refreshAfter = 5 * time.Second
+)
+
+// skipDirs are never descended into when the project is not a git repository.
+var skipDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+	"__pycache__":  true,
+}
+
+// List returns the slash-separated paths of the files under root, sorted.
+// Inside a git work tree it asks git for tracked and untracked, non-ignored
+// files; otherwise it walks the tree skipping VCS and dependency directories.
+func List(ctx context.Context, root string) ([]string, error) {
+	if files, err := gitFiles(ctx, root); err == nil {
+		return files, nil
+	}
+	return walkFiles(root)
+}
+
+func gitFiles(ctx context.Context, root string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var files []string
+	for _, raw := range bytes.Split(out, []byte{0}) {
+		rel := string(raw)
+		if rel == "" || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		// The index may still list files deleted from the work tree.
+		if info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel))); err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, rel)
+		if len(files) >= maxFiles {
+			break
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func walkFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped rather than failing the listing.
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, filepath.ToSlash(rel))
+		if len(files) >= maxFiles {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Index caches List for a root and refreshes it when it gets stale, so a
+// picker can search on every keystroke. A watched index takes its listing
+// from a Watcher instead.
+type Index struct {
+	root string
+
+	mu       sync.Mutex
+	files    []string
+	loadedAt time.Time
+	watched  bool
+}
+
+// New creates an index over root. Nothing is listed until the first Search.
+func New(root string) *Index {
+	return &Index{root: root}
+}
+
+// Watch keeps the listing in sync with w; Files no longer lists on its own.
+func (x *Index) Watch(w *Watcher) {
+	w.Subscribe(func(files []string, _ []Change) {
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		x.files, x.loadedAt, x.watched = files, time.Now(), true
+	})
+}
+
+// Search returns up to limit files matching query, best first.
+func (x *Index) Search(query string, limit int) []string {
+	return Rank(query, x.Files(), limit)
+}
+
+// Files returns the current listing, refreshing it when stale. Listing errors
+// yield the previous listing.
+func (x *Index) Files() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.files == nil || (!x.watched && time.Since(x.loadedAt) > refreshAfter) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if files, err := List(ctx, x.root); err == nil {
+			x.files, x.loadedAt = files, time.Now()
+		}
+	}
+	return x.files
+}
+
+// Rank orders files by how well they fuzzy-match query (case-insensitive
+// subsequence) and returns at most limit of them. Matches at the start of a
+// path segment, in the base name, and consecutive runs score higher; shorter
+// paths break ties. An empty query returns the first files in order. A
+// limit <= 0 returns every match.
+func Rank(query string, files []string, limit int) []string {
+	query = strings.ToLower(query)
+	if query == "" {
+		if limit <= 0 || limit > len(files) {
+			limit = len(files)
+		}
+		return slices.Clone(files[:limit])
+	}
+	type scored struct {
+		path  string
+		score int
+	}
+	var matches []scored
+	for _, path := range files {
+		if score, ok := Score(query, path); ok {
+			matches = append(matches, scored{path, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return len(matches[i].path) < len(matches[j].path)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.path
+	}
+	return out
+}
+
+// Score reports whether the lower-case query is a subsequence of path and how
+// well it matches.
+func Score(query, path string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	target := []rune(strings.ToLower(path))
+	base := utf8.RuneCountInString(path[:strings.LastIndexByte(path, '/')+1])
+	q := []rune(query)
+
+	score, qi, prev := 0, 0, -2
+	for ti := 0; ti < len(target) && qi < len(q); ti++ {
+		if target[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 5
+		}
+		if ti == 0 || isBoundary(target[ti-1]) {
+			score += 8
+		}
+		if ti >= base {
+			score += 2
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+func isBoundary(r rune) bool {
+	return r == '/' || r == '_' || r == '-' || r == '.' || unicode.IsSpace(r)
+}
@@ -0,0 +1,102 @@
+package fileindex
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, rels ...string) {
+	t.Helper()
+	for _, rel := range rels {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestList_RespectsGitignore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	if out, err := exec.Command("git", "-C", root, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	writeFiles(t, root, ".gitignore", "main.go", "pkg/a.go", "build/out.bin")
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("build/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := List(context.Background(), root)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{".gitignore", "main.go", "pkg/a.go"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestList_WalkSkipsDependencyDirs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "main.go", "node_modules/x/index.js", "vendor/y/y.go", "pkg/a.go")
+
+	got, err := walkFiles(root)
+	if err != nil {
+		t.Fatalf("walkFiles() error = %v", err)
+	}
+	want := []string{"main.go", "pkg/a.go"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("walkFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestRank_PrefersSegmentStartsAndBaseNames(t *testing.T) {
+	files := []string{
+		"docs/loop_notes.md",
+		"pkg/loop/agent.go",
+		"pkg/llm/options.go",
+		"README.md",
+	}
+
+	got := Rank("loag", files, 0)
+	if len(got) == 0 || got[0] != "pkg/loop/agent.go" {
+		t.Fatalf("Rank(loag) = %v, want pkg/loop/agent.go first", got)
+	}
+	if got := Rank("xyz", files, 0); len(got) != 0 {
+		t.Fatalf("Rank(xyz) = %v, want no matches", got)
+	}
+	if got := Rank("", files, 2); !slices.Equal(got, files[:2]) {
+		t.Fatalf("Rank(\"\", limit 2) = %v, want first two files", got)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.go", "pkg/loop/agent.go", true},
+		{"*.go", "README.md", false},
+		{"pkg/**/*.go", "pkg/loop/agent.go", true},
+		{"pkg/**/*.go", "pkg/agent.go", true},
+		{"pkg/*.go", "pkg/loop/agent.go", false},
+		{"**/testdata/**", "pkg/x/testdata/a.txt", true},
+		{"cmd/*/main.go", "cmd/agent-server/main.go", true},
+		{"", "anything", true},
+		{"[", "x", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
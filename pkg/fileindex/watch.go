@@ -0,0 +1,260 @@
+package fileindex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often a Watcher rescans, when none is given,
+// if OS file notifications are unavailable.
+const DefaultPollInterval = 2 * time.Second
+
+// settleDelay is how long a Watcher waits after a file event for more to
+// arrive, so a save, a checkout or a build costs one rescan.
+const settleDelay = 100 * time.Millisecond
+
+// Op is the kind of change a Watcher reports.
+type Op int
+
+const (
+	Created Op = iota + 1
+	Modified
+	Removed
+)
+
+func (op Op) String() string {
+	switch op {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Change is one file that appeared, changed or disappeared between polls.
+type Change struct {
+	Path string
+	Op   Op
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher keeps a listing of root up to date in the background and tells
+// subscribers which files changed, so indexes built on top of it can update
+// incrementally instead of rescanning the tree on every query.
+//
+// Run watches the directories of the listed files with OS notifications
+// (fsnotify) and rescans shortly after something changes, so changes are
+// seen within settleDelay and an idle tree costs no CPU. A rescan lists
+// root with List and compares size and modification time, so ignored files
+// are left out the same way List leaves them out. Where notifications are
+// unavailable (e.g. the inotify watch limit is reached) Run falls back to
+// rescanning every interval.
+type Watcher struct {
+	root     string
+	interval time.Duration
+
+	// pollMu serializes polls; mu guards the fields below it.
+	pollMu sync.Mutex
+	mu     sync.Mutex
+	state  map[string]fileState
+	files  []string
+	polled bool
+	subs   []func(files []string, changes []Change)
+}
+
+// NewWatcher creates a watcher over root; interval <= 0 uses
+// DefaultPollInterval. Nothing is scanned until Poll or Run.
+func NewWatcher(root string, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &Watcher{root: root, interval: interval}
+}
+
+// Subscribe registers fn to receive the full listing and the changes after
+// every poll that found some. The first poll establishes the baseline and
+// calls fn with no changes; fn runs on the polling goroutine.
+func (w *Watcher) Subscribe(fn func(files []string, changes []Change)) {
+	w.mu.Lock()
+	w.subs = append(w.subs, fn)
+	files, polled := w.files, w.polled
+	w.mu.Unlock()
+	if polled {
+		fn(files, nil)
+	}
+}
+
+// Files returns the listing from the last poll.
+func (w *Watcher) Files() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.files
+}
+
+// Run keeps the listing up to date until ctx is done, rescanning after OS
+// file notifications or, without them, every interval.
+func (w *Watcher) Run(ctx context.Context) {
+	// Listing errors (e.g. a transient git failure) wait for the next event.
+	_, _ = w.Poll(ctx)
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.poll(ctx)
+		return
+	}
+	defer notifier.Close()
+	watched := make(map[string]bool)
+	if !w.watchDirs(notifier, watched) {
+		w.poll(ctx)
+		return
+	}
+	// Catch what changed before the watches were in place.
+	_, _ = w.Poll(ctx)
+
+	settle := time.NewTimer(settleDelay)
+	settle.Stop()
+	defer settle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				delete(watched, event.Name)
+			}
+			// Files created in a new directory are only listed once it
+			// has some, so watch it right away.
+			if event.Has(fsnotify.Create) && !skipDirs[filepath.Base(event.Name)] {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && notifier.Add(event.Name) == nil {
+					watched[event.Name] = true
+				}
+			}
+			settle.Reset(settleDelay)
+		case <-notifier.Errors:
+			// Events were lost (e.g. the queue overflowed): rescan.
+			settle.Reset(0)
+		case <-settle.C:
+			_, _ = w.Poll(ctx)
+			if !w.watchDirs(notifier, watched) {
+				notifier.Close()
+				w.poll(ctx)
+				return
+			}
+		}
+	}
+}
+
+// poll rescans every interval until ctx is done.
+func (w *Watcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = w.Poll(ctx)
+		}
+	}
+}
+
+// watchDirs adds notifier watches for root and every directory holding a
+// listed file that watched does not have yet. It reports false when the
+// OS refuses a watch.
+func (w *Watcher) watchDirs(notifier *fsnotify.Watcher, watched map[string]bool) bool {
+	dirs := map[string]bool{filepath.Clean(w.root): true}
+	for _, rel := range w.Files() {
+		for dir := filepath.Dir(filepath.FromSlash(rel)); dir != "."; dir = filepath.Dir(dir) {
+			dirs[filepath.Join(w.root, dir)] = true
+		}
+	}
+	for dir := range dirs {
+		if watched[dir] {
+			continue
+		}
+		if err := notifier.Add(dir); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false
+		}
+		watched[dir] = true
+	}
+	return true
+}
+
+// Poll rescans root once, notifies subscribers and returns the changes since
+// the previous poll (none on the first).
+func (w *Watcher) Poll(ctx context.Context) ([]Change, error) {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	files, err := List(ctx, w.root)
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]fileState, len(files))
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(w.root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		state[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	w.mu.Lock()
+	var changes []Change
+	if w.polled {
+		for _, rel := range files {
+			now, ok := state[rel]
+			if !ok {
+				continue
+			}
+			if before, seen := w.state[rel]; !seen {
+				changes = append(changes, Change{Path: rel, Op: Created})
+			} else if before != now {
+				changes = append(changes, Change{Path: rel, Op: Modified})
+			}
+		}
+		for _, rel := range sortedKeys(w.state) {
+			if _, ok := state[rel]; !ok {
+				changes = append(changes, Change{Path: rel, Op: Removed})
+			}
+		}
+	}
+	first := !w.polled
+	w.state, w.files, w.polled = state, files, true
+	subs := w.subs
+	w.mu.Unlock()
+
+	if first || len(changes) > 0 {
+		for _, fn := range subs {
+			fn(files, changes)
+		}
+	}
+	return changes, nil
+}
+
+func sortedKeys(m map[string]fileState) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package fileindex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatcher_ReportsChangesSinceLastPoll(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "keep.go", "edit.go", "gone.go")
+
+	w := NewWatcher(root, 0)
+	var calls [][]Change
+	w.Subscribe(func(_ []string, changes []Change) { calls = append(calls, changes) })
+
+	ctx := context.Background()
+	if changes, err := w.Poll(ctx); err != nil || len(changes) != 0 {
+		t.Fatalf("first Poll() = %v, %v; want baseline without changes", changes, err)
+	}
+
+	writeFiles(t, root, "new.go")
+	if err := os.WriteFile(filepath.Join(root, "edit.go"), []byte("package edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := w.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	want := []Change{{"edit.go", Modified}, {"new.go", Created}, {"gone.go", Removed}}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("Poll() = %v, want %v", changes, want)
+	}
+	if got := w.Files(); !slices.Equal(got, []string{"edit.go", "keep.go", "new.go"}) {
+		t.Fatalf("Files() = %v", got)
+	}
+
+	// A poll without changes does not notify.
+	if _, err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != nil || !slices.Equal(calls[1], want) {
+		t.Fatalf("subscriber calls = %v", calls)
+	}
+}
+
+func TestWatcher_RunRescansOnFileEvents(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a.go", "sub/b.go")
+
+	// With an hour between polls, only file notifications can report the
+	// changes in time.
+	w := NewWatcher(root, time.Hour)
+	notified := make(chan []Change, 16)
+	w.Subscribe(func(_ []string, changes []Change) { notified <- changes })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	next := func() []Change {
+		t.Helper()
+		select {
+		case changes := <-notified:
+			return changes
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification within 5s")
+			return nil
+		}
+	}
+	if changes := next(); changes != nil {
+		t.Fatalf("baseline = %v", changes)
+	}
+	writeFiles(t, root, "sub/c.go")
+	if changes := next(); !slices.Equal(changes, []Change{{"sub/c.go", Created}}) {
+		t.Fatalf("changes = %v", changes)
+	}
+	writeFiles(t, root, "new/d.go")
+	if changes := next(); !slices.Equal(changes, []Change{{"new/d.go", Created}}) {
+		t.Fatalf("changes = %v", changes)
+	}
+	writeFiles(t, root, "new/e.go")
+	if changes := next(); !slices.Equal(changes, []Change{{"new/e.go", Created}}) {
+		t.Fatalf("changes in a directory created while running = %v", changes)
+	}
+}
+
+func TestIndex_WatchUsesWatcherListing(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a.go")
+	w := NewWatcher(root, 0)
+	x := New(root)
+	x.Watch(w)
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, root, "b.go")
+	if got := x.Files(); !slices.Equal(got, []string{"a.go"}) {
+		t.Fatalf("Files() before poll = %v, want the watched listing", got)
+	}
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := x.Files(); !slices.Equal(got, []string{"a.go", "b.go"}) {
+		t.Fatalf("Files() after poll = %v", got)
+	}
+}
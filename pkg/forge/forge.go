@@ -0,0 +1,237 @@
+// Package forge hides which code host the project lives on behind one
+// interface, so the issue and pull request tools and the review command work
+// the same against GitHub, GitLab and Gitea, hosted or self-hosted.
+//
+// GitLab calls pull requests merge requests; this package says pull request
+// for both.
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/github"
+)
+
+// Forge types, as in the forge section of the config.
+const (
+	TypeGitHub = "github"
+	TypeGitLab = "gitlab"
+	TypeGitea  = "gitea"
+)
+
+// Default token variables of GitLab and Gitea; GitHub's is
+// github.DefaultTokenEnv.
+const (
+	DefaultGitLabTokenEnv = "GITLAB_TOKEN"
+	DefaultGiteaTokenEnv  = "GITEA_TOKEN"
+)
+
+// Forge is a code host. Calls take the repository as its path, e.g.
+// "owner/name"; an empty repo means DefaultRepo.
+type Forge interface {
+	// Name is the host's product name for messages, e.g. "GitLab".
+	Name() string
+	DefaultRepo() string
+	// GetIssue fetches an issue and up to maxComments of its comments.
+	GetIssue(ctx context.Context, repo string, number, maxComments int) (Issue, []Comment, error)
+	// ListPRs lists pull requests, newest first.
+	ListPRs(ctx context.Context, repo string, opts ListOptions) ([]PullRequest, error)
+	// CreatePR opens a pull request. An empty Base targets the default
+	// branch.
+	CreatePR(ctx context.Context, repo string, pr NewPullRequest) (PullRequest, error)
+	GetPR(ctx context.Context, repo string, number int) (PullRequest, error)
+	// PRDiff returns the unified diff of a pull request.
+	PRDiff(ctx context.Context, repo string, number int) (string, error)
+	// CreateReview posts review comments on a pull request.
+	CreateReview(ctx context.Context, repo string, number int, review Review) error
+}
+
+// Issue is an issue, or on GitHub and Gitea also the issue side of a pull
+// request.
+type Issue struct {
+	Number    int
+	Title     string
+	Body      string
+	State     string
+	URL       string
+	Author    string
+	Labels    []string
+	Comments  int
+	CreatedAt time.Time
+	// IsPullRequest is set when the issue is a pull request.
+	IsPullRequest bool
+}
+
+// Comment is a comment on an issue.
+type Comment struct {
+	Author    string
+	Body      string
+	CreatedAt time.Time
+}
+
+// PullRequest is a pull request (a merge request on GitLab).
+type PullRequest struct {
+	Number int
+	Title  string
+	Body   string
+	State  string
+	Draft  bool
+	URL    string
+	Author string
+	// Head is the branch with the changes, Base the one it merges into.
+	Head string
+	Base string
+	// HeadSHA is the commit the changes end at.
+	HeadSHA string
+}
+
+// NewPullRequest is the input of CreatePR. Head is the branch with the
+// changes ("branch", or on GitHub "owner:branch" for forks).
+type NewPullRequest struct {
+	Title string
+	Body  string
+	Head  string
+	Base  string
+	Draft bool
+}
+
+// ListOptions filter ListPRs. Zero values mean the forge's defaults.
+type ListOptions struct {
+	State string // open, closed or all
+	Head  string // branch, or owner:branch
+	Base  string
+	Limit int // at most 100
+}
+
+// Review is a set of comments on a pull request: a summary and comments on
+// lines of the new side of the diff, which must be part of it.
+type Review struct {
+	// CommitID is the head commit the comments refer to.
+	CommitID string
+	Body     string
+	Comments []ReviewComment
+}
+
+// ReviewComment is an inline comment on the new side of the diff.
+type ReviewComment struct {
+	Path string
+	Line int
+	Body string
+}
+
+// APIError is a non-2xx response of GitLab or Gitea; GitHub reports
+// *github.APIError.
+type APIError struct {
+	Forge      string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %d %s", strings.ToLower(e.Forge), e.StatusCode, e.Message)
+}
+
+// FromConfig builds the forge of the project config: the one of the forge
+// section, or GitHub as configured in the github section. Without a
+// configured repo it uses the origin remote of the git work tree at root.
+// ok is false when no token is set, so the tools stay unregistered.
+func FromConfig(cfg config.Config, root string) (f Forge, ok bool) {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Forge.Type))
+	if kind == "" || kind == TypeGitHub {
+		client, ok := github.FromConfig(cfg.GitHub, root)
+		if !ok {
+			return nil, false
+		}
+		return GitHub(client), true
+	}
+
+	token := strings.TrimSpace(os.Getenv(TokenEnv(cfg)))
+	if token == "" {
+		return nil, false
+	}
+	repo := strings.Trim(strings.TrimSpace(cfg.Forge.Repo), "/")
+	if repo == "" {
+		repo = RepoFromGit(context.Background(), root)
+	}
+	if kind == TypeGitea {
+		return NewGitea(cfg.Forge.APIURL, token, repo), true
+	}
+	return NewGitLab(cfg.Forge.APIURL, token, repo), true
+}
+
+// TokenEnv names the environment variable the token of the configured
+// forge is read from.
+func TokenEnv(cfg config.Config) string {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Forge.Type))
+	custom := cfg.Forge.TokenEnv
+	if kind == "" || kind == TypeGitHub {
+		custom = cfg.GitHub.TokenEnv
+	}
+	if env := strings.TrimSpace(custom); env != "" {
+		return env
+	}
+	switch kind {
+	case TypeGitLab:
+		return DefaultGitLabTokenEnv
+	case TypeGitea:
+		return DefaultGiteaTokenEnv
+	}
+	return github.DefaultTokenEnv
+}
+
+// RepoFromGit returns the project path of the origin remote, or "" when the
+// remote is missing or has no such path.
+func RepoFromGit(ctx context.Context, dir string) string {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	repo, _ := ParseRemote(strings.TrimSpace(string(out)))
+	return repo
+}
+
+// ParseRemote extracts the project path from a git remote URL such as
+// git@gitlab.com:group/sub/name.git or https://gitea.example.com/owner/name.
+// Unlike github.ParseRemote it keeps GitLab's nested groups.
+func ParseRemote(remote string) (string, bool) {
+	path := ""
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		path = u.Path
+	} else if before, rest, ok := strings.Cut(remote, ":"); ok && strings.Contains(before, "@") {
+		path = rest // scp-like: git@host:group/name.git
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return "", false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// repoOf resolves the repository of a call: repo, or def when it is empty.
+// maxDepth bounds the path segments, 0 meaning any number.
+func repoOf(repo, def string, maxDepth int) (string, error) {
+	repo = strings.Trim(strings.TrimSpace(repo), "/")
+	if repo == "" {
+		repo = def
+	}
+	if repo == "" {
+		return "", fmt.Errorf("no repository: pass owner/name or set forge.repo in the config")
+	}
+	if _, ok := ParseRemote("https://host/" + repo); !ok || (maxDepth > 0 && strings.Count(repo, "/") >= maxDepth) {
+		return "", fmt.Errorf("invalid repository %q: want owner/name", repo)
+	}
+	return repo, nil
+}
@@ -0,0 +1,70 @@
+package forge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+func TestParseRemote_KeepsNestedGroups(t *testing.T) {
+	cases := map[string]string{
+		"git@gitlab.com:group/sub/widgets.git":          "group/sub/widgets",
+		"https://gitlab.example.com/group/widgets":      "group/widgets",
+		"ssh://git@gitea.example.com:2222/acme/widgets": "acme/widgets",
+		"git@github.com:acme/widgets.git":               "acme/widgets",
+		"/srv/git/widgets.git":                          "",
+		"https://gitlab.com/widgets":                    "",
+	}
+	for remote, want := range cases {
+		got, ok := ParseRemote(remote)
+		if got != want || ok != (want != "") {
+			t.Errorf("ParseRemote(%q) = %q, %v; want %q", remote, got, ok, want)
+		}
+	}
+}
+
+func TestFromConfig_PicksForgeAndToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh")
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("ACME_GITEA", "tea")
+
+	f, ok := FromConfig(config.Config{GitHub: config.GitHub{Repo: "acme/widgets"}}, t.TempDir())
+	if !ok || f.Name() != "GitHub" || f.DefaultRepo() != "acme/widgets" {
+		t.Fatalf("github: %v, %v", f, ok)
+	}
+
+	cfg := config.Config{Forge: config.Forge{Type: "gitlab", Repo: "group/sub/widgets"}}
+	if _, ok := FromConfig(cfg, t.TempDir()); ok {
+		t.Fatal("gitlab without GITLAB_TOKEN should have no forge")
+	}
+	if env := TokenEnv(cfg); env != DefaultGitLabTokenEnv {
+		t.Fatalf("TokenEnv = %q", env)
+	}
+	t.Setenv("GITLAB_TOKEN", "lab")
+	f, ok = FromConfig(cfg, t.TempDir())
+	if lab, isLab := f.(*GitLab); !ok || !isLab || lab.DefaultRepo() != "group/sub/widgets" || lab.apiURL != DefaultGitLabAPIURL {
+		t.Fatalf("gitlab: %#v, %v", f, ok)
+	}
+
+	cfg = config.Config{Forge: config.Forge{Type: "Gitea", Repo: "acme/widgets", APIURL: "https://tea.example.com/api/v1/", TokenEnv: "ACME_GITEA"}}
+	f, ok = FromConfig(cfg, t.TempDir())
+	if tea, isTea := f.(*Gitea); !ok || !isTea || tea.apiURL != "https://tea.example.com/api/v1" {
+		t.Fatalf("gitea: %#v, %v", f, ok)
+	}
+}
+
+// newTestServer serves handler and returns its URL.
+func newTestServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
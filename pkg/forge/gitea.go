@@ -0,0 +1,250 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// giteaPageSize is the default largest page of a Gitea instance
+	// (MAX_RESPONSE_ITEMS).
+	giteaPageSize = 50
+	// giteaMaxPages bounds the pages ListPRs reads looking for matches.
+	giteaMaxPages = 10
+)
+
+// Gitea calls the REST API (v1) of a Gitea or Forgejo instance with an
+// access token.
+type Gitea struct {
+	restClient
+	// Repo is the default "owner/name".
+	Repo string
+}
+
+// NewGitea returns a client for apiURL, e.g. https://gitea.example.com/api/v1.
+func NewGitea(apiURL, token, repo string) *Gitea {
+	return &Gitea{
+		restClient: newRESTClient("Gitea", apiURL, func(req *http.Request) { req.Header.Set("Authorization", "token "+token) }),
+		Repo:       repo,
+	}
+}
+
+func (g *Gitea) Name() string        { return "Gitea" }
+func (g *Gitea) DefaultRepo() string { return g.Repo }
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaIssue struct {
+	Number   int       `json:"number"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	State    string    `json:"state"`
+	HTMLURL  string    `json:"html_url"`
+	User     giteaUser `json:"user"`
+	Comments int       `json:"comments"`
+	Labels   []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	CreatedAt   time.Time `json:"created_at"`
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+type giteaRef struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+type giteaPullRequest struct {
+	Number  int       `json:"number"`
+	Title   string    `json:"title"`
+	Body    string    `json:"body"`
+	State   string    `json:"state"`
+	Draft   bool      `json:"draft"`
+	HTMLURL string    `json:"html_url"`
+	User    giteaUser `json:"user"`
+	Head    giteaRef  `json:"head"`
+	Base    giteaRef  `json:"base"`
+}
+
+func (pr giteaPullRequest) pullRequest() PullRequest {
+	return PullRequest{
+		Number:  pr.Number,
+		Title:   pr.Title,
+		Body:    pr.Body,
+		State:   pr.State,
+		Draft:   pr.Draft,
+		URL:     pr.HTMLURL,
+		Author:  pr.User.Login,
+		Head:    pr.Head.Ref,
+		Base:    pr.Base.Ref,
+		HeadSHA: pr.Head.SHA,
+	}
+}
+
+func (g *Gitea) repo(repo string) (string, error) {
+	repo, err := repoOf(repo, g.Repo, 2)
+	if err != nil {
+		return "", err
+	}
+	return "/repos/" + repo, nil
+}
+
+func (g *Gitea) GetIssue(ctx context.Context, repo string, number, maxComments int) (Issue, []Comment, error) {
+	path, err := g.repo(repo)
+	if err != nil {
+		return Issue{}, nil, err
+	}
+	var issue giteaIssue
+	if _, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d", path, number), nil, &issue); err != nil {
+		return Issue{}, nil, err
+	}
+	var comments []Comment
+	if issue.Comments > 0 && maxComments > 0 {
+		var raw []struct {
+			Body      string    `json:"body"`
+			User      giteaUser `json:"user"`
+			CreatedAt time.Time `json:"created_at"`
+		}
+		query := fmt.Sprintf("?page=1&limit=%d", min(maxComments, giteaPageSize))
+		if _, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d/comments%s", path, number, query), nil, &raw); err != nil {
+			return Issue{}, nil, err
+		}
+		for _, c := range raw[:min(len(raw), maxComments)] {
+			comments = append(comments, Comment{Author: c.User.Login, Body: c.Body, CreatedAt: c.CreatedAt})
+		}
+	}
+	labels := make([]string, len(issue.Labels))
+	for i, label := range issue.Labels {
+		labels[i] = label.Name
+	}
+	return Issue{
+		Number:        issue.Number,
+		Title:         issue.Title,
+		Body:          issue.Body,
+		State:         issue.State,
+		URL:           issue.HTMLURL,
+		Author:        issue.User.Login,
+		Labels:        labels,
+		Comments:      issue.Comments,
+		CreatedAt:     issue.CreatedAt,
+		IsPullRequest: issue.PullRequest != nil,
+	}, comments, nil
+}
+
+// ListPRs filters by head and base itself, since Gitea's list endpoint
+// cannot.
+func (g *Gitea) ListPRs(ctx context.Context, repo string, opts ListOptions) ([]PullRequest, error) {
+	path, err := g.repo(repo)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"sort": {"newest"}}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = giteaPageSize
+	}
+	_, head, found := strings.Cut(opts.Head, ":")
+	if !found {
+		head = opts.Head
+	}
+	filtered := head != "" || opts.Base != ""
+	pageSize := giteaPageSize
+	if !filtered {
+		pageSize = min(limit, giteaPageSize)
+	}
+
+	var prs []PullRequest
+	for page := 1; len(prs) < limit && page <= giteaMaxPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(pageSize))
+		var batch []giteaPullRequest
+		if _, err := g.do(ctx, http.MethodGet, path+"/pulls?"+query.Encode(), nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, pr := range batch {
+			if (head == "" || pr.Head.Ref == head) && (opts.Base == "" || pr.Base.Ref == opts.Base) && len(prs) < limit {
+				prs = append(prs, pr.pullRequest())
+			}
+		}
+		if len(batch) < pageSize {
+			break
+		}
+	}
+	return prs, nil
+}
+
+// CreatePR opens a pull request; a draft gets Gitea's "WIP:" title prefix.
+func (g *Gitea) CreatePR(ctx context.Context, repo string, pr NewPullRequest) (PullRequest, error) {
+	path, err := g.repo(repo)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	if pr.Base == "" {
+		var info struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if _, err := g.do(ctx, http.MethodGet, path, nil, &info); err != nil {
+			return PullRequest{}, err
+		}
+		pr.Base = info.DefaultBranch
+	}
+	title := pr.Title
+	if pr.Draft && !strings.HasPrefix(strings.ToUpper(title), "WIP:") {
+		title = "WIP: " + title
+	}
+	in := map[string]any{"title": title, "body": pr.Body, "head": pr.Head, "base": pr.Base}
+	var created giteaPullRequest
+	if _, err := g.do(ctx, http.MethodPost, path+"/pulls", in, &created); err != nil {
+		return PullRequest{}, err
+	}
+	return created.pullRequest(), nil
+}
+
+func (g *Gitea) GetPR(ctx context.Context, repo string, number int) (PullRequest, error) {
+	path, err := g.repo(repo)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	var pr giteaPullRequest
+	if _, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", path, number), nil, &pr); err != nil {
+		return PullRequest{}, err
+	}
+	return pr.pullRequest(), nil
+}
+
+func (g *Gitea) PRDiff(ctx context.Context, repo string, number int) (string, error) {
+	path, err := g.repo(repo)
+	if err != nil {
+		return "", err
+	}
+	var diff rawBody
+	if _, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d.diff", path, number), nil, &diff); err != nil {
+		return "", err
+	}
+	return string(diff), nil
+}
+
+// CreateReview submits one COMMENT review, the inline comments included.
+func (g *Gitea) CreateReview(ctx context.Context, repo string, number int, review Review) error {
+	path, err := g.repo(repo)
+	if err != nil {
+		return err
+	}
+	comments := make([]map[string]any, len(review.Comments))
+	for i, c := range review.Comments {
+		comments[i] = map[string]any{"path": c.Path, "new_position": c.Line, "body": c.Body}
+	}
+	in := map[string]any{"event": "COMMENT", "body": review.Body, "commit_id": review.CommitID, "comments": comments}
+	_, err = g.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/reviews", path, number), in, nil)
+	return err
+}
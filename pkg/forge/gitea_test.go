@@ -0,0 +1,112 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGitea_GetIssueAndDiff(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/issues/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token tok" {
+			t.Errorf("missing token header: %v", r.Header)
+		}
+		writeJSON(w, map[string]any{
+			"number": 7, "title": "Crash", "body": "Steps...", "state": "open", "comments": 1,
+			"user": map[string]any{"login": "alice"}, "labels": []map[string]any{{"name": "bug"}},
+		})
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]any{{"body": "me too", "user": map[string]any{"login": "bob"}}})
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/pulls/4.diff", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("diff --git a/calc.go b/calc.go\n"))
+	})
+	tea := NewGitea(newTestServer(t, mux), "tok", "acme/widgets")
+
+	issue, comments, err := tea.GetIssue(context.Background(), "", 7, 10)
+	if err != nil {
+		t.Fatalf("GetIssue: %v", err)
+	}
+	if issue.Author != "alice" || issue.Labels[0] != "bug" || issue.IsPullRequest || len(comments) != 1 || comments[0].Author != "bob" {
+		t.Fatalf("issue = %+v, comments = %+v", issue, comments)
+	}
+	diff, err := tea.PRDiff(context.Background(), "", 4)
+	if err != nil || diff != "diff --git a/calc.go b/calc.go\n" {
+		t.Fatalf("PRDiff = %q, %v", diff, err)
+	}
+	if _, _, err := tea.GetIssue(context.Background(), "group/sub/widgets", 7, 0); err == nil {
+		t.Fatal("nested repository accepted")
+	}
+}
+
+func TestGitea_ListPRsFiltersBranches(t *testing.T) {
+	pages := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		var page []map[string]any
+		if r.URL.Query().Get("page") == "1" {
+			// A full page without a match, so the next one is read too.
+			for i := range giteaPageSize {
+				page = append(page, map[string]any{"number": 100 + i, "head": map[string]any{"ref": fmt.Sprintf("other-%d", i)}})
+			}
+		} else {
+			page = []map[string]any{
+				{"number": 3, "title": "Add docs", "state": "open", "head": map[string]any{"ref": "docs"}, "base": map[string]any{"ref": "main"}},
+				{"number": 2, "title": "Old docs", "state": "open", "head": map[string]any{"ref": "docs"}, "base": map[string]any{"ref": "v1"}},
+			}
+		}
+		writeJSON(w, page)
+	})
+	tea := NewGitea(newTestServer(t, mux), "tok", "acme/widgets")
+
+	prs, err := tea.ListPRs(context.Background(), "", ListOptions{Head: "acme:docs", Base: "main", Limit: 5})
+	if err != nil {
+		t.Fatalf("ListPRs: %v", err)
+	}
+	if len(prs) != 1 || prs[0].Number != 3 || pages != 2 {
+		t.Fatalf("prs = %+v after %d pages", prs, pages)
+	}
+}
+
+func TestGitea_CreatePRAndReview(t *testing.T) {
+	var created, review map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"default_branch": "main"})
+	})
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{"number": 12, "html_url": "https://tea.example.com/acme/widgets/pulls/12"})
+	})
+	mux.HandleFunc("POST /repos/acme/widgets/pulls/12/reviews", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&review)
+		writeJSON(w, map[string]any{})
+	})
+	tea := NewGitea(newTestServer(t, mux), "tok", "acme/widgets")
+
+	pr, err := tea.CreatePR(context.Background(), "", NewPullRequest{Title: "Fix crash", Head: "fix-7", Draft: true})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.URL != "https://tea.example.com/acme/widgets/pulls/12" || created["base"] != "main" || created["title"] != "WIP: Fix crash" {
+		t.Fatalf("created %+v from %v", pr, created)
+	}
+
+	err = tea.CreateReview(context.Background(), "", 12, Review{CommitID: "abc", Body: "ok", Comments: []ReviewComment{{Path: "calc.go", Line: 5, Body: "why?"}}})
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	comments, _ := review["comments"].([]any)
+	if review["event"] != "COMMENT" || review["commit_id"] != "abc" || len(comments) != 1 {
+		t.Fatalf("review = %v", review)
+	}
+	if c := comments[0].(map[string]any); c["path"] != "calc.go" || c["new_position"] != float64(5) {
+		t.Fatalf("comment = %v", c)
+	}
+}
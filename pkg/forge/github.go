@@ -0,0 +1,102 @@
+package forge
+
+import (
+	"context"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/github"
+)
+
+// GitHub adapts a github.Client to Forge.
+func GitHub(client *github.Client) Forge {
+	return gitHub{client}
+}
+
+type gitHub struct {
+	c *github.Client
+}
+
+func (g gitHub) Name() string        { return "GitHub" }
+func (g gitHub) DefaultRepo() string { return g.c.Repo }
+
+func (g gitHub) GetIssue(ctx context.Context, repo string, number, maxComments int) (Issue, []Comment, error) {
+	issue, comments, err := g.c.GetIssue(ctx, repo, number, maxComments)
+	if err != nil {
+		return Issue{}, nil, err
+	}
+	labels := make([]string, len(issue.Labels))
+	for i, label := range issue.Labels {
+		labels[i] = label.Name
+	}
+	out := make([]Comment, len(comments))
+	for i, c := range comments {
+		out[i] = Comment{Author: c.User.Login, Body: c.Body, CreatedAt: c.CreatedAt}
+	}
+	return Issue{
+		Number:        issue.Number,
+		Title:         issue.Title,
+		Body:          issue.Body,
+		State:         issue.State,
+		URL:           issue.HTMLURL,
+		Author:        issue.User.Login,
+		Labels:        labels,
+		Comments:      issue.Comments,
+		CreatedAt:     issue.CreatedAt,
+		IsPullRequest: issue.PullRequest != nil,
+	}, out, nil
+}
+
+func (g gitHub) ListPRs(ctx context.Context, repo string, opts ListOptions) ([]PullRequest, error) {
+	prs, err := g.c.ListPRs(ctx, repo, github.ListOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PullRequest, len(prs))
+	for i, pr := range prs {
+		out[i] = fromGitHub(pr)
+	}
+	return out, nil
+}
+
+func (g gitHub) CreatePR(ctx context.Context, repo string, pr NewPullRequest) (PullRequest, error) {
+	created, err := g.c.CreatePR(ctx, repo, github.NewPullRequest(pr))
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return fromGitHub(created), nil
+}
+
+func (g gitHub) GetPR(ctx context.Context, repo string, number int) (PullRequest, error) {
+	pr, err := g.c.GetPR(ctx, repo, number)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return fromGitHub(pr), nil
+}
+
+func (g gitHub) PRDiff(ctx context.Context, repo string, number int) (string, error) {
+	return g.c.PRDiff(ctx, repo, number)
+}
+
+// CreateReview submits one COMMENT review, the inline comments included.
+func (g gitHub) CreateReview(ctx context.Context, repo string, number int, review Review) error {
+	out := github.Review{CommitID: review.CommitID, Body: review.Body, Event: "COMMENT"}
+	for _, c := range review.Comments {
+		out.Comments = append(out.Comments, github.ReviewComment{Path: c.Path, Line: c.Line, Side: "RIGHT", Body: c.Body})
+	}
+	return g.c.CreateReview(ctx, repo, number, out)
+}
+
+func fromGitHub(pr github.PullRequest) PullRequest {
+	return PullRequest{
+		Number:  pr.Number,
+		Title:   pr.Title,
+		Body:    pr.Body,
+		State:   pr.State,
+		Draft:   pr.Draft,
+		URL:     pr.HTMLURL,
+		Author:  pr.User.Login,
+		Head:    pr.Head.Ref,
+		Base:    pr.Base.Ref,
+		HeadSHA: pr.Head.SHA,
+	}
+}
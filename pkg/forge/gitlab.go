@@ -0,0 +1,304 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGitLabAPIURL is the REST endpoint of gitlab.com.
+const DefaultGitLabAPIURL = "https://gitlab.com/api/v4"
+
+// gitLabPageSize is the largest page GitLab serves.
+const gitLabPageSize = 100
+
+// GitLab calls the GitLab REST API (v4) with a personal, project or group
+// access token.
+type GitLab struct {
+	restClient
+	// Repo is the default project path, e.g. "group/sub/name".
+	Repo string
+}
+
+// NewGitLab returns a client for apiURL (DefaultGitLabAPIURL when empty).
+func NewGitLab(apiURL, token, repo string) *GitLab {
+	if strings.TrimSpace(apiURL) == "" {
+		apiURL = DefaultGitLabAPIURL
+	}
+	return &GitLab{
+		restClient: newRESTClient("GitLab", apiURL, func(req *http.Request) { req.Header.Set("PRIVATE-TOKEN", token) }),
+		Repo:       repo,
+	}
+}
+
+func (g *GitLab) Name() string        { return "GitLab" }
+func (g *GitLab) DefaultRepo() string { return g.Repo }
+
+type gitLabUser struct {
+	Username string `json:"username"`
+}
+
+type gitLabIssue struct {
+	IID         int        `json:"iid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	WebURL      string     `json:"web_url"`
+	Author      gitLabUser `json:"author"`
+	Labels      []string   `json:"labels"`
+	Notes       int        `json:"user_notes_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type gitLabNote struct {
+	Body      string     `json:"body"`
+	Author    gitLabUser `json:"author"`
+	CreatedAt time.Time  `json:"created_at"`
+	// System notes record events such as label changes.
+	System bool `json:"system"`
+}
+
+type gitLabMergeRequest struct {
+	IID          int        `json:"iid"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	Draft        bool       `json:"draft"`
+	WebURL       string     `json:"web_url"`
+	Author       gitLabUser `json:"author"`
+	SourceBranch string     `json:"source_branch"`
+	TargetBranch string     `json:"target_branch"`
+	SHA          string     `json:"sha"`
+	DiffRefs     struct {
+		BaseSHA  string `json:"base_sha"`
+		HeadSHA  string `json:"head_sha"`
+		StartSHA string `json:"start_sha"`
+	} `json:"diff_refs"`
+}
+
+func (mr gitLabMergeRequest) pullRequest() PullRequest {
+	return PullRequest{
+		Number:  mr.IID,
+		Title:   mr.Title,
+		Body:    mr.Description,
+		State:   mr.State,
+		Draft:   mr.Draft,
+		URL:     mr.WebURL,
+		Author:  mr.Author.Username,
+		Head:    mr.SourceBranch,
+		Base:    mr.TargetBranch,
+		HeadSHA: mr.SHA,
+	}
+}
+
+// project returns the API path of the project of repo.
+func (g *GitLab) project(repo string) (string, error) {
+	repo, err := repoOf(repo, g.Repo, 0)
+	if err != nil {
+		return "", err
+	}
+	return "/projects/" + url.PathEscape(repo), nil
+}
+
+func (g *GitLab) GetIssue(ctx context.Context, repo string, number, maxComments int) (Issue, []Comment, error) {
+	project, err := g.project(repo)
+	if err != nil {
+		return Issue{}, nil, err
+	}
+	var issue gitLabIssue
+	if _, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d", project, number), nil, &issue); err != nil {
+		return Issue{}, nil, err
+	}
+	var comments []Comment
+	if issue.Notes > 0 && maxComments > 0 {
+		var notes []gitLabNote
+		path := fmt.Sprintf("%s/issues/%d/notes?sort=asc&order_by=created_at&per_page=%d", project, number, gitLabPageSize)
+		if _, err := g.do(ctx, http.MethodGet, path, nil, &notes); err != nil {
+			return Issue{}, nil, err
+		}
+		for _, n := range notes {
+			if !n.System && len(comments) < maxComments {
+				comments = append(comments, Comment{Author: n.Author.Username, Body: n.Body, CreatedAt: n.CreatedAt})
+			}
+		}
+	}
+	return Issue{
+		Number:    issue.IID,
+		Title:     issue.Title,
+		Body:      issue.Description,
+		State:     issue.State,
+		URL:       issue.WebURL,
+		Author:    issue.Author.Username,
+		Labels:    issue.Labels,
+		Comments:  issue.Notes,
+		CreatedAt: issue.CreatedAt,
+	}, comments, nil
+}
+
+func (g *GitLab) ListPRs(ctx context.Context, repo string, opts ListOptions) ([]PullRequest, error) {
+	project, err := g.project(repo)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"order_by": {"created_at"}, "sort": {"desc"}}
+	switch opts.State {
+	case "":
+	case "open":
+		query.Set("state", "opened")
+	default:
+		query.Set("state", opts.State)
+	}
+	if opts.Head != "" {
+		// GitLab has no owner:branch form; forks are their own projects.
+		_, branch, found := strings.Cut(opts.Head, ":")
+		if !found {
+			branch = opts.Head
+		}
+		query.Set("source_branch", branch)
+	}
+	if opts.Base != "" {
+		query.Set("target_branch", opts.Base)
+	}
+	if opts.Limit > 0 {
+		query.Set("per_page", strconv.Itoa(min(opts.Limit, gitLabPageSize)))
+	}
+	var mrs []gitLabMergeRequest
+	if _, err := g.do(ctx, http.MethodGet, project+"/merge_requests?"+query.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	prs := make([]PullRequest, len(mrs))
+	for i, mr := range mrs {
+		prs[i] = mr.pullRequest()
+	}
+	return prs, nil
+}
+
+// CreatePR opens a merge request; a draft gets GitLab's "Draft:" title
+// prefix.
+func (g *GitLab) CreatePR(ctx context.Context, repo string, pr NewPullRequest) (PullRequest, error) {
+	project, err := g.project(repo)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	if pr.Base == "" {
+		var info struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if _, err := g.do(ctx, http.MethodGet, project, nil, &info); err != nil {
+			return PullRequest{}, err
+		}
+		pr.Base = info.DefaultBranch
+	}
+	title := pr.Title
+	if pr.Draft && !strings.HasPrefix(strings.ToLower(title), "draft:") {
+		title = "Draft: " + title
+	}
+	in := map[string]any{
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+		"title":         title,
+		"description":   pr.Body,
+	}
+	var created gitLabMergeRequest
+	if _, err := g.do(ctx, http.MethodPost, project+"/merge_requests", in, &created); err != nil {
+		return PullRequest{}, err
+	}
+	return created.pullRequest(), nil
+}
+
+func (g *GitLab) GetPR(ctx context.Context, repo string, number int) (PullRequest, error) {
+	mr, err := g.mergeRequest(ctx, repo, number)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return mr.pullRequest(), nil
+}
+
+func (g *GitLab) mergeRequest(ctx context.Context, repo string, number int) (gitLabMergeRequest, error) {
+	project, err := g.project(repo)
+	if err != nil {
+		return gitLabMergeRequest{}, err
+	}
+	var mr gitLabMergeRequest
+	_, err = g.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", project, number), nil, &mr)
+	return mr, err
+}
+
+// PRDiff assembles a unified diff from the per-file diffs GitLab returns.
+func (g *GitLab) PRDiff(ctx context.Context, repo string, number int) (string, error) {
+	project, err := g.project(repo)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for page := 1; ; page++ {
+		var files []struct {
+			OldPath     string `json:"old_path"`
+			NewPath     string `json:"new_path"`
+			Diff        string `json:"diff"`
+			NewFile     bool   `json:"new_file"`
+			DeletedFile bool   `json:"deleted_file"`
+		}
+		path := fmt.Sprintf("%s/merge_requests/%d/diffs?page=%d&per_page=%d", project, number, page, gitLabPageSize)
+		header, err := g.do(ctx, http.MethodGet, path, nil, &files)
+		if err != nil {
+			return "", err
+		}
+		for _, f := range files {
+			oldName, newName := "a/"+f.OldPath, "b/"+f.NewPath
+			if f.NewFile {
+				oldName = "/dev/null"
+			}
+			if f.DeletedFile {
+				newName = "/dev/null"
+			}
+			fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- %s\n+++ %s\n%s", f.OldPath, f.NewPath, oldName, newName, f.Diff)
+			if f.Diff != "" && !strings.HasSuffix(f.Diff, "\n") {
+				b.WriteString("\n")
+			}
+		}
+		if header.Get("X-Next-Page") == "" || len(files) < gitLabPageSize {
+			return b.String(), nil
+		}
+	}
+}
+
+// CreateReview starts a discussion on each commented line, then posts the
+// body as a note on the merge request.
+func (g *GitLab) CreateReview(ctx context.Context, repo string, number int, review Review) error {
+	project, err := g.project(repo)
+	if err != nil {
+		return err
+	}
+	if len(review.Comments) > 0 {
+		mr, err := g.mergeRequest(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		for _, c := range review.Comments {
+			in := map[string]any{
+				"body": c.Body,
+				"position": map[string]any{
+					"position_type": "text",
+					"base_sha":      mr.DiffRefs.BaseSHA,
+					"start_sha":     mr.DiffRefs.StartSHA,
+					"head_sha":      mr.DiffRefs.HeadSHA,
+					"new_path":      c.Path,
+					"new_line":      c.Line,
+				},
+			}
+			if _, err := g.do(ctx, http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/discussions", project, number), in, nil); err != nil {
+				return fmt.Errorf("comment on %s:%d: %w", c.Path, c.Line, err)
+			}
+		}
+	}
+	if strings.TrimSpace(review.Body) == "" {
+		return nil
+	}
+	_, err = g.do(ctx, http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/notes", project, number), map[string]any{"body": review.Body}, nil)
+	return err
+}
@@ -0,0 +1,164 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// gitLabMux routes on the escaped path, since the project path is one
+// %2F-encoded segment.
+type gitLabMux map[string]http.HandlerFunc
+
+func (m gitLabMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m[r.Method+" "+r.URL.EscapedPath()]; ok {
+		h(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"message":"404 Not found"}`))
+}
+
+const gitLabProject = "/projects/group%2Fsub%2Fwidgets"
+
+func TestGitLab_GetIssueSkipsSystemNotes(t *testing.T) {
+	url := newTestServer(t, gitLabMux{
+		"GET " + gitLabProject + "/issues/7": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("PRIVATE-TOKEN") != "tok" {
+				t.Errorf("missing token header: %v", r.Header)
+			}
+			writeJSON(w, map[string]any{
+				"iid": 7, "title": "Crash on empty input", "description": "Steps...", "state": "opened",
+				"web_url": "https://gitlab.example.com/group/sub/widgets/-/issues/7", "author": map[string]any{"username": "alice"},
+				"labels": []string{"bug"}, "user_notes_count": 2,
+			})
+		},
+		"GET " + gitLabProject + "/issues/7/notes": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []map[string]any{
+				{"body": "added ~bug label", "system": true, "author": map[string]any{"username": "alice"}},
+				{"body": "me too", "author": map[string]any{"username": "bob"}},
+			})
+		},
+	})
+	lab := NewGitLab(url, "tok", "group/sub/widgets")
+
+	issue, comments, err := lab.GetIssue(context.Background(), "", 7, 10)
+	if err != nil {
+		t.Fatalf("GetIssue: %v", err)
+	}
+	if issue.Number != 7 || issue.Author != "alice" || issue.Labels[0] != "bug" || issue.Body != "Steps..." {
+		t.Fatalf("issue = %+v", issue)
+	}
+	if len(comments) != 1 || comments[0].Author != "bob" {
+		t.Fatalf("comments = %+v", comments)
+	}
+
+	_, _, err = lab.GetIssue(context.Background(), "", 8, 10)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Error() != "gitlab: 404 404 Not found" {
+		t.Fatalf("missing issue: %v", err)
+	}
+}
+
+func TestGitLab_ListAndCreateMergeRequests(t *testing.T) {
+	var created map[string]any
+	url := newTestServer(t, gitLabMux{
+		"GET " + gitLabProject + "/merge_requests": func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("state") != "opened" || q.Get("source_branch") != "docs" || q.Get("per_page") != "5" {
+				t.Errorf("query = %q", r.URL.RawQuery)
+			}
+			writeJSON(w, []map[string]any{{
+				"iid": 3, "title": "Add docs", "state": "opened", "draft": true,
+				"source_branch": "docs", "target_branch": "main", "author": map[string]any{"username": "alice"},
+			}})
+		},
+		"GET " + gitLabProject: func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"default_branch": "trunk"})
+		},
+		"POST " + gitLabProject + "/merge_requests": func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(w, map[string]any{"iid": 12, "web_url": "https://gitlab.example.com/group/sub/widgets/-/merge_requests/12"})
+		},
+	})
+	lab := NewGitLab(url, "tok", "group/sub/widgets")
+
+	prs, err := lab.ListPRs(context.Background(), "", ListOptions{State: "open", Head: "acme:docs", Limit: 5})
+	if err != nil {
+		t.Fatalf("ListPRs: %v", err)
+	}
+	if len(prs) != 1 || prs[0].Number != 3 || !prs[0].Draft || prs[0].Head != "docs" || prs[0].Base != "main" {
+		t.Fatalf("prs = %+v", prs)
+	}
+
+	pr, err := lab.CreatePR(context.Background(), "", NewPullRequest{Title: "Fix crash", Body: "Closes #7", Head: "fix-7", Draft: true})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 12 || created["target_branch"] != "trunk" || created["source_branch"] != "fix-7" || created["title"] != "Draft: Fix crash" {
+		t.Fatalf("created %+v from %v", pr, created)
+	}
+}
+
+func TestGitLab_DiffAndReview(t *testing.T) {
+	var discussions []map[string]any
+	var note map[string]any
+	url := newTestServer(t, gitLabMux{
+		"GET " + gitLabProject + "/merge_requests/4/diffs": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []map[string]any{
+				{"old_path": "calc.go", "new_path": "calc.go", "diff": "@@ -1,1 +1,2 @@\n package calc\n+var x = 1\n"},
+				{"old_path": "new.go", "new_path": "new.go", "new_file": true, "diff": "@@ -0,0 +1 @@\n+package calc"},
+			})
+		},
+		"GET " + gitLabProject + "/merge_requests/4": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"iid": 4, "sha": "head1", "diff_refs": map[string]any{"base_sha": "base1", "start_sha": "start1", "head_sha": "head1"}})
+		},
+		"POST " + gitLabProject + "/merge_requests/4/discussions": func(w http.ResponseWriter, r *http.Request) {
+			var d map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&d)
+			discussions = append(discussions, d)
+			writeJSON(w, map[string]any{})
+		},
+		"POST " + gitLabProject + "/merge_requests/4/notes": func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&note)
+			writeJSON(w, map[string]any{})
+		},
+	})
+	lab := NewGitLab(url, "tok", "group/sub/widgets")
+
+	diff, err := lab.PRDiff(context.Background(), "", 4)
+	if err != nil {
+		t.Fatalf("PRDiff: %v", err)
+	}
+	want := "diff --git a/calc.go b/calc.go\n--- a/calc.go\n+++ b/calc.go\n@@ -1,1 +1,2 @@\n package calc\n+var x = 1\n" +
+		"diff --git a/new.go b/new.go\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package calc\n"
+	if diff != want {
+		t.Fatalf("diff:\n%s\nwant:\n%s", diff, want)
+	}
+
+	err = lab.CreateReview(context.Background(), "", 4, Review{Body: "Looks risky.", Comments: []ReviewComment{{Path: "calc.go", Line: 2, Body: "why?"}}})
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	if len(discussions) != 1 || discussions[0]["body"] != "why?" {
+		t.Fatalf("discussions = %v", discussions)
+	}
+	position, _ := discussions[0]["position"].(map[string]any)
+	if position["base_sha"] != "base1" || position["head_sha"] != "head1" || position["new_path"] != "calc.go" || position["new_line"] != float64(2) {
+		t.Fatalf("position = %v", position)
+	}
+	if note["body"] != "Looks risky." {
+		t.Fatalf("note = %v", note)
+	}
+}
+
+func TestGitLab_RejectsMissingRepo(t *testing.T) {
+	_, err := NewGitLab("", "tok", "").GetPR(context.Background(), "", 1)
+	if err == nil || !strings.Contains(err.Error(), "no repository") {
+		t.Fatalf("err = %v", err)
+	}
+}
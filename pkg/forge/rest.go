@@ -0,0 +1,101 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxErrorBody = 64 << 10
+
+// restClient is the JSON-over-HTTP plumbing GitLab and Gitea share.
+type restClient struct {
+	forge  string
+	apiURL string
+	// auth sets the token header of a request.
+	auth func(req *http.Request)
+	HTTP *http.Client
+}
+
+func newRESTClient(forge, apiURL string, auth func(req *http.Request)) restClient {
+	return restClient{
+		forge:  forge,
+		apiURL: strings.TrimRight(apiURL, "/"),
+		auth:   auth,
+		HTTP:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// rawBody asks do for the response as-is, e.g. a diff instead of JSON.
+type rawBody []byte
+
+// do sends in as JSON and decodes the response into out, which may be nil.
+// It returns the response headers, for pagination.
+func (c *restClient) do(ctx context.Context, method, path string, in, out any) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if _, raw := out.(*rawBody); !raw {
+		req.Header.Set("Accept", "application/json")
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth(req)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &APIError{Forge: c.forge, StatusCode: resp.StatusCode, Message: errorMessage(data, resp.Status)}
+	}
+	switch out := out.(type) {
+	case nil:
+		return resp.Header, nil
+	case *rawBody:
+		*out, err = io.ReadAll(resp.Body)
+		return resp.Header, err
+	default:
+		return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+// errorMessage extracts the message of an error response. GitLab sends
+// "message" as a string, a list or an object of field errors, or "error";
+// Gitea sends a string "message".
+func errorMessage(body []byte, status string) string {
+	var payload struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return status
+	}
+	var text string
+	if json.Unmarshal(payload.Message, &text) == nil && text != "" {
+		return text
+	}
+	if len(payload.Message) > 0 && string(payload.Message) != "null" {
+		return string(payload.Message)
+	}
+	if payload.Error != "" {
+		return payload.Error
+	}
+	return status
+}
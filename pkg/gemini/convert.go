@@ -0,0 +1,534 @@
+package gemini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chatRequest is the part of an OpenAI chat completion request Gemini can
+// honor.
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []struct {
+		Function struct {
+			Name        string         `json:"name"`
+			Description string         `json:"description"`
+			Parameters  map[string]any `json:"parameters"`
+		} `json:"function"`
+	} `json:"tools"`
+	ToolChoice          json.RawMessage `json:"tool_choice"`
+	MaxTokens           *int64          `json:"max_tokens"`
+	MaxCompletionTokens *int64          `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"`
+	ResponseFormat      *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Stream        bool `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []chatToolCall  `json:"tool_calls"`
+	ToolCallID string          `json:"tool_call_id"`
+}
+
+type chatToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Gemini's generateContent request and response.
+type (
+	generateRequest struct {
+		Contents          []content         `json:"contents"`
+		SystemInstruction *content          `json:"systemInstruction,omitempty"`
+		Tools             []tool            `json:"tools,omitempty"`
+		ToolConfig        *toolConfig       `json:"toolConfig,omitempty"`
+		GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+	}
+	content struct {
+		Role  string `json:"role,omitempty"`
+		Parts []part `json:"parts"`
+	}
+	part struct {
+		Text             string            `json:"text,omitempty"`
+		Thought          bool              `json:"thought,omitempty"`
+		ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+		InlineData       *inlineData       `json:"inlineData,omitempty"`
+		FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+		FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+	}
+	inlineData struct {
+		MimeType string `json:"mimeType"`
+		Data     string `json:"data"`
+	}
+	functionCall struct {
+		ID   string         `json:"id,omitempty"`
+		Name string         `json:"name"`
+		Args map[string]any `json:"args"`
+	}
+	functionResponse struct {
+		Name     string         `json:"name"`
+		Response map[string]any `json:"response"`
+	}
+	tool struct {
+		FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+	}
+	functionDeclaration struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Parameters  map[string]any `json:"parameters,omitempty"`
+	}
+	toolConfig struct {
+		FunctionCallingConfig struct {
+			Mode                 string   `json:"mode"`
+			AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+		} `json:"functionCallingConfig"`
+	}
+	generationConfig struct {
+		MaxOutputTokens  *int64   `json:"maxOutputTokens,omitempty"`
+		Temperature      *float64 `json:"temperature,omitempty"`
+		TopP             *float64 `json:"topP,omitempty"`
+		StopSequences    []string `json:"stopSequences,omitempty"`
+		ResponseMimeType string   `json:"responseMimeType,omitempty"`
+	}
+
+	generateResponse struct {
+		Candidates []struct {
+			Content      content `json:"content"`
+			FinishReason string  `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback *struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata *usageMetadata `json:"usageMetadata"`
+		ResponseID    string         `json:"responseId"`
+	}
+	usageMetadata struct {
+		PromptTokenCount        int64 `json:"promptTokenCount"`
+		CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+		CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+		TotalTokenCount         int64 `json:"totalTokenCount"`
+	}
+)
+
+// maxSignatures bounds the thought signatures kept for replay.
+const maxSignatures = 4096
+
+// translator converts between the two APIs. Gemini signs the reasoning that
+// led to a function call and wants the signature back when the call is
+// replayed in history; the OpenAI shape has no room for it, so it is kept
+// here by tool call ID.
+type translator struct {
+	cfg Config
+
+	mu         sync.Mutex
+	signatures map[string]string
+}
+
+func newTranslator(cfg Config) *translator {
+	return &translator{cfg: cfg, signatures: map[string]string{}}
+}
+
+func (t *translator) remember(id, signature string) {
+	if signature == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.signatures) >= maxSignatures {
+		clear(t.signatures)
+	}
+	t.signatures[id] = signature
+}
+
+func (t *translator) signature(id string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.signatures[id]
+}
+
+// request converts a chat completion request.
+func (t *translator) request(chat chatRequest) (generateRequest, error) {
+	var req generateRequest
+	var system []part
+	toolNames := map[string]string{}
+	for i, msg := range chat.Messages {
+		texts, images, err := contentParts(msg.Content)
+		if err != nil {
+			return req, fmt.Errorf("message %d: %w", i, err)
+		}
+		switch msg.Role {
+		case "system", "developer":
+			system = append(system, texts...)
+		case "user":
+			req.Contents = appendContent(req.Contents, "user", append(texts, images...))
+		case "assistant":
+			parts := texts
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				args := map[string]any{}
+				if strings.TrimSpace(call.Function.Arguments) != "" {
+					if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+						return req, fmt.Errorf("message %d: arguments of %s: %w", i, call.Function.Name, err)
+					}
+				}
+				parts = append(parts, part{
+					FunctionCall:     &functionCall{Name: call.Function.Name, Args: args},
+					ThoughtSignature: t.signature(call.ID),
+				})
+			}
+			req.Contents = appendContent(req.Contents, "model", parts)
+		case "tool":
+			output := ""
+			for _, p := range texts {
+				output += p.Text
+			}
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				return req, fmt.Errorf("message %d: tool result for unknown call %q", i, msg.ToolCallID)
+			}
+			req.Contents = appendContent(req.Contents, "user", []part{{FunctionResponse: &functionResponse{
+				Name:     name,
+				Response: map[string]any{"content": output},
+			}}})
+		default:
+			return req, fmt.Errorf("message %d: unsupported role %q", i, msg.Role)
+		}
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &content{Parts: system}
+	}
+
+	var declarations []functionDeclaration
+	for _, tl := range chat.Tools {
+		decl := functionDeclaration{Name: tl.Function.Name, Description: tl.Function.Description}
+		if props, _ := tl.Function.Parameters["properties"].(map[string]any); len(props) > 0 {
+			decl.Parameters = convertSchema(tl.Function.Parameters)
+		}
+		declarations = append(declarations, decl)
+	}
+	if len(declarations) > 0 {
+		req.Tools = []tool{{FunctionDeclarations: declarations}}
+	}
+	req.ToolConfig = convertToolChoice(chat.ToolChoice)
+
+	gen := generationConfig{Temperature: chat.Temperature, TopP: chat.TopP, MaxOutputTokens: chat.MaxCompletionTokens}
+	if gen.MaxOutputTokens == nil {
+		gen.MaxOutputTokens = chat.MaxTokens
+	}
+	if len(chat.Stop) > 0 {
+		var one string
+		if json.Unmarshal(chat.Stop, &one) == nil {
+			gen.StopSequences = []string{one}
+		} else {
+			_ = json.Unmarshal(chat.Stop, &gen.StopSequences)
+		}
+	}
+	if chat.ResponseFormat != nil && chat.ResponseFormat.Type != "text" && chat.ResponseFormat.Type != "" {
+		gen.ResponseMimeType = "application/json"
+	}
+	if gen.MaxOutputTokens != nil || gen.Temperature != nil || gen.TopP != nil || len(gen.StopSequences) > 0 || gen.ResponseMimeType != "" {
+		req.GenerationConfig = &gen
+	}
+	return req, nil
+}
+
+// appendContent adds parts to the conversation, merging them into the last
+// content when the role repeats: Gemini wants every result of one turn's
+// function calls in a single content.
+func appendContent(contents []content, role string, parts []part) []content {
+	if len(parts) == 0 {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, content{Role: role, Parts: parts})
+}
+
+// contentParts reads message content, a string or a list of parts.
+func contentParts(raw json.RawMessage) (texts, images []part, err error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil, nil
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		if text == "" {
+			return nil, nil, nil
+		}
+		return []part{{Text: text}}, nil, nil
+	}
+	var list []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		ImageURL struct {
+			URL string `json:"url"`
+		} `json:"image_url"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, nil, fmt.Errorf("content: %w", err)
+	}
+	for _, p := range list {
+		switch p.Type {
+		case "text":
+			if p.Text != "" {
+				texts = append(texts, part{Text: p.Text})
+			}
+		case "image_url":
+			mime, data, ok := parseDataURL(p.ImageURL.URL)
+			if !ok {
+				return nil, nil, fmt.Errorf("only data: image URLs are supported")
+			}
+			images = append(images, part{InlineData: &inlineData{MimeType: mime, Data: data}})
+		default:
+			return nil, nil, fmt.Errorf("unsupported content part %q", p.Type)
+		}
+	}
+	return texts, images, nil
+}
+
+// parseDataURL splits data:<mime>;base64,<data>.
+func parseDataURL(u string) (mime, data string, ok bool) {
+	rest, ok := strings.CutPrefix(u, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mime, ok = strings.CutSuffix(meta, ";base64")
+	return mime, data, ok
+}
+
+func convertToolChoice(raw json.RawMessage) *toolConfig {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	cfg := &toolConfig{}
+	var mode string
+	if json.Unmarshal(raw, &mode) == nil {
+		switch mode {
+		case "none":
+			cfg.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			cfg.FunctionCallingConfig.Mode = "ANY"
+		default:
+			cfg.FunctionCallingConfig.Mode = "AUTO"
+		}
+		return cfg
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(raw, &named) != nil || named.Function.Name == "" {
+		return nil
+	}
+	cfg.FunctionCallingConfig.Mode = "ANY"
+	cfg.FunctionCallingConfig.AllowedFunctionNames = []string{named.Function.Name}
+	return cfg
+}
+
+// schemaFormats are the formats Gemini accepts; it rejects the others.
+var schemaFormats = map[string]bool{"enum": true, "date-time": true, "int32": true, "int64": true, "float": true, "double": true}
+
+// convertSchema maps a JSON Schema onto the OpenAPI subset Gemini accepts:
+// type names are upper case, a "null" member of a type list becomes
+// nullable, and keywords it does not know (additionalProperties, $schema,
+// ...) are dropped.
+func convertSchema(s map[string]any) map[string]any {
+	out := map[string]any{}
+	for key, value := range s {
+		switch key {
+		case "type":
+			switch v := value.(type) {
+			case string:
+				out["type"] = strings.ToUpper(v)
+			case []any:
+				for _, member := range v {
+					if name, _ := member.(string); name == "null" {
+						out["nullable"] = true
+					} else if name != "" {
+						out["type"] = strings.ToUpper(name)
+					}
+				}
+			}
+		case "properties":
+			props, _ := value.(map[string]any)
+			converted := make(map[string]any, len(props))
+			for name, prop := range props {
+				if m, ok := prop.(map[string]any); ok {
+					converted[name] = convertSchema(m)
+				}
+			}
+			out["properties"] = converted
+		case "items":
+			if m, ok := value.(map[string]any); ok {
+				out["items"] = convertSchema(m)
+			}
+		case "anyOf":
+			list, _ := value.([]any)
+			var converted []any
+			for _, member := range list {
+				if m, ok := member.(map[string]any); ok {
+					converted = append(converted, convertSchema(m))
+				}
+			}
+			out["anyOf"] = converted
+		case "format":
+			if f, _ := value.(string); schemaFormats[f] {
+				out["format"] = f
+			}
+		case "description", "enum", "required", "nullable", "title",
+			"minimum", "maximum", "minItems", "maxItems", "minLength", "maxLength", "pattern":
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// chatMessageOut is the assistant message of a chat completion.
+type chatMessageOut struct {
+	Role      string         `json:"role,omitempty"`
+	Content   string         `json:"content,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+}
+
+// candidateMessage converts the first candidate of resp; index numbers the
+// tool calls across a stream.
+func (t *translator) candidateMessage(resp generateResponse, index *int) (msg chatMessageOut, finish string) {
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return msg, "content_filter"
+		}
+		return msg, ""
+	}
+	candidate := resp.Candidates[0]
+	var text strings.Builder
+	for _, p := range candidate.Content.Parts {
+		switch {
+		case p.Thought:
+		case p.FunctionCall != nil:
+			id := p.FunctionCall.ID
+			if id == "" {
+				id = newID("call_")
+			}
+			t.remember(id, p.ThoughtSignature)
+			args, _ := json.Marshal(p.FunctionCall.Args)
+			if p.FunctionCall.Args == nil {
+				args = []byte("{}")
+			}
+			call := chatToolCall{ID: id, Type: "function"}
+			call.Function.Name = p.FunctionCall.Name
+			call.Function.Arguments = string(args)
+			if index != nil {
+				i := *index
+				call.Index = &i
+				*index++
+			}
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		default:
+			text.WriteString(p.Text)
+		}
+	}
+	msg.Content = text.String()
+	return msg, finishReason(candidate.FinishReason)
+}
+
+func finishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP", "FINISH_REASON_UNSPECIFIED":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "MALFORMED_FUNCTION_CALL", "UNEXPECTED_TOOL_CALL":
+		return "stop"
+	default:
+		return "content_filter"
+	}
+}
+
+func convertUsage(u *usageMetadata) map[string]any {
+	if u == nil {
+		return nil
+	}
+	return map[string]any{
+		"prompt_tokens":         u.PromptTokenCount,
+		"completion_tokens":     u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		"total_tokens":          u.TotalTokenCount,
+		"prompt_tokens_details": map[string]any{"cached_tokens": u.CachedContentTokenCount},
+	}
+}
+
+// completionResponse converts a generateContent reply into a chat
+// completion.
+func (t *translator) completionResponse(resp *http.Response, model string) (*http.Response, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var gen generateResponse
+	if err := json.Unmarshal(data, &gen); err != nil {
+		return nil, fmt.Errorf("gemini: read response: %w", err)
+	}
+	msg, finish := t.candidateMessage(gen, nil)
+	msg.Role = "assistant"
+	if len(msg.ToolCalls) > 0 && finish == "stop" {
+		finish = "tool_calls"
+	}
+	completion := map[string]any{
+		"id":      completionID(gen.ResponseID),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]any{{"index": 0, "message": msg, "finish_reason": finish}},
+	}
+	if usage := convertUsage(gen.UsageMetadata); usage != nil {
+		completion["usage"] = usage
+	}
+	body, err := json.Marshal(completion)
+	if err != nil {
+		return nil, err
+	}
+	return replaceBody(resp, body, "application/json"), nil
+}
+
+func completionID(responseID string) string {
+	if responseID == "" {
+		return newID("chatcmpl-")
+	}
+	return "chatcmpl-" + responseID
+}
+
+func newID(prefix string) string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return prefix + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,260 @@
+// Package gemini points the OpenAI client at Google's Gemini models, on
+// Vertex AI or through the Gemini API, without an OpenAI-compatible proxy.
+//
+// A request middleware translates every chat completion into a
+// generateContent call (streamGenerateContent when streaming) and the reply
+// back into a chat completion: system messages become the system
+// instruction, tool definitions become function declarations, tool calls
+// and results become functionCall and functionResponse parts. The rest of
+// the agent keeps talking to an *openai.Client.
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	// DefaultModel is used when no model is set.
+	DefaultModel = "gemini-2.5-flash"
+	// DefaultLocation is the Vertex AI region used when none is set.
+	DefaultLocation = "us-central1"
+
+	apiEndpoint = "https://generativelanguage.googleapis.com"
+)
+
+// Config selects the Gemini backend: Vertex AI when Project is set, the
+// Gemini API otherwise.
+type Config struct {
+	Project  string
+	Location string
+	Model    string
+	// APIKey authenticates with the Gemini API. Vertex AI uses Tokens.
+	APIKey string
+	// Tokens supplies OAuth access tokens for Vertex AI; nil means gcloud.
+	Tokens TokenSource
+	// Endpoint overrides the service URL, e.g. for a private endpoint.
+	Endpoint string
+}
+
+// ConfigFromEnv reads GOOGLE_CLOUD_PROJECT, GOOGLE_CLOUD_LOCATION,
+// GEMINI_MODEL, GEMINI_ENDPOINT and the credentials: GEMINI_API_KEY (or GOOGLE_API_KEY) for
+// the Gemini API, GOOGLE_OAUTH_ACCESS_TOKEN for a fixed Vertex AI token.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Project:  strings.TrimSpace(os.Getenv("GOOGLE_CLOUD_PROJECT")),
+		Location: strings.TrimSpace(os.Getenv("GOOGLE_CLOUD_LOCATION")),
+		Model:    strings.TrimSpace(os.Getenv("GEMINI_MODEL")),
+		APIKey:   strings.TrimSpace(os.Getenv("GEMINI_API_KEY")),
+		Endpoint: strings.TrimSpace(os.Getenv("GEMINI_ENDPOINT")),
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = strings.TrimSpace(os.Getenv("GOOGLE_API_KEY"))
+	}
+	if token := strings.TrimSpace(os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")); token != "" {
+		cfg.Tokens = StaticToken(token)
+	}
+	return cfg
+}
+
+// Vertex reports whether cfg targets Vertex AI.
+func (c Config) Vertex() bool {
+	return c.Project != ""
+}
+
+// Validate reports missing settings.
+func (c Config) Validate() error {
+	if c.Endpoint != "" {
+		if _, err := url.ParseRequestURI(c.Endpoint); err != nil {
+			return fmt.Errorf("invalid gemini endpoint %q: %w", c.Endpoint, err)
+		}
+	}
+	if !c.Vertex() && c.APIKey == "" {
+		return fmt.Errorf("gemini credentials are not set (GEMINI_API_KEY, or GOOGLE_CLOUD_PROJECT for Vertex AI)")
+	}
+	return nil
+}
+
+// ModelName returns the model requests go to by default: Model, or
+// DefaultModel.
+func (c Config) ModelName() string {
+	if c.Model == "" {
+		return DefaultModel
+	}
+	return c.Model
+}
+
+// endpoint returns the service URL without a trailing slash.
+func (c Config) endpoint() string {
+	switch {
+	case c.Endpoint != "":
+		return strings.TrimSuffix(c.Endpoint, "/")
+	case !c.Vertex():
+		return apiEndpoint
+	case c.location() == "global":
+		return "https://aiplatform.googleapis.com"
+	default:
+		return "https://" + c.location() + "-aiplatform.googleapis.com"
+	}
+}
+
+func (c Config) location() string {
+	if c.Location == "" {
+		return DefaultLocation
+	}
+	return c.Location
+}
+
+// modelPath returns the path of model's method, e.g. generateContent.
+func (c Config) modelPath(model, method string) string {
+	model = url.PathEscape(model)
+	if c.Vertex() {
+		return fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+			url.PathEscape(c.Project), url.PathEscape(c.location()), model, method)
+	}
+	return fmt.Sprintf("/v1beta/models/%s:%s", model, method)
+}
+
+// NewClient creates an OpenAI client that talks to Gemini. Extra options,
+// e.g. option.WithMiddleware, are applied after the defaults.
+func NewClient(cfg Config, opts ...option.RequestOption) (*openai.Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	defaults := []option.RequestOption{
+		option.WithBaseURL(cfg.endpoint() + "/"),
+		// Never send an OPENAI_API_KEY picked up from the environment.
+		option.WithHeaderDel("authorization"),
+		option.WithMiddleware(newTranslator(cfg).middleware),
+	}
+	if cfg.Vertex() {
+		tokens := cfg.Tokens
+		if tokens == nil {
+			tokens = GCloudTokenSource()
+		}
+		defaults = append(defaults, option.WithMiddleware(bearer(newCachedTokens(tokens))))
+	} else {
+		defaults = append(defaults, option.WithHeader("x-goog-api-key", cfg.APIKey))
+	}
+	client := openai.NewClient(append(defaults, opts...)...)
+	return &client, nil
+}
+
+func bearer(tokens TokenSource) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		token, err := tokens.Token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("gemini: get access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Value)
+		return next(req)
+	}
+}
+
+// middleware sends chat completions to Gemini and rejects every other
+// OpenAI route, which Gemini does not serve.
+func (t *translator) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/chat/completions") || req.Body == nil {
+		return nil, fmt.Errorf("gemini: %s is not supported", req.URL.Path)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var chat chatRequest
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return nil, fmt.Errorf("gemini: read request: %w", err)
+	}
+	payload, err := t.request(chat)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: %w", err)
+	}
+	body, err = json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	model := chat.Model
+	if model == "" {
+		model = t.cfg.ModelName()
+	}
+	method := "generateContent"
+	if chat.Stream {
+		method = "streamGenerateContent"
+	}
+	req.URL.Path = t.cfg.modelPath(model, method)
+	req.URL.RawPath = ""
+	if chat.Stream {
+		query := req.URL.Query()
+		query.Set("alt", "sse")
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return errorResponse(resp)
+	}
+	if chat.Stream {
+		includeUsage := chat.StreamOptions != nil && chat.StreamOptions.IncludeUsage
+		return t.streamResponse(resp, model, includeUsage), nil
+	}
+	return t.completionResponse(resp, model)
+}
+
+// errorResponse rewrites a Google error body into the OpenAI shape, so
+// openai.Error carries its message.
+func errorResponse(resp *http.Response) (*http.Response, error) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var google struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	// Stream errors come as a one-element array.
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []json.RawMessage
+		if json.Unmarshal(trimmed, &list) == nil && len(list) > 0 {
+			trimmed = list[0]
+		}
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(trimmed, &google) == nil && google.Error.Message != "" {
+		message = google.Error.Message
+	}
+	body, _ := json.Marshal(map[string]any{"error": map[string]string{
+		"message": message,
+		"type":    google.Error.Status,
+		"code":    google.Error.Status,
+	}})
+	return replaceBody(resp, body, "application/json"), nil
+}
+
+func replaceBody(resp *http.Response, body []byte, contentType string) *http.Response {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	resp.Header.Set("Content-Type", contentType)
+	return resp
+}
@@ -0,0 +1,196 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type capturedRequest struct {
+	path, query, apiKey, auth string
+	body                      map[string]any
+}
+
+// fakeGemini answers every request with reply, as JSON or, for
+// streamGenerateContent, as the given server-sent events.
+func fakeGemini(t *testing.T, got *[]capturedRequest, status int, reply string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := capturedRequest{path: r.URL.Path, query: r.URL.RawQuery, apiKey: r.Header.Get("x-goog-api-key"), auth: r.Header.Get("Authorization")}
+		_ = json.Unmarshal(data, &req.body)
+		*got = append(*got, req)
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var editTool = openai.ChatCompletionToolParam{Function: openai.FunctionDefinitionParam{
+	Name:        "edit_file",
+	Description: openai.String("Edit a file."),
+	Parameters: openai.FunctionParameters{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"path":  map[string]any{"type": "string", "format": "uri"},
+			"count": map[string]any{"type": []any{"integer", "null"}},
+		},
+		"required": []string{"path"},
+	},
+}}
+
+func TestNewClient_TranslatesToolCalls(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-should-not-leak")
+	var got []capturedRequest
+	server := fakeGemini(t, &got, http.StatusOK, `{
+		"responseId": "r1",
+		"candidates": [{"finishReason": "STOP", "content": {"role": "model", "parts": [
+			{"text": "thinking it over", "thought": true},
+			{"text": "Editing."},
+			{"functionCall": {"name": "edit_file", "args": {"path": "a.go"}}, "thoughtSignature": "sig-1"}
+		]}}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 4, "thoughtsTokenCount": 3, "totalTokenCount": 17}
+	}`)
+	client, err := NewClient(Config{APIKey: "gemini-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are a coding agent."),
+		openai.UserMessage("Fix a.go"),
+	}
+	resp, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    DefaultModel,
+		Messages: messages,
+		Tools:    []openai.ChatCompletionToolParam{editTool},
+	})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "Editing." || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.Function.Name != "edit_file" || call.Function.Arguments != `{"path":"a.go"}` || call.ID == "" {
+		t.Fatalf("tool call = %+v", call)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 7 || resp.Usage.TotalTokens != 17 {
+		t.Fatalf("usage = %+v", resp.Usage)
+	}
+
+	first := got[0]
+	if first.path != "/v1beta/models/gemini-2.5-flash:generateContent" || first.apiKey != "gemini-key" || first.auth != "" {
+		t.Fatalf("request = %+v", first)
+	}
+	if system := jsonString(first.body["systemInstruction"]); !strings.Contains(system, "You are a coding agent.") {
+		t.Fatalf("systemInstruction = %s", system)
+	}
+	decl := first.body["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)[0]
+	if params := jsonString(decl.(map[string]any)["parameters"]); params != `{"properties":{"count":{"nullable":true,"type":"INTEGER"},"path":{"type":"STRING"}},"required":["path"],"type":"OBJECT"}` {
+		t.Fatalf("parameters = %s", params)
+	}
+
+	// The call and its result go back as functionCall and functionResponse,
+	// with the signature Gemini gave the call.
+	messages = append(messages, choice.Message.ToParam(), openai.ToolMessage("edited", call.ID))
+	if _, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{Model: DefaultModel, Messages: messages}); err != nil {
+		t.Fatalf("second chat: %v", err)
+	}
+	want := `[{"parts":[{"text":"Fix a.go"}],"role":"user"},` +
+		`{"parts":[{"text":"Editing."},{"functionCall":{"args":{"path":"a.go"},"name":"edit_file"},"thoughtSignature":"sig-1"}],"role":"model"},` +
+		`{"parts":[{"functionResponse":{"name":"edit_file","response":{"content":"edited"}}}],"role":"user"}]`
+	if contents := jsonString(got[1].body["contents"]); contents != want {
+		t.Fatalf("contents = %s", contents)
+	}
+}
+
+func TestNewClient_StreamsFromVertex(t *testing.T) {
+	var got []capturedRequest
+	server := fakeGemini(t, &got, http.StatusOK,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}]}`+"\n\n"+
+			`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"name":"edit_file","args":{"path":"b.go"}}}]},"finishReason":"STOP"}],`+
+			`"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"totalTokenCount":7}}`+"\n\n")
+	client, err := NewClient(Config{Project: "proj", Location: "europe-west4", Model: "gemini-2.5-pro", Tokens: StaticToken("tok"), Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:         "gemini-2.5-pro",
+		Messages:      []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")},
+		Tools:         []openai.ChatCompletionToolParam{editTool},
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)},
+	})
+	var acc openai.ChatCompletionAccumulator
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	choice := acc.Choices[0]
+	if choice.Message.Content != "Hello" || choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Arguments != `{"path":"b.go"}` {
+		t.Fatalf("choice = %+v", choice)
+	}
+	if acc.Usage.TotalTokens != 7 {
+		t.Fatalf("usage = %+v", acc.Usage)
+	}
+	if r := got[0]; r.path != "/v1/projects/proj/locations/europe-west4/publishers/google/models/gemini-2.5-pro:streamGenerateContent" || r.query != "alt=sse" || r.auth != "Bearer tok" {
+		t.Fatalf("request = %+v", r)
+	}
+}
+
+func TestNewClient_ConvertsErrors(t *testing.T) {
+	var got []capturedRequest
+	server := fakeGemini(t, &got, http.StatusTooManyRequests, `{"error":{"code":429,"message":"Resource exhausted.","status":"RESOURCE_EXHAUSTED"}}`)
+	client, err := NewClient(Config{APIKey: "k", Endpoint: server.URL}, option.WithMaxRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	_, err = client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "m",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")},
+	})
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "Resource exhausted." {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestConfig_Endpoints(t *testing.T) {
+	cases := map[string]Config{
+		"https://generativelanguage.googleapis.com":     {APIKey: "k"},
+		"https://us-central1-aiplatform.googleapis.com": {Project: "p"},
+		"https://aiplatform.googleapis.com":             {Project: "p", Location: "global"},
+	}
+	for want, cfg := range cases {
+		if got := cfg.endpoint(); got != want {
+			t.Errorf("endpoint(%+v) = %s, want %s", cfg, got, want)
+		}
+	}
+	if err := (Config{}).Validate(); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func jsonString(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
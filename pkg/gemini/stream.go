@@ -0,0 +1,93 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxEventBytes bounds one server-sent event of the Gemini stream.
+const maxEventBytes = 8 << 20
+
+// streamResponse converts the server-sent events of streamGenerateContent
+// into chat completion chunks as they arrive.
+func (t *translator) streamResponse(resp *http.Response, model string, includeUsage bool) *http.Response {
+	pr, pw := io.Pipe()
+	upstream := resp.Body
+	go func() {
+		defer upstream.Close()
+		pw.CloseWithError(t.convertStream(upstream, pw, model, includeUsage))
+	}()
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Type", "text/event-stream")
+	return resp
+}
+
+func (t *translator) convertStream(r io.Reader, w io.Writer, model string, includeUsage bool) error {
+	var (
+		id        = newID("chatcmpl-")
+		created   = time.Now().Unix()
+		toolIndex int
+		sentRole  bool
+		usage     *usageMetadata
+	)
+	write := func(chunk map[string]any) error {
+		chunk["id"], chunk["object"], chunk["created"], chunk["model"] = id, "chat.completion.chunk", created, model
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxEventBytes)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		var gen generateResponse
+		if err := json.Unmarshal(bytes.TrimSpace(data), &gen); err != nil {
+			return fmt.Errorf("gemini: read stream: %w", err)
+		}
+		if gen.UsageMetadata != nil {
+			usage = gen.UsageMetadata
+		}
+		msg, finish := t.candidateMessage(gen, &toolIndex)
+		if finish == "stop" && toolIndex > 0 {
+			finish = "tool_calls"
+		}
+		if msg.Content == "" && len(msg.ToolCalls) == 0 && finish == "" {
+			continue
+		}
+		if !sentRole {
+			msg.Role, sentRole = "assistant", true
+		}
+		choice := map[string]any{"index": 0, "delta": msg, "finish_reason": nil}
+		if finish != "" {
+			choice["finish_reason"] = finish
+		}
+		if err := write(map[string]any{"choices": []any{choice}}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("gemini: read stream: %w", err)
+	}
+	if includeUsage && usage != nil {
+		if err := write(map[string]any{"choices": []any{}, "usage": convertUsage(usage)}); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
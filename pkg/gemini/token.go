@@ -0,0 +1,93 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshBefore renews cached tokens this long before they expire.
+	refreshBefore = 5 * time.Minute
+	// gcloudTokenLife is how long a gcloud token is assumed to stay valid.
+	// gcloud does not say, and may hand out a cached token that is already
+	// part way through its hour, so it is asked again well before then.
+	gcloudTokenLife = 15 * time.Minute
+)
+
+// Token is an OAuth access token. A zero ExpiresAt never expires.
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// TokenSource supplies OAuth access tokens for Vertex AI.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenSourceFunc adapts a function to the TokenSource interface.
+type TokenSourceFunc func(ctx context.Context) (Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// StaticToken always returns token, e.g. one obtained by a CI pipeline.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (Token, error) {
+		return Token{Value: token}, nil
+	})
+}
+
+// GCloudTokenSource gets tokens from the Google Cloud CLI (gcloud auth
+// print-access-token), using whatever identity `gcloud auth login`
+// established.
+func GCloudTokenSource() TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (Token, error) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return Token{}, fmt.Errorf("gcloud auth print-access-token: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return Token{}, fmt.Errorf("gcloud auth print-access-token: %w", err)
+		}
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return Token{}, fmt.Errorf("gcloud returned no access token")
+		}
+		return Token{Value: token, ExpiresAt: time.Now().Add(gcloudTokenLife)}, nil
+	})
+}
+
+// cachedTokens reuses a token until shortly before it expires.
+type cachedTokens struct {
+	source TokenSource
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token Token
+}
+
+func newCachedTokens(source TokenSource) *cachedTokens {
+	return &cachedTokens{source: source, now: time.Now}
+}
+
+func (c *cachedTokens) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.Value != "" && (c.token.ExpiresAt.IsZero() || c.now().Add(refreshBefore).Before(c.token.ExpiresAt)) {
+		return c.token, nil
+	}
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	c.token = token
+	return token, nil
+}
@@ -0,0 +1,383 @@
+// Package github is a minimal GitHub REST client for the agent's github
+// tools: reading issues, listing pull requests and opening new ones.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+const (
+	DefaultAPIURL   = "https://api.github.com"
+	DefaultTokenEnv = "GITHUB_TOKEN"
+	apiVersion      = "2022-11-28"
+	maxErrorBody    = 64 << 10
+)
+
+// Client calls the GitHub REST API with a token.
+type Client struct {
+	apiURL string
+	token  string
+	// Repo is the default "owner/name" for calls that pass none.
+	Repo string
+	HTTP *http.Client
+}
+
+// NewClient returns a client for apiURL (DefaultAPIURL when empty).
+func NewClient(apiURL, token, repo string) *Client {
+	if strings.TrimSpace(apiURL) == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		token:  token,
+		Repo:   repo,
+		HTTP:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// FromConfig builds a client from the project config and the environment.
+// Without a configured repo it uses the origin remote of the git work tree
+// at root. ok is false when no token is set, so the tools stay unregistered.
+func FromConfig(cfg config.GitHub, root string) (client *Client, ok bool) {
+	tokenEnv := strings.TrimSpace(cfg.TokenEnv)
+	if tokenEnv == "" {
+		tokenEnv = DefaultTokenEnv
+	}
+	token := strings.TrimSpace(os.Getenv(tokenEnv))
+	if token == "" {
+		return nil, false
+	}
+	repo := strings.TrimSpace(cfg.Repo)
+	if repo == "" {
+		repo = RepoFromGit(context.Background(), root)
+	}
+	return NewClient(cfg.APIURL, token, repo), true
+}
+
+// RepoFromGit returns "owner/name" of the origin remote, or "" when the
+// remote is missing or not a GitHub-style URL.
+func RepoFromGit(ctx context.Context, dir string) string {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	repo, _ := ParseRemote(strings.TrimSpace(string(out)))
+	return repo
+}
+
+// ParseRemote extracts "owner/name" from a git remote URL such as
+// git@github.com:owner/name.git or https://github.com/owner/name.
+func ParseRemote(remote string) (string, bool) {
+	path := ""
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		path = u.Path
+	} else if before, rest, ok := strings.Cut(remote, ":"); ok && strings.Contains(before, "@") {
+		path = rest // scp-like: git@host:owner/name.git
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	owner, name, ok := strings.Cut(path, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return owner + "/" + name, true
+}
+
+// User is the login of an account.
+type User struct {
+	Login string `json:"login"`
+}
+
+// Label is an issue label.
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Issue is an issue or the issue side of a pull request.
+type Issue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	State     string    `json:"state"`
+	HTMLURL   string    `json:"html_url"`
+	User      User      `json:"user"`
+	Labels    []Label   `json:"labels"`
+	Comments  int       `json:"comments"`
+	CreatedAt time.Time `json:"created_at"`
+	// PullRequest is set when the issue is a pull request.
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+// Comment is an issue comment.
+type Comment struct {
+	User      User      `json:"user"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Ref is one end of a pull request.
+type Ref struct {
+	Ref   string `json:"ref"`
+	Label string `json:"label"`
+	SHA   string `json:"sha"`
+}
+
+// PullRequest is a pull request.
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	Draft   bool   `json:"draft"`
+	HTMLURL string `json:"html_url"`
+	User    User   `json:"user"`
+	Head    Ref    `json:"head"`
+	Base    Ref    `json:"base"`
+}
+
+// Review is a pull request review. Comments must point at lines that are
+// part of the diff; Event is COMMENT, APPROVE or REQUEST_CHANGES.
+type Review struct {
+	CommitID string          `json:"commit_id,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Event    string          `json:"event"`
+	Comments []ReviewComment `json:"comments,omitempty"`
+}
+
+// ReviewComment is an inline comment on the new side of the diff.
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side,omitempty"`
+	Body string `json:"body"`
+}
+
+// NewPullRequest is the input of CreatePR. Head is the branch with the
+// changes ("branch" or "owner:branch" for forks).
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// ListOptions filter ListPRs. Zero values mean GitHub's defaults.
+type ListOptions struct {
+	State string // open, closed or all
+	Head  string // owner:branch
+	Base  string
+	Limit int // at most 100
+}
+
+// APIError is a non-2xx response.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github: %d %s", e.StatusCode, e.Message)
+}
+
+// GetIssue fetches an issue and up to maxComments of its comments.
+func (c *Client) GetIssue(ctx context.Context, repo string, number, maxComments int) (Issue, []Comment, error) {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return Issue{}, nil, err
+	}
+	var issue Issue
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &issue); err != nil {
+		return Issue{}, nil, err
+	}
+	var comments []Comment
+	if issue.Comments > 0 && maxComments > 0 {
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=%d", repo, number, min(maxComments, 100))
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return Issue{}, nil, err
+		}
+	}
+	return issue, comments, nil
+}
+
+// ListPRs lists pull requests, newest first.
+func (c *Client) ListPRs(ctx context.Context, repo string, opts ListOptions) ([]PullRequest, error) {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if opts.Head != "" {
+		query.Set("head", opts.Head)
+	}
+	if opts.Base != "" {
+		query.Set("base", opts.Base)
+	}
+	if opts.Limit > 0 {
+		query.Set("per_page", strconv.Itoa(min(opts.Limit, 100)))
+	}
+	path := "/repos/" + repo + "/pulls"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var prs []PullRequest
+	if err := c.do(ctx, http.MethodGet, path, nil, &prs); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// CreatePR opens a pull request. An empty Base targets the default branch.
+func (c *Client) CreatePR(ctx context.Context, repo string, pr NewPullRequest) (PullRequest, error) {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	if pr.Base == "" {
+		var info struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/repos/"+repo, nil, &info); err != nil {
+			return PullRequest{}, err
+		}
+		pr.Base = info.DefaultBranch
+	}
+	var created PullRequest
+	if err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/pulls", pr, &created); err != nil {
+		return PullRequest{}, err
+	}
+	return created, nil
+}
+
+// GetPR fetches a pull request.
+func (c *Client) GetPR(ctx context.Context, repo string, number int) (PullRequest, error) {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	var pr PullRequest
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+		return PullRequest{}, err
+	}
+	return pr, nil
+}
+
+// PRDiff returns the unified diff of a pull request.
+func (c *Client) PRDiff(ctx context.Context, repo string, number int) (string, error) {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return "", err
+	}
+	var diff rawBody
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &diff); err != nil {
+		return "", err
+	}
+	return string(diff), nil
+}
+
+// CreateReview submits a review with inline comments on a pull request.
+func (c *Client) CreateReview(ctx context.Context, repo string, number int, review Review) error {
+	repo, err := c.repo(repo)
+	if err != nil {
+		return err
+	}
+	var created struct{}
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/reviews", repo, number), review, &created)
+}
+
+// rawBody asks do for the response as-is, e.g. a diff instead of JSON.
+type rawBody []byte
+
+func (c *Client) repo(repo string) (string, error) {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		repo = c.Repo
+	}
+	if repo == "" {
+		return "", fmt.Errorf("no repository: pass owner/name or set github.repo in the config")
+	}
+	if _, ok := ParseRemote("https://github.com/" + repo); !ok {
+		return "", fmt.Errorf("invalid repository %q: want owner/name", repo)
+	}
+	return repo, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	accept := "application/vnd.github+json"
+	if _, raw := out.(*rawBody); raw {
+		accept = "application/vnd.github.diff"
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", apiVersion)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{StatusCode: resp.StatusCode, Message: errorMessage(data, resp.Status)}
+	}
+	if raw, ok := out.(*rawBody); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// errorMessage extracts GitHub's message and validation details.
+func errorMessage(body []byte, status string) string {
+	var payload struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+			Code    string `json:"code"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) != nil || payload.Message == "" {
+		return status
+	}
+	msg := payload.Message
+	for _, e := range payload.Errors {
+		switch {
+		case e.Message != "":
+			msg += "; " + e.Message
+		case e.Field != "":
+			msg += fmt.Sprintf("; %s %s", e.Field, e.Code)
+		}
+	}
+	return msg
+}
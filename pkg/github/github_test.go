@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+func TestParseRemote(t *testing.T) {
+	cases := map[string]string{
+		"git@github.com:acme/widgets.git":          "acme/widgets",
+		"https://github.com/acme/widgets":          "acme/widgets",
+		"https://github.com/acme/widgets.git":      "acme/widgets",
+		"ssh://git@github.com/acme/widgets.git":    "acme/widgets",
+		"https://ghe.example.com/acme/widgets/":    "acme/widgets",
+		"/srv/git/widgets.git":                     "",
+		"https://github.com/acme":                  "",
+		"https://gitlab.com/group/sub/widgets.git": "",
+	}
+	for remote, want := range cases {
+		got, ok := ParseRemote(remote)
+		if got != want || ok != (want != "") {
+			t.Errorf("ParseRemote(%q) = %q, %v; want %q", remote, got, ok, want)
+		}
+	}
+}
+
+func TestFromConfig_RequiresToken(t *testing.T) {
+	t.Setenv("ACME_TOKEN", "")
+	if _, ok := FromConfig(config.GitHub{Repo: "acme/widgets", TokenEnv: "ACME_TOKEN"}, t.TempDir()); ok {
+		t.Fatal("expected no client without a token")
+	}
+	t.Setenv("ACME_TOKEN", "secret")
+	client, ok := FromConfig(config.GitHub{Repo: "acme/widgets", TokenEnv: "ACME_TOKEN"}, t.TempDir())
+	if !ok || client.Repo != "acme/widgets" || client.token != "secret" {
+		t.Fatalf("FromConfig = %+v, %v", client, ok)
+	}
+}
+
+func TestClient_GetIssueWithComments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/issues/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("X-GitHub-Api-Version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		writeJSON(w, map[string]any{"number": 7, "title": "Crash on empty input", "body": "Steps...", "state": "open", "comments": 1})
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]any{{"user": map[string]any{"login": "bob"}, "body": "me too"}})
+	})
+	client := newTestClient(t, mux)
+
+	issue, comments, err := client.GetIssue(context.Background(), "", 7, 10)
+	if err != nil {
+		t.Fatalf("GetIssue: %v", err)
+	}
+	if issue.Title != "Crash on empty input" || len(comments) != 1 || comments[0].User.Login != "bob" {
+		t.Fatalf("issue = %+v, comments = %+v", issue, comments)
+	}
+}
+
+func TestClient_CreatePRDefaultsBaseToDefaultBranch(t *testing.T) {
+	var got NewPullRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"default_branch": "trunk"})
+	})
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{"number": 12, "html_url": "https://github.com/acme/widgets/pull/12"})
+	})
+	client := newTestClient(t, mux)
+
+	pr, err := client.CreatePR(context.Background(), "", NewPullRequest{Title: "Fix crash", Head: "fix-7"})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if pr.Number != 12 || got.Base != "trunk" || got.Head != "fix-7" {
+		t.Fatalf("pr = %+v, request = %+v", pr, got)
+	}
+}
+
+func TestClient_ReportsAPIErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeJSON(w, map[string]any{"message": "Validation Failed", "errors": []map[string]any{{"message": "A pull request already exists for acme:fix-7."}}})
+	})
+	client := newTestClient(t, mux)
+
+	_, err := client.CreatePR(context.Background(), "", NewPullRequest{Title: "x", Head: "fix-7", Base: "main"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 422 || apiErr.Message != "Validation Failed; A pull request already exists for acme:fix-7." {
+		t.Fatalf("err = %v", err)
+	}
+
+	if _, err := NewClient("", "tok", "").ListPRs(context.Background(), "", ListOptions{}); err == nil {
+		t.Fatal("expected an error without a repository")
+	}
+}
+
+func TestClient_PRDiffRequestsDiffMediaType(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/pulls/4", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/vnd.github.diff" {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+		_, _ = w.Write([]byte("diff --git a/x b/x\n"))
+	})
+	client := newTestClient(t, mux)
+
+	diff, err := client.PRDiff(context.Background(), "", 4)
+	if err != nil || diff != "diff --git a/x b/x\n" {
+		t.Fatalf("PRDiff = %q, %v", diff, err)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL, "tok", "acme/widgets")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,197 @@
+// Package gotool runs the Go toolchain (test, vet, gofmt) and parses its
+// output into structured results the agent can act on directly.
+package gotool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxFailureOutput = 4000
+
+var diagnosticPattern = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// Diagnostic is a file:line:col message reported by vet, gofmt, or the compiler.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+type TestFailure struct {
+	Package string `json:"package"`
+	Test    string `json:"test,omitempty"`
+	Output  string `json:"output"`
+}
+
+type TestReport struct {
+	Passed         int           `json:"passed"`
+	Failed         int           `json:"failed"`
+	Skipped        int           `json:"skipped"`
+	Failures       []TestFailure `json:"failures,omitempty"`
+	FailedPackages []string      `json:"failedPackages,omitempty"`
+	BuildErrors    []Diagnostic  `json:"buildErrors,omitempty"`
+	// Other holds non-JSON output that is not a file:line diagnostic, e.g. module errors.
+	Other []string `json:"other,omitempty"`
+}
+
+// OK reports whether every package built and every test passed.
+func (r TestReport) OK() bool {
+	return r.Failed == 0 && len(r.FailedPackages) == 0 && len(r.BuildErrors) == 0 && len(r.Other) == 0
+}
+
+type testEvent struct {
+	Action     string `json:"Action"`
+	Package    string `json:"Package"`
+	ImportPath string `json:"ImportPath"`
+	Test       string `json:"Test"`
+	Output     string `json:"Output"`
+}
+
+// ParseTestJSON consumes `go test -json` output. Lines that are not JSON
+// (compiler errors on older toolchains) are parsed as diagnostics.
+func ParseTestJSON(r io.Reader) (TestReport, error) {
+	var report TestReport
+	outputs := make(map[string]*strings.Builder)
+	failedPackages := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+			report.addRawLine(line)
+			continue
+		}
+
+		var ev testEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			report.addRawLine(line)
+			continue
+		}
+
+		key := ev.Package + "\x00" + ev.Test
+		switch ev.Action {
+		case "build-output":
+			report.addRawLine(strings.TrimRight(ev.Output, "\n"))
+		case "output":
+			b, ok := outputs[key]
+			if !ok {
+				b = &strings.Builder{}
+				outputs[key] = b
+			}
+			b.WriteString(ev.Output)
+		case "pass":
+			if ev.Test != "" {
+				report.Passed++
+			}
+		case "skip":
+			if ev.Test != "" {
+				report.Skipped++
+			}
+		case "fail":
+			if ev.Test == "" {
+				failedPackages[ev.Package] = true
+				continue
+			}
+			report.Failed++
+			report.Failures = append(report.Failures, TestFailure{
+				Package: ev.Package,
+				Test:    ev.Test,
+				Output:  tail(outputs[key], maxFailureOutput),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TestReport{}, fmt.Errorf("scan go test output: %w", err)
+	}
+
+	// 包级失败但没有任何测试失败时（如 panic 于 init、TestMain 失败），把包输出作为失败详情
+	failedTests := make(map[string]bool)
+	for _, failure := range report.Failures {
+		failedTests[failure.Package] = true
+	}
+	for pkg := range failedPackages {
+		report.FailedPackages = append(report.FailedPackages, pkg)
+		if !failedTests[pkg] {
+			if out := tail(outputs[pkg+"\x00"], maxFailureOutput); strings.TrimSpace(out) != "" {
+				report.Failures = append(report.Failures, TestFailure{Package: pkg, Output: out})
+			}
+		}
+	}
+	sort.Strings(report.FailedPackages)
+	return report, nil
+}
+
+func (r *TestReport) addRawLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || line == "FAIL" || strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "ok ") {
+		return
+	}
+	if d, ok := parseDiagnostic(line); ok {
+		r.BuildErrors = append(r.BuildErrors, d)
+		return
+	}
+	r.Other = append(r.Other, line)
+}
+
+// ParseDiagnostics extracts file:line[:col]: message entries from tool output,
+// skipping package headers such as "# example.com/pkg".
+func ParseDiagnostics(output string) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if d, ok := parseDiagnostic(strings.TrimSpace(line)); ok {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+func parseDiagnostic(line string) (Diagnostic, bool) {
+	matches := diagnosticPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return Diagnostic{}, false
+	}
+	lineNo, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return Diagnostic{}, false
+	}
+	column := 0
+	if matches[3] != "" {
+		column, err = strconv.Atoi(matches[3])
+		if err != nil {
+			return Diagnostic{}, false
+		}
+	}
+	return Diagnostic{
+		File:    strings.TrimPrefix(matches[1], "./"),
+		Line:    lineNo,
+		Column:  column,
+		Message: matches[4],
+	}, true
+}
+
+func tail(b *strings.Builder, limit int) string {
+	if b == nil {
+		return ""
+	}
+	text := b.String()
+	if len(text) <= limit {
+		return text
+	}
+	return "..." + text[len(text)-limit:]
+}
@@ -0,0 +1,75 @@
+package gotool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTestJSON_CollectsFailuresWithOutput(t *testing.T) {
+	input := strings.Join([]string{
+		`{"Action":"run","Package":"example.com/a","Test":"TestOK"}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestOK"}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestBad"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestBad","Output":"    a_test.go:12: got 1, want 2\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestBad"}`,
+		`{"Action":"skip","Package":"example.com/a","Test":"TestLater"}`,
+		`{"Action":"fail","Package":"example.com/a"}`,
+		`{"Action":"pass","Package":"example.com/b"}`,
+	}, "\n")
+
+	report, err := ParseTestJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseTestJSON: %v", err)
+	}
+	if report.Passed != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("counts = %d/%d/%d, want 1/1/1", report.Passed, report.Failed, report.Skipped)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].Test != "TestBad" || !strings.Contains(report.Failures[0].Output, "a_test.go:12") {
+		t.Fatalf("unexpected failures: %+v", report.Failures)
+	}
+	if strings.Join(report.FailedPackages, ",") != "example.com/a" {
+		t.Fatalf("failed packages = %v", report.FailedPackages)
+	}
+	if report.OK() {
+		t.Fatal("report should not be OK")
+	}
+}
+
+func TestParseTestJSON_BuildErrors(t *testing.T) {
+	input := strings.Join([]string{
+		`# example.com/a`,
+		`a/a.go:3:2: undefined: missing`,
+		`{"ImportPath":"example.com/b","Action":"build-output","Output":"b/b.go:9:1: syntax error: unexpected }\n"}`,
+		`{"Action":"fail","Package":"example.com/a"}`,
+		`FAIL`,
+	}, "\n")
+
+	report, err := ParseTestJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseTestJSON: %v", err)
+	}
+	if len(report.BuildErrors) != 2 {
+		t.Fatalf("build errors = %+v, want 2", report.BuildErrors)
+	}
+	if got := report.BuildErrors[0].String(); got != "a/a.go:3:2: undefined: missing" {
+		t.Fatalf("first build error = %q", got)
+	}
+	if len(report.Other) != 0 {
+		t.Fatalf("unexpected other output: %v", report.Other)
+	}
+}
+
+func TestParseDiagnostics_VetOutput(t *testing.T) {
+	output := "# example.com/a\n./a.go:10:2: fmt.Printf format %d has arg s of wrong type string\nvet: b.go:4: something odd\n"
+
+	diagnostics := ParseDiagnostics(output)
+	if len(diagnostics) != 2 {
+		t.Fatalf("diagnostics = %+v, want 2", diagnostics)
+	}
+	if diagnostics[0].File != "a.go" || diagnostics[0].Line != 10 || diagnostics[0].Column != 2 {
+		t.Fatalf("unexpected first diagnostic: %+v", diagnostics[0])
+	}
+	if diagnostics[1].File != "b.go" || diagnostics[1].Column != 0 {
+		t.Fatalf("unexpected second diagnostic: %+v", diagnostics[1])
+	}
+}
@@ -0,0 +1,160 @@
+package gotool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Test runs `go test -json` for pkgs (default ./...) in dir. run, when set,
+// is forwarded as -run. A non-zero exit caused by failing tests is not an error.
+func Test(ctx context.Context, dir string, pkgs []string, run string) (TestReport, error) {
+	return TestWithProgress(ctx, dir, pkgs, run, nil)
+}
+
+// TestWithProgress is Test calling progress with the running counts each
+// time a test finishes. progress may be nil.
+func TestWithProgress(ctx context.Context, dir string, pkgs []string, run string, progress func(passed, failed, skipped int)) (TestReport, error) {
+	args := []string{"test", "-json"}
+	if strings.TrimSpace(run) != "" {
+		args = append(args, "-run", run)
+	}
+	args = append(args, defaultPackages(pkgs)...)
+
+	var watch io.Writer
+	if progress != nil {
+		watch = &testCounter{progress: progress}
+	}
+	stdout, stderr, err := runTool(ctx, dir, watch, "go", args...)
+	if err != nil && !isExitError(err) {
+		return TestReport{}, err
+	}
+
+	report, parseErr := ParseTestJSON(strings.NewReader(stdout + "\n" + stderr))
+	if parseErr != nil {
+		return TestReport{}, parseErr
+	}
+	return report, nil
+}
+
+// Vet runs `go vet` for pkgs (default ./...) in dir and returns its findings.
+func Vet(ctx context.Context, dir string, pkgs []string) ([]Diagnostic, error) {
+	args := append([]string{"vet"}, defaultPackages(pkgs)...)
+
+	stdout, stderr, err := execTool(ctx, dir, "go", args...)
+	if err != nil && !isExitError(err) {
+		return nil, err
+	}
+
+	output := stdout + "\n" + stderr
+	diagnostics := ParseDiagnostics(output)
+	if err != nil && len(diagnostics) == 0 {
+		return nil, fmt.Errorf("go vet failed: %s", strings.TrimSpace(output))
+	}
+	return diagnostics, nil
+}
+
+// Fmt runs gofmt over paths (default "."). It returns the files whose
+// formatting differs (or was rewritten when write is true) plus syntax errors.
+func Fmt(ctx context.Context, dir string, paths []string, write bool) ([]string, []Diagnostic, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	args := []string{"-l"}
+	if write {
+		args = append(args, "-w")
+	}
+	args = append(args, paths...)
+
+	stdout, stderr, err := execTool(ctx, dir, "gofmt", args...)
+	if err != nil && !isExitError(err) {
+		return nil, nil, err
+	}
+
+	files := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, strings.TrimPrefix(line, "./"))
+		}
+	}
+	diagnostics := ParseDiagnostics(stderr)
+	if err != nil && len(diagnostics) == 0 {
+		return nil, nil, fmt.Errorf("gofmt failed: %s", strings.TrimSpace(stderr))
+	}
+	return files, diagnostics, nil
+}
+
+func execTool(ctx context.Context, dir, name string, args ...string) (string, string, error) {
+	return runTool(ctx, dir, nil, name, args...)
+}
+
+// runTool runs name in dir, also copying stdout to watch when it is not nil.
+func runTool(ctx context.Context, dir string, watch io.Writer, name string, args ...string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	if watch != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, watch)
+	}
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil && !isExitError(err) {
+		return "", "", fmt.Errorf("run %s: %w", name, err)
+	}
+	return stdout.String(), stderr.String(), err
+}
+
+func isExitError(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr)
+}
+
+func defaultPackages(pkgs []string) []string {
+	if len(pkgs) == 0 {
+		return []string{"./..."}
+	}
+	return pkgs
+}
+
+// testCounter reads `go test -json` output as it is written and counts the
+// tests that finish.
+type testCounter struct {
+	progress                func(passed, failed, skipped int)
+	partial                 []byte
+	passed, failed, skipped int
+}
+
+func (c *testCounter) Write(p []byte) (int, error) {
+	c.partial = append(c.partial, p...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := c.partial[:i]
+		c.partial = c.partial[i+1:]
+
+		var ev testEvent
+		if json.Unmarshal(line, &ev) != nil || ev.Test == "" {
+			continue
+		}
+		switch ev.Action {
+		case "pass":
+			c.passed++
+		case "fail":
+			c.failed++
+		case "skip":
+			c.skipped++
+		default:
+			continue
+		}
+		c.progress(c.passed, c.failed, c.skipped)
+	}
+}
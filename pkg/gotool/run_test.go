@@ -0,0 +1,80 @@
+package gotool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFmt_ListsAndRewritesUnformattedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\nfunc  A( ) {}\n"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n\nfunc B() {}\n"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	files, diagnostics, err := Fmt(context.Background(), dir, nil, false)
+	if err != nil {
+		t.Fatalf("Fmt: %v", err)
+	}
+	if len(files) != 1 || files[0] != "a.go" || len(diagnostics) != 0 {
+		t.Fatalf("files = %v, diagnostics = %v", files, diagnostics)
+	}
+
+	if _, _, err := Fmt(context.Background(), dir, nil, true); err != nil {
+		t.Fatalf("Fmt(write): %v", err)
+	}
+	files, _, err = Fmt(context.Background(), dir, nil, false)
+	if err != nil {
+		t.Fatalf("Fmt: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected formatted tree, got %v", files)
+	}
+}
+
+func TestFmt_ReportsSyntaxErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.go"), []byte("package a\nfunc {\n"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	_, diagnostics, err := Fmt(context.Background(), dir, nil, false)
+	if err != nil {
+		t.Fatalf("Fmt: %v", err)
+	}
+	if len(diagnostics) == 0 || diagnostics[0].File != "bad.go" || diagnostics[0].Line != 2 {
+		t.Fatalf("unexpected diagnostics: %+v", diagnostics)
+	}
+}
+
+func TestTestCounter_CountsFinishedTestsAcrossWrites(t *testing.T) {
+	var got [][3]int
+	c := &testCounter{progress: func(passed, failed, skipped int) {
+		got = append(got, [3]int{passed, failed, skipped})
+	}}
+	stream := `{"Action":"run","Package":"p","Test":"TestA"}
+{"Action":"pass","Package":"p","Test":"TestA"}
+{"Action":"fail","Package":"p","Test":"TestB"}
+{"Action":"skip","Package":"p","Test":"TestC"}
+{"Action":"pass","Package":"p"}
+`
+	// Lines split across writes are put back together.
+	for _, chunk := range []string{stream[:30], stream[30:100], stream[100:]} {
+		if _, err := c.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := [][3]int{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}}
+	if len(got) != len(want) {
+		t.Fatalf("progress = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("progress = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Package harness wires the agent the same way for every entry point:
+// cmd/agent (the chat REPL and the non-interactive subcommands),
+// cmd/agent-server and the s06 lesson. A tool, approval rule or model
+// interceptor added here reaches all of them at once.
+//
+// Problems that do not stop a run, such as a tool that cannot start, are
+// reported on stderr in the language of i18n.Current and the run goes on
+// without that part.
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/i18n"
+	"github.com/nickdu2009/learn-claude-code/pkg/index"
+	"github.com/nickdu2009/learn-claude-code/pkg/injection"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/lsp"
+	"github.com/nickdu2009/learn-claude-code/pkg/mcp"
+	"github.com/nickdu2009/learn-claude-code/pkg/memory"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/provider"
+	"github.com/nickdu2009/learn-claude-code/pkg/qwen"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/nickdu2009/learn-claude-code/pkg/trust"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// NewClient returns the client and default model of cfg, see provider.New.
+// Without DASHSCOPE_MODEL, DashScope conversations use the long-context
+// qwen-long.
+func NewClient(cfg config.Provider, opts ...option.RequestOption) (*openai.Client, string, error) {
+	client, model, err := provider.New(cfg, opts...)
+	if err == nil && provider.Name(cfg) == "qwen" && os.Getenv("DASHSCOPE_MODEL") == "" {
+		model = "qwen-long"
+	}
+	return client, model, err
+}
+
+// RegisterBaseTools registers the shell and file tools on registry. bash
+// runs in the sandbox of AGENT_SANDBOX, with root mounted into it, within
+// cfg.Limits; with cfg.IsolateNetwork it runs offline unless
+// tools.NetworkGate lets a command out. go_test, go_vet and gofmt run the
+// toolchain on the host, so they are only offered when bash runs there
+// unrestricted.
+func RegisterBaseTools(registry *tools.Registry, root string, cfg config.Config) error {
+	executor, err := sandbox.NewFromEnv(root)
+	if err != nil {
+		return err
+	}
+	_, local := executor.(sandbox.Local)
+	def := tools.ShellToolDef(sandbox.ShellOf(executor))
+	if cfg.IsolateNetwork {
+		executor, def = sandbox.Offline(executor), tools.OfflineBashToolDef()
+	}
+	registry.Register(def, tools.NewBashHandler(sandbox.Limited(executor, cfg.Limits)))
+	registry.Register(tools.ReadFileToolDef(), tools.ReadFileHandler)
+	registry.Register(tools.WriteFileToolDef(), tools.WriteFileHandler)
+	registry.Register(tools.EditFileToolDef(), tools.EditFileHandler)
+	registry.Register(tools.MultiEditToolDef(), tools.MultiEditHandler)
+	registry.Register(tools.ListDirToolDef(), tools.ListDirHandler)
+	registry.Register(tools.ListFilesToolDef(), tools.ListFilesHandler)
+	registry.Register(tools.GrepToolDef(), tools.GrepHandler)
+	registry.Register(tools.GitDiffToolDef(), tools.GitDiffHandler)
+	if local && !cfg.IsolateNetwork && cfg.Limits.IsZero() {
+		registry.Register(tools.GoTestToolDef(), tools.GoTestHandler)
+		registry.Register(tools.GoVetToolDef(), tools.GoVetHandler)
+		registry.Register(tools.GofmtToolDef(), tools.GofmtHandler)
+	}
+	return nil
+}
+
+// Tools are the tools RegisterTools started; Close stops them.
+type Tools struct {
+	closers []func()
+}
+
+// Close stops the language server and the index watcher.
+func (t *Tools) Close() {
+	for _, c := range t.closers {
+		c()
+	}
+}
+
+// RegisterTools registers on registry the tools that depend on the machine
+// and the configuration of root:
+//
+//   - go_to_definition, find_references and hover when gopls is installed;
+//     the language server starts on the first call.
+//   - code_search with DashScope as the provider, its index in .index/ kept
+//     current by watcher. A nil watcher is replaced by one of root's own.
+//   - http_request for the hosts of cfg.HTTPRequest.
+//   - memory_write and memory_search with cfg.Memory, stored in .memory/.
+func RegisterTools(registry *tools.Registry, root string, cfg config.Config, watcher *fileindex.Watcher) *Tools {
+	t := &Tools{}
+	if _, err := exec.LookPath("gopls"); err == nil {
+		if gopls, err := lsp.NewGoplsClient(root); err == nil {
+			t.closers = append(t.closers, func() { _ = gopls.Close() })
+			registry.Register(tools.GoToDefinitionToolDef(), tools.NewGoToDefinitionHandler(gopls))
+			registry.Register(tools.FindReferencesToolDef(), tools.NewFindReferencesHandler(gopls))
+			registry.Register(tools.HoverToolDef(), tools.NewHoverHandler(gopls))
+		}
+	}
+	if provider.Name(cfg.Provider) == "qwen" {
+		if codeIndex, err := newCodeIndex(root); err != nil {
+			warn(fmt.Errorf("code_search is unavailable: %w", err))
+		} else {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.closers = append(t.closers, cancel)
+			if watcher == nil {
+				watcher = fileindex.NewWatcher(root, fileindex.DefaultPollInterval)
+				go watcher.Run(ctx)
+			}
+			codeIndex.Watch(ctx, watcher)
+			registry.Register(tools.CodeSearchToolDef(), tools.NewCodeSearchHandler(codeIndex))
+		}
+	}
+	if hosts := cfg.HTTPRequest.AllowedHosts; len(hosts) > 0 {
+		registry.Register(tools.HTTPRequestToolDef(), tools.NewHTTPRequestHandler(tools.HTTPRequestConfig{AllowedHosts: hosts}))
+	}
+	if cfg.Memory {
+		if memories, err := newMemory(root, cfg.Provider); err != nil {
+			warn(fmt.Errorf("memory is unavailable: %w", err))
+		} else {
+			registry.Register(tools.MemoryWriteToolDef(), tools.NewMemoryWriteHandler(memories))
+			registry.Register(tools.MemorySearchToolDef(), tools.NewMemorySearchHandler(memories))
+		}
+	}
+	return t
+}
+
+// newCodeIndex returns the code index of root, stored in index.DefaultDir
+// and embedded with DashScope.
+func newCodeIndex(root string) (*index.Index, error) {
+	client, err := qwen.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	store, err := index.NewFileStore(filepath.Join(root, index.DefaultDir))
+	if err != nil {
+		return nil, err
+	}
+	return index.New(root, store, qwen.NewEmbedder(client, ""))
+}
+
+// newMemory returns the memories of root, stored in memory.DefaultDir.
+// They are embedded with DashScope when it is the provider and searched by
+// keyword otherwise.
+func newMemory(root string, cfg config.Provider) (*memory.Service, error) {
+	repo, err := memory.NewFileRepository(filepath.Join(root, memory.DefaultDir))
+	if err != nil {
+		return nil, err
+	}
+	if provider.Name(cfg) != "qwen" {
+		return memory.NewService(repo, nil), nil
+	}
+	client, err := qwen.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewService(repo, qwen.NewEmbedder(client, "")), nil
+}
+
+// TrustProject reports whether the plugins of root and the MCP servers,
+// schedules and webhooks of its project config may run: when the user
+// trusted the project as it is now, or when approver, asked with the list
+// of programs, grants it. The grant is recorded like `agent trust` does.
+// A nil approver has nobody to ask and only checks the record.
+func TrustProject(root string, cfg config.Config, approver permission.Approver) bool {
+	fp, err := trust.Fingerprint(root, cfg)
+	if err != nil {
+		warn(err)
+		return false
+	}
+	if trust.Trusted(root, fp) {
+		return true
+	}
+	if approver != nil {
+		programs, _ := trust.Programs(root, cfg)
+		ok, err := approver.Approve(context.Background(), permission.Request{
+			Tool:    "project",
+			Summary: i18n.T(i18n.TrustProject),
+			Detail:  strings.Join(programs, "\n"),
+		})
+		if err == nil && ok {
+			if err := trust.Grant(root, fp); err != nil {
+				warn(err)
+			}
+			return true
+		}
+	}
+	fmt.Fprintln(os.Stderr, i18n.T(i18n.UntrustedProject, tools.DefaultPluginDir))
+	return false
+}
+
+// Extensions are the tools that act beyond the workspace on approval:
+// sql_query, plugins and MCP server tools. Close disconnects them.
+type Extensions struct {
+	// Plugins and MCPTools name the tools registered.
+	Plugins, MCPTools []string
+
+	databases interface{ Close() error }
+	servers   mcp.Servers
+}
+
+// Close stops the MCP servers and closes the databases.
+func (e *Extensions) Close() {
+	e.servers.Close()
+	if e.databases != nil {
+		_ = e.databases.Close()
+	}
+}
+
+// RegisterExtensions registers on registry sql_query for cfg.Databases, the
+// plugins in root's tools.DefaultPluginDir and the tools of cfg.MCP's
+// servers. Writes through sql_query, plugins and MCP tools not annotated
+// read-only ask approver first. Unless the project is trusted (see
+// TrustProject) there are no plugins and only the MCP servers of the user's
+// settings start.
+//
+// The only error is a tool name conflict that cfg.MCP.Conflicts refuses
+// (tools.ErrConflict); everything is closed again then.
+func RegisterExtensions(registry *tools.Registry, root string, cfg config.Config, trusted bool, approver permission.Approver) (*Extensions, error) {
+	e := &Extensions{}
+	if databases, err := tools.RegisterDatabases(registry, cfg.Databases, approver); err != nil {
+		warn(err)
+	} else if databases != nil {
+		e.databases = databases
+	}
+	servers := cfg.MCP
+	if trusted {
+		plugins, err := tools.RegisterPlugins(context.Background(), registry, filepath.Join(root, tools.DefaultPluginDir), approver)
+		if err != nil {
+			warn(err)
+		}
+		e.Plugins = plugins
+	} else {
+		servers = servers.UserServers()
+	}
+	var err error
+	e.servers, err = mcp.Connect(context.Background(), servers, root)
+	if err != nil {
+		warn(err)
+	}
+	e.MCPTools, err = e.servers.Register(context.Background(), registry, tools.ConflictPolicy(cfg.MCP.Conflicts), approver)
+	if errors.Is(err, tools.ErrConflict) {
+		e.Close()
+		return nil, err
+	} else if err != nil {
+		warn(err)
+	}
+	return e, nil
+}
+
+// CheckOutput wraps registry so that tool output is checked for prompt
+// injection, explained by cfg.FailureHints and cleaned up by
+// cfg.OutputProcessors before the model reads it.
+func CheckOutput(registry *tools.Registry, cfg config.Config) *tools.Registry {
+	registry = registry.WithMiddleware(injection.Middleware(injection.LogAlert(os.Stderr)))
+	if cfg.FailureHints {
+		registry = registry.WithMiddleware(tools.FailureHints())
+	}
+	return registry.WithMiddleware(tools.OutputProcessors(cfg.OutputProcessors))
+}
+
+// RememberApprovals wraps approver so that requests matching the allow
+// rules of cfg pass without asking, and an [a]lways answer is saved in
+// root's .agent/settings.local.json for the next run.
+func RememberApprovals(root string, cfg config.Config, approver permission.Approver) permission.Approver {
+	return permission.Remember(approver, cfg.Permissions.Allow, func(rule permission.Rule) {
+		if err := config.AddAllowRule(root, rule); err != nil {
+			warn(err)
+		}
+	})
+}
+
+// SkipPermissions returns registry and the approver as
+// --dangerously-skip-permissions has them: every request is approved and
+// dangerous commands run. Callers keep the audit log in registry, refusing
+// to start without it, and print i18n.SkipWarning.
+func SkipPermissions(registry *tools.Registry) (*tools.Registry, permission.Approver) {
+	return registry.WithMiddleware(tools.AllowDangerousCommands()), permission.Bypass
+}
+
+// Context returns ctx for the model calls of a run: with the capabilities
+// of cfg's model and its Interceptors.
+func Context(ctx context.Context, cfg config.Provider) context.Context {
+	return llm.WithInterceptors(llm.WithCapabilities(ctx, provider.Capabilities(cfg)), Interceptors(cfg)...)
+}
+
+// Interceptors returns what every model call goes through: the fallbacks
+// of cfg while the model is unavailable, its circuit breaker (see Breaker)
+// and the prompt cache marker for the system prompt. Switches to a
+// fallback are reported on stderr.
+func Interceptors(cfg config.Provider, opts ...option.RequestOption) []llm.Interceptor {
+	var interceptors []llm.Interceptor
+	if targets := provider.Fallbacks(cfg, opts...); len(targets) > 0 {
+		interceptors = append(interceptors, llm.Fallback(targets, func(ctx context.Context, sw llm.Switch) {
+			fmt.Fprintln(os.Stderr, i18n.T(i18n.ModelSwitched, sw.From, sw.Reason, sw.To, trace.FromContext(ctx)))
+		}))
+	}
+	interceptors = append(interceptors, llm.NewBreaker(Breaker(cfg)).Interceptor())
+	if cache, ok := provider.PromptCache(cfg); ok {
+		interceptors = append(interceptors, cache)
+	}
+	return interceptors
+}
+
+// Breaker returns the circuit breaker options of cfg: after repeated
+// failures calls to the model pause for a cool-down, reported on stderr
+// like the model's recovery.
+func Breaker(cfg config.Provider) llm.BreakerOptions {
+	breaker := provider.BreakerOptions(cfg)
+	breaker.OnChange = func(ctx context.Context, status llm.ModelStatus) {
+		switch status.State {
+		case llm.CircuitOpen:
+			fmt.Fprintln(os.Stderr, i18n.T(i18n.ModelPaused, status.Model, status.LastError, status.OpenUntil.Format(time.TimeOnly), trace.FromContext(ctx)))
+		case llm.CircuitClosed:
+			fmt.Fprintln(os.Stderr, i18n.T(i18n.ModelAvailable, status.Model, trace.FromContext(ctx)))
+		}
+	}
+	return breaker
+}
+
+func warn(err error) {
+	fmt.Fprintln(os.Stderr, i18n.T(i18n.Warning, err))
+}
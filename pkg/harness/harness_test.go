@@ -0,0 +1,113 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+func TestNewClient_SelectsProviderFromConfig(t *testing.T) {
+	for _, key := range []string{"AGENT_PROVIDER", "DASHSCOPE_MODEL", "DASHSCOPE_API_KEYS",
+		"AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_API_VERSION", "AZURE_OPENAI_DEPLOYMENT", "AZURE_OPENAI_API_KEY", "AZURE_OPENAI_AD_TOKEN", "AZURE_OPENAI_AUTH",
+		"GOOGLE_CLOUD_PROJECT", "GOOGLE_CLOUD_LOCATION", "GEMINI_MODEL", "GEMINI_ENDPOINT", "GEMINI_API_KEY", "GOOGLE_API_KEY", "GOOGLE_OAUTH_ACCESS_TOKEN",
+		"DEEPSEEK_API_KEY", "DEEPSEEK_BASE_URL", "DEEPSEEK_MODEL", "OPENROUTER_API_KEY", "OPENROUTER_BASE_URL", "OPENROUTER_MODEL"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DASHSCOPE_API_KEY", "key")
+	t.Setenv("DASHSCOPE_BASE_URL", "https://dashscope.example/v1")
+
+	cases := []struct {
+		name  string
+		cfg   config.Provider
+		env   map[string]string
+		model string
+	}{
+		{name: "qwen by default", model: "qwen-long"},
+		{name: "qwen model from env", cfg: config.Provider{Name: "qwen"}, env: map[string]string{"DASHSCOPE_MODEL": "qwen-max"}, model: "qwen-max"},
+		{
+			name:  "azure",
+			cfg:   config.Provider{Name: "azure", Azure: config.Azure{Endpoint: "https://x.openai.azure.com", Deployment: "gpt4o"}},
+			env:   map[string]string{"AZURE_OPENAI_API_KEY": "key"},
+			model: "gpt4o",
+		},
+		{
+			name:  "gemini",
+			cfg:   config.Provider{Name: "gemini", Gemini: config.Gemini{Model: "gemini-2.5-pro"}},
+			env:   map[string]string{"GEMINI_API_KEY": "key"},
+			model: "gemini-2.5-pro",
+		},
+		{
+			name:  "gemini on vertex",
+			cfg:   config.Provider{Name: "gemini", Gemini: config.Gemini{Project: "p", Location: "us-central1", Model: "gemini-2.5-flash"}},
+			env:   map[string]string{"GOOGLE_OAUTH_ACCESS_TOKEN": "token"},
+			model: "gemini-2.5-flash",
+		},
+		{
+			name:  "deepseek",
+			cfg:   config.Provider{Name: "deepseek"},
+			env:   map[string]string{"DEEPSEEK_API_KEY": "key"},
+			model: "deepseek-chat",
+		},
+		{
+			name:  "openrouter",
+			cfg:   config.Provider{Name: "openrouter", OpenRouter: config.OpenRouter{Model: "anthropic/claude-sonnet-4", Order: []string{"anthropic"}}},
+			env:   map[string]string{"OPENROUTER_API_KEY": "key"},
+			model: "anthropic/claude-sonnet-4",
+		},
+		{
+			name:  "environment overrides the config",
+			cfg:   config.Provider{Name: "openrouter"},
+			env:   map[string]string{"AGENT_PROVIDER": "deepseek", "DEEPSEEK_API_KEY": "key", "DEEPSEEK_MODEL": "deepseek-reasoner"},
+			model: "deepseek-reasoner",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			client, model, err := NewClient(tc.cfg)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			if client == nil || model != tc.model {
+				t.Fatalf("NewClient = %v, %q, want model %q", client, model, tc.model)
+			}
+		})
+	}
+
+	if _, _, err := NewClient(config.Provider{Name: "bedrock"}); err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+}
+
+func TestRegisterBaseTools_OffersGoToolsOnlyWithUnrestrictedBash(t *testing.T) {
+	t.Setenv("AGENT_SANDBOX", "")
+	cases := []struct {
+		name   string
+		cfg    config.Config
+		goTest bool
+	}{
+		{name: "unrestricted", goTest: true},
+		{name: "offline", cfg: config.Config{IsolateNetwork: true}},
+		{name: "limited", cfg: config.Config{Limits: sandbox.Limits{CPUSeconds: 10}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := tools.New()
+			if err := RegisterBaseTools(registry, t.TempDir(), tc.cfg); err != nil {
+				t.Fatalf("RegisterBaseTools: %v", err)
+			}
+			for _, name := range []string{"bash", "read_file", "write_file", "edit_file", "list_dir", "grep"} {
+				if !registry.Has(name) {
+					t.Errorf("%s is not registered", name)
+				}
+			}
+			if registry.Has("go_test") != tc.goTest || registry.Has("go_vet") != tc.goTest {
+				t.Errorf("go_test registered = %v, want %v", registry.Has("go_test"), tc.goTest)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package i18n translates the user-facing strings of the REPL: prompts,
+// warnings and permission dialogs. The language comes from the config
+// ("language") or, when unset, from LC_ALL, LC_MESSAGES or LANG:
+//
+//	i18n.SetLang(i18n.Detect(cfg.Language))
+//	fmt.Fprint(out, i18n.T(i18n.PermissionAllow))
+//
+// Log lines, tool results and anything the model reads stay in English.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Lang is a supported language.
+type Lang string
+
+const (
+	English Lang = "en"
+	Chinese Lang = "zh"
+)
+
+// Supported reports whether there is a bundle for lang.
+func Supported(lang Lang) bool {
+	_, ok := bundles[lang]
+	return ok
+}
+
+// Detect returns configured when it names a supported language, otherwise
+// the language of the first set locale variable, defaulting to English.
+func Detect(configured string) Lang {
+	if lang := Lang(strings.ToLower(strings.TrimSpace(configured))); Supported(lang) {
+		return lang
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return fromLocale(locale)
+		}
+	}
+	return English
+}
+
+// fromLocale maps a POSIX locale such as "zh_CN.UTF-8" to its language.
+func fromLocale(locale string) Lang {
+	fields := strings.FieldsFunc(locale, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == '@'
+	})
+	if len(fields) > 0 && Supported(Lang(strings.ToLower(fields[0]))) {
+		return Lang(strings.ToLower(fields[0]))
+	}
+	return English
+}
+
+var current atomic.Value // Lang
+
+func init() { current.Store(Detect("")) }
+
+// SetLang switches the language T uses. Unsupported languages fall back to
+// English.
+func SetLang(lang Lang) {
+	if !Supported(lang) {
+		lang = English
+	}
+	current.Store(lang)
+}
+
+// Current returns the language T uses.
+func Current() Lang { return current.Load().(Lang) }
+
+// T formats the message for key in the current language. A key missing
+// from the bundle falls back to English.
+func T(key Key, args ...any) string {
+	format, ok := bundles[Current()][key]
+	if !ok {
+		format = bundles[English][key]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+func TestBundles_HaveSameKeysAndVerbs(t *testing.T) {
+	for lang, bundle := range bundles {
+		if len(bundle) != len(bundles[English]) {
+			t.Errorf("%s has %d messages, en has %d", lang, len(bundle), len(bundles[English]))
+		}
+		for key, format := range bundle {
+			want, ok := bundles[English][key]
+			if !ok {
+				t.Errorf("%s: %s is missing from en", lang, key)
+				continue
+			}
+			if got, want := verb.FindAllString(format, -1), verb.FindAllString(want, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: %s uses verbs %v, en uses %v", lang, key, got, want)
+			}
+		}
+	}
+}
+
+func TestDetect_PrefersConfigThenLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "zh_CN.UTF-8")
+	if got := Detect(""); got != Chinese {
+		t.Fatalf("Detect with LANG=zh_CN = %q", got)
+	}
+	if got := Detect("en"); got != English {
+		t.Fatalf("configured en = %q", got)
+	}
+	t.Setenv("LC_ALL", "C")
+	if got := Detect(""); got != English {
+		t.Fatalf("LC_ALL=C should win over LANG: %q", got)
+	}
+	if got := Detect("fr"); got != English {
+		t.Fatalf("unsupported config = %q", got)
+	}
+}
+
+func TestT_FormatsInCurrentLanguage(t *testing.T) {
+	defer SetLang(Current())
+	SetLang(Chinese)
+	if got := T(MentionIncluded, "a.go", 12); got != "已引入 @a.go（12 字节）" {
+		t.Fatalf("zh = %q", got)
+	}
+	SetLang("fr")
+	if got := T(MentionIncluded, "a.go", 12); got != "included @a.go (12 bytes)" {
+		t.Fatalf("fallback = %q", got)
+	}
+}
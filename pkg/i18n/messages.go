@@ -0,0 +1,133 @@
+package i18n
+
+// Key names a translatable message. Messages with arguments are fmt
+// formats; every bundle must use the same verbs in the same order.
+type Key string
+
+// Startup and REPL messages.
+const (
+	NoDotEnv         Key = "no_dotenv"
+	Warning          Key = "warning"
+	Error            Key = "error"
+	FileWatcherError Key = "file_watcher_error"
+	AuditRequired    Key = "audit_required"
+	AuditDisabled    Key = "audit_disabled"
+	AuditLogPath     Key = "audit_log_path"
+	Plugins          Key = "plugins"
+	MCPTools         Key = "mcp_tools"
+	IgnoredSettings  Key = "ignored_settings"
+	TrustProject     Key = "trust_project"
+	UntrustedProject Key = "untrusted_project"
+	RunID            Key = "run_id"
+
+	Prompt                Key = "prompt"
+	PromptSkipPermissions Key = "prompt_skip_permissions"
+	PromptReadOnly        Key = "prompt_read_only"
+
+	ModelSwitched  Key = "model_switched"
+	ModelPaused    Key = "model_paused"
+	ModelAvailable Key = "model_available"
+
+	CommandError     Key = "command_error"
+	MentionSkipped   Key = "mention_skipped"
+	MentionTruncated Key = "mention_truncated"
+	MentionIncluded  Key = "mention_included"
+	LoopError        Key = "loop_error"
+)
+
+// Permission dialogs. The answer letters stay y/n/a/e in every language.
+const (
+	PermissionRequest   Key = "permission_request"
+	PermissionAllow     Key = "permission_allow"
+	PermissionAllowRule Key = "permission_allow_rule"
+	PermissionApply     Key = "permission_apply"
+	PermissionEditError Key = "permission_edit_error"
+	SkipWarning         Key = "skip_warning"
+)
+
+var bundles = map[Lang]map[Key]string{
+	English: {
+		NoDotEnv:         "no .env file found, using system env",
+		Warning:          "warning: %v",
+		Error:            "error: %v",
+		FileWatcherError: "file watcher: %v",
+		AuditRequired:    "error: --dangerously-skip-permissions requires the audit log: %v",
+		AuditDisabled:    "audit log disabled: %v",
+		AuditLogPath:     "audit log: %s",
+		Plugins:          "plugins: %s",
+		MCPTools:         "MCP tools: %s",
+		IgnoredSettings:  "warning: ignoring %s in the project config; set these in ~/.agent/settings.json or .agent/settings.local.json instead",
+		TrustProject:     "trust this project and run its plugins, MCP servers, schedules and webhooks",
+		UntrustedProject: "warning: skipped the plugins in %s and the MCP servers, schedules and webhooks of the project config: the project is not trusted or they changed; review them and run `agent trust`",
+		RunID:            "run id: %s",
+
+		Prompt:                "agent >> ",
+		PromptSkipPermissions: "agent [skip-permissions] >> ",
+		PromptReadOnly:        "agent [read-only] >> ",
+
+		ModelSwitched:  "model %s unavailable (%s), switching to %s %s",
+		ModelPaused:    "model %s unavailable (%s), pausing calls until %s %s",
+		ModelAvailable: "model %s is available again %s",
+
+		CommandError:     "command error: %v",
+		MentionSkipped:   "@%s not included: %s",
+		MentionTruncated: "included @%s (truncated to %d bytes)",
+		MentionIncluded:  "included @%s (%d bytes)",
+		LoopError:        "loop error: %v run=%s",
+
+		PermissionRequest:   "[permission] %s wants to: %s",
+		PermissionAllow:     "Allow? [y/N] ",
+		PermissionAllowRule: "Allow? [y]es / [N]o / [a]lways allow %s: ",
+		PermissionApply:     "Apply? [y]es / [n]o / [a]lways for this file / [e]dit: ",
+		PermissionEditError: "edit failed: %v",
+		SkipWarning: `################################################################
+#  WARNING: --dangerously-skip-permissions is active.          #
+#  Every write, command and query runs WITHOUT confirmation.   #
+#  Use this only inside a disposable container or CI job.      #
+#  Dangerous commands (rm -rf /, sudo, ...) are NOT blocked.  #
+#  All tool calls are recorded in the audit log.               #
+################################################################`,
+	},
+	Chinese: {
+		NoDotEnv:         "未找到 .env 文件，使用系统环境变量",
+		Warning:          "警告：%v",
+		Error:            "错误：%v",
+		FileWatcherError: "文件监听：%v",
+		AuditRequired:    "错误：--dangerously-skip-permissions 需要审计日志：%v",
+		AuditDisabled:    "审计日志已关闭：%v",
+		AuditLogPath:     "审计日志：%s",
+		Plugins:          "插件：%s",
+		MCPTools:         "MCP 工具：%s",
+		IgnoredSettings:  "警告：已忽略项目配置中的 %s，请改在 ~/.agent/settings.json 或 .agent/settings.local.json 中设置",
+		TrustProject:     "信任此项目并运行其插件、MCP 服务器、定时任务与 webhook",
+		UntrustedProject: "警告：已跳过 %s 中的插件与项目配置中的 MCP 服务器、定时任务与 webhook：项目未被信任或它们已改动；检查后运行 `agent trust`",
+		RunID:            "run id：%s",
+
+		Prompt:                "agent >> ",
+		PromptSkipPermissions: "agent [跳过审批] >> ",
+		PromptReadOnly:        "agent [只读] >> ",
+
+		ModelSwitched:  "模型 %s 不可用（%s），切换到 %s %s",
+		ModelPaused:    "模型 %s 不可用（%s），暂停调用至 %s %s",
+		ModelAvailable: "模型 %s 已恢复可用 %s",
+
+		CommandError:     "命令出错：%v",
+		MentionSkipped:   "@%s 未引入：%s",
+		MentionTruncated: "已引入 @%s（截断为 %d 字节）",
+		MentionIncluded:  "已引入 @%s（%d 字节）",
+		LoopError:        "循环出错：%v run=%s",
+
+		PermissionRequest:   "[审批] %s 请求：%s",
+		PermissionAllow:     "允许吗？[y/N] ",
+		PermissionAllowRule: "允许吗？[y] 是 / [N] 否 / [a] 始终允许 %s：",
+		PermissionApply:     "应用吗？[y] 是 / [n] 否 / [a] 此文件始终允许 / [e] 编辑：",
+		PermissionEditError: "编辑失败：%v",
+		SkipWarning: `################################################################
+#  警告：--dangerously-skip-permissions 已开启。
+#  所有写入、命令和查询都将在没有确认的情况下执行。
+#  仅在一次性容器或 CI 任务中使用。
+#  危险命令（rm -rf /、sudo 等）不再被拦截。
+#  所有工具调用都会记录在审计日志中。
+################################################################`,
+	},
+}
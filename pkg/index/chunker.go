@@ -0,0 +1,38 @@
+package index
+
+import "strings"
+
+const (
+	defaultChunkLines   = 60
+	defaultChunkOverlap = 10
+)
+
+// SplitLines cuts content into overlapping windows of at most size lines.
+// Blank-only windows are dropped since they carry no signal for retrieval.
+func SplitLines(path, content string, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = defaultChunkLines
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	chunks := make([]Chunk, 0, len(lines)/size+1)
+	for start := 0; start < len(lines); start += size - overlap {
+		end := min(start+size, len(lines))
+		body := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(body) != "" {
+			chunks = append(chunks, Chunk{
+				Path:      path,
+				StartLine: start + 1,
+				EndLine:   end,
+				Content:   body,
+			})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,40 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitLines_OverlappingWindows(t *testing.T) {
+	lines := make([]string, 25)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+
+	chunks := SplitLines("a.go", strings.Join(lines, "\n")+"\n", 10, 2)
+
+	want := [][2]int{{1, 10}, {9, 18}, {17, 25}}
+	if len(chunks) != len(want) {
+		t.Fatalf("len(chunks) = %d, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.StartLine != want[i][0] || chunk.EndLine != want[i][1] {
+			t.Fatalf("chunk %d range = %d-%d, want %d-%d", i, chunk.StartLine, chunk.EndLine, want[i][0], want[i][1])
+		}
+	}
+	if !strings.HasPrefix(chunks[1].Content, "line 9\n") {
+		t.Fatalf("unexpected chunk content: %q", chunks[1].Content)
+	}
+}
+
+func TestSplitLines_DropsBlankWindows(t *testing.T) {
+	chunks := SplitLines("a.go", "\n\n\n\nfunc main() {}\n", 2, 0)
+
+	if len(chunks) != 1 {
+		t.Fatalf("len(chunks) = %d, want 1: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Location() != "a.go:5-5" {
+		t.Fatalf("location = %q, want a.go:5-5", chunks[0].Location())
+	}
+}
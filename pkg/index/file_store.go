@@ -0,0 +1,69 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const storeFileName = "chunks.json"
+
+// FileStore persists the whole vector store as a single JSON document that is
+// replaced atomically on every Save.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create index dir: %w", err)
+	}
+	return &FileStore{path: filepath.Join(dir, storeFileName)}, nil
+}
+
+func (s *FileStore) Save(chunks []Chunk) error {
+	for _, chunk := range chunks {
+		if err := chunk.Validate(); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(chunks)
+	if err != nil {
+		return fmt.Errorf("marshal index: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write temp index file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename temp index file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Load() ([]Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Chunk{}, nil
+		}
+		return nil, fmt.Errorf("read index file: %w", err)
+	}
+
+	var chunks []Chunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return nil, fmt.Errorf("unmarshal index file: %w", err)
+	}
+	return chunks, nil
+}
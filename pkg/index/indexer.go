@@ -0,0 +1,368 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+)
+
+const (
+	defaultTopK       = 5
+	maxIndexFileBytes = 256 * 1024
+	maxEmbedChars     = 6000
+)
+
+var skippedDirs = map[string]bool{
+	".git":         true,
+	".local":       true,
+	".devtools":    true,
+	DefaultDir:     true,
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+}
+
+var indexedExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".rs": true, ".java": true, ".c": true, ".h": true, ".cc": true, ".cpp": true,
+	".rb": true, ".sh": true, ".sql": true, ".proto": true, ".md": true,
+	".yaml": true, ".yml": true, ".toml": true, ".json": true,
+}
+
+// Embedder turns texts into vectors, one per input, in input order.
+type Embedder interface {
+	Embeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type Store interface {
+	Save(chunks []Chunk) error
+	Load() ([]Chunk, error)
+}
+
+// Index builds and queries the vector store for one project root.
+type Index struct {
+	root     string
+	store    Store
+	embedder Embedder
+
+	// writeMu serializes Build and Update; mu guards the loaded chunks.
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	chunks  []Chunk
+	loaded  bool
+}
+
+func New(root string, store Store, embedder Embedder) (*Index, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, fmt.Errorf("index root is required")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("index store is required")
+	}
+	if embedder == nil {
+		return nil, fmt.Errorf("index embedder is required")
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve index root: %w", err)
+	}
+	return &Index{root: absRoot, store: store, embedder: embedder}, nil
+}
+
+// Build re-chunks and re-embeds every indexable file under the root and
+// replaces the stored index. It returns the number of chunks written.
+func (x *Index) Build(ctx context.Context) (int, error) {
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	chunks, err := x.collect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := x.embed(ctx, chunks); err != nil {
+		return 0, err
+	}
+	if err := x.store.Save(chunks); err != nil {
+		return 0, err
+	}
+
+	x.mu.Lock()
+	x.chunks = chunks
+	x.loaded = true
+	x.mu.Unlock()
+	return len(chunks), nil
+}
+
+// Update re-chunks and re-embeds only the changed files, drops the chunks of
+// removed ones and saves the store. It returns the number of chunks embedded.
+func (x *Index) Update(ctx context.Context, changes []fileindex.Change) (int, error) {
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	current, err := x.ensureLoaded(ctx)
+	if err != nil {
+		return 0, err
+	}
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	touched := make(map[string]bool, len(changes))
+	var fresh []Chunk
+	for _, change := range changes {
+		touched[change.Path] = true
+		if change.Op == fileindex.Removed || !indexable(change.Path) {
+			continue
+		}
+		chunks, err := x.readChunks(filepath.Join(x.root, filepath.FromSlash(change.Path)), change.Path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+		fresh = append(fresh, chunks...)
+	}
+	if err := x.embed(ctx, fresh); err != nil {
+		return 0, err
+	}
+
+	// Chunks loaded on first use may predate this call; start from the latest.
+	x.mu.Lock()
+	if x.loaded {
+		current = x.chunks
+	}
+	x.mu.Unlock()
+	chunks := make([]Chunk, 0, len(current)+len(fresh))
+	for _, chunk := range current {
+		if !touched[chunk.Path] {
+			chunks = append(chunks, chunk)
+		}
+	}
+	chunks = append(chunks, fresh...)
+	if err := x.store.Save(chunks); err != nil {
+		return 0, err
+	}
+
+	x.mu.Lock()
+	x.chunks = chunks
+	x.loaded = true
+	x.mu.Unlock()
+	return len(fresh), nil
+}
+
+// Watch keeps the index in sync with w. Changes whose update fails (e.g. the
+// embedding service is down) are retried with the next batch.
+func (x *Index) Watch(ctx context.Context, w *fileindex.Watcher) {
+	var mu sync.Mutex
+	pending := make(map[string]fileindex.Op)
+	w.Subscribe(func(_ []string, changes []fileindex.Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, change := range changes {
+			pending[change.Path] = change.Op
+		}
+		if len(pending) == 0 {
+			return
+		}
+		batch := make([]fileindex.Change, 0, len(pending))
+		for path, op := range pending {
+			batch = append(batch, fileindex.Change{Path: path, Op: op})
+		}
+		if _, err := x.Update(ctx, batch); err == nil {
+			clear(pending)
+		}
+	})
+}
+
+func (x *Index) embed(ctx context.Context, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = embeddingText(chunk)
+	}
+	vectors, err := x.embedder.Embeddings(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("embed chunks: %w", err)
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("embed chunks: got %d vectors for %d chunks", len(vectors), len(chunks))
+	}
+	for i := range chunks {
+		chunks[i].Embedding = vectors[i]
+	}
+	return nil
+}
+
+// Search returns the k chunks most similar to query. An empty store is built
+// on first use so callers do not need a separate indexing step.
+func (x *Index) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if k <= 0 {
+		k = defaultTopK
+	}
+
+	chunks, err := x.ensureLoaded(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return []Result{}, nil
+	}
+
+	vectors, err := x.embedder.Embeddings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embed query: expected 1 vector, got %d", len(vectors))
+	}
+	queryVector := vectors[0]
+
+	results := make([]Result, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != len(queryVector) {
+			continue
+		}
+		results = append(results, Result{Chunk: chunk, Score: cosine(queryVector, chunk.Embedding)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func (x *Index) ensureLoaded(ctx context.Context) ([]Chunk, error) {
+	x.mu.Lock()
+	if x.loaded {
+		chunks := x.chunks
+		x.mu.Unlock()
+		return chunks, nil
+	}
+	x.mu.Unlock()
+
+	chunks, err := x.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		if _, err := x.Build(ctx); err != nil {
+			return nil, err
+		}
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		return x.chunks, nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.chunks = chunks
+	x.loaded = true
+	return chunks, nil
+}
+
+func (x *Index) collect(ctx context.Context) ([]Chunk, error) {
+	chunks := make([]Chunk, 0)
+	err := filepath.WalkDir(x.root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != x.root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !indexedExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		rel, err := filepath.Rel(x.root, path)
+		if err != nil {
+			return err
+		}
+		fileChunks, err := x.readChunks(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, fileChunks...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk index root: %w", err)
+	}
+	return chunks, nil
+}
+
+// readChunks chunks one file; empty, oversized and binary files yield none.
+func (x *Index) readChunks(path, rel string) ([]Chunk, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 || info.Size() > maxIndexFileBytes {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, nil
+	}
+	return SplitLines(rel, string(data), defaultChunkLines, defaultChunkOverlap), nil
+}
+
+// indexable applies Build's directory and extension filters to a
+// slash-separated relative path.
+func indexable(rel string) bool {
+	dirs := strings.Split(rel, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if skippedDirs[dir] {
+			return false
+		}
+	}
+	return indexedExtensions[strings.ToLower(path.Ext(rel))]
+}
+
+func embeddingText(chunk Chunk) string {
+	text := chunk.Path + "\n" + chunk.Content
+	if len(text) > maxEmbedChars {
+		text = text[:maxEmbedChars]
+	}
+	return text
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
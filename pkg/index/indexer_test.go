@@ -0,0 +1,182 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+)
+
+func TestIndex_SearchBuildsOnFirstUseAndRanksRelevantFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "auth/login.go", "package auth\n\nfunc Login(password string) bool { return password != \"\" }\n")
+	writeFile(t, root, "render/html.go", "package render\n\nfunc HTML(body string) string { return body }\n")
+	writeFile(t, root, "node_modules/dep/index.js", "function password() {}\n")
+	writeFile(t, root, "logo.png", "\x89PNG\x00password")
+
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "index"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	idx, err := New(root, store, keywordEmbedder{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	results, err := idx.Search(context.Background(), "password login", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.Path != "auth/login.go" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored %d chunks, want 2 (skipped dirs and non-source files excluded)", len(stored))
+	}
+}
+
+func TestIndex_SearchReusesPersistedStore(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() { serve() }\n")
+
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	first, err := New(root, store, keywordEmbedder{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if n, err := first.Build(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Build = %d, %v; want 1, nil", n, err)
+	}
+
+	// 删除源文件后，新实例仍应从持久化的索引中命中
+	if err := os.Remove(filepath.Join(root, "main.go")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	second, err := New(root, store, keywordEmbedder{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	results, err := second.Search(context.Background(), "serve", 3)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.Location() != "main.go:1-3" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestNew_RequiresEmbedder(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if _, err := New(t.TempDir(), store, nil); err == nil {
+		t.Fatal("expected embedder error")
+	}
+}
+
+// keywordEmbedder 把文本映射到固定词表上的计数向量，足以验证排序逻辑。
+type keywordEmbedder struct{}
+
+var testVocabulary = []string{"password", "login", "html", "render", "serve", "main"}
+
+func (keywordEmbedder) Embeddings(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		lower := strings.ToLower(text)
+		vector := make([]float32, len(testVocabulary)+1)
+		for j, word := range testVocabulary {
+			vector[j] = float32(strings.Count(lower, word))
+		}
+		vector[len(testVocabulary)] = 0.01
+		out[i] = vector
+	}
+	return out, nil
+}
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", rel, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func TestIndex_UpdateReembedsOnlyChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "auth/login.go", "package auth\n\nfunc Login() {}\n")
+	writeFile(t, root, "render/html.go", "package render\n\nfunc HTML() {}\n")
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	embedder := &countingEmbedder{}
+	idx, err := New(root, store, embedder)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := idx.Build(context.Background()); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	writeFile(t, root, "auth/login.go", "package auth\n\nfunc Login(password string) {}\n")
+	writeFile(t, root, "node_modules/dep/index.js", "function password() {}\n")
+	if err := os.Remove(filepath.Join(root, "render/html.go")); err != nil {
+		t.Fatal(err)
+	}
+	embedder.texts = 0
+	n, err := idx.Update(context.Background(), []fileindex.Change{
+		{Path: "auth/login.go", Op: fileindex.Modified},
+		{Path: "node_modules/dep/index.js", Op: fileindex.Created},
+		{Path: "render/html.go", Op: fileindex.Removed},
+	})
+	if err != nil || n != 1 || embedder.texts != 1 {
+		t.Fatalf("Update = %d, %v (embedded %d texts); want 1 chunk re-embedded", n, err, embedder.texts)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var paths []string
+	for _, chunk := range stored {
+		paths = append(paths, chunk.Path)
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "auth/login.go,main.go" {
+		t.Fatalf("stored paths = %v", paths)
+	}
+	results, err := idx.Search(context.Background(), "password", 1)
+	if err != nil || len(results) != 1 || results[0].Chunk.Path != "auth/login.go" {
+		t.Fatalf("Search = %+v, %v", results, err)
+	}
+}
+
+// countingEmbedder 统计被嵌入的文本数，用于验证增量更新不会重新嵌入整个仓库。
+type countingEmbedder struct {
+	keywordEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.keywordEmbedder.Embeddings(ctx, texts)
+}
@@ -0,0 +1,40 @@
+// Package index chunks project source files, embeds them, and answers
+// semantic code search queries against a local vector store.
+package index
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDir is where the vector store lives, relative to the project root.
+const DefaultDir = ".index"
+
+type Chunk struct {
+	Path      string    `json:"path"`
+	StartLine int       `json:"startLine"`
+	EndLine   int       `json:"endLine"`
+	Content   string    `json:"content"`
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+func (c Chunk) Validate() error {
+	if strings.TrimSpace(c.Path) == "" {
+		return fmt.Errorf("chunk path is required")
+	}
+	if c.StartLine <= 0 || c.EndLine < c.StartLine {
+		return fmt.Errorf("invalid chunk line range %d-%d", c.StartLine, c.EndLine)
+	}
+	return nil
+}
+
+// Location formats the chunk as path:start-end for tool output.
+func (c Chunk) Location() string {
+	return fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine)
+}
+
+// Result is a single search hit ordered by descending Score.
+type Result struct {
+	Chunk Chunk   `json:"chunk"`
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,244 @@
+// Package jsonschema checks JSON values against the subset of JSON Schema
+// that structured model output uses: type, enum, const, properties,
+// required, additionalProperties, items, anyOf, the length and item count
+// bounds and minimum / maximum. Other keywords are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate reports the first place where value, as decoded by
+// encoding/json into an any, does not match schema. Paths in the error
+// start at $, e.g. $.files[2].path.
+func Validate(schema map[string]any, value any) error {
+	return validate(schema, value, "$")
+}
+
+// Check reports schemas that Validate cannot apply, such as an unknown type
+// name or a properties keyword that is not an object.
+func Check(schema map[string]any) error {
+	return check(schema, "$")
+}
+
+func validate(schema map[string]any, value any, path string) error {
+	if types := typeNames(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(value, t) }) {
+		return fmt.Errorf("%s: want %s, got %s", path, strings.Join(types, " or "), typeOf(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+		return fmt.Errorf("%s: %s is not one of %s", path, encode(value), encode(enum))
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		return fmt.Errorf("%s: want %s, got %s", path, encode(c), encode(value))
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var errs []string
+		for _, sub := range anyOf {
+			sub, _ := sub.(map[string]any)
+			err := validate(sub, value, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s: matches none of anyOf (%s)", path, strings.Join(errs, "; "))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return validateObject(schema, v, path)
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			return fmt.Errorf("%s: want at least %v items, got %d", path, n, len(v))
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			return fmt.Errorf("%s: want at most %v items, got %d", path, n, len(v))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(schema["minLength"]); ok && length < n {
+			return fmt.Errorf("%s: want at least %v characters, got %v", path, n, length)
+		}
+		if n, ok := number(schema["maxLength"]); ok && length > n {
+			return fmt.Errorf("%s: want at most %v characters, got %v", path, n, length)
+		}
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && v < n {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, v, n)
+		}
+		if n, ok := number(schema["maximum"]); ok && v > n {
+			return fmt.Errorf("%s: %v is greater than the maximum %v", path, v, n)
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string) error {
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if name, ok := name.(string); ok {
+			if _, present := obj[name]; !present {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := path + "." + name
+		if sub, ok := properties[name].(map[string]any); ok {
+			if err := validate(sub, obj[name], child); err != nil {
+				return err
+			}
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: unexpected property %q", path, name)
+			}
+		case map[string]any:
+			if err := validate(extra, obj[name], child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var knownTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+func check(schema map[string]any, path string) error {
+	for _, t := range typeNames(schema["type"]) {
+		if !slices.Contains(knownTypes, t) {
+			return fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	}
+	if raw, ok := schema["type"]; ok && len(typeNames(raw)) == 0 {
+		return fmt.Errorf("%s: type must be a string or an array of strings", path)
+	}
+	if raw, ok := schema["properties"]; ok {
+		properties, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: properties must be an object", path)
+		}
+		for name, sub := range properties {
+			sub, ok := sub.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s.%s: schema must be an object", path, name)
+			}
+			if err := check(sub, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if raw, ok := schema["required"]; ok {
+		if _, ok := raw.([]any); !ok {
+			return fmt.Errorf("%s: required must be an array", path)
+		}
+	}
+	if raw, ok := schema["items"]; ok {
+		items, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: items must be an object", path)
+		}
+		if err := check(items, path+"[]"); err != nil {
+			return err
+		}
+	}
+	if raw, ok := schema["anyOf"]; ok {
+		anyOf, ok := raw.([]any)
+		if !ok {
+			return fmt.Errorf("%s: anyOf must be an array", path)
+		}
+		for i, sub := range anyOf {
+			sub, ok := sub.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: anyOf[%d] must be an object", path, i)
+			}
+			if err := check(sub, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeNames(raw any) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var names []string
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	default:
+		return typeOf(value) == typ
+	}
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(raw any) (float64, bool) {
+	n, ok := raw.(float64)
+	return n, ok
+}
+
+func encode(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
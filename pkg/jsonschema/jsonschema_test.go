@@ -0,0 +1,69 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, raw string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"required": ["status", "files"],
+		"additionalProperties": false,
+		"properties": {
+			"status": {"enum": ["fixed", "unchanged"]},
+			"tests": {"type": "integer", "minimum": 0},
+			"files": {"type": "array", "minItems": 1, "items": {"type": "string", "minLength": 1}},
+			"note": {"type": ["string", "null"]}
+		}
+	}`).(map[string]any)
+	if err := Check(schema); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{`{"status":"fixed","files":["a.go"],"tests":3,"note":null}`, ""},
+		{`{"status":"fixed"}`, `$: missing required property "files"`},
+		{`{"status":"broken","files":["a.go"]}`, `$.status: "broken" is not one of ["fixed","unchanged"]`},
+		{`{"status":"fixed","files":[]}`, `$.files: want at least 1 items, got 0`},
+		{`{"status":"fixed","files":[""]}`, `$.files[0]: want at least 1 characters, got 0`},
+		{`{"status":"fixed","files":["a.go"],"tests":1.5}`, `$.tests: want integer, got number`},
+		{`{"status":"fixed","files":["a.go"],"extra":1}`, `$: unexpected property "extra"`},
+		{`"fixed"`, `$: want object, got string`},
+	} {
+		err := Validate(schema, decode(t, tc.value))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("Validate(%s) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCheck_RejectsMalformedSchema(t *testing.T) {
+	for _, raw := range []string{
+		`{"type": "text"}`,
+		`{"properties": []}`,
+		`{"properties": {"a": {"type": "strin"}}}`,
+		`{"items": true}`,
+	} {
+		if err := Check(decode(t, raw).(map[string]any)); err == nil || !strings.HasPrefix(err.Error(), "$") {
+			t.Errorf("Check(%s) = %v, want an error", raw, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultBreakerFailures = 3
+	defaultBreakerCoolDown = 30 * time.Second
+)
+
+// Circuit states of a model.
+const (
+	// CircuitClosed lets calls through.
+	CircuitClosed = "closed"
+	// CircuitOpen rejects calls until the cool-down ends.
+	CircuitOpen = "open"
+	// CircuitHalfOpen lets one trial call through to see whether the
+	// provider has recovered.
+	CircuitHalfOpen = "half_open"
+)
+
+// BreakerOptions configures a Breaker.
+type BreakerOptions struct {
+	// Failures is how many consecutive provider failures open the circuit
+	// (default 3).
+	Failures int
+	// CoolDown is how long an open circuit rejects calls before letting a
+	// trial call through (default 30s).
+	CoolDown time.Duration
+	// OnChange, when set, is told about every state change, with the
+	// context of the call that caused it.
+	OnChange func(ctx context.Context, status ModelStatus)
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// ModelStatus is the health of one model as the Breaker sees it.
+type ModelStatus struct {
+	Model string `json:"model"`
+	State string `json:"state"`
+	// Failures counts the consecutive provider failures.
+	Failures  int    `json:"failures"`
+	LastError string `json:"last_error,omitempty"`
+	// OpenUntil is when an open circuit lets a trial call through.
+	OpenUntil time.Time `json:"open_until,omitzero"`
+}
+
+// CircuitOpenError is returned, without calling the provider, for a model
+// whose circuit is open. Fallback moves on to the next model when it sees
+// one.
+type CircuitOpenError struct {
+	Model     string
+	Until     time.Time
+	LastError string
+}
+
+func (e *CircuitOpenError) Error() string {
+	msg := fmt.Sprintf("model %s is unavailable: circuit open until %s", e.Model, e.Until.Format(time.TimeOnly))
+	if e.LastError != "" {
+		msg += " after repeated failures: " + e.LastError
+	}
+	return msg
+}
+
+// Breaker is a circuit breaker per model: after Failures consecutive
+// provider failures (see Fallback for what counts), calls to the model fail
+// fast with a *CircuitOpenError for CoolDown, then one trial call decides
+// whether the circuit closes again or stays open. Keep one Breaker for the
+// life of the process so runs share what it learned.
+type Breaker struct {
+	opts BreakerOptions
+
+	mu     sync.Mutex
+	models map[string]*circuit
+}
+
+type circuit struct {
+	status ModelStatus
+	// trial is set while the half-open trial call is in flight.
+	trial bool
+}
+
+// NewBreaker returns a Breaker with every circuit closed.
+func NewBreaker(opts BreakerOptions) *Breaker {
+	if opts.Failures <= 0 {
+		opts.Failures = defaultBreakerFailures
+	}
+	if opts.CoolDown <= 0 {
+		opts.CoolDown = defaultBreakerCoolDown
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+	return &Breaker{opts: opts, models: make(map[string]*circuit)}
+}
+
+// Interceptor guards the calls that pass through it. Put it after Fallback
+// so an open circuit moves calls to the next model; targets with their own
+// client skip it.
+func (b *Breaker) Interceptor() Interceptor {
+	return Interceptor{
+		Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				if err := b.allow(ctx, params.Model); err != nil {
+					return nil, err
+				}
+				resp, err := next(ctx, params)
+				b.record(ctx, params.Model, err)
+				return resp, err
+			}
+		},
+		Stream: func(next StreamFunc) StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				if err := b.allow(ctx, params.Model); err != nil {
+					return ErrorStream(err)
+				}
+				return &breakerStream{ChunkStream: next(ctx, params), breaker: b, ctx: ctx, model: params.Model}
+			}
+		},
+	}
+}
+
+// Status returns the health of every model called so far, by name.
+func (b *Breaker) Status() []ModelStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statuses := make([]ModelStatus, 0, len(b.models))
+	for _, c := range b.models {
+		statuses = append(statuses, c.status)
+	}
+	slices.SortFunc(statuses, func(a, b ModelStatus) int { return strings.Compare(a.Model, b.Model) })
+	return statuses
+}
+
+// Check is an active health check: it asks model for a one-token reply,
+// even while the circuit is open, and records the outcome like any call.
+// A success closes the circuit.
+func (b *Breaker) Check(ctx context.Context, client *openai.Client, model string) (ModelStatus, error) {
+	_, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model:               shared.ChatModel(model),
+		Messages:            []openai.ChatCompletionMessageParamUnion{openai.UserMessage("ping")},
+		MaxCompletionTokens: openai.Int(1),
+	})
+	b.record(ctx, model, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.circuit(model).status, err
+}
+
+func (b *Breaker) circuit(model string) *circuit {
+	c, ok := b.models[model]
+	if !ok {
+		c = &circuit{status: ModelStatus{Model: model, State: CircuitClosed}}
+		b.models[model] = c
+	}
+	return c
+}
+
+// allow returns a *CircuitOpenError unless a call to model may go ahead.
+func (b *Breaker) allow(ctx context.Context, model string) error {
+	b.mu.Lock()
+	c := b.circuit(model)
+	switch {
+	case c.status.State == CircuitClosed:
+		b.mu.Unlock()
+		return nil
+	case c.status.State == CircuitOpen && !b.opts.now().Before(c.status.OpenUntil):
+		c.status.State = CircuitHalfOpen
+		c.trial = true
+		status := c.status
+		b.mu.Unlock()
+		b.notify(ctx, status)
+		return nil
+	case c.status.State == CircuitHalfOpen && !c.trial:
+		c.trial = true
+		b.mu.Unlock()
+		return nil
+	}
+	err := &CircuitOpenError{Model: model, Until: c.status.OpenUntil, LastError: c.status.LastError}
+	b.mu.Unlock()
+	return err
+}
+
+// record counts the outcome of a call to model. Errors that are not the
+// provider's fault, such as a canceled context or a bad request, count as
+// neither success nor failure.
+func (b *Breaker) record(ctx context.Context, model string, err error) {
+	reason, failed := "", false
+	if err != nil {
+		if reason, failed = fallbackReason(ctx, err); !failed {
+			b.mu.Lock()
+			b.circuit(model).trial = false
+			b.mu.Unlock()
+			return
+		}
+	}
+
+	b.mu.Lock()
+	c := b.circuit(model)
+	before := c.status.State
+	c.trial = false
+	if !failed {
+		c.status = ModelStatus{Model: model, State: CircuitClosed}
+	} else {
+		c.status.Failures++
+		c.status.LastError = reason
+		if before == CircuitHalfOpen || c.status.Failures >= b.opts.Failures {
+			c.status.State = CircuitOpen
+			c.status.OpenUntil = b.opts.now().Add(b.opts.CoolDown)
+		}
+	}
+	status := c.status
+	b.mu.Unlock()
+
+	if status.State != before || (before == CircuitOpen && failed) {
+		b.notify(ctx, status)
+	}
+}
+
+func (b *Breaker) notify(ctx context.Context, status ModelStatus) {
+	if b.opts.OnChange != nil {
+		b.opts.OnChange(ctx, status)
+	}
+}
+
+// breakerStream records the outcome of a stream once it ends.
+type breakerStream struct {
+	ChunkStream
+	breaker *Breaker
+	ctx     context.Context
+	model   string
+	done    bool
+}
+
+func (s *breakerStream) Next() bool {
+	if s.ChunkStream.Next() {
+		return true
+	}
+	if !s.done {
+		s.done = true
+		s.breaker.record(s.ctx, s.model, s.ChunkStream.Err())
+	}
+	return false
+}
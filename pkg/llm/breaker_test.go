@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// failWhile makes the test provider answer 503 for as long as *down is set.
+func failWhile(down *bool) option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !*down {
+			return next(req)
+		}
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rec.WriteString(`{"error":{"message":"overloaded"}}`)
+		resp := rec.Result()
+		resp.Request = req
+		return resp, nil
+	})
+}
+
+func TestBreaker_OpensFailsFastAndRecovers(t *testing.T) {
+	down := true
+	client, requests := newTestClient(t, failWhile(&down))
+	now := time.Unix(1000, 0)
+	var changes []string
+	breaker := NewBreaker(BreakerOptions{Failures: 2, CoolDown: time.Minute, now: func() time.Time { return now },
+		OnChange: func(_ context.Context, status ModelStatus) { changes = append(changes, status.State) }})
+	ctx := WithInterceptors(context.Background(), breaker.Interceptor())
+
+	for i := 0; i < 2; i++ {
+		if _, err := Complete(ctx, client, testParams()); err == nil {
+			t.Fatalf("Complete %d: want the provider error", i)
+		}
+	}
+	// The circuit is open: no request reaches the provider.
+	_, err := Complete(ctx, client, testParams())
+	var open *CircuitOpenError
+	if !errors.As(err, &open) || open.Model != "mock-model" || !strings.Contains(open.LastError, "503") {
+		t.Fatalf("err = %v, want a CircuitOpenError", err)
+	}
+	if len(*requests) != 0 {
+		t.Fatalf("provider saw %d requests", len(*requests))
+	}
+	if st := breaker.Status(); len(st) != 1 || st[0].State != CircuitOpen || st[0].Failures != 2 || !st[0].OpenUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("status = %+v", st)
+	}
+
+	// After the cool-down one trial call goes through; its success closes
+	// the circuit.
+	down = false
+	now = now.Add(time.Minute)
+	stream := Stream(ctx, client, testParams())
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("trial stream: %v", err)
+	}
+	if st := breaker.Status(); st[0].State != CircuitClosed || st[0].Failures != 0 {
+		t.Fatalf("status after recovery = %+v", st)
+	}
+	if got := strings.Join(changes, ","); got != "open,half_open,closed" {
+		t.Fatalf("changes = %s", got)
+	}
+}
+
+func TestBreaker_FailedTrialReopensAndRequestErrorsDoNotCount(t *testing.T) {
+	client, _ := newTestClient(t, failModels(http.StatusBadRequest, "mock-model"))
+	breaker := NewBreaker(BreakerOptions{Failures: 1})
+	ctx := WithInterceptors(context.Background(), breaker.Interceptor())
+	for i := 0; i < 3; i++ {
+		_, _ = Complete(ctx, client, testParams())
+	}
+	if st := breaker.Status(); st[0].State != CircuitClosed {
+		t.Fatalf("a bad request opened the circuit: %+v", st)
+	}
+
+	down := true
+	client, _ = newTestClient(t, failWhile(&down))
+	now := time.Unix(1000, 0)
+	breaker = NewBreaker(BreakerOptions{Failures: 1, CoolDown: time.Second, now: func() time.Time { return now }})
+	ctx = WithInterceptors(context.Background(), breaker.Interceptor())
+	_, _ = Complete(ctx, client, testParams())
+	now = now.Add(time.Second)
+	_, _ = Complete(ctx, client, testParams())
+	if st := breaker.Status(); st[0].State != CircuitOpen || !st[0].OpenUntil.Equal(now.Add(time.Second)) {
+		t.Fatalf("failed trial: status = %+v", st)
+	}
+}
+
+func TestBreaker_OpenCircuitMovesFallbackToNextModel(t *testing.T) {
+	client, requests := newTestClient(t, failModels(http.StatusServiceUnavailable, "mock-model"))
+	breaker := NewBreaker(BreakerOptions{Failures: 1, CoolDown: time.Hour})
+	var switches []Switch
+	chain := func() context.Context {
+		return WithInterceptors(context.Background(), Fallback([]Target{{Model: "backup"}}, func(_ context.Context, sw Switch) {
+			switches = append(switches, sw)
+		}), breaker.Interceptor())
+	}
+
+	// The first failure opens mock-model's circuit.
+	if _, err := Complete(chain(), client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// A fresh fallback chain skips the open circuit without a request.
+	if _, err := Complete(chain(), client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(switches) != 2 || switches[1].To != "backup" || !strings.Contains(switches[1].Reason, "circuit open") {
+		t.Fatalf("switches = %+v", switches)
+	}
+	if len(*requests) != 2 || !strings.Contains((*requests)[1], `"model":"backup"`) {
+		t.Fatalf("provider saw %v", *requests)
+	}
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"slices"
+
+	"github.com/openai/openai-go"
+)
+
+// PromptCache marks the leading system messages of every call as cacheable
+// with "cache_control": {"type": "ephemeral"}, the explicit prompt cache of
+// DashScope and of Anthropic models behind OpenAI-compatible gateways. The
+// system prompt, and the repo map in it, stay the same from call to call, so
+// later calls read that prefix from the cache at a lower price. Providers
+// report the hit in usage.prompt_tokens_details.cached_tokens.
+func PromptCache() Interceptor {
+	return Interceptor{
+		Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				return next(ctx, markCacheable(params))
+			}
+		},
+		Stream: func(next StreamFunc) StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				return next(ctx, markCacheable(params))
+			}
+		},
+	}
+}
+
+// markCacheable puts the cache marker on the last part of the last leading
+// system message: the provider caches everything up to the marker. The
+// caller's messages are not modified.
+func markCacheable(params openai.ChatCompletionNewParams) openai.ChatCompletionNewParams {
+	last := -1
+	for i, msg := range params.Messages {
+		if msg.OfSystem == nil {
+			break
+		}
+		last = i
+	}
+	if last < 0 {
+		return params
+	}
+
+	system := *params.Messages[last].OfSystem
+	parts := slices.Clone(system.Content.OfArrayOfContentParts)
+	if len(parts) == 0 {
+		if system.Content.OfString.Value == "" {
+			return params
+		}
+		parts = []openai.ChatCompletionContentPartTextParam{{Text: system.Content.OfString.Value}}
+	}
+	parts[len(parts)-1].SetExtraFields(map[string]any{
+		"cache_control": map[string]any{"type": "ephemeral"},
+	})
+	system.Content = openai.ChatCompletionSystemMessageParamContentUnion{OfArrayOfContentParts: parts}
+
+	params.Messages = slices.Clone(params.Messages)
+	params.Messages[last] = openai.ChatCompletionMessageParamUnion{OfSystem: &system}
+	return params
+}
@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestPromptCache_MarksSystemPrompt(t *testing.T) {
+	client, requests := newTestClient(t)
+	params := testParams()
+	params.Messages = []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are a coding agent."),
+		openai.SystemMessage("repo map: main.go"),
+		openai.UserMessage("hello"),
+	}
+	ctx := WithInterceptors(context.Background(), PromptCache())
+
+	if _, err := Complete(ctx, client, params); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	var body struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte((*requests)[0]), &body); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if got := string(body.Messages[0].Content); got != `"You are a coding agent."` {
+		t.Fatalf("first system message = %s, want it unmarked", got)
+	}
+	want := `[{"text":"repo map: main.go","type":"text","cache_control":{"type":"ephemeral"}}]`
+	if got := string(body.Messages[1].Content); got != want {
+		t.Fatalf("last system message = %s, want %s", got, want)
+	}
+	if got := string(body.Messages[2].Content); got != `"hello"` {
+		t.Fatalf("user message = %s", got)
+	}
+	if params.Messages[1].OfSystem.Content.OfString.Value == "" {
+		t.Fatalf("caller's messages were modified")
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// Capabilities is what a model can do through its provider. The agent loop
+// adapts to the ones missing (see loop.Run).
+type Capabilities struct {
+	// Tools is native function calling. Without it the tools are described
+	// in the system prompt and the calls parsed from the reply (see ReAct).
+	Tools bool
+	// ParallelToolCalls is several tool calls in one assistant message.
+	// Without it each call is replayed to the model as its own turn.
+	ParallelToolCalls bool
+	// Vision is image input.
+	Vision bool
+	// JSONMode is response_format json_object; JSONSchema is json_schema.
+	JSONMode   bool
+	JSONSchema bool
+	// MaxContextTokens is the context window; 0 means unknown.
+	MaxContextTokens int
+}
+
+// defaultCapabilities are assumed for models not in knownModels: what the
+// OpenAI-compatible APIs the agent was written against support.
+var defaultCapabilities = Capabilities{Tools: true, ParallelToolCalls: true, JSONMode: true, JSONSchema: true}
+
+// knownModels lists what differs from defaultCapabilities, by model name
+// prefix; the longest matching prefix wins.
+var knownModels = map[string]func(*Capabilities){
+	"qwen-plus":         contextWindow(131072),
+	"qwen-max":          contextWindow(32768),
+	"qwen-turbo":        contextWindow(1000000),
+	"qwen-long":         func(c *Capabilities) { c.Tools, c.MaxContextTokens = false, 10000000 },
+	"qwen-vl":           func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 131072 },
+	"qwen3-coder":       contextWindow(262144),
+	"gpt-4o":            func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 128000 },
+	"gpt-4.1":           func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 1047576 },
+	"gpt-5":             func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 400000 },
+	"claude-":           func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 200000 },
+	"gemini-":           func(c *Capabilities) { c.Vision, c.MaxContextTokens = true, 1048576 },
+	"deepseek-chat":     func(c *Capabilities) { c.JSONSchema, c.MaxContextTokens = false, 131072 },
+	"deepseek-reasoner": func(c *Capabilities) { c.JSONSchema, c.MaxContextTokens = false, 131072 },
+	"deepseek-r1":       func(c *Capabilities) { c.Tools, c.MaxContextTokens = false, 131072 },
+	"llama3":            func(c *Capabilities) { c.Tools, c.ParallelToolCalls, c.MaxContextTokens = false, false, 8192 },
+	"llama3.1":          func(c *Capabilities) { c.ParallelToolCalls, c.MaxContextTokens = false, 131072 },
+	"llama3.2":          func(c *Capabilities) { c.ParallelToolCalls, c.MaxContextTokens = false, 131072 },
+	"llama3.3":          func(c *Capabilities) { c.ParallelToolCalls, c.MaxContextTokens = false, 131072 },
+	"gemma":             func(c *Capabilities) { c.Tools, c.ParallelToolCalls = false, false },
+}
+
+func contextWindow(tokens int) func(*Capabilities) {
+	return func(c *Capabilities) { c.MaxContextTokens = tokens }
+}
+
+// ModelCapabilities returns what model is known to support. Gateway names
+// like "meta-llama/llama3.1" and tags like "llama3:8b" match too.
+func ModelCapabilities(model string) Capabilities {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if _, after, ok := strings.Cut(name, "/"); ok {
+		name = after
+	}
+	caps := defaultCapabilities
+	best := ""
+	for prefix := range knownModels {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		knownModels[best](&caps)
+	}
+	return caps
+}
+
+// CapabilityFunc reports the capabilities of a model.
+type CapabilityFunc func(model string) Capabilities
+
+type capabilitiesKey struct{}
+
+// WithCapabilities returns a context whose model calls are adapted to the
+// capabilities f reports, e.g. provider.Capabilities with the overrides of
+// the project config.
+func WithCapabilities(ctx context.Context, f CapabilityFunc) context.Context {
+	return context.WithValue(ctx, capabilitiesKey{}, f)
+}
+
+// CapabilitiesFor returns the capabilities of model: those reported by the
+// context's CapabilityFunc, else ModelCapabilities.
+func CapabilitiesFor(ctx context.Context, model string) Capabilities {
+	if f, ok := ctx.Value(capabilitiesKey{}).(CapabilityFunc); ok && f != nil {
+		return f(model)
+	}
+	return ModelCapabilities(model)
+}
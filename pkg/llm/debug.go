@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	// DefaultDebugDir is relative to the project root.
+	DefaultDebugDir = ".agent/debug"
+	redactedValue   = "[REDACTED]"
+)
+
+var sensitiveHeaderFragments = []string{"authorization", "key", "token", "secret", "cookie"}
+
+// DebugDumper writes the exact request and response of every LLM call to
+// numbered files, NNNN-request.json and NNNN-response.json, in one directory
+// per process run. Credentials in headers are redacted, and each request dump
+// carries the run and span ID of the call (see package trace).
+//
+// Install it on the client:
+//
+//	dumper, err := llm.NewDebugDumper(llm.DefaultDebugDir)
+//	client, err := qwen.NewClient(option.WithMiddleware(dumper.Middleware))
+//
+// It works at the HTTP layer rather than as an Interceptor so the dump shows
+// what was actually sent, after the SDK serialized the params.
+type DebugDumper struct {
+	dir string
+	seq atomic.Int64
+}
+
+type dumpedRequest struct {
+	trace.IDs
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    any               `json:"body"`
+}
+
+type dumpedResponse struct {
+	Status     int               `json:"status"`
+	Headers    map[string]string `json:"headers"`
+	DurationMS int64             `json:"duration_ms"`
+	Body       any               `json:"body"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// NewDebugDumper creates a fresh timestamped directory under root.
+func NewDebugDumper(root string) (*DebugDumper, error) {
+	dir := filepath.Join(root, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create debug dir: %w", err)
+	}
+	return &DebugDumper{dir: dir}, nil
+}
+
+// Dir returns the directory the dumps are written to.
+func (d *DebugDumper) Dir() string {
+	return d.dir
+}
+
+// Middleware is an option.Middleware for openai.NewClient. Dump failures never
+// fail the call.
+func (d *DebugDumper) Middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	prefix := filepath.Join(d.dir, fmt.Sprintf("%04d", d.seq.Add(1)))
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	writeDump(prefix+"-request.json", dumpedRequest{
+		IDs:     trace.FromContext(req.Context()),
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: redactHeaders(req.Header),
+		Body:    dumpBody(body),
+	})
+
+	start := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		writeDump(prefix+"-response.json", dumpedResponse{
+			DurationMS: time.Since(start).Milliseconds(),
+			Error:      err.Error(),
+		})
+		return resp, err
+	}
+
+	// Streaming bodies are consumed incrementally; dump once the SDK closes them.
+	resp.Body = &teeBody{
+		ReadCloser: resp.Body,
+		onClose: func(data []byte) {
+			writeDump(prefix+"-response.json", dumpedResponse{
+				Status:     resp.StatusCode,
+				Headers:    redactHeaders(resp.Header),
+				DurationMS: time.Since(start).Milliseconds(),
+				Body:       dumpBody(data),
+			})
+		},
+	}
+	return resp, nil
+}
+
+type teeBody struct {
+	io.ReadCloser
+	buf     bytes.Buffer
+	once    sync.Once
+	onClose func([]byte)
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	b.once.Do(func() { b.onClose(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
+
+// dumpBody keeps JSON bodies as JSON and everything else (SSE streams, error
+// pages) as text.
+func dumpBody(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	return string(data)
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		lower := strings.ToLower(name)
+		for _, fragment := range sensitiveHeaderFragments {
+			if strings.Contains(lower, fragment) {
+				value = redactedValue
+				break
+			}
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func writeDump(path string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, append(data, '\n'), 0o600)
+}
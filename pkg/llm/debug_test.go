@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go/option"
+)
+
+func TestDebugDumper_WritesRequestAndResponse(t *testing.T) {
+	dumper, err := NewDebugDumper(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDebugDumper: %v", err)
+	}
+	client, _ := newTestClient(t, option.WithMiddleware(dumper.Middleware))
+
+	ctx := trace.WithSpan(trace.WithRun(context.Background(), "run-1"))
+	if _, err := Complete(ctx, client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	stream := Stream(context.Background(), client, testParams())
+	for stream.Next() {
+	}
+	_ = stream.Close()
+
+	request := readDump(t, dumper.Dir(), "0001-request.json")
+	if !strings.Contains(request, `"model": "mock-model"`) || !strings.Contains(request, `"content": "hello"`) {
+		t.Fatalf("request dump missing body:\n%s", request)
+	}
+	if !strings.Contains(request, `"run_id": "run-1"`) || !strings.Contains(request, `"span_id": "`+trace.FromContext(ctx).SpanID+`"`) {
+		t.Fatalf("request dump missing trace IDs:\n%s", request)
+	}
+	if strings.Contains(request, "test-key") || !strings.Contains(request, redactedValue) {
+		t.Fatalf("API key not redacted:\n%s", request)
+	}
+	if response := readDump(t, dumper.Dir(), "0001-response.json"); !strings.Contains(response, `"prompt_tokens": 3`) {
+		t.Fatalf("response dump missing body:\n%s", response)
+	}
+	if response := readDump(t, dumper.Dir(), "0002-response.json"); !strings.Contains(response, "data: [DONE]") {
+		t.Fatalf("stream dump missing SSE body:\n%s", response)
+	}
+}
+
+func readDump(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
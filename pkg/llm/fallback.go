@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// Target is one model in a fallback chain. A nil Client sends the call to the
+// caller's client; set one to fall back to another provider, e.g. a local
+// Ollama server. Calls to another client skip the interceptors that come
+// after Fallback in the chain.
+type Target struct {
+	Model  string
+	Client *openai.Client
+}
+
+// Switch records that calls moved from one model to the next in the chain.
+type Switch struct {
+	At     time.Time
+	From   string
+	To     string
+	Reason string
+}
+
+// Fallback retries a failed call on the next target when the provider is
+// unavailable: unreachable, rejecting the credentials or model, failing with
+// 5xx, still answering 429 after the client's own retries, or behind an open
+// circuit (see Breaker). The call's own model is tried first. Once a switch
+// happens the interceptor stays on the new model, so a run does not keep
+// waiting on a provider that is down; onSwitch (which may be nil) is told
+// about every switch.
+//
+// Errors that would fail on any model, such as a malformed request or a
+// canceled context, are returned unchanged.
+func Fallback(targets []Target, onSwitch func(ctx context.Context, sw Switch)) Interceptor {
+	chain := &fallbackChain{targets: targets, onSwitch: onSwitch}
+	return Interceptor{
+		Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				for {
+					i := chain.current()
+					resp, err := chain.complete(ctx, next, params, i)
+					if err == nil || !chain.advance(ctx, params, i, err) {
+						return resp, err
+					}
+				}
+			}
+		},
+		Stream: func(next StreamFunc) StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				i := chain.current()
+				return &fallbackStream{
+					ChunkStream: chain.stream(ctx, next, params, i),
+					chain:       chain,
+					ctx:         ctx,
+					next:        next,
+					params:      params,
+					target:      i,
+				}
+			}
+		},
+	}
+}
+
+type fallbackChain struct {
+	targets  []Target
+	onSwitch func(ctx context.Context, sw Switch)
+
+	mu sync.Mutex
+	// active is 0 for the call's own model, i for targets[i-1].
+	active int
+}
+
+func (c *fallbackChain) current() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+func (c *fallbackChain) model(params openai.ChatCompletionNewParams, i int) string {
+	if i == 0 {
+		return params.Model
+	}
+	return c.targets[i-1].Model
+}
+
+func (c *fallbackChain) request(params openai.ChatCompletionNewParams, i int) (openai.ChatCompletionNewParams, *openai.Client) {
+	if i == 0 {
+		return params, nil
+	}
+	target := c.targets[i-1]
+	params.Model = target.Model
+	return params, target.Client
+}
+
+func (c *fallbackChain) complete(ctx context.Context, next CompleteFunc, params openai.ChatCompletionNewParams, i int) (*openai.ChatCompletion, error) {
+	params, client := c.request(params, i)
+	if client != nil {
+		return client.Chat.Completions.New(ctx, params)
+	}
+	return next(ctx, params)
+}
+
+func (c *fallbackChain) stream(ctx context.Context, next StreamFunc, params openai.ChatCompletionNewParams, i int) ChunkStream {
+	params, client := c.request(params, i)
+	if client != nil {
+		return client.Chat.Completions.NewStreaming(ctx, params)
+	}
+	return next(ctx, params)
+}
+
+// advance moves past target i after it failed with err and reports whether
+// there is another target to try.
+func (c *fallbackChain) advance(ctx context.Context, params openai.ChatCompletionNewParams, i int, err error) bool {
+	reason, ok := fallbackReason(ctx, err)
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	if i >= len(c.targets) {
+		c.mu.Unlock()
+		return false
+	}
+	// Another call may already have moved the chain further.
+	switched := c.active == i
+	if switched {
+		c.active = i + 1
+	}
+	c.mu.Unlock()
+
+	if switched && c.onSwitch != nil {
+		c.onSwitch(ctx, Switch{At: time.Now(), From: c.model(params, i), To: c.targets[i].Model, Reason: reason})
+	}
+	return true
+}
+
+// fallbackReason describes err when another model might succeed.
+func fallbackReason(ctx context.Context, err error) (string, bool) {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "", false
+	}
+	var open *CircuitOpenError
+	if errors.As(err, &open) {
+		return "circuit open after repeated failures", true
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == 429:
+			return "rate limited (429) after retries", true
+		case code >= 500:
+			return fmt.Sprintf("provider error (%d)", code), true
+		case code == 401 || code == 403 || code == 404:
+			return fmt.Sprintf("model unavailable (%d)", code), true
+		}
+		return "", false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return "provider unreachable: " + urlErr.Err.Error(), true
+	}
+	return "", false
+}
+
+// fallbackStream reopens the stream on the next target when it fails before
+// yielding anything; once chunks have been delivered, errors are final.
+type fallbackStream struct {
+	ChunkStream
+	chain  *fallbackChain
+	ctx    context.Context
+	next   StreamFunc
+	params openai.ChatCompletionNewParams
+	target int
+	chunks int
+}
+
+func (s *fallbackStream) Next() bool {
+	for {
+		if s.ChunkStream.Next() {
+			s.chunks++
+			return true
+		}
+		err := s.ChunkStream.Err()
+		if err == nil || s.chunks > 0 || !s.chain.advance(s.ctx, s.params, s.target, err) {
+			return false
+		}
+		_ = s.ChunkStream.Close()
+		s.target = s.chain.current()
+		s.ChunkStream = s.chain.stream(s.ctx, s.next, s.params, s.target)
+	}
+}
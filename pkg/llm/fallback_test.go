@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// failModels makes the test provider answer status for requests naming one
+// of the given models.
+func failModels(status int, models ...string) option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		body, _ := req.GetBody()
+		data, _ := io.ReadAll(body)
+		for _, model := range models {
+			if strings.Contains(string(data), `"model":"`+model+`"`) {
+				rec := httptest.NewRecorder()
+				rec.WriteHeader(status)
+				_, _ = rec.WriteString(`{"error":{"message":"unavailable"}}`)
+				resp := rec.Result()
+				resp.Request = req
+				return resp, nil
+			}
+		}
+		return next(req)
+	})
+}
+
+func TestFallback_SwitchesOnSustained429AndStays(t *testing.T) {
+	client, requests := newTestClient(t, failModels(http.StatusTooManyRequests, "mock-model"))
+	var switches []Switch
+	ctx := WithInterceptors(context.Background(), Fallback([]Target{{Model: "backup"}}, func(_ context.Context, sw Switch) {
+		switches = append(switches, sw)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := Complete(ctx, client, testParams()); err != nil {
+			t.Fatalf("Complete %d: %v", i, err)
+		}
+	}
+	if len(switches) != 1 || switches[0].From != "mock-model" || switches[0].To != "backup" || !strings.Contains(switches[0].Reason, "429") {
+		t.Fatalf("switches = %+v", switches)
+	}
+	// The second call goes straight to the backup model.
+	if len(*requests) != 2 || !strings.Contains((*requests)[1], `"model":"backup"`) {
+		t.Fatalf("provider saw %v", *requests)
+	}
+}
+
+func TestFallback_StreamsFromNextTargetAndOtherClient(t *testing.T) {
+	client, _ := newTestClient(t, failModels(http.StatusServiceUnavailable, "mock-model"))
+	local, localRequests := newTestClient(t)
+	ctx := WithInterceptors(context.Background(), Fallback([]Target{{Model: "llama3", Client: local}}, nil))
+
+	stream := Stream(ctx, client, testParams())
+	chunks := 0
+	for stream.Next() {
+		chunks++
+	}
+	if err := stream.Err(); err != nil || chunks != 1 {
+		t.Fatalf("stream chunks=%d err=%v", chunks, err)
+	}
+	_ = stream.Close()
+	if len(*localRequests) != 1 || !strings.Contains((*localRequests)[0], `"model":"llama3"`) {
+		t.Fatalf("local provider saw %v", *localRequests)
+	}
+}
+
+func TestFallback_KeepsRequestErrorsAndExhaustedChains(t *testing.T) {
+	client, _ := newTestClient(t, failModels(http.StatusBadRequest, "mock-model"))
+	ctx := WithInterceptors(context.Background(), Fallback([]Target{{Model: "backup"}}, func(context.Context, Switch) {
+		t.Error("a bad request must not switch models")
+	}))
+	var apiErr *openai.Error
+	if _, err := Complete(ctx, client, testParams()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want the 400", err)
+	}
+
+	client, _ = newTestClient(t, failModels(http.StatusInternalServerError, "mock-model", "backup"))
+	ctx = WithInterceptors(context.Background(), Fallback([]Target{{Model: "backup"}}, nil))
+	if _, err := Complete(ctx, client, testParams()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want the last target's 500", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package llm routes chat completion calls through an interceptor chain, the
+// provider-layer analogue of HTTP middleware. Request mutation, response
+// inspection, logging, caching and fault injection are written once as
+// interceptors and attached to the context:
+//
+//	ctx = llm.WithInterceptors(ctx, llm.Logging(log.Printf))
+//	resp, err := llm.Complete(ctx, client, params)
+package llm
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// ChunkStream iterates over a streaming completion.
+// *ssestream.Stream[openai.ChatCompletionChunk] implements it.
+type ChunkStream interface {
+	Next() bool
+	Current() openai.ChatCompletionChunk
+	Err() error
+	Close() error
+}
+
+// CompleteFunc performs one non-streaming chat completion.
+type CompleteFunc func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error)
+
+// StreamFunc opens one streaming chat completion.
+type StreamFunc func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream
+
+// Interceptor wraps completion calls. Either field may be nil to leave that
+// kind of call untouched.
+type Interceptor struct {
+	Complete func(next CompleteFunc) CompleteFunc
+	Stream   func(next StreamFunc) StreamFunc
+}
+
+type interceptorsKey struct{}
+
+// WithInterceptors returns a context whose completion calls run through ic in
+// addition to the interceptors already attached. Earlier interceptors wrap
+// later ones, so the first one sees the request first and the response last.
+func WithInterceptors(ctx context.Context, ic ...Interceptor) context.Context {
+	existing := interceptorsFrom(ctx)
+	chain := make([]Interceptor, 0, len(existing)+len(ic))
+	chain = append(chain, existing...)
+	chain = append(chain, ic...)
+	return context.WithValue(ctx, interceptorsKey{}, chain)
+}
+
+func interceptorsFrom(ctx context.Context) []Interceptor {
+	chain, _ := ctx.Value(interceptorsKey{}).([]Interceptor)
+	return chain
+}
+
+// Complete calls client.Chat.Completions.New through the context's interceptors.
+func Complete(ctx context.Context, client *openai.Client, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	call := CompleteFunc(func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+		return client.Chat.Completions.New(ctx, params)
+	})
+	chain := interceptorsFrom(ctx)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Complete != nil {
+			call = chain[i].Complete(call)
+		}
+	}
+	return call(ctx, params)
+}
+
+// Stream calls client.Chat.Completions.NewStreaming through the context's
+// interceptors.
+func Stream(ctx context.Context, client *openai.Client, params openai.ChatCompletionNewParams) ChunkStream {
+	call := StreamFunc(func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+		return client.Chat.Completions.NewStreaming(ctx, params)
+	})
+	chain := interceptorsFrom(ctx)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Stream != nil {
+			call = chain[i].Stream(call)
+		}
+	}
+	return call(ctx, params)
+}
+
+// ErrorStream returns a stream that yields no chunks and reports err, for
+// interceptors that fail a streaming call without reaching the provider.
+func ErrorStream(err error) ChunkStream {
+	return ssestream.NewStream[openai.ChatCompletionChunk](nil, err)
+}
+
+// Logging logs the model, message count, duration, token usage and error of
+// every call, followed by the trace IDs of ctx (see trace.FromContext).
+// Streaming calls are logged when the stream is closed.
+func Logging(logf func(format string, args ...any)) Interceptor {
+	return Interceptor{
+		Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				start := time.Now()
+				resp, err := next(ctx, params)
+				var usage openai.CompletionUsage
+				if resp != nil {
+					usage = resp.Usage
+				}
+				logf("llm complete model=%s messages=%d duration=%s prompt_tokens=%d completion_tokens=%d err=%v%s",
+					params.Model, len(params.Messages), time.Since(start).Round(time.Millisecond),
+					usage.PromptTokens, usage.CompletionTokens, err, traceFields(ctx))
+				return resp, err
+			}
+		},
+		Stream: func(next StreamFunc) StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				return &loggedStream{
+					ChunkStream: next(ctx, params),
+					logf:        logf,
+					model:       params.Model,
+					messages:    len(params.Messages),
+					start:       time.Now(),
+					trace:       traceFields(ctx),
+				}
+			}
+		},
+	}
+}
+
+type loggedStream struct {
+	ChunkStream
+	logf     func(format string, args ...any)
+	model    string
+	messages int
+	start    time.Time
+	trace    string
+	chunks   int
+}
+
+func (s *loggedStream) Next() bool {
+	if !s.ChunkStream.Next() {
+		return false
+	}
+	s.chunks++
+	return true
+}
+
+func (s *loggedStream) Close() error {
+	s.logf("llm stream model=%s messages=%d duration=%s chunks=%d err=%v%s",
+		s.model, s.messages, time.Since(s.start).Round(time.Millisecond), s.chunks, s.ChunkStream.Err(), s.trace)
+	return s.ChunkStream.Close()
+}
+
+// traceFields returns the trace IDs of ctx as trailing log fields.
+func traceFields(ctx context.Context) string {
+	if ids := trace.FromContext(ctx).String(); ids != "" {
+		return " " + ids
+	}
+	return ""
+}
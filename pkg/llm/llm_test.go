@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestComplete_RunsInterceptorsInOrder(t *testing.T) {
+	client, requests := newTestClient(t)
+
+	var trace []string
+	tag := func(name string) Interceptor {
+		return Interceptor{Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				trace = append(trace, name+" before")
+				resp, err := next(ctx, params)
+				trace = append(trace, name+" after")
+				return resp, err
+			}
+		}}
+	}
+	ctx := WithInterceptors(context.Background(), tag("outer"))
+	ctx = WithInterceptors(ctx, tag("inner"))
+
+	if _, err := Complete(ctx, client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	want := "outer before,inner before,inner after,outer after"
+	if got := strings.Join(trace, ","); got != want {
+		t.Fatalf("trace = %s, want %s", got, want)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("provider calls = %d, want 1", len(*requests))
+	}
+}
+
+func TestComplete_InterceptorMutatesRequest(t *testing.T) {
+	client, requests := newTestClient(t)
+	ctx := WithInterceptors(context.Background(), Interceptor{Complete: func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+			params.Model = "rewritten-model"
+			return next(ctx, params)
+		}
+	}})
+
+	if _, err := Complete(ctx, client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !strings.Contains((*requests)[0], `"model":"rewritten-model"`) {
+		t.Fatalf("request was not rewritten: %s", (*requests)[0])
+	}
+}
+
+func TestFaultInjection_SkipsProvider(t *testing.T) {
+	client, requests := newTestClient(t)
+	injected := errors.New("injected failure")
+	ctx := WithInterceptors(context.Background(), Interceptor{
+		Complete: func(CompleteFunc) CompleteFunc {
+			return func(context.Context, openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				return nil, injected
+			}
+		},
+		Stream: func(StreamFunc) StreamFunc {
+			return func(context.Context, openai.ChatCompletionNewParams) ChunkStream {
+				return ErrorStream(injected)
+			}
+		},
+	})
+
+	if _, err := Complete(ctx, client, testParams()); !errors.Is(err, injected) {
+		t.Fatalf("Complete error = %v, want injected", err)
+	}
+	stream := Stream(ctx, client, testParams())
+	if stream.Next() || !errors.Is(stream.Err(), injected) {
+		t.Fatalf("stream error = %v, want injected", stream.Err())
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Fatalf("provider calls = %d, want 0", len(*requests))
+	}
+}
+
+func TestLogging_LogsCompleteAndStream(t *testing.T) {
+	client, _ := newTestClient(t)
+	var lines []string
+	ctx := WithInterceptors(trace.WithRun(context.Background(), "run-1"), Logging(func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}))
+
+	if _, err := Complete(ctx, client, testParams()); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	stream := Stream(ctx, client, testParams())
+	for stream.Next() {
+	}
+	_ = stream.Close()
+
+	if len(lines) != 2 {
+		t.Fatalf("log lines = %v", lines)
+	}
+	if !strings.Contains(lines[0], "llm complete model=mock-model") || !strings.Contains(lines[0], "prompt_tokens=3") {
+		t.Fatalf("unexpected complete log: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "llm stream model=mock-model") || !strings.Contains(lines[1], "chunks=1") {
+		t.Fatalf("unexpected stream log: %s", lines[1])
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, " run=run-1") {
+			t.Fatalf("log line without the run ID: %s", line)
+		}
+	}
+}
+
+// newTestClient serves a fixed completion, or a one-chunk SSE stream when the
+// request asks for streaming, and records request bodies.
+func newTestClient(t *testing.T, opts ...option.RequestOption) (*openai.Client, *[]string) {
+	t.Helper()
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+
+		if strings.Contains(string(body), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			chunk := map[string]any{
+				"id": "chunk-1", "object": "chat.completion.chunk", "created": 0, "model": "mock-model",
+				"choices": []map[string]any{{"index": 0, "delta": map[string]any{"content": "hi"}, "finish_reason": "stop"}},
+			}
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "mock-id", "object": "chat.completion", "created": 0, "model": "mock-model",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "hi"}}},
+			"usage":   map[string]any{"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := openai.NewClient(append([]option.RequestOption{
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(srv.URL + "/v1/"),
+		option.WithMaxRetries(0),
+	}, opts...)...)
+	return &client, &requests
+}
+
+func testParams() openai.ChatCompletionNewParams {
+	return openai.ChatCompletionNewParams{
+		Model:    shared.ChatModel("mock-model"),
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")},
+	}
+}
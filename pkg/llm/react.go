@@ -0,0 +1,455 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// reactInstructions teach a model without native function calling the text
+// protocol ReAct parses; %s is the list of tool names.
+const reactInstructions = `Answer in exactly this format, with nothing before or after it:
+
+Thought: what to do next and why
+Action: the tool to use, one of [%s]
+Action Input: the tool's arguments as a JSON object on one line
+
+Then stop. The tool's result comes back as "Observation: ...". Use one Action per reply. When no more tools are needed, answer with:
+
+Thought: why you are done
+Final Answer: your answer to the user
+
+Tools (Action Input must match the JSON schema):
+`
+
+// reactStop keeps the model from inventing the tool's result.
+const reactStop = "\nObservation:"
+
+// ReAct grammar labels. Each starts a line.
+const (
+	labelThought     = "Thought:"
+	labelAction      = "Action:"
+	labelActionInput = "Action Input:"
+	labelObservation = "Observation:"
+	labelFinalAnswer = "Final Answer:"
+)
+
+type reactKey struct{}
+
+// ReActParseError is returned by a ReAct model call whose reply breaks the
+// grammar, so the caller can show the model Problem and ask again.
+type ReActParseError struct {
+	Reply   string
+	Problem string
+}
+
+func (e *ReActParseError) Error() string {
+	return "reply does not follow the ReAct format: " + e.Problem
+}
+
+// Correction is the message asking the model to repeat its reply in the
+// ReAct format.
+func (e *ReActParseError) Correction() string {
+	return fmt.Sprintf("Your reply could not be used: %s.\n"+
+		"Reply again using exactly the Thought / Action / Action Input or Thought / Final Answer format.", e.Problem)
+}
+
+// ReAct emulates function calling for models without it, with the
+// Thought / Action / Action Input / Observation protocol of ReAct prompting:
+// the tools of a request are described in its system prompt, earlier tool
+// calls and results are rendered in the protocol, and the reply is parsed
+// back into a tool call or a final answer. Callers see the same messages as
+// with native tool calling. A reply that breaks the grammar fails the call
+// with a *ReActParseError.
+//
+// A streamed reply is only passed on once complete, since it cannot be
+// parsed before it ends.
+func ReAct() Interceptor {
+	return Interceptor{
+		Complete: func(next CompleteFunc) CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				req, tools := reactRequest(params)
+				resp, err := next(ctx, req)
+				if err != nil || tools == nil || resp == nil || len(resp.Choices) == 0 {
+					return resp, err
+				}
+				choice := &resp.Choices[0]
+				if err := reactChoice(&choice.Message, &choice.FinishReason, tools); err != nil {
+					return nil, err
+				}
+				return resp, nil
+			}
+		},
+		Stream: func(next StreamFunc) StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				req, tools := reactRequest(params)
+				stream := next(ctx, req)
+				if tools == nil {
+					return stream
+				}
+				return &reactStream{inner: stream, tools: tools}
+			}
+		},
+	}
+}
+
+// WithReAct attaches ReAct to ctx unless it is already there.
+func WithReAct(ctx context.Context) context.Context {
+	if ctx.Value(reactKey{}) != nil {
+		return ctx
+	}
+	return WithInterceptors(context.WithValue(ctx, reactKey{}, true), ReAct())
+}
+
+// reactRequest rewrites params for a model without tools. It returns the
+// names of the tools the reply may call, or nil when the reply is passed
+// on as it is.
+func reactRequest(params openai.ChatCompletionNewParams) (openai.ChatCompletionNewParams, []string) {
+	hasTools := len(params.Tools) > 0
+	if !hasTools && !slices.ContainsFunc(params.Messages, func(m openai.ChatCompletionMessageParamUnion) bool {
+		return m.OfTool != nil || (m.OfAssistant != nil && len(m.OfAssistant.ToolCalls) > 0)
+	}) {
+		return params, nil
+	}
+
+	var messages []openai.ChatCompletionMessageParamUnion
+	var observations []string
+	flush := func() {
+		if len(observations) > 0 {
+			messages = append(messages, openai.UserMessage(strings.Join(observations, "\n\n")))
+			observations = nil
+		}
+	}
+	for _, msg := range params.Messages {
+		switch {
+		case msg.OfTool != nil:
+			observations = append(observations, labelObservation+" "+toolText(msg.OfTool))
+			continue
+		case msg.OfAssistant != nil && len(msg.OfAssistant.ToolCalls) > 0:
+			flush()
+			var lines []string
+			if thought := strings.TrimSpace(assistantText(msg.OfAssistant)); thought != "" {
+				lines = append(lines, labelThought+" "+thought)
+			}
+			for _, call := range msg.OfAssistant.ToolCalls {
+				lines = append(lines, labelAction+" "+call.Function.Name, labelActionInput+" "+argumentsJSON(call.Function.Arguments))
+			}
+			messages = append(messages, openai.AssistantMessage(strings.Join(lines, "\n")))
+			continue
+		}
+		flush()
+		messages = append(messages, msg)
+	}
+	flush()
+
+	names := []string{}
+	if hasTools {
+		var b strings.Builder
+		for _, tool := range params.Tools {
+			names = append(names, tool.Function.Name)
+			schema, _ := json.Marshal(tool.Function.Parameters)
+			fmt.Fprintf(&b, "\n- %s", tool.Function.Name)
+			if desc := tool.Function.Description.Value; desc != "" {
+				fmt.Fprintf(&b, ": %s", desc)
+			}
+			fmt.Fprintf(&b, "\n  input schema: %s", schema)
+		}
+		messages = withSystemText(messages, fmt.Sprintf(reactInstructions, strings.Join(names, ", "))+b.String())
+		if params.Stop.OfString.Value == "" && len(params.Stop.OfStringArray) == 0 {
+			params.Stop.OfStringArray = []string{reactStop}
+		}
+	}
+
+	params.Messages = messages
+	params.Tools = nil
+	params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{}
+	params.ParallelToolCalls = openai.ChatCompletionNewParams{}.ParallelToolCalls
+	return params, names
+}
+
+// withSystemText appends text to the first system message, or starts the
+// conversation with one.
+func withSystemText(messages []openai.ChatCompletionMessageParamUnion, text string) []openai.ChatCompletionMessageParamUnion {
+	for i, msg := range messages {
+		if msg.OfSystem == nil {
+			continue
+		}
+		system := *msg.OfSystem
+		if parts := system.Content.OfArrayOfContentParts; len(parts) > 0 {
+			system.Content.OfArrayOfContentParts = append(slices.Clone(parts), openai.ChatCompletionContentPartTextParam{Text: "\n\n" + text})
+		} else {
+			system.Content.OfString = openai.String(system.Content.OfString.Value + "\n\n" + text)
+		}
+		messages[i] = openai.ChatCompletionMessageParamUnion{OfSystem: &system}
+		return messages
+	}
+	return append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(text)}, messages...)
+}
+
+func toolText(msg *openai.ChatCompletionToolMessageParam) string {
+	if len(msg.Content.OfArrayOfContentParts) == 0 {
+		return msg.Content.OfString.Value
+	}
+	var texts []string
+	for _, p := range msg.Content.OfArrayOfContentParts {
+		texts = append(texts, p.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func assistantText(msg *openai.ChatCompletionAssistantMessageParam) string {
+	if len(msg.Content.OfArrayOfContentParts) == 0 {
+		return msg.Content.OfString.Value
+	}
+	var texts []string
+	for _, p := range msg.Content.OfArrayOfContentParts {
+		if p.OfText != nil {
+			texts = append(texts, p.OfText.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// argumentsJSON returns arguments as JSON, quoting them if they are not.
+func argumentsJSON(arguments string) string {
+	if strings.TrimSpace(arguments) == "" {
+		return "{}"
+	}
+	if json.Valid([]byte(arguments)) {
+		return arguments
+	}
+	quoted, _ := json.Marshal(arguments)
+	return string(quoted)
+}
+
+// reactChoice parses msg.Content: an Action becomes a tool call with the
+// Thought as content, a Final Answer the content. Without tools to call
+// (e.g. a wrap-up call) any reply is accepted and only a Final Answer label
+// is removed.
+func reactChoice(msg *openai.ChatCompletionMessage, finishReason *string, tools []string) error {
+	step, problem := ParseReAct(msg.Content, tools)
+	if problem != "" {
+		if len(tools) == 0 {
+			return nil
+		}
+		return &ReActParseError{Reply: msg.Content, Problem: problem}
+	}
+	if step.Action == "" {
+		msg.Content = step.FinalAnswer
+		return nil
+	}
+	msg.Content = step.Thought
+	msg.ToolCalls = []openai.ChatCompletionMessageToolCall{{
+		ID:       newCallID(),
+		Function: openai.ChatCompletionMessageToolCallFunction{Name: step.Action, Arguments: step.ActionInput},
+	}}
+	*finishReason = "tool_calls"
+	return nil
+}
+
+// ReActStep is one parsed ReAct reply: an Action with its input, or a
+// Final Answer.
+type ReActStep struct {
+	Thought     string
+	Action      string
+	ActionInput string
+	FinalAnswer string
+}
+
+// ParseReAct parses a reply in the ReAct grammar:
+//
+//	[Thought: text]
+//	Action: tool
+//	Action Input: {JSON object}
+//
+// or
+//
+//	[Thought: text]
+//	Final Answer: text
+//
+// Labels start a line and values run until the next label. Text before the
+// first label counts as thought, and everything from an Observation on is
+// ignored, since the model wrote it instead of the tool. The action must be
+// one of tools. For a reply that breaks the grammar, problem says why.
+func ParseReAct(reply string, tools []string) (step ReActStep, problem string) {
+	type section struct{ label, value string }
+	var (
+		sections []section
+		lead     []string
+	)
+	for _, line := range strings.Split(reply, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, labelObservation) {
+			break
+		}
+		label := ""
+		// Action Input goes before Action, its prefix.
+		for _, l := range []string{labelThought, labelActionInput, labelAction, labelFinalAnswer} {
+			if strings.HasPrefix(trimmed, l) {
+				label = l
+				break
+			}
+		}
+		switch {
+		case label != "":
+			sections = append(sections, section{label: label, value: strings.TrimPrefix(trimmed, label)})
+		case len(sections) == 0:
+			lead = append(lead, line)
+		default:
+			sections[len(sections)-1].value += "\n" + line
+		}
+	}
+
+	count := map[string]int{}
+	thoughts := []string{strings.TrimSpace(strings.Join(lead, "\n"))}
+	for _, s := range sections {
+		value := strings.TrimSpace(s.value)
+		count[s.label]++
+		switch s.label {
+		case labelThought:
+			thoughts = append(thoughts, value)
+		case labelAction:
+			step.Action = strings.Trim(value, "`\"' ")
+		case labelActionInput:
+			step.ActionInput = value
+		case labelFinalAnswer:
+			step.FinalAnswer = value
+		}
+	}
+	step.Thought = strings.Join(slices.DeleteFunc(thoughts, func(t string) bool { return t == "" }), "\n")
+
+	switch {
+	case count[labelFinalAnswer] > 0 && count[labelAction] > 0:
+		return step, "it has both an Action and a Final Answer; give one of them"
+	case count[labelAction] > 1:
+		return step, "it has several Actions; give one Action per reply and wait for its Observation"
+	case count[labelFinalAnswer] > 1:
+		return step, "it has several Final Answers"
+	case count[labelFinalAnswer] == 1:
+		if step.FinalAnswer == "" {
+			return step, "the Final Answer is empty"
+		}
+		return step, ""
+	case count[labelAction] == 0:
+		return step, `it has neither an "Action:" nor a "Final Answer:" line`
+	}
+
+	if !slices.Contains(tools, step.Action) {
+		return step, fmt.Sprintf("%q is not a tool; the Action must be one of [%s]", step.Action, strings.Join(tools, ", "))
+	}
+	if count[labelActionInput] != 1 {
+		return step, `the Action needs exactly one "Action Input:" line`
+	}
+	input := step.ActionInput
+	if strings.HasPrefix(input, "```") {
+		input = strings.TrimPrefix(strings.TrimPrefix(input, "```json"), "```")
+		input = strings.TrimSpace(strings.TrimSuffix(input, "```"))
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return step, fmt.Sprintf("the Action Input is not a JSON object (%v)", err)
+	}
+	step.ActionInput = input
+	return step, ""
+}
+
+func newCallID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "call_" + hex.EncodeToString(b[:])
+}
+
+// reactStream reads the whole reply, then yields it as one chunk with its
+// tool call, followed by a usage chunk when the provider sent usage.
+type reactStream struct {
+	inner  ChunkStream
+	tools  []string
+	chunks []openai.ChatCompletionChunk
+	err    error
+	read   bool
+	next   int
+}
+
+func (s *reactStream) Next() bool {
+	if !s.read {
+		s.read = true
+		s.chunks, s.err = s.collect()
+	}
+	if s.next >= len(s.chunks) {
+		return false
+	}
+	s.next++
+	return true
+}
+
+func (s *reactStream) collect() ([]openai.ChatCompletionChunk, error) {
+	var (
+		text   strings.Builder
+		last   openai.ChatCompletionChunk
+		finish string
+		usage  *openai.CompletionUsage
+	)
+	for s.inner.Next() {
+		chunk := s.inner.Current()
+		last = chunk
+		for _, c := range chunk.Choices {
+			text.WriteString(c.Delta.Content)
+			if c.FinishReason != "" {
+				finish = c.FinishReason
+			}
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			u := chunk.Usage
+			usage = &u
+		}
+	}
+	if err := s.inner.Err(); err != nil {
+		return nil, err
+	}
+
+	msg := openai.ChatCompletionMessage{Content: text.String()}
+	if err := reactChoice(&msg, &finish, s.tools); err != nil {
+		return nil, err
+	}
+	delta := openai.ChatCompletionChunkChoiceDelta{Role: "assistant", Content: msg.Content}
+	for i, call := range msg.ToolCalls {
+		delta.ToolCalls = append(delta.ToolCalls, openai.ChatCompletionChunkChoiceDeltaToolCall{
+			Index:    int64(i),
+			ID:       call.ID,
+			Type:     "function",
+			Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{Name: call.Function.Name, Arguments: call.Function.Arguments},
+		})
+	}
+	chunks := []openai.ChatCompletionChunk{{
+		ID:      last.ID,
+		Model:   last.Model,
+		Created: last.Created,
+		Choices: []openai.ChatCompletionChunkChoice{{Delta: delta, FinishReason: finish}},
+	}}
+	if usage != nil {
+		chunks = append(chunks, openai.ChatCompletionChunk{ID: last.ID, Model: last.Model, Created: last.Created, Usage: *usage})
+	}
+	return chunks, nil
+}
+
+func (s *reactStream) Current() openai.ChatCompletionChunk {
+	if s.next == 0 || s.next > len(s.chunks) {
+		return openai.ChatCompletionChunk{}
+	}
+	return s.chunks[s.next-1]
+}
+
+func (s *reactStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.inner.Err()
+}
+
+func (s *reactStream) Close() error { return s.inner.Close() }
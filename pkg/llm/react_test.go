@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+const reactReply = "Thought: Let me look.\nAction: read_file\nAction Input: {\"path\": \"a.go\"}\nObservation: package a"
+
+// textModel stands in for a model without function calling: it records the
+// request and answers with reply, as a completion or as a stream of two
+// chunks.
+func textModel(got *openai.ChatCompletionNewParams, reply string) Interceptor {
+	return Interceptor{
+		Complete: func(CompleteFunc) CompleteFunc {
+			return func(_ context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				*got = params
+				return &openai.ChatCompletion{Choices: []openai.ChatCompletionChoice{{
+					FinishReason: "stop",
+					Message:      openai.ChatCompletionMessage{Role: "assistant", Content: reply},
+				}}}, nil
+			}
+		},
+		Stream: func(StreamFunc) StreamFunc {
+			return func(_ context.Context, params openai.ChatCompletionNewParams) ChunkStream {
+				*got = params
+				half := len(reply) / 2
+				return &sliceStream{chunks: []openai.ChatCompletionChunk{
+					{ID: "c1", Choices: []openai.ChatCompletionChunkChoice{{Delta: openai.ChatCompletionChunkChoiceDelta{Content: reply[:half]}}}},
+					{ID: "c1", Choices: []openai.ChatCompletionChunkChoice{{Delta: openai.ChatCompletionChunkChoiceDelta{Content: reply[half:]}, FinishReason: "stop"}}},
+				}}
+			}
+		},
+	}
+}
+
+type sliceStream struct {
+	chunks []openai.ChatCompletionChunk
+	i      int
+}
+
+func (s *sliceStream) Next() bool                          { s.i++; return s.i <= len(s.chunks) }
+func (s *sliceStream) Current() openai.ChatCompletionChunk { return s.chunks[s.i-1] }
+func (s *sliceStream) Err() error                          { return nil }
+func (s *sliceStream) Close() error                        { return nil }
+
+func reactParams() openai.ChatCompletionNewParams {
+	return openai.ChatCompletionNewParams{
+		Model: "llama3",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("You are a coding agent."),
+			openai.UserMessage("Fix a.go"),
+			{OfAssistant: &openai.ChatCompletionAssistantMessageParam{ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+				ID:       "call_1",
+				Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "list_dir", Arguments: `{"path":"."}`},
+			}}}},
+			openai.ToolMessage("a.go", "call_1"),
+		},
+		Tools: []openai.ChatCompletionToolParam{{Function: openai.FunctionDefinitionParam{
+			Name:        "read_file",
+			Description: openai.String("Read a file."),
+			Parameters:  openai.FunctionParameters{"type": "object"},
+		}}},
+	}
+}
+
+func TestReAct_PromptsToolsAndParsesCalls(t *testing.T) {
+	var sent openai.ChatCompletionNewParams
+	ctx := WithInterceptors(WithReAct(context.Background()), textModel(&sent, reactReply))
+
+	resp, err := Complete(ctx, nil, reactParams())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "Let me look." || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v", choice)
+	}
+	if call := choice.Message.ToolCalls[0]; call.Function.Name != "read_file" || call.Function.Arguments != `{"path": "a.go"}` || call.ID == "" {
+		t.Fatalf("tool call = %+v", call)
+	}
+
+	if len(sent.Tools) != 0 || len(sent.Messages) != 4 {
+		t.Fatalf("request still uses native tools: %+v", sent)
+	}
+	if system := sent.Messages[0].OfSystem.Content.OfString.Value; !strings.HasPrefix(system, "You are a coding agent.") || !strings.Contains(system, "- read_file: Read a file.") {
+		t.Fatalf("system prompt = %q", system)
+	}
+	if stop := sent.Stop.OfStringArray; len(stop) != 1 || stop[0] != "\nObservation:" {
+		t.Fatalf("stop = %v", stop)
+	}
+	if call := sent.Messages[2].OfAssistant; call == nil || len(call.ToolCalls) != 0 || call.Content.OfString.Value != "Action: list_dir\nAction Input: {\"path\":\".\"}" {
+		t.Fatalf("earlier call = %+v", sent.Messages[2])
+	}
+	if result := sent.Messages[3].OfUser; result == nil || result.Content.OfString.Value != "Observation: a.go" {
+		t.Fatalf("earlier result = %+v", sent.Messages[3])
+	}
+}
+
+func TestReAct_StreamsParsedCalls(t *testing.T) {
+	var sent openai.ChatCompletionNewParams
+	ctx := WithInterceptors(WithReAct(WithReAct(context.Background())), textModel(&sent, reactReply))
+
+	stream := Stream(ctx, nil, reactParams())
+	var acc openai.ChatCompletionAccumulator
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	choice := acc.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "Let me look." || len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Name != "read_file" {
+		t.Fatalf("choice = %+v", choice)
+	}
+	// WithReAct twice still prompts the tools once.
+	if system := sent.Messages[0].OfSystem.Content.OfString.Value; strings.Count(system, "- read_file:") != 1 {
+		t.Fatalf("system prompt = %q", system)
+	}
+}
+
+func TestReAct_LeavesPlainRepliesAlone(t *testing.T) {
+	var sent openai.ChatCompletionNewParams
+	ctx := WithInterceptors(WithReAct(context.Background()), textModel(&sent, "Thought: Fixed.\nFinal Answer: a.go is fixed.\nIt compiles."))
+
+	resp, err := Complete(ctx, nil, reactParams())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if choice := resp.Choices[0]; choice.FinishReason != "stop" || choice.Message.Content != "a.go is fixed.\nIt compiles." || len(choice.Message.ToolCalls) != 0 {
+		t.Fatalf("choice = %+v", choice)
+	}
+}
+
+func TestReAct_RejectsRepliesBreakingTheGrammar(t *testing.T) {
+	var sent openai.ChatCompletionNewParams
+	ctx := WithInterceptors(WithReAct(context.Background()), textModel(&sent, "I will read a.go now."))
+
+	_, err := Complete(ctx, nil, reactParams())
+	var parseErr *ReActParseError
+	if !errors.As(err, &parseErr) || parseErr.Reply != "I will read a.go now." || !strings.Contains(parseErr.Correction(), "neither") {
+		t.Fatalf("err = %v", err)
+	}
+
+	stream := Stream(ctx, nil, reactParams())
+	for stream.Next() {
+		t.Fatal("a broken reply must not be streamed")
+	}
+	if !errors.As(stream.Err(), &parseErr) {
+		t.Fatalf("stream err = %v", stream.Err())
+	}
+}
+
+func TestParseReAct(t *testing.T) {
+	tools := []string{"bash", "read_file"}
+	cases := map[string]struct {
+		reply   string
+		want    ReActStep
+		problem string
+	}{
+		"action": {
+			reply: "I should list files.\nThought: use ls\nAction: `bash`\nAction Input: {\"command\": \"ls\"}",
+			want:  ReActStep{Thought: "I should list files.\nuse ls", Action: "bash", ActionInput: `{"command": "ls"}`},
+		},
+		"fenced input": {
+			reply: "Action: bash\nAction Input: ```json\n{\"command\": \"ls\"}\n```",
+			want:  ReActStep{Action: "bash", ActionInput: `{"command": "ls"}`},
+		},
+		"invented observation": {
+			reply: "Action: bash\nAction Input: {\"command\": \"ls\"}\nObservation: a.go\nFinal Answer: done",
+			want:  ReActStep{Action: "bash", ActionInput: `{"command": "ls"}`},
+		},
+		"final":          {reply: "Thought: done\nFinal Answer: 42", want: ReActStep{Thought: "done", FinalAnswer: "42"}},
+		"unknown tool":   {reply: "Action: python\nAction Input: {}", problem: `"python" is not a tool`},
+		"no input":       {reply: "Action: bash", problem: "Action Input"},
+		"not an object":  {reply: "Action: bash\nAction Input: ls -la", problem: "not a JSON object"},
+		"two actions":    {reply: "Action: bash\nAction Input: {}\nAction: bash\nAction Input: {}", problem: "several Actions"},
+		"action + final": {reply: "Action: bash\nAction Input: {}\nFinal Answer: done", problem: "both"},
+		"plain text":     {reply: "Hello!", problem: "neither"},
+	}
+	for name, tc := range cases {
+		step, problem := ParseReAct(tc.reply, tools)
+		if tc.problem != "" {
+			if !strings.Contains(problem, tc.problem) {
+				t.Errorf("%s: problem = %q, want %q", name, problem, tc.problem)
+			}
+			continue
+		}
+		if problem != "" || step != tc.want {
+			t.Errorf("%s: ParseReAct = %+v, %q; want %+v", name, step, problem, tc.want)
+		}
+	}
+}
+
+func TestModelCapabilities(t *testing.T) {
+	cases := map[string]Capabilities{
+		"llama3:8b":                  {ParallelToolCalls: false, JSONMode: true, JSONSchema: true, MaxContextTokens: 8192},
+		"meta-llama/llama3.1-70b":    {Tools: true, JSONMode: true, JSONSchema: true, MaxContextTokens: 131072},
+		"gpt-4o-mini":                {Tools: true, ParallelToolCalls: true, Vision: true, JSONMode: true, JSONSchema: true, MaxContextTokens: 128000},
+		"some-new-model":             defaultCapabilities,
+		"deepseek/deepseek-reasoner": {Tools: true, ParallelToolCalls: true, JSONMode: true, MaxContextTokens: 131072},
+	}
+	for model, want := range cases {
+		if got := ModelCapabilities(model); got != want {
+			t.Errorf("ModelCapabilities(%q) = %+v, want %+v", model, got, want)
+		}
+	}
+
+	ctx := WithCapabilities(context.Background(), func(string) Capabilities { return Capabilities{} })
+	if got := CapabilitiesFor(ctx, "gpt-4o"); got != (Capabilities{}) {
+		t.Errorf("CapabilitiesFor ignored the context: %+v", got)
+	}
+}
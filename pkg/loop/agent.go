@@ -4,13 +4,18 @@ package loop
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/packages/ssestream"
 	"github.com/openai/openai-go/shared"
@@ -25,6 +30,30 @@ import (
 //	ctx = devtools.WithRecorder(ctx, devtools.NewRecorderFromEnv())
 //	loop.Run(ctx, client, model, messages, registry)
 //
+// When a budget.Tracker is attached via budget.WithTracker, token usage is
+// recorded after every call. Once a limit is crossed, pending tool calls are
+// skipped, the model is asked to wrap up without tools, and Run returns a
+// *budget.ExceededError describing the overage.
+//
+// Model calls go through the interceptors attached via llm.WithInterceptors.
+//
+// With WithPruning, each request sends a priority-pruned copy of the history
+// (see PruneMessages); the returned history stays complete.
+//
+// A turn limit attached via WithMaxTurns stops the loop with ErrMaxTurns
+// before the model call that would exceed it.
+//
+// Requests are adapted to what the model supports (see llm.CapabilitiesFor):
+// without native tool calling the tools are prompted ReAct-style (see
+// llm.ReAct) and a reply breaking its grammar is sent back with the problem,
+// up to twice, before Run fails; without parallel tool calls each call is
+// replayed as its own turn; without vision images are replaced by a note; and
+// with a known context window the history is pruned to fit it unless
+// WithPruning is set.
+//
+// Each turn runs in its own trace span (see trace.WithSpan), under the run
+// of ctx or a new one, so its model calls and tool calls share a span ID.
+//
 // To automatically manage BeginRun/FinishRun for a single top-level task, use
 // RunWithManagedTrace at the application layer.
 //
@@ -37,16 +66,28 @@ func Run(
 	messages []openai.ChatCompletionMessageParamUnion,
 	registry *tools.Registry,
 ) ([]openai.ChatCompletionMessageParamUnion, error) {
+	ctx, caps := withCapabilities(trace.EnsureRun(ctx), model)
 	rec := devtools.RecorderFrom(ctx)
+	tracker := budget.TrackerFrom(ctx)
 	provider := inferProviderFromEnv()
-	useStream := isStreamingEnabled()
+	useStream := shouldStream(ctx)
 
-	for {
+	for turn := 0; ; turn++ {
+		if err := checkTurnLimit(ctx, turn); err != nil {
+			return messages, err
+		}
+		// 一轮（模型调用及其工具调用）共用一个 span
+		ctx := trace.WithSpan(ctx)
+		messages = append(messages, drainFollowUps(ctx)...)
+		requestMessages := adaptMessages(caps, pruneForRequest(ctx, messages))
 		params := openai.ChatCompletionNewParams{
 			Model:    shared.ChatModel(model),
-			Messages: messages,
+			Messages: requestMessages,
 			Tools:    registry.Definitions(),
 		}
+		if caps.Tools && !caps.ParallelToolCalls && len(params.Tools) > 0 {
+			params.ParallelToolCalls = openai.Bool(false)
+		}
 
 		providerOpts := map[string]any{"baseURL": os.Getenv("DASHSCOPE_BASE_URL")}
 
@@ -55,22 +96,18 @@ func Run(
 			stepType = "stream"
 		}
 
-		stepID, start := rec.StartStep(ctx, stepType, model, provider, messages, registry.Definitions(), providerOpts, params)
+		stepID, start := rec.StartStep(ctx, stepType, model, provider, requestMessages, registry.Definitions(), providerOpts, params)
 
-		var (
-			choice    openai.ChatCompletionChoice
-			resp      *openai.ChatCompletion
-			rawChunks any
-			callErr   error
-		)
-
-		if useStream {
-			choice, resp, rawChunks, callErr = runStreaming(ctx, client, params)
-		} else {
-			resp, callErr = client.Chat.Completions.New(ctx, params)
-			if callErr == nil {
-				choice = resp.Choices[0]
+		choice, resp, rawChunks, callErr := callModel(ctx, client, params, useStream)
+		for retry := 0; retry < reactParseRetries; retry++ {
+			var parseErr *llm.ReActParseError
+			if !errors.As(callErr, &parseErr) {
+				break
 			}
+			// Only the request sees the broken reply and the correction.
+			params.Messages = append(slices.Clone(params.Messages),
+				openai.AssistantMessage(parseErr.Reply), openai.UserMessage(parseErr.Correction()))
+			choice, resp, rawChunks, callErr = callModel(ctx, client, params, useStream)
 		}
 
 		if callErr != nil {
@@ -83,18 +120,27 @@ func Run(
 		output := buildViewerOutput(choice.FinishReason, choice.Message)
 		usage := buildViewerUsage(resp)
 		rec.FinishStep(ctx, stepID, start, output, usage, nil, params, resp, rawChunks)
+		if resp != nil {
+			recordUsage(tracker, resp, model)
+		}
 
 		// 没有工具调用时，模型返回最终文本，循环结束
 		if choice.FinishReason != "tool_calls" {
 			return messages, nil
 		}
 
+		// 超出预算：不再执行工具，让模型收尾
+		if overage := tracker.Check(); overage != nil {
+			return wrapUpOverBudget(ctx, client, model, messages, choice.Message.ToolCalls, overage)
+		}
+
 		// 执行所有工具调用，收集结果
 		for _, tc := range choice.Message.ToolCalls {
 			rec.RegisterToolCall(tc.ID, tc.Function.Name)
-			var args map[string]any
-			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-				return messages, fmt.Errorf("failed to parse tool args for %s: %w", tc.Function.Name, err)
+			args, err := parseToolArgs(tc)
+			if err != nil {
+				messages = append(messages, openai.ToolMessage("error: "+err.Error(), tc.ID))
+				continue
 			}
 
 			toolCtx := devtools.WithParentStep(ctx, stepID)
@@ -108,6 +154,37 @@ func Run(
 	}
 }
 
+// callModel makes one model call, streamed or not, and returns the choice
+// the loop continues with.
+func callModel(
+	ctx context.Context,
+	client *openai.Client,
+	params openai.ChatCompletionNewParams,
+	useStream bool,
+) (openai.ChatCompletionChoice, *openai.ChatCompletion, any, error) {
+	if useStream {
+		return runStreaming(ctx, client, params)
+	}
+	resp, err := llm.Complete(ctx, client, params)
+	if err != nil {
+		return openai.ChatCompletionChoice{}, nil, nil, err
+	}
+	choice, err := firstChoice(resp)
+	return choice, resp, nil, err
+}
+
+// ErrNoChoices is returned when a completion comes back without choices,
+// which some providers do under load.
+var ErrNoChoices = errors.New("completion has no choices")
+
+// firstChoice returns the choice the loop continues with, or ErrNoChoices.
+func firstChoice(resp *openai.ChatCompletion) (openai.ChatCompletionChoice, error) {
+	if resp == nil || len(resp.Choices) == 0 {
+		return openai.ChatCompletionChoice{}, ErrNoChoices
+	}
+	return resp.Choices[0], nil
+}
+
 // isStreamingEnabled returns true when AI_SDK_DEVTOOLS_STREAM env var is truthy.
 func isStreamingEnabled() bool {
 	v := strings.TrimSpace(strings.ToLower(os.Getenv("AI_SDK_DEVTOOLS_STREAM")))
@@ -127,14 +204,16 @@ func runStreaming(
 	client *openai.Client,
 	params openai.ChatCompletionNewParams,
 ) (choice openai.ChatCompletionChoice, resp *openai.ChatCompletion, rawChunks any, err error) {
-	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	stream := llm.Stream(ctx, client, params)
 	defer stream.Close()
+	onToken := TokenHandlerFrom(ctx)
+	onToolCall := ToolCallHandlerFrom(ctx)
 
 	var (
 		chunks       []openai.ChatCompletionChunk
 		textBuf      strings.Builder
 		finishReason string
-		toolCallMap  = map[int]*openai.ChatCompletionMessageToolCall{} // index → accumulated tool call
+		toolCalls    toolCallAssembler
 		modelID      string
 		id           string
 	)
@@ -155,22 +234,16 @@ func runStreaming(
 				finishReason = string(c.FinishReason)
 			}
 			textBuf.WriteString(c.Delta.Content)
+			if onToken != nil && c.Delta.Content != "" {
+				onToken(c.Delta.Content)
+			}
 
-			// Accumulate tool call deltas.
+			// Reassemble the tool calls from their fragments.
 			for _, tcDelta := range c.Delta.ToolCalls {
-				idx := int(tcDelta.Index)
-				if _, exists := toolCallMap[idx]; !exists {
-					toolCallMap[idx] = &openai.ChatCompletionMessageToolCall{
-						ID:   tcDelta.ID,
-						Type: "function",
-					}
-				}
-				tc := toolCallMap[idx]
-				if tcDelta.ID != "" {
-					tc.ID = tcDelta.ID
+				delta := toolCalls.add(tcDelta)
+				if onToolCall != nil {
+					onToolCall(delta)
 				}
-				tc.Function.Name += tcDelta.Function.Name
-				tc.Function.Arguments += tcDelta.Function.Arguments
 			}
 		}
 	}
@@ -179,18 +252,10 @@ func runStreaming(
 		return
 	}
 
-	// Build tool calls slice in order.
-	toolCalls := make([]openai.ChatCompletionMessageToolCall, len(toolCallMap))
-	for i, tc := range toolCallMap {
-		if i < len(toolCalls) {
-			toolCalls[i] = *tc
-		}
-	}
-
 	msg := openai.ChatCompletionMessage{
 		Role:      "assistant",
 		Content:   textBuf.String(),
-		ToolCalls: toolCalls,
+		ToolCalls: toolCalls.toolCalls(),
 	}
 	choice = openai.ChatCompletionChoice{
 		Message:      msg,
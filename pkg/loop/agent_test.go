@@ -3,6 +3,7 @@ package loop
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 
 	"context"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -477,3 +479,60 @@ func TestLoop_TodoNagReminderInjected(t *testing.T) {
 		t.Fatalf("expected nag reminder in 4th request messages, got: %s", string(mock.requestBodies[3]))
 	}
 }
+
+// 超出预算后跳过待执行的工具调用，让模型收尾并返回超支错误。
+func TestRun_StopsToolExecutionWhenBudgetExceeded(t *testing.T) {
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{
+			makeHTTPToolCallResponse("call-1", "bash", `{"command":"echo hi"}`),
+			makeHTTPStopResponse("partial work summary"),
+		},
+	}
+	executed := false
+	registry := tools.New()
+	registry.Register(tools.BashToolDef(), func(context.Context, map[string]any) (string, error) {
+		executed = true
+		return "hi", nil
+	})
+	ctx := budget.WithTracker(context.Background(), budget.New(budget.Limits{MaxDuration: time.Nanosecond}, budget.Pricing{}))
+
+	history, err := Run(ctx, newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("long task")}, registry)
+	if !errors.Is(err, budget.ErrExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if executed {
+		t.Fatal("tool should not run after the budget is exceeded")
+	}
+	wrapUp := string(mock.requestBodies[1])
+	if !strings.Contains(wrapUp, "budget exceeded: time") || strings.Contains(wrapUp, `"tools"`) {
+		t.Fatalf("wrap-up request should explain the overage without tools, got %s", wrapUp)
+	}
+	if got := lastAssistantText(history); got != "partial work summary" {
+		t.Fatalf("final message = %q", got)
+	}
+}
+
+func TestRun_StopsAtMaxTurns(t *testing.T) {
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{
+			makeHTTPToolCallResponse("call-1", "bash", `{"command":"echo 1"}`),
+			makeHTTPToolCallResponse("call-2", "bash", `{"command":"echo 2"}`),
+		},
+	}
+	registry := tools.New()
+	registry.Register(tools.BashToolDef(), func(context.Context, map[string]any) (string, error) { return "ok", nil })
+
+	history, err := Run(WithMaxTurns(context.Background(), 1), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("loop forever")}, registry)
+	if !errors.Is(err, ErrMaxTurns) {
+		t.Fatalf("expected ErrMaxTurns, got %v", err)
+	}
+	if len(mock.requestBodies) != 1 {
+		t.Fatalf("model calls = %d, want 1", len(mock.requestBodies))
+	}
+	// user, assistant tool call, tool result
+	if len(history) != 3 {
+		t.Fatalf("history = %d messages, want 3", len(history))
+	}
+}
@@ -2,13 +2,13 @@ package loop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/nickdu2009/learn-claude-code/pkg/background"
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
@@ -28,7 +28,7 @@ func RunWithBackgroundNotifications(source BackgroundNotificationSource) AgentRu
 	) ([]openai.ChatCompletionMessageParamUnion, error) {
 		rec := devtools.RecorderFrom(ctx)
 		provider := inferProviderFromEnv()
-		useStream := isStreamingEnabled()
+		useStream := shouldStream(ctx)
 
 		for {
 			if source != nil {
@@ -63,9 +63,9 @@ func RunWithBackgroundNotifications(source BackgroundNotificationSource) AgentRu
 			if useStream {
 				choice, resp, rawChunks, callErr = runStreaming(ctx, client, params)
 			} else {
-				resp, callErr = client.Chat.Completions.New(ctx, params)
+				resp, callErr = llm.Complete(ctx, client, params)
 				if callErr == nil {
-					choice = resp.Choices[0]
+					choice, callErr = firstChoice(resp)
 				}
 			}
 
@@ -86,9 +86,10 @@ func RunWithBackgroundNotifications(source BackgroundNotificationSource) AgentRu
 
 			for _, tc := range choice.Message.ToolCalls {
 				rec.RegisterToolCall(tc.ID, tc.Function.Name)
-				var args map[string]any
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-					return messages, fmt.Errorf("failed to parse tool args for %s: %w", tc.Function.Name, err)
+				args, err := parseToolArgs(tc)
+				if err != nil {
+					messages = append(messages, openai.ToolMessage("error: "+err.Error(), tc.ID))
+					continue
 				}
 
 				toolCtx := devtools.WithParentStep(ctx, stepID)
@@ -0,0 +1,75 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// wrapUpOverBudget answers the pending tool calls with a skip notice, asks the
+// model for a final summary without tools, and returns the overage error.
+func wrapUpOverBudget(
+	ctx context.Context,
+	client *openai.Client,
+	model string,
+	messages []openai.ChatCompletionMessageParamUnion,
+	pending []openai.ChatCompletionMessageToolCall,
+	overage error,
+) ([]openai.ChatCompletionMessageParamUnion, error) {
+	for _, tc := range pending {
+		messages = append(messages, openai.ToolMessage(fmt.Sprintf("Skipped: %s.", overage), tc.ID))
+	}
+	messages = append(messages, openai.UserMessage(fmt.Sprintf(
+		"<budget>%s</budget>\n"+
+			"The task budget is exhausted and no more tools can run. "+
+			"Wrap up now: summarize what was done, what remains, and any state the user must know about.",
+		overage,
+	)))
+
+	rec := devtools.RecorderFrom(ctx)
+	params := openai.ChatCompletionNewParams{
+		Model:    shared.ChatModel(model),
+		Messages: messages,
+	}
+	providerOpts := map[string]any{"baseURL": os.Getenv("DASHSCOPE_BASE_URL")}
+	stepID, start := rec.StartStep(ctx, "generate", model, inferProviderFromEnv(), messages, nil, providerOpts, params)
+
+	resp, err := llm.Complete(ctx, client, params)
+	if err != nil {
+		rec.FinishStep(ctx, stepID, start, nil, nil, fmt.Errorf("API call failed: %w", err), params, nil, nil)
+		return messages, errors.Join(overage, fmt.Errorf("wrap-up call failed: %w", err))
+	}
+	recordUsage(budget.TrackerFrom(ctx), resp, model)
+	choice, err := firstChoice(resp)
+	if err != nil {
+		rec.FinishStep(ctx, stepID, start, nil, buildViewerUsage(resp), err, params, resp, nil)
+		return messages, errors.Join(overage, fmt.Errorf("wrap-up call failed: %w", err))
+	}
+	rec.FinishStep(ctx, stepID, start, buildViewerOutput(choice.FinishReason, choice.Message), buildViewerUsage(resp), nil, params, resp, nil)
+
+	return append(messages, choice.Message.ToParam()), overage
+}
+
+// recordUsage adds the tokens of resp, prompt cache hits included, to
+// tracker.
+func recordUsage(tracker *budget.Tracker, resp *openai.ChatCompletion, model string) {
+	name := respModel(resp, model)
+	tracker.RecordModel(name, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	tracker.RecordCached(name, resp.Usage.PromptTokensDetails.CachedTokens)
+}
+
+// respModel returns the model that answered: the response names it, which
+// after a fallback (see llm.Fallback) is not the one requested.
+func respModel(resp *openai.ChatCompletion, requested string) string {
+	if resp.Model != "" {
+		return resp.Model
+	}
+	return requested
+}
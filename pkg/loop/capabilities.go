@@ -0,0 +1,136 @@
+package loop
+
+import (
+	"context"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/openai/openai-go"
+)
+
+// contextBudgetShare is the part of a model's context window the request
+// history may fill when no pruning is configured; the rest is left for the
+// tool definitions and the reply.
+const contextBudgetShare = 0.75
+
+// reactParseRetries bounds how often a reply breaking the ReAct grammar is
+// sent back to the model for correction.
+const reactParseRetries = 2
+
+// imagePlaceholder replaces the images sent to a model without vision.
+const imagePlaceholder = "[image omitted: the model cannot read images]"
+
+// withCapabilities adapts ctx to what model supports (see
+// llm.CapabilitiesFor): without native tool calling, model calls go through
+// llm.ReAct; with a known context window and no WithPruning, requests are
+// pruned to fit it.
+func withCapabilities(ctx context.Context, model string) (context.Context, llm.Capabilities) {
+	caps := llm.CapabilitiesFor(ctx, model)
+	if !caps.Tools {
+		ctx = llm.WithReAct(ctx)
+	}
+	if _, ok := ctx.Value(pruneKey{}).(PruneOptions); !ok && caps.MaxContextTokens > 0 {
+		ctx = WithPruning(ctx, PruneOptions{BudgetTokens: int(float64(caps.MaxContextTokens) * contextBudgetShare)})
+	}
+	return ctx, caps
+}
+
+// adaptMessages returns the request copy of messages for a model with caps:
+// images are replaced by a note without vision, and tool calls are replayed
+// one per assistant turn without parallel tool calls.
+func adaptMessages(caps llm.Capabilities, messages []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	if !caps.Vision {
+		messages = withoutImages(messages)
+	}
+	if !caps.ParallelToolCalls {
+		messages = serializeToolCalls(messages)
+	}
+	return messages
+}
+
+func withoutImages(messages []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	var out []openai.ChatCompletionMessageParamUnion
+	for i, msg := range messages {
+		user := msg.OfUser
+		if user == nil || !hasImage(user.Content.OfArrayOfContentParts) {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)), messages[:i]...)
+		}
+		parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(user.Content.OfArrayOfContentParts))
+		for _, part := range user.Content.OfArrayOfContentParts {
+			if part.OfImageURL != nil {
+				part = openai.TextContentPart(imagePlaceholder)
+			}
+			parts = append(parts, part)
+		}
+		copied := *user
+		copied.Content.OfArrayOfContentParts = parts
+		out = append(out, openai.ChatCompletionMessageParamUnion{OfUser: &copied})
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+func hasImage(parts []openai.ChatCompletionContentPartUnionParam) bool {
+	for _, part := range parts {
+		if part.OfImageURL != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// serializeToolCalls splits each assistant message with several tool calls,
+// and the results that follow it, into one call and its result per turn.
+// The message's text stays with the first call.
+func serializeToolCalls(messages []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	var out []openai.ChatCompletionMessageParamUnion
+	for i := 0; i < len(messages); i++ {
+		assistant := messages[i].OfAssistant
+		if assistant == nil || len(assistant.ToolCalls) < 2 {
+			if out != nil {
+				out = append(out, messages[i])
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)), messages[:i]...)
+		}
+
+		results := map[string]openai.ChatCompletionMessageParamUnion{}
+		var unmatched []openai.ChatCompletionMessageParamUnion
+		for i+1 < len(messages) && messages[i+1].OfTool != nil {
+			i++
+			results[messages[i].OfTool.ToolCallID] = messages[i]
+			unmatched = append(unmatched, messages[i])
+		}
+		for n, call := range assistant.ToolCalls {
+			turn := *assistant
+			turn.ToolCalls = []openai.ChatCompletionMessageToolCallParam{call}
+			if n > 0 {
+				turn.Content = openai.ChatCompletionAssistantMessageParamContentUnion{}
+			}
+			out = append(out, openai.ChatCompletionMessageParamUnion{OfAssistant: &turn})
+			if result, ok := results[call.ID]; ok {
+				out = append(out, result)
+				delete(results, call.ID)
+			}
+		}
+		// Results for calls the message does not have are kept at the end.
+		for _, msg := range unmatched {
+			if _, ok := results[msg.OfTool.ToolCallID]; ok {
+				out = append(out, msg)
+			}
+		}
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func echoRegistry() *tools.Registry {
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Function: openai.FunctionDefinitionParam{
+		Name:       "echo",
+		Parameters: openai.FunctionParameters{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}},
+	}}, func(_ context.Context, args map[string]any) (string, error) {
+		return "echo: " + args["text"].(string), nil
+	})
+	return registry
+}
+
+// UT-CAP-01: 模型不支持原生工具调用时，工具通过 ReAct 提示词描述，Action 照常执行；格式错误的回复带着问题重试。
+func TestRun_PromptsToolsForModelsWithoutFunctionCalling(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStopResponse("I will echo."),
+		makeHTTPStopResponse("Thought: echo it\nAction: echo\nAction Input: {\"text\": \"hi\"}"),
+		makeHTTPStopResponse("Final Answer: Said hi."),
+	}}
+	ctx := llm.WithCapabilities(context.Background(), func(string) llm.Capabilities { return llm.Capabilities{} })
+
+	history, err := Run(ctx, newCapturingMockClient(mock), "mock-model", []openai.ChatCompletionMessageParamUnion{openai.UserMessage("say hi")}, echoRegistry())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(history) != 4 || history[2].OfTool == nil || history[2].OfTool.Content.OfString.Value != "echo: hi" || history[3].OfAssistant.Content.OfString.Value != "Said hi." {
+		t.Fatalf("history = %+v", history)
+	}
+	if calls := history[1].OfAssistant.ToolCalls; len(calls) != 1 || calls[0].Function.Name != "echo" {
+		t.Fatalf("parsed tool calls = %+v", calls)
+	}
+
+	for i, body := range mock.requestBodies {
+		if strings.Contains(string(body), `"tools"`) {
+			t.Fatalf("request %d sent native tools: %s", i, body)
+		}
+	}
+	var second struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	// The broken first reply goes back with the problem, to the retry only.
+	_ = json.Unmarshal(mock.requestBodies[1], &second)
+	if n := len(second.Messages); n != 4 || second.Messages[2].Content != "I will echo." || !strings.Contains(second.Messages[3].Content, "neither") {
+		t.Fatalf("retry request: %s", mock.requestBodies[1])
+	}
+	_ = json.Unmarshal(mock.requestBodies[2], &second)
+	if n := len(second.Messages); n != 4 || second.Messages[3].Role != "user" || second.Messages[3].Content != "Observation: echo: hi" {
+		t.Fatalf("tool result not replayed as an observation: %s", mock.requestBodies[2])
+	}
+}
+
+// UT-CAP-02: 模型不支持并行工具调用时，一条消息里的多个调用按“调用、结果”逐轮重放。
+func TestAdaptMessages_SerializesParallelToolCalls(t *testing.T) {
+	call := func(id string) openai.ChatCompletionMessageToolCallParam {
+		return openai.ChatCompletionMessageToolCallParam{ID: id, Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "echo", Arguments: "{}"}}
+	}
+	assistant := openai.ChatCompletionAssistantMessageParam{ToolCalls: []openai.ChatCompletionMessageToolCallParam{call("a"), call("b")}}
+	assistant.Content.OfString = openai.String("Checking both.")
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("go"),
+		{OfAssistant: &assistant},
+		openai.ToolMessage("result a", "a"),
+		openai.ToolMessage("result b", "b"),
+		openai.UserMessage("next"),
+	}
+
+	got := adaptMessages(llm.Capabilities{Tools: true}, messages)
+	var shape []string
+	for _, msg := range got {
+		switch {
+		case msg.OfAssistant != nil:
+			shape = append(shape, "assistant:"+msg.OfAssistant.ToolCalls[0].ID+":"+msg.OfAssistant.Content.OfString.Value)
+		case msg.OfTool != nil:
+			shape = append(shape, "tool:"+msg.OfTool.ToolCallID)
+		default:
+			shape = append(shape, "user")
+		}
+	}
+	want := "user,assistant:a:Checking both.,tool:a,assistant:b:,tool:b,user"
+	if strings.Join(shape, ",") != want {
+		t.Fatalf("messages = %s, want %s", strings.Join(shape, ","), want)
+	}
+	if len(messages) != 5 || len(messages[1].OfAssistant.ToolCalls) != 2 {
+		t.Fatal("the history itself was changed")
+	}
+
+	// Images are replaced for models without vision.
+	withImage := []openai.ChatCompletionMessageParamUnion{openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+		openai.TextContentPart("what is this?"),
+		openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "data:image/png;base64,AAAA"}),
+	})}
+	data, _ := json.Marshal(adaptMessages(llm.Capabilities{ParallelToolCalls: true}, withImage))
+	if strings.Contains(string(data), "image_url") || !strings.Contains(string(data), imagePlaceholder) {
+		t.Fatalf("image not replaced: %s", data)
+	}
+}
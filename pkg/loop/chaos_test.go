@@ -0,0 +1,238 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Chaos client：按计划注入故障，验证 loop.Run 的降级行为
+// ─────────────────────────────────────────────────────────────────────────────
+
+type fault int
+
+const (
+	// faultStop answers normally with a final text reply.
+	faultStop fault = iota
+	// faultToolCall answers with a valid call of the lookup tool.
+	faultToolCall
+	// faultMalformedToolCall calls a tool that does not exist.
+	faultMalformedToolCall
+	// faultTruncatedJSON calls lookup with arguments cut off mid-string.
+	faultTruncatedJSON
+	faultEmptyChoices
+	// faultRateLimit answers 429 Too Many Requests.
+	faultRateLimit
+	// faultTimeout never answers; the request ends with its context.
+	faultTimeout
+)
+
+// chaosHTTPClient answers the i-th request according to schedule[i]; calls
+// past the end of the schedule get faultStop.
+type chaosHTTPClient struct {
+	schedule []fault
+	calls    int
+}
+
+func (c *chaosHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	f := faultStop
+	if c.calls < len(c.schedule) {
+		f = c.schedule[c.calls]
+	}
+	c.calls++
+
+	switch f {
+	case faultToolCall:
+		return makeHTTPToolCallResponse("call-ok", "lookup", `{"key": "a"}`), nil
+	case faultMalformedToolCall:
+		return makeHTTPToolCallResponse("call-bad", "no_such_tool", `{}`), nil
+	case faultTruncatedJSON:
+		return makeHTTPToolCallResponse("call-cut", "lookup", `{"key": "ab`), nil
+	case faultEmptyChoices:
+		return marshalToHTTPResponse(map[string]any{
+			"id": "mock-id", "object": "chat.completion", "created": 0, "model": "mock-model",
+			"choices": []map[string]any{},
+		}), nil
+	case faultRateLimit:
+		resp := marshalToHTTPResponse(map[string]any{
+			"error": map[string]any{"message": "Rate limit exceeded", "type": "rate_limit_error", "code": "rate_limit"},
+		})
+		resp.StatusCode = http.StatusTooManyRequests
+		resp.Header.Set("Retry-After", "0")
+		return resp, nil
+	case faultTimeout:
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return makeHTTPStopResponse("done"), nil
+}
+
+func newChaosClient(chaos *chaosHTTPClient) *openai.Client {
+	c := openai.NewClient(
+		option.WithAPIKey("mock-key"),
+		option.WithBaseURL("https://mock.example.com/v1/"),
+		option.WithHTTPClient(chaos),
+		option.WithMaxRetries(0),
+	)
+	return &c
+}
+
+// IT-LOOP-CHAOS: 各类故障下 Run 不 panic；可恢复的故障（坏工具调用）作为工具错误交给模型继续，
+// 不可恢复的故障（空 choices、限流、超时）返回错误且保留已有历史。
+func TestRun_DegradesGracefullyUnderFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule []fault
+		timeout  time.Duration
+		// wantErr checks the error; nil means Run must succeed.
+		wantErr func(error) bool
+		// wantMessages is the history length Run returns, the user message included.
+		wantMessages int
+		// wantToolResults are substrings of the tool results, in order.
+		wantToolResults []string
+		wantLookups     int
+	}{
+		{
+			name:            "malformed tool call",
+			schedule:        []fault{faultMalformedToolCall},
+			wantMessages:    4,
+			wantToolResults: []string{"unknown tool: no_such_tool"},
+		},
+		{
+			name:            "truncated JSON arguments",
+			schedule:        []fault{faultTruncatedJSON},
+			wantMessages:    4,
+			wantToolResults: []string{"invalid JSON arguments for lookup"},
+		},
+		{
+			name:            "recovers after bad calls",
+			schedule:        []fault{faultTruncatedJSON, faultMalformedToolCall, faultToolCall},
+			wantMessages:    8,
+			wantToolResults: []string{"invalid JSON", "unknown tool", "value of a"},
+			wantLookups:     1,
+		},
+		{
+			name:         "empty choices",
+			schedule:     []fault{faultEmptyChoices},
+			wantErr:      func(err error) bool { return errors.Is(err, ErrNoChoices) },
+			wantMessages: 1,
+		},
+		{
+			name:     "rate limited",
+			schedule: []fault{faultRateLimit},
+			wantErr: func(err error) bool {
+				var apiErr *openai.Error
+				return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+			},
+			wantMessages: 1,
+		},
+		{
+			name:         "timeout",
+			schedule:     []fault{faultTimeout},
+			timeout:      50 * time.Millisecond,
+			wantErr:      func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+			wantMessages: 1,
+		},
+		{
+			name:     "rate limited mid-run keeps finished tool calls",
+			schedule: []fault{faultToolCall, faultRateLimit},
+			wantErr: func(err error) bool {
+				var apiErr *openai.Error
+				return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+			},
+			wantMessages:    3,
+			wantToolResults: []string{"value of a"},
+			wantLookups:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			registry := tools.New()
+			registry.Register(openai.ChatCompletionToolParam{
+				Type:     "function",
+				Function: shared.FunctionDefinitionParam{Name: "lookup"},
+			}, func(_ context.Context, args map[string]any) (string, error) {
+				lookups++
+				key, _ := args["key"].(string)
+				return "value of " + key, nil
+			})
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			chaos := &chaosHTTPClient{schedule: tt.schedule}
+			history, err := Run(ctx, newChaosClient(chaos), "mock-model",
+				[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("go")}, registry)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != nil && (err == nil || !tt.wantErr(err)):
+				t.Fatalf("error = %v, not the expected failure", err)
+			}
+			if len(history) != tt.wantMessages {
+				t.Fatalf("history has %d messages, want %d", len(history), tt.wantMessages)
+			}
+
+			var results []string
+			for _, msg := range history {
+				if msg.OfTool != nil {
+					results = append(results, msg.OfTool.Content.OfString.Value)
+				}
+			}
+			if len(results) != len(tt.wantToolResults) {
+				t.Fatalf("tool results = %q, want %d", results, len(tt.wantToolResults))
+			}
+			for i, want := range tt.wantToolResults {
+				if !strings.Contains(results[i], want) {
+					t.Errorf("tool result %d = %q, want it to contain %q", i, results[i], want)
+				}
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("lookup ran %d times, want %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+// IT-LOOP-CHAOS: 超出预算后的收尾调用返回空 choices 时不 panic，同时报告超预算与 ErrNoChoices。
+func TestRun_BudgetWrapUpSurvivesEmptyChoices(t *testing.T) {
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{
+		Type:     "function",
+		Function: shared.FunctionDefinitionParam{Name: "lookup"},
+	}, func(context.Context, map[string]any) (string, error) {
+		t.Error("lookup should not run after the budget is exceeded")
+		return "", nil
+	})
+	ctx := budget.WithTracker(context.Background(), budget.New(budget.Limits{MaxDuration: time.Nanosecond}, budget.Pricing{}))
+
+	chaos := &chaosHTTPClient{schedule: []fault{faultToolCall, faultEmptyChoices}}
+	history, err := Run(ctx, newChaosClient(chaos), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("go")}, registry)
+	if !errors.Is(err, budget.ErrExceeded) || !errors.Is(err, ErrNoChoices) {
+		t.Fatalf("error = %v, want the overage and ErrNoChoices", err)
+	}
+	if chaos.calls != 2 {
+		t.Fatalf("model calls = %d, want 2", chaos.calls)
+	}
+	// 用户消息、工具调用、跳过说明与收尾请求都保留
+	if len(history) != 4 {
+		t.Fatalf("history has %d messages, want 4", len(history))
+	}
+}
@@ -0,0 +1,110 @@
+package loop
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/openai/openai-go"
+)
+
+// CompactStrategy tunes an explicit /compact.
+type CompactStrategy struct {
+	Name string
+	// KeepRecentMessages overrides CompactOptions.KeepRecentMessages when
+	// non-zero; -1 keeps no recent messages besides the summary.
+	KeepRecentMessages int
+	// Instructions are appended to the summary prompt.
+	Instructions string
+}
+
+const keepLastStrategyPrefix = "keep-last-"
+
+// ParseCompactStrategy resolves a /compact strategy argument: "" (default),
+// "aggressive", "keep-code" or "keep-last-N".
+func ParseCompactStrategy(name string) (CompactStrategy, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); {
+	case name == "" || name == "default":
+		return CompactStrategy{Name: "default"}, nil
+	case name == "aggressive":
+		return CompactStrategy{
+			Name:               name,
+			KeepRecentMessages: -1,
+			Instructions:       "Be terse: at most a few bullet points per heading. Drop exploration that led nowhere.",
+		}, nil
+	case name == "keep-code":
+		return CompactStrategy{
+			Name: name,
+			Instructions: "Preserve verbatim every code snippet, file path, identifier, command and error message " +
+				"that is still relevant; summarize only the prose.",
+		}, nil
+	case strings.HasPrefix(name, keepLastStrategyPrefix):
+		n, err := strconv.Atoi(strings.TrimPrefix(name, keepLastStrategyPrefix))
+		if err != nil || n <= 0 {
+			return CompactStrategy{}, fmt.Errorf("invalid strategy %q: want keep-last-N with N > 0", name)
+		}
+		return CompactStrategy{Name: name, KeepRecentMessages: n}, nil
+	default:
+		return CompactStrategy{}, fmt.Errorf("unknown strategy %q (aggressive, keep-code, keep-last-N)", name)
+	}
+}
+
+// ManualCompact summarizes messages with the given strategy, saving the full
+// transcript first like AutoCompact.
+func ManualCompact(
+	ctx context.Context,
+	client *openai.Client,
+	model string,
+	messages []openai.ChatCompletionMessageParamUnion,
+	opts CompactOptions,
+	strategy CompactStrategy,
+	focus string,
+) (CompactResult, error) {
+	return compactWithStrategy(ctx, client, model, messages, opts, "manual", focus, strategy)
+}
+
+// CompactCommand returns the /compact slash command. history returns the
+// current conversation and replace swaps in the compacted one. Words after
+// the strategy are passed to the summarizer as focus.
+func CompactCommand(
+	client *openai.Client,
+	model string,
+	opts CompactOptions,
+	history func() []openai.ChatCompletionMessageParamUnion,
+	replace func([]openai.ChatCompletionMessageParamUnion),
+) command.Command {
+	return command.Command{
+		Name:        "compact",
+		Usage:       "[aggressive|keep-code|keep-last-N] [focus...]",
+		Description: "summarize the conversation and replace history with the summary plus recent turns",
+		Run: func(ctx context.Context, args []string) (string, error) {
+			var strategyArg string
+			if len(args) > 0 {
+				_, err := ParseCompactStrategy(args[0])
+				switch {
+				case err == nil:
+					strategyArg, args = args[0], args[1:]
+				case strings.HasPrefix(strings.ToLower(args[0]), keepLastStrategyPrefix):
+					return "", err
+				}
+			}
+			strategy, err := ParseCompactStrategy(strategyArg)
+			if err != nil {
+				return "", err
+			}
+
+			messages := history()
+			result, err := ManualCompact(ctx, client, model, messages, opts, strategy, strings.Join(args, " "))
+			if err != nil {
+				return "", err
+			}
+			replace(result.Messages)
+			return fmt.Sprintf("Compacted %d messages (~%d tokens) into %d (~%d tokens) using the %s strategy.\nTranscript: %s",
+				len(messages), EstimateMessagesTokens(messages),
+				len(result.Messages), EstimateMessagesTokens(result.Messages),
+				strategy.Name, result.TranscriptPath), nil
+		},
+	}
+}
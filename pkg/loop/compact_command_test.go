@@ -0,0 +1,89 @@
+package loop
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestParseCompactStrategy(t *testing.T) {
+	cases := map[string]CompactStrategy{
+		"":            {Name: "default"},
+		"keep-last-4": {Name: "keep-last-4", KeepRecentMessages: 4},
+	}
+	for arg, want := range cases {
+		got, err := ParseCompactStrategy(arg)
+		if err != nil || got != want {
+			t.Fatalf("ParseCompactStrategy(%q) = %+v, %v", arg, got, err)
+		}
+	}
+	if got, _ := ParseCompactStrategy("aggressive"); got.KeepRecentMessages != -1 {
+		t.Fatalf("aggressive should keep no recent messages, got %+v", got)
+	}
+	for _, bad := range []string{"keep-last-0", "keep-last-x", "gentle"} {
+		if _, err := ParseCompactStrategy(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCompactCommand_ReplacesHistoryWithStrategy(t *testing.T) {
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{makeHTTPStopResponse("Goal\nCompleted\nCurrentState\nDecisions\nConstraints\nNextSteps")},
+	}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("first task"),
+		openai.AssistantMessage("first answer"),
+		openai.UserMessage("second task"),
+		openai.AssistantMessage("second answer"),
+	}
+	cmd := CompactCommand(newCapturingMockClient(mock), "mock-model",
+		CompactOptions{TranscriptDir: sandboxContextCompactDir(t)},
+		func() []openai.ChatCompletionMessageParamUnion { return history },
+		func(messages []openai.ChatCompletionMessageParamUnion) { history = messages },
+	)
+
+	output, err := cmd.Run(context.Background(), []string{"keep-code", "the", "parser"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(output, "keep-code strategy") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+	request := string(mock.requestBodies[0])
+	if !strings.Contains(request, "Focus: the parser") || !strings.Contains(request, "Preserve verbatim") {
+		t.Fatalf("summary prompt missing focus or strategy instructions: %s", request)
+	}
+	if history[1].OfUser == nil || !strings.Contains(history[1].OfUser.Content.OfString.Value, "Conversation compressed via manual compact") {
+		t.Fatalf("history was not replaced with the summary: %+v", history[1])
+	}
+}
+
+func TestCompactCommand_AggressiveKeepsOnlySummary(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{makeHTTPStopResponse("summary")}}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("task"),
+		openai.AssistantMessage("answer"),
+	}
+	cmd := CompactCommand(newCapturingMockClient(mock), "mock-model",
+		CompactOptions{TranscriptDir: sandboxContextCompactDir(t)},
+		func() []openai.ChatCompletionMessageParamUnion { return history },
+		func(messages []openai.ChatCompletionMessageParamUnion) { history = messages },
+	)
+
+	if _, err := cmd.Run(context.Background(), []string{"aggressive"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// system, summary, acknowledgement
+	if len(history) != 3 {
+		t.Fatalf("history = %d messages, want 3", len(history))
+	}
+	if _, err := cmd.Run(context.Background(), []string{"keep-last-zero"}); err == nil {
+		t.Fatal("expected error for malformed keep-last strategy")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
 )
@@ -22,8 +24,8 @@ const (
 
 // CompactOptions controls the s06 three-layer compaction behavior.
 type CompactOptions struct {
-	// ThresholdTokens is a tutorial-style rough token budget, estimated as
-	// serialized message characters / 4 instead of using a provider tokenizer.
+	// ThresholdTokens is the history size that triggers auto compact, counted
+	// with tokens.Default (see EstimateMessagesTokens).
 	ThresholdTokens       int
 	KeepRecentToolResults int
 	KeepRecentMessages    int
@@ -59,18 +61,11 @@ func withCompactDefaults(opts CompactOptions) CompactOptions {
 	return opts
 }
 
-// EstimateMessagesTokens returns a rough token estimate using the tutorial heuristic:
-// around one token for every four characters in the serialized message history.
+// EstimateMessagesTokens counts the tokens of the message history with
+// tokens.Default: the model's tokenizer when AGENT_TOKENIZER_FILE is set, a
+// script-aware estimate otherwise.
 func EstimateMessagesTokens(messages []openai.ChatCompletionMessageParamUnion) int {
-	data, err := json.Marshal(messages)
-	if err != nil {
-		rendered := fmt.Sprint(messages)
-		if rendered == "" {
-			return 0
-		}
-		return len(rendered) / 4
-	}
-	return len(data) / 4
+	return tokens.CountMessages(tokens.Default(), messages)
 }
 
 // MicroCompact replaces older tool outputs with lightweight placeholders.
@@ -139,8 +134,24 @@ func autoCompactWithTrigger(
 	opts CompactOptions,
 	trigger string,
 	focus string,
+) (CompactResult, error) {
+	return compactWithStrategy(ctx, client, model, messages, opts, trigger, focus, CompactStrategy{})
+}
+
+func compactWithStrategy(
+	ctx context.Context,
+	client *openai.Client,
+	model string,
+	messages []openai.ChatCompletionMessageParamUnion,
+	opts CompactOptions,
+	trigger string,
+	focus string,
+	strategy CompactStrategy,
 ) (CompactResult, error) {
 	opts = withCompactDefaults(opts)
+	if strategy.KeepRecentMessages != 0 {
+		opts.KeepRecentMessages = strategy.KeepRecentMessages
+	}
 
 	store := TranscriptStore{Dir: opts.TranscriptDir}
 	transcriptPath, err := store.Save(messages)
@@ -157,11 +168,11 @@ func autoCompactWithTrigger(
 		conversation = conversation[:opts.SummaryCharLimit]
 	}
 
-	prompt := buildCompactPrompt(conversation, trigger, focus)
+	prompt := buildCompactPrompt(conversation, trigger, focus, strategy.Instructions)
 	summaryCtx, cancel := newSummaryRequestContext(ctx, opts.SummaryTimeout)
 	defer cancel()
 
-	resp, err := client.Chat.Completions.New(summaryCtx, openai.ChatCompletionNewParams{
+	resp, err := llm.Complete(summaryCtx, client, openai.ChatCompletionNewParams{
 		Model: shared.ChatModel(model),
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.UserMessage(prompt),
@@ -195,7 +206,7 @@ func autoCompactWithTrigger(
 	}, nil
 }
 
-func buildCompactPrompt(conversation, trigger, focus string) string {
+func buildCompactPrompt(conversation, trigger, focus, instructions string) string {
 	lines := []string{
 		"Summarize this coding-agent conversation for continuity.",
 		"Use the exact headings below and keep the summary concise but specific:",
@@ -210,6 +221,9 @@ func buildCompactPrompt(conversation, trigger, focus string) string {
 	if focus = strings.TrimSpace(focus); focus != "" {
 		lines = append(lines, fmt.Sprintf("Focus: %s", focus))
 	}
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		lines = append(lines, fmt.Sprintf("Instructions: %s", instructions))
+	}
 	lines = append(lines, "", "Conversation JSON:", conversation)
 	return strings.Join(lines, "\n")
 }
@@ -223,7 +237,10 @@ func buildCompressedMessages(
 	thresholdTokens int,
 ) []openai.ChatCompletionMessageParamUnion {
 	instructions, remainder := splitInstructionMessages(original)
-	tail := keepTailMessages(remainder, keepRecentMessages)
+	var tail []openai.ChatCompletionMessageParamUnion
+	if keepRecentMessages >= 0 {
+		tail = keepTailMessages(remainder, keepRecentMessages)
+	}
 
 	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(instructions)+len(tail)+2)
 	result = append(result, instructions...)
@@ -2,11 +2,12 @@ package loop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
@@ -25,7 +26,7 @@ func RunWithContextCompact(
 ) ([]openai.ChatCompletionMessageParamUnion, error) {
 	rec := devtools.RecorderFrom(ctx)
 	provider := inferProviderFromEnv()
-	useStream := isStreamingEnabled()
+	useStream := shouldStream(ctx)
 	opts = withCompactDefaults(opts)
 
 	for {
@@ -62,9 +63,9 @@ func RunWithContextCompact(
 		if useStream {
 			choice, resp, rawChunks, callErr = runStreaming(ctx, client, params)
 		} else {
-			resp, callErr = client.Chat.Completions.New(ctx, params)
+			resp, callErr = llm.Complete(ctx, client, params)
 			if callErr == nil {
-				choice = resp.Choices[0]
+				choice, callErr = firstChoice(resp)
 			}
 		}
 
@@ -78,6 +79,9 @@ func RunWithContextCompact(
 		output := buildViewerOutput(choice.FinishReason, choice.Message)
 		usage := buildViewerUsage(resp)
 		rec.FinishStep(ctx, stepID, start, output, usage, nil, params, resp, rawChunks)
+		if resp != nil {
+			recordUsage(budget.TrackerFrom(ctx), resp, model)
+		}
 
 		if choice.FinishReason != "tool_calls" {
 			return messages, nil
@@ -89,9 +93,10 @@ func RunWithContextCompact(
 		for _, tc := range choice.Message.ToolCalls {
 			rec.RegisterToolCall(tc.ID, tc.Function.Name)
 
-			var args map[string]any
-			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-				return messages, fmt.Errorf("failed to parse tool args for %s: %w", tc.Function.Name, err)
+			args, err := parseToolArgs(tc)
+			if err != nil {
+				messages = append(messages, openai.ToolMessage("error: "+err.Error(), tc.ID))
+				continue
 			}
 
 			if tc.Function.Name == "compact" {
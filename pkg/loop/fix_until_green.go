@@ -0,0 +1,178 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+const (
+	defaultFixMaxAttempts  = 3
+	defaultFixTestTimeout  = 5 * time.Minute
+	maxFixTestOutputLength = 8000
+)
+
+// ErrTestsStillFailing is returned by RunFixUntilGreen when the attempt
+// budget is exhausted and the test command still fails.
+var ErrTestsStillFailing = errors.New("tests still failing")
+
+// FixUntilGreenOptions configures the "fix until green" mode.
+type FixUntilGreenOptions struct {
+	// TestCommand is run through bash after each edit and whenever the model
+	// stops; exit status 0 means green.
+	TestCommand string
+	// MaxAttempts bounds how many times failures are fed back after the model
+	// declares it is done.
+	MaxAttempts int
+	Workdir     string
+	Timeout     time.Duration
+	// EditTools names the tools whose success triggers a test run.
+	EditTools []string
+	// Runner is the underlying loop; defaults to Run.
+	Runner AgentRunner
+}
+
+// FixUntilGreenOptionsFromEnv reads AGENT_TEST_COMMAND and AGENT_FIX_MAX_ATTEMPTS.
+// ok is false when no test command is configured.
+func FixUntilGreenOptionsFromEnv() (opts FixUntilGreenOptions, ok bool) {
+	opts.TestCommand = strings.TrimSpace(os.Getenv("AGENT_TEST_COMMAND"))
+	if raw := strings.TrimSpace(os.Getenv("AGENT_FIX_MAX_ATTEMPTS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			opts.MaxAttempts = n
+		}
+	}
+	return opts, opts.TestCommand != ""
+}
+
+// WithFixUntilGreenFromEnv wraps runner in RunFixUntilGreen, running the
+// tests in workdir, when AGENT_TEST_COMMAND is set; otherwise it returns
+// runner unchanged.
+func WithFixUntilGreenFromEnv(runner AgentRunner, workdir string) AgentRunner {
+	opts, ok := FixUntilGreenOptionsFromEnv()
+	if !ok {
+		return runner
+	}
+	opts.Workdir, opts.Runner = workdir, runner
+	return RunFixUntilGreen(opts)
+}
+
+func withFixUntilGreenDefaults(opts FixUntilGreenOptions) FixUntilGreenOptions {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultFixMaxAttempts
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultFixTestTimeout
+	}
+	if len(opts.EditTools) == 0 {
+		opts.EditTools = []string{"write_file", "edit_file", "multi_edit"}
+	}
+	if opts.Runner == nil {
+		opts.Runner = Run
+	}
+	return opts
+}
+
+// TestRunResult is the outcome of one TestCommand execution.
+type TestRunResult struct {
+	Passed bool
+	Output string
+}
+
+// RunFixUntilGreen returns a runner that keeps the model iterating until the
+// configured test command passes:
+//   - after every successful edit tool call, the test result is appended to
+//     that tool's output so the model sees regressions immediately
+//   - when the model stops while tests are red, the failures are sent back as
+//     a user message, up to MaxAttempts times
+func RunFixUntilGreen(opts FixUntilGreenOptions) AgentRunner {
+	opts = withFixUntilGreenDefaults(opts)
+
+	return func(
+		ctx context.Context,
+		client *openai.Client,
+		model string,
+		messages []openai.ChatCompletionMessageParamUnion,
+		registry *tools.Registry,
+	) ([]openai.ChatCompletionMessageParamUnion, error) {
+		if strings.TrimSpace(opts.TestCommand) == "" {
+			return messages, fmt.Errorf("fix-until-green: test command is not configured")
+		}
+
+		editAware := registry.WithMiddleware(func(name string, next tools.Handler) tools.Handler {
+			if !slices.Contains(opts.EditTools, name) {
+				return next
+			}
+			return func(ctx context.Context, args map[string]any) (string, error) {
+				output, err := next(ctx, args)
+				if err != nil {
+					return output, err
+				}
+				result := RunTestCommand(ctx, opts)
+				return output + "\n\n" + formatAutoTestResult(opts.TestCommand, result), nil
+			}
+		})
+
+		for attempt := 1; ; attempt++ {
+			history, err := opts.Runner(ctx, client, model, messages, editAware)
+			if err != nil {
+				return history, err
+			}
+
+			result := RunTestCommand(ctx, opts)
+			if result.Passed {
+				return history, nil
+			}
+			if ctx.Err() != nil {
+				return history, ctx.Err()
+			}
+			if attempt >= opts.MaxAttempts {
+				return history, fmt.Errorf("%w after %d attempt(s)", ErrTestsStillFailing, attempt)
+			}
+
+			messages = append(history, openai.UserMessage(fmt.Sprintf(
+				"<test-results attempt=\"%d/%d\">\n$ %s\n%s\n</test-results>\n"+
+					"The tests are still failing. Fix the code so that the command above passes.",
+				attempt, opts.MaxAttempts, opts.TestCommand, result.Output,
+			)))
+		}
+	}
+}
+
+// RunTestCommand executes opts.TestCommand and captures the tail of its output.
+func RunTestCommand(ctx context.Context, opts FixUntilGreenOptions) TestRunResult {
+	opts = withFixUntilGreenDefaults(opts)
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	cmd := sandbox.ShellFromEnv().Command(runCtx, opts.TestCommand)
+	cmd.Dir = opts.Workdir
+	out, err := cmd.CombinedOutput()
+
+	output := strings.TrimSpace(string(out))
+	if runCtx.Err() == context.DeadlineExceeded {
+		output += fmt.Sprintf("\n(test command timed out after %s)", opts.Timeout)
+	} else if err != nil && output == "" {
+		output = fmt.Sprintf("Error: %s", err)
+	}
+	if len(output) > maxFixTestOutputLength {
+		output = "...\n" + output[len(output)-maxFixTestOutputLength:]
+	}
+	return TestRunResult{Passed: err == nil, Output: output}
+}
+
+func formatAutoTestResult(command string, result TestRunResult) string {
+	if result.Passed {
+		return fmt.Sprintf("[auto-test] PASS: %s", command)
+	}
+	return fmt.Sprintf("[auto-test] FAIL: %s\n%s", command, result.Output)
+}
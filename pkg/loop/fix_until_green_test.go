@@ -0,0 +1,126 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// 模型先声称完成 → 测试失败被回灌 → 模型调用 edit_file 修复 → 测试通过后结束。
+func TestRunFixUntilGreen_FeedsFailuresBackUntilPass(t *testing.T) {
+	dir := sandboxLoopDir(t)
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{
+			makeHTTPStopResponse("done"),
+			makeHTTPToolCallResponse("call-edit-1", "edit_file", `{"path":"fixed.txt"}`),
+			makeHTTPStopResponse("fixed"),
+		},
+	}
+	registry := tools.New()
+	registry.Register(tools.EditFileToolDef(), func(_ context.Context, _ map[string]any) (string, error) {
+		if err := os.WriteFile(filepath.Join(dir, "fixed.txt"), []byte("ok"), 0o644); err != nil {
+			return "", err
+		}
+		return "Edited fixed.txt", nil
+	})
+
+	runner := RunFixUntilGreen(FixUntilGreenOptions{
+		TestCommand: "test -f fixed.txt || { echo 'FAIL: fixed.txt missing'; exit 1; }",
+		MaxAttempts: 3,
+		Workdir:     dir,
+	})
+	history, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("make tests pass")}, registry)
+	if err != nil {
+		t.Fatalf("runner error: %v", err)
+	}
+
+	if mock.callCount != 3 {
+		t.Fatalf("LLM calls = %d, want 3", mock.callCount)
+	}
+	if !strings.Contains(string(mock.requestBodies[1]), "fixed.txt missing") {
+		t.Fatalf("second request should carry test failures, got %s", mock.requestBodies[1])
+	}
+
+	var toolOutput string
+	for _, msg := range history {
+		if msg.OfTool != nil {
+			toolOutput = msg.OfTool.Content.OfString.Value
+		}
+	}
+	if !strings.Contains(toolOutput, "[auto-test] PASS") {
+		t.Fatalf("edit tool output should include auto-test result, got %q", toolOutput)
+	}
+}
+
+func TestRunFixUntilGreen_StopsAfterMaxAttempts(t *testing.T) {
+	dir := sandboxLoopDir(t)
+	mock := &capturingMockHTTPClient{}
+
+	runner := RunFixUntilGreen(FixUntilGreenOptions{
+		TestCommand: "echo still broken; exit 1",
+		MaxAttempts: 2,
+		Workdir:     dir,
+	})
+	_, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix it")}, tools.New())
+	if !errors.Is(err, ErrTestsStillFailing) {
+		t.Fatalf("expected ErrTestsStillFailing, got %v", err)
+	}
+	if mock.callCount != 2 {
+		t.Fatalf("LLM calls = %d, want 2", mock.callCount)
+	}
+}
+
+func TestRunFixUntilGreen_RequiresTestCommand(t *testing.T) {
+	_, err := RunFixUntilGreen(FixUntilGreenOptions{})(context.Background(), nil, "mock-model", nil, tools.New())
+	if err == nil || !strings.Contains(err.Error(), "test command") {
+		t.Fatalf("expected test command error, got %v", err)
+	}
+}
+
+func TestFixUntilGreenOptionsFromEnv(t *testing.T) {
+	t.Setenv("AGENT_TEST_COMMAND", "go test ./...")
+	t.Setenv("AGENT_FIX_MAX_ATTEMPTS", "5")
+
+	opts, ok := FixUntilGreenOptionsFromEnv()
+	if !ok || opts.TestCommand != "go test ./..." || opts.MaxAttempts != 5 {
+		t.Fatalf("unexpected options: %+v ok=%v", opts, ok)
+	}
+}
+
+func TestWithFixUntilGreenFromEnv(t *testing.T) {
+	dir := sandboxLoopDir(t)
+	calls := 0
+	runner := func(_ context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		calls++
+		return messages, nil
+	}
+
+	t.Setenv("AGENT_TEST_COMMAND", "")
+	if _, err := WithFixUntilGreenFromEnv(runner, dir)(context.Background(), nil, "mock-model", nil, tools.New()); err != nil || calls != 1 {
+		t.Fatalf("without a test command: calls = %d, err = %v", calls, err)
+	}
+
+	calls = 0
+	t.Setenv("AGENT_TEST_COMMAND", "test -f green.txt")
+	t.Setenv("AGENT_FIX_MAX_ATTEMPTS", "2")
+	_, err := WithFixUntilGreenFromEnv(runner, dir)(context.Background(), nil, "mock-model", nil, tools.New())
+	if !errors.Is(err, ErrTestsStillFailing) || calls != 2 {
+		t.Fatalf("red tests: calls = %d, err = %v", calls, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "green.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	calls = 0
+	if _, err := WithFixUntilGreenFromEnv(runner, dir)(context.Background(), nil, "mock-model", nil, tools.New()); err != nil || calls != 1 {
+		t.Fatalf("green tests: calls = %d, err = %v", calls, err)
+	}
+}
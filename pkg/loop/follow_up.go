@@ -0,0 +1,28 @@
+package loop
+
+import (
+	"context"
+
+	"github.com/openai/openai-go"
+)
+
+// FollowUpSource returns user messages that arrived while a run was in
+// progress; each call drains them.
+type FollowUpSource func() []openai.ChatCompletionMessageParamUnion
+
+type followUpKey struct{}
+
+// WithFollowUps attaches a source of mid-run user messages to ctx. Run
+// appends them to the conversation before each model call, after the
+// results of the previous tool calls.
+func WithFollowUps(ctx context.Context, source FollowUpSource) context.Context {
+	return context.WithValue(ctx, followUpKey{}, source)
+}
+
+func drainFollowUps(ctx context.Context) []openai.ChatCompletionMessageParamUnion {
+	source, _ := ctx.Value(followUpKey{}).(FollowUpSource)
+	if source == nil {
+		return nil
+	}
+	return source()
+}
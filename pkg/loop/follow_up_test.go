@@ -0,0 +1,35 @@
+package loop
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestRun_AppendsFollowUpsBeforeModelCall(t *testing.T) {
+	mock := &backgroundMockHTTPClient{
+		responses: []*http.Response{makeLoopHTTPStopResponse("done")},
+	}
+	pending := []openai.ChatCompletionMessageParamUnion{openai.UserMessage("also update the docs")}
+	ctx := WithFollowUps(context.Background(), func() []openai.ChatCompletionMessageParamUnion {
+		out := pending
+		pending = nil
+		return out
+	})
+
+	history, err := Run(ctx, newLoopMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix the bug")}, tools.New())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("history = %d messages, want user+follow-up+assistant", len(history))
+	}
+	if body := string(mock.requestBodies[0]); !strings.Contains(body, "also update the docs") {
+		t.Fatalf("expected follow-up in request, got %s", body)
+	}
+}
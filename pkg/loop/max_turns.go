@@ -0,0 +1,30 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrMaxTurns is returned by Run when the turn limit attached via WithMaxTurns
+// is reached while the model still wants to call tools.
+var ErrMaxTurns = errors.New("max turns reached")
+
+type maxTurnsKey struct{}
+
+// WithMaxTurns limits Run to n model calls; n <= 0 means no limit.
+func WithMaxTurns(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxTurnsKey{}, n)
+}
+
+func maxTurnsFrom(ctx context.Context) int {
+	n, _ := ctx.Value(maxTurnsKey{}).(int)
+	return n
+}
+
+func checkTurnLimit(ctx context.Context, turn int) error {
+	if limit := maxTurnsFrom(ctx); limit > 0 && turn >= limit {
+		return fmt.Errorf("%w (%d)", ErrMaxTurns, limit)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package loop
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+)
+
+const (
+	defaultPruneRecentTurns       = 2
+	defaultPruneRecentToolResults = 3
+	defaultPruneToolOutputTokens  = 200
+)
+
+// Retention priorities, lowest dropped first.
+const (
+	priorityOldToolOutput = iota
+	priorityOldTurn
+	priorityRecentToolResult
+	priorityRecentTurn
+	prioritySystem
+)
+
+// PruneOptions configures priority-based pruning of the messages sent to the
+// model.
+type PruneOptions struct {
+	// BudgetTokens is the most the request history may count; <= 0 disables
+	// pruning.
+	BudgetTokens int
+	// RecentTurns is how many of the latest user turns are never pruned.
+	RecentTurns int
+	// RecentToolResults is how many of the latest tool exchanges, counting
+	// those inside the recent turns, outrank older conversation.
+	RecentToolResults int
+	// ToolOutputTokens is what old tool outputs are truncated to before any
+	// message is dropped.
+	ToolOutputTokens int
+}
+
+func withPruneDefaults(opts PruneOptions) PruneOptions {
+	if opts.RecentTurns <= 0 {
+		opts.RecentTurns = defaultPruneRecentTurns
+	}
+	if opts.RecentToolResults <= 0 {
+		opts.RecentToolResults = defaultPruneRecentToolResults
+	}
+	if opts.ToolOutputTokens <= 0 {
+		opts.ToolOutputTokens = defaultPruneToolOutputTokens
+	}
+	return opts
+}
+
+type pruneKey struct{}
+
+// WithPruning makes Run prune each request's history to opts.BudgetTokens with
+// PruneMessages. The history Run returns is not pruned.
+func WithPruning(ctx context.Context, opts PruneOptions) context.Context {
+	return context.WithValue(ctx, pruneKey{}, opts)
+}
+
+func pruneForRequest(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	opts, ok := ctx.Value(pruneKey{}).(PruneOptions)
+	if !ok {
+		return messages
+	}
+	return PruneMessages(messages, opts)
+}
+
+// messageUnit is a run of messages that must be kept or dropped together: an
+// assistant tool call and its results, or a single message.
+type messageUnit struct {
+	start, end int
+	priority   int
+	toolCall   bool
+}
+
+// PruneMessages fits messages into opts.BudgetTokens by retention priority:
+// system > recent user turns > recent tool results > older turns > old tool
+// outputs. Old tool outputs are first truncated, then whole units are dropped
+// lowest priority and oldest first, never splitting a tool call from its
+// results. System messages and recent turns are always kept, so the result can
+// still exceed the budget. The input slice is not modified.
+func PruneMessages(messages []openai.ChatCompletionMessageParamUnion, opts PruneOptions) []openai.ChatCompletionMessageParamUnion {
+	if opts.BudgetTokens <= 0 {
+		return messages
+	}
+	counter := tokens.Default()
+	if tokens.CountMessages(counter, messages) <= opts.BudgetTokens {
+		return messages
+	}
+	opts = withPruneDefaults(opts)
+
+	out := slices.Clone(messages)
+	units := prioritizeUnits(out, opts)
+
+	// Pass 1: truncate old tool outputs, oldest first.
+	for _, u := range units {
+		if u.priority != priorityOldToolOutput {
+			continue
+		}
+		for i := u.start + 1; i < u.end; i++ {
+			if tool := out[i].OfTool; tool != nil {
+				truncated := tokens.Truncate(counter, tool.Content.OfString.Value, opts.ToolOutputTokens)
+				out[i] = openai.ToolMessage(truncated, tool.ToolCallID)
+			}
+		}
+		if tokens.CountMessages(counter, out) <= opts.BudgetTokens {
+			return out
+		}
+	}
+
+	// Pass 2: drop units by ascending priority, oldest first.
+	order := slices.Clone(units)
+	slices.SortStableFunc(order, func(a, b messageUnit) int { return a.priority - b.priority })
+
+	dropped := make(map[int]bool)
+	total := tokens.CountMessages(counter, out)
+	droppedMessages := 0
+	for _, u := range order {
+		if u.priority >= priorityRecentTurn || total <= opts.BudgetTokens {
+			break
+		}
+		dropped[u.start] = true
+		total -= tokens.CountMessages(counter, out[u.start:u.end])
+		droppedMessages += u.end - u.start
+	}
+	if droppedMessages == 0 {
+		return out
+	}
+
+	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(out)-droppedMessages+1)
+	noted := false
+	for _, u := range units {
+		if dropped[u.start] {
+			continue
+		}
+		if !noted && u.priority != prioritySystem {
+			result = append(result, openai.UserMessage(fmt.Sprintf(
+				"[%d earlier messages were pruned to fit the context budget]", droppedMessages)))
+			noted = true
+		}
+		result = append(result, out[u.start:u.end]...)
+	}
+	return result
+}
+
+func prioritizeUnits(messages []openai.ChatCompletionMessageParamUnion, opts PruneOptions) []messageUnit {
+	var units []messageUnit
+	for i := 0; i < len(messages); {
+		u := messageUnit{start: i, end: i + 1}
+		if msg := messages[i].OfAssistant; msg != nil && len(msg.ToolCalls) > 0 {
+			u.toolCall = true
+			for u.end < len(messages) && messages[u.end].OfTool != nil {
+				u.end++
+			}
+		}
+		units = append(units, u)
+		i = u.end
+	}
+
+	// Recent turns start at the RecentTurns-th user message from the end.
+	recentFrom := len(messages)
+	for i, seen := len(messages)-1, 0; i >= 0 && seen < opts.RecentTurns; i-- {
+		if messages[i].OfUser != nil {
+			recentFrom = i
+			seen++
+		}
+	}
+
+	recentTools := 0
+	for i := len(units) - 1; i >= 0; i-- {
+		u := &units[i]
+		msg := messages[u.start]
+		if u.toolCall {
+			recentTools++
+		}
+		switch {
+		case msg.OfSystem != nil || msg.OfDeveloper != nil:
+			u.priority = prioritySystem
+		case u.start >= recentFrom:
+			u.priority = priorityRecentTurn
+		case u.toolCall && recentTools <= opts.RecentToolResults:
+			u.priority = priorityRecentToolResult
+		case u.toolCall:
+			u.priority = priorityOldToolOutput
+		default:
+			u.priority = priorityOldTurn
+		}
+	}
+	return units
+}
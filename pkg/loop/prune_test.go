@@ -0,0 +1,89 @@
+package loop
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func pruneFixture() []openai.ChatCompletionMessageParamUnion {
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("first task"),
+		assistantToolCallParam("call-1", "read_file", `{"path":"big.txt"}`),
+		openai.ToolMessage(strings.Repeat("old output ", 400), "call-1"),
+		openai.AssistantMessage("first answer"),
+		openai.UserMessage("second task"),
+		assistantToolCallParam("call-2", "bash", `{"command":"go test"}`),
+		openai.ToolMessage("ok", "call-2"),
+		openai.AssistantMessage("second answer"),
+	}
+}
+
+func TestPruneMessages_TruncatesOldToolOutputFirst(t *testing.T) {
+	messages := pruneFixture()
+	budget := tokens.CountMessages(tokens.Default(), messages) - 500
+
+	pruned := PruneMessages(messages, PruneOptions{BudgetTokens: budget, RecentTurns: 1, RecentToolResults: 1})
+	if len(pruned) != len(messages) {
+		t.Fatalf("expected truncation only, got %d messages", len(pruned))
+	}
+	if !strings.Contains(pruned[3].OfTool.Content.OfString.Value, "truncated") {
+		t.Fatalf("old tool output was not truncated: %q", pruned[3].OfTool.Content.OfString.Value)
+	}
+	if messages[3].OfTool.Content.OfString.Value != strings.Repeat("old output ", 400) {
+		t.Fatal("input must not be modified")
+	}
+}
+
+func TestPruneMessages_DropsLowPriorityUnitsWithoutOrphans(t *testing.T) {
+	messages := pruneFixture()
+	pruned := PruneMessages(messages, PruneOptions{BudgetTokens: 40, RecentTurns: 1, RecentToolResults: 1, ToolOutputTokens: 10})
+
+	if pruned[0].OfSystem == nil {
+		t.Fatal("system message must be kept")
+	}
+	if pruned[1].OfUser == nil || !strings.Contains(pruned[1].OfUser.Content.OfString.Value, "pruned") {
+		t.Fatalf("expected pruning note after system message, got %+v", pruned[1])
+	}
+	last := pruned[len(pruned)-4:]
+	if last[0].OfUser == nil || last[0].OfUser.Content.OfString.Value != "second task" {
+		t.Fatalf("recent turn must be kept intact, got %+v", last[0])
+	}
+	if !isValidMessageSuffix(pruned) {
+		t.Fatal("pruning left a tool result without its call")
+	}
+	for _, msg := range pruned {
+		if msg.OfTool != nil && msg.OfTool.ToolCallID == "call-1" {
+			t.Fatal("old tool exchange should be dropped before older turns")
+		}
+	}
+}
+
+func TestPruneMessages_UnderBudgetIsUnchanged(t *testing.T) {
+	messages := pruneFixture()
+	if got := PruneMessages(messages, PruneOptions{BudgetTokens: 1 << 20}); len(got) != len(messages) {
+		t.Fatalf("messages = %d, want unchanged", len(got))
+	}
+}
+
+func TestRun_WithPruningSendsPrunedHistory(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{makeHTTPStopResponse("done")}}
+	ctx := WithPruning(context.Background(), PruneOptions{BudgetTokens: 40, RecentTurns: 1, RecentToolResults: 1})
+
+	history, err := Run(ctx, newCapturingMockClient(mock), "mock-model", pruneFixture(), tools.New())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.Contains(string(mock.requestBodies[0]), "old output") {
+		t.Fatal("request should not carry the pruned tool output")
+	}
+	if len(history) != len(pruneFixture())+1 {
+		t.Fatalf("returned history = %d messages, want the full history plus the reply", len(history))
+	}
+}
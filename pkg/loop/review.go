@@ -0,0 +1,255 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultReviewMaxRounds = 2
+	maxReviewDiffLength    = 20000
+	maxReviewNewFileLength = 4000
+)
+
+const reviewerSystemPrompt = `You are a strict code reviewer. You are given the user's original request,
+the coding agent's final answer, and the diff it produced. Decide whether the diff fully and
+correctly implements the request.
+
+Reply with APPROVE on the first line if it does.
+Otherwise reply with REVISE on the first line, followed by a numbered list of concrete,
+actionable revision instructions (file, what is wrong, what to change). Do not rewrite the code yourself.`
+
+// ErrReviewNotApproved is returned by RunWithReview when the reviewer still
+// requests changes after the last allowed round.
+var ErrReviewNotApproved = errors.New("review not approved")
+
+// ReviewOptions configures the second-pass reviewer.
+type ReviewOptions struct {
+	// Model is the reviewer model; empty means the main agent's model.
+	Model string
+	// MaxRounds bounds how many times revision instructions are sent back.
+	MaxRounds int
+	Workdir   string
+	// Diff returns the changes to review; defaults to GitDiff(Workdir).
+	Diff func(ctx context.Context) (string, error)
+	// Runner is the underlying loop; defaults to Run.
+	Runner AgentRunner
+}
+
+// ReviewVerdict is the parsed reviewer reply.
+type ReviewVerdict struct {
+	Approved     bool
+	Instructions string
+}
+
+// ReviewOptionsFromEnv reads AGENT_REVIEW, AGENT_REVIEW_MODEL and
+// AGENT_REVIEW_MAX_ROUNDS. ok is false unless AGENT_REVIEW is truthy or a
+// reviewer model is set.
+func ReviewOptionsFromEnv() (opts ReviewOptions, ok bool) {
+	opts.Model = strings.TrimSpace(os.Getenv("AGENT_REVIEW_MODEL"))
+	if raw := strings.TrimSpace(os.Getenv("AGENT_REVIEW_MAX_ROUNDS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			opts.MaxRounds = n
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_REVIEW"))) {
+	case "1", "true", "yes", "y", "on":
+		ok = true
+	}
+	return opts, ok || opts.Model != ""
+}
+
+// WithReviewFromEnv wraps runner in RunWithReview, reviewing the diff of
+// workdir, when AGENT_REVIEW or AGENT_REVIEW_MODEL is set; otherwise it
+// returns runner unchanged.
+func WithReviewFromEnv(runner AgentRunner, workdir string) AgentRunner {
+	opts, ok := ReviewOptionsFromEnv()
+	if !ok {
+		return runner
+	}
+	opts.Workdir, opts.Runner = workdir, runner
+	return RunWithReview(opts)
+}
+
+func withReviewDefaults(opts ReviewOptions) ReviewOptions {
+	if opts.MaxRounds <= 0 {
+		opts.MaxRounds = defaultReviewMaxRounds
+	}
+	if opts.Diff == nil {
+		workdir := opts.Workdir
+		opts.Diff = func(ctx context.Context) (string, error) { return GitDiff(ctx, workdir) }
+	}
+	if opts.Runner == nil {
+		opts.Runner = Run
+	}
+	return opts
+}
+
+// RunWithReview returns a runner that, after the main agent stops, asks a
+// reviewer model to check the diff against the original request. Revision
+// instructions are sent back to the agent as a user message, up to MaxRounds
+// times. Runs that produce no diff are not reviewed.
+func RunWithReview(opts ReviewOptions) AgentRunner {
+	opts = withReviewDefaults(opts)
+
+	return func(
+		ctx context.Context,
+		client *openai.Client,
+		model string,
+		messages []openai.ChatCompletionMessageParamUnion,
+		registry *tools.Registry,
+	) ([]openai.ChatCompletionMessageParamUnion, error) {
+		request := lastUserText(messages)
+		reviewModel := opts.Model
+		if reviewModel == "" {
+			reviewModel = model
+		}
+
+		for round := 1; ; round++ {
+			history, err := opts.Runner(ctx, client, model, messages, registry)
+			if err != nil {
+				return history, err
+			}
+
+			diff, err := opts.Diff(ctx)
+			if err != nil {
+				return history, fmt.Errorf("review: collect diff: %w", err)
+			}
+			if strings.TrimSpace(diff) == "" {
+				return history, nil
+			}
+
+			verdict, err := RequestReview(ctx, client, reviewModel, request, lastAssistantText(history), diff)
+			if err != nil {
+				return history, err
+			}
+			if verdict.Approved {
+				return history, nil
+			}
+			if round >= opts.MaxRounds {
+				return history, fmt.Errorf("%w after %d round(s): %s", ErrReviewNotApproved, round, verdict.Instructions)
+			}
+
+			messages = append(history, openai.UserMessage(fmt.Sprintf(
+				"<review round=\"%d/%d\">\n%s\n</review>\n"+
+					"A reviewer checked your changes against the original request and asked for revisions. Address every point above.",
+				round, opts.MaxRounds, verdict.Instructions,
+			)))
+		}
+	}
+}
+
+// RequestReview asks the reviewer model for a verdict on diff.
+func RequestReview(ctx context.Context, client *openai.Client, model, request, answer, diff string) (ReviewVerdict, error) {
+	if len(diff) > maxReviewDiffLength {
+		diff = diff[:maxReviewDiffLength] + "\n... (diff truncated)"
+	}
+	prompt := fmt.Sprintf(
+		"<request>\n%s\n</request>\n\n<agent-answer>\n%s\n</agent-answer>\n\n<diff>\n%s\n</diff>",
+		request, answer, diff,
+	)
+
+	resp, err := llm.Complete(ctx, client, openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(reviewerSystemPrompt),
+			openai.UserMessage(prompt),
+		},
+	})
+	if err != nil {
+		return ReviewVerdict{}, fmt.Errorf("review call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return ReviewVerdict{}, fmt.Errorf("review call returned no choices")
+	}
+	return ParseReviewVerdict(resp.Choices[0].Message.Content), nil
+}
+
+// ParseReviewVerdict interprets the reviewer reply. Anything that does not
+// start with APPROVE is treated as a revision request.
+func ParseReviewVerdict(content string) ReviewVerdict {
+	content = strings.TrimSpace(content)
+	first, rest, _ := strings.Cut(content, "\n")
+	line := strings.Trim(strings.TrimSpace(first), "*#`:. ")
+	keyword := strings.ToUpper(line)
+
+	switch {
+	case strings.HasPrefix(keyword, "APPROVE"):
+		return ReviewVerdict{Approved: true}
+	case strings.HasPrefix(keyword, "REVISE"):
+		content = strings.TrimSpace(rest)
+		if content == "" {
+			content = strings.TrimSpace(strings.TrimLeft(line[len("REVISE"):], "*:.- "))
+		}
+	}
+	if content == "" {
+		content = "The reviewer requested changes without details. Re-check the request and your diff."
+	}
+	return ReviewVerdict{Instructions: content}
+}
+
+// GitDiff returns the uncommitted changes in dir, including untracked files.
+func GitDiff(ctx context.Context, dir string) (string, error) {
+	tracked, err := gitOutput(ctx, dir, "diff", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	untracked, err := gitOutput(ctx, dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(tracked)
+	for _, path := range strings.Fields(untracked) {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		if len(content) > maxReviewNewFileLength {
+			content = content[:maxReviewNewFileLength] + "\n... (truncated)"
+		}
+		fmt.Fprintf(&b, "new file: %s\n%s\n", path, content)
+	}
+	return b.String(), nil
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func lastUserText(messages []openai.ChatCompletionMessageParamUnion) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if msg := messages[i].OfUser; msg != nil && msg.Content.OfString.Value != "" {
+			return msg.Content.OfString.Value
+		}
+	}
+	return ""
+}
+
+func lastAssistantText(messages []openai.ChatCompletionMessageParamUnion) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if msg := messages[i].OfAssistant; msg != nil && msg.Content.OfString.Value != "" {
+			return msg.Content.OfString.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// 主模型完成 → 评审要求修改 → 主模型修订 → 评审通过。
+func TestRunWithReview_SendsRevisionInstructionsBack(t *testing.T) {
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{
+			makeHTTPStopResponse("done"),
+			makeHTTPStopResponse("REVISE\n1. main.go: handle the empty input case"),
+			makeHTTPStopResponse("handled empty input"),
+			makeHTTPStopResponse("APPROVE"),
+		},
+	}
+	runner := RunWithReview(ReviewOptions{
+		Model: "reviewer-model",
+		Diff:  func(context.Context) (string, error) { return "diff --git a/main.go b/main.go\n+fix", nil },
+	})
+
+	history, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix the parser")}, tools.New())
+	if err != nil {
+		t.Fatalf("runner error: %v", err)
+	}
+
+	if mock.callCount != 4 {
+		t.Fatalf("LLM calls = %d, want 4", mock.callCount)
+	}
+	review := string(mock.requestBodies[1])
+	if !strings.Contains(review, `"model":"reviewer-model"`) || !strings.Contains(review, "fix the parser") {
+		t.Fatalf("review request should use reviewer model and original request, got %s", review)
+	}
+	if !strings.Contains(string(mock.requestBodies[2]), "handle the empty input case") {
+		t.Fatalf("revision instructions not sent back, got %s", mock.requestBodies[2])
+	}
+	if got := lastAssistantText(history); got != "handled empty input" {
+		t.Fatalf("final answer = %q", got)
+	}
+}
+
+func TestRunWithReview_StopsAfterMaxRounds(t *testing.T) {
+	mock := &capturingMockHTTPClient{
+		responses: []*http.Response{
+			makeHTTPStopResponse("done"),
+			makeHTTPStopResponse("REVISE: still wrong"),
+		},
+	}
+	runner := RunWithReview(ReviewOptions{
+		MaxRounds: 1,
+		Diff:      func(context.Context) (string, error) { return "+change", nil },
+	})
+
+	_, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("task")}, tools.New())
+	if !errors.Is(err, ErrReviewNotApproved) || !strings.Contains(err.Error(), "still wrong") {
+		t.Fatalf("expected ErrReviewNotApproved with instructions, got %v", err)
+	}
+}
+
+func TestRunWithReview_SkipsEmptyDiff(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{makeHTTPStopResponse("just an answer")}}
+	runner := RunWithReview(ReviewOptions{Diff: func(context.Context) (string, error) { return "", nil }})
+
+	if _, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("question")}, tools.New()); err != nil {
+		t.Fatalf("runner error: %v", err)
+	}
+	if mock.callCount != 1 {
+		t.Fatalf("reviewer should not be called without a diff, calls = %d", mock.callCount)
+	}
+}
+
+func TestParseReviewVerdict(t *testing.T) {
+	if !ParseReviewVerdict("**APPROVE**\nLooks good.").Approved {
+		t.Fatal("expected approval")
+	}
+	if got := ParseReviewVerdict("Revise: rename foo").Instructions; got != "rename foo" {
+		t.Fatalf("inline instructions = %q", got)
+	}
+	verdict := ParseReviewVerdict("The change misses tests.")
+	if verdict.Approved || verdict.Instructions != "The change misses tests." {
+		t.Fatalf("unexpected verdict: %+v", verdict)
+	}
+}
+
+func TestWithReviewFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	runner := func(_ context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage("done")), nil
+	}
+	messages := []openai.ChatCompletionMessageParamUnion{openai.UserMessage("add main.go")}
+
+	t.Setenv("AGENT_REVIEW", "")
+	t.Setenv("AGENT_REVIEW_MODEL", "")
+	mock := &capturingMockHTTPClient{}
+	if _, err := WithReviewFromEnv(runner, dir)(context.Background(), newCapturingMockClient(mock), "mock-model", messages, tools.New()); err != nil || mock.callCount != 0 {
+		t.Fatalf("without AGENT_REVIEW: reviewer calls = %d, err = %v", mock.callCount, err)
+	}
+
+	t.Setenv("AGENT_REVIEW_MODEL", "reviewer-model")
+	t.Setenv("AGENT_REVIEW_MAX_ROUNDS", "1")
+	mock = &capturingMockHTTPClient{responses: []*http.Response{makeHTTPStopResponse("REVISE: main.go is empty")}}
+	_, err := WithReviewFromEnv(runner, dir)(context.Background(), newCapturingMockClient(mock), "mock-model", messages, tools.New())
+	if !errors.Is(err, ErrReviewNotApproved) {
+		t.Fatalf("expected ErrReviewNotApproved, got %v", err)
+	}
+	if mock.callCount != 1 || !strings.Contains(string(mock.requestBodies[0]), "reviewer-model") || !strings.Contains(string(mock.requestBodies[0]), "main.go") {
+		t.Fatalf("reviewer request = %s", mock.requestBodies)
+	}
+}
@@ -0,0 +1,32 @@
+package loop
+
+import (
+	"context"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/snapshot"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// RunWithSnapshots treats each invocation as one user turn: the workspace is
+// snapshotted before the turn's first mutating tool call so the user can roll
+// the turn back with /undo (snapshots.UndoCommand). runner defaults to Run.
+func RunWithSnapshots(snapshots *snapshot.Manager, runner AgentRunner) AgentRunner {
+	if runner == nil {
+		runner = Run
+	}
+
+	return func(
+		ctx context.Context,
+		client *openai.Client,
+		model string,
+		messages []openai.ChatCompletionMessageParamUnion,
+		registry *tools.Registry,
+	) ([]openai.ChatCompletionMessageParamUnion, error) {
+		if snapshots == nil {
+			return runner(ctx, client, model, messages, registry)
+		}
+		snapshots.BeginTurn()
+		return runner(ctx, client, model, messages, registry.WithMiddleware(snapshots.Middleware()))
+	}
+}
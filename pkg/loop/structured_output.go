@@ -0,0 +1,211 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/jsonschema"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultOutputMaxRetries = 2
+	defaultOutputSchemaName = "final_answer"
+)
+
+// Response formats for the formatting calls of RunWithOutputSchema, from the
+// strictest to none at all.
+const (
+	FormatJSONSchema = "json_schema"
+	FormatJSONObject = "json_object"
+	FormatNone       = "none"
+)
+
+// ErrOutputSchema is returned by RunWithOutputSchema when the final answer
+// still does not match the schema after the last retry.
+var ErrOutputSchema = errors.New("final answer does not match the output schema")
+
+// OutputOptions configures RunWithOutputSchema.
+type OutputOptions struct {
+	// Schema is the JSON schema the final answer must match.
+	Schema map[string]any
+	// Name labels the schema in the json_schema response format.
+	Name string
+	// Format is the response format asked for first (default: the
+	// strictest the model supports, see llm.CapabilitiesFor). Providers that
+	// reject it get the next weaker one.
+	Format string
+	// MaxRetries bounds the formatting calls (default 2).
+	MaxRetries int
+	// Runner is the underlying loop; defaults to Run.
+	Runner AgentRunner
+}
+
+// RunWithOutputSchema returns a runner whose final assistant message is a
+// JSON value matching opts.Schema, so headless callers can parse the reply.
+// When the agent's own final answer does not match, the model is asked
+// again without tools, with response_format set where the provider supports
+// it and the validation error in the prompt, up to MaxRetries times.
+func RunWithOutputSchema(opts OutputOptions) AgentRunner {
+	if opts.Name == "" {
+		opts.Name = defaultOutputSchemaName
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultOutputMaxRetries
+	}
+	if opts.Runner == nil {
+		opts.Runner = Run
+	}
+
+	return func(
+		ctx context.Context,
+		client *openai.Client,
+		model string,
+		messages []openai.ChatCompletionMessageParamUnion,
+		registry *tools.Registry,
+	) ([]openai.ChatCompletionMessageParamUnion, error) {
+		history, err := opts.Runner(ctx, client, model, messages, registry)
+		if err != nil {
+			return history, err
+		}
+		ctx, caps := withCapabilities(ctx, model)
+
+		output, violation := checkOutput(opts.Schema, lastAssistantText(history))
+		if violation == nil {
+			return replaceFinalAnswer(history, output), nil
+		}
+
+		schema, err := json.Marshal(opts.Schema)
+		if err != nil {
+			return history, fmt.Errorf("encode output schema: %w", err)
+		}
+		format := opts.Format
+		if format == "" {
+			format = strictestFormat(caps)
+		}
+		for attempt := 1; ; attempt++ {
+			history = append(history, openai.UserMessage(fmt.Sprintf(
+				"<output-schema>\n%s\n</output-schema>\n<problem>%s</problem>\n"+
+					"Give your final answer again as a single JSON value matching the schema above. "+
+					"Reply with the JSON only: no prose, no code fences.",
+				schema, violation,
+			)))
+
+			var resp *openai.ChatCompletion
+			resp, format, err = completeFormatted(ctx, client, model, history, opts, format)
+			if err != nil {
+				return history, fmt.Errorf("output format call failed: %w", err)
+			}
+			recordUsage(budget.TrackerFrom(ctx), resp, model)
+			if len(resp.Choices) == 0 {
+				return history, fmt.Errorf("output format call returned no choices")
+			}
+			history = append(history, resp.Choices[0].Message.ToParam())
+
+			output, violation = checkOutput(opts.Schema, resp.Choices[0].Message.Content)
+			if violation == nil {
+				return replaceFinalAnswer(history, output), nil
+			}
+			if attempt >= opts.MaxRetries {
+				return history, fmt.Errorf("%w after %d attempt(s): %v", ErrOutputSchema, attempt, violation)
+			}
+		}
+	}
+}
+
+// completeFormatted makes one formatting call, falling back to a weaker
+// response format while the provider rejects the request. It returns the
+// format that worked, for the next attempt.
+func completeFormatted(
+	ctx context.Context,
+	client *openai.Client,
+	model string,
+	messages []openai.ChatCompletionMessageParamUnion,
+	opts OutputOptions,
+	format string,
+) (*openai.ChatCompletion, string, error) {
+	for {
+		params := openai.ChatCompletionNewParams{
+			Model:    shared.ChatModel(model),
+			Messages: messages,
+		}
+		switch format {
+		case FormatJSONSchema:
+			params.ResponseFormat.OfJSONSchema = &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: opts.Name, Schema: opts.Schema},
+			}
+		case FormatJSONObject:
+			params.ResponseFormat.OfJSONObject = &shared.ResponseFormatJSONObjectParam{}
+		}
+
+		resp, err := llm.Complete(ctx, client, params)
+		var apiErr *openai.Error
+		if err != nil && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			switch format {
+			case FormatJSONSchema:
+				format = FormatJSONObject
+				continue
+			case FormatJSONObject:
+				format = FormatNone
+				continue
+			}
+		}
+		return resp, format, err
+	}
+}
+
+// strictestFormat returns the strictest response format a model with caps
+// supports.
+func strictestFormat(caps llm.Capabilities) string {
+	switch {
+	case caps.JSONSchema:
+		return FormatJSONSchema
+	case caps.JSONMode:
+		return FormatJSONObject
+	default:
+		return FormatNone
+	}
+}
+
+// checkOutput parses text as JSON, tolerating a surrounding code fence, and
+// validates it against schema. It returns the bare JSON.
+func checkOutput(schema map[string]any, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSpace(strings.TrimSuffix(text, "```"))
+	}
+	if text == "" {
+		return "", errors.New("the final answer is empty")
+	}
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", fmt.Errorf("the final answer is not valid JSON: %v", err)
+	}
+	if err := jsonschema.Validate(schema, value); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// replaceFinalAnswer sets the last assistant message to output, e.g. to drop
+// a code fence around the JSON.
+func replaceFinalAnswer(history []openai.ChatCompletionMessageParamUnion, output string) []openai.ChatCompletionMessageParamUnion {
+	last := len(history) - 1
+	if last < 0 || history[last].OfAssistant == nil || history[last].OfAssistant.Content.OfString.Value == output {
+		return history
+	}
+	msg := *history[last].OfAssistant
+	msg.Content.OfString = openai.String(output)
+	history[last] = openai.ChatCompletionMessageParamUnion{OfAssistant: &msg}
+	return history
+}
@@ -0,0 +1,94 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+var testOutputSchema = map[string]any{
+	"type":     "object",
+	"required": []any{"status"},
+	"properties": map[string]any{
+		"status": map[string]any{"enum": []any{"fixed", "unchanged"}},
+	},
+}
+
+func makeHTTPBadRequest(message string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"` + message + `","type":"invalid_request_error"}}`)),
+	}
+}
+
+func TestRunWithOutputSchema_AcceptsFencedAnswer(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStopResponse("```json\n{\"status\":\"fixed\"}\n```"),
+	}}
+	runner := RunWithOutputSchema(OutputOptions{Schema: testOutputSchema})
+
+	history, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix it")}, tools.New())
+	if err != nil {
+		t.Fatalf("runner error: %v", err)
+	}
+	if mock.callCount != 1 {
+		t.Fatalf("LLM calls = %d, want 1", mock.callCount)
+	}
+	if got := lastAssistantText(history); got != `{"status":"fixed"}` {
+		t.Fatalf("final answer = %q", got)
+	}
+}
+
+// 最终回答不符合 schema → 追加格式化调用；json_schema 被拒后降级为 json_object。
+func TestRunWithOutputSchema_RetriesWithWeakerFormat(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStopResponse("I fixed the parser."),
+		makeHTTPBadRequest("response_format json_schema is not supported"),
+		makeHTTPStopResponse(`{"status":"done"}`),
+		makeHTTPStopResponse(`{"status":"fixed"}`),
+	}}
+	runner := RunWithOutputSchema(OutputOptions{Schema: testOutputSchema})
+
+	history, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix it")}, tools.New())
+	if err != nil {
+		t.Fatalf("runner error: %v", err)
+	}
+	if mock.callCount != 4 {
+		t.Fatalf("LLM calls = %d, want 4", mock.callCount)
+	}
+	if body := string(mock.requestBodies[1]); !strings.Contains(body, `"type":"json_schema"`) || strings.Contains(body, `"tools"`) {
+		t.Fatalf("first format call should ask for json_schema without tools, got %s", body)
+	}
+	if body := string(mock.requestBodies[2]); !strings.Contains(body, `"type":"json_object"`) {
+		t.Fatalf("rejected json_schema should fall back to json_object, got %s", body)
+	}
+	if body := string(mock.requestBodies[3]); !strings.Contains(body, `"type":"json_object"`) || !strings.Contains(body, `\"done\" is not one of`) {
+		t.Fatalf("retry should keep json_object and report the violation, got %s", body)
+	}
+	if got := lastAssistantText(history); got != `{"status":"fixed"}` {
+		t.Fatalf("final answer = %q", got)
+	}
+}
+
+func TestRunWithOutputSchema_GivesUpAfterMaxRetries(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStopResponse("done"),
+		makeHTTPStopResponse("still prose"),
+	}}
+	runner := RunWithOutputSchema(OutputOptions{Schema: testOutputSchema, MaxRetries: 1})
+
+	_, err := runner(context.Background(), newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix it")}, tools.New())
+	if !errors.Is(err, ErrOutputSchema) || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Fatalf("expected ErrOutputSchema, got %v", err)
+	}
+}
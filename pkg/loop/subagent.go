@@ -2,12 +2,12 @@ package loop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
@@ -50,7 +50,7 @@ func RunSubagentWithLimit(
 		registry,
 		maxRounds,
 		func(callCtx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
-			return client.Chat.Completions.New(callCtx, params)
+			return llm.Complete(callCtx, client, params)
 		},
 	)
 }
@@ -78,6 +78,8 @@ func runSubagentWithCaller(
 		maxRounds = DefaultSubagentMaxRounds
 	}
 
+	// The subagent's tokens and tool calls are internal; don't forward them to the parent's listener.
+	ctx = WithToolCallHandler(WithTokenHandler(ctx, nil), nil)
 	rec := devtools.RecorderFrom(ctx)
 	provider := inferProviderFromEnv()
 	useStream := isStreamingEnabled()
@@ -123,7 +125,7 @@ func runSubagentWithCaller(
 		} else {
 			resp, callErr = call(ctx, params)
 			if callErr == nil {
-				choice = resp.Choices[0]
+				choice, callErr = firstChoice(resp)
 			}
 		}
 
@@ -158,16 +160,10 @@ func runSubagentWithCaller(
 		for _, tc := range choice.Message.ToolCalls {
 			rec.RegisterToolCall(tc.ID, tc.Function.Name)
 
-			var args map[string]any
-			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-				runErr := fmt.Errorf("failed to parse tool args for %s: %w", tc.Function.Name, err)
-				_ = rec.FinishRun(ctx, devtools.RunResult{
-					Status:           "error",
-					CompletionReason: "error",
-					Summary:          runErr.Error(),
-					Error:            runErr.Error(),
-				})
-				return "", runErr
+			args, err := parseToolArgs(tc)
+			if err != nil {
+				messages = append(messages, openai.ToolMessage("error: "+err.Error(), tc.ID))
+				continue
 			}
 
 			toolCtx := devtools.WithParentStep(ctx, stepID)
@@ -2,11 +2,11 @@ package loop
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/nickdu2009/learn-claude-code/pkg/devtools"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
 	"github.com/nickdu2009/learn-claude-code/pkg/tools"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
@@ -32,7 +32,7 @@ func RunWithTodoNag(
 ) ([]openai.ChatCompletionMessageParamUnion, error) {
 	rec := devtools.RecorderFrom(ctx)
 	provider := inferProviderFromEnv()
-	useStream := isStreamingEnabled()
+	useStream := shouldStream(ctx)
 
 	roundsSinceTodo := 0
 
@@ -67,9 +67,9 @@ func RunWithTodoNag(
 		if useStream {
 			choice, resp, rawChunks, callErr = runStreaming(ctx, client, params)
 		} else {
-			resp, callErr = client.Chat.Completions.New(ctx, params)
+			resp, callErr = llm.Complete(ctx, client, params)
 			if callErr == nil {
-				choice = resp.Choices[0]
+				choice, callErr = firstChoice(resp)
 			}
 		}
 
@@ -99,9 +99,10 @@ func RunWithTodoNag(
 				usedTodoThisRound = true
 			}
 
-			var args map[string]any
-			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-				return messages, fmt.Errorf("failed to parse tool args for %s: %w", tc.Function.Name, err)
+			args, err := parseToolArgs(tc)
+			if err != nil {
+				messages = append(messages, openai.ToolMessage("error: "+err.Error(), tc.ID))
+				continue
 			}
 
 			output, err := registry.Dispatch(ctx, tc.Function.Name, args)
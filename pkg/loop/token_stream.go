@@ -0,0 +1,27 @@
+package loop
+
+import "context"
+
+// TokenHandler receives assistant text deltas as they stream in.
+type TokenHandler func(delta string)
+
+type tokenHandlerKey struct{}
+
+// WithTokenHandler attaches h to ctx. Runners started with such a context use
+// the streaming API and pass every text delta to h, e.g. to forward tokens to
+// a remote client. A nil h removes any handler.
+func WithTokenHandler(ctx context.Context, h TokenHandler) context.Context {
+	return context.WithValue(ctx, tokenHandlerKey{}, h)
+}
+
+// TokenHandlerFrom returns the handler attached to ctx, or nil.
+func TokenHandlerFrom(ctx context.Context) TokenHandler {
+	h, _ := ctx.Value(tokenHandlerKey{}).(TokenHandler)
+	return h
+}
+
+// shouldStream reports whether a runner should use the streaming API: either
+// DevTools streaming is enabled or a token or tool call handler is listening.
+func shouldStream(ctx context.Context) bool {
+	return isStreamingEnabled() || TokenHandlerFrom(ctx) != nil || ToolCallHandlerFrom(ctx) != nil
+}
@@ -0,0 +1,180 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// ToolCallDelta is one streamed fragment of a tool call's arguments.
+type ToolCallDelta struct {
+	// Index orders the calls of one assistant message.
+	Index int
+	ID    string
+	Name  string
+	// Fragment is what just arrived; Arguments is everything so far, usually
+	// incomplete JSON. PartialString reads a field out of it.
+	Fragment  string
+	Arguments string
+}
+
+// ToolCallHandler receives tool call fragments as they stream in, e.g. to
+// show a command while the model is still writing it.
+type ToolCallHandler func(delta ToolCallDelta)
+
+type toolCallHandlerKey struct{}
+
+// WithToolCallHandler attaches h to ctx. Like WithTokenHandler it makes
+// runners use the streaming API. A nil h removes any handler.
+func WithToolCallHandler(ctx context.Context, h ToolCallHandler) context.Context {
+	return context.WithValue(ctx, toolCallHandlerKey{}, h)
+}
+
+// ToolCallHandlerFrom returns the handler attached to ctx, or nil.
+func ToolCallHandlerFrom(ctx context.Context) ToolCallHandler {
+	h, _ := ctx.Value(toolCallHandlerKey{}).(ToolCallHandler)
+	return h
+}
+
+// toolCallAssembler rebuilds tool calls from the fragments of a stream.
+// Providers send the id and name with the first fragment of a call and the
+// arguments in pieces; some repeat the id or the whole name later.
+type toolCallAssembler struct {
+	calls map[int64]*openai.ChatCompletionMessageToolCall
+}
+
+// add merges one fragment and returns the call as assembled so far.
+func (a *toolCallAssembler) add(delta openai.ChatCompletionChunkChoiceDeltaToolCall) ToolCallDelta {
+	if a.calls == nil {
+		a.calls = make(map[int64]*openai.ChatCompletionMessageToolCall)
+	}
+	tc, ok := a.calls[delta.Index]
+	if !ok {
+		tc = &openai.ChatCompletionMessageToolCall{Type: "function"}
+		a.calls[delta.Index] = tc
+	}
+	if delta.ID != "" {
+		tc.ID = delta.ID
+	}
+	if name := delta.Function.Name; name != "" && name != tc.Function.Name {
+		tc.Function.Name += name
+	}
+	tc.Function.Arguments += delta.Function.Arguments
+	return ToolCallDelta{
+		Index:     int(delta.Index),
+		ID:        tc.ID,
+		Name:      tc.Function.Name,
+		Fragment:  delta.Function.Arguments,
+		Arguments: tc.Function.Arguments,
+	}
+}
+
+// toolCalls returns the assembled calls ordered by index. Gaps in the
+// indexes, which some providers leave, are closed up.
+func (a *toolCallAssembler) toolCalls() []openai.ChatCompletionMessageToolCall {
+	indexes := make([]int64, 0, len(a.calls))
+	for i := range a.calls {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	calls := make([]openai.ChatCompletionMessageToolCall, 0, len(indexes))
+	for _, i := range indexes {
+		calls = append(calls, *a.calls[i])
+	}
+	return calls
+}
+
+// parseToolArgs decodes the arguments of a call before it is dispatched.
+// Empty arguments mean no arguments. The error is meant for the model, so it
+// can send the call again.
+func parseToolArgs(tc openai.ChatCompletionMessageToolCall) (map[string]any, error) {
+	raw := strings.TrimSpace(tc.Function.Arguments)
+	if raw == "" {
+		return map[string]any{}, nil
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return nil, fmt.Errorf("invalid JSON arguments for %s (%v); send the call again with a complete JSON object", tc.Function.Name, err)
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return args, nil
+}
+
+// PartialString returns the value of the top-level string field key in
+// args, which may be cut off anywhere, e.g. `{"command": "go te` gives
+// "go te". ok is false until the value has started.
+func PartialString(args, key string) (value string, ok bool) {
+	depth := 0
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '"':
+			end := stringEnd(args, i)
+			if end < 0 {
+				return "", false
+			}
+			name := args[i : end+1]
+			i = end
+			if depth != 1 {
+				continue
+			}
+			rest := strings.TrimLeft(args[end+1:], " \t\r\n")
+			if !strings.HasPrefix(rest, ":") {
+				continue
+			}
+			var decoded string
+			if json.Unmarshal([]byte(name), &decoded) != nil || decoded != key {
+				continue
+			}
+			rest = strings.TrimLeft(rest[1:], " \t\r\n")
+			if !strings.HasPrefix(rest, `"`) {
+				return "", false
+			}
+			return decodePartialString(rest), true
+		}
+	}
+	return "", false
+}
+
+// stringEnd returns the index of the quote closing the JSON string that
+// starts at s[start], or -1 when s ends first.
+func stringEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// decodePartialString decodes the JSON string at the start of s, dropping an
+// escape sequence cut off at the end.
+func decodePartialString(s string) string {
+	end := stringEnd(s, 0)
+	if end < 0 {
+		body := s[1:]
+		// Back off to the last complete escape sequence.
+		for cut := len(body); cut >= 0 && cut >= len(body)-6; cut-- {
+			var decoded string
+			if json.Unmarshal([]byte(`"`+body[:cut]+`"`), &decoded) == nil {
+				return decoded
+			}
+		}
+		return ""
+	}
+	var decoded string
+	_ = json.Unmarshal([]byte(s[:end+1]), &decoded)
+	return decoded
+}
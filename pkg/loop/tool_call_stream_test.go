@@ -0,0 +1,183 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// makeHTTPStreamResponse 把 chunk 的 delta 列表编码为 SSE 响应体。
+func makeHTTPStreamResponse(finishReason string, deltas ...map[string]any) *http.Response {
+	var b strings.Builder
+	for i, delta := range deltas {
+		choice := map[string]any{"index": 0, "delta": delta}
+		if i == len(deltas)-1 {
+			choice["finish_reason"] = finishReason
+		}
+		data, _ := json.Marshal(map[string]any{
+			"id": "mock-stream", "object": "chat.completion.chunk", "created": 0, "model": "mock-model",
+			"choices": []any{choice},
+		})
+		fmt.Fprintf(&b, "data: %s\n\n", data)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(b.String())),
+	}
+}
+
+func toolCallFragment(index int, id, name, args string) map[string]any {
+	return map[string]any{"tool_calls": []any{map[string]any{
+		"index": index, "id": id, "type": "function",
+		"function": map[string]any{"name": name, "arguments": args},
+	}}}
+}
+
+// 参数分片到达：处理器看到命令逐步成形，拼好的 JSON 才分发给工具。
+func TestRun_AssemblesStreamedToolCalls(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStreamResponse("tool_calls",
+			toolCallFragment(0, "call_1", "bash", ""),
+			toolCallFragment(0, "", "bash", `{"command": "go te`),
+			toolCallFragment(0, "", "", `st ./...\"\n`),
+			toolCallFragment(0, "", "", `"}`),
+			toolCallFragment(2, "call_2", "bash", `{"command":"ls"}`),
+		),
+		makeHTTPStreamResponse("stop", map[string]any{"content": "done"}),
+	}}
+	registry := tools.New()
+	var dispatched []string
+	registry.Register(tools.BashToolDef(), func(_ context.Context, args map[string]any) (string, error) {
+		dispatched = append(dispatched, args["command"].(string))
+		return "ok", nil
+	})
+
+	var shown []string
+	ctx := WithToolCallHandler(context.Background(), func(d ToolCallDelta) {
+		if command, ok := PartialString(d.Arguments, "command"); ok && d.Index == 0 {
+			shown = append(shown, command)
+		}
+	})
+	_, err := Run(ctx, newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("run the tests")}, registry)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := strings.Join(shown, "|"); got != "go te|go test ./...\"\n|go test ./...\"\n" {
+		t.Fatalf("shown = %q", got)
+	}
+	if len(dispatched) != 2 || dispatched[0] != "go test ./...\"\n" || dispatched[1] != "ls" {
+		t.Fatalf("dispatched = %q", dispatched)
+	}
+	if !strings.Contains(string(mock.requestBodies[1]), `"tool_call_id":"call_2"`) {
+		t.Fatalf("second call lost after the index gap: %s", mock.requestBodies[1])
+	}
+}
+
+func TestRun_ReportsInvalidToolArgsToModel(t *testing.T) {
+	mock := &capturingMockHTTPClient{responses: []*http.Response{
+		makeHTTPStreamResponse("tool_calls", toolCallFragment(0, "call_1", "bash", `{"command": "ls`)),
+		makeHTTPStreamResponse("stop", map[string]any{"content": "retrying"}),
+	}}
+	registry := tools.New()
+	registry.Register(tools.BashToolDef(), func(context.Context, map[string]any) (string, error) {
+		t.Fatal("tool dispatched with invalid arguments")
+		return "", nil
+	})
+
+	ctx := WithToolCallHandler(context.Background(), func(ToolCallDelta) {})
+	if _, err := Run(ctx, newCapturingMockClient(mock), "mock-model",
+		[]openai.ChatCompletionMessageParamUnion{openai.UserMessage("list")}, registry); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if body := string(mock.requestBodies[1]); !strings.Contains(body, "invalid JSON arguments for bash") {
+		t.Fatalf("model was not told about the invalid arguments: %s", body)
+	}
+}
+
+func TestPartialString(t *testing.T) {
+	for _, tc := range []struct {
+		args, want string
+		ok         bool
+	}{
+		{``, "", false},
+		{`{"comm`, "", false},
+		{`{"command":`, "", false},
+		{`{"command": "`, "", true},
+		{`{"command": "echo \"hi`, `echo "hi`, true},
+		{`{"command": "a\u00`, "a", true},
+		{`{"command": "a\`, "a", true},
+		{`{"timeout": 5, "command": "ls"}`, "ls", true},
+		{`{"env": {"command": "nested"}, "command": "top`, "top", true},
+		{`{"path": "command"`, "", false},
+	} {
+		got, ok := PartialString(tc.args, "command")
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("PartialString(%q) = %q, %v; want %q, %v", tc.args, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// FUZZ-TOOL-ARGS: 任意参数字符串解析不 panic；成功时得到非 nil 的 map，失败时错误指明工具。
+func FuzzParseToolArgs(f *testing.F) {
+	for _, seed := range []string{
+		`{"command":"ls"}`, `{"command": "ec`, ``, `   `, `null`, `[]`, `"str"`, `{"a":1e999}`, `{"a":"\ud800"}`, `{{}`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		tc := openai.ChatCompletionMessageToolCall{ID: "call-1", Type: "function"}
+		tc.Function.Name = "bash"
+		tc.Function.Arguments = raw
+		args, err := parseToolArgs(tc)
+		if err != nil {
+			if !strings.Contains(err.Error(), "bash") {
+				t.Fatalf("error does not name the tool: %v", err)
+			}
+			return
+		}
+		if args == nil {
+			t.Fatalf("nil arguments for %q", raw)
+		}
+	})
+}
+
+// FUZZ-PARTIAL-STRING: 任意截断位置都不 panic，完整参数时取回原值。
+func FuzzPartialString(f *testing.F) {
+	f.Add("command", "go test ./...")
+	f.Add("path", `C:\tmp\"quoted"`)
+	f.Add("k", "emoji 🙂 and \u2028 and \x00")
+	f.Fuzz(func(t *testing.T, key, value string) {
+		if key == "other" || !utf8.ValidString(key) {
+			return
+		}
+		encoded, err := json.Marshal(map[string]any{"other": []any{key, map[string]string{key: "nested"}}, key: value})
+		if err != nil {
+			return
+		}
+		// Marshal replaces invalid UTF-8, so compare with what round-trips.
+		var decoded map[string]any
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("unmarshal %s: %v", encoded, err)
+		}
+		args := string(encoded)
+		for i := range args {
+			PartialString(args[:i], key)
+		}
+		got, ok := PartialString(args, key)
+		if want := decoded[key].(string); !ok || got != want {
+			t.Fatalf("PartialString(%s, %q) = %q, %v; want %q", args, key, got, ok, want)
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const shutdownTimeout = 3 * time.Second
+
+// Client lazily starts a language server for one workspace root and keeps
+// it running across tool calls. It is safe for concurrent use.
+type Client struct {
+	root    string
+	command string
+	args    []string
+
+	mu    sync.Mutex
+	conn  *Conn
+	cmd   *exec.Cmd
+	stdin io.Closer
+	// versions holds the document version last sent for each open URI.
+	versions map[string]int
+}
+
+// NewClient prepares a client; the server process starts on first use.
+func NewClient(root, command string, args ...string) (*Client, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("language server command is required")
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve workspace root: %w", err)
+	}
+	return &Client{root: absRoot, command: command, args: args, versions: make(map[string]int)}, nil
+}
+
+// NewGoplsClient returns a client for gopls.
+func NewGoplsClient(root string) (*Client, error) {
+	return NewClient(root, "gopls", "serve")
+}
+
+// Root returns the absolute workspace root.
+func (c *Client) Root() string {
+	return c.root
+}
+
+// Definition returns where the symbol at path:line:column is defined.
+// line and column are 1-based, column counted in characters.
+func (c *Client) Definition(ctx context.Context, path string, line, column int) ([]Location, error) {
+	params, err := c.positionParams(ctx, path, line, column)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	return decodeLocations(raw)
+}
+
+// References returns every use of the symbol at path:line:column, including its declaration.
+func (c *Client) References(ctx context.Context, path string, line, column int) ([]Location, error) {
+	pos, err := c.positionParams(ctx, path, line, column)
+	if err != nil {
+		return nil, err
+	}
+	params := referenceParams{textDocumentPositionParams: pos}
+	params.Context.IncludeDeclaration = true
+
+	var locations []Location
+	if err := c.conn.Call(ctx, "textDocument/references", params, &locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// Hover returns the type signature and documentation for the symbol at path:line:column.
+func (c *Client) Hover(ctx context.Context, path string, line, column int) (string, error) {
+	params, err := c.positionParams(ctx, path, line, column)
+	if err != nil {
+		return "", err
+	}
+
+	var result *hoverResult
+	if err := c.conn.Call(ctx, "textDocument/hover", params, &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return strings.TrimSpace(result.Contents.Value), nil
+}
+
+// Close shuts the language server down if it was started.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	_ = c.conn.Call(ctx, "shutdown", nil, nil)
+	_ = c.conn.Notify("exit", nil)
+	_ = c.stdin.Close()
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- c.cmd.Wait() }()
+	select {
+	case <-waitErr:
+	case <-ctx.Done():
+		_ = c.cmd.Process.Kill()
+		<-waitErr
+	}
+
+	c.conn = nil
+	c.cmd = nil
+	c.versions = make(map[string]int)
+	return nil
+}
+
+func (c *Client) positionParams(ctx context.Context, path string, line, column int) (textDocumentPositionParams, error) {
+	if line <= 0 || column <= 0 {
+		return textDocumentPositionParams{}, fmt.Errorf("line and column must be positive")
+	}
+
+	absPath := path
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(c.root, path)
+	}
+	absPath = filepath.Clean(absPath)
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return textDocumentPositionParams{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if line > len(lines) {
+		return textDocumentPositionParams{}, fmt.Errorf("line %d out of range (file has %d lines)", line, len(lines))
+	}
+
+	if err := c.ensureOpen(ctx, absPath, string(content)); err != nil {
+		return textDocumentPositionParams{}, err
+	}
+
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: PathToURI(absPath)},
+		Position: Position{
+			Line:      line - 1,
+			Character: UTF16Offset(lines[line-1], column),
+		},
+	}, nil
+}
+
+func (c *Client) ensureOpen(ctx context.Context, absPath, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.start(ctx); err != nil {
+			return err
+		}
+	}
+
+	uri := PathToURI(absPath)
+	if version, ok := c.versions[uri]; ok {
+		// 文件可能已被工具修改，发送全量 didChange 以保持服务器视图同步；版本号须逐次递增
+		if err := c.conn.Notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": version + 1},
+			"contentChanges": []map[string]any{{"text": content}},
+		}); err != nil {
+			return err
+		}
+		c.versions[uri] = version + 1
+		return nil
+	}
+	if err := c.conn.Notify("textDocument/didOpen", map[string]any{
+		"textDocument": textDocumentItem{URI: uri, LanguageID: languageID(absPath), Version: 1, Text: content},
+	}); err != nil {
+		return err
+	}
+	c.versions[uri] = 1
+	return nil
+}
+
+func (c *Client) start(ctx context.Context) error {
+	cmd := exec.Command(c.command, c.args...)
+	cmd.Dir = c.root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("language server stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("language server stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s: %w", c.command, err)
+	}
+
+	conn := NewConn(stdout, stdin)
+	if err := initialize(ctx, conn, c.root); err != nil {
+		_ = stdin.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+
+	c.conn = conn
+	c.cmd = cmd
+	c.stdin = stdin
+	return nil
+}
+
+func initialize(ctx context.Context, conn *Conn, root string) error {
+	rootURI := PathToURI(root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]any{
+			{"uri": rootURI, "name": filepath.Base(root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"hover": map[string]any{"contentFormat": []string{"plaintext", "markdown"}},
+			},
+		},
+	}
+	if err := conn.Call(ctx, "initialize", params, nil); err != nil {
+		return fmt.Errorf("initialize language server: %w", err)
+	}
+	if err := conn.Notify("initialized", map[string]any{}); err != nil {
+		return fmt.Errorf("initialized notification: %w", err)
+	}
+	return nil
+}
+
+// decodeLocations accepts the three shapes textDocument/definition may return:
+// null, a single Location, or an array of Location/LocationLink.
+func decodeLocations(raw json.RawMessage) ([]Location, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return []Location{}, nil
+	}
+
+	if strings.HasPrefix(trimmed, "{") {
+		var loc Location
+		if err := json.Unmarshal(raw, &loc); err != nil {
+			return nil, fmt.Errorf("decode location: %w", err)
+		}
+		return []Location{loc}, nil
+	}
+
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange *Range `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("decode locations: %w", err)
+	}
+	locations := make([]Location, 0, len(items))
+	for _, item := range items {
+		if item.TargetURI != "" && item.TargetSelectionRange != nil {
+			locations = append(locations, Location{URI: item.TargetURI, Range: *item.TargetSelectionRange})
+			continue
+		}
+		locations = append(locations, item.Location)
+	}
+	return locations, nil
+}
@@ -0,0 +1,209 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxMessageSize bounds the Content-Length accepted from the server, so a
+// corrupt or hostile header cannot make the client allocate gigabytes.
+const maxMessageSize = 64 << 20
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// Conn speaks JSON-RPC 2.0 with LSP's Content-Length framing.
+type Conn struct {
+	w      io.Writer
+	writeM sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan rpcMessage
+	closed  error
+	done    chan struct{}
+}
+
+// NewConn starts reading responses from r in the background.
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	c := &Conn{
+		w:       w,
+		pending: make(map[int64]chan rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Call sends a request and decodes the result into result (which may be nil).
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.closed != nil {
+		err := c.closed
+		c.mu.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan rpcMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(rpcMessage{ID: &id, Method: method}, params); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.closedErr()
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("decode %s result: %w", method, err)
+		}
+		return nil
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(rpcMessage{Method: method}, params)
+}
+
+func (c *Conn) send(msg rpcMessage, params any) error {
+	msg.JSONRPC = "2.0"
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("encode %s params: %w", msg.Method, err)
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) write(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	c.writeM.Lock()
+	defer c.writeM.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	if _, err := c.w.Write(body); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+	return nil
+}
+
+func (c *Conn) readLoop(r *bufio.Reader) {
+	err := func() error {
+		tp := textproto.NewReader(r)
+		for {
+			header, err := tp.ReadMIMEHeader()
+			if err != nil {
+				return err
+			}
+			length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+			if err != nil {
+				return fmt.Errorf("invalid Content-Length: %w", err)
+			}
+			if length <= 0 || length > maxMessageSize {
+				return fmt.Errorf("invalid Content-Length %d (must be 1-%d)", length, maxMessageSize)
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return err
+			}
+
+			var msg rpcMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return fmt.Errorf("decode message: %w", err)
+			}
+			c.dispatch(msg)
+		}
+	}()
+
+	c.mu.Lock()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	c.closed = fmt.Errorf("lsp connection closed: %w", err)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *Conn) dispatch(msg rpcMessage) {
+	switch {
+	case msg.ID != nil && msg.Method == "":
+		c.mu.Lock()
+		ch, ok := c.pending[*msg.ID]
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	case msg.ID != nil:
+		// 服务端发起的请求（如 workspace/configuration）必须应答，否则部分服务器会阻塞
+		_ = c.write(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: serverRequestResult(msg)})
+	}
+}
+
+func serverRequestResult(msg rpcMessage) json.RawMessage {
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			items := make([]any, len(params.Items))
+			raw, err := json.Marshal(items)
+			if err == nil {
+				return raw
+			}
+		}
+	}
+	return json.RawMessage("null")
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
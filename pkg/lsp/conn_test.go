@@ -0,0 +1,196 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConn_CallRoundTrip(t *testing.T) {
+	conn, server := newPipeConn(t)
+
+	go func() {
+		req := server.read(t)
+		if req.Method != "textDocument/hover" {
+			t.Errorf("method = %q, want textDocument/hover", req.Method)
+		}
+		server.write(t, rpcMessage{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"contents":{"kind":"plaintext","value":"func Run()"}}`)})
+	}()
+
+	var result hoverResult
+	if err := conn.Call(testContext(t), "textDocument/hover", map[string]any{}, &result); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if result.Contents.Value != "func Run()" {
+		t.Fatalf("hover = %q", result.Contents.Value)
+	}
+}
+
+func TestConn_CallReturnsServerError(t *testing.T) {
+	conn, server := newPipeConn(t)
+
+	go func() {
+		req := server.read(t)
+		server.write(t, rpcMessage{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32601, Message: "method not found"}})
+	}()
+
+	err := conn.Call(testContext(t), "custom/unknown", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "method not found") {
+		t.Fatalf("expected server error, got %v", err)
+	}
+}
+
+func TestConn_AnswersServerRequests(t *testing.T) {
+	conn, server := newPipeConn(t)
+
+	go func() {
+		req := server.read(t)
+		id := int64(99)
+		server.write(t, rpcMessage{
+			JSONRPC: "2.0",
+			ID:      &id,
+			Method:  "workspace/configuration",
+			Params:  json.RawMessage(`{"items":[{},{}]}`),
+		})
+		reply := server.read(t)
+		if reply.ID == nil || *reply.ID != 99 || string(reply.Result) != "[null,null]" {
+			t.Errorf("unexpected configuration reply: %+v result=%s", reply, reply.Result)
+		}
+		server.write(t, rpcMessage{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`null`)})
+	}()
+
+	if err := conn.Call(testContext(t), "initialize", map[string]any{}, nil); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+}
+
+func TestConn_CallFailsAfterServerExits(t *testing.T) {
+	conn, server := newPipeConn(t)
+	server.exit()
+
+	err := conn.Call(testContext(t), "initialize", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("expected closed error, got %v", err)
+	}
+}
+
+func TestConn_RejectsInvalidContentLength(t *testing.T) {
+	for _, length := range []string{"0", "-1", strconv.Itoa(maxMessageSize + 1)} {
+		conn, server := newPipeConn(t)
+		go func() {
+			server.read(t)
+			_, _ = io.WriteString(server.w, "Content-Length: "+length+"\r\n\r\n")
+		}()
+
+		err := conn.Call(testContext(t), "initialize", nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid Content-Length") {
+			t.Fatalf("Content-Length %s: expected invalid Content-Length, got %v", length, err)
+		}
+	}
+}
+
+func TestClient_SendsIncreasingDocumentVersions(t *testing.T) {
+	client, err := NewClient(t.TempDir(), "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, server := newPipeConn(t)
+	client.conn = conn
+
+	versions := make(chan int, 3)
+	go func() {
+		for range 3 {
+			var params struct {
+				TextDocument struct {
+					Version int `json:"version"`
+				} `json:"textDocument"`
+			}
+			_ = json.Unmarshal(server.read(t).Params, &params)
+			versions <- params.TextDocument.Version
+		}
+	}()
+	for _, content := range []string{"a", "b", "c"} {
+		if err := client.ensureOpen(testContext(t), "/w/main.go", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for want := 1; want <= 3; want++ {
+		if got := <-versions; got != want {
+			t.Fatalf("version = %d, want %d", got, want)
+		}
+	}
+}
+
+type fakeServer struct {
+	r      *bufio.Reader
+	w      io.WriteCloser
+	closeR func() error
+}
+
+func newPipeConn(t *testing.T) (*Conn, *fakeServer) {
+	t.Helper()
+
+	clientToServerR, clientToServerW := io.Pipe()
+	serverToClientR, serverToClientW := io.Pipe()
+	t.Cleanup(func() {
+		_ = clientToServerW.Close()
+		_ = serverToClientW.Close()
+	})
+
+	conn := NewConn(serverToClientR, clientToServerW)
+	return conn, &fakeServer{r: bufio.NewReader(clientToServerR), w: serverToClientW, closeR: clientToServerR.Close}
+}
+
+// exit simulates the server process dying: both pipe ends go away.
+func (s *fakeServer) exit() {
+	_ = s.closeR()
+	_ = s.w.Close()
+}
+
+func (s *fakeServer) read(t *testing.T) rpcMessage {
+	header, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		t.Errorf("read header: %v", err)
+		return rpcMessage{}
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		t.Errorf("parse length: %v", err)
+		return rpcMessage{}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		t.Errorf("read body: %v", err)
+		return rpcMessage{}
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Errorf("decode: %v", err)
+	}
+	return msg
+}
+
+func (s *fakeServer) write(t *testing.T, msg rpcMessage) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Errorf("encode: %v", err)
+		return
+	}
+	if _, err := io.WriteString(s.w, "Content-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+string(body)); err != nil {
+		t.Errorf("write: %v", err)
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
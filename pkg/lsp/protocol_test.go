@@ -0,0 +1,57 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUTF16Offset_CountsSurrogatePairs(t *testing.T) {
+	line := "s := \"😀\" + x"
+
+	// 列 10 指向 '+'（按字符计 1-based），emoji 在 UTF-16 中占两个单元
+	if got := UTF16Offset(line, 10); got != 10 {
+		t.Fatalf("UTF16Offset = %d, want 10", got)
+	}
+	if got := UTF16Offset(line, 1); got != 0 {
+		t.Fatalf("UTF16Offset(col 1) = %d, want 0", got)
+	}
+}
+
+func TestPathToURI_RoundTrip(t *testing.T) {
+	uri := PathToURI("/tmp/my project/main.go")
+	if uri != "file:///tmp/my%20project/main.go" {
+		t.Fatalf("uri = %q", uri)
+	}
+	path, err := URIToPath(uri)
+	if err != nil {
+		t.Fatalf("URIToPath: %v", err)
+	}
+	if path != "/tmp/my project/main.go" {
+		t.Fatalf("path = %q", path)
+	}
+}
+
+func TestDecodeLocations_AcceptsAllShapes(t *testing.T) {
+	cases := map[string]string{
+		"null":   `null`,
+		"single": `{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}`,
+		"array":  `[{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}]`,
+		"links":  `[{"targetUri":"file:///a.go","targetRange":{"start":{"line":0,"character":0},"end":{"line":9,"character":0}},"targetSelectionRange":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}]`,
+	}
+
+	for name, raw := range cases {
+		locations, err := decodeLocations(json.RawMessage(raw))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if name == "null" {
+			if len(locations) != 0 {
+				t.Fatalf("null: expected no locations, got %+v", locations)
+			}
+			continue
+		}
+		if len(locations) != 1 || locations[0].URI != "file:///a.go" || locations[0].Range.Start != (Position{Line: 1, Character: 2}) {
+			t.Fatalf("%s: unexpected locations %+v", name, locations)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package mcp connects to Model Context Protocol servers over stdio and
+// registers their tools on a tools.Registry next to the built-in ones.
+//
+// Servers are declared in the "mcp" section of .agent/config.json (see
+// config.MCP). A server tool named like a tool that is already registered
+// is handled by the configured tools.ConflictPolicy: by default it is
+// namespaced as <server>__<tool>, so the model never sees two tools with
+// the same name.
+//
+//	servers, err := mcp.Connect(ctx, cfg.MCP, root)
+//	defer servers.Close()
+//	names, err := servers.Register(ctx, registry, tools.ConflictPolicy(cfg.MCP.Conflicts), approver)
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+// ProtocolVersion is the MCP revision the client asks for.
+const ProtocolVersion = "2025-06-18"
+
+const (
+	startTimeout    = 30 * time.Second
+	shutdownTimeout = 3 * time.Second
+)
+
+// Client is a connection to one running server.
+type Client struct {
+	name  string
+	conn  *Conn
+	cmd   *exec.Cmd
+	stdin io.Closer
+}
+
+// Start runs server in dir and performs the initialize handshake.
+func Start(ctx context.Context, name string, server config.MCPServer, dir string) (*Client, error) {
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for key, value := range server.Env {
+		cmd.Env = append(cmd.Env, key+"="+os.ExpandEnv(value))
+	}
+	// Server logs would garble the REPL; MCP servers must not need stderr.
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s stdin: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s stdout: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server %s: %w", name, err)
+	}
+
+	c := &Client{name: name, conn: NewConn(stdout, stdin), cmd: cmd, stdin: stdin}
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	if err := c.initialize(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name returns the server's name from the config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "learn-claude-code", "version": "0"},
+	}
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := c.conn.Call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize mcp server %s: %w", c.name, err)
+	}
+	if err := c.conn.Notify("notifications/initialized", nil); err != nil {
+		return fmt.Errorf("mcp server %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// Tool is a tool offered by a server.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations struct {
+		// ReadOnlyHint marks tools that do not change anything; they run
+		// without approval.
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	} `json:"annotations"`
+}
+
+// ListTools returns every tool of the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.conn.Call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("list tools of mcp server %s: %w", c.name, err)
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// content is one item of a tool result.
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	Resource struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"resource"`
+}
+
+// CallTool calls the server's tool name and returns its result as text.
+// A result the server flags as an error is returned as an error.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	if err := c.conn.Call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", fmt.Errorf("mcp server %s: %w", c.name, err)
+	}
+	var parts []string
+	for _, item := range result.Content {
+		switch item.Type {
+		case "text":
+			parts = append(parts, item.Text)
+		case "resource":
+			if item.Resource.Text != "" {
+				parts = append(parts, item.Resource.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource %s]", item.Resource.URI))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s omitted]", item.Type, item.MimeType))
+		}
+	}
+	text := strings.TrimSpace(strings.Join(parts, "\n"))
+	if result.IsError {
+		return "", fmt.Errorf("%s: %s", name, text)
+	}
+	if text == "" {
+		text = "(no output)"
+	}
+	return text, nil
+}
+
+// Close stops the server: its stdin is closed, and it is killed if it
+// has not exited shortly after.
+func (c *Client) Close() error {
+	if c.cmd == nil {
+		return nil
+	}
+	_ = c.stdin.Close()
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- c.cmd.Wait() }()
+	select {
+	case <-waitErr:
+	case <-time.After(shutdownTimeout):
+		_ = c.cmd.Process.Kill()
+		<-waitErr
+	}
+	c.cmd = nil
+	return nil
+}
+
+// Servers are the running servers of a config.
+type Servers []*Client
+
+// Connect starts every server of cfg in name order. Servers that fail to
+// start are skipped and reported in the error, so one broken server does
+// not hide the others.
+func Connect(ctx context.Context, cfg config.MCP, dir string) (Servers, error) {
+	names := make([]string, 0, len(cfg.Servers))
+	for name := range cfg.Servers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var (
+		servers Servers
+		errs    []error
+	)
+	for _, name := range names {
+		c, err := Start(ctx, name, cfg.Servers[name], dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		servers = append(servers, c)
+	}
+	return servers, errors.Join(errs...)
+}
+
+// Close stops every server.
+func (s Servers) Close() {
+	for _, c := range s {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxMessageBytes bounds one line from the server.
+const maxMessageBytes = 16 << 20
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Conn speaks JSON-RPC 2.0 with MCP's stdio framing: one message per line.
+type Conn struct {
+	w      io.Writer
+	writeM sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan rpcMessage
+	closed  error
+	done    chan struct{}
+}
+
+// NewConn starts reading responses from r in the background.
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	c := &Conn{
+		w:       w,
+		pending: make(map[int64]chan rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+	return c
+}
+
+// Call sends a request and decodes the result into result (which may be nil).
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.closed != nil {
+		err := c.closed
+		c.mu.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan rpcMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(rpcMessage{ID: &id, Method: method}, params); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		// Tell the server to stop working on it; it may ignore this.
+		_ = c.Notify("notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	case <-c.done:
+		return c.closedErr()
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("decode %s result: %w", method, err)
+		}
+		return nil
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(rpcMessage{Method: method}, params)
+}
+
+func (c *Conn) send(msg rpcMessage, params any) error {
+	msg.JSONRPC = "2.0"
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("encode %s params: %w", msg.Method, err)
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) write(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	c.writeM.Lock()
+	defer c.writeM.Unlock()
+	if _, err := c.w.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return nil
+}
+
+func (c *Conn) readLoop(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageBytes)
+	err := func() error {
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			var msg rpcMessage
+			if err := json.Unmarshal(line, &msg); err != nil {
+				return fmt.Errorf("decode message: %w", err)
+			}
+			c.dispatch(msg)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return io.ErrUnexpectedEOF
+	}()
+
+	c.mu.Lock()
+	c.closed = fmt.Errorf("mcp connection closed: %w", err)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *Conn) dispatch(msg rpcMessage) {
+	switch {
+	case msg.ID != nil && msg.Method == "":
+		c.mu.Lock()
+		ch, ok := c.pending[*msg.ID]
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	case msg.ID != nil && msg.Method == "ping":
+		_ = c.write(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+	case msg.ID != nil:
+		// The client declares no capabilities, so sampling, roots and
+		// elicitation requests are refused rather than left hanging.
+		_ = c.write(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: -32601, Message: "method not found: " + msg.Method}})
+	}
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+// fakeServerEnv makes the test binary act as an MCP server, see TestMain.
+const fakeServerEnv = "MCP_FAKE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		serveFake()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serveFake answers on stdin and stdout like a small MCP server. Its
+// tools come in two pages; read_file collides with the built-in tool.
+func serveFake() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req rpcMessage
+		if json.Unmarshal(scanner.Bytes(), &req) != nil || req.ID == nil {
+			continue
+		}
+		var params struct {
+			Cursor    string         `json:"cursor"`
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}, "serverInfo": map[string]any{"name": "fake"}}
+		case "tools/list":
+			if params.Cursor == "" {
+				result = map[string]any{"nextCursor": "2", "tools": []map[string]any{
+					{"name": "read_file", "description": "Read a remote file.", "inputSchema": map[string]any{"type": "object"}},
+					{"name": "search", "annotations": map[string]any{"readOnlyHint": true}},
+				}}
+			} else {
+				result = map[string]any{"tools": []map[string]any{{"name": "deploy"}, {"name": "bad.name"}}}
+			}
+		case "tools/call":
+			if params.Name == "deploy" && params.Arguments["env"] == "prod" {
+				result = map[string]any{"isError": true, "content": []map[string]any{{"type": "text", "text": "prod is frozen"}}}
+				break
+			}
+			result = map[string]any{"content": []map[string]any{
+				{"type": "text", "text": fmt.Sprintf("%s %v", params.Name, params.Arguments["q"])},
+				{"type": "image", "mimeType": "image/png", "data": "AAAA"},
+			}}
+		}
+		raw, _ := json.Marshal(result)
+		reply, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: req.ID, Result: raw})
+		fmt.Println(string(reply))
+	}
+}
+
+func connectFake(t *testing.T, names ...string) Servers {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.MCP{Servers: map[string]config.MCPServer{}}
+	for _, name := range names {
+		cfg.Servers[name] = config.MCPServer{Command: exe, Env: map[string]string{fakeServerEnv: "1"}}
+	}
+	servers, err := Connect(context.Background(), cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(servers.Close)
+	return servers
+}
+
+func builtinRegistry() *tools.Registry {
+	r := tools.New()
+	r.Register(tools.ReadFileToolDef(), func(context.Context, map[string]any) (string, error) { return "builtin", nil })
+	return r
+}
+
+func TestServers_RegisterNamespacesConflictingTools(t *testing.T) {
+	servers := connectFake(t, "fake", "ci")
+	r := builtinRegistry()
+	names, err := servers.Register(context.Background(), r, tools.ConflictNamespace, permission.DenyAll)
+	if err == nil || !strings.Contains(err.Error(), `"bad.name"`) {
+		t.Fatalf("Register error = %v, want the invalid name reported", err)
+	}
+	// ci starts first (name order) and takes search and deploy.
+	if got := strings.Join(names, ","); got != "ci__read_file,search,deploy,fake__read_file,fake__search,fake__deploy" {
+		t.Fatalf("names = %s", got)
+	}
+	seen := map[string]bool{}
+	for _, def := range r.Definitions() {
+		if seen[def.Function.Name] {
+			t.Fatalf("duplicate definition %s", def.Function.Name)
+		}
+		seen[def.Function.Name] = true
+	}
+
+	ctx := context.Background()
+	if out, err := r.Dispatch(ctx, "read_file", nil); err != nil || out != "builtin" {
+		t.Fatalf("read_file = %q, %v", out, err)
+	}
+	if out, err := r.Dispatch(ctx, "fake__search", map[string]any{"q": "flaky"}); err != nil || !strings.HasPrefix(out, "search flaky") || !strings.Contains(out, "[image image/png omitted]") {
+		t.Fatalf("read-only tool = %q, %v", out, err)
+	}
+	if out, err := r.Dispatch(ctx, "fake__deploy", nil); err != nil || !strings.Contains(out, "declined to run fake__deploy") {
+		t.Fatalf("unapproved tool = %q, %v", out, err)
+	}
+}
+
+func TestServers_RegisterSkipsOrRejectsConflicts(t *testing.T) {
+	servers := connectFake(t, "fake")
+	r := builtinRegistry()
+	names, _ := servers.Register(context.Background(), r, tools.ConflictSkip, permission.AllowAll)
+	if got := strings.Join(names, ","); got != "search,deploy" {
+		t.Fatalf("names = %s", got)
+	}
+	if _, err := r.Dispatch(context.Background(), "deploy", map[string]any{"env": "prod"}); err == nil || !strings.Contains(err.Error(), "prod is frozen") {
+		t.Fatalf("tool error: %v", err)
+	}
+
+	if _, err := servers.Register(context.Background(), builtinRegistry(), tools.ConflictError, permission.AllowAll); !errors.Is(err, tools.ErrConflict) || !strings.Contains(err.Error(), `"read_file" already exists`) {
+		t.Fatalf("error policy: %v", err)
+	}
+}
+
+func TestConnect_ReportsServersThatFailToStart(t *testing.T) {
+	servers, err := Connect(context.Background(), config.MCP{Servers: map[string]config.MCPServer{
+		"missing": {Command: "/nonexistent/mcp-server"},
+	}}, t.TempDir())
+	defer servers.Close()
+	if len(servers) != 0 || err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("Connect = %v, %v", servers, err)
+	}
+}
+
+func TestTool_ToolDefKeepsSchemaAndNamesTheServer(t *testing.T) {
+	def := Tool{Name: "search", InputSchema: map[string]any{"type": "object", "required": []any{"q"}}}.ToolDef("github")
+	if def.Function.Name != "search" || def.Function.Parameters["required"] == nil {
+		t.Fatalf("def = %+v", def.Function)
+	}
+	if got := def.Function.Description.Value; got != "Tool search of MCP server github." {
+		t.Fatalf("description = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const maxOutputTokens = 12000
+
+// toolNamePattern is what the API accepts as a function name.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ToolDef returns the definition the model sees, under the tool's own name.
+func (t Tool) ToolDef(server string) openai.ChatCompletionToolParam {
+	description := fmt.Sprintf("%s (MCP server %s)", strings.TrimSpace(t.Description), server)
+	if strings.TrimSpace(t.Description) == "" {
+		description = fmt.Sprintf("Tool %s of MCP server %s.", t.Name, server)
+	}
+	parameters := t.InputSchema
+	if parameters == nil {
+		parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        t.Name,
+			Description: openai.String(description),
+			Parameters:  openai.FunctionParameters(parameters),
+		},
+	}
+}
+
+// Handler calls tool on c. Tools not annotated read-only run only when
+// approver allows them; a nil approver denies them. name is the tool's
+// registered name, which approval requests and allow rules use.
+func Handler(c *Client, tool Tool, name string, approver permission.Approver) tools.Handler {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if !tool.Annotations.ReadOnlyHint {
+			input, err := json.Marshal(args)
+			if err != nil {
+				return "", err
+			}
+			ok, err := approver.Approve(ctx, permission.Request{
+				Tool:    name,
+				Summary: fmt.Sprintf("call %s on MCP server %s", tool.Name, c.Name()),
+				Detail:  string(input),
+			})
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "Error: the user declined to run " + name, nil
+			}
+		}
+		output, err := c.CallTool(ctx, tool.Name, args)
+		if err != nil {
+			return "", err
+		}
+		return tokens.Truncate(tokens.Default(), output, maxOutputTokens), nil
+	}
+}
+
+// Register registers the tools of every server on r, resolving name
+// conflicts with policy, and returns the names the model sees. A server
+// whose tools cannot be listed or registered is reported in the error and
+// the others still register.
+func (s Servers) Register(ctx context.Context, r *tools.Registry, policy tools.ConflictPolicy, approver permission.Approver) ([]string, error) {
+	var (
+		names []string
+		errs  []error
+	)
+	for _, c := range s {
+		list, err := c.ListTools(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, tool := range list {
+			if !toolNamePattern.MatchString(tool.Name) {
+				errs = append(errs, fmt.Errorf("mcp server %s: tool name %q is not a valid function name", c.Name(), tool.Name))
+				continue
+			}
+			name, err := r.ResolveName(c.Name(), tool.Name, policy)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("mcp server %s: %w", c.Name(), err))
+				continue
+			}
+			if name == "" {
+				continue
+			}
+			def := tool.ToolDef(c.Name())
+			def.Function.Name = name
+			r.Register(def, Handler(c, tool, name, approver))
+			names = append(names, name)
+		}
+	}
+	return names, errors.Join(errs...)
+}
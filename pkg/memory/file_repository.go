@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const memoryFileName = "memories.jsonl"
+
+// DefaultDir is where memories are kept, relative to the project root.
+const DefaultDir = ".memory"
+
+// FileRepository stores memories as one JSON object per line so that appends
+// never rewrite earlier entries.
+type FileRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileRepository(dir string) (*FileRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create memory dir: %w", err)
+	}
+	return &FileRepository{path: filepath.Join(dir, memoryFileName)}, nil
+}
+
+func (r *FileRepository) Append(entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal memory: %w", err)
+	}
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open memory file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("append memory: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRepository) List() ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, fmt.Errorf("open memory file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// embedding 向量序列化后单行可能远超默认 64KB
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	entries := make([]Entry, 0)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("decode memory: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan memory file: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileRepository_AppendThenListPreservesOrder(t *testing.T) {
+	repo := newTempFileRepository(t)
+
+	for _, entry := range []Entry{
+		{ID: "mem-1", Content: "first", CreatedAt: time.Now().UTC()},
+		{ID: "mem-2", Content: "second", Tags: []string{"build"}, CreatedAt: time.Now().UTC()},
+	} {
+		if err := repo.Append(entry); err != nil {
+			t.Fatalf("Append(%s): %v", entry.ID, err)
+		}
+	}
+
+	entries, err := repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[0].ID != "mem-1" || entries[1].ID != "mem-2" {
+		t.Fatalf("unexpected order: %+v", entries)
+	}
+	if len(entries[1].Tags) != 1 || entries[1].Tags[0] != "build" {
+		t.Fatalf("tags not persisted: %+v", entries[1])
+	}
+}
+
+func TestFileRepository_ListWithoutFileReturnsEmpty(t *testing.T) {
+	repo := newTempFileRepository(t)
+
+	entries, err := repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries, got %d", len(entries))
+	}
+}
+
+func TestFileRepository_AppendRejectsInvalidEntry(t *testing.T) {
+	repo := newTempFileRepository(t)
+
+	if err := repo.Append(Entry{ID: "mem-1", Content: "  "}); err == nil {
+		t.Fatal("expected content validation error")
+	}
+}
+
+func newTempFileRepository(t *testing.T) *FileRepository {
+	t.Helper()
+
+	repo, err := NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	return repo
+}
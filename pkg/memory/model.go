@@ -0,0 +1,33 @@
+// Package memory persists project facts across agent sessions and retrieves
+// the most relevant ones for a query.
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Entry struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+func (e Entry) Validate() error {
+	if strings.TrimSpace(e.ID) == "" {
+		return fmt.Errorf("memory id is required")
+	}
+	if strings.TrimSpace(e.Content) == "" {
+		return fmt.Errorf("memory content is required")
+	}
+	return nil
+}
+
+// Result is a single search hit ordered by descending Score.
+type Result struct {
+	Entry Entry   `json:"entry"`
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,6 @@
+package memory
+
+type Repository interface {
+	Append(entry Entry) error
+	List() ([]Entry, error)
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const defaultSearchLimit = 5
+
+// Embedder turns texts into vectors. It is optional: without one, search falls
+// back to keyword overlap.
+type Embedder interface {
+	Embeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type Service struct {
+	repo     Repository
+	embedder Embedder
+	nextID   atomic.Uint64
+}
+
+func NewService(repo Repository, embedder Embedder) *Service {
+	return &Service{repo: repo, embedder: embedder}
+}
+
+func (s *Service) Remember(ctx context.Context, content string, tags []string) (Entry, error) {
+	entry := Entry{
+		ID:        s.newID(),
+		Content:   strings.TrimSpace(content),
+		Tags:      normalizeTags(tags),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := entry.Validate(); err != nil {
+		return Entry{}, err
+	}
+
+	if s.embedder != nil {
+		vectors, err := s.embedder.Embeddings(ctx, []string{embeddingText(entry)})
+		if err != nil {
+			return Entry{}, fmt.Errorf("embed memory: %w", err)
+		}
+		if len(vectors) != 1 {
+			return Entry{}, fmt.Errorf("embed memory: expected 1 vector, got %d", len(vectors))
+		}
+		entry.Embedding = vectors[0]
+	}
+
+	if err := s.repo.Append(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Search returns at most limit memories ranked by relevance to query. Entries
+// with a zero score are omitted.
+func (s *Service) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	entries, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var queryVector []float32
+	if s.embedder != nil && hasEmbeddings(entries) {
+		vectors, err := s.embedder.Embeddings(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		if len(vectors) == 1 {
+			queryVector = vectors[0]
+		}
+	}
+
+	queryTerms := tokenize(query)
+	results := make([]Result, 0, len(entries))
+	for _, entry := range entries {
+		var score float64
+		if queryVector != nil && len(entry.Embedding) == len(queryVector) {
+			score = cosine(queryVector, entry.Embedding)
+		} else {
+			score = keywordScore(queryTerms, entry)
+		}
+		if score <= 0 {
+			continue
+		}
+		results = append(results, Result{Entry: entry, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.CreatedAt.After(results[j].Entry.CreatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (s *Service) newID() string {
+	id := s.nextID.Add(1)
+	return fmt.Sprintf("mem-%d-%d", time.Now().UTC().UnixNano(), id)
+}
+
+func embeddingText(entry Entry) string {
+	if len(entry.Tags) == 0 {
+		return entry.Content
+	}
+	return entry.Content + "\ntags: " + strings.Join(entry.Tags, ", ")
+}
+
+func hasEmbeddings(entries []Entry) bool {
+	for _, entry := range entries {
+		if len(entry.Embedding) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		out = append(out, tag)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// keywordScore 返回 query 词项在条目正文与标签中的命中比例，标签命中权重加倍。
+func keywordScore(queryTerms []string, entry Entry) float64 {
+	if len(queryTerms) == 0 {
+		return 0
+	}
+
+	contentTerms := make(map[string]struct{})
+	for _, term := range tokenize(entry.Content) {
+		contentTerms[term] = struct{}{}
+	}
+	tagTerms := make(map[string]struct{})
+	for _, tag := range entry.Tags {
+		for _, term := range tokenize(tag) {
+			tagTerms[term] = struct{}{}
+		}
+	}
+
+	var hits float64
+	for _, term := range queryTerms {
+		if _, ok := tagTerms[term]; ok {
+			hits += 2
+			continue
+		}
+		if _, ok := contentTerms[term]; ok {
+			hits++
+		}
+	}
+	return hits / float64(2*len(queryTerms))
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]struct{}, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := seen[field]; ok {
+			continue
+		}
+		seen[field] = struct{}{}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestService_SearchRanksKeywordMatches(t *testing.T) {
+	svc := NewService(newTempFileRepository(t), nil)
+	ctx := context.Background()
+
+	mustRemember(t, svc, "tests run with go test ./... from the repo root", "testing")
+	mustRemember(t, svc, "the devtools viewer listens on port 4983")
+	mustRemember(t, svc, "prefer table driven tests in pkg/tools")
+
+	results, err := svc.Search(ctx, "how do I run tests", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2: %+v", len(results), results)
+	}
+	if !strings.Contains(results[0].Entry.Content, "go test") {
+		t.Fatalf("unexpected top result: %+v", results[0])
+	}
+}
+
+func TestService_SearchTagMatchOutranksContentMatch(t *testing.T) {
+	svc := NewService(newTempFileRepository(t), nil)
+
+	mustRemember(t, svc, "viewer port is configurable")
+	mustRemember(t, svc, "AI_SDK_DEVTOOLS_PORT controls it", "port")
+
+	results, err := svc.Search(context.Background(), "port", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+	if results[0].Entry.Tags[0] != "port" {
+		t.Fatalf("expected tagged entry first, got %+v", results[0])
+	}
+}
+
+func TestService_SearchUsesEmbeddingsWhenAvailable(t *testing.T) {
+	embedder := fakeEmbedder{
+		"uses sqlite for storage": {1, 0},
+		"deploys to kubernetes":   {0, 1},
+		"database":                {0.9, 0.1},
+	}
+	svc := NewService(newTempFileRepository(t), embedder)
+
+	mustRemember(t, svc, "uses sqlite for storage")
+	mustRemember(t, svc, "deploys to kubernetes")
+
+	results, err := svc.Search(context.Background(), "database", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Entry.Content != "uses sqlite for storage" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestService_RememberRequiresContent(t *testing.T) {
+	svc := NewService(newTempFileRepository(t), nil)
+
+	if _, err := svc.Remember(context.Background(), "   ", nil); err == nil {
+		t.Fatal("expected content validation error")
+	}
+}
+
+func TestService_RememberNormalizesTags(t *testing.T) {
+	svc := NewService(newTempFileRepository(t), nil)
+
+	entry := mustRemember(t, svc, "fact", " Build ", "build", "", "CI")
+	if strings.Join(entry.Tags, ",") != "build,ci" {
+		t.Fatalf("tags = %v, want [build ci]", entry.Tags)
+	}
+}
+
+type fakeEmbedder map[string][]float32
+
+func (f fakeEmbedder) Embeddings(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		out = append(out, f[text])
+	}
+	return out, nil
+}
+
+func mustRemember(t *testing.T, svc *Service, content string, tags ...string) Entry {
+	t.Helper()
+
+	entry, err := svc.Remember(context.Background(), content, tags)
+	if err != nil {
+		t.Fatalf("Remember(%q): %v", content, err)
+	}
+	return entry
+}
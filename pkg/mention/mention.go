@@ -0,0 +1,166 @@
+// Package mention expands @path/to/file references in user input into fenced
+// file contents, so users can show the agent a file without asking it to cat
+// the file.
+package mention
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxFileBytes  = 100 << 10
+	defaultMaxTotalBytes = 400 << 10
+	binarySniffBytes     = 8000
+)
+
+// mentionPattern matches @path at the start of the input or after whitespace,
+// so e-mail addresses are left alone.
+var mentionPattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// Options limits what Expand includes.
+type Options struct {
+	// Root is the project directory; mentions resolve against it and may not
+	// escape it.
+	Root string
+	// MaxFileBytes truncates each file (default 100 KiB).
+	MaxFileBytes int
+	// MaxTotalBytes caps all included content (default 400 KiB); later files
+	// are skipped.
+	MaxTotalBytes int
+}
+
+// Inclusion reports what happened to one mention.
+type Inclusion struct {
+	Path      string
+	Bytes     int
+	Truncated bool
+	// Skipped explains why the file was not included; empty when it was.
+	Skipped string
+}
+
+// Expand appends the contents of every @-mentioned file to input. Mentions
+// that do not name an existing path are left as plain text and not reported.
+func Expand(input string, opts Options) (string, []Inclusion) {
+	if opts.MaxFileBytes <= 0 {
+		opts.MaxFileBytes = defaultMaxFileBytes
+	}
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = defaultMaxTotalBytes
+	}
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return input, nil
+	}
+
+	var (
+		blocks     []string
+		inclusions []Inclusion
+		seen       = map[string]bool{}
+		total      int
+	)
+	for _, match := range mentionPattern.FindAllStringSubmatch(input, -1) {
+		rel, path, ok := resolve(root, match[2])
+		if !ok || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+
+		inc := Inclusion{Path: rel}
+		data, err := os.ReadFile(path)
+		switch {
+		case err != nil:
+			inc.Skipped = err.Error()
+		case isBinary(data):
+			inc.Skipped = "binary file"
+		case total >= opts.MaxTotalBytes:
+			inc.Skipped = fmt.Sprintf("total mention limit of %d bytes reached", opts.MaxTotalBytes)
+		}
+		if inc.Skipped != "" {
+			inclusions = append(inclusions, inc)
+			blocks = append(blocks, fmt.Sprintf("[@%s not included: %s]", rel, inc.Skipped))
+			continue
+		}
+
+		limit := min(opts.MaxFileBytes, opts.MaxTotalBytes-total)
+		if len(data) > limit {
+			data = trimToRune(data[:limit])
+			inc.Truncated = true
+		}
+		inc.Bytes = len(data)
+		total += len(data)
+		inclusions = append(inclusions, inc)
+		blocks = append(blocks, formatBlock(rel, data, inc.Truncated))
+	}
+
+	if len(blocks) == 0 {
+		return input, inclusions
+	}
+	return input + "\n\n" + strings.Join(blocks, "\n\n"), inclusions
+}
+
+// resolve maps a mention to a regular file under root. Trailing punctuation
+// ("see @main.go.") is dropped when the literal path does not exist.
+func resolve(root, mention string) (rel, path string, ok bool) {
+	candidates := []string{mention}
+	if trimmed := strings.TrimRight(mention, ".,;:!?)]}'\""); trimmed != mention && trimmed != "" {
+		candidates = append(candidates, trimmed)
+	}
+	for _, candidate := range candidates {
+		path = filepath.Join(root, filepath.FromSlash(candidate))
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		return filepath.ToSlash(rel), path, true
+	}
+	return "", "", false
+}
+
+func isBinary(data []byte) bool {
+	sniff := data[:min(len(data), binarySniffBytes)]
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(trimToRune(sniff))
+}
+
+// trimToRune drops a trailing partial UTF-8 sequence.
+func trimToRune(data []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+			return data
+		}
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+func formatBlock(rel string, data []byte, truncated bool) string {
+	// The fence must be longer than any backtick run in the file.
+	fence := "```"
+	for strings.Contains(string(data), fence) {
+		fence += "`"
+	}
+	lang := strings.TrimPrefix(filepath.Ext(rel), ".")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s:\n%s%s\n%s", rel, fence, lang, data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	b.WriteString(fence)
+	if truncated {
+		fmt.Fprintf(&b, "\n[@%s truncated to %d bytes]", rel, len(data))
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package mention
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, root, rel string, data []byte) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpand_IncludesFencedContents(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "pkg/a.go", []byte("package a\n"))
+
+	got, incs := Expand("explain @pkg/a.go.", Options{Root: root})
+
+	want := "explain @pkg/a.go.\n\n@pkg/a.go:\n```go\npackage a\n```"
+	if got != want {
+		t.Fatalf("Expand() =\n%q\nwant\n%q", got, want)
+	}
+	if len(incs) != 1 || incs[0].Path != "pkg/a.go" || incs[0].Bytes != 10 || incs[0].Skipped != "" {
+		t.Fatalf("inclusions = %+v", incs)
+	}
+}
+
+func TestExpand_LeavesUnknownMentionsAndEmails(t *testing.T) {
+	root := t.TempDir()
+	input := "mail me@example.com about @missing.go"
+
+	got, incs := Expand(input, Options{Root: root})
+	if got != input || len(incs) != 0 {
+		t.Fatalf("Expand() = %q, %+v; want input unchanged", got, incs)
+	}
+}
+
+func TestExpand_RejectsPathsOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	writeFile(t, parent, "secret.txt", []byte("secret"))
+	writeFile(t, root, "ok.txt", []byte("ok"))
+
+	got, incs := Expand("@../secret.txt", Options{Root: root})
+	if strings.Contains(got, "secret\n") || len(incs) != 0 {
+		t.Fatalf("Expand() included a file outside root: %q %+v", got, incs)
+	}
+}
+
+func TestExpand_SkipsBinaryFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "blob.bin", []byte{0x7f, 'E', 'L', 'F', 0, 1})
+
+	got, incs := Expand("@blob.bin", Options{Root: root})
+	if len(incs) != 1 || incs[0].Skipped != "binary file" {
+		t.Fatalf("inclusions = %+v", incs)
+	}
+	if !strings.Contains(got, "[@blob.bin not included: binary file]") {
+		t.Fatalf("Expand() = %q", got)
+	}
+}
+
+func TestExpand_EnforcesSizeLimits(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "big.txt", []byte(strings.Repeat("x", 50)))
+	writeFile(t, root, "next.txt", []byte("next"))
+
+	got, incs := Expand("@big.txt @next.txt", Options{Root: root, MaxFileBytes: 20, MaxTotalBytes: 20})
+	if len(incs) != 2 {
+		t.Fatalf("inclusions = %+v", incs)
+	}
+	if !incs[0].Truncated || incs[0].Bytes != 20 {
+		t.Fatalf("big.txt inclusion = %+v, want truncated to 20 bytes", incs[0])
+	}
+	if incs[1].Skipped == "" {
+		t.Fatalf("next.txt inclusion = %+v, want skipped over total limit", incs[1])
+	}
+	if !strings.Contains(got, "[@big.txt truncated to 20 bytes]") {
+		t.Fatalf("Expand() = %q", got)
+	}
+}
+
+func TestExpand_FenceOutlastsBackticks(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "doc.md", []byte("```go\nx\n```\n"))
+
+	got, _ := Expand("@doc.md", Options{Root: root})
+	if !strings.Contains(got, "````md\n```go\nx\n```\n````") {
+		t.Fatalf("Expand() = %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+)
+
+// tokenRateBuckets suit generation speed in tokens per second.
+var tokenRateBuckets = []float64{1, 5, 10, 20, 40, 60, 80, 100, 150, 200, 400}
+
+// Agent records LLM and tool metrics for an agent process.
+type Agent struct {
+	llmDuration    *Histogram
+	llmFirstToken  *Histogram
+	llmErrors      *Counter
+	llmTokens      *Counter
+	llmTokenRate   *Histogram
+	toolDuration   *Histogram
+	toolErrors     *Counter
+	toolExitFailed *Counter
+}
+
+// NewAgent registers the agent metrics on r.
+func NewAgent(r *Registry) *Agent {
+	return &Agent{
+		llmDuration:    r.Histogram("agent_llm_request_duration_seconds", "Latency of LLM calls.", nil, "model", "mode"),
+		llmFirstToken:  r.Histogram("agent_llm_time_to_first_token_seconds", "Time until a streaming LLM call yields its first chunk.", nil, "model"),
+		llmErrors:      r.Counter("agent_llm_errors_total", "LLM calls that failed.", "model", "mode"),
+		llmTokens:      r.Counter("agent_llm_tokens_total", "Tokens reported by the provider.", "model", "type"),
+		llmTokenRate:   r.Histogram("agent_llm_tokens_per_second", "Completion tokens per second of successful LLM calls.", tokenRateBuckets, "model"),
+		toolDuration:   r.Histogram("agent_tool_duration_seconds", "Duration of tool calls.", nil, "tool"),
+		toolErrors:     r.Counter("agent_tool_errors_total", "Tool calls whose handler returned an error.", "tool"),
+		toolExitFailed: r.Counter("agent_tool_nonzero_exits_total", "Commands run by tools that exited non-zero.", "tool"),
+	}
+}
+
+// Interceptor measures every LLM call made through llm.Complete and
+// llm.Stream. Streaming calls are measured when the stream is closed; their
+// tokens are counted only when the provider reports usage. Latencies carry
+// the trace IDs of the call as exemplars.
+func (a *Agent) Interceptor() llm.Interceptor {
+	return llm.Interceptor{
+		Complete: func(next llm.CompleteFunc) llm.CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				start := time.Now()
+				resp, err := next(ctx, params)
+				var usage openai.CompletionUsage
+				if resp != nil {
+					usage = resp.Usage
+				}
+				a.observeLLM(trace.FromContext(ctx), params.Model, "complete", time.Since(start), usage, err)
+				return resp, err
+			}
+		},
+		Stream: func(next llm.StreamFunc) llm.StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) llm.ChunkStream {
+				return &measuredStream{ChunkStream: next(ctx, params), agent: a, ids: trace.FromContext(ctx), model: params.Model, start: time.Now()}
+			}
+		},
+	}
+}
+
+func (a *Agent) observeLLM(ids trace.IDs, model, mode string, elapsed time.Duration, usage openai.CompletionUsage, err error) {
+	a.llmDuration.ObserveWithExemplar(elapsed.Seconds(), exemplarOf(ids), model, mode)
+	if err != nil {
+		a.llmErrors.Inc(model, mode)
+		return
+	}
+	a.llmTokens.Add(float64(usage.PromptTokens), model, "prompt")
+	a.llmTokens.Add(float64(usage.CompletionTokens), model, "completion")
+	if usage.CompletionTokens > 0 && elapsed > 0 {
+		a.llmTokenRate.Observe(float64(usage.CompletionTokens)/elapsed.Seconds(), model)
+	}
+}
+
+type measuredStream struct {
+	llm.ChunkStream
+	agent   *Agent
+	ids     trace.IDs
+	model   string
+	start   time.Time
+	chunks  int
+	usage   openai.CompletionUsage
+	decided bool
+}
+
+func (s *measuredStream) Next() bool {
+	if !s.ChunkStream.Next() {
+		return false
+	}
+	if s.chunks == 0 {
+		s.agent.llmFirstToken.Observe(time.Since(s.start).Seconds(), s.model)
+	}
+	s.chunks++
+	if chunk := s.ChunkStream.Current(); chunk.Usage.TotalTokens > 0 {
+		s.usage = chunk.Usage
+	}
+	return true
+}
+
+func (s *measuredStream) Close() error {
+	if !s.decided {
+		s.decided = true
+		s.agent.observeLLM(s.ids, s.model, "stream", time.Since(s.start), s.usage, s.ChunkStream.Err())
+	}
+	return s.ChunkStream.Close()
+}
+
+// Middleware measures tool calls dispatched through a tools.Registry.
+func (a *Agent) Middleware() tools.Middleware {
+	return func(name string, next tools.Handler) tools.Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, exitStatus := tools.WithExitStatus(ctx)
+			start := time.Now()
+			output, err := next(ctx, args)
+			a.toolDuration.ObserveWithExemplar(time.Since(start).Seconds(), exemplarOf(trace.FromContext(ctx)), name)
+			if err != nil {
+				a.toolErrors.Inc(name)
+			}
+			if code, ok := exitStatus(); ok && code != 0 {
+				a.toolExitFailed.Inc(name)
+			}
+			return output, err
+		}
+	}
+}
+
+func exemplarOf(ids trace.IDs) map[string]string {
+	return map[string]string{"run_id": ids.RunID, "span_id": ids.SpanID}
+}
@@ -0,0 +1,310 @@
+// Package metrics is a small in-process metrics registry — counters and
+// histograms with labels — exported in the Prometheus text format, so server
+// mode and long-running agents can be scraped without extra dependencies.
+// Scrapers asking for OpenMetrics also get the exemplars of histogram
+// buckets, which link a bucket to the run that last landed in it.
+//
+//	reg := metrics.NewRegistry()
+//	agentMetrics := metrics.NewAgent(reg)
+//	ctx = llm.WithInterceptors(ctx, agentMetrics.Interceptor())
+//	registry = registry.WithMiddleware(agentMetrics.Middleware())
+//	mux.Handle("GET /metrics", reg.Handler())
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets suit latencies in seconds, from 5ms to 2 minutes.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Registry holds metric families in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]*family)}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string // "counter" or "histogram"
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64 // counter value
+	counts      []uint64
+	count       uint64
+	sum         float64
+	// exemplars holds the latest exemplar of each bucket, +Inf last.
+	exemplars []*exemplar
+}
+
+type exemplar struct {
+	labels map[string]string
+	value  float64
+	time   time.Time
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct{ f *family }
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct{ f *family }
+
+// Counter registers (or returns the existing) counter called name.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels, nil)}
+}
+
+// Histogram registers (or returns the existing) histogram called name;
+// nil buckets use DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{r.register(name, help, "histogram", labels, sorted)}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.byName[name]; ok {
+		if f.kind != kind || len(f.labels) != len(labels) {
+			panic(fmt.Sprintf("metrics: %s re-registered with a different type or labels", name))
+		}
+		return f
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	r.byName[name] = f
+	return f
+}
+
+// Add increases the counter for the given label values by v (v < 0 is ignored).
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Observe records one value for the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.ObserveWithExemplar(v, nil, labelValues...)
+}
+
+// ObserveWithExemplar records one value and keeps exemplarLabels (such as a
+// trace ID) as the exemplar of the bucket it falls in. Empty values are
+// dropped; without any labels it is Observe.
+func (h *Histogram) ObserveWithExemplar(v float64, exemplarLabels map[string]string, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	bucket := len(h.f.buckets)
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.counts[i]++
+			bucket = min(bucket, i)
+		}
+	}
+	s.count++
+	s.sum += v
+
+	labels := make(map[string]string, len(exemplarLabels))
+	for name, value := range exemplarLabels {
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	if len(labels) > 0 {
+		s.exemplars[bucket] = &exemplar{labels: labels, value: v, time: time.Now()}
+	}
+}
+
+// get returns the series for labelValues; f.mu must be held.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+			s.exemplars = make([]*exemplar, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	return r.write(w, false)
+}
+
+// WriteOpenMetricsTo writes every metric in the OpenMetrics text format,
+// with exemplars.
+func (r *Registry) WriteOpenMetricsTo(w io.Writer) (int64, error) {
+	return r.write(w, true)
+}
+
+func (r *Registry) write(w io.Writer, openMetrics bool) (int64, error) {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	for _, f := range families {
+		f.write(cw, openMetrics)
+	}
+	if openMetrics {
+		fmt.Fprint(cw, "# EOF\n")
+	}
+	if err := bw.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+func (f *family) write(w io.Writer, openMetrics bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := f.name
+	if openMetrics && f.kind == "counter" {
+		// OpenMetrics names the counter family without its _total sample suffix.
+		name = strings.TrimSuffix(name, "_total")
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(f.help), name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind == "counter" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelString(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d%s\n", f.name, f.labelString(s.labelValues, formatFloat(upper)), s.counts[i], exemplarString(s.exemplars[i], openMetrics))
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d%s\n", f.name, f.labelString(s.labelValues, "+Inf"), s.count, exemplarString(s.exemplars[len(f.buckets)], openMetrics))
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelString(s.labelValues, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelString(s.labelValues, ""), s.count)
+	}
+}
+
+// labelString renders {a="x",b="y"}, adding le when given.
+func (f *family) labelString(values []string, le string) string {
+	var pairs []string
+	for i, name := range f.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// exemplarString renders " # {a="x"} value timestamp" for OpenMetrics.
+func exemplarString(e *exemplar, openMetrics bool) string {
+	if e == nil || !openMetrics {
+		return ""
+	}
+	names := make([]string, 0, len(e.labels))
+	for name := range e.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(e.labels[name]) + `"`
+	}
+	ts := strconv.FormatFloat(float64(e.time.UnixMilli())/1000, 'f', 3, 64)
+	return " # {" + strings.Join(pairs, ",") + "} " + formatFloat(e.value) + " " + ts
+}
+
+// Handler serves the registry for Prometheus to scrape: in the OpenMetrics
+// format, with exemplars, when the scraper accepts it and in the Prometheus
+// text format otherwise.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			_, _ = r.WriteOpenMetricsTo(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
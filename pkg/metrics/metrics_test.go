@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestRegistry_WritesPrometheusText(t *testing.T) {
+	reg := NewRegistry()
+	calls := reg.Counter("calls_total", "Calls made.", "tool")
+	latency := reg.Histogram("latency_seconds", "Latency.", []float64{1, 0.1})
+	calls.Inc("bash")
+	calls.Add(2, `we"ird`)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP calls_total Calls made.
+# TYPE calls_total counter
+calls_total{tool="bash"} 1
+calls_total{tool="we\"ird"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if reg.Counter("calls_total", "again", "tool") == nil {
+		t.Fatal("re-registering should return the existing counter")
+	}
+
+	// OpenMetrics scrapers also get the exemplar of the bucket it fell in.
+	latency.ObserveWithExemplar(0.07, map[string]string{"run_id": "r1", "span_id": ""})
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	rec = httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, req)
+	got := rec.Body.String()
+	for _, want := range []string{"# TYPE calls counter\n", `latency_seconds_bucket{le="0.1"} 2 # {run_id="r1"} 0.07 `, `latency_seconds_bucket{le="1"} 3` + "\n", "# EOF\n"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("OpenMetrics exposition missing %q:\n%s", want, got)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+}
+
+func TestAgent_RecordsLLMAndToolMetrics(t *testing.T) {
+	reg := NewRegistry()
+	agent := NewAgent(reg)
+
+	failed := errors.New("provider down")
+	calls := 0
+	provider := llm.Interceptor{Complete: func(llm.CompleteFunc) llm.CompleteFunc {
+		return func(context.Context, openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+			calls++
+			time.Sleep(time.Millisecond)
+			if calls == 2 {
+				return nil, failed
+			}
+			return &openai.ChatCompletion{Usage: openai.CompletionUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}, nil
+		}
+	}}
+	ctx := llm.WithInterceptors(trace.WithRun(context.Background(), "run-1"), agent.Interceptor(), provider)
+	params := openai.ChatCompletionNewParams{Model: shared.ChatModel("qwen-plus")}
+	_, _ = llm.Complete(ctx, nil, params)
+	_, _ = llm.Complete(ctx, nil, params)
+
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "bash"}}, tools.BashHandler)
+	registry = registry.WithMiddleware(agent.Middleware())
+	_, _ = registry.Dispatch(context.Background(), "bash", map[string]any{"command": "exit 2"})
+	_, _ = registry.Dispatch(context.Background(), "bash", map[string]any{})
+
+	var out strings.Builder
+	if _, err := reg.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	text := out.String()
+	for _, line := range []string{
+		`agent_llm_request_duration_seconds_count{model="qwen-plus",mode="complete"} 2`,
+		`agent_llm_errors_total{model="qwen-plus",mode="complete"} 1`,
+		`agent_llm_tokens_total{model="qwen-plus",type="completion"} 5`,
+		`agent_llm_tokens_total{model="qwen-plus",type="prompt"} 10`,
+		`agent_llm_tokens_per_second_count{model="qwen-plus"} 1`,
+		`agent_tool_duration_seconds_count{tool="bash"} 2`,
+		`agent_tool_errors_total{tool="bash"} 1`,
+		`agent_tool_nonzero_exits_total{tool="bash"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, text)
+		}
+	}
+
+	out.Reset()
+	_, _ = reg.WriteOpenMetricsTo(&out)
+	if !strings.Contains(out.String(), `# {run_id="run-1"}`) {
+		t.Errorf("LLM latency without the run ID exemplar:\n%s", out.String())
+	}
+}
@@ -0,0 +1,310 @@
+// Package notify tells webhooks about the lifecycle of unattended runs
+// (batch tasks, daemon tasks, pipelines, watch fixes): when a run starts,
+// when it asks for a permission nobody is there to grant, and when it
+// completes or fails, with a summary of the files it changed. Webhooks get
+// the event as JSON, or as a Slack incoming-webhook message.
+//
+// Notifications are best effort: a webhook that fails or times out is
+// reported through Logf and never fails the run.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/recap"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// Event types.
+const (
+	EventRunStarted          = "run_started"
+	EventPermissionRequested = "permission_requested"
+	EventRunCompleted        = "run_completed"
+	EventRunFailed           = "run_failed"
+)
+
+const (
+	sendTimeout = 10 * time.Second
+	// maxTextChars clips prompts and replies in events.
+	maxTextChars = 2000
+)
+
+// Event is one notification.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Run names what ran, e.g. "batch task-3" or "daemon".
+	Run        string      `json:"run"`
+	Workspace  string      `json:"workspace"`
+	Prompt     string      `json:"prompt,omitempty"`
+	Reply      string      `json:"reply,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMS int64       `json:"duration_ms,omitempty"`
+	Permission *Permission `json:"permission,omitempty"`
+	// Changes is set on completion and failure.
+	Changes *Changes `json:"changes,omitempty"`
+}
+
+// Permission is the request a headless run could not have approved.
+type Permission struct {
+	Tool    string `json:"tool"`
+	Summary string `json:"summary"`
+	Command string `json:"command,omitempty"`
+}
+
+// Changes summarizes what the run did to the workspace. Runs sharing a
+// workspace at the same time see each other's changes.
+type Changes struct {
+	Files []File `json:"files"`
+	// Unavailable explains why Files is unknown, e.g. outside git.
+	Unavailable string `json:"unavailable,omitempty"`
+	Tokens      int64  `json:"tokens"`
+}
+
+// File is one changed file.
+type File struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+}
+
+// Notifier sends events to the configured webhooks. A nil *Notifier sends
+// nothing, so callers need not check whether any webhook is configured.
+type Notifier struct {
+	hooks     []hook
+	workspace string
+	client    *http.Client
+	logf      func(format string, args ...any)
+}
+
+type hook struct {
+	url    string
+	slack  bool
+	events []string
+}
+
+// New returns a notifier for the webhooks of the project config, or nil
+// when there are none. Webhooks whose url_env is unset are skipped with a
+// warning through logf.
+func New(webhooks []config.Webhook, workspace string, logf func(format string, args ...any)) *Notifier {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	var hooks []hook
+	for _, w := range webhooks {
+		url := strings.TrimSpace(w.URL)
+		if env := strings.TrimSpace(w.URLEnv); env != "" {
+			if url = strings.TrimSpace(os.Getenv(env)); url == "" {
+				logf("webhook: %s is not set, skipping it", env)
+				continue
+			}
+		}
+		hooks = append(hooks, hook{url: url, slack: strings.EqualFold(w.Format, "slack"), events: w.Events})
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	return &Notifier{hooks: hooks, workspace: workspace, client: &http.Client{Timeout: sendTimeout}, logf: logf}
+}
+
+// Send posts event to every webhook that wants its type.
+func (n *Notifier) Send(ctx context.Context, event Event) {
+	if n == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Workspace == "" {
+		event.Workspace = n.workspace
+	}
+	for _, h := range n.hooks {
+		if len(h.events) > 0 && !slices.Contains(h.events, event.Type) {
+			continue
+		}
+		var payload any = event
+		if h.slack {
+			payload = map[string]string{"text": SlackText(event)}
+		}
+		if err := n.post(ctx, h.url, payload); err != nil {
+			n.logf("webhook: %s %s: %v", event.Type, event.Run, err)
+		}
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// A canceled run still reports how it ended.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+type runKey struct{}
+
+type runInfo struct {
+	notifier *Notifier
+	name     string
+	prompt   string
+}
+
+// Runner wraps next so every run it makes sends run_started and then
+// run_completed or run_failed. name identifies the runs, e.g. "daemon".
+// Without a notifier next is returned as is.
+func (n *Notifier) Runner(name string, next loop.AgentRunner) loop.AgentRunner {
+	if n == nil {
+		return next
+	}
+	return func(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		prompt := ""
+		if len(messages) > 0 {
+			prompt = clip(session.MessagePreview(messages[len(messages)-1], 0))
+		}
+		ctx = context.WithValue(ctx, runKey{}, runInfo{notifier: n, name: name, prompt: prompt})
+		n.Send(ctx, Event{Type: EventRunStarted, Run: name, Prompt: prompt})
+
+		started := time.Now()
+		turn := recap.Begin(ctx, n.workspace, "")
+		history, err := next(turn.Context(ctx), client, model, messages, registry)
+		summary := turn.End(context.WithoutCancel(ctx))
+
+		event := Event{
+			Type:       EventRunCompleted,
+			Run:        name,
+			Prompt:     prompt,
+			DurationMS: time.Since(started).Milliseconds(),
+			Changes:    changesOf(summary),
+		}
+		if len(history) > len(messages) {
+			if last := history[len(history)-1]; last.OfAssistant != nil {
+				event.Reply = clip(last.OfAssistant.Content.OfString.Value)
+			}
+		}
+		if err != nil {
+			event.Type, event.Error = EventRunFailed, err.Error()
+		}
+		n.Send(ctx, event)
+		return history, err
+	}
+}
+
+func changesOf(s recap.Summary) *Changes {
+	c := &Changes{Files: []File{}, Tokens: s.Usage.TotalTokens()}
+	if s.FilesErr != nil {
+		c.Unavailable = s.FilesErr.Error()
+	}
+	for _, f := range s.Files {
+		c.Files = append(c.Files, File{Path: f.Path, Status: f.Status, Added: f.Added, Deleted: f.Deleted})
+	}
+	return c
+}
+
+// Approver wraps the approver of headless runs: a request made within a
+// run of Runner is sent as permission_requested to that run's notifier
+// before next decides it, usually by denying it. Requests made outside such
+// a run go straight to next.
+func Approver(next permission.Approver) permission.Approver {
+	return permission.ApproverFunc(func(ctx context.Context, req permission.Request) (bool, error) {
+		info, _ := ctx.Value(runKey{}).(runInfo)
+		info.notifier.Send(ctx, Event{
+			Type:       EventPermissionRequested,
+			Run:        info.name,
+			Prompt:     info.prompt,
+			Permission: &Permission{Tool: req.Tool, Summary: req.Summary, Command: req.Command},
+		})
+		return next.Approve(ctx, req)
+	})
+}
+
+// SlackText renders event as a Slack message.
+func SlackText(e Event) string {
+	run := e.Run
+	if run == "" {
+		run = "agent"
+	}
+	var b strings.Builder
+	switch e.Type {
+	case EventRunStarted:
+		fmt.Fprintf(&b, ":arrow_forward: *%s* started in `%s`", run, e.Workspace)
+		if e.Prompt != "" {
+			fmt.Fprintf(&b, "\n> %s", quote(e.Prompt))
+		}
+	case EventPermissionRequested:
+		fmt.Fprintf(&b, ":raised_hand: *%s* asked to %s (%s); nobody can approve it in a headless run", run, e.Permission.Summary, e.Permission.Tool)
+	case EventRunCompleted, EventRunFailed:
+		duration := (time.Duration(e.DurationMS) * time.Millisecond).Round(time.Second)
+		if e.Type == EventRunCompleted {
+			fmt.Fprintf(&b, ":white_check_mark: *%s* completed in %s", run, duration)
+		} else {
+			fmt.Fprintf(&b, ":x: *%s* failed after %s: %s", run, duration, e.Error)
+		}
+		if e.Reply != "" {
+			fmt.Fprintf(&b, "\n> %s", quote(e.Reply))
+		}
+		if e.Changes != nil {
+			b.WriteString("\n" + changesText(*e.Changes))
+		}
+	default:
+		fmt.Fprintf(&b, "*%s*: %s", run, e.Type)
+	}
+	return b.String()
+}
+
+func changesText(c Changes) string {
+	switch {
+	case c.Unavailable != "":
+		return "files: unknown (" + c.Unavailable + ")"
+	case len(c.Files) == 0:
+		return "files: no changes"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "files: %d changed\n```", len(c.Files))
+	for _, f := range c.Files {
+		fmt.Fprintf(&b, "\n%-8s %s (+%d -%d)", f.Status, f.Path, f.Added, f.Deleted)
+	}
+	b.WriteString("\n```")
+	return b.String()
+}
+
+func quote(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ")
+}
+
+func clip(s string) string {
+	if runes := []rune(s); len(runes) > maxTextChars {
+		return string(runes[:maxTextChars-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// receiver records the bodies posted to it.
+type receiver struct {
+	mu     sync.Mutex
+	bodies []map[string]any
+}
+
+func (r *receiver) start(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		r.mu.Lock()
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRunner_SendsLifecycleWithChanges(t *testing.T) {
+	repo := initRepo(t)
+	var events, slack receiver
+	t.Setenv("TEST_SLACK_URL", slack.start(t))
+	n := New([]config.Webhook{
+		{URL: events.start(t)},
+		{URLEnv: "TEST_SLACK_URL", Format: "slack", Events: []string{EventRunFailed}},
+		{URLEnv: "TEST_UNSET_URL"},
+	}, repo, nil)
+	if len(n.hooks) != 2 {
+		t.Fatalf("hooks = %+v", n.hooks)
+	}
+
+	approver := Approver(permission.DenyAll)
+	runner := n.Runner("batch fix-1", func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		if ok, _ := approver.Approve(ctx, permission.Request{Tool: "deploy", Summary: "deploy to staging"}); ok {
+			t.Error("headless request approved")
+		}
+		if err := os.WriteFile(filepath.Join(repo, "calc.go"), []byte("package calc\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return append(messages, openai.AssistantMessage("Tests still fail.")), errors.New("max turns reached")
+	})
+	if _, err := runner(context.Background(), nil, "m", []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Fix the tests")}, tools.New()); err == nil {
+		t.Fatal("the run's error was lost")
+	}
+
+	var types []string
+	for _, body := range events.bodies {
+		types = append(types, body["type"].(string))
+	}
+	if strings.Join(types, ",") != "run_started,permission_requested,run_failed" {
+		t.Fatalf("events = %v", types)
+	}
+	if p := events.bodies[1]["permission"].(map[string]any); p["tool"] != "deploy" || events.bodies[1]["run"] != "batch fix-1" {
+		t.Fatalf("permission event = %v", events.bodies[1])
+	}
+	failed := events.bodies[2]
+	files := failed["changes"].(map[string]any)["files"].([]any)
+	if failed["error"] != "max turns reached" || failed["reply"] != "Tests still fail." || len(files) != 1 || files[0].(map[string]any)["path"] != "calc.go" {
+		t.Fatalf("failed event = %v", failed)
+	}
+
+	if len(slack.bodies) != 1 {
+		t.Fatalf("slack got %d messages", len(slack.bodies))
+	}
+	text := slack.bodies[0]["text"].(string)
+	if !strings.Contains(text, ":x: *batch fix-1* failed") || !strings.Contains(text, "created  calc.go (+1 -0)") {
+		t.Fatalf("slack text = %q", text)
+	}
+}
+
+func TestNew_NilWithoutWebhooks(t *testing.T) {
+	n := New(nil, t.TempDir(), nil)
+	if n != nil {
+		t.Fatal("expected a nil notifier")
+	}
+	// A nil notifier leaves runners alone, and requests outside a run are
+	// only decided.
+	n.Send(context.Background(), Event{Type: EventRunStarted})
+	if n.Runner("x", nil) != nil {
+		t.Fatal("nil notifier wrapped the runner")
+	}
+	if ok, _ := Approver(permission.AllowAll).Approve(context.Background(), permission.Request{Tool: "bash"}); !ok {
+		t.Fatal("Approver changed the decision")
+	}
+}
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "README"), []byte("calc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return repo
+}
@@ -0,0 +1,98 @@
+// Package openrouter creates a client for OpenRouter, which serves models of
+// many vendors behind one OpenAI-compatible API and routes each request to
+// one of the providers hosting the model. Routing preferences (provider
+// order, pinning, sorting) travel in the "provider" field of every chat
+// completion request.
+package openrouter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	defaultBaseURL = "https://openrouter.ai/api/v1"
+	defaultModel   = "openai/gpt-4o-mini"
+	// appTitle attributes requests to this project in OpenRouter's rankings.
+	appTitle = "learn-claude-code"
+)
+
+// Routing is OpenRouter's provider routing preferences. Provider names are
+// OpenRouter's, e.g. "deepinfra" or "together".
+type Routing struct {
+	// Order lists the providers to try first, in order.
+	Order []string `json:"order,omitempty"`
+	// AllowFallbacks false pins the request to Order (or Only): it fails
+	// rather than go to another provider. Nil leaves OpenRouter's default,
+	// which is to fall back.
+	AllowFallbacks *bool `json:"allow_fallbacks,omitempty"`
+	// Only and Ignore restrict the providers considered.
+	Only   []string `json:"only,omitempty"`
+	Ignore []string `json:"ignore,omitempty"`
+	// Sort is "price", "throughput" or "latency".
+	Sort string `json:"sort,omitempty"`
+	// RequireParameters skips providers that ignore some request
+	// parameters, e.g. tools.
+	RequireParameters bool `json:"require_parameters,omitempty"`
+	// DataCollection "deny" skips providers that may store prompts.
+	DataCollection string `json:"data_collection,omitempty"`
+}
+
+// IsZero reports whether r leaves routing to OpenRouter.
+func (r Routing) IsZero() bool {
+	return len(r.Order) == 0 && r.AllowFallbacks == nil && len(r.Only) == 0 && len(r.Ignore) == 0 &&
+		r.Sort == "" && !r.RequireParameters && r.DataCollection == ""
+}
+
+// Config describes how to reach OpenRouter.
+type Config struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+	Routing Routing
+}
+
+// ConfigFromEnv reads OPENROUTER_API_KEY, OPENROUTER_BASE_URL and
+// OPENROUTER_MODEL.
+func ConfigFromEnv() Config {
+	return Config{
+		APIKey:  strings.TrimSpace(os.Getenv("OPENROUTER_API_KEY")),
+		BaseURL: strings.TrimSpace(os.Getenv("OPENROUTER_BASE_URL")),
+		Model:   strings.TrimSpace(os.Getenv("OPENROUTER_MODEL")),
+	}
+}
+
+// ModelName returns the model requests go to by default. OpenRouter names
+// models vendor/model, e.g. deepseek/deepseek-chat.
+func (c Config) ModelName() string {
+	if c.Model == "" {
+		return defaultModel
+	}
+	return c.Model
+}
+
+// NewClient creates an OpenAI client pointed at OpenRouter. Extra options,
+// e.g. option.WithMiddleware, are applied after the defaults.
+func NewClient(cfg Config, opts ...option.RequestOption) (*openai.Client, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("OPENROUTER_API_KEY is not set")
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	defaults := []option.RequestOption{
+		option.WithBaseURL(baseURL),
+		option.WithAPIKey(cfg.APIKey),
+		option.WithHeader("X-Title", appTitle),
+	}
+	if !cfg.Routing.IsZero() {
+		defaults = append(defaults, option.WithJSONSet("provider", cfg.Routing))
+	}
+	client := openai.NewClient(append(defaults, opts...)...)
+	return &client, nil
+}
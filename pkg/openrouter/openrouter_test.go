@@ -0,0 +1,56 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestNewClient_SendsRoutingPreferences(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "deepseek/deepseek-chat",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "hi"}}},
+		})
+	}))
+	defer server.Close()
+
+	pinned := false
+	cfg := Config{APIKey: "or-key", BaseURL: server.URL, Model: "deepseek/deepseek-chat", Routing: Routing{Order: []string{"deepinfra"}, AllowFallbacks: &pinned}}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    cfg.ModelName(),
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")},
+	}); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	provider, _ := json.Marshal(body["provider"])
+	if string(provider) != `{"allow_fallbacks":false,"order":["deepinfra"]}` || body["model"] != "deepseek/deepseek-chat" {
+		t.Fatalf("body = %v", body)
+	}
+	if header.Get("Authorization") != "Bearer or-key" || header.Get("X-Title") != appTitle {
+		t.Fatalf("headers = %v", header)
+	}
+}
+
+func TestNewClient_RequiresAPIKey(t *testing.T) {
+	if _, err := NewClient(Config{}); err == nil {
+		t.Fatal("expected an error without OPENROUTER_API_KEY")
+	}
+	if !(Routing{}).IsZero() || (Routing{Sort: "price"}).IsZero() {
+		t.Fatal("IsZero is wrong")
+	}
+}
@@ -0,0 +1,201 @@
+// Package orchestrator fans a request out to parallel worker agents: a
+// planner splits the work into independent subtasks, each subtask runs in its
+// own workspace, and the results are merged into a single report.
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+const (
+	defaultMaxParallel = 3
+	defaultMaxSubtasks = 5
+)
+
+// Worker runs one subtask inside dir and returns the agent's final summary.
+type Worker func(ctx context.Context, subtask Subtask, dir string) (string, error)
+
+// RegistryBuilder builds a tool registry whose tools operate inside dir.
+type RegistryBuilder func(dir string) (*tools.Registry, error)
+
+// Result is the outcome of one subtask.
+type Result struct {
+	Subtask  Subtask
+	Dir      string
+	Summary  string
+	Diff     string
+	Err      error
+	Duration time.Duration
+}
+
+// Report collects all subtask results in plan order.
+type Report struct {
+	Request string
+	Results []Result
+}
+
+// Failed returns the results whose worker returned an error.
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Summary renders the merged report for the user or a parent agent.
+func (r Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d subtask(s), %d failed\n", len(r.Results), len(r.Failed()))
+	for _, result := range r.Results {
+		status := "ok"
+		if result.Err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(&b, "\n## [%s] %s (%s, %s)\n", result.Subtask.ID, result.Subtask.Title, status, result.Duration.Round(time.Millisecond))
+		if result.Err != nil {
+			fmt.Fprintf(&b, "error: %s\n", result.Err)
+		}
+		if summary := strings.TrimSpace(result.Summary); summary != "" {
+			b.WriteString(summary)
+			b.WriteByte('\n')
+		}
+		if result.Diff != "" {
+			fmt.Fprintf(&b, "(%d changed file(s))\n", strings.Count(result.Diff, "diff --git "))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Orchestrator plans a request and runs the subtasks concurrently.
+type Orchestrator struct {
+	Planner    Planner
+	Workspaces WorkspaceProvider
+	Worker     Worker
+	// MaxParallel bounds concurrent workers (default 3).
+	MaxParallel int
+	// KeepWorkspaces skips cleanup so results can be inspected.
+	KeepWorkspaces bool
+}
+
+// NewAgentWorker returns a Worker that runs loop.RunSubagent with a registry
+// built for the subtask's workspace.
+func NewAgentWorker(client *openai.Client, model string, build RegistryBuilder) Worker {
+	return func(ctx context.Context, subtask Subtask, dir string) (string, error) {
+		if build == nil {
+			return "", fmt.Errorf("registry builder is not configured")
+		}
+		registry, err := build(dir)
+		if err != nil {
+			return "", fmt.Errorf("build registry: %w", err)
+		}
+		systemPrompt := fmt.Sprintf(
+			"You are worker %q at %s.\n"+
+				"Complete only the assigned subtask; other workers handle the rest in parallel.\n"+
+				"Prefer tools over prose. Finish with a short summary of what you changed.",
+			subtask.ID,
+			dir,
+		)
+		return loop.RunSubagent(ctx, client, model, systemPrompt, subtask.Prompt, registry)
+	}
+}
+
+// Run plans the request, executes every subtask in its own workspace and
+// returns the merged report. Individual subtask failures are recorded in the
+// report rather than aborting the others.
+func (o *Orchestrator) Run(ctx context.Context, request string) (Report, error) {
+	if o.Planner == nil {
+		return Report{}, fmt.Errorf("planner is not configured")
+	}
+	if o.Workspaces == nil {
+		return Report{}, fmt.Errorf("workspace provider is not configured")
+	}
+	if o.Worker == nil {
+		return Report{}, fmt.Errorf("worker is not configured")
+	}
+
+	subtasks, err := o.Planner.Plan(ctx, request)
+	if err != nil {
+		return Report{}, fmt.Errorf("plan: %w", err)
+	}
+
+	parallel := o.MaxParallel
+	if parallel <= 0 {
+		parallel = defaultMaxParallel
+	}
+
+	report := Report{Request: request, Results: make([]Result, len(subtasks))}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, subtask := range subtasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				report.Results[i] = Result{Subtask: subtask, Err: ctx.Err()}
+				return
+			}
+			report.Results[i] = o.runSubtask(ctx, subtask)
+		}()
+	}
+	wg.Wait()
+
+	return report, nil
+}
+
+func (o *Orchestrator) runSubtask(ctx context.Context, subtask Subtask) (result Result) {
+	start := time.Now()
+	result.Subtask = subtask
+	defer func() { result.Duration = time.Since(start) }()
+
+	ws, err := o.Workspaces.Create(ctx, subtask.ID)
+	if err != nil {
+		result.Err = fmt.Errorf("create workspace: %w", err)
+		return result
+	}
+	result.Dir = ws.Dir
+	if !o.KeepWorkspaces && ws.Cleanup != nil {
+		defer func() {
+			if cleanupErr := ws.Cleanup(); cleanupErr != nil && result.Err == nil {
+				result.Err = fmt.Errorf("cleanup workspace: %w", cleanupErr)
+			}
+		}()
+	}
+
+	result.Summary, result.Err = o.Worker(ctx, subtask, ws.Dir)
+	if ws.Diff != nil {
+		diff, diffErr := ws.Diff(ctx)
+		result.Diff = diff
+		result.Err = errors.Join(result.Err, diffErr)
+	}
+	return result
+}
+
+// Merge applies every successful subtask's diff to repo in plan order. It
+// stops at the first diff that does not apply and reports which subtask
+// conflicted; earlier diffs stay applied.
+func Merge(ctx context.Context, repo string, report Report) error {
+	for _, result := range report.Results {
+		if result.Err != nil || result.Diff == "" {
+			continue
+		}
+		if err := ApplyDiff(ctx, repo, result.Diff); err != nil {
+			return fmt.Errorf("merge subtask %s: %w", result.Subtask.ID, err)
+		}
+	}
+	return nil
+}
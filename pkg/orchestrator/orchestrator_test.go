@@ -0,0 +1,138 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrchestrator_RunsSubtasksInParallelWorkspaces(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "shared.txt"), []byte("base"), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	var running, peak atomic.Int32
+	orch := &Orchestrator{
+		Planner:    staticPlan("a", "b", "c"),
+		Workspaces: TempDirs{Root: root},
+		Worker: func(_ context.Context, subtask Subtask, dir string) (string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			if _, err := os.Stat(filepath.Join(dir, "shared.txt")); err != nil {
+				return "", err
+			}
+			if subtask.ID == "b" {
+				return "", errors.New("boom")
+			}
+			return "did " + subtask.ID, os.WriteFile(filepath.Join(dir, subtask.ID+".txt"), nil, 0644)
+		},
+		MaxParallel: 2,
+	}
+
+	report, err := orch.Run(context.Background(), "do things")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(report.Results) != 3 || report.Results[0].Summary != "did a" || report.Results[2].Summary != "did c" {
+		t.Fatalf("results not in plan order: %+v", report.Results)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].Subtask.ID != "b" {
+		t.Fatalf("expected b to fail, got %+v", failed)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("parallelism exceeded: %d", peak.Load())
+	}
+	if _, err := os.Stat(report.Results[0].Dir); !os.IsNotExist(err) {
+		t.Fatalf("workspace should be cleaned up, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+		t.Fatal("worker must not write into the source root")
+	}
+	if !strings.Contains(report.Summary(), "3 subtask(s), 1 failed") {
+		t.Fatalf("unexpected summary:\n%s", report.Summary())
+	}
+}
+
+func TestGitWorktrees_DiffAndMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	gitInit(t, repo)
+
+	orch := &Orchestrator{
+		Planner:    staticPlan("readme", "code"),
+		Workspaces: GitWorktrees{Repo: repo, BaseDir: t.TempDir()},
+		Worker: func(_ context.Context, subtask Subtask, dir string) (string, error) {
+			if subtask.ID == "readme" {
+				return "edited", os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\nworld\n"), 0644)
+			}
+			return "added", os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+		},
+	}
+
+	report, err := orch.Run(context.Background(), "parallel edit")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, result := range report.Results {
+		if result.Err != nil || result.Diff == "" {
+			t.Fatalf("subtask %s: err=%v diff=%q", result.Subtask.ID, result.Err, result.Diff)
+		}
+	}
+
+	if err := Merge(context.Background(), repo, report); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, "README.md"))
+	if err != nil || string(data) != "hello\nworld\n" {
+		t.Fatalf("README not merged: %q %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "main.go")); err != nil {
+		t.Fatalf("new file not merged: %v", err)
+	}
+}
+
+func staticPlan(ids ...string) Planner {
+	return PlannerFunc(func(context.Context, string) ([]Subtask, error) {
+		subtasks := make([]Subtask, len(ids))
+		for i, id := range ids {
+			subtasks[i] = Subtask{ID: id, Title: id, Prompt: "work on " + id}
+		}
+		return subtasks, nil
+	})
+}
+
+func gitInit(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
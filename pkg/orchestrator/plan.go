@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const plannerSystemPrompt = `You are the planning agent of a multi-agent coding system.
+Split the user's request into independent subtasks that can be worked on in parallel,
+each in its own copy of the repository. Subtasks must not depend on each other's output
+and should touch disjoint files where possible. Use a single subtask when the work cannot
+be split. Reply with JSON only, in this shape:
+{"subtasks":[{"id":"short-kebab-id","title":"one line","prompt":"complete, self-contained instructions"}]}`
+
+// Subtask is one independent unit of work handed to a worker agent.
+type Subtask struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Prompt string `json:"prompt"`
+}
+
+// Planner splits a top-level request into subtasks.
+type Planner interface {
+	Plan(ctx context.Context, request string) ([]Subtask, error)
+}
+
+// PlannerFunc adapts a function to the Planner interface.
+type PlannerFunc func(ctx context.Context, request string) ([]Subtask, error)
+
+func (f PlannerFunc) Plan(ctx context.Context, request string) ([]Subtask, error) {
+	return f(ctx, request)
+}
+
+// ModelPlanner asks the model for a JSON plan.
+type ModelPlanner struct {
+	Client *openai.Client
+	Model  string
+	// MaxSubtasks caps the plan size; extra subtasks are dropped (default 5).
+	MaxSubtasks int
+}
+
+func (p ModelPlanner) Plan(ctx context.Context, request string) ([]Subtask, error) {
+	if p.Client == nil {
+		return nil, fmt.Errorf("planner client is nil")
+	}
+
+	resp, err := llm.Complete(ctx, p.Client, openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(p.Model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(plannerSystemPrompt),
+			openai.UserMessage(request),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("planner call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("planner returned no choices")
+	}
+
+	subtasks, err := ParsePlan(resp.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	limit := p.MaxSubtasks
+	if limit <= 0 {
+		limit = defaultMaxSubtasks
+	}
+	if len(subtasks) > limit {
+		subtasks = subtasks[:limit]
+	}
+	return subtasks, nil
+}
+
+// ParsePlan decodes the planner reply, tolerating Markdown code fences and
+// filling in missing IDs.
+func ParsePlan(content string) ([]Subtask, error) {
+	raw := strings.TrimSpace(content)
+	if start := strings.Index(raw, "{"); start >= 0 {
+		if end := strings.LastIndex(raw, "}"); end > start {
+			raw = raw[start : end+1]
+		}
+	}
+
+	var plan struct {
+		Subtasks []Subtask `json:"subtasks"`
+	}
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+		return nil, fmt.Errorf("decode plan: %w", err)
+	}
+
+	subtasks := make([]Subtask, 0, len(plan.Subtasks))
+	seen := make(map[string]bool)
+	for i, st := range plan.Subtasks {
+		st.Prompt = strings.TrimSpace(st.Prompt)
+		if st.Prompt == "" {
+			continue
+		}
+		st.ID = sanitizeID(st.ID)
+		if st.ID == "" || seen[st.ID] {
+			st.ID = fmt.Sprintf("task-%d", i+1)
+		}
+		seen[st.ID] = true
+		if strings.TrimSpace(st.Title) == "" {
+			st.Title = st.ID
+		}
+		subtasks = append(subtasks, st)
+	}
+	if len(subtasks) == 0 {
+		return nil, fmt.Errorf("plan contains no subtasks")
+	}
+	return subtasks, nil
+}
+
+// sanitizeID keeps IDs safe for directory and branch names.
+func sanitizeID(id string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(id)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ', r == '/', r == '.':
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
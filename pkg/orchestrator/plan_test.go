@@ -0,0 +1,33 @@
+package orchestrator
+
+import "testing"
+
+func TestParsePlan_StripsFencesAndFillsIDs(t *testing.T) {
+	content := "```json\n{\"subtasks\":[" +
+		"{\"id\":\"Add API/Handler\",\"title\":\"api\",\"prompt\":\"add handler\"}," +
+		"{\"id\":\"\",\"prompt\":\"write docs\"}," +
+		"{\"id\":\"empty\",\"prompt\":\"  \"}]}\n```"
+
+	subtasks, err := ParsePlan(content)
+	if err != nil {
+		t.Fatalf("ParsePlan: %v", err)
+	}
+	if len(subtasks) != 2 {
+		t.Fatalf("expected 2 subtasks, got %+v", subtasks)
+	}
+	if subtasks[0].ID != "add-api-handler" {
+		t.Fatalf("id not sanitized: %q", subtasks[0].ID)
+	}
+	if subtasks[1].ID != "task-2" || subtasks[1].Title != "task-2" {
+		t.Fatalf("missing id/title not filled: %+v", subtasks[1])
+	}
+}
+
+func TestParsePlan_RejectsEmptyPlan(t *testing.T) {
+	if _, err := ParsePlan(`{"subtasks":[]}`); err == nil {
+		t.Fatal("expected error for empty plan")
+	}
+	if _, err := ParsePlan("not json"); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
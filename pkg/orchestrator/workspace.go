@@ -0,0 +1,165 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace is an isolated directory a worker agent operates in.
+type Workspace struct {
+	Dir string
+	// Diff returns the worker's changes as a unified diff; nil when the
+	// workspace cannot produce one.
+	Diff func(ctx context.Context) (string, error)
+	// Cleanup removes the workspace.
+	Cleanup func() error
+}
+
+// WorkspaceProvider creates one isolated workspace per subtask.
+type WorkspaceProvider interface {
+	Create(ctx context.Context, id string) (Workspace, error)
+}
+
+// TempDirs copies Root into a fresh temporary directory for each subtask.
+// Changes stay in the copy; no diff is produced.
+type TempDirs struct {
+	Root string
+	// Skip lists directory names not copied (default .git, .local, .devtools, node_modules).
+	Skip []string
+}
+
+func (t TempDirs) Create(_ context.Context, id string) (Workspace, error) {
+	dir, err := os.MkdirTemp("", "orchestrator-"+id+"-")
+	if err != nil {
+		return Workspace{}, fmt.Errorf("create temp workspace: %w", err)
+	}
+	skip := t.Skip
+	if skip == nil {
+		skip = []string{".git", ".local", ".devtools", "node_modules"}
+	}
+	if t.Root != "" {
+		if err := copyTree(t.Root, dir, skip); err != nil {
+			_ = os.RemoveAll(dir)
+			return Workspace{}, err
+		}
+	}
+	return Workspace{Dir: dir, Cleanup: func() error { return os.RemoveAll(dir) }}, nil
+}
+
+// GitWorktrees checks out a detached worktree of Repo at HEAD per subtask.
+// The worker's changes (including new files) are returned by Diff.
+type GitWorktrees struct {
+	Repo string
+	// BaseDir holds the worktrees (default: a temporary directory).
+	BaseDir string
+}
+
+func (g GitWorktrees) Create(ctx context.Context, id string) (Workspace, error) {
+	base := g.BaseDir
+	if base == "" {
+		var err error
+		if base, err = os.MkdirTemp("", "orchestrator-worktrees-"); err != nil {
+			return Workspace{}, fmt.Errorf("create worktree base: %w", err)
+		}
+	}
+	dir := filepath.Join(base, id)
+	if _, err := runGit(ctx, g.Repo, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		return Workspace{}, err
+	}
+
+	return Workspace{
+		Dir: dir,
+		Diff: func(ctx context.Context) (string, error) {
+			if _, err := runGit(ctx, dir, "add", "-A"); err != nil {
+				return "", err
+			}
+			return runGit(ctx, dir, "diff", "--cached", "--binary", "HEAD")
+		},
+		Cleanup: func() error {
+			_, err := runGit(context.Background(), g.Repo, "worktree", "remove", "--force", dir)
+			return err
+		},
+	}, nil
+}
+
+// ApplyDiff applies a unified diff to repo with a three-way fallback.
+func ApplyDiff(ctx context.Context, repo, diff string) error {
+	if strings.TrimSpace(diff) == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "apply", "--3way", "--whitespace=nowarn", "-")
+	cmd.Dir = repo
+	cmd.Stdin = strings.NewReader(diff)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git apply: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func copyTree(src, dst string, skip []string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			for _, name := range skip {
+				if rel != "." && d.Name() == name {
+					return filepath.SkipDir
+				}
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
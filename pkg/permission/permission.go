@@ -0,0 +1,297 @@
+// Package permission asks for user approval before the agent performs
+// actions with side effects (writes, destructive commands, ...).
+package permission
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/i18n"
+)
+
+// Request describes the action awaiting approval.
+type Request struct {
+	Tool    string
+	Summary string
+	// Detail is shown verbatim below the summary, e.g. the SQL statement.
+	Detail string
+	// Command is the command or statement the action runs, e.g. the SQL
+	// query; allow rules match on its leading words.
+	Command string
+	// Change is set when the action writes a file; reviewers can show its
+	// diff and let the user edit the content.
+	Change *FileChange
+}
+
+// FileChange is a proposed write to one file.
+type FileChange struct {
+	// Path is the file as the user should see it (workspace-relative).
+	Path string
+	// Diff is a unified diff from the current to the proposed content.
+	Diff     string
+	Proposed string
+}
+
+// Verdict is a reviewer's answer to a request.
+type Verdict struct {
+	Approved bool
+	// Always approves further changes to the same file, or requests
+	// matching the same Rule, without asking.
+	Always bool
+	// Edited, when non-nil, is the content the user wants written instead
+	// of FileChange.Proposed.
+	Edited *string
+}
+
+// Reviewer is implemented by approvers that can do more than yes/no for
+// file changes: approve a file for the rest of the session or edit the
+// change before it is applied.
+type Reviewer interface {
+	Review(ctx context.Context, req Request) (Verdict, error)
+}
+
+// Review asks a for a verdict, through Review when a implements Reviewer
+// and as a plain yes/no otherwise.
+func Review(ctx context.Context, a Approver, req Request) (Verdict, error) {
+	if r, ok := a.(Reviewer); ok {
+		return r.Review(ctx, req)
+	}
+	approved, err := a.Approve(ctx, req)
+	return Verdict{Approved: approved}, err
+}
+
+// Approver decides whether a side-effecting action may proceed.
+type Approver interface {
+	Approve(ctx context.Context, req Request) (bool, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, req Request) (bool, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, req Request) (bool, error) {
+	return f(ctx, req)
+}
+
+var (
+	AllowAll Approver = ApproverFunc(func(context.Context, Request) (bool, error) { return true, nil })
+	DenyAll  Approver = ApproverFunc(func(context.Context, Request) (bool, error) { return false, nil })
+)
+
+// Bypass approves every request without asking. It backs
+// --dangerously-skip-permissions for containers and CI, where nobody is
+// there to answer; audit.RecordingApprover marks its decisions as bypassed.
+var Bypass Approver = bypass{}
+
+type bypass struct{}
+
+func (bypass) Approve(context.Context, Request) (bool, error) { return true, nil }
+
+// Prompter asks on a terminal-like stream and accepts y/yes as approval.
+// Its questions are in the language of i18n.Current; the answers are not.
+// File changes are reviewed with their diff and the choice of [y]es, [n]o,
+// [a]lways for this file or [e]dit in the user's editor.
+type Prompter struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+	// edit opens path in an editor and waits for it to exit.
+	edit func(ctx context.Context, path string) error
+}
+
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out, edit: runEditor}
+}
+
+func (p *Prompter) Approve(ctx context.Context, req Request) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.out, "\n%s\n", i18n.T(i18n.PermissionRequest, req.Tool, req.Summary))
+	if detail := strings.TrimSpace(req.Detail); detail != "" {
+		fmt.Fprintf(p.out, "%s\n", detail)
+	}
+	fmt.Fprint(p.out, i18n.T(i18n.PermissionAllow))
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("read approval: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Review implements Reviewer. Requests without a file change are asked as
+// yes/no, with the choice to always allow their Rule.
+func (p *Prompter) Review(ctx context.Context, req Request) (Verdict, error) {
+	if req.Change == nil {
+		return p.reviewAction(ctx, req)
+	}
+	if err := ctx.Err(); err != nil {
+		return Verdict{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.out, "\n%s\n", i18n.T(i18n.PermissionRequest, req.Tool, req.Summary))
+	fmt.Fprint(p.out, req.Change.Diff)
+	if !strings.HasSuffix(req.Change.Diff, "\n") {
+		fmt.Fprintln(p.out)
+	}
+	for {
+		fmt.Fprint(p.out, i18n.T(i18n.PermissionApply))
+		line, err := p.in.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF {
+				return Verdict{}, nil
+			}
+			return Verdict{}, fmt.Errorf("read approval: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return Verdict{Approved: true}, nil
+		case "n", "no", "":
+			return Verdict{}, nil
+		case "a", "always":
+			return Verdict{Approved: true, Always: true}, nil
+		case "e", "edit":
+			edited, err := p.editContent(ctx, req.Change)
+			if err != nil {
+				fmt.Fprintln(p.out, i18n.T(i18n.PermissionEditError, err))
+				continue
+			}
+			return Verdict{Approved: true, Edited: &edited}, nil
+		}
+	}
+}
+
+// reviewAction asks about a request without a file change.
+func (p *Prompter) reviewAction(ctx context.Context, req Request) (Verdict, error) {
+	if err := ctx.Err(); err != nil {
+		return Verdict{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.out, "\n%s\n", i18n.T(i18n.PermissionRequest, req.Tool, req.Summary))
+	if detail := strings.TrimSpace(req.Detail); detail != "" {
+		fmt.Fprintf(p.out, "%s\n", detail)
+	}
+	fmt.Fprint(p.out, i18n.T(i18n.PermissionAllowRule, RuleFor(req)))
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return Verdict{}, nil
+		}
+		return Verdict{}, fmt.Errorf("read approval: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return Verdict{Approved: true}, nil
+	case "a", "always":
+		return Verdict{Approved: true, Always: true}, nil
+	default:
+		return Verdict{}, nil
+	}
+}
+
+// editContent lets the user edit the proposed content in a temporary file
+// named after the target so editors pick the right syntax.
+func (p *Prompter) editContent(ctx context.Context, change *FileChange) (string, error) {
+	f, err := os.CreateTemp("", "agent-edit-*-"+filepath.Base(change.Path))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(change.Proposed)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.edit(ctx, f.Name()); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// runEditor opens path in $VISUAL, $EDITOR or vi on the process terminal.
+func runEditor(ctx context.Context, path string) error {
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The variable may carry flags, e.g. "code --wait".
+	fields := strings.Fields(editor)
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", editor, err)
+	}
+	return nil
+}
+
+type approverKey struct{}
+
+// WithApprover attaches an approver to ctx, overriding the one a Contextual
+// approver falls back to. Servers use it to route approvals to the client
+// that started the run.
+func WithApprover(ctx context.Context, a Approver) context.Context {
+	return context.WithValue(ctx, approverKey{}, a)
+}
+
+// Contextual returns an approver that delegates to the approver attached to
+// the call's context, or to fallback when there is none. It is also a
+// Reviewer, passing reviews through to whichever approver it picks.
+func Contextual(fallback Approver) Approver {
+	if fallback == nil {
+		fallback = DenyAll
+	}
+	return contextual{fallback: fallback}
+}
+
+type contextual struct {
+	fallback Approver
+}
+
+func (c contextual) pick(ctx context.Context) Approver {
+	if a, ok := ctx.Value(approverKey{}).(Approver); ok && a != nil {
+		return a
+	}
+	return c.fallback
+}
+
+func (c contextual) Approve(ctx context.Context, req Request) (bool, error) {
+	return c.pick(ctx).Approve(ctx, req)
+}
+
+func (c contextual) Review(ctx context.Context, req Request) (Verdict, error) {
+	return Review(ctx, c.pick(ctx), req)
+}
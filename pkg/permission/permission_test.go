@@ -0,0 +1,109 @@
+package permission
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrompter_ApprovesOnYes(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPrompter(strings.NewReader("y\n"), &out)
+
+	ok, err := p.Approve(context.Background(), Request{Tool: "sql_query", Summary: "write to app", Detail: "DELETE FROM users"})
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected approval")
+	}
+	if !strings.Contains(out.String(), "DELETE FROM users") || !strings.Contains(out.String(), "[y/N]") {
+		t.Fatalf("unexpected prompt: %q", out.String())
+	}
+}
+
+func TestPrompter_DeniesByDefault(t *testing.T) {
+	for _, input := range []string{"\n", "n\n", "whatever\n", ""} {
+		p := NewPrompter(strings.NewReader(input), &bytes.Buffer{})
+
+		ok, err := p.Approve(context.Background(), Request{Tool: "t", Summary: "s"})
+		if err != nil {
+			t.Fatalf("Approve(%q): %v", input, err)
+		}
+		if ok {
+			t.Fatalf("input %q should not approve", input)
+		}
+	}
+}
+
+func TestContextual_PrefersContextApprover(t *testing.T) {
+	approver := Contextual(DenyAll)
+
+	if ok, _ := approver.Approve(context.Background(), Request{}); ok {
+		t.Fatal("expected fallback to deny")
+	}
+	ctx := WithApprover(context.Background(), AllowAll)
+	if ok, _ := approver.Approve(ctx, Request{}); !ok {
+		t.Fatal("expected context approver to allow")
+	}
+}
+
+func TestPrompter_ReviewShowsDiffAndParsesChoices(t *testing.T) {
+	change := &FileChange{Path: "a.go", Diff: "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n", Proposed: "y\n"}
+	tests := []struct {
+		input string
+		want  Verdict
+	}{
+		{"y\n", Verdict{Approved: true}},
+		{"n\n", Verdict{}},
+		{"?\na\n", Verdict{Approved: true, Always: true}},
+		{"", Verdict{}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		p := NewPrompter(strings.NewReader(tt.input), &out)
+		got, err := p.Review(context.Background(), Request{Tool: "edit_file", Summary: "modify a.go", Change: change})
+		if err != nil {
+			t.Fatalf("Review(%q): %v", tt.input, err)
+		}
+		if got.Approved != tt.want.Approved || got.Always != tt.want.Always || got.Edited != nil {
+			t.Errorf("Review(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "+y\n") || !strings.Contains(out.String(), "[a]lways for this file") {
+			t.Errorf("prompt missing diff or choices: %q", out.String())
+		}
+	}
+}
+
+func TestPrompter_ReviewEdit(t *testing.T) {
+	p := NewPrompter(strings.NewReader("e\n"), &bytes.Buffer{})
+	p.edit = func(_ context.Context, path string) error {
+		if !strings.HasSuffix(path, "-a.go") {
+			t.Errorf("temp file %q should keep the target's name", path)
+		}
+		return os.WriteFile(path, []byte("edited\n"), 0o600)
+	}
+	got, err := p.Review(context.Background(), Request{Tool: "write_file", Change: &FileChange{Path: "pkg/a.go", Proposed: "proposed\n"}})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+	if !got.Approved || got.Edited == nil || *got.Edited != "edited\n" {
+		t.Fatalf("Review = %+v", got)
+	}
+}
+
+func TestReview_FallsBackToApprove(t *testing.T) {
+	v, err := Review(context.Background(), AllowAll, Request{Change: &FileChange{}})
+	if err != nil || !v.Approved || v.Always {
+		t.Fatalf("Review(AllowAll) = %+v, %v", v, err)
+	}
+	// Contextual passes reviews through to the approver it picks.
+	reviewer := NewPrompter(strings.NewReader("a\n"), &bytes.Buffer{})
+	ctx := WithApprover(context.Background(), reviewer)
+	v, err = Review(ctx, Contextual(nil), Request{Change: &FileChange{Path: "a"}})
+	if err != nil || !v.Always {
+		t.Fatalf("Review(Contextual) = %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,107 @@
+package permission
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FileTool is the tool name of rules for file changes: they cover every tool
+// that writes files (write_file, edit_file, multi_edit).
+const FileTool = "write"
+
+// Rule allows, without asking, the requests of Tool whose command starts
+// with Prefix word by word: "go test" matches "go test ./..." but not
+// "go testdata". An empty Prefix allows every request of the tool.
+type Rule struct {
+	Tool   string `json:"tool"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// RuleFor returns the rule an "always" answer to req creates: the path of a
+// file change, the first two words of a command, or the whole tool.
+func RuleFor(req Request) Rule {
+	if req.Change != nil {
+		return Rule{Tool: FileTool, Prefix: req.Change.Path}
+	}
+	words := strings.Fields(req.Command)
+	return Rule{Tool: req.Tool, Prefix: strings.Join(words[:min(len(words), 2)], " ")}
+}
+
+// Matches reports whether r allows req.
+func (r Rule) Matches(req Request) bool {
+	tool, command := req.Tool, req.Command
+	if req.Change != nil {
+		tool, command = FileTool, req.Change.Path
+	}
+	if r.Tool != tool {
+		return false
+	}
+	if r.Prefix == "" {
+		return true
+	}
+	command = strings.Join(strings.Fields(command), " ")
+	rest, ok := strings.CutPrefix(command, r.Prefix)
+	return ok && (rest == "" || strings.HasPrefix(rest, " "))
+}
+
+// String describes the rule for the user.
+func (r Rule) String() string {
+	switch {
+	case r.Tool == FileTool:
+		return "changes to " + r.Prefix
+	case r.Prefix == "":
+		return r.Tool
+	}
+	return r.Tool + " " + r.Prefix + " ..."
+}
+
+// Remember approves the requests matching rules without asking and passes
+// the others to inner. When the user answers "always", the request's rule
+// applies for the rest of the process and is handed to onAlways, e.g. to
+// persist it; onAlways may be nil.
+func Remember(inner Approver, rules []Rule, onAlways func(Rule)) Approver {
+	return &remember{inner: inner, rules: append([]Rule(nil), rules...), onAlways: onAlways}
+}
+
+type remember struct {
+	inner    Approver
+	onAlways func(Rule)
+
+	mu    sync.Mutex
+	rules []Rule
+}
+
+func (r *remember) Approve(ctx context.Context, req Request) (bool, error) {
+	verdict, err := r.Review(ctx, req)
+	return verdict.Approved, err
+}
+
+func (r *remember) Review(ctx context.Context, req Request) (Verdict, error) {
+	if r.allowed(req) {
+		return Verdict{Approved: true}, nil
+	}
+	verdict, err := Review(ctx, r.inner, req)
+	if err != nil || !verdict.Approved || !verdict.Always {
+		return verdict, err
+	}
+	rule := RuleFor(req)
+	r.mu.Lock()
+	r.rules = append(r.rules, rule)
+	r.mu.Unlock()
+	if r.onAlways != nil {
+		r.onAlways(rule)
+	}
+	return verdict, nil
+}
+
+func (r *remember) allowed(req Request) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rule := range r.rules {
+		if rule.Matches(req) {
+			return true
+		}
+	}
+	return false
+}
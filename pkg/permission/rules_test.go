@@ -0,0 +1,70 @@
+package permission
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRule_MatchesWordPrefixes(t *testing.T) {
+	rule := Rule{Tool: "sql_query", Prefix: "DELETE FROM"}
+	for _, tt := range []struct {
+		req  Request
+		want bool
+	}{
+		{Request{Tool: "sql_query", Command: "DELETE FROM sessions WHERE id = 1"}, true},
+		{Request{Tool: "sql_query", Command: "DELETE  FROM\tsessions"}, true},
+		{Request{Tool: "sql_query", Command: "DELETE FROMAGE"}, false},
+		{Request{Tool: "create_pr", Command: "DELETE FROM sessions"}, false},
+	} {
+		if got := rule.Matches(tt.req); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.req.Command, got, tt.want)
+		}
+	}
+
+	if !(Rule{Tool: "create_pr"}).Matches(Request{Tool: "create_pr"}) {
+		t.Error("a rule without prefix should allow the whole tool")
+	}
+	file := RuleFor(Request{Tool: "edit_file", Change: &FileChange{Path: "pkg/a.go"}})
+	if file != (Rule{Tool: FileTool, Prefix: "pkg/a.go"}) || !file.Matches(Request{Tool: "write_file", Change: &FileChange{Path: "pkg/a.go"}}) {
+		t.Errorf("file rule = %+v", file)
+	}
+}
+
+func TestRemember_PersistsAlwaysAnswers(t *testing.T) {
+	var out bytes.Buffer
+	var saved []Rule
+	approver := Remember(NewPrompter(strings.NewReader("a\n"), &out),
+		[]Rule{{Tool: "create_pr"}},
+		func(r Rule) { saved = append(saved, r) })
+
+	ctx := context.Background()
+	approve := func(req Request) bool {
+		t.Helper()
+		ok, err := approver.Approve(ctx, req)
+		if err != nil {
+			t.Fatalf("Approve: %v", err)
+		}
+		return ok
+	}
+	if !approve(Request{Tool: "create_pr", Summary: "open a pull request"}) || out.Len() != 0 {
+		t.Fatalf("configured rule should approve without asking, output %q", out.String())
+	}
+	if !approve(Request{Tool: "sql_query", Command: "UPDATE users SET name = 'x'"}) {
+		t.Fatal("always answer should approve")
+	}
+	if !strings.Contains(out.String(), "[a]lways allow sql_query UPDATE users ...") {
+		t.Fatalf("prompt should offer the rule, got %q", out.String())
+	}
+	// The input is exhausted: approvals now come from the remembered rule.
+	if !approve(Request{Tool: "sql_query", Command: "UPDATE users SET name = 'y'"}) {
+		t.Fatal("remembered rule should approve")
+	}
+	if approve(Request{Tool: "sql_query", Command: "UPDATE orders SET total = 0"}) {
+		t.Fatal("other commands should still be asked")
+	}
+	if len(saved) != 1 || saved[0] != (Rule{Tool: "sql_query", Prefix: "UPDATE users"}) {
+		t.Fatalf("saved = %+v", saved)
+	}
+}
@@ -0,0 +1,254 @@
+// Package pipeline runs the agent as a filter, so other processes can embed
+// it: user messages come in on one stream and the reply, or every event of
+// the run, goes out on another.
+//
+// Both directions have two formats. With FormatText the whole input is one
+// prompt and the output is the final reply. With FormatStreamJSON every
+// input line is a message
+//
+//	{"type":"user","content":"fix the test"}
+//
+// and the messages continue one conversation, one turn at a time. Output in
+// FormatStreamJSON is one event per line:
+//
+//	{"type":"init","model":"...","tools":["bash",...]}
+//	{"type":"token","delta":"..."}
+//	{"type":"tool_start","tool":"bash","args":{...}}
+//	{"type":"tool_end","tool":"bash","output":"...","error":"..."}
+//	{"type":"result","reply":"...","duration_ms":1200}
+//	{"type":"result","is_error":true,"error":"...","duration_ms":1200}
+//	{"type":"error","error":"line 3: ..."}
+//
+// Every turn ends with exactly one result event; error reports input lines
+// that could not be used, which are skipped. In text output the first
+// failure ends the run instead. Unlike pkg/stdio there is no way to answer
+// approvals: tools that need one are denied.
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// Formats of the input and output streams.
+const (
+	FormatText       = "text"
+	FormatStreamJSON = "stream-json"
+)
+
+// Event types of FormatStreamJSON output.
+const (
+	EventInit      = "init"
+	EventToken     = "token"
+	EventToolStart = "tool_start"
+	EventToolEnd   = "tool_end"
+	EventResult    = "result"
+	EventError     = "error"
+)
+
+// maxLineBytes bounds one input message.
+const maxLineBytes = 16 << 20
+
+// Config configures Run.
+type Config struct {
+	Client       *openai.Client
+	Model        string
+	Registry     *tools.Registry
+	SystemPrompt string
+	// MaxTurns limits the model calls of each message; 0 means no limit.
+	MaxTurns int
+	// InputFormat and OutputFormat default to FormatText.
+	InputFormat  string
+	OutputFormat string
+	// Runner defaults to loop.Run.
+	Runner loop.AgentRunner
+}
+
+// CheckFormat reports whether format is one Run understands.
+func CheckFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatStreamJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (want %s or %s)", format, FormatText, FormatStreamJSON)
+}
+
+type runner struct {
+	agent *agent.Agent
+	json  bool
+
+	w       io.Writer
+	writeMu sync.Mutex
+}
+
+// Run reads messages from r until it is exhausted and writes the results to
+// w. It reports whether any turn failed. The error is what ended the run
+// early: a failure of the streams or, in text output, the failed turn.
+func Run(ctx context.Context, cfg Config, r io.Reader, w io.Writer) (failed bool, err error) {
+	if err := CheckFormat(cfg.InputFormat); err != nil {
+		return false, fmt.Errorf("input: %w", err)
+	}
+	if err := CheckFormat(cfg.OutputFormat); err != nil {
+		return false, fmt.Errorf("output: %w", err)
+	}
+	if cfg.Registry == nil {
+		cfg.Registry = tools.New()
+	}
+	p := &runner{w: w, json: cfg.OutputFormat == FormatStreamJSON}
+	registry := cfg.Registry
+	if p.json {
+		registry = registry.WithMiddleware(p.toolEvents)
+	}
+	opts := []agent.Option{
+		agent.WithClient(cfg.Client),
+		agent.WithModel(cfg.Model),
+		agent.WithTools(registry),
+		agent.WithSystemPrompt(cfg.SystemPrompt),
+		agent.WithMaxTurns(cfg.MaxTurns),
+	}
+	if cfg.Runner != nil {
+		opts = append(opts, agent.WithRunner(cfg.Runner))
+	}
+	a, err := agent.New(opts...)
+	if err != nil {
+		return false, err
+	}
+	p.agent = a
+
+	if p.json {
+		names := []string{}
+		for _, def := range cfg.Registry.Definitions() {
+			names = append(names, def.Function.Name)
+		}
+		if err := p.emit(map[string]any{"type": EventInit, "model": cfg.Model, "tools": names}); err != nil {
+			return false, err
+		}
+	}
+
+	if cfg.InputFormat != FormatStreamJSON {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return false, err
+		}
+		prompt := strings.TrimSpace(string(data))
+		if prompt == "" {
+			return false, fmt.Errorf("no prompt on the input")
+		}
+		return p.turn(ctx, prompt)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for n := 1; scanner.Scan(); n++ {
+		if ctx.Err() != nil {
+			return failed, ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		prompt, err := parseMessage(line)
+		if err != nil {
+			failed = true
+			if err := p.inputError(fmt.Errorf("line %d: %w", n, err)); err != nil {
+				return failed, err
+			}
+			continue
+		}
+		turnFailed, err := p.turn(ctx, prompt)
+		failed = failed || turnFailed
+		if err != nil {
+			return failed, err
+		}
+	}
+	return failed, scanner.Err()
+}
+
+// parseMessage returns the content of a stream-json input line.
+func parseMessage(line string) (string, error) {
+	var msg struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return "", err
+	}
+	if msg.Type != "user" {
+		return "", fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return "", fmt.Errorf("user message without content")
+	}
+	return msg.Content, nil
+}
+
+// turn runs one user message and writes its result.
+func (p *runner) turn(ctx context.Context, prompt string) (failed bool, err error) {
+	start := time.Now()
+	if !p.json {
+		reply, runErr := p.agent.Run(ctx, prompt)
+		if runErr != nil {
+			return true, runErr
+		}
+		_, err := fmt.Fprintln(p.w, reply)
+		return false, err
+	}
+
+	reply, runErr := p.agent.Stream(ctx, prompt, func(delta string) {
+		_ = p.emit(map[string]any{"type": EventToken, "delta": delta})
+	})
+	result := map[string]any{"type": EventResult, "duration_ms": time.Since(start).Milliseconds()}
+	if runErr != nil {
+		result["is_error"] = true
+		result["error"] = runErr.Error()
+	} else {
+		result["reply"] = reply
+	}
+	return runErr != nil, p.emit(result)
+}
+
+// inputError reports an unusable input line. Text output has no place for
+// it, so it ends the run like a failed turn does.
+func (p *runner) inputError(err error) error {
+	if !p.json {
+		return err
+	}
+	return p.emit(map[string]any{"type": EventError, "error": err.Error()})
+}
+
+func (p *runner) toolEvents(name string, next tools.Handler) tools.Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		_ = p.emit(map[string]any{"type": EventToolStart, "tool": name, "args": args})
+		output, err := next(ctx, args)
+
+		event := map[string]any{"type": EventToolEnd, "tool": name, "output": output}
+		if err != nil {
+			event["error"] = err.Error()
+		}
+		_ = p.emit(event)
+		return output, err
+	}
+}
+
+// emit writes one event line; tools may run concurrently.
+func (p *runner) emit(event map[string]any) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode %v event: %w", event["type"], err)
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
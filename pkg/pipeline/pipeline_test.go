@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// echoRunner streams "on it", calls the echo tool with the prompt and
+// replies with the tool output plus the number of user messages so far.
+// The prompt "fail" makes the turn fail.
+func echoRunner(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, r *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+	prompt := messages[len(messages)-1].OfUser.Content.OfString.Value
+	if prompt == "fail" {
+		return messages, errors.New("model unavailable")
+	}
+	if onToken := loop.TokenHandlerFrom(ctx); onToken != nil {
+		onToken("on it")
+	}
+	output, err := r.Dispatch(ctx, "echo", map[string]any{"text": prompt})
+	if err != nil {
+		return messages, err
+	}
+	users := 0
+	for _, m := range messages {
+		if m.OfUser != nil {
+			users++
+		}
+	}
+	return append(messages, openai.AssistantMessage(output+" #"+strconv.Itoa(users))), nil
+}
+
+func echoRegistry() *tools.Registry {
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "echo"}},
+		func(_ context.Context, args map[string]any) (string, error) {
+			text, _ := args["text"].(string)
+			return text, nil
+		})
+	return registry
+}
+
+func run(t *testing.T, in, out string, input string) (string, bool, error) {
+	t.Helper()
+	var w bytes.Buffer
+	failed, err := Run(context.Background(), Config{
+		Client: &openai.Client{}, Model: "test-model", Registry: echoRegistry(), Runner: echoRunner,
+		InputFormat: in, OutputFormat: out,
+	}, strings.NewReader(input), &w)
+	return w.String(), failed, err
+}
+
+// UT-PIPE-001: 文本输入 / 文本输出：整个输入为一条提示，只输出最终回复
+func TestRun_TextInTextOut(t *testing.T) {
+	out, failed, err := run(t, "", "", "  hello\n")
+	if err != nil || failed {
+		t.Fatalf("Run: failed=%v err=%v", failed, err)
+	}
+	if out != "hello #1\n" {
+		t.Fatalf("output = %q", out)
+	}
+
+	if _, _, err := run(t, FormatText, FormatText, "fail"); err == nil || !strings.Contains(err.Error(), "model unavailable") {
+		t.Fatalf("failed turn: err = %v", err)
+	}
+}
+
+// UT-PIPE-002: stream-json 输入 / 输出：多条消息延续同一会话，每轮输出 token、工具事件和 result；
+// 坏行输出 error 事件并跳过，失败的轮次以 is_error 的 result 结束，run 整体标记为失败
+func TestRun_StreamJSON(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"user","content":"first"}`,
+		``,
+		`not json`,
+		`{"type":"assistant","content":"x"}`,
+		`{"type":"user","content":"fail"}`,
+		`{"type":"user","content":"second"}`,
+	}, "\n")
+	out, failed, err := run(t, FormatStreamJSON, FormatStreamJSON, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed {
+		t.Error("failed = false despite bad lines and a failed turn")
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event line %q: %v", line, err)
+		}
+		summary, _ := event["type"].(string)
+		for _, key := range []string{"tool", "reply", "error"} {
+			if v, ok := event[key]; ok {
+				summary += " " + v.(string)
+			}
+		}
+		if event["type"] == EventResult {
+			if _, ok := event["duration_ms"]; !ok {
+				t.Errorf("result without duration_ms: %s", line)
+			}
+		}
+		got = append(got, summary)
+	}
+	want := []string{
+		"init",
+		"token",
+		"tool_start echo",
+		"tool_end echo",
+		"result first #1",
+		"error line 3: invalid character 'o' in literal null (expecting 'u')",
+		`error line 4: unknown message type "assistant"`,
+		"result model unavailable",
+		"token",
+		"tool_start echo",
+		"tool_end echo",
+		"result second #3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// UT-PIPE-003: 未知格式在运行前报错
+func TestRun_RejectsUnknownFormat(t *testing.T) {
+	if _, _, err := run(t, "xml", "", "hi"); err == nil {
+		t.Fatal("unknown input format accepted")
+	}
+	if _, _, err := run(t, "", "yaml", "hi"); err == nil {
+		t.Fatal("unknown output format accepted")
+	}
+}
@@ -0,0 +1,353 @@
+// Package precommit checks staged changes against the project's rules before
+// they are committed. A model, usually a small and cheap one, gets the diff
+// and the rules and reports each violation with report_violation. Secrets
+// are also found without the model, and the model only ever sees the diff
+// with them masked.
+package precommit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/redact"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	// maxDiffLength keeps the prompt small; the check runs on every commit.
+	maxDiffLength   = 30000
+	defaultMaxTurns = 4
+)
+
+// IDs of the built-in rules.
+const (
+	RuleNoTODO       = "no-todo"
+	RuleTestsUpdated = "tests-updated"
+	RuleNoSecrets    = "no-secrets"
+)
+
+// Rule is one project rule, stated for the model in plain words.
+type Rule struct {
+	ID   string `json:"id"`
+	Text string `json:"rule"`
+}
+
+// DefaultRules apply when the config lists none.
+var DefaultRules = []Rule{
+	{ID: RuleNoTODO, Text: "Added lines must not contain TODO, FIXME or XXX comments; track unfinished work in an issue instead."},
+	{ID: RuleTestsUpdated, Text: "A change to the behavior of a source file comes with a change to its tests, e.g. calc.go with calc_test.go. Comment, documentation and formatting changes need no tests."},
+	{ID: RuleNoSecrets, Text: "Added lines must not contain credentials such as API keys, tokens, passwords or private keys."},
+}
+
+const systemPrompt = `You check a commit against the rules of a project before it is made.
+You are given the staged diff and the rules, each with an ID. Report every violation with
+report_violation: the rule, the file and line on the new side of the diff, what is wrong and how to
+fix it. Only report clear violations of the listed rules, nothing else; code quality is not your
+concern. Secrets in the diff are masked as [REDACTED:...].
+
+When you are done, reply with "OK" or the number of violations.`
+
+// Violation is one broken rule.
+type Violation struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+	// Fix tells the author how to resolve it.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Violations []Violation `json:"violations"`
+}
+
+// Passed reports whether the commit may go ahead.
+func (r Result) Passed() bool { return len(r.Violations) == 0 }
+
+// Options configure Check.
+type Options struct {
+	Client *openai.Client
+	Model  string
+	Rules  []Rule
+	// MaxTurns limits the model calls (default 4).
+	MaxTurns int
+}
+
+// Rules resolves the configured rules: the defaults when none are listed,
+// built-in text for built-in IDs without their own.
+func Rules(cfg config.PreCommit) ([]Rule, error) {
+	if len(cfg.Rules) == 0 {
+		return slices.Clone(DefaultRules), nil
+	}
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule := Rule{ID: strings.TrimSpace(r.ID), Text: strings.TrimSpace(r.Rule)}
+		if rule.Text == "" {
+			i := slices.IndexFunc(DefaultRules, func(d Rule) bool { return d.ID == rule.ID })
+			if i < 0 {
+				return nil, fmt.Errorf("pre-commit rule %q needs a rule text (built-in rules: %s, %s, %s)", rule.ID, RuleNoTODO, RuleTestsUpdated, RuleNoSecrets)
+			}
+			rule.Text = DefaultRules[i].Text
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Check checks diff against opts.Rules. An empty diff passes without asking
+// the model.
+func Check(ctx context.Context, diff string, opts Options) (Result, error) {
+	if strings.TrimSpace(diff) == "" || len(opts.Rules) == 0 {
+		return Result{}, nil
+	}
+	if opts.MaxTurns <= 0 {
+		opts.MaxTurns = defaultMaxTurns
+	}
+	ids := make([]string, len(opts.Rules))
+	for i, r := range opts.Rules {
+		ids[i] = r.ID
+	}
+
+	collector := &violations{rules: ids}
+	if slices.Contains(ids, RuleNoSecrets) {
+		for _, v := range ScanSecrets(diff) {
+			collector.add(v)
+		}
+	}
+	registry := tools.New()
+	registry.Register(reportViolationToolDef(ids), collector.handle)
+	a, err := agent.New(
+		agent.WithClient(opts.Client),
+		agent.WithModel(opts.Model),
+		agent.WithTools(registry),
+		agent.WithSystemPrompt(systemPrompt),
+		agent.WithMaxTurns(opts.MaxTurns),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	_, err = a.Run(ctx, checkPrompt(redact.Text(diff), opts.Rules))
+	return Result{Violations: collector.list}, err
+}
+
+func checkPrompt(diff string, rules []Rule) string {
+	var b strings.Builder
+	b.WriteString("Rules:\n")
+	for _, r := range rules {
+		fmt.Fprintf(&b, "- %s: %s\n", r.ID, r.Text)
+	}
+	note := ""
+	if len(diff) > maxDiffLength {
+		diff = diff[:maxDiffLength]
+		note = "\n(The diff was truncated.)"
+	}
+	b.WriteString("\nStaged diff:\n\n```diff\n" + diff + "\n```" + note)
+	return b.String()
+}
+
+func reportViolationToolDef(ids []string) openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "report_violation",
+			Description: openai.String("Record one violation of a rule. Call once per violation."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"rule":    map[string]any{"type": "string", "enum": ids},
+					"file":    map[string]any{"type": "string", "description": "Path as shown in the diff."},
+					"line":    map[string]any{"type": "integer", "description": "Line number on the new side of the diff; omit for file-level violations."},
+					"message": map[string]any{"type": "string", "description": "What breaks the rule."},
+					"fix":     map[string]any{"type": "string", "description": "What the author should change."},
+				},
+				"required": []string{"rule", "file", "message", "fix"},
+			},
+		},
+	}
+}
+
+type violations struct {
+	mu    sync.Mutex
+	rules []string
+	list  []Violation
+}
+
+func (v *violations) handle(_ context.Context, args map[string]any) (string, error) {
+	var violation Violation
+	violation.Rule, _ = args["rule"].(string)
+	violation.File, _ = args["file"].(string)
+	violation.Message, _ = args["message"].(string)
+	violation.Fix, _ = args["fix"].(string)
+	if line, ok := args["line"].(float64); ok && line > 0 {
+		violation.Line = int(line)
+	}
+	violation.File = strings.TrimPrefix(strings.TrimSpace(violation.File), "b/")
+	if violation.File == "" || strings.TrimSpace(violation.Message) == "" {
+		return "", fmt.Errorf("'file' and 'message' are required")
+	}
+	if !slices.Contains(v.rules, violation.Rule) {
+		return "", fmt.Errorf("invalid 'rule' %q: want one of %s", violation.Rule, strings.Join(v.rules, ", "))
+	}
+	if !v.add(violation) {
+		return "Already recorded.", nil
+	}
+	return "Recorded the violation.", nil
+}
+
+// add records violation unless the same rule was already reported for its
+// line, e.g. a secret found by ScanSecrets and again by the model.
+func (v *violations) add(violation Violation) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, old := range v.list {
+		if old.Rule == violation.Rule && old.File == violation.File && old.Line == violation.Line && violation.Line > 0 {
+			return false
+		}
+	}
+	v.list = append(v.list, violation)
+	return true
+}
+
+var (
+	masked     = regexp.MustCompile(`\[REDACTED:([a-z-]+)\]`)
+	privateKey = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+)
+
+// ScanSecrets reports the added lines of diff that contain a credential
+// recognized by package redact.
+func ScanSecrets(diff string) []Violation {
+	var found []Violation
+	for _, line := range addedLines(diff) {
+		kind := ""
+		if m := masked.FindStringSubmatch(redact.Text(line.text)); m != nil {
+			kind = m[1]
+		} else if privateKey.MatchString(line.text) {
+			kind = "private-key"
+		}
+		if kind == "" {
+			continue
+		}
+		found = append(found, Violation{
+			Rule:    RuleNoSecrets,
+			File:    line.file,
+			Line:    line.number,
+			Message: fmt.Sprintf("possible secret (%s) added", kind),
+			Fix:     "Remove it from the change, load it from the environment instead, and rotate it if it was ever pushed.",
+		})
+	}
+	return found
+}
+
+type addedLine struct {
+	file   string
+	number int
+	text   string
+}
+
+// addedLines lists the "+" lines of a unified diff with their new-side line
+// numbers.
+func addedLines(diff string) []addedLine {
+	var lines []addedLine
+	var file, prev string
+	next := 0
+	for _, line := range strings.Split(diff, "\n") {
+		header := strings.HasPrefix(prev, "--- ")
+		prev = line
+		switch {
+		case header && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			next = 0
+		case strings.HasPrefix(line, "diff "):
+			file, next = "", 0
+		case strings.HasPrefix(line, "@@"):
+			next = hunkStart(line)
+		case file == "" || next == 0:
+		case strings.HasPrefix(line, "+"):
+			lines = append(lines, addedLine{file: file, number: next, text: line[1:]})
+			next++
+		case line == "" || strings.HasPrefix(line, " "):
+			next++ // context; some tools strip the space of blank lines
+		}
+	}
+	return lines
+}
+
+// hunkStart parses the new-side start of "@@ -a,b +c,d @@".
+func hunkStart(header string) int {
+	for _, field := range strings.Fields(header) {
+		if strings.HasPrefix(field, "+") {
+			start, _, _ := strings.Cut(field[1:], ",")
+			if n, err := strconv.Atoi(start); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// Format renders result for the terminal of the person committing.
+func Format(result Result) string {
+	if result.Passed() {
+		return "pre-commit: all rules pass.\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "pre-commit: %d rule violation(s), commit blocked.\n", len(result.Violations))
+	for _, v := range result.Violations {
+		location := v.File
+		if v.Line > 0 {
+			location = fmt.Sprintf("%s:%d", v.File, v.Line)
+		}
+		fmt.Fprintf(&b, "\n[%s] %s\n  %s\n", v.Rule, location, v.Message)
+		if v.Fix != "" {
+			fmt.Fprintf(&b, "  fix: %s\n", v.Fix)
+		}
+	}
+	b.WriteString("\nFix them and commit again, or skip the check with git commit --no-verify.\n")
+	return b.String()
+}
+
+// hookScript is the pre-commit hook Install writes.
+const hookScript = `#!/bin/sh
+# Installed by "agent hook install": checks the staged changes against the
+# rules in the hooks.pre_commit section of .agent/config.json.
+exec agent hook pre-commit
+`
+
+// Install writes the pre-commit hook of the git repository at dir and
+// returns its path. An existing hook is only replaced with force.
+func Install(ctx context.Context, dir string, force bool) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+	hooks := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooks) {
+		hooks = filepath.Join(dir, hooks)
+	}
+	path := filepath.Join(hooks, "pre-commit")
+	if existing, err := os.ReadFile(path); err == nil && string(existing) != hookScript && !force {
+		return "", fmt.Errorf("%s already exists; pass --force to replace it", path)
+	}
+	if err := os.MkdirAll(hooks, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(hookScript), 0o755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
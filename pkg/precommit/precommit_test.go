@@ -0,0 +1,160 @@
+package precommit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const sampleDiff = `diff --git a/calc.go b/calc.go
+index 1111111..2222222 100644
+--- a/calc.go
++++ b/calc.go
+@@ -1,3 +1,6 @@
+ package calc
+
+-func Div(a, b int) int { return a / b }
++// TODO: handle b == 0
++func Div(a, b int) int { return a / b }
++
++const apiKey = "sk-abcdefghijklmnopqrstuvwx"
+`
+
+func TestRules_ResolvesBuiltInText(t *testing.T) {
+	rules, err := Rules(config.PreCommit{})
+	if err != nil || len(rules) != 3 {
+		t.Fatalf("defaults = %v, %v", rules, err)
+	}
+	rules, err = Rules(config.PreCommit{Rules: []config.HookRule{{ID: "no-todo"}, {ID: "changelog", Rule: "Update CHANGELOG.md."}}})
+	if err != nil || len(rules) != 2 || rules[0].Text != DefaultRules[0].Text || rules[1].Text != "Update CHANGELOG.md." {
+		t.Fatalf("rules = %v, %v", rules, err)
+	}
+	if _, err := Rules(config.PreCommit{Rules: []config.HookRule{{ID: "changelog"}}}); err == nil {
+		t.Fatal("expected an error for a custom rule without text")
+	}
+}
+
+func TestScanSecrets_FindsAddedCredentials(t *testing.T) {
+	found := ScanSecrets(sampleDiff)
+	if len(found) != 1 || found[0].File != "calc.go" || found[0].Line != 6 || !strings.Contains(found[0].Message, "api-key") {
+		t.Fatalf("found = %+v", found)
+	}
+}
+
+func TestCheck_MergesModelAndSecretViolations(t *testing.T) {
+	var prompt string
+	client := scriptedClient(t, &prompt,
+		toolCalls(
+			violation(`{"rule": "no-todo", "file": "b/calc.go", "line": 4, "message": "TODO added", "fix": "Handle b == 0 or open an issue."}`),
+			violation(`{"rule": "no-secrets", "file": "calc.go", "line": 6, "message": "API key", "fix": "Remove it."}`),
+			violation(`{"rule": "style", "file": "calc.go", "message": "not a rule"}`),
+		),
+		reply("2"),
+	)
+
+	result, err := Check(context.Background(), sampleDiff, Options{Client: client, Model: "m", Rules: DefaultRules})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(result.Violations) != 2 || result.Violations[0].Rule != RuleNoSecrets || result.Violations[1].Rule != RuleNoTODO {
+		t.Fatalf("violations = %+v", result.Violations)
+	}
+	if strings.Contains(prompt, "sk-abcdefghijklmnopqrstuvwx") || !strings.Contains(prompt, "no-todo: Added lines") {
+		t.Fatal("the prompt must list the rules and mask secrets")
+	}
+	out := Format(result)
+	if !strings.Contains(out, "[no-todo] calc.go:4") || !strings.Contains(out, "fix: Handle b == 0") || !strings.Contains(out, "--no-verify") {
+		t.Fatalf("Format = %q", out)
+	}
+
+	if result, err := Check(context.Background(), " \n", Options{Rules: DefaultRules}); err != nil || !result.Passed() {
+		t.Fatalf("empty diff: %+v, %v", result, err)
+	}
+}
+
+func TestInstall_KeepsForeignHooks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	path, err := Install(context.Background(), repo, false)
+	if err != nil || path != filepath.Join(repo, ".git", "hooks", "pre-commit") {
+		t.Fatalf("Install = %q, %v", path, err)
+	}
+	if _, err := Install(context.Background(), repo, false); err != nil {
+		t.Fatalf("reinstall: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(context.Background(), repo, false); err == nil {
+		t.Fatal("expected an error for an existing hook")
+	}
+	if _, err := Install(context.Background(), repo, true); err != nil {
+		t.Fatalf("forced install: %v", err)
+	}
+}
+
+func violation(args string) map[string]any {
+	return map[string]any{"name": "report_violation", "arguments": args}
+}
+
+func toolCalls(functions ...map[string]any) map[string]any {
+	calls := make([]map[string]any, len(functions))
+	for i, fn := range functions {
+		calls[i] = map[string]any{"id": "call-" + string(rune('a'+i)), "type": "function", "function": fn}
+	}
+	return completion("tool_calls", map[string]any{"role": "assistant", "content": "", "tool_calls": calls})
+}
+
+func reply(content string) map[string]any {
+	return completion("stop", map[string]any{"role": "assistant", "content": content})
+}
+
+func completion(finishReason string, message map[string]any) map[string]any {
+	return map[string]any{
+		"id": "mock-id", "object": "chat.completion", "created": 0, "model": "mock-model",
+		"choices": []map[string]any{{"index": 0, "finish_reason": finishReason, "message": message}},
+	}
+}
+
+// scriptedClient answers with responses in order and stores the body of
+// the first request in firstRequest.
+func scriptedClient(t *testing.T, firstRequest *string, responses ...map[string]any) *openai.Client {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if *firstRequest == "" {
+			body, _ := io.ReadAll(r.Body)
+			*firstRequest = string(body)
+		}
+		if len(responses) == 0 {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	t.Cleanup(srv.Close)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(srv.URL+"/v1/"), option.WithMaxRetries(0))
+	return &client
+}
@@ -0,0 +1,168 @@
+// Package profile switches the agent between the named profiles of the
+// config (model, system prompt, tool set and permission policy), chosen
+// with --profile at startup or /profile in the REPL.
+package profile
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+// Default names the setup without a profile.
+const Default = "default"
+
+// Set holds the profiles and which one is active.
+type Set struct {
+	profiles map[string]config.Profile
+	// check vets a profile before it becomes active; may be nil.
+	check func(config.Profile) error
+
+	mu     sync.Mutex
+	active string
+}
+
+// New returns a set of profiles with none active. check, when not nil, can
+// refuse a profile, e.g. ProfileSkip without an audit log.
+func New(profiles map[string]config.Profile, check func(config.Profile) error) *Set {
+	return &Set{profiles: profiles, check: check}
+}
+
+// Use activates the profile called name; Default or "" deactivates it.
+func (s *Set) Use(name string) error {
+	var p config.Profile
+	if name != "" && name != Default {
+		var ok bool
+		if p, ok = s.profiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q. available: %s", name, strings.Join(s.Names(), ", "))
+		}
+	}
+	if s.check != nil {
+		if err := s.check(p); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	if name == Default {
+		name = ""
+	}
+	s.mu.Lock()
+	s.active = name
+	s.mu.Unlock()
+	return nil
+}
+
+// Active returns the active profile and its name, which is empty when none
+// is active.
+func (s *Set) Active() (string, config.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, s.profiles[s.active]
+}
+
+// Names returns Default and the profile names in sorted order.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.profiles)+1)
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return append([]string{Default}, names...)
+}
+
+// Model returns the profile's model, or fallback when it sets none.
+func Model(p config.Profile, fallback string) string {
+	if p.Model != "" {
+		return p.Model
+	}
+	return fallback
+}
+
+// Tools narrows r to the profile's tools, and to the read-only ones under
+// ProfileReadOnly.
+func Tools(p config.Profile, r *tools.Registry) *tools.Registry {
+	if len(p.Tools) > 0 {
+		r = r.Only(p.Tools...)
+	}
+	if p.Permission == config.ProfileReadOnly {
+		r = tools.ReadOnly(r)
+	}
+	return r
+}
+
+// Approver returns the approver for the profile's permission policy: ask
+// for everything the profile's Allow rules do not cover, or nothing under
+// ProfileSkip.
+func Approver(p config.Profile, ask permission.Approver) permission.Approver {
+	switch {
+	case p.Permission == config.ProfileSkip:
+		return permission.Bypass
+	case len(p.Allow) > 0:
+		return permission.Remember(ask, p.Allow, nil)
+	}
+	return ask
+}
+
+// Command returns the /profile command, which lists the profiles or
+// switches to one. describe, when not nil, adds to the switch message, e.g.
+// a warning for ProfileSkip.
+func (s *Set) Command(describe func(name string, p config.Profile) string) command.Command {
+	return command.Command{
+		Name:        "profile",
+		Usage:       "[name]",
+		Description: "switch to a profile from the config; no name lists them",
+		Run: func(_ context.Context, args []string) (string, error) {
+			if len(args) == 0 {
+				return s.list(), nil
+			}
+			if err := s.Use(args[0]); err != nil {
+				return "", err
+			}
+			name, p := s.Active()
+			out := "Using profile " + args[0] + "."
+			if describe != nil {
+				if extra := describe(name, p); extra != "" {
+					out += "\n" + extra
+				}
+			}
+			return out, nil
+		},
+	}
+}
+
+func (s *Set) list() string {
+	active, _ := s.Active()
+	var b strings.Builder
+	b.WriteString("Profiles:")
+	for _, name := range s.Names() {
+		mark := " "
+		if name == active || name == Default && active == "" {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "\n %s %s", mark, name)
+		p := s.profiles[name]
+		var details []string
+		if p.Description != "" {
+			details = append(details, p.Description)
+		}
+		if p.Model != "" {
+			details = append(details, "model "+p.Model)
+		}
+		if p.Permission != "" {
+			details = append(details, "permission "+p.Permission)
+		}
+		if len(p.Tools) > 0 {
+			details = append(details, "tools "+strings.Join(p.Tools, ", "))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(&b, "  %s", strings.Join(details, "; "))
+		}
+	}
+	return b.String()
+}
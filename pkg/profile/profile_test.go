@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+func TestSet_SwitchesProfilesAndAppliesThem(t *testing.T) {
+	profiles := map[string]config.Profile{
+		"reviewer": {Model: "qwen-max", SystemPrompt: "Only review.", Permission: config.ProfileReadOnly},
+		"yolo":     {Permission: config.ProfileSkip},
+		"bash":     {Tools: []string{"bash"}, Allow: []permission.Rule{{Tool: "bash", Prefix: "go test"}}},
+	}
+	set := New(profiles, func(p config.Profile) error {
+		if p.Permission == config.ProfileSkip {
+			return errors.New("needs the audit log")
+		}
+		return nil
+	})
+	cmd := set.Command(nil)
+
+	if out, err := cmd.Run(context.Background(), []string{"reviewer"}); err != nil || out != "Using profile reviewer." {
+		t.Fatalf("/profile reviewer = %q, %v", out, err)
+	}
+	name, p := set.Active()
+	if name != "reviewer" || Model(p, "qwen-long") != "qwen-max" || p.SystemPrompt != "Only review." {
+		t.Fatalf("active = %s %+v", name, p)
+	}
+	if _, err := cmd.Run(context.Background(), []string{"yolo"}); err == nil || !strings.Contains(err.Error(), "audit log") {
+		t.Fatalf("switching to yolo: %v", err)
+	}
+	if _, err := cmd.Run(context.Background(), []string{"nope"}); err == nil || !strings.Contains(err.Error(), "available: default, bash, reviewer, yolo") {
+		t.Fatalf("unknown profile: %v", err)
+	}
+	if list, _ := cmd.Run(context.Background(), nil); !strings.Contains(list, "* reviewer  model qwen-max; permission read-only") || !strings.Contains(list, "  default") {
+		t.Fatalf("list:\n%s", list)
+	}
+
+	if err := set.Use(Default); err != nil {
+		t.Fatal(err)
+	}
+	if name, p := set.Active(); name != "" || Model(p, "qwen-long") != "qwen-long" {
+		t.Fatalf("default = %q %+v", name, p)
+	}
+
+	registry := tools.New()
+	registry.Register(tools.BashToolDef(), func(context.Context, map[string]any) (string, error) { return "ran", nil })
+	registry.Register(tools.CompactToolDef(), tools.NewCompactHandler())
+	if defs := Tools(profiles["bash"], registry).Definitions(); len(defs) != 1 || defs[0].Function.Name != "bash" {
+		t.Fatalf("bash profile tools = %v", defs)
+	}
+	if out, _ := Tools(profiles["reviewer"], registry).Dispatch(context.Background(), "bash", map[string]any{"command": "rm -rf x"}); !strings.Contains(out, "read-only mode") {
+		t.Fatalf("read-only profile ran a write: %q", out)
+	}
+
+	if Approver(profiles["yolo"], permission.DenyAll) != permission.Bypass {
+		t.Fatal("skip profile should bypass approval")
+	}
+	allowed, _ := Approver(profiles["bash"], permission.DenyAll).Approve(context.Background(), permission.Request{Tool: "bash", Command: "go test ./..."})
+	denied, _ := Approver(profiles["bash"], permission.DenyAll).Approve(context.Background(), permission.Request{Tool: "bash", Command: "rm x"})
+	if !allowed || denied {
+		t.Fatalf("profile allow rules: allowed=%v denied=%v", allowed, denied)
+	}
+}
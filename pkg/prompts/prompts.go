@@ -0,0 +1,206 @@
+// Package prompts loads the user's prompt templates: reusable task recipes
+// kept as Markdown files in .agent/prompts/. A template names its variables
+// as {{name}} and is rendered into the user message by /template:
+//
+//	.agent/prompts/fix-bug.md
+//	---
+//	description: Reproduce and fix a GitHub issue
+//	---
+//	Read issue #{{issue}}, write a failing test, then fix it.
+//
+//	/template fix-bug issue=123
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+)
+
+// DefaultDir is where templates live, relative to the project root.
+const DefaultDir = ".agent/prompts"
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// Template is one prompt template, named after its file.
+type Template struct {
+	Name        string
+	Description string
+	Body        string
+	// Vars are the variables Body uses, in order of first use.
+	Vars []string
+	Path string
+}
+
+// Library holds the templates of a directory.
+type Library struct {
+	templates map[string]Template
+	order     []string
+}
+
+// Load reads every *.md file in dir. A missing dir is an empty library.
+func Load(dir string) (*Library, error) {
+	lib := &Library{templates: make(map[string]Template)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		meta, body := parseFrontmatter(string(content))
+		t := Template{
+			Name:        strings.TrimSuffix(filepath.Base(path), ".md"),
+			Description: meta["description"],
+			Body:        body,
+			Path:        path,
+		}
+		for _, m := range placeholder.FindAllStringSubmatch(body, -1) {
+			if !slices.Contains(t.Vars, m[1]) {
+				t.Vars = append(t.Vars, m[1])
+			}
+		}
+		lib.templates[t.Name] = t
+		lib.order = append(lib.order, t.Name)
+	}
+	slices.Sort(lib.order)
+	return lib, nil
+}
+
+// Names returns the template names in sorted order.
+func (l *Library) Names() []string { return slices.Clone(l.order) }
+
+// Get returns the template called name.
+func (l *Library) Get(name string) (Template, bool) {
+	t, ok := l.templates[name]
+	return t, ok
+}
+
+// Render fills the variables of template name from values. Every variable
+// must be given and every value must be used, so typos are caught.
+func (l *Library) Render(name string, values map[string]string) (string, error) {
+	t, ok := l.templates[name]
+	if !ok {
+		available := strings.Join(l.order, ", ")
+		if available == "" {
+			available = "(none)"
+		}
+		return "", fmt.Errorf("unknown template %q. available: %s", name, available)
+	}
+	var missing []string
+	for _, v := range t.Vars {
+		if _, ok := values[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %s needs %s", name, strings.Join(missing, ", "))
+	}
+	for key := range values {
+		if !slices.Contains(t.Vars, key) {
+			return "", fmt.Errorf("template %s has no variable %q (variables: %s)", name, key, strings.Join(t.Vars, ", "))
+		}
+	}
+	return placeholder.ReplaceAllStringFunc(t.Body, func(m string) string {
+		return values[placeholder.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// ParseArgs reads key=value arguments. A word without "=" continues the
+// previous value, so values may contain spaces: note=flaky on CI.
+func ParseArgs(args []string) (map[string]string, error) {
+	values := make(map[string]string)
+	last := ""
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			if last == "" {
+				return nil, fmt.Errorf("argument %q: want name=value", arg)
+			}
+			values[last] += " " + arg
+			continue
+		}
+		values[key] = value
+		last = key
+	}
+	return values, nil
+}
+
+// Command returns the /template command. Templates are read from dir on
+// every use, so new and edited files apply without a restart. The rendered
+// prompt is handed to submit, which sends it as the user message.
+func Command(dir string, submit func(message string)) command.Command {
+	return command.Command{
+		Name:        "template",
+		Usage:       "[name [var=value ...]]",
+		Description: "send a prompt template from " + DefaultDir + " as the message; no name lists them",
+		Run: func(_ context.Context, args []string) (string, error) {
+			lib, err := Load(dir)
+			if err != nil {
+				return "", err
+			}
+			if len(args) == 0 {
+				return lib.list(), nil
+			}
+			values, err := ParseArgs(args[1:])
+			if err != nil {
+				return "", err
+			}
+			message, err := lib.Render(args[0], values)
+			if err != nil {
+				return "", err
+			}
+			submit(message)
+			return message, nil
+		},
+	}
+}
+
+func (l *Library) list() string {
+	if len(l.order) == 0 {
+		return "No templates in " + DefaultDir + "."
+	}
+	var b strings.Builder
+	b.WriteString("Templates:")
+	for _, name := range l.order {
+		t := l.templates[name]
+		usage := name
+		for _, v := range t.Vars {
+			usage += " " + v + "=…"
+		}
+		fmt.Fprintf(&b, "\n  %s", usage)
+		if t.Description != "" {
+			fmt.Fprintf(&b, "  %s", t.Description)
+		}
+	}
+	return b.String()
+}
+
+// parseFrontmatter splits an optional "---" block of key: value lines from
+// the body.
+func parseFrontmatter(text string) (map[string]string, string) {
+	normalized := strings.ReplaceAll(text, "\r\n", "\n")
+	meta := make(map[string]string)
+	rest, ok := strings.CutPrefix(normalized, "---\n")
+	if !ok {
+		return meta, strings.TrimSpace(normalized)
+	}
+	head, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return meta, strings.TrimSpace(normalized)
+	}
+	for _, line := range strings.Split(head, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			meta[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return meta, strings.TrimSpace(body)
+}
@@ -0,0 +1,50 @@
+package prompts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommand_RendersAndSubmitsTemplate(t *testing.T) {
+	dir := t.TempDir()
+	body := "---\ndescription: Fix an issue\n---\nRead issue #{{issue}} ({{ note }}), then fix #{{issue}}.\n"
+	if err := os.WriteFile(filepath.Join(dir, "fix-bug.md"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var submitted string
+	cmd := Command(dir, func(message string) { submitted = message })
+
+	list, err := cmd.Run(context.Background(), nil)
+	if err != nil || !strings.Contains(list, "fix-bug issue=… note=…  Fix an issue") {
+		t.Fatalf("list = %q, %v", list, err)
+	}
+
+	if _, err := cmd.Run(context.Background(), []string{"fix-bug", "issue=123", "note=flaky", "on", "CI"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Read issue #123 (flaky on CI), then fix #123."; submitted != want {
+		t.Fatalf("submitted %q, want %q", submitted, want)
+	}
+
+	for args, want := range map[string]string{
+		"fix-bug issue=1":               "needs note",
+		"fix-bug issue=1 note=x isue=2": `no variable "isue"`,
+		"fix-bug 123":                   "want name=value",
+		"fix-it":                        "available: fix-bug",
+	} {
+		if _, err := cmd.Run(context.Background(), strings.Fields(args)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("/template %s: error %v, want %q", args, err, want)
+		}
+	}
+}
+
+func TestLoad_MissingDirIsEmpty(t *testing.T) {
+	lib, err := Load(filepath.Join(t.TempDir(), "none"))
+	if err != nil || len(lib.Names()) != 0 {
+		t.Fatalf("Load = %v, %v", lib.Names(), err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package provider creates the chat client for the configured LLM backend:
+// DashScope (qwen) by default, Azure OpenAI, Gemini, DeepSeek or OpenRouter.
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/azure"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/deepseek"
+	"github.com/nickdu2009/learn-claude-code/pkg/gemini"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/openrouter"
+	"github.com/nickdu2009/learn-claude-code/pkg/qwen"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Name returns the selected backend: AGENT_PROVIDER, else cfg.Name, else "qwen".
+func Name(cfg config.Provider) string {
+	name := strings.TrimSpace(os.Getenv("AGENT_PROVIDER"))
+	if name == "" {
+		name = strings.TrimSpace(cfg.Name)
+	}
+	if name == "" {
+		return "qwen"
+	}
+	return strings.ToLower(name)
+}
+
+// New creates the client and default model for the selected backend. Extra
+// options, e.g. option.WithMiddleware, are applied after the defaults.
+func New(cfg config.Provider, opts ...option.RequestOption) (*openai.Client, string, error) {
+	switch name := Name(cfg); name {
+	case "qwen":
+		client, err := qwen.NewClient(opts...)
+		return client, qwen.Model(), err
+	case "azure":
+		azureCfg := AzureConfig(cfg.Azure)
+		client, err := azure.NewClient(azureCfg, opts...)
+		return client, azureCfg.Model(), err
+	case "gemini":
+		geminiCfg := GeminiConfig(cfg.Gemini)
+		client, err := gemini.NewClient(geminiCfg, opts...)
+		return client, geminiCfg.ModelName(), err
+	case "deepseek":
+		client, err := deepseek.NewClient(opts...)
+		return client, deepseek.Model(), err
+	case "openrouter":
+		routerCfg := OpenRouterConfig(cfg.OpenRouter)
+		client, err := openrouter.NewClient(routerCfg, opts...)
+		return client, routerCfg.ModelName(), err
+	default:
+		return nil, "", fmt.Errorf("unknown provider %q (want qwen, azure, gemini, deepseek or openrouter)", name)
+	}
+}
+
+// Fallbacks turns the configured fallback chain into llm targets. Entries
+// with a base URL get their own client, built with opts like the primary one;
+// config.Load keeps such entries from the user's settings only.
+func Fallbacks(cfg config.Provider, opts ...option.RequestOption) []llm.Target {
+	targets := make([]llm.Target, 0, len(cfg.Fallbacks))
+	for _, fb := range cfg.Fallbacks {
+		target := llm.Target{Model: strings.TrimSpace(fb.Model)}
+		if baseURL := strings.TrimSpace(fb.BaseURL); baseURL != "" {
+			clientOpts := []option.RequestOption{option.WithBaseURL(baseURL), option.WithHeaderDel("authorization")}
+			if fb.APIKeyEnv != "" {
+				clientOpts = append(clientOpts, option.WithAPIKey(os.Getenv(fb.APIKeyEnv)))
+			}
+			client := openai.NewClient(append(clientOpts, opts...)...)
+			target.Client = &client
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// PromptCache returns the interceptor marking the system prompt as
+// cacheable, and false when cfg.PromptCache leaves the prompt unmarked.
+func PromptCache(cfg config.Provider) (llm.Interceptor, bool) {
+	switch strings.ToLower(strings.TrimSpace(cfg.PromptCache)) {
+	case "explicit":
+		return llm.PromptCache(), true
+	case "off":
+		return llm.Interceptor{}, false
+	}
+	return llm.PromptCache(), Name(cfg) == "qwen"
+}
+
+// Capabilities reports what models support on the selected backend: the
+// built-in llm.ModelCapabilities, adjusted for what the backend passes
+// through, then cfg.Capabilities overrides keyed by model name or prefix.
+func Capabilities(cfg config.Provider) llm.CapabilityFunc {
+	name := Name(cfg)
+	return func(model string) llm.Capabilities {
+		caps := llm.ModelCapabilities(model)
+		if name == "gemini" {
+			// generateContent gets JSON mode but not the schema.
+			caps.JSONSchema = false
+		}
+
+		best, found := "", false
+		lower := strings.ToLower(model)
+		for key := range cfg.Capabilities {
+			if strings.HasPrefix(lower, strings.ToLower(key)) && (!found || len(key) > len(best)) {
+				best, found = key, true
+			}
+		}
+		if !found {
+			return caps
+		}
+		override := cfg.Capabilities[best]
+		setBool(&caps.Tools, override.Tools)
+		setBool(&caps.ParallelToolCalls, override.ParallelToolCalls)
+		setBool(&caps.Vision, override.Vision)
+		setBool(&caps.JSONMode, override.JSONMode)
+		setBool(&caps.JSONSchema, override.JSONSchema)
+		if override.MaxContextTokens != nil {
+			caps.MaxContextTokens = *override.MaxContextTokens
+		}
+		return caps
+	}
+}
+
+func setBool(dst *bool, v *bool) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// BreakerOptions returns the circuit breaker settings of cfg. Invalid
+// values, rejected by config validation, fall back to the defaults.
+func BreakerOptions(cfg config.Provider) llm.BreakerOptions {
+	coolDown, _ := cfg.CircuitBreaker.CoolDownDuration()
+	return llm.BreakerOptions{Failures: cfg.CircuitBreaker.Failures, CoolDown: coolDown}
+}
+
+// AzureConfig merges the config file's Azure section with the AZURE_OPENAI_*
+// environment; the environment wins so CI can override a checked-in config.
+func AzureConfig(c config.Azure) azure.Config {
+	cfg := azure.ConfigFromEnv()
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = strings.TrimSpace(c.Endpoint)
+	}
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = strings.TrimSpace(c.APIVersion)
+	}
+	if cfg.Deployment == "" {
+		cfg.Deployment = strings.TrimSpace(c.Deployment)
+	}
+	cfg.Deployments = c.Deployments
+	if strings.EqualFold(strings.TrimSpace(c.Auth), "aad") {
+		cfg.APIKey = ""
+		if cfg.Tokens == nil {
+			cfg.Tokens = azure.CLITokenSource()
+		}
+	}
+	return cfg
+}
+
+// GeminiConfig merges the config file's Gemini section with the
+// environment (see gemini.ConfigFromEnv); the environment wins.
+func GeminiConfig(c config.Gemini) gemini.Config {
+	cfg := gemini.ConfigFromEnv()
+	if cfg.Project == "" {
+		cfg.Project = strings.TrimSpace(c.Project)
+	}
+	if cfg.Location == "" {
+		cfg.Location = strings.TrimSpace(c.Location)
+	}
+	if cfg.Model == "" {
+		cfg.Model = strings.TrimSpace(c.Model)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = strings.TrimSpace(c.Endpoint)
+	}
+	return cfg
+}
+
+// OpenRouterConfig merges the config file's OpenRouter section with the
+// OPENROUTER_* environment; the environment wins.
+func OpenRouterConfig(c config.OpenRouter) openrouter.Config {
+	cfg := openrouter.ConfigFromEnv()
+	if cfg.Model == "" {
+		cfg.Model = strings.TrimSpace(c.Model)
+	}
+	cfg.Routing = openrouter.Routing{
+		Order:             c.Order,
+		AllowFallbacks:    c.AllowFallbacks,
+		Only:              c.Only,
+		Ignore:            c.Ignore,
+		Sort:              strings.TrimSpace(c.Sort),
+		RequireParameters: c.RequireParameters,
+		DataCollection:    strings.TrimSpace(c.DataCollection),
+	}
+	return cfg
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+func clearAzureEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"AGENT_PROVIDER", "AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_API_VERSION",
+		"AZURE_OPENAI_DEPLOYMENT", "AZURE_OPENAI_API_KEY", "AZURE_OPENAI_AD_TOKEN", "AZURE_OPENAI_AUTH"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestNew_SelectsAzureFromConfig(t *testing.T) {
+	clearAzureEnv(t)
+	t.Setenv("AZURE_OPENAI_API_KEY", "key")
+	cfg := config.Provider{Name: "azure", Azure: config.Azure{Endpoint: "https://x.openai.azure.com", Deployment: "gpt4o"}}
+
+	client, model, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if client == nil || model != "gpt4o" {
+		t.Fatalf("New = %v, %q", client, model)
+	}
+}
+
+func TestNew_EnvironmentOverridesConfig(t *testing.T) {
+	clearAzureEnv(t)
+	t.Setenv("AGENT_PROVIDER", "azure")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "from-env")
+	t.Setenv("AZURE_OPENAI_AD_TOKEN", "token")
+	cfg := config.Provider{Name: "qwen", Azure: config.Azure{Endpoint: "https://x.openai.azure.com", Deployment: "from-file"}}
+
+	_, model, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if model != "from-env" {
+		t.Fatalf("model = %q, want from-env", model)
+	}
+}
+
+func TestAzureConfig_AADIgnoresAPIKey(t *testing.T) {
+	clearAzureEnv(t)
+	t.Setenv("AZURE_OPENAI_API_KEY", "key")
+	cfg := AzureConfig(config.Azure{Endpoint: "https://x.openai.azure.com", Deployment: "d", Auth: "aad"})
+	if cfg.APIKey != "" || cfg.Tokens == nil {
+		t.Fatalf("aad config = %+v", cfg)
+	}
+}
+
+func TestNew_MissingAzureSettings(t *testing.T) {
+	clearAzureEnv(t)
+	if _, _, err := New(config.Provider{Name: "azure"}); err == nil {
+		t.Fatal("expected an error without an endpoint")
+	}
+}
+
+func TestNew_SelectsGeminiOnVertex(t *testing.T) {
+	for _, key := range []string{"AGENT_PROVIDER", "GOOGLE_CLOUD_PROJECT", "GOOGLE_CLOUD_LOCATION", "GEMINI_MODEL",
+		"GEMINI_API_KEY", "GOOGLE_API_KEY", "GOOGLE_OAUTH_ACCESS_TOKEN", "GEMINI_ENDPOINT"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("GEMINI_MODEL", "gemini-2.5-pro")
+	cfg := config.Provider{Name: "gemini", Gemini: config.Gemini{Project: "acme", Model: "gemini-2.5-flash"}}
+
+	client, model, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if client == nil || model != "gemini-2.5-pro" {
+		t.Fatalf("New = %v, %q", client, model)
+	}
+	if geminiCfg := GeminiConfig(cfg.Gemini); !geminiCfg.Vertex() || geminiCfg.Project != "acme" {
+		t.Fatalf("gemini config = %+v", geminiCfg)
+	}
+	t.Setenv("GEMINI_ENDPOINT", "https://gemini.internal.example")
+	cfg.Gemini.Endpoint = "https://from-config.example"
+	if geminiCfg := GeminiConfig(cfg.Gemini); geminiCfg.Endpoint != "https://gemini.internal.example" {
+		t.Fatalf("endpoint = %q, want the environment's", geminiCfg.Endpoint)
+	}
+
+	if _, _, err := New(config.Provider{Name: "gemini"}); err == nil {
+		t.Fatal("expected an error without a project or API key")
+	}
+}
+
+func TestNew_SelectsOpenRouterWithRouting(t *testing.T) {
+	for _, key := range []string{"AGENT_PROVIDER", "OPENROUTER_BASE_URL", "OPENROUTER_MODEL"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OPENROUTER_API_KEY", "or-key")
+	pinned := false
+	cfg := config.Provider{Name: "openrouter", OpenRouter: config.OpenRouter{Model: "qwen/qwen3-coder", Only: []string{"together"}, AllowFallbacks: &pinned}}
+
+	client, model, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if client == nil || model != "qwen/qwen3-coder" {
+		t.Fatalf("New = %v, %q", client, model)
+	}
+	if routing := OpenRouterConfig(cfg.OpenRouter).Routing; len(routing.Only) != 1 || routing.AllowFallbacks == nil || *routing.AllowFallbacks {
+		t.Fatalf("routing = %+v", routing)
+	}
+}
+
+func TestFallbacks_BuildsClientsForOtherEndpoints(t *testing.T) {
+	targets := Fallbacks(config.Provider{Fallbacks: []config.FallbackModel{
+		{Model: "qwen-plus"},
+		{Model: "llama3", BaseURL: "http://localhost:11434/v1"},
+	}})
+	if len(targets) != 2 || targets[0].Model != "qwen-plus" || targets[0].Client != nil {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if targets[1].Model != "llama3" || targets[1].Client == nil {
+		t.Fatalf("ollama target = %+v", targets[1])
+	}
+}
+
+func TestPromptCache_DefaultsByProvider(t *testing.T) {
+	clearAzureEnv(t)
+	for _, tc := range []struct {
+		cfg  config.Provider
+		want bool
+	}{
+		{config.Provider{}, true},
+		{config.Provider{Name: "azure"}, false},
+		{config.Provider{Name: "azure", PromptCache: "explicit"}, true},
+		{config.Provider{PromptCache: "off"}, false},
+	} {
+		if _, ok := PromptCache(tc.cfg); ok != tc.want {
+			t.Errorf("PromptCache(%+v) = %v, want %v", tc.cfg, ok, tc.want)
+		}
+	}
+}
+
+func TestCapabilities_AppliesOverrides(t *testing.T) {
+	t.Setenv("AGENT_PROVIDER", "")
+	no, window := false, 16384
+	cfg := config.Provider{Capabilities: map[string]config.Capabilities{
+		"my-llama":     {ParallelToolCalls: &no},
+		"my-llama-8b":  {Tools: &no, MaxContextTokens: &window},
+		"unrelated-xx": {Vision: &no},
+	}}
+
+	caps := Capabilities(cfg)("my-llama-8b-instruct")
+	if caps.Tools || !caps.ParallelToolCalls || caps.MaxContextTokens != window || !caps.JSONMode {
+		t.Fatalf("longest key should win: %+v", caps)
+	}
+	if caps := Capabilities(cfg)("my-llama-70b"); !caps.Tools || caps.ParallelToolCalls {
+		t.Fatalf("prefix override: %+v", caps)
+	}
+	if caps := Capabilities(cfg)("gpt-4o"); !caps.Vision || caps.MaxContextTokens != 128000 {
+		t.Fatalf("built-in capabilities: %+v", caps)
+	}
+
+	cfg.Name = "gemini"
+	if caps := Capabilities(cfg)("gemini-2.5-pro"); caps.JSONSchema || !caps.JSONMode {
+		t.Fatalf("gemini passes no response schema: %+v", caps)
+	}
+}
@@ -4,6 +4,7 @@ package qwen
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -12,10 +13,12 @@ import (
 const defaultModel = "qwen-plus"
 
 // NewClient creates an OpenAI client pointed at DashScope's compatible endpoint.
-// Required env vars: DASHSCOPE_API_KEY, DASHSCOPE_BASE_URL
-func NewClient() (*openai.Client, error) {
-	apiKey := os.Getenv("DASHSCOPE_API_KEY")
-	if apiKey == "" {
+// Required env vars: DASHSCOPE_API_KEY (or DASHSCOPE_API_KEYS), DASHSCOPE_BASE_URL
+// Several keys are rotated through a KeyPool.
+// Extra options, e.g. option.WithMiddleware, are applied after the defaults.
+func NewClient(opts ...option.RequestOption) (*openai.Client, error) {
+	keys := APIKeys()
+	if len(keys) == 0 {
 		return nil, fmt.Errorf("DASHSCOPE_API_KEY is not set")
 	}
 
@@ -24,13 +27,32 @@ func NewClient() (*openai.Client, error) {
 		return nil, fmt.Errorf("DASHSCOPE_BASE_URL is not set")
 	}
 
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-		option.WithBaseURL(baseURL),
-	)
+	defaults := []option.RequestOption{option.WithBaseURL(baseURL)}
+	if len(keys) == 1 {
+		defaults = append(defaults, option.WithAPIKey(keys[0]))
+	} else {
+		pool, err := NewKeyPool(keys)
+		if err != nil {
+			return nil, err
+		}
+		defaults = append(defaults, option.WithMiddleware(pool.Middleware))
+	}
+	client := openai.NewClient(append(defaults, opts...)...)
 	return &client, nil
 }
 
+// APIKeys returns the configured keys: DASHSCOPE_API_KEYS, else
+// DASHSCOPE_API_KEY, each a comma- or whitespace-separated list.
+func APIKeys() []string {
+	value := os.Getenv("DASHSCOPE_API_KEYS")
+	if strings.TrimSpace(value) == "" {
+		value = os.Getenv("DASHSCOPE_API_KEY")
+	}
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
 // Model returns the model name from env, falling back to defaultModel.
 func Model() string {
 	if m := os.Getenv("DASHSCOPE_MODEL"); m != "" {
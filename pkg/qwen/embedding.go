@@ -0,0 +1,82 @@
+package qwen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+const (
+	defaultEmbeddingModel = "text-embedding-v3"
+	// DashScope's compatible endpoint accepts at most 10 inputs per embedding request.
+	maxEmbeddingBatch = 10
+)
+
+// EmbeddingModel returns the embedding model name from env, falling back to defaultEmbeddingModel.
+func EmbeddingModel() string {
+	if m := os.Getenv("DASHSCOPE_EMBEDDING_MODEL"); m != "" {
+		return m
+	}
+	return defaultEmbeddingModel
+}
+
+// Embedder calls DashScope's OpenAI-compatible embeddings endpoint.
+type Embedder struct {
+	client *openai.Client
+	model  string
+}
+
+// NewEmbedder creates an Embedder. An empty model falls back to EmbeddingModel().
+func NewEmbedder(client *openai.Client, model string) *Embedder {
+	if strings.TrimSpace(model) == "" {
+		model = EmbeddingModel()
+	}
+	return &Embedder{client: client, model: model}
+}
+
+// Embeddings returns one vector per input text, in input order. Inputs are
+// split into provider-sized batches transparently.
+func (e *Embedder) Embeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if e == nil || e.client == nil {
+		return nil, fmt.Errorf("embedding client is not configured")
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("embedding input %d is empty", i)
+		}
+	}
+
+	vectors := make([][]float32, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		end := min(start+maxEmbeddingBatch, len(texts))
+		batch := texts[start:end]
+
+		resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Model:          openai.EmbeddingModel(e.model),
+			Input:          openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+			EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("embeddings request failed: %w", err)
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("embeddings response has %d vectors, want %d", len(resp.Data), len(batch))
+		}
+
+		for _, item := range resp.Data {
+			idx := int(item.Index)
+			if idx < 0 || idx >= len(batch) {
+				return nil, fmt.Errorf("embeddings response index %d out of range", item.Index)
+			}
+			vector := make([]float32, len(item.Embedding))
+			for i, value := range item.Embedding {
+				vector[i] = float32(value)
+			}
+			vectors[start+idx] = vector
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,95 @@
+package qwen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestEmbedder_EmbeddingsBatchesAndPreservesOrder(t *testing.T) {
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		if body.Model != "test-embed" {
+			t.Errorf("model = %q, want test-embed", body.Model)
+		}
+		batchSizes = append(batchSizes, len(body.Input))
+
+		// 故意倒序返回，验证按 index 回填
+		data := make([]map[string]any, 0, len(body.Input))
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			data = append(data, map[string]any{
+				"object":    "embedding",
+				"index":     i,
+				"embedding": []float64{float64(len(body.Input[i]))},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  body.Model,
+			"data":   data,
+			"usage":  map[string]any{"prompt_tokens": 1, "total_tokens": 1},
+		})
+	}))
+	defer server.Close()
+
+	embedder := NewEmbedder(newTestClient(server.URL), "test-embed")
+
+	texts := make([]string, 12)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	vectors, err := embedder.Embeddings(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embeddings: %v", err)
+	}
+
+	if len(batchSizes) != 2 || batchSizes[0] != 10 || batchSizes[1] != 2 {
+		t.Fatalf("batch sizes = %v, want [10 2]", batchSizes)
+	}
+	for i, vector := range vectors {
+		if len(vector) != 1 || vector[0] != float32(i+1) {
+			t.Fatalf("vector %d = %v, want [%d]", i, vector, i+1)
+		}
+	}
+}
+
+func TestEmbedder_EmbeddingsRejectsEmptyInput(t *testing.T) {
+	embedder := NewEmbedder(newTestClient("http://127.0.0.1:0"), "test-embed")
+
+	if _, err := embedder.Embeddings(context.Background(), []string{"ok", " "}); err == nil {
+		t.Fatal("expected empty input error")
+	}
+}
+
+func TestNewEmbedder_DefaultsModelFromEnv(t *testing.T) {
+	t.Setenv("DASHSCOPE_EMBEDDING_MODEL", "custom-embed")
+
+	embedder := NewEmbedder(newTestClient("http://127.0.0.1:0"), "")
+	if embedder.model != "custom-embed" {
+		t.Fatalf("model = %q, want custom-embed", embedder.model)
+	}
+}
+
+func newTestClient(baseURL string) *openai.Client {
+	client := openai.NewClient(
+		option.WithAPIKey("mock-key"),
+		option.WithBaseURL(baseURL),
+		option.WithMaxRetries(0),
+	)
+	return &client
+}
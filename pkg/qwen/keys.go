@@ -0,0 +1,214 @@
+package qwen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	// rateWindow is the span over which requests per key are counted.
+	rateWindow = time.Minute
+	// authQuarantine benches keys the provider rejected (401/403).
+	authQuarantine = time.Hour
+	// quotaQuarantine benches keys whose quota or balance ran out.
+	quotaQuarantine = 15 * time.Minute
+	// defaultCooldown benches a rate-limited key without a Retry-After.
+	defaultCooldown = 10 * time.Second
+	maxErrorBody    = 64 << 10
+)
+
+// quotaMarkers identify quota and billing errors in DashScope and OpenAI
+// error bodies, as opposed to ordinary rate limiting.
+var quotaMarkers = []string{"insufficient_quota", "arrearage", "quota"}
+
+// KeyPool spreads requests over several API keys. Each request uses the
+// healthy key with the fewest requests in the last minute; keys the provider
+// rejects are quarantined — for an hour after auth errors, 15 minutes after
+// quota errors, and for Retry-After after a plain 429 — and the request is
+// retried at once with the next key.
+type KeyPool struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	keys []*poolKey
+	next int
+}
+
+type poolKey struct {
+	key         string
+	recent      []time.Time
+	quarantined time.Time
+	reason      string
+}
+
+// KeyStats describes one key of a pool; Key is masked.
+type KeyStats struct {
+	Key               string
+	RequestsPerMinute int
+	QuarantinedUntil  time.Time
+	Reason            string
+}
+
+// NewKeyPool creates a pool over keys; blank and duplicate keys are dropped.
+func NewKeyPool(keys []string) (*KeyPool, error) {
+	p := &KeyPool{now: time.Now}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.keys = append(p.keys, &poolKey{key: key})
+	}
+	if len(p.keys) == 0 {
+		return nil, fmt.Errorf("no API keys configured")
+	}
+	return p, nil
+}
+
+// Len returns the number of keys in the pool.
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// Stats reports request rates and quarantines per key.
+func (p *KeyPool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	stats := make([]KeyStats, len(p.keys))
+	for i, k := range p.keys {
+		k.prune(now)
+		stats[i] = KeyStats{Key: maskKey(k.key), RequestsPerMinute: len(k.recent)}
+		if k.quarantined.After(now) {
+			stats[i].QuarantinedUntil, stats[i].Reason = k.quarantined, k.reason
+		}
+	}
+	return stats
+}
+
+// Middleware authenticates each request with a key from the pool.
+func (p *KeyPool) Middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	tried := make(map[*poolKey]bool)
+	for {
+		k := p.pick(tried)
+		tried[k] = true
+		req.Header.Set("Authorization", "Bearer "+k.key)
+		resp, err := next(req)
+		if err != nil || resp.StatusCode < 400 {
+			return resp, err
+		}
+
+		if !p.judge(k, resp) || len(tried) == len(p.keys) || req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+		req.Body = body
+	}
+}
+
+// pick chooses the least-used healthy key not yet tried for this request.
+// When every untried key is quarantined, the one released soonest is used.
+func (p *KeyPool) pick(tried map[*poolKey]bool) *poolKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+
+	var best, soonest *poolKey
+	for offset := range p.keys {
+		k := p.keys[(p.next+offset)%len(p.keys)]
+		if tried[k] {
+			continue
+		}
+		k.prune(now)
+		if k.quarantined.After(now) {
+			if soonest == nil || k.quarantined.Before(soonest.quarantined) {
+				soonest = k
+			}
+			continue
+		}
+		if best == nil || len(k.recent) < len(best.recent) {
+			best = k
+		}
+	}
+	if best == nil {
+		best = soonest
+	}
+	for i, k := range p.keys {
+		if k == best {
+			p.next = i + 1
+		}
+	}
+	best.recent = append(best.recent, now)
+	return best
+}
+
+// judge quarantines k when resp shows the key itself is the problem and
+// reports whether another key is worth trying.
+func (p *KeyPool) judge(k *poolKey, resp *http.Response) bool {
+	var bench time.Duration
+	var reason string
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		bench, reason = authQuarantine, fmt.Sprintf("auth error (%d)", resp.StatusCode)
+	case http.StatusTooManyRequests, http.StatusBadRequest:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		lower := strings.ToLower(string(body))
+		for _, marker := range quotaMarkers {
+			if strings.Contains(lower, marker) {
+				bench, reason = quotaQuarantine, fmt.Sprintf("quota exhausted (%d)", resp.StatusCode)
+				break
+			}
+		}
+		if bench == 0 && resp.StatusCode == http.StatusTooManyRequests {
+			bench, reason = retryAfter(resp.Header.Get("Retry-After")), "rate limited (429)"
+		}
+	}
+	if bench == 0 {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.quarantined, k.reason = p.now().Add(bench), reason
+	return true
+}
+
+func (k *poolKey) prune(now time.Time) {
+	cutoff := now.Add(-rateWindow)
+	drop := 0
+	for drop < len(k.recent) && !k.recent[drop].After(cutoff) {
+		drop++
+	}
+	k.recent = k.recent[drop:]
+}
+
+func retryAfter(value string) time.Duration {
+	if secs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultCooldown
+}
+
+// maskKey keeps enough of a key to tell keys apart in logs.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "…" + key[len(key)-4:]
+}
@@ -0,0 +1,130 @@
+package qwen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// keyedServer answers by API key: "sk-revoked-key" gets 401,
+// "sk-no-balance-k" a quota error, anything else a completion.
+func keyedServer(t *testing.T, used *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		*used = append(*used, key)
+		w.Header().Set("Content-Type", "application/json")
+		switch key {
+		case "sk-revoked-key":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":"invalid_api_key","message":"Incorrect API key"}}`))
+			return
+		case "sk-no-balance-k":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"code":"insufficient_quota","message":"You exceeded your current quota"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "m",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "ok"}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func poolClient(t *testing.T, pool *KeyPool, url string) openai.Client {
+	t.Helper()
+	return openai.NewClient(option.WithBaseURL(url+"/v1/"), option.WithMaxRetries(0), option.WithMiddleware(pool.Middleware))
+}
+
+func complete(t *testing.T, client openai.Client) {
+	t.Helper()
+	_, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "qwen-plus",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")},
+	})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+}
+
+func TestKeyPool_BalancesAcrossKeys(t *testing.T) {
+	var used []string
+	server := keyedServer(t, &used)
+	pool, err := NewKeyPool([]string{"sk-aaaaaaaa1", "sk-bbbbbbbb2", "sk-aaaaaaaa1", " "})
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+	if pool.Len() != 2 {
+		t.Fatalf("Len = %d, want duplicates and blanks dropped", pool.Len())
+	}
+	client := poolClient(t, pool, server.URL)
+	for i := 0; i < 4; i++ {
+		complete(t, client)
+	}
+	if got := strings.Join(used, ","); got != "sk-aaaaaaaa1,sk-bbbbbbbb2,sk-aaaaaaaa1,sk-bbbbbbbb2" {
+		t.Fatalf("keys used = %s", got)
+	}
+	for _, s := range pool.Stats() {
+		if s.RequestsPerMinute != 2 || strings.Contains(s.Key, "aaaaaaaa") {
+			t.Fatalf("stats = %+v", pool.Stats())
+		}
+	}
+}
+
+func TestKeyPool_QuarantinesFailingKeysAndRetries(t *testing.T) {
+	var used []string
+	server := keyedServer(t, &used)
+	pool, err := NewKeyPool([]string{"sk-revoked-key", "sk-no-balance-k", "sk-good-key-1"})
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	pool.now = func() time.Time { return now }
+	client := poolClient(t, pool, server.URL)
+
+	complete(t, client)
+	if got := strings.Join(used, ","); got != "sk-revoked-key,sk-no-balance-k,sk-good-key-1" {
+		t.Fatalf("keys used = %s", got)
+	}
+	stats := pool.Stats()
+	if !stats[0].QuarantinedUntil.Equal(now.Add(authQuarantine)) || !strings.Contains(stats[0].Reason, "auth") {
+		t.Fatalf("revoked key stats = %+v", stats[0])
+	}
+	if !stats[1].QuarantinedUntil.Equal(now.Add(quotaQuarantine)) || !strings.Contains(stats[1].Reason, "quota") {
+		t.Fatalf("exhausted key stats = %+v", stats[1])
+	}
+
+	// Quarantined keys are skipped until their quarantine ends.
+	used = nil
+	complete(t, client)
+	if strings.Join(used, ",") != "sk-good-key-1" {
+		t.Fatalf("keys used = %v", used)
+	}
+	now = now.Add(quotaQuarantine + time.Second)
+	used = nil
+	complete(t, client)
+	if strings.Join(used, ",") != "sk-no-balance-k,sk-good-key-1" {
+		t.Fatalf("keys used after quarantine = %v", used)
+	}
+}
+
+func TestAPIKeys_ParsesLists(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEYS", "")
+	t.Setenv("DASHSCOPE_API_KEY", "sk-one")
+	if got := APIKeys(); len(got) != 1 || got[0] != "sk-one" {
+		t.Fatalf("APIKeys = %v", got)
+	}
+	t.Setenv("DASHSCOPE_API_KEYS", "sk-a, sk-b\nsk-c")
+	if got := APIKeys(); strings.Join(got, ",") != "sk-a,sk-b,sk-c" {
+		t.Fatalf("APIKeys = %v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package readline
+
+import "bufio"
+
+type keyKind int
+
+const (
+	keyIgnore keyKind = iota
+	keyRune
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyKillLine
+	keyEsc
+	keyInterrupt
+	keyEOF
+)
+
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// controlKeys maps raw-mode control bytes to keys.
+var controlKeys = map[rune]keyKind{
+	'\r':   keyEnter,
+	'\n':   keyEnter,
+	'\t':   keyTab,
+	0x7f:   keyBackspace,
+	0x08:   keyBackspace, // Ctrl-H
+	0x01:   keyHome,      // Ctrl-A
+	0x05:   keyEnd,       // Ctrl-E
+	0x02:   keyLeft,      // Ctrl-B
+	0x06:   keyRight,     // Ctrl-F
+	0x10:   keyUp,        // Ctrl-P
+	0x0e:   keyDown,      // Ctrl-N
+	0x15:   keyKillLine,  // Ctrl-U
+	0x07:   keyEsc,       // Ctrl-G
+	0x03:   keyInterrupt, // Ctrl-C
+	0x04:   keyEOF,       // Ctrl-D
+	'\x1b': keyEsc,
+}
+
+// csiKeys maps the final byte of "ESC [ x" / "ESC O x" sequences.
+var csiKeys = map[rune]keyKind{
+	'A': keyUp,
+	'B': keyDown,
+	'C': keyRight,
+	'D': keyLeft,
+	'H': keyHome,
+	'F': keyEnd,
+}
+
+// tildeKeys maps the parameter of "ESC [ n ~" sequences.
+var tildeKeys = map[string]keyKind{
+	"1": keyHome,
+	"3": keyDelete,
+	"4": keyEnd,
+	"7": keyHome,
+	"8": keyEnd,
+}
+
+// readKey decodes the next key press. A lone Esc is told apart from an escape
+// sequence by whether more bytes arrived with it: terminals write a sequence
+// in one go.
+func readKey(br *bufio.Reader) (key, error) {
+	r, _, err := br.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if r == '\x1b' && br.Buffered() > 0 {
+		return readEscape(br)
+	}
+	if kind, ok := controlKeys[r]; ok {
+		return key{kind: kind}, nil
+	}
+	if r < 0x20 {
+		return key{kind: keyIgnore}, nil
+	}
+	return key{kind: keyRune, r: r}, nil
+}
+
+func readEscape(br *bufio.Reader) (key, error) {
+	intro, _, err := br.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if intro != '[' && intro != 'O' {
+		// Alt+key or an Esc typed just before another key.
+		_ = br.UnreadRune()
+		return key{kind: keyEsc}, nil
+	}
+
+	var params []rune
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		if r >= 0x40 && r <= 0x7e {
+			if r == '~' {
+				return key{kind: tildeKeys[string(params)]}, nil
+			}
+			return key{kind: csiKeys[r]}, nil
+		}
+		params = append(params, r)
+	}
+}
@@ -0,0 +1,361 @@
+// Package readline is the line editor behind the interactive REPLs. On a
+// terminal it switches stdin to raw mode (via stty) for in-line editing and an
+// @ file picker: typing "@" at the start of a word opens a fuzzy list of
+// candidates below the prompt; Up/Down (or Ctrl-P/Ctrl-N) select, Tab or Enter
+// inserts the path, Esc (or Ctrl-G) closes the list. When stdin is not a
+// terminal, or raw mode is unavailable, lines are read as plain text.
+// Between lines, WatchEsc reports Esc presses, e.g. to cancel a running tool.
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// ErrInterrupt is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupt = errors.New("interrupted")
+
+// maxCandidates is how many picker rows are drawn under the prompt.
+const maxCandidates = 8
+
+// Picker returns the candidates for the text typed after "@", best first.
+type Picker func(query string) []string
+
+// Reader reads edited lines from a terminal.
+type Reader struct {
+	in     *os.File
+	out    io.Writer
+	br     *bufio.Reader
+	picker Picker
+}
+
+// New creates a reader on in (usually os.Stdin) that echoes to out.
+func New(in *os.File, out io.Writer) *Reader {
+	return &Reader{in: in, out: out, br: bufio.NewReader(in)}
+}
+
+// SetPicker enables the @ picker; nil disables it.
+func (r *Reader) SetPicker(p Picker) {
+	r.picker = p
+}
+
+// ReadLine shows prompt and returns the line without its newline. It returns
+// io.EOF at end of input (Ctrl-D on an empty line) and ErrInterrupt on Ctrl-C.
+func (r *Reader) ReadLine(prompt string) (string, error) {
+	if isTerminal(r.in) {
+		if restore, err := makeRaw(r.in); err == nil {
+			defer restore()
+			return r.edit(prompt)
+		}
+	}
+	return r.readPlain(prompt)
+}
+
+func (r *Reader) readPlain(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.br.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// WatchEsc calls onEsc each time Esc is pressed until stop is called, e.g.
+// to cancel a running tool. Ctrl-C still ends the process, after restoring
+// the terminal. Reads time out every 100ms, so stop returns promptly without
+// leaving a read behind that would swallow the next key. It does nothing
+// when stdin is not a terminal.
+func (r *Reader) WatchEsc(onEsc func()) (stop func()) {
+	if !isTerminal(r.in) {
+		return func() {}
+	}
+	saved, err := stty(r.in, "-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty(r.in, "-icanon", "-echo", "min", "0", "time", "1"); err != nil {
+		return func() {}
+	}
+
+	// Ctrl-C would otherwise end the process with echo still off.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	var stopped atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64)
+		for !stopped.Load() {
+			select {
+			case <-interrupts:
+				_, _ = stty(r.in, strings.TrimSpace(saved))
+				fmt.Fprint(r.out, "\r\n")
+				os.Exit(130)
+			default:
+			}
+			n, err := r.in.Read(buf)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return
+			}
+			// A lone Esc; keys such as arrows send longer escape sequences.
+			if n == 1 && buf[0] == '\x1b' {
+				onEsc()
+			}
+		}
+	}()
+	return func() {
+		stopped.Store(true)
+		<-done
+		signal.Stop(interrupts)
+		_, _ = stty(r.in, strings.TrimSpace(saved))
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// makeRaw puts the terminal in raw mode and returns a function restoring the
+// previous settings. stty keeps this free of platform-specific ioctls.
+func makeRaw(f *os.File) (func(), error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { _, _ = stty(f, strings.TrimSpace(saved)) }, nil
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// edit runs the raw-mode editor until the line is submitted.
+func (r *Reader) edit(prompt string) (string, error) {
+	e := &editor{out: r.out, prompt: prompt, picker: r.picker, pickStart: -1}
+	e.render()
+	for {
+		k, err := readKey(r.br)
+		if err != nil {
+			e.closePicker()
+			e.render()
+			fmt.Fprint(e.out, "\r\n")
+			return "", err
+		}
+		if line, done, err := e.handle(k); done {
+			return line, err
+		}
+	}
+}
+
+// editor is the line-editing state machine; it only writes to out.
+type editor struct {
+	out    io.Writer
+	prompt string
+	picker Picker
+
+	buf []rune
+	pos int
+
+	// pickStart is the index of the "@" that opened the picker, or -1.
+	pickStart  int
+	candidates []string
+	selected   int
+}
+
+func (e *editor) handle(k key) (line string, done bool, err error) {
+	if e.pickStart >= 0 && e.handlePicker(k) {
+		e.render()
+		return "", false, nil
+	}
+
+	switch k.kind {
+	case keyRune:
+		opens := k.r == '@' && e.picker != nil && (e.pos == 0 || unicode.IsSpace(e.buf[e.pos-1]))
+		e.insert(k.r)
+		if opens {
+			e.pickStart = e.pos - 1
+			e.refreshCandidates()
+		}
+	case keyEnter:
+		return e.finish(string(e.buf), nil)
+	case keyInterrupt:
+		return e.finish("", ErrInterrupt)
+	case keyEOF:
+		if len(e.buf) == 0 {
+			return e.finish("", io.EOF)
+		}
+		e.deleteAt(e.pos)
+	case keyBackspace:
+		if e.pos > 0 {
+			e.pos--
+			e.deleteAt(e.pos)
+		}
+	case keyDelete:
+		e.deleteAt(e.pos)
+	case keyLeft:
+		e.pos = max(e.pos-1, 0)
+	case keyRight:
+		e.pos = min(e.pos+1, len(e.buf))
+	case keyHome:
+		e.pos = 0
+	case keyEnd:
+		e.pos = len(e.buf)
+	case keyKillLine:
+		e.buf = append([]rune(nil), e.buf[e.pos:]...)
+		e.pos = 0
+	}
+	e.render()
+	return "", false, nil
+}
+
+// handlePicker applies k while the picker is open. It returns false when k
+// should fall through to normal editing.
+func (e *editor) handlePicker(k key) bool {
+	switch k.kind {
+	case keyUp:
+		if n := len(e.visibleCandidates()); n > 0 {
+			e.selected = (e.selected + n - 1) % n
+		}
+		return true
+	case keyDown:
+		if n := len(e.visibleCandidates()); n > 0 {
+			e.selected = (e.selected + 1) % n
+		}
+		return true
+	case keyTab, keyEnter:
+		if len(e.candidates) == 0 {
+			e.closePicker()
+			return k.kind == keyTab
+		}
+		e.accept(e.candidates[e.selected])
+		return true
+	case keyEsc:
+		e.closePicker()
+		return true
+	case keyRune:
+		e.insert(k.r)
+		if unicode.IsSpace(k.r) {
+			e.closePicker()
+		} else {
+			e.refreshCandidates()
+		}
+		return true
+	case keyBackspace:
+		e.pos--
+		e.deleteAt(e.pos)
+		if e.pos <= e.pickStart {
+			e.closePicker()
+		} else {
+			e.refreshCandidates()
+		}
+		return true
+	default:
+		e.closePicker()
+		return false
+	}
+}
+
+// accept replaces the query after "@" with path and closes the picker.
+func (e *editor) accept(path string) {
+	tail := append([]rune(path+" "), e.buf[e.pos:]...)
+	e.buf = append(e.buf[:e.pickStart+1], tail...)
+	e.pos = e.pickStart + 1 + len([]rune(path)) + 1
+	e.closePicker()
+}
+
+func (e *editor) refreshCandidates() {
+	e.candidates = e.picker(string(e.buf[e.pickStart+1 : e.pos]))
+	e.selected = 0
+}
+
+func (e *editor) visibleCandidates() []string {
+	return e.candidates[:min(len(e.candidates), maxCandidates)]
+}
+
+func (e *editor) closePicker() {
+	e.pickStart, e.candidates, e.selected = -1, nil, 0
+}
+
+func (e *editor) insert(r rune) {
+	e.buf = append(e.buf[:e.pos], append([]rune{r}, e.buf[e.pos:]...)...)
+	e.pos++
+}
+
+func (e *editor) deleteAt(i int) {
+	if i >= 0 && i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *editor) finish(line string, err error) (string, bool, error) {
+	e.closePicker()
+	e.render()
+	fmt.Fprint(e.out, "\r\n")
+	return line, true, err
+}
+
+// render redraws the prompt, the line and any picker rows, then moves the
+// cursor back to its place in the line.
+func (e *editor) render() {
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+	b.WriteString(e.prompt)
+	b.WriteString(string(e.buf))
+	if e.pickStart >= 0 {
+		rows := e.visibleCandidates()
+		if len(rows) == 0 {
+			b.WriteString("\r\n  (no matching files)")
+		}
+		for i, c := range rows {
+			marker := "  "
+			if i == e.selected {
+				marker = "\x1b[7m>"
+			}
+			fmt.Fprintf(&b, "\r\n%s %s\x1b[0m", marker, c)
+		}
+		fmt.Fprintf(&b, "\x1b[%dA", max(len(rows), 1))
+	}
+	b.WriteString("\r")
+	if col := displayWidth(e.prompt) + displayWidth(string(e.buf[:e.pos])); col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	fmt.Fprint(e.out, b.String())
+}
+
+// displayWidth counts terminal columns, skipping ANSI escape sequences and
+// counting East Asian wide characters as two.
+func displayWidth(s string) int {
+	width, inEscape := 0, false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			inEscape = r < '@' || r > '~' || r == '['
+		case r == '\x1b':
+			inEscape = true
+		case unicode.IsControl(r):
+		case unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) || (r >= 0xFF00 && r <= 0xFF60):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,117 @@
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestReader(input string, picker Picker) *Reader {
+	return &Reader{out: &bytes.Buffer{}, br: bufio.NewReader(strings.NewReader(input)), picker: picker}
+}
+
+func fixedPicker(files ...string) Picker {
+	return func(query string) []string {
+		var out []string
+		for _, f := range files {
+			if strings.Contains(f, query) {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+}
+
+func TestEdit_CursorMovementAndEditing(t *testing.T) {
+	// "helo", Left, insert "l", End, "!", Home, Delete → "ello!"
+	r := newTestReader("helo\x1b[Dl\x05!\x01\x1b[3~\r", nil)
+
+	got, err := r.edit("> ")
+	if err != nil {
+		t.Fatalf("edit() error = %v", err)
+	}
+	if got != "ello!" {
+		t.Fatalf("edit() = %q, want %q", got, "ello!")
+	}
+}
+
+func TestEdit_PickerInsertsSelectedPath(t *testing.T) {
+	picker := fixedPicker("pkg/loop/agent.go", "pkg/loop/prune.go")
+	// Open the picker, filter by "loop", move down one, accept with Tab.
+	r := newTestReader("see @loop\x1b[B\tnow\r", picker)
+
+	got, err := r.edit("> ")
+	if err != nil {
+		t.Fatalf("edit() error = %v", err)
+	}
+	if want := "see @pkg/loop/prune.go now"; got != want {
+		t.Fatalf("edit() = %q, want %q", got, want)
+	}
+}
+
+func TestEdit_PickerOnlyOpensAtWordStart(t *testing.T) {
+	calls := 0
+	picker := func(string) []string { calls++; return []string{"x.go"} }
+	r := newTestReader("me@host\r", picker)
+
+	got, err := r.edit("> ")
+	if err != nil || got != "me@host" {
+		t.Fatalf("edit() = %q, %v", got, err)
+	}
+	if calls != 0 {
+		t.Fatalf("picker called %d times for an e-mail address", calls)
+	}
+}
+
+func TestEdit_EscClosesPickerKeepingText(t *testing.T) {
+	r := newTestReader("@ma\x07\r", fixedPicker("main.go"))
+
+	got, err := r.edit("> ")
+	if err != nil || got != "@ma" {
+		t.Fatalf("edit() = %q, %v; want %q", got, err, "@ma")
+	}
+}
+
+func TestEdit_EnterWithoutCandidatesSubmits(t *testing.T) {
+	r := newTestReader("@zzz\r", fixedPicker("main.go"))
+
+	got, err := r.edit("> ")
+	if err != nil || got != "@zzz" {
+		t.Fatalf("edit() = %q, %v; want %q", got, err, "@zzz")
+	}
+}
+
+func TestEdit_InterruptAndEOF(t *testing.T) {
+	if _, err := newTestReader("abc\x03", nil).edit("> "); !errors.Is(err, ErrInterrupt) {
+		t.Fatalf("Ctrl-C error = %v, want ErrInterrupt", err)
+	}
+	if _, err := newTestReader("\x04", nil).edit("> "); !errors.Is(err, io.EOF) {
+		t.Fatalf("Ctrl-D error = %v, want io.EOF", err)
+	}
+}
+
+func TestReadLine_PlainInputWhenNotATerminal(t *testing.T) {
+	r := newTestReader("first\r\nlast", nil)
+
+	for _, want := range []string{"first", "last"} {
+		got, err := r.ReadLine("> ")
+		if err != nil || got != want {
+			t.Fatalf("ReadLine() = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := r.ReadLine("> "); !errors.Is(err, io.EOF) {
+		t.Fatalf("ReadLine() at end error = %v, want io.EOF", err)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	if got := displayWidth("\x1b[36ms06 >> \x1b[0m"); got != 7 {
+		t.Fatalf("displayWidth(colored prompt) = %d, want 7", got)
+	}
+	if got := displayWidth("中文a"); got != 5 {
+		t.Fatalf("displayWidth(CJK) = %d, want 5", got)
+	}
+}
@@ -0,0 +1,259 @@
+// Package recap summarizes what one agent turn did to the workspace — files
+// created, modified and deleted with line counts, commands run and tokens
+// spent — so the user can audit the turn at a glance.
+//
+// File changes come from a git diff between working-tree snapshots taken at
+// the start and end of the turn (see snapshot.WorkingTree); commands come
+// from the audit log; tokens from a budget.Tracker attached to the turn.
+package recap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/snapshot"
+)
+
+// maxCommandChars clips long commands in the summary.
+const maxCommandChars = 100
+
+// FileChange is one file the turn created, modified or deleted.
+type FileChange struct {
+	Path string
+	// Status is "created", "modified" or "deleted".
+	Status  string
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// Command is one command the agent ran.
+type Command struct {
+	Command  string
+	ExitCode *int
+	Failed   bool
+}
+
+// Summary is what one turn did.
+type Summary struct {
+	Files []FileChange
+	// FilesErr explains why Files could not be computed, e.g. outside git.
+	FilesErr error
+	Commands []Command
+	Usage    budget.Usage
+}
+
+// Mutated reports whether the turn changed the workspace. When file changes
+// are unknown, any command counts as a possible change.
+func (s Summary) Mutated() bool {
+	if s.FilesErr != nil {
+		return len(s.Commands) > 0
+	}
+	return len(s.Files) > 0
+}
+
+// String renders the summary block.
+func (s Summary) String() string {
+	var b strings.Builder
+	b.WriteString("── turn summary ──\n")
+	switch {
+	case s.FilesErr != nil:
+		fmt.Fprintf(&b, "files: unavailable (%v)\n", s.FilesErr)
+	case len(s.Files) == 0:
+		b.WriteString("files: no changes\n")
+	default:
+		var added, deleted int
+		for _, f := range s.Files {
+			added += f.Added
+			deleted += f.Deleted
+		}
+		fmt.Fprintf(&b, "files: %d changed (+%d -%d)\n", len(s.Files), added, deleted)
+		for _, f := range s.Files {
+			counts := fmt.Sprintf("+%d -%d", f.Added, f.Deleted)
+			if f.Binary {
+				counts = "binary"
+			}
+			fmt.Fprintf(&b, "  %-8s %s (%s)\n", f.Status, f.Path, counts)
+		}
+	}
+	if len(s.Commands) > 0 {
+		fmt.Fprintf(&b, "commands: %d\n", len(s.Commands))
+		for _, c := range s.Commands {
+			status := ""
+			switch {
+			case c.ExitCode != nil && *c.ExitCode != 0:
+				status = fmt.Sprintf(" [exit %d]", *c.ExitCode)
+			case c.Failed:
+				status = " [failed]"
+			}
+			fmt.Fprintf(&b, "  $ %s%s\n", clip(c.Command), status)
+		}
+	}
+	fmt.Fprintf(&b, "tokens: %d (prompt %d, completion %d)", s.Usage.TotalTokens(), s.Usage.PromptTokens, s.Usage.CompletionTokens)
+	if s.Usage.Cost > 0 {
+		fmt.Fprintf(&b, ", cost≈%s", s.Usage.CostString())
+	}
+	if cache := s.Usage.CacheString(); cache != "" {
+		fmt.Fprintf(&b, ", %s", cache)
+	}
+	return b.String()
+}
+
+func clip(command string) string {
+	command = strings.Join(strings.Fields(command), " ")
+	if runes := []rune(command); len(runes) > maxCommandChars {
+		return string(runes[:maxCommandChars-3]) + "..."
+	}
+	return command
+}
+
+// Turn tracks one turn from Begin to End.
+type Turn struct {
+	repo      string
+	auditPath string
+	offset    int64
+	tree      string
+	treeErr   error
+	tracker   *budget.Tracker
+	// baseline is the outer tracker's usage when the turn started.
+	baseline budget.Usage
+}
+
+// Begin snapshots the workspace of repo and remembers where the audit log at
+// auditPath ends. auditPath may be empty when nothing is audited.
+func Begin(ctx context.Context, repo, auditPath string) *Turn {
+	t := &Turn{repo: repo, auditPath: auditPath, tracker: budget.New(budget.Limits{}, budget.Pricing{})}
+	t.tree, t.treeErr = snapshot.WorkingTree(ctx, repo)
+	if auditPath != "" {
+		if info, err := os.Stat(auditPath); err == nil {
+			t.offset = info.Size()
+		}
+	}
+	return t
+}
+
+// Context attaches the turn's token tracker to ctx; run the turn with it.
+// A tracker already on ctx (e.g. one enforcing a task budget) is kept and
+// the turn reports how much its usage grew.
+func (t *Turn) Context(ctx context.Context) context.Context {
+	if outer := budget.TrackerFrom(ctx); outer != nil {
+		t.tracker, t.baseline = outer, outer.Usage()
+		return ctx
+	}
+	return budget.WithTracker(ctx, t.tracker)
+}
+
+// End computes the summary of the turn.
+func (t *Turn) End(ctx context.Context) Summary {
+	s := Summary{Usage: t.tracker.Usage().Sub(t.baseline)}
+	s.Files, s.FilesErr = t.files(ctx)
+	s.Commands = t.commands()
+	return s
+}
+
+func (t *Turn) files(ctx context.Context) ([]FileChange, error) {
+	if t.treeErr != nil {
+		return nil, fmt.Errorf("no workspace snapshot: %w", t.treeErr)
+	}
+	end, err := snapshot.WorkingTree(ctx, t.repo)
+	if err != nil {
+		return nil, err
+	}
+	if end == t.tree {
+		return nil, nil
+	}
+	return diffTrees(ctx, t.repo, t.tree, end)
+}
+
+// diffTrees lists the files that differ between two trees with line counts.
+func diffTrees(ctx context.Context, repo, from, to string) ([]FileChange, error) {
+	status, err := git(ctx, repo, "diff", "--name-status", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := git(ctx, repo, "diff", "--numstat", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// --name-status -z: "M\0path\0" pairs.
+	var files []FileChange
+	fields := strings.Split(strings.TrimSuffix(status, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		change := FileChange{Path: fields[i+1], Status: "modified"}
+		switch fields[i] {
+		case "A":
+			change.Status = "created"
+		case "D":
+			change.Status = "deleted"
+		}
+		files = append(files, change)
+	}
+
+	// --numstat -z: "added\tdeleted\tpath\0"; binary files report "-".
+	counts := make(map[string][3]int)
+	for _, record := range strings.Split(strings.TrimSuffix(numstat, "\x00"), "\x00") {
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		added, errA := strconv.Atoi(parts[0])
+		deleted, errD := strconv.Atoi(parts[1])
+		binary := 0
+		if errA != nil || errD != nil {
+			binary = 1
+		}
+		counts[parts[2]] = [3]int{added, deleted, binary}
+	}
+	for i := range files {
+		c := counts[files[i].Path]
+		files[i].Added, files[i].Deleted, files[i].Binary = c[0], c[1], c[2] == 1
+	}
+	return files, nil
+}
+
+func (t *Turn) commands() []Command {
+	if t.auditPath == "" {
+		return nil
+	}
+	f, err := os.Open(t.auditPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil
+	}
+	// A torn last line still yields the records before it.
+	records, _ := audit.ReadRecords(f)
+
+	var commands []Command
+	for _, rec := range records {
+		command, ok := rec.Args["command"].(string)
+		if !ok || strings.TrimSpace(command) == "" {
+			continue
+		}
+		commands = append(commands, Command{Command: command, ExitCode: rec.ExitCode, Failed: rec.Status == "error"})
+	}
+	return commands
+}
+
+func git(ctx context.Context, repo string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repo
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,135 @@
+package recap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+)
+
+func TestTurn_SummarizesFilesCommandsAndTokens(t *testing.T) {
+	repo := initRepo(t)
+	logger, err := audit.Open(t.TempDir(), "session")
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer logger.Close()
+	// Records from earlier turns are not part of this one.
+	if err := logger.Write(audit.Record{Tool: "bash", Args: map[string]any{"command": "echo earlier"}, Status: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	turn := Begin(ctx, repo, logger.Path())
+	turnCtx := turn.Context(ctx)
+
+	writeFile(t, repo, "keep.txt", "one\nTWO\nthree\nfour\n")
+	writeFile(t, repo, "new.txt", "hello\n")
+	if err := os.Remove(filepath.Join(repo, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	exit := 2
+	_ = logger.Write(audit.Record{Tool: "bash", Args: map[string]any{"command": "go test ./..."}, Status: "ok", ExitCode: &exit})
+	_ = logger.Write(audit.Record{Tool: "read_file", Args: map[string]any{"path": "keep.txt"}, Status: "ok"})
+	budget.TrackerFrom(turnCtx).Record(1200, 300)
+
+	s := turn.End(ctx)
+	if !s.Mutated() {
+		t.Fatal("Mutated() = false")
+	}
+	want := map[string]FileChange{
+		"keep.txt": {Path: "keep.txt", Status: "modified", Added: 2, Deleted: 1},
+		"new.txt":  {Path: "new.txt", Status: "created", Added: 1},
+		"old.txt":  {Path: "old.txt", Status: "deleted", Deleted: 1},
+	}
+	if len(s.Files) != len(want) {
+		t.Fatalf("Files = %+v", s.Files)
+	}
+	for _, f := range s.Files {
+		if f != want[f.Path] {
+			t.Errorf("file %s = %+v, want %+v", f.Path, f, want[f.Path])
+		}
+	}
+	if len(s.Commands) != 1 || s.Commands[0].Command != "go test ./..." {
+		t.Fatalf("Commands = %+v", s.Commands)
+	}
+
+	out := s.String()
+	for _, line := range []string{
+		"files: 3 changed (+3 -2)",
+		"  created  new.txt (+1 -0)",
+		"  $ go test ./... [exit 2]",
+		"tokens: 1500 (prompt 1200, completion 300)",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("summary missing %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestTurn_NoChanges(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+	turn := Begin(ctx, repo, "")
+	if s := turn.End(ctx); s.Mutated() || s.FilesErr != nil {
+		t.Fatalf("summary = %+v, want no mutation", s)
+	}
+}
+
+func TestTurn_OuterTrackerReportsDelta(t *testing.T) {
+	tracker := budget.New(budget.Limits{}, budget.Pricing{InputPer1K: 0.01})
+	tracker.Record(1000, 100)
+	ctx := budget.WithTracker(context.Background(), tracker)
+
+	turn := Begin(ctx, t.TempDir(), "")
+	turnCtx := turn.Context(ctx)
+	if budget.TrackerFrom(turnCtx) != tracker {
+		t.Fatal("outer tracker was replaced")
+	}
+	tracker.Record(50, 5)
+
+	s := turn.End(ctx)
+	if s.Usage.TotalTokens() != 55 {
+		t.Fatalf("turn tokens = %d, want 55", s.Usage.TotalTokens())
+	}
+	if !strings.HasSuffix(s.String(), "tokens: 55 (prompt 50, completion 5), cost≈0.0005") {
+		t.Fatalf("summary = %q", s.String())
+	}
+	if s.FilesErr == nil || s.Mutated() {
+		t.Fatalf("outside git: FilesErr = %v, Mutated = %v", s.FilesErr, s.Mutated())
+	}
+}
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "keep.txt", "one\ntwo\nthree\n")
+	writeFile(t, repo, "old.txt", "bye\n")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return repo
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+// Package redact masks secrets (API keys, cloud credentials, tokens, private
+// keys) in text before it reaches the model, the terminal or logs.
+//
+// Well-known credential formats are matched by pattern. Values assigned to
+// secret-looking names (API_KEY=..., "password": ...) are masked when they
+// look random enough, so reading a .env file does not leak it while ordinary
+// code such as `token = cfg.Token` is left alone.
+package redact
+
+import (
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// rule masks every match of pattern. When group is non-zero only that
+// capture group is replaced, keeping the surrounding text for context.
+type rule struct {
+	kind    string
+	pattern *regexp.Regexp
+	group   int
+}
+
+var rules = []rule{
+	{kind: "private-key", pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{kind: "aws-access-key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "aws-secret-key", pattern: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), group: 1},
+	{kind: "github-token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "slack-token", pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{kind: "google-api-key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "stripe-key", pattern: regexp.MustCompile(`\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}\b`)},
+	{kind: "api-key", pattern: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{kind: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "bearer-token", pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{20,}=*)`), group: 1},
+	{kind: "url-password", pattern: regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^:/\s@]+:([^@\s/]+)@`), group: 1},
+}
+
+// assignment matches NAME=value / "name": "value" pairs whose name ends in a
+// secret-suggesting word (API_KEY, client_secret, DB_PASSWORD, ...). The value
+// is checked with looksSecret before masking.
+var assignment = regexp.MustCompile(
+	`(?i)([A-Za-z0-9_.-]*(?:key|token|secret|passw(?:or)?d|pwd|credentials?|auth)["']?\s*[:=]\s*["']?)([^\s"'()\[\]{};,<>]{8,})`,
+)
+
+// placeholder starts every mask; the secret's kind and "]" follow.
+const placeholder = "[REDACTED"
+
+// Text returns s with secrets replaced by "[REDACTED:<kind>]": known credential
+// formats first, then secret-looking assignments.
+func Text(s string) string {
+	for _, r := range rules {
+		s = replace(s, r)
+	}
+	return maskAssignments(s)
+}
+
+func replace(s string, r rule) string {
+	masked := placeholder + ":" + r.kind + "]"
+	if r.group == 0 {
+		return r.pattern.ReplaceAllString(s, masked)
+	}
+	return r.pattern.ReplaceAllStringFunc(s, func(match string) string {
+		sub := r.pattern.FindStringSubmatchIndex(match)
+		start, end := sub[2*r.group], sub[2*r.group+1]
+		return match[:start] + masked + match[end:]
+	})
+}
+
+func maskAssignments(s string) string {
+	return assignment.ReplaceAllStringFunc(s, func(match string) string {
+		sub := assignment.FindStringSubmatch(match)
+		value := sub[2]
+		if !looksSecret(value) {
+			return match
+		}
+		return sub[1] + placeholder + ":secret]"
+	})
+}
+
+// looksSecret reports whether an assigned value is random enough to be a
+// credential: it contains a digit or is long, and has high character entropy.
+func looksSecret(value string) bool {
+	if !strings.ContainsAny(value, "0123456789") && len(value) < 20 {
+		return false
+	}
+	return entropy(value) >= 3.0
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+var (
+	enabledOnce sync.Once
+	enabled     bool
+)
+
+// Enabled reports whether tool output should be redacted. It is on unless
+// AGENT_REDACT_SECRETS is set to 0, false or off.
+func Enabled() bool {
+	enabledOnce.Do(func() {
+		switch strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_REDACT_SECRETS"))) {
+		case "0", "false", "off", "no":
+			enabled = false
+		default:
+			enabled = true
+		}
+	})
+	return enabled
+}
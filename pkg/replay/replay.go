@@ -0,0 +1,146 @@
+// Package replay steps through a recorded conversation, assistant message by
+// assistant message, to debug what the agent did on a past run. Tool calls
+// show their recorded results and can be executed again to see whether the
+// tools still behave the same.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// Call is one tool call the model made.
+type Call struct {
+	ID        string
+	Name      string
+	Arguments string
+	// Recorded is the result stored in the conversation. Missing is set when
+	// the conversation ends before the result, e.g. after a crash.
+	Recorded string
+	Missing  bool
+}
+
+// Step is one assistant message with the tool calls it made.
+type Step struct {
+	// Number counts steps from 1.
+	Number int
+	// Message is the index of the assistant message in the conversation.
+	Message int
+	// Prompt is the user message that started the turn; it is set on the
+	// first step of each turn only.
+	Prompt string
+	Text   string
+	Calls  []Call
+}
+
+// Load reads a conversation from a session file (see pkg/session) or from a
+// bare JSON array of messages such as an eval transcript.
+func Load(path string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Sessions may be encrypted at rest, see package atrest.
+	c, err := atrest.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = c.Open(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var messages []openai.ChatCompletionMessageParamUnion
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &messages)
+	} else {
+		var sess session.Session
+		err = json.Unmarshal(data, &sess)
+		messages = sess.Messages
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// Steps splits a conversation into steps.
+func Steps(messages []openai.ChatCompletionMessageParamUnion) []Step {
+	results := make(map[string]string)
+	for _, msg := range messages {
+		if msg.OfTool != nil {
+			results[msg.OfTool.ToolCallID] = msg.OfTool.Content.OfString.Value
+		}
+	}
+
+	var (
+		steps  []Step
+		prompt string
+	)
+	for i, msg := range messages {
+		switch {
+		case msg.OfUser != nil:
+			prompt = msg.OfUser.Content.OfString.Value
+		case msg.OfAssistant != nil:
+			step := Step{
+				Number:  len(steps) + 1,
+				Message: i,
+				Prompt:  prompt,
+				Text:    msg.OfAssistant.Content.OfString.Value,
+			}
+			prompt = ""
+			for _, tc := range msg.OfAssistant.ToolCalls {
+				recorded, ok := results[tc.ID]
+				step.Calls = append(step.Calls, Call{
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+					Recorded:  recorded,
+					Missing:   !ok,
+				})
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Result is the outcome of executing a call again.
+type Result struct {
+	Output string
+	Err    error
+	// Diverged reports that the output differs from the recorded result.
+	Diverged bool
+}
+
+// Execute runs the calls of step again through registry, in order. Run it
+// in a scratch copy of the workspace: the calls may write files and run
+// commands.
+func Execute(ctx context.Context, registry *tools.Registry, step Step) []Result {
+	results := make([]Result, len(step.Calls))
+	for i, call := range step.Calls {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			results[i] = Result{Err: fmt.Errorf("invalid arguments: %w", err), Diverged: true}
+			continue
+		}
+		output, err := registry.Dispatch(ctx, call.Name, args)
+		// loop.Run records failures as "error: ..." results.
+		got := output
+		if err != nil {
+			got = "error: " + err.Error()
+		}
+		results[i] = Result{
+			Output:   output,
+			Err:      err,
+			Diverged: call.Missing || strings.TrimSpace(got) != strings.TrimSpace(call.Recorded),
+		}
+	}
+	return results
+}
@@ -0,0 +1,98 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+func toolCall(id, name, args string) openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+			ID:       id,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: name, Arguments: args},
+		}},
+	}}
+}
+
+func conversation() []openai.ChatCompletionMessageParamUnion {
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("fix it"),
+		toolCall("c1", "echo", `{"text":"a"}`),
+		openai.ToolMessage("a", "c1"),
+		toolCall("c2", "fail", `{}`),
+		openai.ToolMessage("error: boom", "c2"),
+		openai.AssistantMessage("done"),
+		openai.UserMessage("again"),
+		toolCall("c3", "echo", `{"text":"b"}`),
+	}
+}
+
+func TestSteps_GroupsCallsWithRecordedResults(t *testing.T) {
+	steps := Steps(conversation())
+	if len(steps) != 4 {
+		t.Fatalf("got %d steps", len(steps))
+	}
+	if steps[0].Prompt != "fix it" || steps[1].Prompt != "" || steps[3].Prompt != "again" {
+		t.Fatalf("prompts: %q %q %q", steps[0].Prompt, steps[1].Prompt, steps[3].Prompt)
+	}
+	if c := steps[0].Calls[0]; c.Name != "echo" || c.Recorded != "a" || c.Missing || steps[0].Message != 2 {
+		t.Fatalf("step 1 = %+v", steps[0])
+	}
+	if steps[2].Text != "done" || len(steps[2].Calls) != 0 {
+		t.Fatalf("step 3 = %+v", steps[2])
+	}
+	if !steps[3].Calls[0].Missing {
+		t.Fatal("the last call has no recorded result")
+	}
+}
+
+func TestExecute_ReportsDivergence(t *testing.T) {
+	registry := tools.New()
+	def := func(name string) openai.ChatCompletionToolParam {
+		return openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: name}}
+	}
+	registry.Register(def("echo"), func(_ context.Context, args map[string]any) (string, error) {
+		return "now " + args["text"].(string), nil
+	})
+	registry.Register(def("fail"), func(context.Context, map[string]any) (string, error) {
+		return "", errors.New("boom")
+	})
+
+	steps := Steps(conversation())
+	if r := Execute(context.Background(), registry, steps[0]); !r[0].Diverged || r[0].Output != "now a" {
+		t.Fatalf("echo = %+v", r[0])
+	}
+	if r := Execute(context.Background(), registry, steps[1]); r[0].Diverged || r[0].Err == nil {
+		t.Fatalf("fail = %+v, want the recorded error again", r[0])
+	}
+}
+
+func TestLoad_ReadsSessionsAndTranscripts(t *testing.T) {
+	dir := t.TempDir()
+	sessionData, _ := json.Marshal(session.Session{ID: "s1", Messages: conversation()})
+	transcriptData, _ := json.Marshal(conversation())
+	key := atrest.NewKey()
+	t.Setenv(atrest.KeyEnv, key)
+	c, _ := atrest.New(key)
+	for name, data := range map[string][]byte{"session.json": sessionData, "transcript.json": transcriptData, "sealed.json": c.Seal(sessionData)} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		messages, err := Load(path)
+		if err != nil || len(Steps(messages)) != 4 {
+			t.Fatalf("%s: %d messages, %v", name, len(messages), err)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+// Package repomap builds a compact, ranked map of a Go repository — packages,
+// their internal imports and exported symbols — to prime the system prompt so
+// the model knows where things live before it starts exploring.
+//
+// Packages are ranked by how many other packages in the module import them,
+// so the core libraries come first and leaf commands last. The map is cut to
+// a token budget: every package gets a line before any symbols are added,
+// and whatever does not fit is summarized as "+N more".
+package repomap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+)
+
+// DefaultMaxTokens is the map budget when Options.MaxTokens is zero.
+const DefaultMaxTokens = 2000
+
+// maxSymbolChars clips long signatures.
+const maxSymbolChars = 120
+
+// Options configures Build.
+type Options struct {
+	// MaxTokens caps the rendered map; 0 means DefaultMaxTokens.
+	MaxTokens int
+	// Counter measures the map; nil means tokens.Default().
+	Counter tokens.Counter
+}
+
+// Package is one Go package directory in the map.
+type Package struct {
+	// Dir is slash-separated and relative to the root ("." for the root).
+	Dir  string
+	Name string
+	// Imports are the directories of the module's own packages it imports.
+	Imports []string
+	// ImportedBy counts the module's packages that import this one.
+	ImportedBy int
+	Files      []File
+}
+
+// File is a non-test Go source file and its exported symbols.
+type File struct {
+	Name    string
+	Symbols []string
+}
+
+// Build maps the repository at root and renders it within the token budget.
+// It returns "" when root contains no Go packages.
+func Build(ctx context.Context, root string, opts Options) (string, error) {
+	pkgs, err := Scan(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	return Render(pkgs, opts), nil
+}
+
+// Scan parses the Go files under root (respecting .gitignore) and returns the
+// packages in rank order: most imported first, then by directory.
+func Scan(ctx context.Context, root string) ([]Package, error) {
+	m := NewMap(root)
+	if err := m.Load(ctx); err != nil {
+		return nil, err
+	}
+	return m.Packages(), nil
+}
+
+// Map keeps parsed files between renders so a long session can keep its
+// repo map fresh by reparsing only the files that changed.
+type Map struct {
+	root   string
+	module string
+
+	mu    sync.Mutex
+	files map[string]parsedFile
+}
+
+type parsedFile struct {
+	pkg     string
+	imports []string
+	symbols []string
+}
+
+// NewMap creates an empty map of root; call Load or Watch to fill it.
+func NewMap(root string) *Map {
+	return &Map{root: root, module: modulePath(root), files: make(map[string]parsedFile)}
+}
+
+// Load parses every Go file under root, replacing what the map held.
+func (m *Map) Load(ctx context.Context) error {
+	files, err := fileindex.List(ctx, m.root)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]parsedFile)
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f, ok := m.parse(rel); ok {
+			parsed[rel] = f
+		}
+	}
+	m.mu.Lock()
+	m.files = parsed
+	m.mu.Unlock()
+	return nil
+}
+
+// Update reparses changed files and forgets removed ones.
+func (m *Map) Update(changes []fileindex.Change) {
+	for _, change := range changes {
+		f, ok := m.parse(change.Path)
+		m.mu.Lock()
+		if ok && change.Op != fileindex.Removed {
+			m.files[change.Path] = f
+		} else {
+			delete(m.files, change.Path)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Watch loads the map from w's first poll and applies its changes after.
+func (m *Map) Watch(w *fileindex.Watcher) {
+	w.Subscribe(func(files []string, changes []fileindex.Change) {
+		if changes != nil {
+			m.Update(changes)
+			return
+		}
+		// The baseline poll: parse the whole listing once.
+		for _, rel := range files {
+			if f, ok := m.parse(rel); ok {
+				m.mu.Lock()
+				m.files[rel] = f
+				m.mu.Unlock()
+			}
+		}
+	})
+}
+
+// Render ranks the current packages and renders them within the budget.
+func (m *Map) Render(opts Options) string {
+	return Render(m.Packages(), opts)
+}
+
+func (m *Map) parse(rel string) (parsedFile, bool) {
+	if !strings.HasSuffix(rel, ".go") || strings.HasSuffix(rel, "_test.go") {
+		return parsedFile{}, false
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(m.root, filepath.FromSlash(rel)), nil, parser.SkipObjectResolution)
+	if err != nil {
+		return parsedFile{}, false
+	}
+	parsed := parsedFile{pkg: f.Name.Name, symbols: exportedSymbols(fset, f)}
+	for _, spec := range f.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if m.module == "" {
+			continue
+		}
+		if importPath == m.module {
+			parsed.imports = append(parsed.imports, ".")
+		} else if internal, ok := strings.CutPrefix(importPath, m.module+"/"); ok {
+			parsed.imports = append(parsed.imports, internal)
+		}
+	}
+	return parsed, true
+}
+
+// Packages groups the parsed files into packages in rank order.
+func (m *Map) Packages() []Package {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byDir := make(map[string]*Package)
+	imports := make(map[string]map[string]bool)
+	for _, rel := range sortedFileNames(m.files) {
+		f := m.files[rel]
+		dir := path.Dir(rel)
+		pkg := byDir[dir]
+		if pkg == nil {
+			pkg = &Package{Dir: dir, Name: f.pkg}
+			byDir[dir] = pkg
+			imports[dir] = make(map[string]bool)
+		}
+		for _, dep := range f.imports {
+			imports[dir][dep] = true
+		}
+		pkg.Files = append(pkg.Files, File{Name: path.Base(rel), Symbols: f.symbols})
+	}
+
+	for dir, deps := range imports {
+		for dep := range deps {
+			if target := byDir[dep]; target != nil && dep != dir {
+				target.ImportedBy++
+				byDir[dir].Imports = append(byDir[dir].Imports, dep)
+			}
+		}
+	}
+
+	pkgs := make([]Package, 0, len(byDir))
+	for _, pkg := range byDir {
+		sort.Strings(pkg.Imports)
+		// Files with the most API first; they are the likeliest entry points.
+		sort.SliceStable(pkg.Files, func(i, j int) bool {
+			if len(pkg.Files[i].Symbols) != len(pkg.Files[j].Symbols) {
+				return len(pkg.Files[i].Symbols) > len(pkg.Files[j].Symbols)
+			}
+			return pkg.Files[i].Name < pkg.Files[j].Name
+		})
+		pkgs = append(pkgs, *pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].ImportedBy != pkgs[j].ImportedBy {
+			return pkgs[i].ImportedBy > pkgs[j].ImportedBy
+		}
+		return pkgs[i].Dir < pkgs[j].Dir
+	})
+	return pkgs
+}
+
+func sortedFileNames(files map[string]parsedFile) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// modulePath reads the module path from root/go.mod, or returns "".
+func modulePath(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// exportedSymbols lists a file's exported declarations: function and method
+// signatures, type kinds, and const/var names.
+func exportedSymbols(fset *token.FileSet, f *ast.File) []string {
+	var symbols []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() || (d.Recv != nil && !exportedReceiver(d.Recv)) {
+				continue
+			}
+			sig := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+			symbols = append(symbols, clip(format(fset, sig)))
+		case *ast.GenDecl:
+			symbols = append(symbols, genDeclSymbols(fset, d)...)
+		}
+	}
+	return symbols
+}
+
+func genDeclSymbols(fset *token.FileSet, d *ast.GenDecl) []string {
+	var symbols, names []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if !s.Name.IsExported() {
+				continue
+			}
+			kind := ""
+			switch s.Type.(type) {
+			case *ast.StructType:
+				kind = "struct"
+			case *ast.InterfaceType:
+				kind = "interface"
+			default:
+				kind = format(fset, s.Type)
+			}
+			if s.Assign.IsValid() {
+				kind = "= " + kind
+			}
+			symbols = append(symbols, clip("type "+s.Name.Name+" "+kind))
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				if name.IsExported() {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	if len(names) > 0 {
+		symbols = append(symbols, clip(d.Tok.String()+" "+strings.Join(names, ", ")))
+	}
+	return symbols
+}
+
+func exportedReceiver(recv *ast.FieldList) bool {
+	if len(recv.List) == 0 {
+		return false
+	}
+	t := recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.IsExported()
+		default:
+			return false
+		}
+	}
+}
+
+func format(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func clip(s string) string {
+	if len(s) > maxSymbolChars {
+		return s[:maxSymbolChars-3] + "..."
+	}
+	return s
+}
+
+// Render formats ranked packages within the token budget. Package lines go
+// in first, then symbols round-robin across packages in rank order, so one
+// large package cannot crowd out the rest of the map.
+func Render(pkgs []Package, opts Options) string {
+	if len(pkgs) == 0 {
+		return ""
+	}
+	budget := opts.MaxTokens
+	if budget <= 0 {
+		budget = DefaultMaxTokens
+	}
+	counter := opts.Counter
+	if counter == nil {
+		counter = tokens.Default()
+	}
+
+	header := fmt.Sprintf("Repository map (%d Go packages, most imported first; exported symbols per file):\n", len(pkgs))
+	used := counter.Count(header) + counter.Count(omittedNote(len(pkgs)))
+	fits := func(s string) bool {
+		n := counter.Count(s)
+		if used+n > budget {
+			return false
+		}
+		used += n
+		return true
+	}
+
+	shown := 0
+	// Each package reserves room for its "... N more files" line.
+	for shown < len(pkgs) && fits(packageLine(pkgs[shown])+moreFilesLine(len(pkgs[shown].Files))) {
+		shown++
+	}
+	// picked[i][j] is how many leading symbols of file j in package i are
+	// shown; a file's name is only listed once one of its symbols is.
+	picked := make([][]int, shown)
+	cursor := make([][2]int, shown)
+	for i := range shown {
+		picked[i] = make([]int, len(pkgs[i].Files))
+	}
+	for progress := true; progress; {
+		progress = false
+		for i := range shown {
+			files, c := pkgs[i].Files, &cursor[i]
+			for c[0] < len(files) && c[1] >= len(files[c[0]].Symbols) {
+				c[0], c[1] = c[0]+1, 0
+			}
+			if c[0] >= len(files) {
+				continue
+			}
+			cost := symbolLine(files[c[0]].Symbols[c[1]])
+			if c[1] == 0 {
+				cost = fileLine(files[c[0]].Name, 1) + cost
+			}
+			if !fits(cost) {
+				continue
+			}
+			picked[i][c[0]]++
+			c[1]++
+			progress = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	for i := range shown {
+		pkg := pkgs[i]
+		b.WriteString(packageLine(pkg))
+		hidden := 0
+		for j, f := range pkg.Files {
+			if picked[i][j] == 0 {
+				hidden++
+				continue
+			}
+			b.WriteString(fileLine(f.Name, len(f.Symbols)-picked[i][j]))
+			for _, sym := range f.Symbols[:picked[i][j]] {
+				b.WriteString(symbolLine(sym))
+			}
+		}
+		if hidden > 0 {
+			b.WriteString(moreFilesLine(hidden))
+		}
+	}
+	if shown < len(pkgs) {
+		b.WriteString(omittedNote(len(pkgs) - shown))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func moreFilesLine(n int) string {
+	if n == 1 {
+		return "  ... 1 more file\n"
+	}
+	return fmt.Sprintf("  ... %d more files\n", n)
+}
+
+func omittedNote(n int) string {
+	return fmt.Sprintf("... %d more packages omitted\n", n)
+}
+
+// fileLine names a file; hidden counts symbols left out for lack of budget.
+func fileLine(name string, hidden int) string {
+	if hidden > 0 {
+		return fmt.Sprintf("  %s (+%d more)\n", name, hidden)
+	}
+	return "  " + name + "\n"
+}
+
+func symbolLine(sym string) string {
+	return "    " + sym + "\n"
+}
+
+func packageLine(pkg Package) string {
+	var meta []string
+	if pkg.Name != path.Base(pkg.Dir) {
+		meta = append(meta, "package "+pkg.Name)
+	}
+	if pkg.ImportedBy > 0 {
+		meta = append(meta, fmt.Sprintf("imported by %d", pkg.ImportedBy))
+	}
+	if len(pkg.Imports) > 0 {
+		meta = append(meta, "imports "+strings.Join(pkg.Imports, ", "))
+	}
+	if len(meta) == 0 {
+		return pkg.Dir + "/\n"
+	}
+	return pkg.Dir + "/ (" + strings.Join(meta, "; ") + ")\n"
+}
+
+// MaxTokensFromEnv reads AGENT_REPO_MAP_TOKENS: unset means DefaultMaxTokens,
+// 0 (or a negative number) disables the map.
+func MaxTokensFromEnv() int {
+	raw := strings.TrimSpace(os.Getenv("AGENT_REPO_MAP_TOKENS"))
+	if raw == "" {
+		return DefaultMaxTokens
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return DefaultMaxTokens
+	}
+	return max(0, n)
+}
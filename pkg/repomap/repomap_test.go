@@ -0,0 +1,168 @@
+package repomap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod": "module example.com/demo\n\ngo 1.25\n",
+		"core/core.go": `package core
+
+// Store keeps things.
+type Store struct{ items []string }
+
+type hidden int
+
+const Version, build = "1", "x"
+
+func New() *Store { return &Store{} }
+
+func (s *Store) Add(item string) error { return nil }
+
+func (h hidden) Exported() {}
+
+func helper() {}
+`,
+		"core/core_test.go": "package core\n\nfunc TestSkipped() {}\n",
+		"api/api.go": `package api
+
+import "example.com/demo/core"
+
+func Serve(s *core.Store) {}
+`,
+		"cmd/tool/main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/demo/api"
+	"example.com/demo/core"
+)
+
+func main() { fmt.Println(api.Serve, core.New) }
+`,
+	})
+	return root
+}
+
+func TestScan_RanksByImportersAndListsExportedSymbols(t *testing.T) {
+	pkgs, err := Scan(context.Background(), testRepo(t))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	var dirs []string
+	for _, p := range pkgs {
+		dirs = append(dirs, p.Dir)
+	}
+	if got := strings.Join(dirs, " "); got != "core api cmd/tool" {
+		t.Fatalf("package order = %q, want core api cmd/tool", got)
+	}
+
+	core := pkgs[0]
+	if core.ImportedBy != 2 || len(core.Files) != 1 {
+		t.Fatalf("core = %+v, want imported by 2 with one non-test file", core)
+	}
+	want := []string{"type Store struct", "const Version", "func New() *Store", "func (s *Store) Add(item string) error"}
+	if got := core.Files[0].Symbols; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("symbols = %q, want %q", got, want)
+	}
+	if got := strings.Join(pkgs[2].Imports, ","); got != "api,core" {
+		t.Fatalf("cmd/tool imports = %q, want api,core", got)
+	}
+}
+
+func TestBuild_RendersMap(t *testing.T) {
+	got, err := Build(context.Background(), testRepo(t), Options{})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	for _, want := range []string{
+		"Repository map (3 Go packages",
+		"core/ (imported by 2)\n  core.go\n    type Store struct",
+		"cmd/tool/ (package main; imports api, core)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("map missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRender_SharesBudgetAcrossPackages(t *testing.T) {
+	big := File{Name: "big.go"}
+	for i := range 200 {
+		big.Symbols = append(big.Symbols, "func Symbol"+strings.Repeat("x", i%7)+"()")
+	}
+	pkgs := []Package{
+		{Dir: "big", Name: "big", ImportedBy: 5, Files: []File{big, {Name: "empty.go"}}},
+		{Dir: "small", Name: "small", Files: []File{{Name: "small.go", Symbols: []string{"func Small()"}}}},
+	}
+
+	got := Render(pkgs, Options{MaxTokens: 120, Counter: tokens.Heuristic{}})
+	if n := (tokens.Heuristic{}).Count(got); n > 120 {
+		t.Fatalf("map uses %d tokens, budget 120:\n%s", n, got)
+	}
+	for _, want := range []string{"big.go (+", "small/\n  small.go\n    func Small()", "  ... 1 more file"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("map missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRender_OmitsPackagesThatDoNotFit(t *testing.T) {
+	var pkgs []Package
+	for _, dir := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		pkgs = append(pkgs, Package{Dir: "some/long/directory/name/" + dir, Name: dir})
+	}
+	got := Render(pkgs, Options{MaxTokens: 60, Counter: tokens.Heuristic{}})
+	if !strings.Contains(got, "more packages omitted") {
+		t.Fatalf("expected omitted note:\n%s", got)
+	}
+}
+
+func TestMap_UpdateReparsesChangedFiles(t *testing.T) {
+	root := testRepo(t)
+	m := NewMap(root)
+	if err := m.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	writeFiles(t, root, map[string]string{"core/extra.go": "package core\n\nfunc Extra() {}\n"})
+	if err := os.Remove(filepath.Join(root, "api/api.go")); err != nil {
+		t.Fatal(err)
+	}
+	m.Update([]fileindex.Change{
+		{Path: "core/extra.go", Op: fileindex.Created},
+		{Path: "api/api.go", Op: fileindex.Removed},
+	})
+
+	got := m.Render(Options{})
+	if !strings.Contains(got, "func Extra()") {
+		t.Errorf("map missing new symbol:\n%s", got)
+	}
+	if strings.Contains(got, "api/") || !strings.Contains(got, "Repository map (2 Go packages") {
+		t.Errorf("map still lists the removed package:\n%s", got)
+	}
+}
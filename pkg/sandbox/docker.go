@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDockerImage   = "debian:bookworm-slim"
+	defaultDockerCPUs    = "1"
+	defaultDockerMemory  = "1g"
+	defaultDockerNetwork = "none"
+	containerWorkspace   = "/workspace"
+	containerStopTimeout = 10 * time.Second
+)
+
+var containerSeq atomic.Uint64
+
+type DockerOptions struct {
+	Image  string
+	CPUs   string
+	Memory string
+	// Network is passed to --network; "none" disables networking.
+	Network string
+	// Binary is the docker CLI to invoke (default "docker").
+	Binary string
+}
+
+// DockerOptionsFromEnv reads AGENT_SANDBOX_IMAGE, AGENT_SANDBOX_CPUS,
+// AGENT_SANDBOX_MEMORY and AGENT_SANDBOX_NETWORK.
+func DockerOptionsFromEnv() DockerOptions {
+	return DockerOptions{
+		Image:   os.Getenv("AGENT_SANDBOX_IMAGE"),
+		CPUs:    os.Getenv("AGENT_SANDBOX_CPUS"),
+		Memory:  os.Getenv("AGENT_SANDBOX_MEMORY"),
+		Network: os.Getenv("AGENT_SANDBOX_NETWORK"),
+	}
+}
+
+func withDockerDefaults(opts DockerOptions) DockerOptions {
+	if strings.TrimSpace(opts.Image) == "" {
+		opts.Image = defaultDockerImage
+	}
+	if strings.TrimSpace(opts.CPUs) == "" {
+		opts.CPUs = defaultDockerCPUs
+	}
+	if strings.TrimSpace(opts.Memory) == "" {
+		opts.Memory = defaultDockerMemory
+	}
+	if strings.TrimSpace(opts.Network) == "" {
+		opts.Network = defaultDockerNetwork
+	}
+	if strings.TrimSpace(opts.Binary) == "" {
+		opts.Binary = "docker"
+	}
+	return opts
+}
+
+// Docker runs each command in a fresh container with the project root
+// bind-mounted at /workspace. The container is removed when the command ends.
+type Docker struct {
+	root string
+	opts DockerOptions
+}
+
+func NewDocker(root string, opts DockerOptions) (*Docker, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, fmt.Errorf("sandbox root is required")
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve sandbox root: %w", err)
+	}
+	return &Docker{root: absRoot, opts: withDockerDefaults(opts)}, nil
+}
+
+func (d *Docker) Exec(ctx context.Context, command, dir string) ([]byte, error) {
+	name := fmt.Sprintf("agent-sandbox-%d-%d", os.Getpid(), containerSeq.Add(1))
+	args, err := d.runArgs(name, command, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, d.opts.Binary, args...)
+	out, runErr := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		// 取消时 docker CLI 被杀掉，但容器可能仍在运行，需要显式清理
+		d.forceRemove(name)
+	}
+	return out, runErr
+}
+
+func (d *Docker) runArgs(name, command, dir string) ([]string, error) {
+	workdir, err := d.containerDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"run", "--rm", "-i",
+		"--name", name,
+		"--network", d.opts.Network,
+		"--cpus", d.opts.CPUs,
+		"--memory", d.opts.Memory,
+		"-v", d.root + ":" + containerWorkspace,
+		"-w", workdir,
+		d.opts.Image,
+		"bash", "-c", command,
+	}, nil
+}
+
+// containerDir maps a host directory under root to its path inside the container.
+func (d *Docker) containerDir(dir string) (string, error) {
+	if strings.TrimSpace(dir) == "" {
+		return containerWorkspace, nil
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve working directory: %w", err)
+	}
+	rel, err := filepath.Rel(d.root, absDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("working directory %s is outside the sandbox root %s", dir, d.root)
+	}
+	return path.Join(containerWorkspace, filepath.ToSlash(rel)), nil
+}
+
+func (d *Docker) forceRemove(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerStopTimeout)
+	defer cancel()
+	_ = exec.CommandContext(ctx, d.opts.Binary, "rm", "-f", name).Run()
+}
@@ -0,0 +1,89 @@
+package sandbox
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocker_RunArgsApplyLimitsAndMount(t *testing.T) {
+	root := t.TempDir()
+	d, err := NewDocker(root, DockerOptions{Image: "golang:1.25", Memory: "512m"})
+	if err != nil {
+		t.Fatalf("NewDocker: %v", err)
+	}
+
+	args, err := d.runArgs("c1", "go test ./...", filepath.Join(root, "pkg", "tools"))
+	if err != nil {
+		t.Fatalf("runArgs: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		"run --rm -i --name c1",
+		"--network none",
+		"--cpus 1",
+		"--memory 512m",
+		"-v " + root + ":/workspace",
+		"-w /workspace/pkg/tools",
+		"golang:1.25 bash -c go test ./...",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("args missing %q: %s", want, joined)
+		}
+	}
+}
+
+func TestDocker_RejectsDirOutsideRoot(t *testing.T) {
+	d, err := NewDocker(t.TempDir(), DockerOptions{})
+	if err != nil {
+		t.Fatalf("NewDocker: %v", err)
+	}
+	if _, err := d.runArgs("c1", "ls", t.TempDir()); err == nil {
+		t.Fatal("expected outside-root error")
+	}
+}
+
+func TestDocker_ExecUsesConfiguredBinary(t *testing.T) {
+	root := t.TempDir()
+	// 用 echo 代替 docker CLI，验证传参而不依赖本机 Docker
+	d, err := NewDocker(root, DockerOptions{Binary: "echo"})
+	if err != nil {
+		t.Fatalf("NewDocker: %v", err)
+	}
+
+	out, err := d.Exec(context.Background(), "whoami", root)
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if !strings.Contains(string(out), "debian:bookworm-slim bash -c whoami") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestNewFromEnv_SelectsBackend(t *testing.T) {
+	t.Setenv("AGENT_SANDBOX", "")
+	exec, err := NewFromEnv(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFromEnv(local): %v", err)
+	}
+	if _, ok := exec.(Local); !ok {
+		t.Fatalf("expected Local, got %T", exec)
+	}
+
+	t.Setenv("AGENT_SANDBOX", "docker")
+	t.Setenv("AGENT_SANDBOX_NETWORK", "bridge")
+	exec, err = NewFromEnv(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFromEnv(docker): %v", err)
+	}
+	d, ok := exec.(*Docker)
+	if !ok || d.opts.Network != "bridge" {
+		t.Fatalf("expected docker with bridge network, got %#v", exec)
+	}
+
+	t.Setenv("AGENT_SANDBOX", "vm")
+	if _, err := NewFromEnv(t.TempDir()); err == nil {
+		t.Fatal("expected unknown backend error")
+	}
+}
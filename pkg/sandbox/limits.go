@@ -0,0 +1,78 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Limits caps the resources of each command and its children. Zero values
+// disable the corresponding limit.
+type Limits struct {
+	// CPUSeconds is the CPU time a process may use before it is killed.
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// MemoryMB caps a process's virtual memory. Runtimes that reserve much
+	// address space up front (Go, the JVM) need generous values.
+	MemoryMB int `json:"memory_mb,omitempty"`
+	// FileSizeMB is the largest file a process may write.
+	FileSizeMB int `json:"file_size_mb,omitempty"`
+	// Processes caps the processes of the user, which stops fork bombs.
+	Processes int `json:"processes,omitempty"`
+}
+
+func (l Limits) Validate() error {
+	if l.CPUSeconds < 0 || l.MemoryMB < 0 || l.FileSizeMB < 0 || l.Processes < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Wrap prefixes command with the ulimit calls that apply l in the shell
+// running it. The calls are chained with &&, so when a limit cannot be set
+// (e.g. it is above the hard limit) the command does not run and the
+// shell's error explains why. The command is grouped on its own lines so
+// its lists, background jobs and trailing comments stay behind the limits.
+func (l Limits) Wrap(command string) string {
+	var b strings.Builder
+	for _, limit := range []struct {
+		flag  string
+		value int
+	}{
+		{"-t", l.CPUSeconds},
+		{"-v", l.MemoryMB * 1024},
+		{"-f", l.FileSizeMB * 1024},
+		{"-u", l.Processes},
+	} {
+		if limit.value > 0 {
+			fmt.Fprintf(&b, "ulimit %s %d && ", limit.flag, limit.value)
+		}
+	}
+	if b.Len() == 0 {
+		return command
+	}
+	return b.String() + "{\n" + command + "\n}"
+}
+
+// Limited returns an executor that runs commands through e with l applied.
+// With no limit set, or a shell without ulimit (PowerShell, cmd), it
+// returns e.
+func Limited(e Executor, l Limits) Executor {
+	if l.IsZero() || !ShellOf(e).POSIX() {
+		return e
+	}
+	return limited{inner: e, limits: l}
+}
+
+type limited struct {
+	inner  Executor
+	limits Limits
+}
+
+func (l limited) Exec(ctx context.Context, command, dir string) ([]byte, error) {
+	return l.inner.Exec(ctx, l.limits.Wrap(command), dir)
+}
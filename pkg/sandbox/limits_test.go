@@ -0,0 +1,56 @@
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLimits_Wrap(t *testing.T) {
+	if got := (Limits{}).Wrap("make"); got != "make" {
+		t.Fatalf("zero limits changed the command: %q", got)
+	}
+	got := Limits{CPUSeconds: 30, FileSizeMB: 2, Processes: 64}.Wrap("make")
+	want := "ulimit -t 30 && ulimit -f 2048 && ulimit -u 64 && {\nmake\n}"
+	if got != want {
+		t.Fatalf("Wrap = %q, want %q", got, want)
+	}
+}
+
+func TestLimits_WrapStopsWhenALimitCannotBeSet(t *testing.T) {
+	wrapped := Limits{CPUSeconds: 7}.Wrap("echo ran; echo also ran")
+	// The soft limit cannot be raised above a lower hard limit.
+	out, err := exec.Command("sh", "-c", `ulimit -H -t 5 && sh -c "$1" limited "$1"`, "sh", wrapped).CombinedOutput()
+	if err == nil || strings.Contains(string(out), "ran") {
+		t.Fatalf("command ran without its limits: %v (%s)", err, out)
+	}
+}
+
+func TestLimits_WrapKeepsTheWholeCommandBehindTheLimits(t *testing.T) {
+	executor := Limited(LocalFromEnv(), Limits{FileSizeMB: 1})
+	out, err := executor.Exec(context.Background(), "ulimit -f; ulimit -f # trailing comment", t.TempDir())
+	if err != nil {
+		t.Fatalf("Exec: %v (%s)", err, out)
+	}
+	if got := strings.Fields(string(out)); len(got) != 2 || got[0] != "1024" || got[1] != "1024" {
+		t.Fatalf("limits seen by the command = %q", got)
+	}
+}
+
+func TestLimited_AppliesLimitsToCommands(t *testing.T) {
+	executor := Limited(LocalFromEnv(), Limits{CPUSeconds: 7, FileSizeMB: 1})
+	out, err := executor.Exec(context.Background(), "ulimit -t; ulimit -f", t.TempDir())
+	if err != nil {
+		t.Fatalf("Exec: %v (%s)", err, out)
+	}
+	if got := strings.Fields(string(out)); len(got) != 2 || got[0] != "7" || got[1] != "1024" {
+		t.Fatalf("limits seen by the command = %q", got)
+	}
+
+	// A file larger than the limit cannot be written.
+	dir := t.TempDir()
+	if _, err := executor.Exec(context.Background(), "head -c 2000000 /dev/zero > big", dir); err == nil {
+		t.Fatal("expected the write to fail past the file size limit")
+	}
+}
@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// macOSOfflineProfile is the sandbox-exec profile denying all networking.
+const macOSOfflineProfile = "(version 1)(allow default)(deny network*)"
+
+type networkKey struct{}
+
+// WithNetwork lets the commands run with ctx reach the network through an
+// Offline executor, e.g. after the user approved it for one command.
+func WithNetwork(ctx context.Context) context.Context {
+	return context.WithValue(ctx, networkKey{}, true)
+}
+
+// NetworkAllowed reports whether WithNetwork was applied to ctx.
+func NetworkAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(networkKey{}).(bool)
+	return allowed
+}
+
+// Offline returns an executor that runs the commands of e without network
+// access: in a new network namespace on Linux (unshare, which needs
+// unprivileged user namespaces) and under a sandbox-exec profile on macOS.
+// Docker executors are returned unchanged, their containers have their own
+// network setting.
+func Offline(e Executor) Executor {
+	if _, ok := e.(*Docker); ok {
+		return e
+	}
+	return offline{inner: e}
+}
+
+type offline struct {
+	inner Executor
+}
+
+func (o offline) Exec(ctx context.Context, command, dir string) ([]byte, error) {
+	if NetworkAllowed(ctx) {
+		return o.inner.Exec(ctx, command, dir)
+	}
+	wrapped, err := offlineCommand(runtime.GOOS, ShellOf(o.inner), command)
+	if err != nil {
+		return nil, err
+	}
+	return o.inner.Exec(ctx, wrapped, dir)
+}
+
+// offlineCommand wraps command so that it runs without network on goos, in
+// a new instance of shell.
+func offlineCommand(goos string, shell Shell, command string) (string, error) {
+	if !shell.POSIX() {
+		return "", fmt.Errorf("network isolation needs a POSIX shell, not %s", shell.Name())
+	}
+	inner := shellQuote(shell.Path) + " -c " + shellQuote(command)
+	switch goos {
+	case "linux":
+		return "unshare --net --map-root-user -- " + inner, nil
+	case "darwin":
+		return "sandbox-exec -p " + shellQuote(macOSOfflineProfile) + " " + inner, nil
+	default:
+		return "", fmt.Errorf("network isolation is not supported on %s", goos)
+	}
+}
+
+// shellQuote quotes s as a single bash word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
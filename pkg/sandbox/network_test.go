@@ -0,0 +1,56 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOfflineCommand(t *testing.T) {
+	bash := ShellFor("bash")
+	got, err := offlineCommand("linux", bash, "echo 'hi'")
+	if err != nil || got != `unshare --net --map-root-user -- 'bash' -c 'echo '\''hi'\'''` {
+		t.Fatalf("linux = %q, %v", got, err)
+	}
+	got, err = offlineCommand("darwin", bash, "curl x")
+	if err != nil || !strings.HasPrefix(got, "sandbox-exec -p '(version 1)") || !strings.HasSuffix(got, "'bash' -c 'curl x'") {
+		t.Fatalf("darwin = %q, %v", got, err)
+	}
+	if _, err := offlineCommand("windows", bash, "dir"); err == nil {
+		t.Fatal("expected an error for unsupported systems")
+	}
+	if _, err := offlineCommand("linux", ShellFor("pwsh"), "Get-Item ."); err == nil {
+		t.Fatal("expected an error for a non-POSIX shell")
+	}
+	if d := (&Docker{}); Offline(d) != Executor(d) {
+		t.Fatal("docker executors keep their own network setting")
+	}
+}
+
+func TestOffline_HidesNetworkUnlessAllowed(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("network namespaces are Linux only")
+	}
+	if err := exec.Command("unshare", "--net", "--map-root-user", "true").Run(); err != nil {
+		t.Skipf("unprivileged network namespaces unavailable: %v", err)
+	}
+	// Only the loopback interface exists in a fresh network namespace.
+	const count = "tail -n +3 /proc/net/dev | grep -vc '^ *lo:'"
+	executor := Offline(LocalFromEnv())
+
+	out, _ := executor.Exec(context.Background(), count, t.TempDir())
+	if got := strings.TrimSpace(string(out)); got != "0" {
+		t.Fatalf("interfaces besides lo offline = %q", got)
+	}
+	host, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Fatalf("readlink: %v", err)
+	}
+	out, _ = executor.Exec(WithNetwork(context.Background()), "readlink /proc/self/ns/net", t.TempDir())
+	if got := strings.TrimSpace(string(out)); got != host {
+		t.Fatalf("allowed command ran in network namespace %q, want the host's %q", got, host)
+	}
+}
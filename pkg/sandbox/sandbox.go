@@ -0,0 +1,138 @@
+// Package sandbox provides interchangeable backends for running shell
+// commands on behalf of the agent: directly on the host, or inside an
+// ephemeral Docker container.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	KindLocal  = "local"
+	KindDocker = "docker"
+)
+
+// Executor runs a shell command in dir and returns its combined output.
+// A non-zero exit status is reported through err, as with exec.Cmd.
+type Executor interface {
+	Exec(ctx context.Context, command, dir string) ([]byte, error)
+}
+
+// DefaultSecretPatterns match the names of environment variables that
+// usually hold credentials, such as DASHSCOPE_API_KEY or GITHUB_TOKEN.
+var DefaultSecretPatterns = []string{
+	"*API_KEY*", "*_KEY", "*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*CREDENTIAL*", "*_PAT",
+}
+
+// Local runs commands with a shell of the host, DefaultShell unless Shell is
+// set. Commands get the agent's environment without the variables matching
+// DefaultSecretPatterns or SecretPatterns, so they cannot read the agent's
+// own credentials, unless InheritSecrets is set.
+type Local struct {
+	Shell          Shell
+	InheritSecrets bool
+	// SecretPatterns are shell patterns (path.Match) for further variable
+	// names to strip, matched case-insensitively.
+	SecretPatterns []string
+}
+
+// LocalFromEnv configures Local from AGENT_SHELL (see ShellFromEnv),
+// AGENT_SANDBOX_INHERIT_SECRETS (1, true or yes to keep secrets) and
+// AGENT_SANDBOX_SECRET_PATTERNS (a comma-separated list of extra patterns).
+func LocalFromEnv() Local {
+	l := Local{Shell: ShellFromEnv()}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_SANDBOX_INHERIT_SECRETS"))) {
+	case "1", "true", "yes", "on":
+		l.InheritSecrets = true
+	}
+	for _, pattern := range strings.Split(os.Getenv("AGENT_SANDBOX_SECRET_PATTERNS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			l.SecretPatterns = append(l.SecretPatterns, pattern)
+		}
+	}
+	return l
+}
+
+func (l Local) Exec(ctx context.Context, command, dir string) ([]byte, error) {
+	return l.Command(ctx, command, dir).CombinedOutput()
+}
+
+// Command prepares command to run in dir, for callers that manage the
+// process themselves.
+func (l Local) Command(ctx context.Context, command, dir string) *exec.Cmd {
+	cmd := l.shell().Command(ctx, command)
+	cmd.Dir = dir
+	cmd.Env = l.Environ()
+	return cmd
+}
+
+func (l Local) shell() Shell {
+	if l.Shell.Path == "" {
+		return DefaultShell()
+	}
+	return l.Shell
+}
+
+// ShellOf returns the shell e runs commands with. Docker containers and
+// unknown executors are taken to run bash.
+func ShellOf(e Executor) Shell {
+	switch e := e.(type) {
+	case Local:
+		return e.shell()
+	case limited:
+		return ShellOf(e.inner)
+	case offline:
+		return ShellOf(e.inner)
+	}
+	return ShellFor("bash")
+}
+
+// Environ returns the environment commands run with.
+func (l Local) Environ() []string {
+	if l.InheritSecrets {
+		return os.Environ()
+	}
+	return ScrubEnv(os.Environ(), append(append([]string(nil), DefaultSecretPatterns...), l.SecretPatterns...))
+}
+
+// ScrubEnv returns env without the NAME=value entries whose name matches
+// one of patterns, ignoring case.
+func ScrubEnv(env, patterns []string) []string {
+	out := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if !secretName(name, patterns) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func secretName(name string, patterns []string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFromEnv selects the backend from AGENT_SANDBOX (local or docker,
+// default local). root is the project directory mounted into containers.
+func NewFromEnv(root string) (Executor, error) {
+	kind := strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_SANDBOX")))
+	switch kind {
+	case "", KindLocal:
+		return LocalFromEnv(), nil
+	case KindDocker:
+		return NewDocker(root, DockerOptionsFromEnv())
+	default:
+		return nil, fmt.Errorf("unknown AGENT_SANDBOX %q (want %s or %s)", kind, KindLocal, KindDocker)
+	}
+}
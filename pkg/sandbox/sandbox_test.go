@@ -0,0 +1,48 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScrubEnv_StripsSecretNames(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"DASHSCOPE_API_KEY=sk-1",
+		"GITHUB_TOKEN=ghp_1",
+		"AWS_SECRET_ACCESS_KEY=x",
+		"db_password=hunter2",
+		"HOME=/root",
+		"STRIPE_RESTRICTED=rk_1",
+	}
+	got := ScrubEnv(env, append(DefaultSecretPatterns, "stripe_*"))
+	if strings.Join(got, " ") != "PATH=/usr/bin HOME=/root" {
+		t.Fatalf("ScrubEnv = %q", got)
+	}
+}
+
+func TestLocal_HidesCredentialsUnlessInherited(t *testing.T) {
+	t.Setenv("AGENT_TEST_API_KEY", "sk-secret")
+	t.Setenv("AGENT_TEST_PLAIN", "visible")
+	t.Setenv("AGENT_SANDBOX_INHERIT_SECRETS", "")
+	t.Setenv("AGENT_SANDBOX_SECRET_PATTERNS", "AGENT_TEST_PLAIN")
+
+	command := `echo "[$AGENT_TEST_API_KEY][$AGENT_TEST_PLAIN]"`
+	out, err := LocalFromEnv().Exec(context.Background(), command, t.TempDir())
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "[][]" {
+		t.Fatalf("scrubbed output = %q", got)
+	}
+
+	t.Setenv("AGENT_SANDBOX_INHERIT_SECRETS", "1")
+	out, err = LocalFromEnv().Exec(context.Background(), command, t.TempDir())
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "[sk-secret][visible]" {
+		t.Fatalf("inherited output = %q", got)
+	}
+}
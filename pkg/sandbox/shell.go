@@ -0,0 +1,84 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Shell families, which decide the command syntax the model should write.
+const (
+	FamilyPOSIX      = "posix"
+	FamilyPowerShell = "powershell"
+	FamilyCmd        = "cmd"
+)
+
+// Shell is the interpreter local commands run with.
+type Shell struct {
+	// Path is the program, looked up in PATH when it has no directory.
+	Path string
+	// Args come before the command, e.g. -c for bash.
+	Args   []string
+	Family string
+}
+
+// Name is the program name without directory or extension, e.g. "pwsh".
+// Both slashes separate directories, so Windows paths work everywhere.
+func (s Shell) Name() string {
+	base := s.Path[strings.LastIndexAny(s.Path, `/\`)+1:]
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// POSIX reports whether the shell takes POSIX sh syntax, which Limits and
+// Offline rely on.
+func (s Shell) POSIX() bool {
+	return s.Family == FamilyPOSIX
+}
+
+// Command returns the command line running command in s.
+func (s Shell) Command(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, s.Path, append(append([]string(nil), s.Args...), command)...)
+}
+
+// ShellFor describes the shell at path (bash, sh, zsh, dash, pwsh,
+// powershell or cmd, with or without directory and extension). Unknown
+// programs are taken to be POSIX shells.
+func ShellFor(path string) Shell {
+	s := Shell{Path: path}
+	switch strings.ToLower(s.Name()) {
+	case "pwsh", "powershell":
+		s.Args, s.Family = []string{"-NoProfile", "-NonInteractive", "-Command"}, FamilyPowerShell
+	case "cmd":
+		s.Args, s.Family = []string{"/d", "/s", "/c"}, FamilyCmd
+	default:
+		s.Args, s.Family = []string{"-c"}, FamilyPOSIX
+	}
+	return s
+}
+
+// DefaultShell is bash, or on Windows PowerShell (pwsh when installed,
+// Windows PowerShell otherwise).
+func DefaultShell() Shell {
+	return defaultShell(runtime.GOOS, exec.LookPath)
+}
+
+func defaultShell(goos string, lookPath func(string) (string, error)) Shell {
+	if goos != "windows" {
+		return ShellFor("bash")
+	}
+	if _, err := lookPath("pwsh"); err == nil {
+		return ShellFor("pwsh")
+	}
+	return ShellFor("powershell")
+}
+
+// ShellFromEnv returns the shell named by AGENT_SHELL, or DefaultShell.
+func ShellFromEnv() Shell {
+	if path := strings.TrimSpace(os.Getenv("AGENT_SHELL")); path != "" {
+		return ShellFor(path)
+	}
+	return DefaultShell()
+}
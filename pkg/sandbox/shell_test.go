@@ -0,0 +1,54 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShellFor(t *testing.T) {
+	for _, tt := range []struct {
+		path, name, family string
+		args               []string
+	}{
+		{"bash", "bash", FamilyPOSIX, []string{"-c"}},
+		{"/usr/bin/zsh", "zsh", FamilyPOSIX, []string{"-c"}},
+		{`C:\Program Files\PowerShell\7\pwsh.exe`, "pwsh", FamilyPowerShell, []string{"-NoProfile", "-NonInteractive", "-Command"}},
+		{"cmd.exe", "cmd", FamilyCmd, []string{"/d", "/s", "/c"}},
+	} {
+		s := ShellFor(tt.path)
+		if s.Name() != tt.name || s.Family != tt.family || strings.Join(s.Args, " ") != strings.Join(tt.args, " ") {
+			t.Errorf("ShellFor(%q) = %+v (name %q)", tt.path, s, s.Name())
+		}
+	}
+}
+
+func TestDefaultShell(t *testing.T) {
+	found := func(string) (string, error) { return "found", nil }
+	missing := func(string) (string, error) { return "", errors.New("not found") }
+	if s := defaultShell("linux", missing); s.Name() != "bash" {
+		t.Errorf("linux default = %q", s.Name())
+	}
+	if s := defaultShell("windows", found); s.Name() != "pwsh" || s.Family != FamilyPowerShell {
+		t.Errorf("windows default with pwsh = %+v", s)
+	}
+	if s := defaultShell("windows", missing); s.Name() != "powershell" {
+		t.Errorf("windows default without pwsh = %+v", s)
+	}
+}
+
+func TestLocal_UsesConfiguredShell(t *testing.T) {
+	t.Setenv("AGENT_SHELL", "sh")
+	local := LocalFromEnv()
+	if local.Shell.Name() != "sh" {
+		t.Fatalf("shell = %+v", local.Shell)
+	}
+	out, err := local.Exec(context.Background(), "echo $0", t.TempDir())
+	if err != nil || strings.TrimSpace(string(out)) != "sh" {
+		t.Fatalf("Exec = %q, %v", out, err)
+	}
+	if _, ok := Limited(Local{Shell: ShellFor("pwsh")}, Limits{CPUSeconds: 1}).(Local); !ok {
+		t.Fatal("limits need ulimit and should be skipped for PowerShell")
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+// User is an API client of a multi-user server. Requests authenticate with
+// "Authorization: Bearer <token>", or ?access_token=<token> where headers
+// cannot be set (EventSource, browser WebSockets).
+type User struct {
+	Name  string
+	Token string
+	// MessagesPerMinute limits how fast the user can send messages; 0 means
+	// no limit. Short bursts up to the per-minute amount are allowed.
+	MessagesPerMinute int
+	// MaxTokensPerDay caps the tokens of all the user's runs per UTC day;
+	// 0 means no cap. A run that is already going is not cut off.
+	MaxTokensPerDay int64
+	// Budget caps each run of the user.
+	Budget config.Budget
+}
+
+// account is a user's live state. Usage is kept in memory only, so a
+// restart resets the day's count.
+type account struct {
+	User
+
+	mu         sync.Mutex
+	allowance  float64
+	lastRefill time.Time
+	day        string
+	dayTokens  int64
+}
+
+// limitError is a request refused by a user limit.
+type limitError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *limitError) Error() string { return e.reason }
+
+type accountKey struct{}
+
+func newAccounts(users []User) ([]*account, error) {
+	accounts := make([]*account, 0, len(users))
+	names := make(map[string]bool)
+	for _, u := range users {
+		if strings.TrimSpace(u.Name) == "" || strings.TrimSpace(u.Token) == "" {
+			return nil, fmt.Errorf("every user needs a name and a token")
+		}
+		if names[u.Name] {
+			return nil, fmt.Errorf("duplicate user %q", u.Name)
+		}
+		names[u.Name] = true
+		if err := u.Budget.Validate(); err != nil {
+			return nil, fmt.Errorf("user %q: %w", u.Name, err)
+		}
+		accounts = append(accounts, &account{User: u, allowance: float64(u.MessagesPerMinute), lastRefill: time.Now()})
+	}
+	return accounts, nil
+}
+
+// authenticate rejects requests without a valid token and attaches the
+// caller's account to the request context. Without users it lets
+// everything through.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if len(s.accounts) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("access_token")
+		}
+		acct := s.lookup(strings.TrimSpace(token))
+		if acct == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agent-server"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid API token"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accountKey{}, acct)))
+	})
+}
+
+// lookup compares token with every user's in constant time.
+func (s *Server) lookup(token string) *account {
+	if token == "" {
+		return nil
+	}
+	var found *account
+	for _, acct := range s.accounts {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(acct.Token)) == 1 {
+			found = acct
+		}
+	}
+	return found
+}
+
+// accountFrom returns the caller of a request, or nil on an open server.
+func accountFrom(ctx context.Context) *account {
+	acct, _ := ctx.Value(accountKey{}).(*account)
+	return acct
+}
+
+// owner is the session owner for the caller; "" on an open server.
+func (a *account) owner() string {
+	if a == nil {
+		return ""
+	}
+	return a.Name
+}
+
+// admit takes one message from the user's allowance. It refuses when the
+// day's tokens are spent or the user is sending too fast.
+func (a *account) admit() *limitError {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if a.MaxTokensPerDay > 0 && a.usedToday(now) >= a.MaxTokensPerDay {
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return &limitError{reason: fmt.Sprintf("daily token budget of %d exhausted", a.MaxTokensPerDay), retryAfter: midnight.Sub(now)}
+	}
+	if a.MessagesPerMinute <= 0 {
+		return nil
+	}
+	perSecond := float64(a.MessagesPerMinute) / 60
+	a.allowance = math.Min(float64(a.MessagesPerMinute), a.allowance+now.Sub(a.lastRefill).Seconds()*perSecond)
+	a.lastRefill = now
+	if a.allowance < 1 {
+		wait := time.Duration((1 - a.allowance) / perSecond * float64(time.Second))
+		return &limitError{reason: fmt.Sprintf("rate limit of %d messages per minute exceeded", a.MessagesPerMinute), retryAfter: wait}
+	}
+	a.allowance--
+	return nil
+}
+
+// newTracker returns the tracker of one run, enforcing the per-run budget
+// and counting tokens toward the daily cap.
+func (a *account) newTracker() *budget.Tracker {
+	tracker, ok, _ := budget.FromConfig(a.Budget)
+	if !ok {
+		tracker = budget.New(budget.Limits{}, budget.PricingFromConfig(a.Budget))
+	}
+	return tracker
+}
+
+// charge adds the tokens of a finished run to the day's usage.
+func (a *account) charge(tokens int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usedToday(time.Now())
+	a.dayTokens += tokens
+}
+
+func (a *account) usedToday(now time.Time) int64 {
+	if day := now.UTC().Format(time.DateOnly); day != a.day {
+		a.day, a.dayTokens = day, 0
+	}
+	return a.dayTokens
+}
+
+func writeLimitError(w http.ResponseWriter, err *limitError) {
+	seconds := int(math.Ceil(err.retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	writeError(w, http.StatusTooManyRequests, err)
+}
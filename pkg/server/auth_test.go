@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestServer_AuthenticatesAndSeparatesUsers(t *testing.T) {
+	srv, ts := newAuthServer(t, nil, User{Name: "alice", Token: "tok-a"}, User{Name: "bob", Token: "tok-b"})
+
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "", `{}`, http.StatusUnauthorized, nil)
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "wrong", `{}`, http.StatusUnauthorized, nil)
+
+	var created session.Session
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "tok-a", `{"title":"mine"}`, http.StatusCreated, &created)
+	if created.Owner != "alice" {
+		t.Fatalf("owner = %q", created.Owner)
+	}
+	authedJSON(t, http.MethodGet, ts.URL+"/sessions/"+created.ID, "tok-b", "", http.StatusNotFound, nil)
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+created.ID+"/messages", "tok-b", `{"content":"hi"}`, http.StatusNotFound, nil)
+	getJSON(t, ts.URL+"/sessions/"+created.ID+"?access_token=tok-a", http.StatusOK, nil)
+
+	var listed []session.Session
+	authedJSON(t, http.MethodGet, ts.URL+"/sessions", "tok-b", "", http.StatusOK, &listed)
+	if len(listed) != 0 {
+		t.Fatalf("bob sees %+v", listed)
+	}
+	authedJSON(t, http.MethodGet, ts.URL+"/sessions", "tok-a", "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].ID != created.ID || listed[0].Messages != nil {
+		t.Fatalf("alice sees %+v", listed)
+	}
+
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+created.ID+"/messages", "tok-a", `{"content":"hi"}`, http.StatusAccepted, nil)
+	srv.Wait()
+}
+
+func TestServer_EnforcesRateAndDailyTokenLimits(t *testing.T) {
+	runner := func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		budget.TrackerFrom(ctx).Record(60, 40)
+		return append(messages, openai.AssistantMessage("ok")), nil
+	}
+	srv, ts := newAuthServer(t, runner,
+		User{Name: "fast", Token: "tok-f", MessagesPerMinute: 2},
+		User{Name: "frugal", Token: "tok-g", MaxTokensPerDay: 150},
+	)
+
+	var sess session.Session
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "tok-f", `{}`, http.StatusCreated, &sess)
+	for i := 0; i < 2; i++ {
+		authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+sess.ID+"/messages", "tok-f", `{"content":"hi"}`, http.StatusAccepted, nil)
+		srv.Wait()
+	}
+	resp := authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+sess.ID+"/messages", "tok-f", `{"content":"hi"}`, http.StatusTooManyRequests, nil)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("429 without Retry-After")
+	}
+
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "tok-g", `{}`, http.StatusCreated, &sess)
+	for i := 0; i < 2; i++ {
+		authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+sess.ID+"/messages", "tok-g", `{"content":"hi"}`, http.StatusAccepted, nil)
+		srv.Wait()
+	}
+	// 200 tokens used: the daily cap of 150 is spent.
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+sess.ID+"/messages", "tok-g", `{"content":"hi"}`, http.StatusTooManyRequests, nil)
+}
+
+func TestServer_AppliesPerRunBudget(t *testing.T) {
+	runner := func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		tracker := budget.TrackerFrom(ctx)
+		tracker.Record(600, 600)
+		return messages, tracker.Check()
+	}
+	srv, ts := newAuthServer(t, runner, User{Name: "alice", Token: "tok-a", Budget: config.Budget{MaxTokens: 1000}})
+
+	var sess session.Session
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions", "tok-a", `{}`, http.StatusCreated, &sess)
+	events := subscribe(t, ts.URL+"/sessions/"+sess.ID+"/events?access_token=tok-a")
+	authedJSON(t, http.MethodPost, ts.URL+"/sessions/"+sess.ID+"/messages", "tok-a", `{"content":"hi"}`, http.StatusAccepted, nil)
+	for event := range events {
+		if event.Type == EventError {
+			if msg, _ := event.Data["error"].(string); !strings.Contains(msg, "budget exceeded") {
+				t.Fatalf("error event = %v", event.Data)
+			}
+			break
+		}
+		if event.Type == EventDone {
+			t.Fatal("run finished without a budget error")
+		}
+	}
+	srv.Wait()
+}
+
+func newAuthServer(t *testing.T, runner loop.AgentRunner, users ...User) (*Server, *httptest.Server) {
+	t.Helper()
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	if runner == nil {
+		runner = func(_ context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			return append(messages, openai.AssistantMessage("ok")), nil
+		}
+	}
+	srv, err := New(Config{Sessions: session.NewService(repo), Runner: runner, Users: users})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func authedJSON(t *testing.T, method, url, token, body string, wantStatus int, out any) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		var e map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		t.Fatalf("%s %s: status %d (%v), want %d", method, url, resp.StatusCode, e, wantStatus)
+	}
+	checkResponse(t, resp, wantStatus, out)
+	return resp
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types streamed over GET /sessions/{id}/events and /ws.
+const (
+	EventToken             = "token"
+	EventToolCall          = "tool_call_delta"
+	EventToolStart         = "tool_start"
+	EventToolEnd           = "tool_end"
+	EventToolProgress      = "tool_progress"
+	EventMessage           = "message"
+	EventFollowUp          = "follow_up"
+	EventPermissionRequest = "permission_request"
+	EventModelSwitch       = "model_switch"
+	EventProviderStatus    = "provider_status"
+	EventDone              = "done"
+	EventError             = "error"
+	// EventReady is the first event on a WebSocket connection.
+	EventReady = "ready"
+)
+
+const subscriberBuffer = 256
+
+// Event is one server-sent event for a session.
+type Event struct {
+	Type string         `json:"type"`
+	Time time.Time      `json:"time"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// hub fans session events out to SSE subscribers. Slow subscribers drop
+// events rather than blocking the agent loop.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+func (h *hub) subscribe(sessionID string) chan Event {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[sessionID] == nil {
+		h.subscribers[sessionID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[sessionID][ch] = struct{}{}
+	return ch
+}
+
+func (h *hub) unsubscribe(sessionID string, ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[sessionID], ch)
+	if len(h.subscribers[sessionID]) == 0 {
+		delete(h.subscribers, sessionID)
+	}
+}
+
+func (h *hub) publish(sessionID, eventType string, data map[string]any) {
+	event := Event{Type: eventType, Time: time.Now().UTC(), Data: data}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[sessionID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	agentv1 "github.com/nickdu2009/learn-claude-code/api/proto/agent/v1"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/openai/openai-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCService returns the gRPC form of the API, agent.v1.AgentService (see
+// api/proto/agent/v1). It shares sessions, runs and events with Handler:
+// a run started over gRPC can be watched and steered over HTTP, and the
+// other way round. With Config.Users set, every call needs
+// "authorization: Bearer <token>" metadata.
+func (s *Server) GRPCService() agentv1.AgentServiceServer {
+	return &grpcService{s: s}
+}
+
+type grpcService struct {
+	agentv1.UnimplementedAgentServiceServer
+	s *Server
+}
+
+// authenticate attaches the caller's account to ctx, like the HTTP
+// middleware does. Without users it lets everything through.
+func (g *grpcService) authenticate(ctx context.Context) (context.Context, error) {
+	if len(g.s.accounts) == 0 {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	for _, value := range md.Get("authorization") {
+		if t, ok := strings.CutPrefix(value, "Bearer "); ok {
+			token = t
+		}
+	}
+	acct := g.s.lookup(strings.TrimSpace(token))
+	if acct == nil {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid API token")
+	}
+	return context.WithValue(ctx, accountKey{}, acct), nil
+}
+
+func (g *grpcService) StartSession(ctx context.Context, req *agentv1.StartSessionRequest) (*agentv1.Session, error) {
+	ctx, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := g.s.createSession(ctx, req.GetTitle())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return sessionProto(sess), nil
+}
+
+func (g *grpcService) GetSession(ctx context.Context, req *agentv1.GetSessionRequest) (*agentv1.Session, error) {
+	ctx, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := g.s.session(ctx, req.GetSessionId())
+	if err != nil {
+		return nil, sessionStatus(err)
+	}
+	return sessionProto(sess), nil
+}
+
+func (g *grpcService) SendMessage(req *agentv1.SendMessageRequest, stream grpc.ServerStreamingServer[agentv1.Event]) error {
+	ctx, err := g.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	id := req.GetSessionId()
+	if strings.TrimSpace(req.GetContent()) == "" {
+		return status.Error(codes.InvalidArgument, "content is required")
+	}
+	if _, err := g.s.session(ctx, id); err != nil {
+		return sessionStatus(err)
+	}
+	acct := accountFrom(ctx)
+	if err := acct.admit(); err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	// Subscribe first so the stream misses none of the run's events.
+	ch := g.s.events.subscribe(id)
+	defer g.s.events.unsubscribe(id, ch)
+	err = g.s.startRun(acct, id, req.GetContent())
+	switch {
+	case errors.Is(err, errSessionBusy):
+		return status.Errorf(codes.Aborted, "session %s is already running", id)
+	case err != nil:
+		return sessionStatus(err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case event := <-ch:
+			if err := sendEvent(stream, id, event); err != nil {
+				return err
+			}
+			if event.Type == EventDone {
+				return nil
+			}
+		}
+	}
+}
+
+func (g *grpcService) Converse(stream grpc.BidiStreamingServer[agentv1.ClientMessage, agentv1.Event]) error {
+	ctx, err := g.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	id := first.GetSessionId()
+	if _, ok := first.GetKind().(*agentv1.ClientMessage_UserMessage); !ok || id == "" {
+		return status.Error(codes.InvalidArgument, "the first message must carry session_id and a user message")
+	}
+	if _, err := g.s.session(ctx, id); err != nil {
+		return sessionStatus(err)
+	}
+	acct := accountFrom(ctx)
+
+	ch := g.s.events.subscribe(id)
+	defer g.s.events.unsubscribe(id, ch)
+
+	// Recv blocks, so it gets a goroutine; Send stays on this one.
+	received := make(chan *agentv1.ClientMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case received <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// After the client closes its side the stream stays open until the run
+	// its messages started is done.
+	awaitingDone, closing := false, false
+	handle := func(msg *agentv1.ClientMessage) error {
+		err := g.s.handleClientMessage(acct, id, clientMessageOf(msg))
+		if err != nil {
+			return sendEvent(stream, id, Event{Type: EventError, Time: time.Now().UTC(), Data: map[string]any{"error": err.Error()}})
+		}
+		if _, ok := msg.GetKind().(*agentv1.ClientMessage_UserMessage); ok {
+			awaitingDone = true
+		}
+		return nil
+	}
+	if err := handle(first); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-g.s.cfg.BaseContext.Done():
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case msg := <-received:
+			if err := handle(msg); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			if !awaitingDone {
+				return nil
+			}
+			closing, recvErr = true, nil
+		case event := <-ch:
+			if err := sendEvent(stream, id, event); err != nil {
+				return err
+			}
+			if event.Type == EventDone {
+				awaitingDone = false
+				if closing {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (g *grpcService) StreamEvents(req *agentv1.StreamEventsRequest, stream grpc.ServerStreamingServer[agentv1.Event]) error {
+	ctx, err := g.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	id := req.GetSessionId()
+	if _, err := g.s.session(ctx, id); err != nil {
+		return sessionStatus(err)
+	}
+	ch := g.s.events.subscribe(id)
+	defer g.s.events.unsubscribe(id, ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-g.s.cfg.BaseContext.Done():
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case event := <-ch:
+			if err := sendEvent(stream, id, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// clientMessageOf translates a Converse message to the WebSocket message
+// with the same effect.
+func clientMessageOf(msg *agentv1.ClientMessage) clientMessage {
+	switch kind := msg.GetKind().(type) {
+	case *agentv1.ClientMessage_UserMessage:
+		return clientMessage{Type: "message", Content: kind.UserMessage}
+	case *agentv1.ClientMessage_Interrupt:
+		return clientMessage{Type: "interrupt"}
+	case *agentv1.ClientMessage_PermissionResponse:
+		return clientMessage{Type: "permission_response", ID: kind.PermissionResponse.GetRequestId(), Approved: kind.PermissionResponse.GetApproved()}
+	default:
+		return clientMessage{}
+	}
+}
+
+// sendEvent sends event on stream. Events the schema has no type for
+// (tool_progress, model_switch, provider_status) are left out.
+func sendEvent(stream grpc.ServerStream, id string, event Event) error {
+	msg := eventProto(id, event)
+	if msg == nil {
+		return nil
+	}
+	return stream.SendMsg(msg)
+}
+
+func eventProto(id string, event Event) *agentv1.Event {
+	str := func(key string) string {
+		value, _ := event.Data[key].(string)
+		return value
+	}
+	msg := &agentv1.Event{Time: timestamppb.New(event.Time), SessionId: id}
+	switch event.Type {
+	case EventToken:
+		msg.Type = agentv1.EventType_EVENT_TYPE_TOKEN
+		msg.Payload = &agentv1.Event_Token{Token: &agentv1.TokenDelta{Delta: str("delta")}}
+	case EventToolCall:
+		msg.Type = agentv1.EventType_EVENT_TYPE_TOOL_CALL_DELTA
+		msg.Payload = &agentv1.Event_ToolCallDelta{ToolCallDelta: &agentv1.ToolCallDelta{Id: str("id"), Tool: str("tool"), Delta: str("delta")}}
+	case EventToolStart:
+		msg.Type = agentv1.EventType_EVENT_TYPE_TOOL_START
+		msg.Payload = &agentv1.Event_ToolStart{ToolStart: &agentv1.ToolStart{Tool: str("tool"), ArgsJson: mustJSON(event.Data["args"])}}
+	case EventToolEnd:
+		msg.Type = agentv1.EventType_EVENT_TYPE_TOOL_END
+		msg.Payload = &agentv1.Event_ToolEnd{ToolEnd: &agentv1.ToolEnd{Tool: str("tool"), Output: str("output"), Error: str("error")}}
+	case EventMessage:
+		msg.Type = agentv1.EventType_EVENT_TYPE_MESSAGE
+		msg.Payload = &agentv1.Event_Message{Message: &agentv1.AssistantMessage{Content: str("content")}}
+	case EventFollowUp:
+		msg.Type = agentv1.EventType_EVENT_TYPE_FOLLOW_UP
+		msg.Payload = &agentv1.Event_FollowUp{FollowUp: &agentv1.FollowUp{Content: str("content")}}
+	case EventPermissionRequest:
+		msg.Type = agentv1.EventType_EVENT_TYPE_PERMISSION_REQUEST
+		msg.Payload = &agentv1.Event_PermissionRequest{PermissionRequest: &agentv1.PermissionRequest{
+			Id: str("id"), Tool: str("tool"), Summary: str("summary"), Detail: str("detail"),
+		}}
+	case EventDone:
+		messages, _ := event.Data["messages"].(int)
+		interrupted, _ := event.Data["interrupted"].(bool)
+		msg.Type = agentv1.EventType_EVENT_TYPE_DONE
+		msg.Payload = &agentv1.Event_Done{Done: &agentv1.Done{Messages: int32(messages), Interrupted: interrupted}}
+	case EventError:
+		msg.Type = agentv1.EventType_EVENT_TYPE_ERROR
+		msg.Payload = &agentv1.Event_Error{Error: &agentv1.Error{Error: str("error")}}
+	default:
+		return nil
+	}
+	return msg
+}
+
+func sessionProto(sess session.Session) *agentv1.Session {
+	msg := &agentv1.Session{
+		Id:        sess.ID,
+		Title:     sess.Title,
+		ParentId:  sess.ParentID,
+		CreatedAt: timestamppb.New(sess.CreatedAt),
+		UpdatedAt: timestamppb.New(sess.UpdatedAt),
+	}
+	for _, m := range sess.Messages {
+		msg.Messages = append(msg.Messages, messageProto(m))
+	}
+	return msg
+}
+
+func messageProto(m openai.ChatCompletionMessageParamUnion) *agentv1.Message {
+	msg := &agentv1.Message{}
+	switch {
+	case m.OfSystem != nil:
+		msg.Role, msg.Content = "system", m.OfSystem.Content.OfString.Value
+	case m.OfUser != nil:
+		msg.Role, msg.Content = "user", m.OfUser.Content.OfString.Value
+		for _, part := range m.OfUser.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				msg.Content += part.OfText.Text
+			}
+		}
+	case m.OfAssistant != nil:
+		msg.Role, msg.Content = "assistant", m.OfAssistant.Content.OfString.Value
+		for _, tc := range m.OfAssistant.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, &agentv1.ToolCall{Id: tc.ID, Name: tc.Function.Name, ArgumentsJson: tc.Function.Arguments})
+		}
+	case m.OfTool != nil:
+		msg.Role, msg.Content = "tool", m.OfTool.Content.OfString.Value
+		msg.ToolCallId = m.OfTool.ToolCallID
+	}
+	return msg
+}
+
+func sessionStatus(err error) error {
+	if errors.Is(err, session.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
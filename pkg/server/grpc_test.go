@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	agentv1 "github.com/nickdu2009/learn-claude-code/api/proto/agent/v1"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPC_SendMessageStreamsRunEvents(t *testing.T) {
+	srv, _ := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		loop.TokenHandlerFrom(ctx)("Hel")
+		loop.TokenHandlerFrom(ctx)("lo")
+		if _, err := registry.Dispatch(ctx, "echo", map[string]any{"text": "ping"}); err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage("Hello")), nil
+	})
+	client := dialGRPC(t, srv)
+	ctx := testContext(t)
+
+	created, err := client.StartSession(ctx, &agentv1.StartSessionRequest{Title: "demo"})
+	if err != nil || created.GetId() == "" || len(created.GetMessages()) != 1 || created.GetMessages()[0].GetRole() != "system" {
+		t.Fatalf("StartSession = %v, %v", created, err)
+	}
+
+	stream, err := client.SendMessage(ctx, &agentv1.SendMessageRequest{SessionId: created.GetId(), Content: "say hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	var toolEnd *agentv1.ToolEnd
+	var done *agentv1.Done
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		types = append(types, strings.TrimPrefix(event.GetType().String(), "EVENT_TYPE_"))
+		if event.GetSessionId() != created.GetId() {
+			t.Fatalf("event of session %q", event.GetSessionId())
+		}
+		if event.GetToolEnd() != nil {
+			toolEnd = event.GetToolEnd()
+		}
+		if event.GetDone() != nil {
+			done = event.GetDone()
+		}
+	}
+	if got := strings.Join(types, ","); got != "TOKEN,TOKEN,TOOL_START,TOOL_END,MESSAGE,DONE" {
+		t.Fatalf("events = %s", got)
+	}
+	if toolEnd.GetTool() != "echo" || toolEnd.GetOutput() != "ping" || done.GetMessages() != 3 {
+		t.Fatalf("tool end = %v, done = %v", toolEnd, done)
+	}
+
+	srv.Wait()
+	stored, err := client.GetSession(ctx, &agentv1.GetSessionRequest{SessionId: created.GetId()})
+	if err != nil || len(stored.GetMessages()) != 3 || stored.GetMessages()[2].GetContent() != "Hello" {
+		t.Fatalf("GetSession = %v, %v", stored, err)
+	}
+	if _, err := client.GetSession(ctx, &agentv1.GetSessionRequest{SessionId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetSession(nope) = %v, want NotFound", err)
+	}
+}
+
+func TestGRPC_ConversePermissionRoundTrip(t *testing.T) {
+	srv, _ := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		output, err := registry.Dispatch(ctx, "guarded", nil)
+		if err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage(output)), nil
+	})
+	client := dialGRPC(t, srv)
+	ctx := testContext(t)
+	created, err := client.StartSession(ctx, &agentv1.StartSessionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Converse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&agentv1.ClientMessage{SessionId: created.GetId(), Kind: &agentv1.ClientMessage_UserMessage{UserMessage: "go"}}); err != nil {
+		t.Fatal(err)
+	}
+	next := func(want agentv1.EventType) *agentv1.Event {
+		t.Helper()
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("waiting for %s: %v", want, err)
+			}
+			if event.GetType() == want {
+				return event
+			}
+		}
+	}
+	request := next(agentv1.EventType_EVENT_TYPE_PERMISSION_REQUEST).GetPermissionRequest()
+	if request.GetTool() != "guarded" {
+		t.Fatalf("unexpected permission request: %v", request)
+	}
+	if err := stream.Send(&agentv1.ClientMessage{Kind: &agentv1.ClientMessage_PermissionResponse{
+		PermissionResponse: &agentv1.PermissionResponse{RequestId: request.GetId(), Approved: true},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := next(agentv1.EventType_EVENT_TYPE_MESSAGE).GetMessage().GetContent(); content != "approved" {
+		t.Fatalf("assistant content = %q, want approved", content)
+	}
+	next(agentv1.EventType_EVENT_TYPE_DONE)
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("stream stayed open after the run: %v", err)
+	}
+}
+
+func TestGRPC_AuthenticatesAndSeparatesUsers(t *testing.T) {
+	srv, _ := newAuthServer(t, nil, User{Name: "alice", Token: "tok-a"}, User{Name: "bob", Token: "tok-b"})
+	client := dialGRPC(t, srv)
+	ctx := testContext(t)
+	as := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	if _, err := client.StartSession(ctx, &agentv1.StartSessionRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("StartSession without a token = %v", err)
+	}
+	created, err := client.StartSession(as("tok-a"), &agentv1.StartSessionRequest{Title: "mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetSession(as("tok-b"), &agentv1.GetSessionRequest{SessionId: created.GetId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("bob reads alice's session: %v", err)
+	}
+	if _, err := client.GetSession(as("tok-a"), &agentv1.GetSessionRequest{SessionId: created.GetId()}); err != nil {
+		t.Fatalf("alice cannot read her session: %v", err)
+	}
+}
+
+// dialGRPC serves srv's gRPC service over an in-memory connection.
+func dialGRPC(t *testing.T, srv *Server) agentv1.AgentServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	agentv1.RegisterAgentServiceServer(gs, srv.GRPCService())
+	go gs.Serve(listener)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return agentv1.NewAgentServiceClient(conn)
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
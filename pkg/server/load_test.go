@@ -0,0 +1,291 @@
+//go:build load
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+// 负载测试：多个并发会话经 HTTP 接口对话，模型由本地 mock provider 扮演。
+// 统计每条消息从 POST 到 done 事件的 p99 延迟、堆内存增长，并检查结束后没有泄漏 goroutine。
+//
+//	go test -tags load -run TestLoad -v ./pkg/server/
+//
+// AGENT_LOAD_SESSIONS（默认 50）、AGENT_LOAD_MESSAGES（默认 3）调整规模，
+// AGENT_LOAD_MAX_P99_MS（默认 2000）、AGENT_LOAD_MAX_HEAP_MB（默认 100）调整阈值。
+
+// loadScenario describes how the mock provider answers.
+type loadScenario struct {
+	name string
+	// toolCall makes the first answer of every message call the echo tool.
+	toolCall bool
+	// latency is how long the provider takes per completion.
+	latency time.Duration
+}
+
+var loadScenarios = []loadScenario{
+	{name: "chat"},
+	{name: "tool call", toolCall: true},
+	{name: "slow provider", latency: 20 * time.Millisecond},
+}
+
+func TestLoad_ConcurrentSessions(t *testing.T) {
+	sessions := loadEnvInt(t, "AGENT_LOAD_SESSIONS", 50)
+	messages := loadEnvInt(t, "AGENT_LOAD_MESSAGES", 3)
+	maxP99 := time.Duration(loadEnvInt(t, "AGENT_LOAD_MAX_P99_MS", 2000)) * time.Millisecond
+	maxHeap := uint64(loadEnvInt(t, "AGENT_LOAD_MAX_HEAP_MB", 100)) << 20
+
+	for _, scenario := range loadScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			heapBefore := heapInUse()
+
+			latencies, err := runLoad(t, scenario, sessions, messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			heapAfter := heapInUse()
+			growth := uint64(0)
+			if heapAfter > heapBefore {
+				growth = heapAfter - heapBefore
+			}
+			p50, p99 := percentile(latencies, 50), percentile(latencies, 99)
+			t.Logf("%d sessions x %d messages: p50 %s, p99 %s, heap growth %d KiB",
+				sessions, messages, p50.Round(time.Microsecond), p99.Round(time.Microsecond), growth>>10)
+
+			if p99 > maxP99 {
+				t.Errorf("p99 latency %s exceeds %s", p99, maxP99)
+			}
+			if growth > maxHeap {
+				t.Errorf("heap grew by %d MiB, more than %d MiB", growth>>20, maxHeap>>20)
+			}
+			if leaked := waitForGoroutines(goroutines, 5*time.Second); leaked > 0 {
+				buf := make([]byte, 1<<20)
+				t.Errorf("%d goroutine(s) leaked:\n%s", leaked, buf[:runtime.Stack(buf, true)])
+			}
+		})
+	}
+}
+
+// runLoad drives sessions concurrent sessions of messages messages each and
+// returns the latency of every message. Everything it starts is shut down
+// before it returns.
+func runLoad(t *testing.T, scenario loadScenario, sessions, messages int) ([]time.Duration, error) {
+	transport := &http.Transport{MaxIdleConnsPerHost: sessions}
+	defer transport.CloseIdleConnections()
+	httpClient := &http.Client{Transport: transport}
+
+	provider := httptest.NewServer(mockProvider(scenario))
+	defer provider.Close()
+	client := openai.NewClient(
+		option.WithBaseURL(provider.URL+"/v1/"),
+		option.WithAPIKey("k"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(httpClient),
+	)
+
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		return nil, err
+	}
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "echo"}},
+		func(_ context.Context, args map[string]any) (string, error) {
+			text, _ := args["text"].(string)
+			return text, nil
+		})
+	srv, err := New(Config{Client: &client, Model: "mock", Registry: registry, Sessions: session.NewService(repo)})
+	if err != nil {
+		return nil, err
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.Wait()
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errs      []error
+		wg        sync.WaitGroup
+	)
+	for i := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := runSession(httpClient, ts.URL, fmt.Sprintf("load-%d", i), messages)
+			mu.Lock()
+			defer mu.Unlock()
+			latencies = append(latencies, got...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%d of %d sessions failed, first: %w", len(errs), sessions, errs[0])
+	}
+	return latencies, nil
+}
+
+// runSession creates a session, follows its events and sends messages one
+// after the other, timing each from POST to the done event.
+func runSession(client *http.Client, base, title string, messages int) ([]time.Duration, error) {
+	var created session.Session
+	if err := loadPost(client, base+"/sessions", fmt.Sprintf(`{"title":%q}`, title), http.StatusCreated, &created); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, base+"/sessions/"+created.ID+"/events", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	// Wait for the subscription to be in place (the untyped ready event).
+	for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "data: ") {
+	}
+
+	var latencies []time.Duration
+	for n := range messages {
+		start := time.Now()
+		if err := loadPost(client, base+"/sessions/"+created.ID+"/messages", fmt.Sprintf(`{"content":"message %d"}`, n), http.StatusAccepted, nil); err != nil {
+			return latencies, err
+		}
+		if err := awaitDone(scanner); err != nil {
+			return latencies, err
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	return latencies, nil
+}
+
+func awaitDone(scanner *bufio.Scanner) error {
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case EventError:
+			return fmt.Errorf("run failed: %v", event.Data["error"])
+		case EventDone:
+			return nil
+		}
+	}
+	return fmt.Errorf("event stream ended before done: %v", scanner.Err())
+}
+
+func loadPost(client *http.Client, url, body string, wantStatus int, out any) error {
+	resp, err := client.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("POST %s: status %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// mockProvider answers chat completions the way scenario says.
+func mockProvider(scenario loadScenario) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		time.Sleep(scenario.latency)
+
+		message := map[string]any{"role": "assistant", "content": "ok"}
+		finish := "stop"
+		if last := len(body.Messages) - 1; scenario.toolCall && last >= 0 && body.Messages[last].Role == "user" {
+			message = map[string]any{"role": "assistant", "content": "", "tool_calls": []map[string]any{{
+				"id": "call-1", "type": "function",
+				"function": map[string]any{"name": "echo", "arguments": `{"text":"ping"}`},
+			}}}
+			finish = "tool_calls"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "mock",
+			"choices": []map[string]any{{"index": 0, "finish_reason": finish, "message": message}},
+			"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12},
+		})
+	})
+}
+
+func percentile(durations []time.Duration, p int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// waitForGoroutines waits for the goroutine count to fall back to baseline
+// and returns how many are left over when timeout passes.
+func waitForGoroutines(baseline int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		extra := runtime.NumGoroutine() - baseline
+		if extra <= 0 || time.Now().After(deadline) {
+			return max(extra, 0)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func loadEnvInt(t *testing.T, name string, def int) int {
+	t.Helper()
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		t.Fatalf("%s=%q: want a positive integer", name, raw)
+	}
+	return n
+}
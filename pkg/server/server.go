@@ -0,0 +1,756 @@
+// Package server exposes the agent loop over HTTP so web UIs and remote
+// clients can drive sessions:
+//
+//	POST /sessions                 create a session
+//	GET  /sessions/{id}            fetch a session and its messages
+//	POST /sessions/{id}/messages   send a user message (runs asynchronously)
+//	GET  /sessions/{id}/events     server-sent events: tokens, tool calls, results
+//	GET  /sessions/{id}/ws         WebSocket: the same events, plus client messages
+//	                               to interrupt, answer permission requests and
+//	                               send follow-ups mid-run (browsers only from
+//	                               the server's own origin)
+//	GET  /sessions                 list the caller's sessions (without messages)
+//
+// POST bodies must be sent as application/json, which a cross-site HTML form
+// cannot do.
+//
+// GRPCService serves the same API over gRPC (api/proto/agent/v1).
+//
+// With Config.Users set, every request needs a user's API token and each
+// user sees only their own sessions; see User for the limits per user.
+//
+// Sessions are persisted through pkg/session. Tools that ask for approval
+// should be built with permission.Contextual so requests raised during a run
+// are routed to the session's WebSocket clients.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/envinfo"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/metrics"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+)
+
+const (
+	maxRequestBodyBytes = 1 << 20
+	maxEventOutputBytes = 4000
+)
+
+// Config wires the server to the agent.
+type Config struct {
+	Client   *openai.Client
+	Model    string
+	Registry *tools.Registry
+	Sessions *session.Service
+	// SystemPrompt may use envinfo template variables such as {{env}}; they
+	// are expanded with a fresh snapshot of WorkDir when a session is created.
+	SystemPrompt string
+	// WorkDir is the directory the environment snapshot describes; it
+	// defaults to the process working directory.
+	WorkDir string
+	// PromptVars supplies extra SystemPrompt variables at session creation,
+	// e.g. {{repo_map}} from a map kept fresh by a file watcher.
+	PromptVars func() map[string]string
+	// Fallbacks are tried in order when Model is unavailable; each switch is
+	// recorded in the session and published as a model_switch event.
+	Fallbacks []llm.Target
+	// Interceptors wrap every model call inside the fallback and metrics
+	// ones, e.g. llm.PromptCache.
+	Interceptors []llm.Interceptor
+	// CircuitBreaker, when set, stops calls to a model that keeps failing
+	// for a cool-down, moving runs to Fallbacks meanwhile. Each state change
+	// is published as a provider_status event to the session whose call
+	// caused it, and GET /health reports every model's state.
+	CircuitBreaker *llm.BreakerOptions
+	// Metrics, when set, records LLM and tool metrics of every run and is
+	// served on GET /metrics in the Prometheus text format.
+	Metrics *metrics.Registry
+	// Runner defaults to loop.Run.
+	Runner loop.AgentRunner
+	// BaseContext is the parent of every run; cancel it to stop in-flight runs.
+	BaseContext context.Context
+	// Users turns on authentication; empty leaves the API open.
+	Users []User
+}
+
+// Server serves the session API.
+type Server struct {
+	cfg      Config
+	events   *hub
+	metrics  *metrics.Agent
+	accounts []*account
+	breaker  *llm.Breaker
+
+	mu      sync.Mutex
+	running map[string]*activeRun
+	wg      sync.WaitGroup
+
+	requestSeq atomic.Int64
+}
+
+// activeRun is the steerable state of an in-flight run, guarded by Server.mu.
+type activeRun struct {
+	cancel    context.CancelFunc
+	followUps []openai.ChatCompletionMessageParamUnion
+	approvals map[string]chan bool
+}
+
+var errSessionBusy = errors.New("session is already running")
+
+func New(cfg Config) (*Server, error) {
+	if cfg.Sessions == nil {
+		return nil, fmt.Errorf("session service is required")
+	}
+	if cfg.Registry == nil {
+		cfg.Registry = tools.New()
+	}
+	if cfg.Runner == nil {
+		cfg.Runner = loop.Run
+	}
+	if cfg.BaseContext == nil {
+		cfg.BaseContext = context.Background()
+	}
+	accounts, err := newAccounts(cfg.Users)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: cfg, events: newHub(), accounts: accounts, running: make(map[string]*activeRun)}
+	if cfg.Metrics != nil {
+		s.metrics = metrics.NewAgent(cfg.Metrics)
+	}
+	if cfg.CircuitBreaker != nil {
+		opts := *cfg.CircuitBreaker
+		onChange := opts.OnChange
+		opts.OnChange = func(ctx context.Context, status llm.ModelStatus) {
+			if id, ok := ctx.Value(sessionKey{}).(string); ok {
+				s.events.publish(id, EventProviderStatus, providerStatusData(status))
+			}
+			if onChange != nil {
+				onChange(ctx, status)
+			}
+		}
+		s.breaker = llm.NewBreaker(opts)
+	}
+	return s, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", s.handleCreateSession)
+	mux.HandleFunc("GET /sessions", s.handleListSessions)
+	mux.HandleFunc("GET /sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("POST /sessions/{id}/messages", s.handlePostMessage)
+	mux.HandleFunc("GET /sessions/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /sessions/{id}/ws", s.handleWebSocket)
+	if s.cfg.Metrics != nil {
+		mux.Handle("GET /metrics", s.cfg.Metrics.Handler())
+	}
+	mux.HandleFunc("GET /health", s.handleHealth)
+	return s.authenticate(mux)
+}
+
+// Wait blocks until all in-flight runs have finished.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) workDir() string {
+	if s.cfg.WorkDir != "" {
+		return s.cfg.WorkDir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Title string `json:"title"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	sess, err := s.createSession(r.Context(), body.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+// createSession creates a session for the caller, seeded with the system
+// prompt.
+func (s *Server) createSession(ctx context.Context, title string) (session.Session, error) {
+	var messages []openai.ChatCompletionMessageParamUnion
+	if strings.TrimSpace(s.cfg.SystemPrompt) != "" {
+		prompt := s.cfg.SystemPrompt
+		if strings.Contains(prompt, "{{") {
+			prompt = envinfo.Gather(ctx, s.workDir()).Expand(prompt)
+			if s.cfg.PromptVars != nil {
+				for name, value := range s.cfg.PromptVars() {
+					prompt = strings.ReplaceAll(prompt, "{{"+name+"}}", value)
+				}
+			}
+		}
+		messages = append(messages, openai.SystemMessage(prompt))
+	}
+	return s.cfg.Sessions.CreateFor(accountFrom(ctx).owner(), title, messages)
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	all, err := s.cfg.Sessions.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	acct := accountFrom(r.Context())
+	list := make([]session.Session, 0, len(all))
+	for _, sess := range all {
+		if acct == nil || sess.Owner == acct.Name {
+			sess.Messages = nil
+			list = append(list, sess)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// session returns session id if the caller may use it. Other users'
+// sessions are reported as not found so their IDs do not leak.
+func (s *Server) session(ctx context.Context, id string) (session.Session, error) {
+	sess, err := s.cfg.Sessions.Get(id)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if acct := accountFrom(ctx); acct != nil && sess.Owner != acct.Name {
+		return session.Session{}, fmt.Errorf("%w: %s", session.ErrNotFound, id)
+	}
+	return sess, nil
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.session(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content is required"))
+		return
+	}
+	if _, err := s.session(r.Context(), id); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	acct := accountFrom(r.Context())
+	if err := acct.admit(); err != nil {
+		writeLimitError(w, err)
+		return
+	}
+
+	err := s.startRun(acct, id, body.Content)
+	switch {
+	case errors.Is(err, errSessionBusy):
+		writeError(w, http.StatusConflict, fmt.Errorf("session %s is already running", id))
+		return
+	case err != nil:
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "running", "session": id})
+}
+
+// startRun appends the user message to the session and runs the agent in the
+// background for acct (nil on an open server). It returns errSessionBusy when
+// a run is already in progress.
+func (s *Server) startRun(acct *account, id, content string) error {
+	sess, err := s.cfg.Sessions.Get(id)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(s.cfg.BaseContext)
+	active := &activeRun{cancel: cancel, approvals: make(map[string]chan bool)}
+	if !s.claim(id, active) {
+		cancel()
+		return errSessionBusy
+	}
+
+	messages := append(sess.Messages, openai.UserMessage(content))
+	if _, err := s.cfg.Sessions.SaveMessages(id, messages); err != nil {
+		s.release(id)
+		cancel()
+		return err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		s.run(ctx, acct, id, active, messages)
+	}()
+	return nil
+}
+
+// run drives the agent until it stops. Follow-ups that arrive after the
+// model's final answer start another round instead of being dropped. Each
+// run gets a trace run ID, reported with its done event.
+func (s *Server) run(ctx context.Context, acct *account, id string, active *activeRun, messages []openai.ChatCompletionMessageParamUnion) {
+	runID := trace.NewRunID()
+	ctx = trace.WithRun(ctx, runID)
+	if acct != nil {
+		tracker := acct.newTracker()
+		ctx = budget.WithTracker(ctx, tracker)
+		defer func() { acct.charge(tracker.Usage().TotalTokens()) }()
+	}
+	ctx = loop.WithTokenHandler(ctx, func(delta string) {
+		s.events.publish(id, EventToken, map[string]any{"delta": delta})
+	})
+	ctx = loop.WithToolCallHandler(ctx, func(delta loop.ToolCallDelta) {
+		s.events.publish(id, EventToolCall, map[string]any{"id": delta.ID, "tool": delta.Name, "delta": delta.Fragment})
+	})
+	ctx = tools.WithProgressHandler(ctx, func(p tools.Progress) {
+		s.events.publish(id, EventToolProgress, map[string]any{"tool": p.Tool, "message": p.Message, "current": p.Current, "total": p.Total})
+	})
+	ctx = loop.WithFollowUps(ctx, func() []openai.ChatCompletionMessageParamUnion {
+		return s.takeFollowUps(active)
+	})
+	ctx = permission.WithApprover(ctx, s.clientApprover(id, active))
+	if len(s.cfg.Fallbacks) > 0 {
+		ctx = llm.WithInterceptors(ctx, llm.Fallback(s.cfg.Fallbacks, s.recordModelSwitch(id)))
+	}
+	if s.breaker != nil {
+		// Inside the fallback interceptor: an open circuit moves the call
+		// to the next model.
+		ctx = llm.WithInterceptors(context.WithValue(ctx, sessionKey{}, id), s.breaker.Interceptor())
+	}
+	registry := s.cfg.Registry.WithMiddleware(s.toolEvents(id), tools.NewRepeatGuard(tools.DefaultMaxRepeats).Middleware())
+	if s.metrics != nil {
+		// Inside the fallback interceptor: each attempt is measured under
+		// the model it actually used.
+		ctx = llm.WithInterceptors(ctx, s.metrics.Interceptor())
+		registry = registry.WithMiddleware(s.metrics.Middleware())
+	}
+	ctx = llm.WithInterceptors(ctx, s.cfg.Interceptors...)
+
+	for {
+		history, runErr := s.cfg.Runner(ctx, s.cfg.Client, s.cfg.Model, messages, registry)
+		if len(history) == 0 {
+			history = messages
+		}
+		if runErr == nil && len(history) > len(messages) {
+			if last := history[len(history)-1]; last.OfAssistant != nil {
+				s.events.publish(id, EventMessage, map[string]any{
+					"role":    "assistant",
+					"content": last.OfAssistant.Content.OfString.Value,
+				})
+			}
+		}
+
+		s.mu.Lock()
+		pending := active.followUps
+		active.followUps = nil
+		if runErr == nil && len(pending) > 0 {
+			s.mu.Unlock()
+			messages = append(history, pending...)
+			continue
+		}
+		delete(s.running, id)
+		s.mu.Unlock()
+
+		history = append(history, pending...)
+		if _, err := s.cfg.Sessions.SaveMessages(id, history); err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+		if runErr != nil {
+			s.events.publish(id, EventError, map[string]any{"error": runErr.Error(), "run_id": runID})
+		}
+		s.events.publish(id, EventDone, map[string]any{
+			"run_id":      runID,
+			"messages":    len(history),
+			"interrupted": errors.Is(ctx.Err(), context.Canceled) && s.cfg.BaseContext.Err() == nil,
+		})
+		return
+	}
+}
+
+// recordModelSwitch stores a fallback in the session and tells its clients.
+func (s *Server) recordModelSwitch(id string) func(context.Context, llm.Switch) {
+	return func(_ context.Context, sw llm.Switch) {
+		err := s.cfg.Sessions.RecordModelSwitch(id, session.ModelSwitch{At: sw.At.UTC(), From: sw.From, To: sw.To, Reason: sw.Reason})
+		data := map[string]any{"from": sw.From, "to": sw.To, "reason": sw.Reason}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		s.events.publish(id, EventModelSwitch, data)
+	}
+}
+
+// sessionKey carries the session of a run to the breaker's callback.
+type sessionKey struct{}
+
+func providerStatusData(status llm.ModelStatus) map[string]any {
+	data := map[string]any{"model": status.Model, "state": status.State, "failures": status.Failures}
+	if status.LastError != "" {
+		data["last_error"] = status.LastError
+	}
+	if !status.OpenUntil.IsZero() {
+		data["open_until"] = status.OpenUntil.UTC()
+	}
+	return data
+}
+
+// handleHealth reports the circuit state of every model called so far:
+// "ok" while all circuits are closed, "degraded" otherwise. With ?check=1
+// the primary model and the fallbacks are each sent a one-token request
+// first.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.breaker == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+		return
+	}
+	if r.URL.Query().Get("check") != "" {
+		targets := append([]llm.Target{{Model: s.cfg.Model}}, s.cfg.Fallbacks...)
+		for _, target := range targets {
+			client := target.Client
+			if client == nil {
+				client = s.cfg.Client
+			}
+			_, _ = s.breaker.Check(r.Context(), client, target.Model)
+		}
+	}
+	models := s.breaker.Status()
+	status := "ok"
+	for _, m := range models {
+		if m.State != llm.CircuitClosed {
+			status = "degraded"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": status, "models": models})
+}
+
+func (s *Server) takeFollowUps(active *activeRun) []openai.ChatCompletionMessageParamUnion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := active.followUps
+	active.followUps = nil
+	return pending
+}
+
+// clientApprover forwards permission requests to the session's clients as
+// permission_request events and waits for a matching permission_response.
+func (s *Server) clientApprover(id string, active *activeRun) permission.Approver {
+	return permission.ApproverFunc(func(ctx context.Context, req permission.Request) (bool, error) {
+		requestID := fmt.Sprintf("perm-%d", s.requestSeq.Add(1))
+		answer := make(chan bool, 1)
+		s.mu.Lock()
+		active.approvals[requestID] = answer
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(active.approvals, requestID)
+			s.mu.Unlock()
+		}()
+
+		s.events.publish(id, EventPermissionRequest, map[string]any{
+			"id":      requestID,
+			"tool":    req.Tool,
+			"summary": req.Summary,
+			"detail":  req.Detail,
+		})
+		select {
+		case approved := <-answer:
+			return approved, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	})
+}
+
+func (s *Server) toolEvents(id string) tools.Middleware {
+	return func(name string, next tools.Handler) tools.Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			s.events.publish(id, EventToolStart, map[string]any{"tool": name, "args": args})
+			output, err := next(ctx, args)
+
+			data := map[string]any{"tool": name, "output": truncate(output, maxEventOutputBytes)}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			s.events.publish(id, EventToolEnd, data)
+			return output, err
+		}
+	}
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.session(r.Context(), id); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := s.events.subscribe(id)
+	defer s.events.unsubscribe(id, ch)
+
+	fmt.Fprintf(w, "event: ready\ndata: %s\n\n", mustJSON(map[string]string{"session": id}))
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, mustJSON(event))
+			flusher.Flush()
+		}
+	}
+}
+
+// clientMessage is a message sent by a WebSocket client:
+//
+//	{"type":"message","content":"..."}                      start a run, or queue a follow-up
+//	{"type":"interrupt"}                                     cancel the in-flight run
+//	{"type":"permission_response","id":"...","approved":true}
+type clientMessage struct {
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	ID       string `json:"id"`
+	Approved bool   `json:"approved"`
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.session(r.Context(), id); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch := s.events.subscribe(id)
+	defer s.events.unsubscribe(id, ch)
+
+	// Hijacked connections outlive http.Server.Shutdown; close them with the server.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-s.cfg.BaseContext.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	ready := Event{Type: EventReady, Time: time.Now().UTC(), Data: map[string]any{"session": id}}
+	if err := conn.WriteText([]byte(mustJSON(ready))); err != nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case event := <-ch:
+				if err := conn.WriteText([]byte(mustJSON(event))); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg clientMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			err = fmt.Errorf("invalid message: %w", err)
+		} else {
+			err = s.handleClientMessage(accountFrom(r.Context()), id, msg)
+		}
+		if err != nil {
+			reply := Event{Type: EventError, Time: time.Now().UTC(), Data: map[string]any{"error": err.Error()}}
+			if err := conn.WriteText([]byte(mustJSON(reply))); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) handleClientMessage(acct *account, id string, msg clientMessage) error {
+	switch msg.Type {
+	case "message":
+		if strings.TrimSpace(msg.Content) == "" {
+			return fmt.Errorf("content is required")
+		}
+		if err := acct.admit(); err != nil {
+			return err
+		}
+		return s.send(acct, id, msg.Content)
+	case "interrupt":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		active := s.running[id]
+		if active == nil {
+			return fmt.Errorf("session %s is not running", id)
+		}
+		active.cancel()
+		return nil
+	case "permission_response":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var answer chan bool
+		if active := s.running[id]; active != nil {
+			answer = active.approvals[msg.ID]
+		}
+		if answer == nil {
+			return fmt.Errorf("unknown permission request %q", msg.ID)
+		}
+		delete(s.running[id].approvals, msg.ID)
+		answer <- msg.Approved
+		return nil
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+}
+
+// send starts a run with content, or queues it as a follow-up when one is
+// already in progress.
+func (s *Server) send(acct *account, id, content string) error {
+	for {
+		s.mu.Lock()
+		if active := s.running[id]; active != nil {
+			active.followUps = append(active.followUps, openai.UserMessage(content))
+			s.mu.Unlock()
+			s.events.publish(id, EventFollowUp, map[string]any{"content": content})
+			return nil
+		}
+		s.mu.Unlock()
+
+		// Another client may claim the session in between; queue on retry.
+		if err := s.startRun(acct, id, content); !errors.Is(err, errSessionBusy) {
+			return err
+		}
+	}
+}
+
+func (s *Server) claim(id string, active *activeRun) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[id] != nil {
+		return false
+	}
+	s.running[id] = active
+	return true
+}
+
+func (s *Server) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+}
+
+// errNotJSON rejects request bodies sent with another Content-Type.
+var errNotJSON = errors.New("Content-Type must be application/json")
+
+// decodeBody decodes the JSON body of r into dst; an empty body leaves dst
+// as it is. Any Content-Type but application/json is refused, even with an
+// empty body, so a cross-site form post cannot create sessions or runs.
+func decodeBody(r *http.Request, dst any) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return errNotJSON
+	}
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBodyBytes))
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+func writeBodyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotJSON) {
+		writeError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, session.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func mustJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "\n... (truncated)"
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/metrics"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/nickdu2009/learn-claude-code/pkg/trace"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestServer_SessionLifecycleStreamsEvents(t *testing.T) {
+	release := make(chan struct{})
+	var runID string
+	srv, ts := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		<-release
+		runID = trace.FromContext(ctx).RunID
+		loop.TokenHandlerFrom(ctx)("Hel")
+		loop.TokenHandlerFrom(ctx)("lo")
+		if _, err := registry.Dispatch(ctx, "echo", map[string]any{"text": "ping"}); err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage("Hello")), nil
+	})
+
+	var created session.Session
+	postJSON(t, ts.URL+"/sessions", `{"title":"demo"}`, http.StatusCreated, &created)
+	if created.ID == "" || len(created.Messages) != 1 {
+		t.Fatalf("unexpected session: %+v", created)
+	}
+
+	events := subscribe(t, ts.URL+"/sessions/"+created.ID+"/events")
+	postJSON(t, ts.URL+"/sessions/"+created.ID+"/messages", `{"content":"say hello"}`, http.StatusAccepted, nil)
+	postJSON(t, ts.URL+"/sessions/"+created.ID+"/messages", `{"content":"again"}`, http.StatusConflict, nil)
+	close(release)
+
+	var types []string
+	var done Event
+	for event := range events {
+		types = append(types, event.Type)
+		if event.Type == EventDone {
+			done = event
+			break
+		}
+	}
+	want := []string{EventToken, EventToken, EventToolStart, EventToolEnd, EventMessage, EventDone}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	if runID == "" || done.Data["run_id"] != runID {
+		t.Fatalf("done event run_id = %v, runner saw %q", done.Data["run_id"], runID)
+	}
+
+	srv.Wait()
+	var stored session.Session
+	getJSON(t, ts.URL+"/sessions/"+created.ID, http.StatusOK, &stored)
+	if len(stored.Messages) != 3 {
+		t.Fatalf("stored messages = %d, want system+user+assistant", len(stored.Messages))
+	}
+}
+
+func TestServer_UnknownSession(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	getJSON(t, ts.URL+"/sessions/nope", http.StatusNotFound, nil)
+	postJSON(t, ts.URL+"/sessions/nope/messages", `{"content":"x"}`, http.StatusNotFound, nil)
+}
+
+func TestServer_RequiresJSONBodies(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	post := func(url, contentType, body string) int {
+		t.Helper()
+		resp, err := http.Post(url, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", url, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x", ""} {
+		if code := post(ts.URL+"/sessions", contentType, `{"title":"x"}`); code != http.StatusUnsupportedMediaType {
+			t.Errorf("create session as %q = %d, want 415", contentType, code)
+		}
+		if code := post(ts.URL+"/sessions/nope/messages", contentType, `{"content":"x"}`); code != http.StatusUnsupportedMediaType {
+			t.Errorf("post message as %q = %d, want 415", contentType, code)
+		}
+	}
+	if code := post(ts.URL+"/sessions", "text/plain", ""); code != http.StatusUnsupportedMediaType {
+		t.Errorf("empty text/plain body = %d, want 415", code)
+	}
+	if code := post(ts.URL+"/sessions", "application/json; charset=utf-8", `{"title":"x"}`); code != http.StatusCreated {
+		t.Errorf("JSON with charset = %d, want 201", code)
+	}
+}
+
+func TestServer_RecordsModelFallback(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Model == "qwen-max" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":{"message":"overloaded"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": body.Model,
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "from " + body.Model}}},
+		})
+	}))
+	t.Cleanup(provider.Close)
+	client := openai.NewClient(option.WithBaseURL(provider.URL+"/v1/"), option.WithAPIKey("k"), option.WithMaxRetries(0))
+
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	srv, err := New(Config{
+		Client:    &client,
+		Model:     "qwen-max",
+		Fallbacks: []llm.Target{{Model: "qwen-plus"}},
+		Sessions:  session.NewService(repo),
+		Runner: func(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			resp, err := llm.Complete(ctx, client, openai.ChatCompletionNewParams{Model: model, Messages: messages})
+			if err != nil {
+				return messages, err
+			}
+			return append(messages, resp.Choices[0].Message.ToParam()), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	var created session.Session
+	postJSON(t, ts.URL+"/sessions", `{}`, http.StatusCreated, &created)
+	events := subscribe(t, ts.URL+"/sessions/"+created.ID+"/events")
+	postJSON(t, ts.URL+"/sessions/"+created.ID+"/messages", `{"content":"hi"}`, http.StatusAccepted, nil)
+
+	var switched *Event
+	for event := range events {
+		if event.Type == EventModelSwitch {
+			switched = &event
+		}
+		if event.Type == EventDone {
+			break
+		}
+	}
+	if switched == nil || switched.Data["from"] != "qwen-max" || switched.Data["to"] != "qwen-plus" {
+		t.Fatalf("model_switch event = %+v", switched)
+	}
+
+	srv.Wait()
+	var stored session.Session
+	getJSON(t, ts.URL+"/sessions/"+created.ID, http.StatusOK, &stored)
+	if len(stored.ModelSwitches) != 1 || !strings.Contains(stored.ModelSwitches[0].Reason, "503") {
+		t.Fatalf("model switches = %+v", stored.ModelSwitches)
+	}
+	if last := stored.Messages[len(stored.Messages)-1]; session.MessagePreview(last, 0) != "from qwen-plus" {
+		t.Fatalf("last message = %q", session.MessagePreview(last, 0))
+	}
+}
+
+func TestServer_CircuitBreakerReportsProviderStatus(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Model == "qwen-max" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":{"message":"overloaded"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": body.Model,
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "from " + body.Model}}},
+		})
+	}))
+	t.Cleanup(provider.Close)
+	client := openai.NewClient(option.WithBaseURL(provider.URL+"/v1/"), option.WithAPIKey("k"), option.WithMaxRetries(0))
+
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	srv, err := New(Config{
+		Client:         &client,
+		Model:          "qwen-max",
+		Fallbacks:      []llm.Target{{Model: "qwen-plus"}},
+		CircuitBreaker: &llm.BreakerOptions{Failures: 1, CoolDown: time.Hour},
+		Sessions:       session.NewService(repo),
+		Runner: func(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			resp, err := llm.Complete(ctx, client, openai.ChatCompletionNewParams{Model: model, Messages: messages})
+			if err != nil {
+				return messages, err
+			}
+			return append(messages, resp.Choices[0].Message.ToParam()), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	var health struct {
+		Status string            `json:"status"`
+		Models []llm.ModelStatus `json:"models"`
+	}
+	getJSON(t, ts.URL+"/health", http.StatusOK, &health)
+	if health.Status != "ok" || len(health.Models) != 0 {
+		t.Fatalf("health before any call = %+v", health)
+	}
+
+	var created session.Session
+	postJSON(t, ts.URL+"/sessions", `{}`, http.StatusCreated, &created)
+	events := subscribe(t, ts.URL+"/sessions/"+created.ID+"/events")
+	postJSON(t, ts.URL+"/sessions/"+created.ID+"/messages", `{"content":"hi"}`, http.StatusAccepted, nil)
+
+	var opened *Event
+	for event := range events {
+		if event.Type == EventProviderStatus {
+			opened = &event
+		}
+		if event.Type == EventDone {
+			break
+		}
+	}
+	if opened == nil || opened.Data["model"] != "qwen-max" || opened.Data["state"] != llm.CircuitOpen || !strings.Contains(opened.Data["last_error"].(string), "503") {
+		t.Fatalf("provider_status event = %+v", opened)
+	}
+	srv.Wait()
+
+	getJSON(t, ts.URL+"/health?check=1", http.StatusOK, &health)
+	if health.Status != "degraded" || len(health.Models) != 2 || health.Models[0].Model != "qwen-max" || health.Models[0].State != llm.CircuitOpen || health.Models[1].State != llm.CircuitClosed {
+		t.Fatalf("health = %+v", health)
+	}
+}
+
+func TestServer_ServesMetrics(t *testing.T) {
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "echo"}},
+		func(_ context.Context, args map[string]any) (string, error) { return args["text"].(string), nil })
+	srv, err := New(Config{
+		Registry: registry,
+		Sessions: session.NewService(repo),
+		Metrics:  metrics.NewRegistry(),
+		Runner: func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+			_, err := registry.Dispatch(ctx, "echo", map[string]any{"text": "ping"})
+			return append(messages, openai.AssistantMessage("done")), err
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	var created session.Session
+	postJSON(t, ts.URL+"/sessions", `{}`, http.StatusCreated, &created)
+	postJSON(t, ts.URL+"/sessions/"+created.ID+"/messages", `{"content":"hi"}`, http.StatusAccepted, nil)
+	srv.Wait()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `agent_tool_duration_seconds_count{tool="echo"} 1`) {
+		t.Fatalf("metrics missing tool duration:\n%s", body)
+	}
+}
+
+func newTestServer(t *testing.T, runner loop.AgentRunner) (*Server, *httptest.Server) {
+	t.Helper()
+
+	repo, err := session.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "echo"}},
+		func(_ context.Context, args map[string]any) (string, error) { return args["text"].(string), nil })
+	guard := permission.Contextual(permission.DenyAll)
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "guarded"}},
+		func(ctx context.Context, _ map[string]any) (string, error) {
+			ok, err := guard.Approve(ctx, permission.Request{Tool: "guarded", Summary: "do something risky"})
+			if err != nil || !ok {
+				return "denied", err
+			}
+			return "approved", nil
+		})
+
+	srv, err := New(Config{
+		Registry:     registry,
+		Sessions:     session.NewService(repo),
+		SystemPrompt: "You are a test agent.",
+		Runner:       runner,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func subscribe(t *testing.T, url string) <-chan Event {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	ready := make(chan struct{})
+	events := make(chan Event)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil || event.Type == "" {
+				close(ready) // the ready event carries no type
+				continue
+			}
+			events <- event
+		}
+	}()
+	<-ready
+	return events
+}
+
+func postJSON(t *testing.T, url, body string, wantStatus int, out any) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, resp, wantStatus, out)
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, out any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, resp, wantStatus, out)
+}
+
+func checkResponse(t *testing.T, resp *http.Response, wantStatus int, out any) {
+	t.Helper()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d", resp.Request.Method, resp.Request.URL, resp.StatusCode, wantStatus)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/session"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("acceptKey = %q", got)
+	}
+}
+
+func TestWebSocket_PermissionRoundTrip(t *testing.T) {
+	_, ts := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		output, err := registry.Dispatch(ctx, "guarded", nil)
+		if err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage(output)), nil
+	})
+	id := createSession(t, ts.URL)
+	ws := dialWebSocket(t, ts.URL+"/sessions/"+id+"/ws")
+
+	ws.send(t, `{"type":"message","content":"go"}`)
+	request := ws.next(t, EventPermissionRequest)
+	if request.Data["tool"] != "guarded" {
+		t.Fatalf("unexpected permission request: %+v", request.Data)
+	}
+	ws.send(t, fmt.Sprintf(`{"type":"permission_response","id":%q,"approved":true}`, request.Data["id"]))
+
+	message := ws.next(t, EventMessage)
+	if message.Data["content"] != "approved" {
+		t.Fatalf("assistant content = %v, want approved", message.Data["content"])
+	}
+	ws.next(t, EventDone)
+}
+
+func TestWebSocket_InterruptCancelsRun(t *testing.T) {
+	srv, ts := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		loop.TokenHandlerFrom(ctx)("working")
+		<-ctx.Done()
+		return messages, ctx.Err()
+	})
+	id := createSession(t, ts.URL)
+	ws := dialWebSocket(t, ts.URL+"/sessions/"+id+"/ws")
+
+	ws.send(t, `{"type":"message","content":"go"}`)
+	ws.next(t, EventToken)
+	ws.send(t, `{"type":"interrupt"}`)
+	ws.next(t, EventError)
+	done := ws.next(t, EventDone)
+	if done.Data["interrupted"] != true {
+		t.Fatalf("expected interrupted run, got %+v", done.Data)
+	}
+
+	srv.Wait()
+	ws.send(t, `{"type":"interrupt"}`)
+	if event := ws.next(t, EventError); !strings.Contains(fmt.Sprint(event.Data["error"]), "not running") {
+		t.Fatalf("unexpected error: %+v", event.Data)
+	}
+}
+
+func TestWebSocket_FollowUpStartsAnotherRound(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	srv, ts := newTestServer(t, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		calls++
+		if calls == 1 {
+			loop.TokenHandlerFrom(ctx)("thinking")
+			<-release
+		}
+		return append(messages, openai.AssistantMessage(fmt.Sprintf("answer %d", calls))), nil
+	})
+	id := createSession(t, ts.URL)
+	ws := dialWebSocket(t, ts.URL+"/sessions/"+id+"/ws")
+
+	ws.send(t, `{"type":"message","content":"first"}`)
+	ws.next(t, EventToken)
+	ws.send(t, `{"type":"message","content":"and also this"}`)
+	ws.next(t, EventFollowUp)
+	close(release)
+	ws.next(t, EventDone)
+
+	srv.Wait()
+	var stored session.Session
+	getJSON(t, ts.URL+"/sessions/"+id, http.StatusOK, &stored)
+	// system, first, answer 1, follow-up, answer 2
+	if calls != 2 || len(stored.Messages) != 5 {
+		t.Fatalf("calls = %d, stored messages = %d", calls, len(stored.Messages))
+	}
+}
+
+func TestWebSocket_RefusesOtherOrigins(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	id := createSession(t, ts.URL)
+	for _, origin := range []string{"http://evil.example", "null", "http://" + strings.TrimPrefix(ts.URL, "http://") + ".evil.example"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/sessions/"+id+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("handshake from %s: %v", origin, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("handshake from %s = %s, want 403", origin, resp.Status)
+		}
+	}
+	// dialWebSocket sends the server's own origin.
+	dialWebSocket(t, ts.URL+"/sessions/"+id+"/ws")
+}
+
+func createSession(t *testing.T, baseURL string) string {
+	t.Helper()
+	var created session.Session
+	postJSON(t, baseURL+"/sessions", `{}`, http.StatusCreated, &created)
+	return created.ID
+}
+
+type testWSClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, url string) *testWSClient {
+	t.Helper()
+
+	url = strings.TrimPrefix(url, "http://")
+	host, path, _ := strings.Cut(url, "/")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	fmt.Fprintf(conn, "GET /%s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nOrigin: http://%s\r\n\r\n", path, host, key, host)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		t.Fatalf("handshake failed: %s %v", resp.Status, resp.Header)
+	}
+
+	client := &testWSClient{conn: conn, reader: reader}
+	client.next(t, EventReady)
+	return client
+}
+
+// send writes one masked text frame, as browsers do.
+func (c *testWSClient) send(t *testing.T, text string) {
+	t.Helper()
+	payload := []byte(text)
+	frame := []byte{0x80 | opText}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+// next reads events until one of the wanted type arrives.
+func (c *testWSClient) next(t *testing.T, eventType string) Event {
+	t.Helper()
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			t.Fatalf("waiting for %s: %v", eventType, err)
+		}
+		length := int(header[1] & 0x7F)
+		if length == 126 {
+			var ext [2]byte
+			_, _ = io.ReadFull(c.reader, ext[:])
+			length = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			t.Fatalf("read payload: %v", err)
+		}
+		var event Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatalf("decode event %q: %v", payload, err)
+		}
+		if event.Type == eventType {
+			return event
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+)
+
+// DefaultDir is relative to the project root.
+const DefaultDir = ".sessions"
+
+// FileRepository stores one <id>.json file per session, encrypted when
+// AGENT_STORAGE_KEY is set (see package atrest).
+type FileRepository struct {
+	dir    string
+	cipher *atrest.Cipher
+	mu     sync.Mutex
+}
+
+func NewFileRepository(dir string) (*FileRepository, error) {
+	c, err := atrest.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+	return &FileRepository{dir: dir, cipher: c}, nil
+}
+
+func (r *FileRepository) Save(s Session) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	data = r.cipher.Seal(data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tmp, err := os.CreateTemp(r.dir, s.ID+".json.tmp-*")
+	if err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path(s.ID)); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	return nil
+}
+
+func (r *FileRepository) Get(id string) (Session, error) {
+	if err := (Session{ID: id}).Validate(); err != nil {
+		return Session{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.read(r.path(id))
+}
+
+// List returns all sessions, most recently updated first.
+func (r *FileRepository) List() ([]Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read session dir: %w", err)
+	}
+	sessions := make([]Session, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		s, err := r.read(filepath.Join(r.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	slices.SortFunc(sessions, func(a, b Session) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return sessions, nil
+}
+
+func (r *FileRepository) read(path string) (Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Session{}, fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSuffix(filepath.Base(path), ".json"))
+		}
+		return Session{}, fmt.Errorf("read session: %w", err)
+	}
+	if data, err = r.cipher.Open(data); err != nil {
+		return Session{}, fmt.Errorf("read session %s: %w", filepath.Base(path), err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Session{}, fmt.Errorf("unmarshal session %s: %w", filepath.Base(path), err)
+	}
+	return s, nil
+}
+
+func (r *FileRepository) path(id string) string {
+	return filepath.Join(r.dir, id+".json")
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/openai/openai-go"
+)
+
+func TestFileRepository_EncryptsWithStorageKey(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	if err := plain.Save(Session{ID: "old", CreatedAt: now, UpdatedAt: now, Messages: sampleConversation()}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(atrest.KeyEnv, atrest.NewKey())
+	repo, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(Session{ID: "new", CreatedAt: now, UpdatedAt: now, Messages: sampleConversation()}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "new.json"))
+	if err != nil || !atrest.IsSealed(data) {
+		t.Fatalf("session not encrypted on disk: %.60s, %v", data, err)
+	}
+	// Sessions saved before the key was set stay readable.
+	if sessions, err := repo.List(); err != nil || len(sessions) != 2 {
+		t.Fatalf("List = %d sessions, %v", len(sessions), err)
+	}
+
+	if _, err := plain.Get("new"); !errors.Is(err, atrest.ErrNoKey) {
+		t.Fatalf("reading without the key: %v", err)
+	}
+}
+
+func TestFileRepository_RoundTripsMessages(t *testing.T) {
+	repo, err := NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+
+	sess := Session{ID: "s1", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Messages: sampleConversation()}
+	if err := repo.Save(sess); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := repo.Get("s1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Messages) != len(sess.Messages) {
+		t.Fatalf("messages = %d, want %d", len(got.Messages), len(sess.Messages))
+	}
+	if got.Messages[2].OfAssistant == nil || got.Messages[2].OfAssistant.ToolCalls[0].Function.Name != "bash" {
+		t.Fatalf("assistant tool call not preserved: %+v", got.Messages[2])
+	}
+	if got.Messages[3].OfTool == nil || got.Messages[3].OfTool.ToolCallID != "call-1" {
+		t.Fatalf("tool message not preserved: %+v", got.Messages[3])
+	}
+
+	if _, err := repo.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.Save(Session{ID: "../x"}); err == nil {
+		t.Fatal("expected invalid id error")
+	}
+}
+
+func sampleConversation() []openai.ChatCompletionMessageParamUnion {
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are a coding agent."),
+		openai.UserMessage("list files"),
+		{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+			ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+				ID:       "call-1",
+				Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "bash", Arguments: `{"command":"ls"}`},
+			}},
+		}},
+		openai.ToolMessage("main.go", "call-1"),
+		openai.AssistantMessage("There is one file: main.go"),
+		openai.UserMessage("now delete it"),
+	}
+}
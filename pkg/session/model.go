@@ -0,0 +1,81 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// Session is a saved conversation. Forks record where they branched off.
+type Session struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	// Owner is the server user the session belongs to; empty outside a
+	// multi-user server.
+	Owner     string    `json:"owner,omitempty"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	ForkIndex int       `json:"fork_index,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// ModelSwitches lists every fallback to another model during the session.
+	ModelSwitches []ModelSwitch `json:"model_switches,omitempty"`
+
+	Messages []openai.ChatCompletionMessageParamUnion `json:"messages"`
+}
+
+// ModelSwitch records that the agent moved to another model because the
+// previous one was unavailable.
+type ModelSwitch struct {
+	At     time.Time `json:"at"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+func (s Session) Validate() error {
+	if strings.TrimSpace(s.ID) == "" {
+		return fmt.Errorf("session id is required")
+	}
+	if strings.ContainsAny(s.ID, `/\`) || strings.HasPrefix(s.ID, ".") {
+		return fmt.Errorf("invalid session id %q", s.ID)
+	}
+	return nil
+}
+
+// MessageRole returns the role of a message param ("system", "user", ...).
+func MessageRole(msg openai.ChatCompletionMessageParamUnion) string {
+	if role := msg.GetRole(); role != nil {
+		return *role
+	}
+	return ""
+}
+
+// MessagePreview returns the first line of a message's text, shortened to max runes.
+func MessagePreview(msg openai.ChatCompletionMessageParamUnion, max int) string {
+	var text string
+	switch {
+	case msg.OfSystem != nil:
+		text = msg.OfSystem.Content.OfString.Value
+	case msg.OfUser != nil:
+		text = msg.OfUser.Content.OfString.Value
+	case msg.OfAssistant != nil:
+		text = msg.OfAssistant.Content.OfString.Value
+		if text == "" && len(msg.OfAssistant.ToolCalls) > 0 {
+			names := make([]string, len(msg.OfAssistant.ToolCalls))
+			for i, tc := range msg.OfAssistant.ToolCalls {
+				names[i] = tc.Function.Name
+			}
+			text = "[tool calls: " + strings.Join(names, ", ") + "]"
+		}
+	case msg.OfTool != nil:
+		text = msg.OfTool.Content.OfString.Value
+	}
+
+	text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(text); max > 0 && len(runes) > max {
+		text = string(runes[:max-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,12 @@
+package session
+
+import "errors"
+
+// ErrNotFound is returned when a session id does not exist.
+var ErrNotFound = errors.New("session not found")
+
+type Repository interface {
+	Save(s Session) error
+	Get(id string) (Session, error)
+	List() ([]Session, error)
+}
@@ -0,0 +1,185 @@
+// Package session persists conversations so they can be resumed, listed and
+// forked into alternative branches.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/openai/openai-go"
+)
+
+type Service struct {
+	repo Repository
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// NewID returns a sortable, unique session id.
+func NewID() string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:])
+}
+
+// Create saves a new session with the given initial messages.
+func (s *Service) Create(title string, messages []openai.ChatCompletionMessageParamUnion) (Session, error) {
+	return s.CreateFor("", title, messages)
+}
+
+// CreateFor is Create for a session owned by a server user.
+func (s *Service) CreateFor(owner, title string, messages []openai.ChatCompletionMessageParamUnion) (Session, error) {
+	now := s.now().UTC()
+	sess := Session{
+		ID:        NewID(),
+		Title:     strings.TrimSpace(title),
+		Owner:     owner,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  slices.Clone(messages),
+	}
+	if err := s.repo.Save(sess); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+func (s *Service) Get(id string) (Session, error) {
+	return s.repo.Get(id)
+}
+
+func (s *Service) List() ([]Session, error) {
+	return s.repo.List()
+}
+
+// SaveMessages replaces the message history of an existing session.
+func (s *Service) SaveMessages(id string, messages []openai.ChatCompletionMessageParamUnion) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, err := s.repo.Get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	sess.Messages = slices.Clone(messages)
+	sess.UpdatedAt = s.now().UTC()
+	if err := s.repo.Save(sess); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+// RecordModelSwitch appends a model fallback to the session's metadata.
+func (s *Service) RecordModelSwitch(id string, sw ModelSwitch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, err := s.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	sess.ModelSwitches = append(sess.ModelSwitches, sw)
+	sess.UpdatedAt = s.now().UTC()
+	return s.repo.Save(sess)
+}
+
+// Fork copies messages[0..index] (inclusive) of session id into a new
+// session. The original is left unchanged. Forking is refused where it would
+// split an assistant tool call from its tool results.
+func (s *Service) Fork(id string, index int) (Session, error) {
+	parent, err := s.repo.Get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := ValidateForkIndex(parent.Messages, index); err != nil {
+		return Session{}, err
+	}
+
+	title := parent.Title
+	if title == "" {
+		title = parent.ID
+	}
+	now := s.now().UTC()
+	fork := Session{
+		ID:        NewID(),
+		Title:     fmt.Sprintf("%s (fork @%d)", title, index),
+		Owner:     parent.Owner,
+		ParentID:  parent.ID,
+		ForkIndex: index,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  slices.Clone(parent.Messages[:index+1]),
+	}
+	if err := s.repo.Save(fork); err != nil {
+		return Session{}, err
+	}
+	return fork, nil
+}
+
+// ValidateForkIndex reports whether messages can be cut after index.
+func ValidateForkIndex(messages []openai.ChatCompletionMessageParamUnion, index int) error {
+	if index < 0 || index >= len(messages) {
+		return fmt.Errorf("message index %d out of range (0-%d)", index, len(messages)-1)
+	}
+	if msg := messages[index].OfAssistant; msg != nil && len(msg.ToolCalls) > 0 {
+		return fmt.Errorf("message %d requests tool calls; fork after its tool results instead", index)
+	}
+	if index+1 < len(messages) && messages[index+1].OfTool != nil {
+		return fmt.Errorf("message %d is followed by more tool results; fork after the last of them", index)
+	}
+	return nil
+}
+
+// ForkCommand returns the /fork slash command. current returns the active
+// session id; switchTo is called with the new fork so the caller can make it
+// the active session. Without arguments it lists the message indices.
+func (s *Service) ForkCommand(current func() string, switchTo func(Session)) command.Command {
+	return command.Command{
+		Name:        "fork",
+		Usage:       "<message-index>",
+		Description: "branch the current session at a message into a new session",
+		Run: func(_ context.Context, args []string) (string, error) {
+			id := current()
+			if len(args) == 0 {
+				sess, err := s.repo.Get(id)
+				if err != nil {
+					return "", err
+				}
+				return describeMessages(sess), nil
+			}
+			index, err := strconv.Atoi(args[0])
+			if err != nil {
+				return "", fmt.Errorf("invalid message index %q", args[0])
+			}
+			fork, err := s.Fork(id, index)
+			if err != nil {
+				return "", err
+			}
+			if switchTo != nil {
+				switchTo(fork)
+			}
+			return fmt.Sprintf("Forked %s at message %d into %s.", id, index, fork.ID), nil
+		},
+	}
+}
+
+func describeMessages(sess Session) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Session %s (%d messages):", sess.ID, len(sess.Messages))
+	for i, msg := range sess.Messages {
+		fmt.Fprintf(&b, "\n  %3d %-9s %s", i, MessageRole(msg), MessagePreview(msg, 60))
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestService_ForkCopiesPrefix(t *testing.T) {
+	svc := newTestService(t)
+	parent, err := svc.Create("explore", sampleConversation())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	fork, err := svc.Fork(parent.ID, 4)
+	if err != nil {
+		t.Fatalf("Fork: %v", err)
+	}
+	if fork.ID == parent.ID || fork.ParentID != parent.ID || fork.ForkIndex != 4 {
+		t.Fatalf("unexpected fork metadata: %+v", fork)
+	}
+	if len(fork.Messages) != 5 {
+		t.Fatalf("fork messages = %d, want 5", len(fork.Messages))
+	}
+
+	reloaded, err := svc.Get(parent.ID)
+	if err != nil || len(reloaded.Messages) != 6 {
+		t.Fatalf("parent should be unchanged: %d messages, err %v", len(reloaded.Messages), err)
+	}
+}
+
+func TestService_RecordModelSwitchSurvivesSaveMessages(t *testing.T) {
+	svc := newTestService(t)
+	sess, err := svc.Create("", sampleConversation())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := svc.RecordModelSwitch(sess.ID, ModelSwitch{From: "qwen-max", To: "qwen-plus", Reason: "rate limited"}); err != nil {
+		t.Fatalf("RecordModelSwitch: %v", err)
+	}
+	if _, err := svc.SaveMessages(sess.ID, sampleConversation()[:2]); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	reloaded, err := svc.Get(sess.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(reloaded.ModelSwitches) != 1 || reloaded.ModelSwitches[0].To != "qwen-plus" {
+		t.Fatalf("model switches = %+v", reloaded.ModelSwitches)
+	}
+}
+
+func TestService_ForkRejectsSplitToolExchange(t *testing.T) {
+	svc := newTestService(t)
+	parent, err := svc.Create("", sampleConversation())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for _, index := range []int{2, -1, 6} {
+		if _, err := svc.Fork(parent.ID, index); err == nil {
+			t.Fatalf("Fork(%d) should fail", index)
+		}
+	}
+}
+
+func TestService_ForkCommandSwitchesSession(t *testing.T) {
+	svc := newTestService(t)
+	parent, err := svc.Create("", sampleConversation())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	current := parent.ID
+	cmd := svc.ForkCommand(func() string { return current }, func(s Session) { current = s.ID })
+
+	listing, err := cmd.Run(context.Background(), nil)
+	if err != nil || !strings.Contains(listing, "2 assistant") || !strings.Contains(listing, "[tool calls: bash]") {
+		t.Fatalf("unexpected listing %q (err %v)", listing, err)
+	}
+
+	output, err := cmd.Run(context.Background(), []string{"1"})
+	if err != nil {
+		t.Fatalf("/fork 1: %v", err)
+	}
+	if current == parent.ID || !strings.Contains(output, current) {
+		t.Fatalf("active session not switched: %q (current %s)", output, current)
+	}
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	repo, err := NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	return NewService(repo)
+}
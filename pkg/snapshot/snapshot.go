@@ -0,0 +1,248 @@
+// Package snapshot records lightweight git snapshots of the workspace before
+// the agent modifies it, so a whole turn of agent changes can be undone.
+//
+// Snapshots are dangling commits built from a temporary index: they include
+// untracked (non-ignored) files and never touch the user's index, stash,
+// branches or working tree. Each one is pinned by a ref under
+// refs/agent-snapshots/ until it is undone.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+const refPrefix = "refs/agent-snapshots/"
+
+// ErrNoSnapshot is returned by Undo when there is nothing to restore.
+var ErrNoSnapshot = errors.New("no snapshot to restore")
+
+// DefaultMutatingTools are the tools that trigger a snapshot.
+var DefaultMutatingTools = []string{"write_file", "edit_file", "multi_edit", "bash", "gofmt"}
+
+// Snapshot is one saved workspace state.
+type Snapshot struct {
+	Seq    int
+	Commit string
+	Label  string
+	Time   time.Time
+}
+
+// Manager takes snapshots of one repository and keeps the undo stack.
+type Manager struct {
+	repo string
+
+	mu        sync.Mutex
+	stack     []Snapshot
+	seq       int
+	turnTaken bool
+}
+
+func New(repo string) *Manager {
+	return &Manager{repo: repo}
+}
+
+// Available reports whether repo is inside a git work tree, which snapshots
+// need.
+func Available(ctx context.Context, repo string) bool {
+	out, err := runGit(ctx, repo, nil, nil, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// BeginTurn marks the start of a user turn; the next mutating tool call will
+// take a fresh snapshot.
+func (m *Manager) BeginTurn() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turnTaken = false
+}
+
+// Snapshots returns the undo stack, oldest first.
+func (m *Manager) Snapshots() []Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.stack)
+}
+
+// Take records the current workspace state.
+func (m *Manager) Take(ctx context.Context, label string) (Snapshot, error) {
+	tree, err := m.workingTree(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	args := []string{"commit-tree", tree, "-m", "agent snapshot: " + label}
+	if head, err := m.git(ctx, nil, nil, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		args = append(args, "-p", strings.TrimSpace(head))
+	}
+	commit, err := m.git(ctx, snapshotIdentity(), nil, args...)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	snap := Snapshot{Seq: m.seq, Commit: strings.TrimSpace(commit), Label: label, Time: time.Now()}
+	m.mu.Unlock()
+
+	if _, err := m.git(ctx, nil, nil, "update-ref", fmt.Sprintf("%s%d", refPrefix, snap.Seq), snap.Commit); err != nil {
+		return Snapshot{}, err
+	}
+
+	m.mu.Lock()
+	m.stack = append(m.stack, snap)
+	m.mu.Unlock()
+	return snap, nil
+}
+
+// Undo restores the most recent snapshot and removes it from the stack.
+// Files ignored by git are left untouched.
+func (m *Manager) Undo(ctx context.Context) (Snapshot, error) {
+	m.mu.Lock()
+	if len(m.stack) == 0 {
+		m.mu.Unlock()
+		return Snapshot{}, ErrNoSnapshot
+	}
+	snap := m.stack[len(m.stack)-1]
+	m.mu.Unlock()
+
+	if err := m.restore(ctx, snap); err != nil {
+		return Snapshot{}, err
+	}
+
+	m.mu.Lock()
+	m.stack = m.stack[:len(m.stack)-1]
+	m.turnTaken = false
+	m.mu.Unlock()
+	_, _ = m.git(ctx, nil, nil, "update-ref", "-d", fmt.Sprintf("%s%d", refPrefix, snap.Seq))
+	return snap, nil
+}
+
+// Middleware snapshots the workspace before the first call of a mutating
+// tool in each turn. A failed snapshot does not block the tool; the failure
+// is appended to its output instead.
+func (m *Manager) Middleware(mutating ...string) tools.Middleware {
+	if len(mutating) == 0 {
+		mutating = DefaultMutatingTools
+	}
+	return func(name string, next tools.Handler) tools.Handler {
+		if !slices.Contains(mutating, name) {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			m.mu.Lock()
+			take := !m.turnTaken
+			m.turnTaken = true
+			m.mu.Unlock()
+
+			var snapErr error
+			if take {
+				_, snapErr = m.Take(ctx, "before "+name)
+			}
+			output, err := next(ctx, args)
+			if snapErr != nil {
+				output += fmt.Sprintf("\n\n[snapshot] warning: could not snapshot workspace: %s", snapErr)
+			}
+			return output, err
+		}
+	}
+}
+
+// UndoCommand returns the /undo slash command.
+func (m *Manager) UndoCommand() command.Command {
+	return command.Command{
+		Name:        "undo",
+		Description: "restore the workspace to the snapshot taken before the last agent changes",
+		Run: func(ctx context.Context, _ []string) (string, error) {
+			snap, err := m.Undo(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Restored snapshot #%d (%s, %s).", snap.Seq, snap.Label, snap.Time.Format(time.Kitchen)), nil
+		},
+	}
+}
+
+func (m *Manager) restore(ctx context.Context, snap Snapshot) error {
+	current, err := m.workingTree(ctx)
+	if err != nil {
+		return err
+	}
+	diff, err := m.git(ctx, nil, nil, "diff", "--binary", current, snap.Commit)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil
+	}
+	if _, err := m.git(ctx, nil, strings.NewReader(diff), "apply", "--whitespace=nowarn", "-"); err != nil {
+		return fmt.Errorf("restore snapshot #%d: %w", snap.Seq, err)
+	}
+	return nil
+}
+
+func (m *Manager) workingTree(ctx context.Context) (string, error) {
+	return WorkingTree(ctx, m.repo)
+}
+
+// WorkingTree writes the current working tree of repo (tracked and untracked,
+// respecting .gitignore) as a tree object using a throwaway index, and
+// returns its hash. Neither the user's index nor the work tree is touched.
+func WorkingTree(ctx context.Context, repo string) (string, error) {
+	index, err := os.CreateTemp("", "agent-snapshot-index-")
+	if err != nil {
+		return "", fmt.Errorf("create temp index: %w", err)
+	}
+	indexPath := index.Name()
+	_ = index.Close()
+	_ = os.Remove(indexPath) // git refuses an empty, non-index file
+	defer os.Remove(indexPath)
+
+	env := []string{"GIT_INDEX_FILE=" + indexPath}
+	if _, err := runGit(ctx, repo, env, nil, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	tree, err := runGit(ctx, repo, env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+func (m *Manager) git(ctx context.Context, env []string, stdin *strings.Reader, args ...string) (string, error) {
+	return runGit(ctx, m.repo, env, stdin, args...)
+}
+
+func runGit(ctx context.Context, repo string, env []string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func snapshotIdentity() []string {
+	return []string{
+		"GIT_AUTHOR_NAME=agent", "GIT_AUTHOR_EMAIL=agent@localhost",
+		"GIT_COMMITTER_NAME=agent", "GIT_COMMITTER_EMAIL=agent@localhost",
+	}
+}
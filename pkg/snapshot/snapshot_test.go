@@ -0,0 +1,139 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestManager_UndoRestoresWorkspace(t *testing.T) {
+	repo := initRepo(t)
+	writeFile(t, repo, "tracked.txt", "dirty but uncommitted\n")
+	writeFile(t, repo, "notes.txt", "untracked\n")
+
+	mgr := New(repo)
+	if _, err := mgr.Take(context.Background(), "before edit"); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+
+	writeFile(t, repo, "tracked.txt", "agent rewrote this\n")
+	writeFile(t, repo, "created.txt", "new file\n")
+	if err := os.Remove(filepath.Join(repo, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mgr.Undo(context.Background()); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := readFile(t, repo, "tracked.txt"); got != "dirty but uncommitted\n" {
+		t.Fatalf("tracked.txt = %q", got)
+	}
+	if got := readFile(t, repo, "notes.txt"); got != "untracked\n" {
+		t.Fatalf("notes.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "created.txt")); !os.IsNotExist(err) {
+		t.Fatalf("created.txt should be removed, stat err = %v", err)
+	}
+	if status := git(t, repo, "status", "--porcelain"); !strings.Contains(status, " M tracked.txt") {
+		t.Fatalf("user index should be untouched, status:\n%s", status)
+	}
+	if _, err := mgr.Undo(context.Background()); !errors.Is(err, ErrNoSnapshot) {
+		t.Fatalf("expected ErrNoSnapshot, got %v", err)
+	}
+}
+
+func TestAvailable_OnlyInsideAGitWorkTree(t *testing.T) {
+	if !Available(context.Background(), initRepo(t)) {
+		t.Fatal("a git repository should be available")
+	}
+	if Available(context.Background(), t.TempDir()) {
+		t.Fatal("a plain directory should not be available")
+	}
+}
+
+func TestManager_MiddlewareSnapshotsOncePerTurn(t *testing.T) {
+	repo := initRepo(t)
+	mgr := New(repo)
+
+	registry := tools.New()
+	registry.Register(def("write_file"), func(context.Context, map[string]any) (string, error) { return "ok", nil })
+	registry.Register(def("read_file"), func(context.Context, map[string]any) (string, error) { return "ok", nil })
+	wrapped := registry.WithMiddleware(mgr.Middleware())
+
+	for turn := 0; turn < 2; turn++ {
+		mgr.BeginTurn()
+		_, _ = wrapped.Dispatch(context.Background(), "read_file", nil)
+		_, _ = wrapped.Dispatch(context.Background(), "write_file", nil)
+		_, _ = wrapped.Dispatch(context.Background(), "write_file", nil)
+	}
+	if got := len(mgr.Snapshots()); got != 2 {
+		t.Fatalf("snapshots = %d, want one per turn", got)
+	}
+}
+
+func TestManager_MiddlewareWarnsOutsideGit(t *testing.T) {
+	mgr := New(t.TempDir())
+	registry := tools.New()
+	registry.Register(def("bash"), func(context.Context, map[string]any) (string, error) { return "ran", nil })
+
+	output, err := registry.WithMiddleware(mgr.Middleware()).Dispatch(context.Background(), "bash", nil)
+	if err != nil {
+		t.Fatalf("tool should still run, got %v", err)
+	}
+	if !strings.HasPrefix(output, "ran") || !strings.Contains(output, "[snapshot] warning") {
+		t.Fatalf("unexpected output: %q", output)
+	}
+}
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	writeFile(t, repo, "tracked.txt", "original\n")
+	git(t, repo, "init", "-q")
+	git(t, repo, "add", "-A")
+	git(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+	return repo
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func def(name string) openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: name}}
+}
@@ -0,0 +1,38 @@
+package sqldb
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	lineComment  = regexp.MustCompile(`--[^\n]*`)
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	quoted       = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+	writeKeyword = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|upsert|create|alter|drop|truncate|grant|revoke|attach|detach|vacuum|reindex|copy|call|exec|execute|lock)\b`)
+)
+
+var readOnlyLeadingKeywords = map[string]bool{
+	"select": true, "with": true, "explain": true, "show": true,
+	"describe": true, "desc": true, "values": true, "table": true,
+}
+
+// IsReadOnly conservatively reports whether statement only reads data. Any
+// statement it cannot prove read-only (multiple statements, write keywords
+// outside string literals, PRAGMA, ...) is treated as a write.
+func IsReadOnly(statement string) bool {
+	cleaned := blockComment.ReplaceAllString(statement, " ")
+	cleaned = lineComment.ReplaceAllString(cleaned, " ")
+	cleaned = quoted.ReplaceAllString(cleaned, "''")
+	cleaned = strings.TrimSpace(cleaned)
+	cleaned = strings.TrimSpace(strings.TrimSuffix(cleaned, ";"))
+	if cleaned == "" || strings.Contains(cleaned, ";") {
+		return false
+	}
+
+	fields := strings.Fields(cleaned)
+	if !readOnlyLeadingKeywords[strings.ToLower(strings.TrimLeft(fields[0], "("))] {
+		return false
+	}
+	return !writeKeyword.MatchString(cleaned)
+}
@@ -0,0 +1,28 @@
+package sqldb
+
+import "testing"
+
+func TestIsReadOnly(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT * FROM users":                               true,
+		"  select count(*) from t;  ":                       true,
+		"WITH recent AS (SELECT 1) SELECT * FROM recent":    true,
+		"EXPLAIN SELECT * FROM t":                           true,
+		"SELECT 'drop table x' AS note":                     true,
+		"SELECT replace(name, 'a', 'b') FROM t":             true,
+		"-- delete everything\nSELECT 1":                    true,
+		"DELETE FROM users":                                 false,
+		"WITH gone AS (DELETE FROM t RETURNING *) SELECT 1": false,
+		"SELECT 1; DROP TABLE users":                        false,
+		"SELECT * FROM t FOR UPDATE":                        false,
+		"PRAGMA journal_mode = WAL":                         false,
+		"INSERT INTO t VALUES (1)":                          false,
+		"":                                                  false,
+	}
+
+	for statement, want := range cases {
+		if got := IsReadOnly(statement); got != want {
+			t.Errorf("IsReadOnly(%q) = %v, want %v", statement, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package sqldb manages named database/sql connections declared in the
+// project config. Drivers are registered by the application via blank
+// imports (e.g. modernc.org/sqlite, github.com/lib/pq).
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+const defaultMaxRows = 100
+
+// Result is the tabular outcome of a query, or the affected row count of a write.
+type Result struct {
+	Columns      []string
+	Rows         [][]string
+	Truncated    bool
+	RowsAffected int64
+	IsWrite      bool
+}
+
+// Registry lazily opens and caches one *sql.DB per declared connection.
+type Registry struct {
+	configs map[string]config.Database
+
+	mu  sync.Mutex
+	dbs map[string]*sql.DB
+}
+
+// NewRegistry validates the declared connections without opening them.
+func NewRegistry(databases map[string]config.Database) (*Registry, error) {
+	configs := make(map[string]config.Database, len(databases))
+	for name, db := range databases {
+		if err := db.Validate(); err != nil {
+			return nil, fmt.Errorf("database %q: %w", name, err)
+		}
+		configs[name] = db
+	}
+	return &Registry{configs: configs, dbs: make(map[string]*sql.DB)}, nil
+}
+
+// Names returns the declared connection names in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query runs a read-only statement and returns at most maxRows rows.
+func (r *Registry) Query(ctx context.Context, name, query string, maxRows int) (Result, error) {
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+	db, err := r.open(name)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return Result{}, fmt.Errorf("query %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return Result{}, fmt.Errorf("read columns: %w", err)
+	}
+
+	result := Result{Columns: columns, Rows: make([][]string, 0)}
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return Result{}, fmt.Errorf("scan row: %w", err)
+		}
+		row := make([]string, len(columns))
+		for i, value := range values {
+			row[i] = formatValue(value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, fmt.Errorf("iterate rows: %w", err)
+	}
+	return result, nil
+}
+
+// Exec runs a statement that may modify data. Callers must obtain approval first.
+func (r *Registry) Exec(ctx context.Context, name, statement string) (Result, error) {
+	db, err := r.open(name)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res, err := db.ExecContext(ctx, statement)
+	if err != nil {
+		return Result{}, fmt.Errorf("exec %s: %w", name, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		affected = -1
+	}
+	return Result{RowsAffected: affected, IsWrite: true}, nil
+}
+
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for name, db := range r.dbs {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("close %s: %w", name, err)
+		}
+		delete(r.dbs, name)
+	}
+	return firstErr
+}
+
+func (r *Registry) open(name string) (*sql.DB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if db, ok := r.dbs[name]; ok {
+		return db, nil
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q (configured: %s)", name, strings.Join(r.Names(), ", "))
+	}
+
+	db, err := sql.Open(cfg.Driver, os.ExpandEnv(cfg.DSN))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	r.dbs[name] = db
+	return db, nil
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,69 @@
+package sqldb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+)
+
+func TestRegistry_QueryFormatsRowsAndTruncates(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	result, err := reg.Query(context.Background(), "app", "SELECT id, name FROM users", 2)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if strings.Join(result.Columns, ",") != "id,name" {
+		t.Fatalf("columns = %v", result.Columns)
+	}
+	if len(result.Rows) != 2 || !result.Truncated {
+		t.Fatalf("rows = %v truncated = %v, want 2 rows truncated", result.Rows, result.Truncated)
+	}
+	if result.Rows[1][1] != "NULL" {
+		t.Fatalf("nil should render as NULL, got %q", result.Rows[1][1])
+	}
+}
+
+func TestRegistry_ExecReportsRowsAffected(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	result, err := reg.Exec(context.Background(), "app", "DELETE FROM users WHERE id = 9")
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if !result.IsWrite || result.RowsAffected != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	executed := testDriver.executed()
+	if len(executed) == 0 || executed[len(executed)-1] != "DELETE FROM users WHERE id = 9" {
+		t.Fatalf("statement not executed, got %v", executed)
+	}
+}
+
+func TestRegistry_UnknownConnection(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	_, err := reg.Query(context.Background(), "missing", "SELECT 1", 0)
+	if err == nil || !strings.Contains(err.Error(), "configured: app") {
+		t.Fatalf("expected unknown database error listing names, got %v", err)
+	}
+}
+
+func TestNewRegistry_ValidatesConfig(t *testing.T) {
+	if _, err := NewRegistry(map[string]config.Database{"x": {Driver: "sqldbfake"}}); err == nil {
+		t.Fatal("expected dsn validation error")
+	}
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	reg, err := NewRegistry(map[string]config.Database{"app": {Driver: "sqldbfake", DSN: "memory"}})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { _ = reg.Close() })
+	return reg
+}
@@ -0,0 +1,407 @@
+// Package stdio lets editors and wrappers drive the agent over line-delimited
+// JSON-RPC 2.0: one JSON message per line on stdin and stdout.
+//
+// The client sends the requests
+//
+//	initialize {"client":"vscode"}     -> {"protocol_version":1,"model":"...","tools":["bash",...]}
+//	prompt     {"text":"fix the test"} -> {"reply":"..."} when the turn is done
+//	cancel     {}                      -> {"cancelled":true}; the prompt fails with code -32800
+//	reset      {}                      -> {}; the next prompt starts a new conversation
+//
+// initialize must come first, and one prompt runs at a time. While a prompt
+// runs the agent sends the notifications
+//
+//	token           {"delta":"..."}
+//	tool_call_delta {"id":"call_1","tool":"bash","delta":"{\"command\": \"go te"}
+//	tool_start      {"tool":"bash","args":{...}}
+//	tool_end        {"tool":"bash","output":"...","error":"..."}
+//
+// tool_call_delta carries the arguments of a tool call as the model writes
+// them; concatenated they are the JSON object tool_start reports.
+//
+// and, when a tool needs approval, the request
+//
+//	permission_request {"tool":"...","summary":"...","detail":"...","path":"...","diff":"..."}
+//
+// which the client answers with {"approved":true} or {"approved":false}. An
+// error response denies the action too.
+package stdio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/agent"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// ProtocolVersion is reported by initialize and changes when the protocol
+// does.
+const ProtocolVersion = 1
+
+// Method names.
+const (
+	MethodInitialize        = "initialize"
+	MethodPrompt            = "prompt"
+	MethodCancel            = "cancel"
+	MethodReset             = "reset"
+	MethodToken             = "token"
+	MethodToolCallDelta     = "tool_call_delta"
+	MethodToolStart         = "tool_start"
+	MethodToolEnd           = "tool_end"
+	MethodPermissionRequest = "permission_request"
+)
+
+// Error codes besides the JSON-RPC 2.0 ones; they follow LSP.
+const (
+	CodeParseError       = -32700
+	CodeInvalidRequest   = -32600
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeNotInitialized   = -32002
+	CodeBusy             = -32001
+	CodeRequestCancelled = -32800
+)
+
+// maxLineBytes bounds one incoming message.
+const maxLineBytes = 16 << 20
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Config configures Serve.
+type Config struct {
+	Client       *openai.Client
+	Model        string
+	Registry     *tools.Registry
+	SystemPrompt string
+	// MaxTurns limits the model calls of each prompt; 0 means no limit.
+	MaxTurns int
+	// Runner defaults to loop.Run.
+	Runner loop.AgentRunner
+}
+
+type server struct {
+	agent *agent.Agent
+	tools []string
+	model string
+
+	w       io.Writer
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	initialized bool
+	cancel      context.CancelFunc
+	nextID      int
+	pending     map[string]chan message
+	running     sync.WaitGroup
+}
+
+// Serve speaks the protocol on r and w until r is exhausted. Tools that
+// need approval should be built with permission.Contextual: their requests
+// go to the client. A prompt still running at the end is cancelled.
+func Serve(ctx context.Context, cfg Config, r io.Reader, w io.Writer) error {
+	if cfg.Registry == nil {
+		cfg.Registry = tools.New()
+	}
+	s := &server{w: w, model: cfg.Model, pending: make(map[string]chan message)}
+	for _, def := range cfg.Registry.Definitions() {
+		s.tools = append(s.tools, def.Function.Name)
+	}
+	opts := []agent.Option{
+		agent.WithClient(cfg.Client),
+		agent.WithModel(cfg.Model),
+		agent.WithTools(cfg.Registry.WithMiddleware(s.toolEvents)),
+		agent.WithSystemPrompt(cfg.SystemPrompt),
+		agent.WithMaxTurns(cfg.MaxTurns),
+		agent.WithPermissionCallback(s.askPermission),
+	}
+	if cfg.Runner != nil {
+		opts = append(opts, agent.WithRunner(cfg.Runner))
+	}
+	a, err := agent.New(opts...)
+	if err != nil {
+		return err
+	}
+	s.agent = a
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.running.Wait()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			s.respond(json.RawMessage("null"), nil, &Error{Code: CodeParseError, Message: err.Error()})
+			continue
+		}
+		s.handle(ctx, msg)
+	}
+	return scanner.Err()
+}
+
+func (s *server) handle(ctx context.Context, msg message) {
+	if msg.Method == "" {
+		if len(msg.ID) == 0 {
+			s.respond(json.RawMessage("null"), nil, &Error{Code: CodeInvalidRequest, Message: "message has neither method nor id"})
+			return
+		}
+		s.resolve(msg)
+		return
+	}
+
+	s.mu.Lock()
+	initialized := s.initialized
+	s.mu.Unlock()
+	if !initialized && msg.Method != MethodInitialize {
+		s.respond(msg.ID, nil, &Error{Code: CodeNotInitialized, Message: "initialize first"})
+		return
+	}
+
+	switch msg.Method {
+	case MethodInitialize:
+		s.mu.Lock()
+		s.initialized = true
+		s.mu.Unlock()
+		s.respond(msg.ID, map[string]any{
+			"protocol_version": ProtocolVersion,
+			"model":            s.model,
+			"tools":            s.tools,
+		}, nil)
+	case MethodPrompt:
+		var params struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(orEmpty(msg.Params), &params); err != nil || strings.TrimSpace(params.Text) == "" {
+			s.respond(msg.ID, nil, &Error{Code: CodeInvalidParams, Message: "prompt needs a non-empty text"})
+			return
+		}
+		s.prompt(ctx, msg.ID, params.Text)
+	case MethodCancel:
+		s.mu.Lock()
+		cancel := s.cancel
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		s.respond(msg.ID, map[string]any{"cancelled": cancel != nil}, nil)
+	case MethodReset:
+		s.mu.Lock()
+		busy := s.cancel != nil
+		s.mu.Unlock()
+		if busy {
+			s.respond(msg.ID, nil, &Error{Code: CodeBusy, Message: "a prompt is running"})
+			return
+		}
+		s.agent.Reset()
+		s.respond(msg.ID, map[string]any{}, nil)
+	default:
+		s.respond(msg.ID, nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + msg.Method})
+	}
+}
+
+// prompt runs the turn in the background so that cancel and permission
+// responses can still be read.
+func (s *server) prompt(ctx context.Context, id json.RawMessage, text string) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		s.respond(id, nil, &Error{Code: CodeBusy, Message: "a prompt is already running"})
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ctx = loop.WithToolCallHandler(ctx, func(delta loop.ToolCallDelta) {
+			s.notify(MethodToolCallDelta, map[string]any{"id": delta.ID, "tool": delta.Name, "delta": delta.Fragment})
+		})
+		reply, err := s.agent.Stream(ctx, text, func(delta string) {
+			s.notify(MethodToken, map[string]any{"delta": delta})
+		})
+		cancelled := ctx.Err() != nil
+
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+		cancel()
+
+		switch {
+		case cancelled:
+			s.respond(id, nil, &Error{Code: CodeRequestCancelled, Message: "cancelled"})
+		case err != nil:
+			s.respond(id, nil, &Error{Code: CodeInternalError, Message: err.Error()})
+		default:
+			s.respond(id, map[string]any{"reply": reply}, nil)
+		}
+	}()
+}
+
+func (s *server) toolEvents(name string, next tools.Handler) tools.Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		s.notify(MethodToolStart, map[string]any{"tool": name, "args": args})
+		output, err := next(ctx, args)
+
+		params := map[string]any{"tool": name, "output": output}
+		if err != nil {
+			params["error"] = err.Error()
+		}
+		s.notify(MethodToolEnd, params)
+		return output, err
+	}
+}
+
+func (s *server) askPermission(ctx context.Context, req permission.Request) (bool, error) {
+	params := map[string]any{
+		"tool":    req.Tool,
+		"summary": req.Summary,
+		"detail":  req.Detail,
+	}
+	if req.Change != nil {
+		params["path"] = req.Change.Path
+		params["diff"] = req.Change.Diff
+	}
+	result, err := s.call(ctx, MethodPermissionRequest, params)
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var answer struct {
+		Approved bool `json:"approved"`
+	}
+	if err := json.Unmarshal(orEmpty(result), &answer); err != nil {
+		return false, fmt.Errorf("invalid %s result: %w", MethodPermissionRequest, err)
+	}
+	return answer.Approved, nil
+}
+
+// call sends a request to the client and waits for its response.
+func (s *server) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	s.mu.Lock()
+	s.nextID++
+	key := fmt.Sprintf("agent-%d", s.nextID)
+	answer := make(chan message, 1)
+	s.pending[key] = answer
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	id, _ := json.Marshal(key)
+	if err := s.send(message{ID: id, Method: method}, params); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-answer:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve hands a client response to the call waiting for it.
+func (s *server) resolve(msg message) {
+	var key string
+	if json.Unmarshal(msg.ID, &key) != nil {
+		return
+	}
+	s.mu.Lock()
+	answer, ok := s.pending[key]
+	s.mu.Unlock()
+	if ok {
+		answer <- msg
+	}
+}
+
+func (s *server) notify(method string, params any) {
+	_ = s.send(message{Method: method}, params)
+}
+
+// respond answers a request; notifications (no id) get no response.
+func (s *server) respond(id json.RawMessage, result any, rpcErr *Error) {
+	if len(id) == 0 {
+		return
+	}
+	msg := message{ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		} else {
+			msg.Result = raw
+		}
+	}
+	_ = s.send(msg, nil)
+}
+
+func (s *server) send(msg message, params any) error {
+	msg.JSONRPC = "2.0"
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("encode %s params: %w", msg.Method, err)
+		}
+		msg.Params = raw
+	}
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func orEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
@@ -0,0 +1,161 @@
+package stdio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+type testClient struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out chan message
+}
+
+func startServer(t *testing.T, registry *tools.Registry, runner loop.AgentRunner) *testClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(context.Background(), Config{Client: &openai.Client{}, Model: "test-model", Registry: registry, Runner: runner}, inR, outW)
+		outW.Close()
+	}()
+
+	c := &testClient{t: t, in: inW, out: make(chan message, 100)}
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var msg message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				t.Errorf("invalid line %q: %v", scanner.Text(), err)
+			}
+			c.out <- msg
+		}
+		close(c.out)
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return c
+}
+
+func (c *testClient) send(line string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) next() message {
+	c.t.Helper()
+	select {
+	case msg := <-c.out:
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+func TestServe_PromptStreamsEventsAndAsksPermission(t *testing.T) {
+	registry := tools.New()
+	registry.Register(openai.ChatCompletionToolParam{Type: "function", Function: shared.FunctionDefinitionParam{Name: "deploy"}},
+		func(ctx context.Context, _ map[string]any) (string, error) {
+			ok, err := permission.Contextual(nil).Approve(ctx, permission.Request{Tool: "deploy", Summary: "deploy to prod"})
+			if err != nil || !ok {
+				return "denied", err
+			}
+			return "deployed", nil
+		})
+	c := startServer(t, registry, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, r *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		loop.TokenHandlerFrom(ctx)("on it")
+		output, err := r.Dispatch(ctx, "deploy", map[string]any{"env": "prod"})
+		if err != nil {
+			return messages, err
+		}
+		return append(messages, openai.AssistantMessage("result: "+output)), nil
+	})
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"prompt","params":{"text":"deploy"}}`)
+	if msg := c.next(); msg.Error == nil || msg.Error.Code != CodeNotInitialized {
+		t.Fatalf("prompt before initialize = %+v", msg)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"client":"test"}}`)
+	if msg := c.next(); string(msg.Result) != `{"model":"test-model","protocol_version":1,"tools":["deploy"]}` {
+		t.Fatalf("initialize = %s", msg.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"prompt","params":{"text":"deploy"}}`)
+	var methods []string
+	for {
+		msg := c.next()
+		if msg.Method == MethodPermissionRequest {
+			if string(msg.Params) != `{"detail":"","summary":"deploy to prod","tool":"deploy"}` {
+				t.Fatalf("permission_request params = %s", msg.Params)
+			}
+			c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"approved":true}}`, msg.ID))
+		}
+		if msg.Method != "" {
+			methods = append(methods, msg.Method)
+			continue
+		}
+		if string(msg.ID) != "3" || string(msg.Result) != `{"reply":"result: deployed"}` {
+			t.Fatalf("prompt response = %+v", msg)
+		}
+		break
+	}
+	if fmt.Sprint(methods) != "[token tool_start permission_request tool_end]" {
+		t.Fatalf("messages before the reply = %v", methods)
+	}
+}
+
+func TestServe_CancelStopsThePrompt(t *testing.T) {
+	started := make(chan struct{})
+	c := startServer(t, nil, func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		close(started)
+		<-ctx.Done()
+		return messages, ctx.Err()
+	})
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	c.next()
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"prompt","params":{"text":"wait"}}`)
+	<-started
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"prompt","params":{"text":"again"}}`)
+	if msg := c.next(); string(msg.ID) != "3" || msg.Error == nil || msg.Error.Code != CodeBusy {
+		t.Fatalf("second prompt = %+v", msg)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"cancel"}`)
+	responses := map[string]message{}
+	for len(responses) < 2 {
+		msg := c.next()
+		responses[string(msg.ID)] = msg
+	}
+	if string(responses["4"].Result) != `{"cancelled":true}` {
+		t.Fatalf("cancel = %+v", responses["4"])
+	}
+	if e := responses["2"].Error; e == nil || e.Code != CodeRequestCancelled {
+		t.Fatalf("cancelled prompt = %+v", responses["2"])
+	}
+
+	c.send(`not json`)
+	if msg := c.next(); msg.Error == nil || msg.Error.Code != CodeParseError {
+		t.Fatalf("parse error = %+v", msg)
+	}
+}
@@ -0,0 +1,140 @@
+// Package sysprompt assembles the system prompt from ordered layers: the
+// built-in core instructions, the environment snapshot, the project's
+// AGENT.md, the user's own AGENT.md and mode-specific additions. Layers are
+// read on every Build, so edits to the files and mode switches apply on the
+// next turn; /prompt shows what the model receives.
+package sysprompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/command"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+)
+
+// ProjectFile is the name of the project instructions file at the
+// repository root.
+const ProjectFile = "AGENT.md"
+
+// Layer is one section of the system prompt.
+type Layer struct {
+	Name string
+	// Text returns the content of the layer; empty leaves it out.
+	Text func() string
+}
+
+// Static is a layer with fixed text.
+func Static(name, text string) Layer {
+	return Layer{Name: name, Text: func() string { return text }}
+}
+
+// File is a layer with the content of path under heading, left out while
+// the file is missing or empty.
+func File(name, path, heading string) Layer {
+	return Layer{Name: name, Text: func() string {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Sprintf("%s\n(unreadable: %v)", heading, err)
+			}
+			return ""
+		}
+		text := strings.TrimSpace(string(content))
+		if text == "" {
+			return ""
+		}
+		return heading + "\n" + text
+	}}
+}
+
+// Project is the layer of root's AGENT.md.
+func Project(root string) Layer {
+	return File("project", filepath.Join(root, ProjectFile), "Project instructions ("+ProjectFile+"):")
+}
+
+// User is the layer of the user's own instructions in ~/.agent/AGENT.md,
+// which apply to every project and come after the project's.
+func User() Layer {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Static("user", "")
+	}
+	return File("user", filepath.Join(home, ".agent", ProjectFile), "User instructions:")
+}
+
+// Part is the text of a layer at assembly time.
+type Part struct {
+	Name string
+	Text string
+}
+
+// Builder assembles layers in the order they were given.
+type Builder struct {
+	layers []Layer
+}
+
+// New returns a builder of layers.
+func New(layers ...Layer) *Builder {
+	return &Builder{layers: layers}
+}
+
+// Parts returns the non-empty layers.
+func (b *Builder) Parts() []Part {
+	var parts []Part
+	for _, l := range b.layers {
+		if text := strings.TrimSpace(l.Text()); text != "" {
+			parts = append(parts, Part{Name: l.Name, Text: text})
+		}
+	}
+	return parts
+}
+
+// Build returns the system prompt.
+func (b *Builder) Build() string {
+	parts := b.Parts()
+	texts := make([]string, len(parts))
+	for i, p := range parts {
+		texts[i] = p.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Command returns the /prompt command, which shows the assembled system
+// prompt with a header per layer, or one layer by name.
+func (b *Builder) Command(counter tokens.Counter) command.Command {
+	return command.Command{
+		Name:        "prompt",
+		Usage:       "[layer]",
+		Description: "show the system prompt the model receives, layer by layer",
+		Run: func(_ context.Context, args []string) (string, error) {
+			parts := b.Parts()
+			if len(args) > 0 {
+				for _, p := range parts {
+					if p.Name == args[0] {
+						return p.Text, nil
+					}
+				}
+				names := make([]string, len(b.layers))
+				for i, l := range b.layers {
+					names[i] = l.Name
+				}
+				return "", fmt.Errorf("layer %q is empty or unknown. layers: %s", args[0], strings.Join(names, ", "))
+			}
+			var out strings.Builder
+			total := 0
+			for _, p := range parts {
+				n := counter.Count(p.Text)
+				total += n
+				fmt.Fprintf(&out, "── %s (~%d tokens) ──\n%s\n\n", p.Name, n, p.Text)
+			}
+			fmt.Fprintf(&out, "total ~%d tokens in %d layers", total, len(parts))
+			return out.String(), nil
+		},
+	}
+}
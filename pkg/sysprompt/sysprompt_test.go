@@ -0,0 +1,47 @@
+package sysprompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+)
+
+func TestBuilder_AssemblesLayersInOrder(t *testing.T) {
+	root := t.TempDir()
+	mode := ""
+	b := New(
+		Static("core", "You are a coding agent."),
+		Static("environment", "OS: linux"),
+		Project(root),
+		Layer{Name: "mode", Text: func() string { return mode }},
+	)
+
+	if got := b.Build(); got != "You are a coding agent.\n\nOS: linux" {
+		t.Fatalf("without AGENT.md or mode:\n%s", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ProjectFile), []byte("Run make lint.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mode = "Read-only mode."
+	want := "You are a coding agent.\n\nOS: linux\n\nProject instructions (AGENT.md):\nRun make lint.\n\nRead-only mode."
+	if got := b.Build(); got != want {
+		t.Fatalf("Build() =\n%s\nwant\n%s", got, want)
+	}
+
+	cmd := b.Command(tokens.Heuristic{})
+	out, err := cmd.Run(context.Background(), nil)
+	if err != nil || !strings.Contains(out, "── project (~") || !strings.HasSuffix(out, "in 4 layers") {
+		t.Fatalf("/prompt = %q, %v", out, err)
+	}
+	if out, _ := cmd.Run(context.Background(), []string{"mode"}); out != "Read-only mode." {
+		t.Fatalf("/prompt mode = %q", out)
+	}
+	if _, err := cmd.Run(context.Background(), []string{"user"}); err == nil || !strings.Contains(err.Error(), "layers: core, environment, project, mode") {
+		t.Fatalf("/prompt user: %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+// Package telemetry reports anonymous usage counters, so the maintainers
+// can see which tools and providers need attention. It is strictly opt-in:
+// nothing is recorded unless the local settings file enables it with an
+// endpoint (see config.Telemetry), and AGENT_TELEMETRY=off turns it off
+// regardless.
+//
+// A report holds counters only: runs, model turns, calls per tool, tool
+// errors and failed runs by error class, with the command, the provider
+// name, the OS and the architecture. Prompts, replies, paths, arguments,
+// model output and error messages are never part of it, and tools that
+// are not built in (plugins, MCP servers) are counted as "other" so their
+// names stay private.
+//
+//	rec := telemetry.New(cfg.Telemetry, "batch", provider.Name(cfg.Provider), logf)
+//	defer rec.Flush(context.Background())
+//	registry = registry.WithMiddleware(telemetry.Middleware(telemetry.ToolNames(registry)...))
+//	runner := rec.Runner(loop.Run)
+//
+// Reports are best effort: an endpoint that fails or times out is reported
+// through logf and never fails the run.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/budget"
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/llm"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+// DisableEnv set to "off" disables telemetry even when the settings enable it.
+const DisableEnv = "AGENT_TELEMETRY"
+
+// Schema identifies the report layout.
+const Schema = "agent-telemetry/v1"
+
+// OtherTool counts the calls of tools that are not built in.
+const OtherTool = "other"
+
+// Error classes.
+const (
+	ErrorCanceled   = "canceled"
+	ErrorTimeout    = "timeout"
+	ErrorMaxTurns   = "max_turns"
+	ErrorBudget     = "budget"
+	ErrorRateLimit  = "rate_limit"
+	ErrorAuth       = "auth"
+	ErrorProvider   = "provider"
+	ErrorBadRequest = "bad_request"
+	ErrorNetwork    = "network"
+	ErrorOther      = "other"
+)
+
+const (
+	sendTimeout      = 10 * time.Second
+	maxResponseBytes = 1 << 16
+)
+
+// Report is what the endpoint receives.
+type Report struct {
+	Schema   string `json:"schema"`
+	Command  string `json:"command"`
+	Provider string `json:"provider"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	// Seconds is the time the counters cover.
+	Seconds int64 `json:"seconds"`
+	Runs    int   `json:"runs"`
+	// Turns counts model calls.
+	Turns      int            `json:"turns"`
+	Tools      map[string]int `json:"tools"`
+	ToolErrors map[string]int `json:"tool_errors"`
+	// Errors counts failed runs by error class (see Classify), and failed
+	// model calls as "model_" and the class.
+	Errors map[string]int `json:"errors"`
+}
+
+// Recorder counts usage and sends it to the endpoint. A nil *Recorder
+// records nothing, so callers need not check whether telemetry is enabled.
+type Recorder struct {
+	endpoint string
+	client   *http.Client
+	logf     func(format string, args ...any)
+
+	mu     sync.Mutex
+	report Report
+	since  time.Time
+}
+
+// New returns a recorder for command, e.g. "batch", or nil unless settings
+// enable telemetry and DisableEnv does not turn it off. provider is the
+// backend name, see provider.Name.
+func New(settings config.Telemetry, command, provider string, logf func(format string, args ...any)) *Recorder {
+	if !settings.Enabled || strings.EqualFold(strings.TrimSpace(os.Getenv(DisableEnv)), "off") {
+		return nil
+	}
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	r := &Recorder{
+		endpoint: strings.TrimSpace(settings.Endpoint),
+		client:   &http.Client{Timeout: sendTimeout},
+		logf:     logf,
+		report:   Report{Schema: Schema, Command: command, Provider: strings.ToLower(provider), OS: runtime.GOOS, Arch: runtime.GOARCH},
+	}
+	r.reset()
+	return r
+}
+
+func (r *Recorder) reset() {
+	r.report.Runs, r.report.Turns = 0, 0
+	r.report.Tools, r.report.ToolErrors, r.report.Errors = map[string]int{}, map[string]int{}, map[string]int{}
+	r.since = time.Now()
+}
+
+type recorderKey struct{}
+
+// Context returns ctx carrying r: the model calls made with it and the tool
+// calls dispatched through Middleware with it are counted.
+func (r *Recorder) Context(ctx context.Context) context.Context {
+	if r == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, recorderKey{}, r)
+	return llm.WithInterceptors(ctx, r.interceptor())
+}
+
+// Run counts one run that ended with err.
+func (r *Recorder) Run(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Runs++
+	if err != nil {
+		r.report.Errors[Classify(err)]++
+	}
+}
+
+// Runner wraps next so every run it makes is counted, with its model and
+// tool calls. Without a recorder next is returned as is.
+func (r *Recorder) Runner(next loop.AgentRunner) loop.AgentRunner {
+	if r == nil {
+		return next
+	}
+	return func(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		history, err := next(r.Context(ctx), client, model, messages, registry)
+		r.Run(err)
+		return history, err
+	}
+}
+
+func (r *Recorder) interceptor() llm.Interceptor {
+	return llm.Interceptor{
+		Complete: func(next llm.CompleteFunc) llm.CompleteFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				resp, err := next(ctx, params)
+				r.turn(err)
+				return resp, err
+			}
+		},
+		Stream: func(next llm.StreamFunc) llm.StreamFunc {
+			return func(ctx context.Context, params openai.ChatCompletionNewParams) llm.ChunkStream {
+				return &countedStream{ChunkStream: next(ctx, params), recorder: r}
+			}
+		},
+	}
+}
+
+func (r *Recorder) turn(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Turns++
+	if err != nil {
+		r.report.Errors["model_"+Classify(err)]++
+	}
+}
+
+// countedStream counts its call once, when it is closed.
+type countedStream struct {
+	llm.ChunkStream
+	recorder *Recorder
+	counted  bool
+}
+
+func (s *countedStream) Close() error {
+	if !s.counted {
+		s.counted = true
+		s.recorder.turn(s.ChunkStream.Err())
+	}
+	return s.ChunkStream.Close()
+}
+
+// Middleware counts the tool calls dispatched with a context from
+// Recorder.Context. Tools missing from builtin are counted as OtherTool.
+func Middleware(builtin ...string) tools.Middleware {
+	known := make(map[string]bool, len(builtin))
+	for _, name := range builtin {
+		known[name] = true
+	}
+	return func(name string, next tools.Handler) tools.Handler {
+		counted := name
+		if !known[name] {
+			counted = OtherTool
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			output, err := next(ctx, args)
+			if r, ok := ctx.Value(recorderKey{}).(*Recorder); ok {
+				r.mu.Lock()
+				r.report.Tools[counted]++
+				if err != nil {
+					r.report.ToolErrors[counted]++
+				}
+				r.mu.Unlock()
+			}
+			return output, err
+		}
+	}
+}
+
+// ToolNames returns the names of the tools registered on r so far, to pass
+// to Middleware before plugins and MCP tools are added.
+func ToolNames(r *tools.Registry) []string {
+	var names []string
+	for _, def := range r.Definitions() {
+		names = append(names, def.Function.Name)
+	}
+	return names
+}
+
+// Snapshot returns the counters recorded since the last Flush.
+func (r *Recorder) Snapshot() Report {
+	if r == nil {
+		return Report{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Seconds = int64(time.Since(r.since).Seconds())
+	report.Tools, report.ToolErrors, report.Errors = clone(r.report.Tools), clone(r.report.ToolErrors), clone(r.report.Errors)
+	return report
+}
+
+func clone(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Flush sends the counters recorded since the last Flush, unless nothing
+// ran, and starts counting afresh.
+func (r *Recorder) Flush(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	report := r.Snapshot()
+	if report.Runs == 0 && report.Turns == 0 {
+		return
+	}
+	r.mu.Lock()
+	r.reset()
+	r.mu.Unlock()
+	if err := r.post(ctx, report); err != nil {
+		r.logf("telemetry: %v", err)
+	}
+}
+
+func (r *Recorder) post(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	// Flushing at exit still reports a canceled command.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// Classify names the class of err without revealing its message.
+func Classify(err error) string {
+	var apiErr *openai.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, loop.ErrMaxTurns):
+		return ErrorMaxTurns
+	case errors.Is(err, budget.ErrExceeded):
+		return ErrorBudget
+	case errors.As(err, &apiErr):
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			return ErrorRateLimit
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return ErrorAuth
+		case code >= 500:
+			return ErrorProvider
+		default:
+			return ErrorBadRequest
+		}
+	case errors.As(err, &netErr):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
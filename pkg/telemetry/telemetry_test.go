@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+func TestNew_IsStrictlyOptIn(t *testing.T) {
+	t.Setenv(DisableEnv, "")
+	if r := New(config.Telemetry{Endpoint: "https://telemetry.example.com"}, "batch", "", nil); r != nil {
+		t.Fatal("recorder without enabled")
+	}
+	t.Setenv(DisableEnv, "off")
+	if r := New(config.Telemetry{Enabled: true, Endpoint: "https://telemetry.example.com"}, "batch", "", nil); r != nil {
+		t.Fatalf("recorder despite %s=off", DisableEnv)
+	}
+
+	var r *Recorder
+	runner := r.Runner(func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, _ *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		return messages, nil
+	})
+	if _, err := runner(context.Background(), nil, "m", nil, tools.New()); err != nil {
+		t.Fatal(err)
+	}
+	r.Flush(context.Background())
+}
+
+func TestRecorder_CountsRunsToolsAndErrorsAnonymously(t *testing.T) {
+	t.Setenv(DisableEnv, "")
+	var reports []Report
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var report Report
+		_ = json.NewDecoder(req.Body).Decode(&report)
+		reports = append(reports, report)
+	}))
+	defer srv.Close()
+	r := New(config.Telemetry{Enabled: true, Endpoint: srv.URL}, "batch", "Gemini", nil)
+
+	registry := tools.New()
+	registry.Register(tools.ReadFileToolDef(), func(context.Context, map[string]any) (string, error) { return "", errors.New("no such file") })
+	builtin := ToolNames(registry)
+	registry.Register(openai.ChatCompletionToolParam{Function: openai.FunctionDefinitionParam{Name: "deploy_acme_prod"}}, func(context.Context, map[string]any) (string, error) { return "ok", nil })
+	registry = registry.WithMiddleware(Middleware(builtin...))
+
+	runner := r.Runner(func(ctx context.Context, _ *openai.Client, _ string, messages []openai.ChatCompletionMessageParamUnion, registry *tools.Registry) ([]openai.ChatCompletionMessageParamUnion, error) {
+		_, _ = registry.Dispatch(ctx, "read_file", map[string]any{"path": "/home/alice/secret.txt"})
+		_, _ = registry.Dispatch(ctx, "deploy_acme_prod", nil)
+		return messages, fmt.Errorf("stopped: %w", loop.ErrMaxTurns)
+	})
+	for range 2 {
+		if _, err := runner(context.Background(), nil, "m", []openai.ChatCompletionMessageParamUnion{openai.UserMessage("fix the secret bug")}, registry); err == nil {
+			t.Fatal("the run's error was lost")
+		}
+	}
+	// Dispatching outside a recorded run counts nothing.
+	_, _ = registry.Dispatch(context.Background(), "read_file", nil)
+
+	r.Flush(context.Background())
+	r.Flush(context.Background())
+	if len(reports) != 1 {
+		t.Fatalf("reports = %+v, want one: nothing ran before the second flush", reports)
+	}
+	got := reports[0]
+	if got.Schema != Schema || got.Command != "batch" || got.Provider != "gemini" || got.Runs != 2 {
+		t.Fatalf("report = %+v", got)
+	}
+	if got.Tools["read_file"] != 2 || got.ToolErrors["read_file"] != 2 || got.Tools[OtherTool] != 2 || len(got.Tools) != 2 {
+		t.Fatalf("tools = %v, errors = %v", got.Tools, got.ToolErrors)
+	}
+	if got.Errors[ErrorMaxTurns] != 2 {
+		t.Fatalf("errors = %v", got.Errors)
+	}
+	data, _ := json.Marshal(got)
+	for _, private := range []string{"alice", "secret", "acme"} {
+		if strings.Contains(string(data), private) {
+			t.Fatalf("report leaks %q: %s", private, data)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for err, want := range map[error]string{
+		context.Canceled: ErrorCanceled,
+		fmt.Errorf("call: %w", context.DeadlineExceeded): ErrorTimeout,
+		&openai.Error{StatusCode: 429}:                   ErrorRateLimit,
+		&openai.Error{StatusCode: 401}:                   ErrorAuth,
+		&openai.Error{StatusCode: 503}:                   ErrorProvider,
+		&openai.Error{StatusCode: 400}:                   ErrorBadRequest,
+		errors.New("boom"):                               ErrorOther,
+	} {
+		if got := Classify(err); got != want {
+			t.Errorf("Classify(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package textdiff computes line-based unified diffs for showing proposed
+// file changes to the user.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultContext is the number of unchanged lines around each hunk.
+	DefaultContext = 3
+	// maxEditDistance bounds the diff search; beyond it the remaining lines
+	// are shown as one replacement instead.
+	maxEditDistance = 4000
+)
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	text string
+}
+
+// Unified returns a unified diff from before to after, labelled with the
+// given file names, or "" when they are equal. context < 0 uses
+// DefaultContext.
+func Unified(fromName, toName, before, after string, context int) string {
+	if before == after {
+		return ""
+	}
+	if context < 0 {
+		context = DefaultContext
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks(ops, context) {
+		writeHunk(&b, ops[h[0]:h[1]], h[2], h[3])
+	}
+	return b.String()
+}
+
+// Stats counts the added and deleted lines between before and after.
+func Stats(before, after string) (added, deleted int) {
+	for _, o := range diffLines(splitLines(before), splitLines(after)) {
+		switch o.kind {
+		case opInsert:
+			added++
+		case opDelete:
+			deleted++
+		}
+	}
+	return added, deleted
+}
+
+// splitLines keeps line terminators so a missing final newline shows up as
+// a change.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script turning a into b: common prefix and
+// suffix are trimmed, and the middle is diffed with Myers' algorithm.
+func diffLines(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{opEqual, line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{opEqual, line})
+	}
+	return ops
+}
+
+// myers finds a shortest edit script. trace[d] holds the furthest x reached
+// on diagonals -d-1..d+1 before step d, which is all backtracking needs.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+	limit := min(n+m, maxEditDistance)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	var rev []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, op{opEqual, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, op{opInsert, b[y-1]})
+			} else {
+				rev = append(rev, op{opDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]op, len(rev))
+	for i, o := range rev {
+		ops[len(rev)-1-i] = o
+	}
+	return ops
+}
+
+func replaceAll(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, op{opDelete, line})
+	}
+	for _, line := range b {
+		ops = append(ops, op{opInsert, line})
+	}
+	return ops
+}
+
+// hunks groups changes with their context. Each hunk is
+// [start op, end op, first old line, first new line] (lines 1-based).
+func hunks(ops []op, context int) [][4]int {
+	var out [][4]int
+	oldLine, newLine := 1, 1
+	lineAt := make([][2]int, len(ops)+1)
+	for i, o := range ops {
+		lineAt[i] = [2]int{oldLine, newLine}
+		if o.kind != opInsert {
+			oldLine++
+		}
+		if o.kind != opDelete {
+			newLine++
+		}
+	}
+	lineAt[len(ops)] = [2]int{oldLine, newLine}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+		start := max(0, i-context)
+		end := i
+		// Extend while the next change is within 2*context unchanged lines.
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(len(ops), end+context)
+				break
+			}
+			end = run
+		}
+		out = append(out, [4]int{start, end, lineAt[start][0], lineAt[start][1]})
+		i = end
+	}
+	return out
+}
+
+func writeHunk(b *strings.Builder, ops []op, oldStart, newStart int) {
+	var oldCount, newCount int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+	// An empty range starts at the line before it, as in diff -u.
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, o := range ops {
+		b.WriteByte(byte(o.kind))
+		b.WriteString(o.text)
+		if !strings.HasSuffix(o.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,124 @@
+package textdiff
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnified_SimpleChange(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	got := Unified("a/x.txt", "b/x.txt", before, after, -1)
+	want := "--- a/x.txt\n+++ b/x.txt\n" +
+		"@@ -2,9 +2,10 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n i\n j\n+k\n"
+	if got != want {
+		t.Fatalf("Unified() =\n%s\nwant\n%s", got, want)
+	}
+	if added, deleted := Stats(before, after); added != 2 || deleted != 1 {
+		t.Fatalf("Stats() = +%d -%d, want +2 -1", added, deleted)
+	}
+}
+
+func TestUnified_EdgeCases(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same\n", 3); got != "" {
+		t.Fatalf("equal inputs: %q", got)
+	}
+	if got := Unified("a", "b", "", "new\n", 3); !strings.HasSuffix(got, "@@ -0,0 +1 @@\n+new\n") {
+		t.Fatalf("created file:\n%s", got)
+	}
+	if got := Unified("a", "b", "x\n", "x", 3); !strings.Contains(got, "+x\n\\ No newline at end of file\n") {
+		t.Fatalf("missing final newline:\n%s", got)
+	}
+}
+
+// TestUnified_MatchesDiff compares hunks with diff -u on random edits.
+func TestUnified_MatchesDiff(t *testing.T) {
+	diffPath, err := exec.LookPath("diff")
+	if err != nil {
+		t.Skip("diff not installed")
+	}
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+	for i := range 50 {
+		var a, b []string
+		for range 30 + rng.Intn(40) {
+			line := string(rune('a'+rng.Intn(6))) + "\n"
+			a = append(a, line)
+			switch rng.Intn(6) {
+			case 0: // delete
+			case 1:
+				b = append(b, "new\n", line)
+			case 2:
+				b = append(b, "changed\n")
+			default:
+				b = append(b, line)
+			}
+		}
+		before, after := strings.Join(a, ""), strings.Join(b, "")
+		oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+		_ = os.WriteFile(oldPath, []byte(before), 0o644)
+		_ = os.WriteFile(newPath, []byte(after), 0o644)
+		out, _ := exec.Command(diffPath, "-u", oldPath, newPath).Output()
+
+		// Both are shortest edit scripts, but may pick different equal-cost
+		// alignments; compare the sizes and that the patch round-trips.
+		got := Unified("old", "new", before, after, 3)
+		wantAdded, wantDeleted := countLines(string(out))
+		gotAdded, gotDeleted := countLines(got)
+		if gotAdded != wantAdded || gotDeleted != wantDeleted {
+			t.Fatalf("case %d: +%d -%d, diff -u has +%d -%d", i, gotAdded, gotDeleted, wantAdded, wantDeleted)
+		}
+		if applied := apply(t, before, got); applied != after {
+			t.Fatalf("case %d: applying the diff gave\n%q\nwant\n%q\ndiff:\n%s", i, applied, after, got)
+		}
+	}
+}
+
+func countLines(diff string) (added, deleted int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			deleted++
+		}
+	}
+	return added, deleted
+}
+
+// apply is a minimal patch for diffs of newline-terminated text.
+func apply(t *testing.T, before, diff string) string {
+	t.Helper()
+	src := splitLines(before)
+	var out []string
+	next := 0
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case line == "", strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		case strings.HasPrefix(line, "@@"):
+			var oldStart int
+			if _, err := fmt.Sscanf(line, "@@ -%d", &oldStart); err != nil {
+				t.Fatalf("bad hunk header %q", line)
+			}
+			for next < oldStart-1 {
+				out = append(out, src[next])
+				next++
+			}
+		case line[0] == ' ':
+			out = append(out, src[next])
+			next++
+		case line[0] == '-':
+			next++
+		case line[0] == '+':
+			out = append(out, line[1:])
+		}
+	}
+	return strings.Join(append(out, src[next:]...), "")
+}
@@ -0,0 +1,105 @@
+package tokens
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pretokenizePattern splits text into the pieces BPE runs on. It is the
+// cl100k_base pattern (also used by Qwen) minus the `\s+(?!\S)` alternative,
+// which RE2 cannot express; runs of whitespace before a word therefore stay
+// whole instead of leaving their last space to the word, which can shift a
+// count by one token at such boundaries.
+var pretokenizePattern = regexp.MustCompile(
+	`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`,
+)
+
+// BPE counts tokens with byte-pair encoding over a tiktoken rank table.
+type BPE struct {
+	ranks map[string]int
+}
+
+// LoadTiktoken reads a tiktoken rank file: one "<base64 token> <rank>" pair
+// per line.
+func LoadTiktoken(path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open tokenizer file: %w", err)
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		encoded, rawRank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want \"<base64> <rank>\"", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(rawRank)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read tokenizer file: %w", err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("tokenizer file %s is empty", path)
+	}
+	return NewBPE(ranks), nil
+}
+
+// NewBPE builds a counter from token bytes to merge rank.
+func NewBPE(ranks map[string]int) *BPE {
+	return &BPE{ranks: ranks}
+}
+
+func (b *BPE) Count(text string) int {
+	total := 0
+	for _, piece := range pretokenizePattern.FindAllString(text, -1) {
+		if _, ok := b.ranks[piece]; ok {
+			total++
+			continue
+		}
+		total += b.countPiece(piece)
+	}
+	return total
+}
+
+// countPiece merges the lowest-ranked adjacent pair until no pair is in the
+// table; the remaining parts are the tokens.
+func (b *BPE) countPiece(piece string) int {
+	parts := make([]string, len(piece))
+	for i := range len(piece) {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := b.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts)
+}
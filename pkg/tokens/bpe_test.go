@@ -0,0 +1,52 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBPE_MergesByRank(t *testing.T) {
+	bpe := NewBPE(map[string]int{
+		"h": 0, "e": 1, "l": 2, "o": 3, " ": 4, "w": 5, "r": 6, "d": 7,
+		"ll": 8, "he": 9, "hell": 10, "hello": 11, " w": 12, "or": 13,
+	})
+
+	// "hello" is a whole token; " world" becomes " w" + "or" + "l" + "d".
+	if got := bpe.Count("hello world"); got != 5 {
+		t.Fatalf("Count = %d, want 5", got)
+	}
+	// Bytes without merges count one token each.
+	if got := bpe.Count("dr"); got != 2 {
+		t.Fatalf("Count = %d, want 2", got)
+	}
+}
+
+func TestLoadTiktoken(t *testing.T) {
+	var b strings.Builder
+	for rank, token := range []string{"a", "b", "ab"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("write rank file: %v", err)
+	}
+
+	bpe, err := LoadTiktoken(path)
+	if err != nil {
+		t.Fatalf("LoadTiktoken: %v", err)
+	}
+	if got := bpe.Count("abab"); got != 2 {
+		t.Fatalf("Count = %d, want 2", got)
+	}
+
+	if err := os.WriteFile(path, []byte("not-a-rank-line\n"), 0o644); err != nil {
+		t.Fatalf("write rank file: %v", err)
+	}
+	if _, err := LoadTiktoken(path); err == nil {
+		t.Fatal("expected error for malformed rank file")
+	}
+}
@@ -0,0 +1,159 @@
+// Package tokens counts tokens for context-window management, truncation and
+// cost estimates.
+//
+// Two counters are available:
+//
+//   - BPE loads a tiktoken-format rank file (cl100k_base.tiktoken,
+//     qwen.tiktoken, ...) and counts exactly like the model's tokenizer, up to
+//     rare differences at whitespace boundaries (see BPE).
+//   - Heuristic needs no vocabulary and is script-aware: ASCII text averages
+//     about four characters per token, CJK about one character per token.
+//
+// Default picks BPE when AGENT_TOKENIZER_FILE points at a rank file and falls
+// back to Heuristic otherwise.
+package tokens
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+)
+
+// Counter counts the tokens in a piece of text.
+type Counter interface {
+	Count(text string) int
+}
+
+// Per-message framing overhead (role and separators) in chat formats.
+const messageOverheadTokens = 4
+
+var (
+	defaultOnce    sync.Once
+	defaultCounter Counter
+)
+
+// Default returns the process-wide counter, configured from the environment
+// on first use. A rank file that fails to load falls back to Heuristic with a
+// warning on stderr.
+func Default() Counter {
+	defaultOnce.Do(func() {
+		defaultCounter = Heuristic{}
+		path := strings.TrimSpace(os.Getenv("AGENT_TOKENIZER_FILE"))
+		if path == "" {
+			return
+		}
+		bpe, err := LoadTiktoken(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tokens: %v; falling back to heuristic counting\n", err)
+			return
+		}
+		defaultCounter = bpe
+	})
+	return defaultCounter
+}
+
+// Heuristic estimates token counts without a vocabulary.
+type Heuristic struct{}
+
+func (Heuristic) Count(text string) int {
+	var ascii, other int
+	tokens := 0
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			tokens++
+		default:
+			other++
+		}
+	}
+	// Round up so non-empty text never counts as zero.
+	return tokens + (ascii+3)/4 + (other+1)/2
+}
+
+// CountMessages counts the tokens of a chat history: text content, tool call
+// names and arguments, plus a fixed framing overhead per message.
+func CountMessages(c Counter, messages []openai.ChatCompletionMessageParamUnion) int {
+	total := 0
+	for _, msg := range messages {
+		total += messageOverheadTokens
+		for _, text := range messageTexts(msg) {
+			total += c.Count(text)
+		}
+	}
+	return total
+}
+
+func messageTexts(msg openai.ChatCompletionMessageParamUnion) []string {
+	switch {
+	case msg.OfSystem != nil:
+		return textParts(msg.OfSystem.Content.OfString.Value, msg.OfSystem.Content.OfArrayOfContentParts)
+	case msg.OfDeveloper != nil:
+		return textParts(msg.OfDeveloper.Content.OfString.Value, msg.OfDeveloper.Content.OfArrayOfContentParts)
+	case msg.OfUser != nil:
+		texts := []string{msg.OfUser.Content.OfString.Value}
+		for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				texts = append(texts, part.OfText.Text)
+			}
+		}
+		return texts
+	case msg.OfAssistant != nil:
+		texts := []string{msg.OfAssistant.Content.OfString.Value}
+		for _, part := range msg.OfAssistant.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				texts = append(texts, part.OfText.Text)
+			}
+		}
+		for _, tc := range msg.OfAssistant.ToolCalls {
+			texts = append(texts, tc.Function.Name, tc.Function.Arguments)
+		}
+		return texts
+	case msg.OfTool != nil:
+		return textParts(msg.OfTool.Content.OfString.Value, msg.OfTool.Content.OfArrayOfContentParts)
+	default:
+		// Unknown message kinds: count their serialized form.
+		data, _ := json.Marshal(msg)
+		return []string{string(data)}
+	}
+}
+
+func textParts(text string, parts []openai.ChatCompletionContentPartTextParam) []string {
+	texts := []string{text}
+	for _, part := range parts {
+		texts = append(texts, part.Text)
+	}
+	return texts
+}
+
+// Truncate returns the longest prefix of text, cut at a rune boundary, that
+// fits in maxTokens, followed by a marker when anything was dropped.
+func Truncate(c Counter, text string, maxTokens int) string {
+	if maxTokens <= 0 || c.Count(text) <= maxTokens {
+		return text
+	}
+	// Binary search over rune offsets for the longest fitting prefix.
+	offsets := make([]int, 0, len(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+
+	lo, hi := 0, len(offsets)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if c.Count(text[:offsets[mid]]) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return text[:offsets[lo]] + fmt.Sprintf("\n... (truncated to %d tokens)", maxTokens)
+}
@@ -0,0 +1,53 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestHeuristic_IsScriptAware(t *testing.T) {
+	if got := (Heuristic{}).Count("hello world!"); got != 3 {
+		t.Fatalf("ASCII count = %d, want 3", got)
+	}
+	if got := (Heuristic{}).Count("上下文管理"); got != 5 {
+		t.Fatalf("CJK count = %d, want one token per character", got)
+	}
+	if got := (Heuristic{}).Count(""); got != 0 {
+		t.Fatalf("empty count = %d", got)
+	}
+}
+
+func TestCountMessages_IncludesToolCallsAndOverhead(t *testing.T) {
+	assistant := openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{{
+			ID:       "call-1",
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "bash", Arguments: `{"command":"ls"}`},
+		}},
+	}
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("abcd"),
+		{OfAssistant: &assistant},
+	}
+
+	// 2 * overhead + "abcd" (1) + "bash" (1) + arguments (4)
+	if got := CountMessages(Heuristic{}, messages); got != 2*messageOverheadTokens+6 {
+		t.Fatalf("CountMessages = %d", got)
+	}
+}
+
+func TestTruncate_FitsBudgetAtRuneBoundary(t *testing.T) {
+	text := strings.Repeat("数据", 20)
+	got := Truncate(Heuristic{}, text, 10)
+	kept, marker, ok := strings.Cut(got, "\n... (truncated")
+	if !ok || marker == "" {
+		t.Fatalf("expected truncation marker, got %q", got)
+	}
+	if kept != strings.Repeat("数据", 5) {
+		t.Fatalf("kept %q, want 10 characters", kept)
+	}
+	if Truncate(Heuristic{}, "short", 10) != "short" {
+		t.Fatal("text within budget must be unchanged")
+	}
+}
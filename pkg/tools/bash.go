@@ -4,24 +4,170 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
 )
 
-var dangerousPatterns = []string{
-	"rm -rf /", "sudo", "shutdown", "reboot", "> /dev/",
+// maxBashOutputTokens caps command output fed back to the model.
+const maxBashOutputTokens = 12000
+
+// dangerousPatterns are blocked by shell family wherever they appear in a
+// command. Windows shells ignore case, so their patterns are lower case and
+// matched against the lowered command.
+var dangerousPatterns = map[string][]string{
+	sandbox.FamilyPOSIX:      {"sudo", "shutdown", "reboot", "> /dev/"},
+	sandbox.FamilyPowerShell: {"format-volume", "clear-disk", "stop-computer", "restart-computer", "shutdown"},
+	sandbox.FamilyCmd:        {"format c:", "diskpart", "shutdown"},
+}
+
+// quoteChars are removed before matching, by shell family: the shell drops
+// them, so s'u'do and "sudo" still run sudo.
+var quoteChars = map[string]*strings.Replacer{
+	sandbox.FamilyPOSIX:      strings.NewReplacer(`'`, "", `"`, "", `\`, ""),
+	sandbox.FamilyPowerShell: strings.NewReplacer(`'`, "", `"`, "", "`", ""),
+	sandbox.FamilyCmd:        strings.NewReplacer(`"`, "", "^", ""),
+}
+
+// treeDelete is a family's way of deleting a whole tree: one of names,
+// called with arguments that dangerous accepts.
+type treeDelete struct {
+	names     []string
+	dangerous func(args []string) bool
+}
+
+// treeDeletes are blocked by shell family when they recurse into an
+// absolute path. Their flags are read one by one, so neither order nor
+// grouping matters: rm -rf /, rm -fr / and rm -r -f / are all blocked.
+var treeDeletes = map[string]treeDelete{
+	sandbox.FamilyPOSIX:      {names: []string{"rm"}, dangerous: posixTreeDelete},
+	sandbox.FamilyPowerShell: {names: []string{"remove-item", "ri", "rm", "rmdir", "rd", "del", "erase"}, dangerous: powerShellTreeDelete},
+	sandbox.FamilyCmd:        {names: []string{"rd", "rmdir"}, dangerous: cmdTreeDelete},
+}
+
+var (
+	// commandBoundary splits a command line into the simple commands a
+	// tree delete may hide in, e.g. after && or inside $(...).
+	commandBoundary = regexp.MustCompile("[;&|(){}`\n]")
+	// driveRoot matches an absolute Windows path such as c:\ or d:/data.
+	driveRoot = regexp.MustCompile(`^[a-z]:[\\/]`)
+)
+
+// isDangerous reports whether command matches a dangerous pattern or tree
+// delete of the shell family once quotes and escapes are removed and runs of
+// whitespace are collapsed, so neither "rm  -rf /" nor "su\do" slips through.
+// Under AllowDangerousCommands (--dangerously-skip-permissions) the check is
+// off.
+func isDangerous(family, command string) bool {
+	checked := command
+	if quotes := quoteChars[family]; quotes != nil {
+		checked = quotes.Replace(checked)
+	}
+	checked = strings.Join(strings.Fields(checked), " ")
+	if family != sandbox.FamilyPOSIX {
+		checked = strings.ToLower(checked)
+	}
+	for _, pattern := range dangerousPatterns[family] {
+		if strings.Contains(checked, pattern) {
+			return true
+		}
+	}
+	del, ok := treeDeletes[family]
+	if !ok {
+		return false
+	}
+	for _, part := range commandBoundary.Split(checked, -1) {
+		words := strings.Fields(part)
+		for i, word := range words {
+			name := word[strings.LastIndexAny(word, `/\`)+1:]
+			if slices.Contains(del.names, name) && del.dangerous(words[i+1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// posixTreeDelete reports whether rm args recurse into an absolute path.
+func posixTreeDelete(args []string) bool {
+	recursive, absolute, options := false, false, true
+	for _, arg := range args {
+		switch {
+		case options && arg == "--":
+			options = false
+		case options && strings.HasPrefix(arg, "--"):
+			// GNU rm takes any unambiguous prefix of a long option.
+			recursive = recursive || len(arg) > 2 && strings.HasPrefix("--recursive", arg)
+		case options && strings.HasPrefix(arg, "-") && arg != "-":
+			recursive = recursive || strings.ContainsAny(arg, "rR")
+		default:
+			absolute = absolute || strings.HasPrefix(arg, "/")
+		}
+	}
+	return recursive && absolute
+}
+
+// powerShellTreeDelete reports whether Remove-Item args (lower case)
+// recurse into a drive path. Parameters may be abbreviated and take their
+// value after a colon, as in -recurse:$true or -path:c:\.
+func powerShellTreeDelete(args []string) bool {
+	recursive, absolute := false, false
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			absolute = absolute || driveRoot.MatchString(arg)
+			continue
+		}
+		name, value, _ := strings.Cut(arg, ":")
+		recursive = recursive || len(name) > 1 && strings.HasPrefix("-recurse", name)
+		absolute = absolute || driveRoot.MatchString(value)
+	}
+	return recursive && absolute
+}
+
+// cmdTreeDelete reports whether rd args (lower case) remove a drive path
+// with /s, given alone or grouped as in /s/q.
+func cmdTreeDelete(args []string) bool {
+	recursive, absolute := false, false
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "/") {
+			recursive = recursive || slices.Contains(strings.Split(arg[1:], "/"), "s")
+			continue
+		}
+		absolute = absolute || driveRoot.MatchString(arg)
+	}
+	return recursive && absolute
 }
 
-// BashToolDef returns the definition for the bash tool.
+// BashToolDef returns the definition for the bash tool running commands in
+// the shell of sandbox.ShellFromEnv.
 func BashToolDef() openai.ChatCompletionToolParam {
+	return ShellToolDef(sandbox.ShellFromEnv())
+}
+
+// ShellToolDef returns the definition for the bash tool, described for
+// shell so the model writes commands in its syntax. The tool keeps the name
+// bash whatever the shell.
+func ShellToolDef(shell sandbox.Shell) openai.ChatCompletionToolParam {
+	var description string
+	switch shell.Family {
+	case sandbox.FamilyPowerShell:
+		description = fmt.Sprintf("Run a PowerShell command (%s). Use PowerShell syntax, e.g. Get-ChildItem, Select-String, not bash.", shell.Name())
+	case sandbox.FamilyCmd:
+		description = "Run a Windows cmd.exe command. Use cmd syntax, e.g. dir, type, findstr, not bash."
+	default:
+		description = fmt.Sprintf("Run a shell command (%s).", shell.Name())
+	}
 	return openai.ChatCompletionToolParam{
 		Type: "function",
 		Function: shared.FunctionDefinitionParam{
 			Name:        "bash",
-			Description: openai.String("Run a shell command."),
+			Description: openai.String(description),
 			Parameters: openai.FunctionParameters{
 				"type": "object",
 				"properties": map[string]any{
@@ -33,32 +179,130 @@ func BashToolDef() openai.ChatCompletionToolParam {
 	}
 }
 
-// BashHandler executes the bash command.
+// OfflineBashToolDef returns the bash tool for executors without network
+// access (sandbox.Offline): the model may ask for network access for one
+// command, which NetworkGate lets the user grant.
+func OfflineBashToolDef() openai.ChatCompletionToolParam {
+	def := BashToolDef()
+	def.Function.Description = openai.String(def.Function.Description.Value + " Commands run without network access unless allow_network is set and the user approves.")
+	def.Function.Parameters = openai.FunctionParameters{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{"type": "string"},
+			"allow_network": map[string]any{
+				"type":        "boolean",
+				"description": "Ask the user to run this command with network access, e.g. to download dependencies.",
+			},
+		},
+		"required": []string{"command"},
+	}
+	return def
+}
+
+// NetworkGate asks approver before a bash command runs with allow_network
+// set and, when approved, lets it reach the network (sandbox.WithNetwork).
+// A nil approver denies every request.
+func NetworkGate(approver permission.Approver) Middleware {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+	return func(name string, next Handler) Handler {
+		if name != "bash" {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			if allow, _ := args["allow_network"].(bool); !allow {
+				return next(ctx, args)
+			}
+			command, _ := args["command"].(string)
+			approved, err := approver.Approve(ctx, permission.Request{
+				Tool:    "bash",
+				Summary: "run a command with network access",
+				Detail:  command,
+				Command: command,
+			})
+			if err != nil {
+				return "", fmt.Errorf("approval failed: %w", err)
+			}
+			if !approved {
+				return "Network access denied: the command was not run. Run it without allow_network, or find a way that needs no network.", nil
+			}
+			return next(sandbox.WithNetwork(ctx), args)
+		}
+	}
+}
+
+type dangerousKey struct{}
+
+// AllowDangerousCommands lets bash run the commands isDangerous blocks. It is
+// part of --dangerously-skip-permissions for disposable containers and CI
+// jobs: the entry points then print the warning banner and refuse to start
+// without the audit log, which records every command.
+func AllowDangerousCommands() Middleware {
+	return func(name string, next Handler) Handler {
+		if name != "bash" {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			return next(context.WithValue(ctx, dangerousKey{}, true), args)
+		}
+	}
+}
+
+// BashHandler executes the bash command on the host, without the agent's
+// credentials in its environment (see sandbox.LocalFromEnv).
 func BashHandler(ctx context.Context, args map[string]any) (string, error) {
+	return runBash(ctx, sandbox.LocalFromEnv(), args)
+}
+
+// NewBashHandler creates a bash tool handler that runs commands through the
+// given execution backend (e.g. a Docker sandbox).
+func NewBashHandler(executor sandbox.Executor) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if executor == nil {
+			return "", fmt.Errorf("bash executor is not configured")
+		}
+		return runBash(ctx, executor, args)
+	}
+}
+
+func runBash(ctx context.Context, executor sandbox.Executor, args map[string]any) (string, error) {
 	command, ok := args["command"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing or invalid 'command' argument")
 	}
 
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(command, pattern) {
-			return "Error: Dangerous command blocked", nil
-		}
+	shell := sandbox.ShellOf(executor)
+	if allowed, _ := ctx.Value(dangerousKey{}).(bool); !allowed && isDangerous(shell.Family, command) {
+		return "Error: Dangerous command blocked", nil
 	}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	cmd.Dir, _ = os.Getwd() // Default to current working directory
-	out, err := cmd.CombinedOutput()
+	dir, _ := os.Getwd() // Default to current working directory
+	run := command
+	workDir := workDirFrom(ctx)
+	if workDir != nil {
+		dir = workDir.Dir()
+		if shell.POSIX() {
+			run = trackCwd(command)
+		}
+	}
+	out, err := executor.Exec(ctx, run, dir)
+	reportExitStatus(ctx, err)
 
-	result := strings.TrimSpace(string(out))
+	result, moved := string(out), ""
+	if workDir != nil {
+		result, moved = followCwd(ctx, workDir, result)
+	}
+	result = strings.TrimSpace(result)
 	if err != nil && result == "" {
 		result = fmt.Sprintf("Error: %s", err)
 	}
 	if result == "" {
 		result = "(no output)"
 	}
-	if len(result) > 50000 {
-		result = result[:50000]
+	result = tokens.Truncate(tokens.Default(), result, maxBashOutputTokens)
+	if moved != "" {
+		result += "\n" + moved
 	}
 	return result, nil
 }
@@ -2,8 +2,12 @@ package tools
 
 import (
 	"context"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
 )
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -85,3 +89,300 @@ func TestBashHandler_NoOutput(t *testing.T) {
 		t.Errorf("expected '(no output)', got %q", result)
 	}
 }
+
+// UT-BASH-SANDBOX: NewBashHandler 通过注入的执行后端运行命令。
+func TestNewBashHandler_UsesExecutor(t *testing.T) {
+	executor := &recordingExecutor{output: "from sandbox\n"}
+
+	result, err := NewBashHandler(executor)(context.Background(), map[string]any{
+		"command": "uname -a",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "from sandbox" {
+		t.Errorf("expected executor output, got %q", result)
+	}
+	if executor.command != "uname -a" {
+		t.Errorf("executor received %q", executor.command)
+	}
+}
+
+// UT-BASH-EXIT: bash 通过 WithExitStatus 上报进程退出码。
+func TestBashHandler_ReportsExitStatus(t *testing.T) {
+	ctx, exitStatus := WithExitStatus(context.Background())
+
+	if _, err := BashHandler(ctx, map[string]any{"command": "exit 7"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code, ok := exitStatus(); !ok || code != 7 {
+		t.Fatalf("exit status = %d (reported %v), want 7", code, ok)
+	}
+}
+
+// UT-BASH-EXIT-NESTED: 嵌套的 WithExitStatus（如审计与指标中间件）都能读到退出码。
+func TestBashHandler_ReportsExitStatusToNestedSinks(t *testing.T) {
+	outer, outerStatus := WithExitStatus(context.Background())
+	inner, innerStatus := WithExitStatus(outer)
+
+	if _, err := BashHandler(inner, map[string]any{"command": "exit 3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, status := range []func() (int, bool){outerStatus, innerStatus} {
+		if code, ok := status(); !ok || code != 3 {
+			t.Fatalf("exit status = %d (reported %v), want 3", code, ok)
+		}
+	}
+}
+
+// UT-BASH-NETWORK: allow_network 需经审批，批准后命令带着 sandbox.WithNetwork 执行。
+func TestNetworkGate_AsksBeforeAllowingNetwork(t *testing.T) {
+	var networked []bool
+	executor := networkExecutor(func(ctx context.Context) { networked = append(networked, sandbox.NetworkAllowed(ctx)) })
+	var asked []permission.Request
+	answer := false
+	approver := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+		asked = append(asked, req)
+		return answer, nil
+	})
+	registry := New()
+	registry.Register(OfflineBashToolDef(), NewBashHandler(executor))
+	registry = registry.WithMiddleware(NetworkGate(approver))
+
+	run := func(args map[string]any) string {
+		t.Helper()
+		out, err := registry.Dispatch(context.Background(), "bash", args)
+		if err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+		return out
+	}
+	run(map[string]any{"command": "go build ./..."})
+	if out := run(map[string]any{"command": "go mod download", "allow_network": true}); !strings.Contains(out, "Network access denied") {
+		t.Fatalf("denied request ran: %q", out)
+	}
+	answer = true
+	run(map[string]any{"command": "go mod download", "allow_network": true})
+
+	if len(networked) != 2 || networked[0] || !networked[1] {
+		t.Fatalf("network allowed per run = %v, want [false true]", networked)
+	}
+	if len(asked) != 2 || asked[1].Command != "go mod download" {
+		t.Fatalf("approval requests = %+v", asked)
+	}
+}
+
+// UT-BASH-SHELL: 工具描述与危险命令规则随 shell 变化（PowerShell 不区分大小写）。
+func TestShellToolDef_FollowsShell(t *testing.T) {
+	pwsh := sandbox.ShellFor("pwsh")
+	if desc := ShellToolDef(pwsh).Function.Description.Value; !strings.Contains(desc, "PowerShell") {
+		t.Errorf("PowerShell description = %q", desc)
+	}
+	if desc := ShellToolDef(sandbox.ShellFor("zsh")).Function.Description.Value; desc != "Run a shell command (zsh)." {
+		t.Errorf("zsh description = %q", desc)
+	}
+
+	executor := &recordingExecutor{output: "ran"}
+	handler := NewBashHandler(sandbox.Local{Shell: pwsh})
+	result, err := handler(context.Background(), map[string]any{"command": `Remove-Item -Recurse -Force C:\`})
+	if err != nil || result != "Error: Dangerous command blocked" {
+		t.Errorf("PowerShell root delete = %q, %v", result, err)
+	}
+	// The POSIX rules do not apply to PowerShell and the other way round.
+	if result, _ := NewBashHandler(executor)(context.Background(), map[string]any{"command": "format-volume -DriveLetter D"}); result != "ran" {
+		t.Errorf("bash command = %q", result)
+	}
+}
+
+// UT-BASH-SKIP: --dangerously-skip-permissions 下危险命令不再被拦截，其他工具不受影响。
+func TestAllowDangerousCommands(t *testing.T) {
+	executor := &recordingExecutor{output: "ran"}
+	handler := NewBashHandler(executor)
+	if result, _ := handler(context.Background(), map[string]any{"command": "sudo reboot"}); result != "Error: Dangerous command blocked" {
+		t.Fatalf("without the middleware = %q", result)
+	}
+	allow := AllowDangerousCommands()
+	result, err := allow("bash", handler)(context.Background(), map[string]any{"command": "sudo reboot"})
+	if err != nil || result != "ran" || executor.command != "sudo reboot" {
+		t.Errorf("with the middleware = %q, %v, ran %q", result, err, executor.command)
+	}
+	called := false
+	other := func(ctx context.Context, _ map[string]any) (string, error) {
+		called = ctx.Value(dangerousKey{}) == nil
+		return "", nil
+	}
+	allow("read_file", other)(context.Background(), nil)
+	if !called {
+		t.Error("read_file saw the bash override")
+	}
+}
+
+type networkExecutor func(ctx context.Context)
+
+func (f networkExecutor) Exec(ctx context.Context, _, _ string) ([]byte, error) {
+	f(ctx)
+	return []byte("ok"), nil
+}
+
+type recordingExecutor struct {
+	output  string
+	command string
+}
+
+func (r *recordingExecutor) Exec(_ context.Context, command, _ string) ([]byte, error) {
+	r.command = command
+	return []byte(r.output), nil
+}
+
+// dangerCorpus labels commands by hand. It is the oracle of FuzzIsDangerous:
+// rewrites of these commands must keep their label, whatever isDangerous
+// itself makes of the rewrite.
+var dangerCorpus = []struct {
+	family    string
+	command   string
+	dangerous bool
+}{
+	{sandbox.FamilyPOSIX, "rm -rf /", true},
+	{sandbox.FamilyPOSIX, "rm -fr /", true},
+	{sandbox.FamilyPOSIX, "rm -r -f /", true},
+	{sandbox.FamilyPOSIX, "rm -Rf /*", true},
+	{sandbox.FamilyPOSIX, "rm --recursive --force /", true},
+	{sandbox.FamilyPOSIX, "rm --rec -f /home", true},
+	{sandbox.FamilyPOSIX, "/bin/rm -rf /", true},
+	{sandbox.FamilyPOSIX, "echo $(rm -rf /)", true},
+	{sandbox.FamilyPOSIX, "sudo ls", true},
+	{sandbox.FamilyPOSIX, "shutdown -h now", true},
+	{sandbox.FamilyPOSIX, "reboot", true},
+	{sandbox.FamilyPOSIX, "cat image > /dev/sda", true},
+	{sandbox.FamilyPOSIX, "rm -rf build", false},
+	{sandbox.FamilyPOSIX, "rm -rf ./dist", false},
+	{sandbox.FamilyPOSIX, "rm -f /tmp/x.log", false},
+	{sandbox.FamilyPOSIX, "rm -- -r", false},
+	{sandbox.FamilyPOSIX, "ls -la /", false},
+	{sandbox.FamilyPOSIX, "grep -r TODO /src", false},
+	{sandbox.FamilyPOSIX, "go test ./...", false},
+	{sandbox.FamilyPowerShell, `Remove-Item -Recurse -Force C:\`, true},
+	{sandbox.FamilyPowerShell, `Remove-Item -Force -Recurse C:\`, true},
+	{sandbox.FamilyPowerShell, `ri -r -fo C:\Windows`, true},
+	{sandbox.FamilyPowerShell, `Remove-Item -Path C:\ -Recurse`, true},
+	{sandbox.FamilyPowerShell, `Remove-Item -Recurse:$true D:/data`, true},
+	{sandbox.FamilyPowerShell, "Stop-Computer -Force", true},
+	{sandbox.FamilyPowerShell, "Format-Volume -DriveLetter D", true},
+	{sandbox.FamilyPowerShell, `Remove-Item -Recurse .\build`, false},
+	{sandbox.FamilyPowerShell, `Remove-Item C:\temp\x.txt`, false},
+	{sandbox.FamilyPowerShell, `Get-ChildItem -Recurse C:\`, false},
+	{sandbox.FamilyCmd, `rd /s /q c:\`, true},
+	{sandbox.FamilyCmd, `rmdir /q /s C:\`, true},
+	{sandbox.FamilyCmd, `RD /S/Q D:\data`, true},
+	{sandbox.FamilyCmd, "format c:", true},
+	{sandbox.FamilyCmd, "diskpart", true},
+	{sandbox.FamilyCmd, `rd /s /q build`, false},
+	{sandbox.FamilyCmd, `rd C:\empty`, false},
+	{sandbox.FamilyCmd, `dir /s c:\`, false},
+}
+
+// UT-BASH-DANGER: 危险命令按词与参数判断，参数顺序与分组（rm -fr /、rm -r -f /）不影响结果。
+func TestIsDangerous_Corpus(t *testing.T) {
+	for _, tc := range dangerCorpus {
+		if got := isDangerous(tc.family, tc.command); got != tc.dangerous {
+			t.Errorf("%s: isDangerous(%q) = %v, want %v", tc.family, tc.command, got, tc.dangerous)
+		}
+	}
+}
+
+// FUZZ-BASH-DANGER: 语料命令经 shell 等价改写（空白、引号、转义、大小写、参数拆分、前后命令）后仍保持人工标注。
+func FuzzIsDangerous(f *testing.F) {
+	for i := range dangerCorpus {
+		f.Add(uint8(i), []byte{})
+		f.Add(uint8(i), []byte{1, 2, 3, 4, 5, 6, 7, 8, 9})
+		f.Add(uint8(i), []byte{255, 7, 130, 66, 3, 91, 12, 200})
+	}
+	f.Fuzz(func(t *testing.T, index uint8, choices []byte) {
+		entry := dangerCorpus[int(index)%len(dangerCorpus)]
+		command := rewriteCommand(entry.family, entry.command, choices)
+		if got := isDangerous(entry.family, command); got != entry.dangerous {
+			t.Errorf("%s: isDangerous(%q) = %v, want %v (rewritten from %q)", entry.family, command, got, entry.dangerous, entry.command)
+		}
+	})
+}
+
+// rewriteCommand rewrites command into one the shell of family runs the same
+// way, each choice picking how the next word is spaced, split, quoted or
+// cased, and which commands run before and after it.
+func rewriteCommand(family, command string, choices []byte) string {
+	next := func() int {
+		if len(choices) == 0 {
+			return 0
+		}
+		c := choices[0]
+		choices = choices[1:]
+		return int(c)
+	}
+	surroundings := map[string][2][]string{
+		sandbox.FamilyPOSIX:      {{"", "cd /tmp && ", "true; ", "X=1 "}, {"", " && echo done", "; ls", " | cat"}},
+		sandbox.FamilyPowerShell: {{"", "cd $env:TEMP; ", "Write-Output hi | Out-Null; "}, {"", "; Get-Date", " | Out-Null"}},
+		sandbox.FamilyCmd:        {{"", "cd build & ", "echo hi && "}, {"", " & echo done", " || echo failed"}},
+	}[family]
+	spaces := []string{" ", "\t", "  ", " \t "}
+
+	var b strings.Builder
+	b.WriteString(surroundings[0][next()%len(surroundings[0])])
+	for i, word := range strings.Fields(command) {
+		if i > 0 {
+			b.WriteString(spaces[next()%len(spaces)])
+		}
+		choice := next()
+		switch family {
+		case sandbox.FamilyPOSIX:
+			if strings.ContainsAny(word, "$()<>|;&*") {
+				break
+			}
+			if len(word) > 2 && word[0] == '-' && word[1] != '-' && choice&8 != 0 {
+				// -rf runs as -f -r.
+				flags := []byte(word[1:])
+				slices.Reverse(flags)
+				parts := make([]string, len(flags))
+				for j, flag := range flags {
+					parts[j] = "-" + string(flag)
+				}
+				word = strings.Join(parts, " ")
+				break
+			}
+			switch choice % 5 {
+			case 1:
+				word = "'" + word + "'"
+			case 2:
+				word = `"` + word + `"`
+			case 3:
+				if len(word) > 1 {
+					word = word[:1] + `\` + word[1:]
+				}
+			case 4:
+				word = `''` + word
+			}
+		case sandbox.FamilyPowerShell:
+			if choice&1 != 0 {
+				word = strings.ToUpper(word)
+			}
+			// Quoting the command or a parameter name would turn it into a string.
+			if i > 0 && !strings.HasPrefix(word, "-") {
+				switch choice / 2 % 3 {
+				case 1:
+					word = "'" + word + "'"
+				case 2:
+					word = `"` + word + `"`
+				}
+			}
+		case sandbox.FamilyCmd:
+			if choice&1 != 0 {
+				word = strings.ToUpper(word)
+			}
+			if choice&2 != 0 && len(word) > 1 {
+				word = word[:1] + "^" + word[1:]
+			}
+		}
+		b.WriteString(word)
+	}
+	b.WriteString(surroundings[1][next()%len(surroundings[1])])
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+// CancelledMarker ends the result of a tool call the user cancelled.
+const CancelledMarker = "[cancelled by user]"
+
+// cancelGrace is how long a cancelled tool gets to stop and hand back its
+// partial output before the call is given up on.
+const cancelGrace = 2 * time.Second
+
+// ToolCanceller lets the user cancel the tool call in flight, e.g. with Esc,
+// without ending the turn: the call returns its partial output followed by
+// CancelledMarker and the model carries on from there.
+type ToolCanceller struct {
+	// watch listens for the user's cancel key while a tool runs, calling
+	// onCancel on each press, until stop is called. It may be nil.
+	watch func(onCancel func()) (stop func())
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	cancelled bool
+	stop      func()
+}
+
+// NewToolCanceller returns a ToolCanceller that watches for the cancel key
+// with watch, e.g. (*readline.Reader).WatchEsc.
+func NewToolCanceller(watch func(onCancel func()) (stop func())) *ToolCanceller {
+	return &ToolCanceller{watch: watch}
+}
+
+// Cancel cancels the tool call in flight and reports whether there was one.
+func (c *ToolCanceller) Cancel() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil {
+		return false
+	}
+	c.cancelled = true
+	c.cancel()
+	return true
+}
+
+// Middleware runs each tool call so that Cancel can end it. Only the
+// outermost call is tracked: a tool dispatching others (a subagent) is
+// cancelled as a whole. Register it last, so approvals asked by outer
+// middlewares are not mistaken for the tool running.
+func (c *ToolCanceller) Middleware() Middleware {
+	return func(_ string, next Handler) Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			callCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			if !c.begin(cancel) {
+				return next(ctx, args)
+			}
+
+			type result struct {
+				output string
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				output, err := next(callCtx, args)
+				done <- result{output, err}
+			}()
+			var r result
+			select {
+			case r = <-done:
+			case <-callCtx.Done():
+				select {
+				case r = <-done:
+				case <-time.After(cancelGrace):
+				}
+			}
+			if !c.end() || ctx.Err() != nil {
+				return r.output, r.err
+			}
+			output := strings.TrimSpace(r.output)
+			if output != "" {
+				output += "\n"
+			}
+			return output + CancelledMarker, nil
+		}
+	}
+}
+
+// begin tracks a call; it is false when one is already in flight.
+func (c *ToolCanceller) begin(cancel context.CancelFunc) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return false
+	}
+	c.cancel, c.cancelled = cancel, false
+	if c.watch != nil {
+		c.stop = c.watch(func() { c.Cancel() })
+	}
+	return true
+}
+
+// end stops tracking the call and reports whether the user cancelled it.
+func (c *ToolCanceller) end() bool {
+	c.mu.Lock()
+	stop := c.stop
+	cancelled := c.cancelled
+	c.cancel, c.cancelled, c.stop = nil, false, nil
+	c.mu.Unlock()
+	// Outside the lock: stop waits for the watcher, which may be calling Cancel.
+	if stop != nil {
+		stop()
+	}
+	return cancelled
+}
+
+// Approver stops watching for the cancel key while inner asks the user, so
+// the answer typed at the prompt reaches it. Tools that ask for approval
+// themselves (plugins) should be given this approver.
+func (c *ToolCanceller) Approver(inner permission.Approver) permission.Approver {
+	if inner == nil {
+		inner = permission.DenyAll
+	}
+	return cancellerApprover{c: c, inner: inner}
+}
+
+type cancellerApprover struct {
+	c     *ToolCanceller
+	inner permission.Approver
+}
+
+func (a cancellerApprover) Approve(ctx context.Context, req permission.Request) (bool, error) {
+	defer a.c.pause()()
+	return a.inner.Approve(ctx, req)
+}
+
+func (a cancellerApprover) Review(ctx context.Context, req permission.Request) (permission.Verdict, error) {
+	defer a.c.pause()()
+	return permission.Review(ctx, a.inner, req)
+}
+
+// pause stops watching and returns a function resuming it if the call is
+// still in flight.
+func (c *ToolCanceller) pause() (resume func()) {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+	if stop == nil {
+		return func() {}
+	}
+	stop()
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.cancel != nil && c.stop == nil {
+			c.stop = c.watch(func() { c.Cancel() })
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+// fakeKeys stands in for the terminal: press simulates the cancel key while
+// a watch is active.
+type fakeKeys struct {
+	mu       sync.Mutex
+	onCancel func()
+	watches  int
+}
+
+func (k *fakeKeys) watch(onCancel func()) func() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onCancel = onCancel
+	k.watches++
+	return func() {
+		k.mu.Lock()
+		k.onCancel = nil
+		k.mu.Unlock()
+	}
+}
+
+func (k *fakeKeys) press() bool {
+	k.mu.Lock()
+	onCancel := k.onCancel
+	k.mu.Unlock()
+	if onCancel == nil {
+		return false
+	}
+	onCancel()
+	return true
+}
+
+// UT-CANCEL: Esc 只取消当前工具调用，部分输出加上取消标记作为结果。
+func TestToolCanceller_CancelsRunningToolWithPartialOutput(t *testing.T) {
+	keys := &fakeKeys{}
+	canceller := NewToolCanceller(keys.watch)
+	started := make(chan struct{})
+	handler := canceller.Middleware()("bash", func(ctx context.Context, _ map[string]any) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "line 1\nline 2\n", ctx.Err()
+	})
+
+	go func() {
+		<-started
+		keys.press()
+	}()
+	out, err := handler(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "line 1\nline 2\n"+CancelledMarker {
+		t.Fatalf("output = %q", out)
+	}
+	if keys.press() {
+		t.Fatal("still watching after the call ended")
+	}
+	if canceller.Cancel() {
+		t.Fatal("Cancel reported a call in flight")
+	}
+
+	// The next call is unaffected.
+	out, err = canceller.Middleware()("bash", func(context.Context, map[string]any) (string, error) {
+		return "ok", nil
+	})(context.Background(), nil)
+	if out != "ok" || err != nil {
+		t.Fatalf("next call = %q, %v", out, err)
+	}
+}
+
+// UT-CANCEL-APPROVE: 工具自己请求审批时暂停监听按键，答复后恢复。
+func TestToolCanceller_ApproverPausesWatching(t *testing.T) {
+	keys := &fakeKeys{}
+	canceller := NewToolCanceller(keys.watch)
+	var watchingDuringPrompt bool
+	approver := canceller.Approver(permission.ApproverFunc(func(context.Context, permission.Request) (bool, error) {
+		keys.mu.Lock()
+		watchingDuringPrompt = keys.onCancel != nil
+		keys.mu.Unlock()
+		return true, nil
+	}))
+
+	var watchingAfterPrompt bool
+	handler := canceller.Middleware()("plugin", func(ctx context.Context, _ map[string]any) (string, error) {
+		if _, err := approver.Approve(ctx, permission.Request{Tool: "plugin"}); err != nil {
+			return "", err
+		}
+		keys.mu.Lock()
+		watchingAfterPrompt = keys.onCancel != nil
+		keys.mu.Unlock()
+		return "done", nil
+	})
+	if out, err := handler(context.Background(), nil); out != "done" || err != nil {
+		t.Fatalf("call = %q, %v", out, err)
+	}
+	if watchingDuringPrompt || !watchingAfterPrompt || keys.watches != 2 {
+		t.Fatalf("watching during prompt = %v, after = %v, watches = %d", watchingDuringPrompt, watchingAfterPrompt, keys.watches)
+	}
+}
+
+func TestToolCanceller_CancelsBashCommand(t *testing.T) {
+	keys := &fakeKeys{}
+	canceller := NewToolCanceller(keys.watch)
+	registry := New()
+	registry.Register(BashToolDef(), BashHandler)
+	registry = registry.WithMiddleware(canceller.Middleware())
+
+	go func() {
+		for !keys.press() {
+		}
+	}()
+	out, err := registry.Dispatch(context.Background(), "bash", map[string]any{"command": "sleep 30"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out, CancelledMarker) {
+		t.Fatalf("output = %q", out)
+	}
+}
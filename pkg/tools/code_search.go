@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/index"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const maxSnippetLines = 20
+
+// CodeSearcher returns the chunks most relevant to a natural-language query.
+type CodeSearcher interface {
+	Search(ctx context.Context, query string, k int) ([]index.Result, error)
+}
+
+// CodeSearchToolDef returns the definition for the code_search tool.
+func CodeSearchToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "code_search",
+			Description: openai.String(
+				"Semantic search over the project's source files. Returns the most relevant snippets with file:line references.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "Describe the code you are looking for, e.g. 'where tool calls are dispatched'.",
+					},
+					"top_k": map[string]any{
+						"type":        "integer",
+						"description": "Number of snippets to return (default 5).",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// NewCodeSearchHandler creates a tool handler backed by a semantic code index.
+func NewCodeSearchHandler(searcher CodeSearcher) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if searcher == nil {
+			return "", fmt.Errorf("code index is not configured")
+		}
+
+		query, ok := args["query"].(string)
+		if !ok || strings.TrimSpace(query) == "" {
+			return "", fmt.Errorf("missing or invalid 'query' argument")
+		}
+		topK := 0
+		if raw, exists := args["top_k"]; exists {
+			value, err := intArg(raw)
+			if err != nil {
+				return "", fmt.Errorf("invalid 'top_k': %w", err)
+			}
+			topK = value
+		}
+
+		results, err := searcher.Search(ctx, query, topK)
+		if err != nil {
+			return "", err
+		}
+		if len(results) == 0 {
+			return "No matching code.", nil
+		}
+
+		var b strings.Builder
+		for i, result := range results {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			fmt.Fprintf(&b, "%s (score %.2f)\n", result.Chunk.Location(), result.Score)
+			b.WriteString(numberedSnippet(result.Chunk))
+		}
+		return b.String(), nil
+	}
+}
+
+func numberedSnippet(chunk index.Chunk) string {
+	lines := strings.Split(chunk.Content, "\n")
+	truncated := len(lines) > maxSnippetLines
+	if truncated {
+		lines = lines[:maxSnippetLines]
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%5d | %s\n", chunk.StartLine+i, line)
+	}
+	if truncated {
+		fmt.Fprintf(&b, "      | ... (%d more lines)\n", chunk.EndLine-chunk.StartLine+1-maxSnippetLines)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
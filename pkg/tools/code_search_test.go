@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/index"
+)
+
+func TestCodeSearchHandler_FormatsLocationsAndLineNumbers(t *testing.T) {
+	searcher := fakeCodeSearcher{results: []index.Result{{
+		Chunk: index.Chunk{Path: "pkg/loop/agent.go", StartLine: 40, EndLine: 41, Content: "func Run() {\n}"},
+		Score: 0.87,
+	}}}
+
+	result, err := NewCodeSearchHandler(&searcher)(context.Background(), map[string]any{
+		"query": "agent loop",
+		"top_k": float64(3),
+	})
+	if err != nil {
+		t.Fatalf("code_search: %v", err)
+	}
+	if searcher.gotK != 3 {
+		t.Fatalf("top_k = %d, want 3", searcher.gotK)
+	}
+	for _, want := range []string{"pkg/loop/agent.go:40-41 (score 0.87)", "   40 | func Run() {", "   41 | }"} {
+		if !strings.Contains(result, want) {
+			t.Fatalf("result missing %q:\n%s", want, result)
+		}
+	}
+}
+
+func TestCodeSearchHandler_RequiresQuery(t *testing.T) {
+	_, err := NewCodeSearchHandler(&fakeCodeSearcher{})(context.Background(), map[string]any{})
+	if err == nil || !strings.Contains(err.Error(), "query") {
+		t.Fatalf("expected query error, got %v", err)
+	}
+}
+
+type fakeCodeSearcher struct {
+	results []index.Result
+	gotK    int
+}
+
+func (f *fakeCodeSearcher) Search(_ context.Context, _ string, k int) ([]index.Result, error) {
+	f.gotK = k
+	return f.results, nil
+}
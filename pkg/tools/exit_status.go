@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type exitStatusKey struct{}
+
+// exitCoder is an error carrying the exit status of a process or a
+// WebAssembly plugin.
+type exitCoder interface {
+	error
+	ExitCode() int
+}
+
+type exitStatusSink struct {
+	mu   sync.Mutex
+	code int
+	set  bool
+	// parent is the sink of an enclosing WithExitStatus, which sees the
+	// same status.
+	parent *exitStatusSink
+}
+
+// WithExitStatus returns a context in which command-running tools (bash)
+// report the exit status of the process they ran, and a function that reads
+// it back after dispatch. ok is false when no command was run.
+func WithExitStatus(ctx context.Context) (context.Context, func() (code int, ok bool)) {
+	parent, _ := ctx.Value(exitStatusKey{}).(*exitStatusSink)
+	sink := &exitStatusSink{parent: parent}
+	return context.WithValue(ctx, exitStatusKey{}, sink), func() (int, bool) {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return sink.code, sink.set
+	}
+}
+
+// reportExitStatus records the status derived from a command error: 0 on
+// success, the process exit code, or -1 when the command did not run.
+func reportExitStatus(ctx context.Context, err error) {
+	sink, ok := ctx.Value(exitStatusKey{}).(*exitStatusSink)
+	if !ok {
+		return
+	}
+	code := 0
+	if err != nil {
+		code = -1
+		var exitErr exitCoder
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+	}
+
+	for ; sink != nil; sink = sink.parent {
+		sink.mu.Lock()
+		sink.code, sink.set = code, true
+		sink.mu.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// FailureClass is a common reason a command fails.
+type FailureClass string
+
+const (
+	FailureMissingCommand   FailureClass = "missing command"
+	FailurePermissionDenied FailureClass = "permission denied"
+	FailureSyntaxError      FailureClass = "syntax error"
+	FailureMissingFile      FailureClass = "missing file"
+	FailureResourceLimit    FailureClass = "resource limit"
+)
+
+type failureRule struct {
+	class FailureClass
+	// code matches the exit status alone; 0 means the pattern must match.
+	code    int
+	pattern *regexp.Regexp
+	remedy  string
+}
+
+// failureRules are tried in order; the first match classifies the failure.
+var failureRules = []failureRule{
+	{FailureMissingCommand, 127, regexp.MustCompile(`(?i)command not found|not recognized as (?:an internal or external command|the name of a cmdlet)|executable file not found`),
+		"the program is not installed or not on PATH. Check with `command -v NAME`, use an alternative that is installed, or ask the user to install it; do not retry the same command."},
+	{FailurePermissionDenied, 126, regexp.MustCompile(`(?i)permission denied|operation not permitted|access is denied|EACCES`),
+		"the process lacks rights to that path or action. Check ownership and mode with `ls -l`, run `chmod +x` for scripts you created, write inside the project instead of system paths; do not use sudo without asking the user."},
+	{FailureSyntaxError, 0, regexp.MustCompile(`(?i)syntax error|unexpected (?:token|EOF|end of file)|unterminated quoted string|parse error|unmatched [\x60'"]`),
+		"the command line itself is malformed. Check quoting and escaping (prefer single quotes, or write a script file for complex commands), balance brackets and quotes, and confirm the shell in use supports the syntax."},
+	{FailureMissingFile, 0, regexp.MustCompile(`(?i)no such file or directory|cannot find the (?:path|file) specified|does not exist`),
+		"a path does not exist. Verify the current directory and the exact path with `pwd` and `ls` (or list_files) before retrying; paths are relative to the working directory."},
+	{FailureResourceLimit, 152, regexp.MustCompile(`(?i)CPU time limit exceeded|File size limit exceeded|cannot allocate memory|out of memory|fork: retry|resource temporarily unavailable`),
+		"the command ran into the CPU, memory, file size or process limits (the limits section of .agent/config.json). Make it do less: a narrower test run with -run, a smaller input, fewer parallel jobs; do not just retry."},
+}
+
+// ClassifyFailure returns the class of a command that exited with code and
+// printed output, or "" when it is not one of the known classes.
+func ClassifyFailure(code int, output string) FailureClass {
+	for _, r := range failureRules {
+		if r.pattern.MatchString(output) {
+			return r.class
+		}
+	}
+	for _, r := range failureRules {
+		if r.code != 0 && r.code == code {
+			return r.class
+		}
+	}
+	return ""
+}
+
+// FailureHint is the nudge appended to the output of a failed command of
+// class, "" for an unknown class.
+func FailureHint(class FailureClass) string {
+	for _, r := range failureRules {
+		if r.class == class {
+			return fmt.Sprintf("[hint: the command failed with a %s error: %s]", class, r.remedy)
+		}
+	}
+	return ""
+}
+
+// FailureHints appends a short hint to the output of commands (bash,
+// plugins) that exit non-zero for a recognizable reason, naming the class
+// of error and how to recover. Weaker models otherwise tend to retry the
+// same failing command. Outputs of unknown failures are left alone.
+func FailureHints() Middleware {
+	return func(name string, next Handler) Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, exitStatus := WithExitStatus(ctx)
+			output, err := next(ctx, args)
+			code, ok := exitStatus()
+			if err != nil || !ok || code == 0 {
+				return output, err
+			}
+			if hint := FailureHint(ClassifyFailure(code, output)); hint != "" {
+				output += "\n\n" + hint
+			}
+			return output, nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
+)
+
+func TestFailureHints_NudgesAfterRecognizableFailures(t *testing.T) {
+	registry := New()
+	registry.Register(BashToolDef(), NewBashHandler(sandbox.Local{}))
+	hinted := registry.WithMiddleware(FailureHints())
+	ctx := context.Background()
+
+	for command, want := range map[string]string{
+		"definitely-not-a-command-xyz": "missing command",
+		"cat /nonexistent/file":        "missing file",
+		"echo 'unbalanced":             "syntax error",
+	} {
+		out, err := hinted.Dispatch(ctx, "bash", map[string]any{"command": command})
+		if err != nil || !strings.Contains(out, "[hint: the command failed with a "+want+" error") {
+			t.Errorf("%s = %q, %v; want a %s hint", command, out, err, want)
+		}
+	}
+
+	for _, command := range []string{"echo ok", "exit 3"} {
+		if out, _ := hinted.Dispatch(ctx, "bash", map[string]any{"command": command}); strings.Contains(out, "[hint:") {
+			t.Errorf("%s = %q; want no hint", command, out)
+		}
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	for _, tc := range []struct {
+		code   int
+		output string
+		want   FailureClass
+	}{
+		{127, "", FailureMissingCommand},
+		{126, "", FailurePermissionDenied},
+		{1, "touch: cannot touch '/etc/x': Permission denied", FailurePermissionDenied},
+		{2, "bash: -c: line 1: syntax error near unexpected token `)'", FailureSyntaxError},
+		{152, "", FailureResourceLimit},
+		{1, "--- FAIL: TestX", ""},
+	} {
+		if got := ClassifyFailure(tc.code, tc.output); got != tc.want {
+			t.Errorf("ClassifyFailure(%d, %q) = %q, want %q", tc.code, tc.output, got, tc.want)
+		}
+	}
+}
@@ -1,27 +1,44 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/shared"
 )
 
+const (
+	defaultReadLines = 2000
+	maxReadBytes     = 256 << 10
+	binarySniffBytes = 8000
+)
+
 // ReadFileToolDef returns the definition for the read_file tool.
 func ReadFileToolDef() openai.ChatCompletionToolParam {
 	return openai.ChatCompletionToolParam{
 		Type: "function",
 		Function: shared.FunctionDefinitionParam{
-			Name:        "read_file",
-			Description: openai.String("Read the contents of a file."),
+			Name: "read_file",
+			Description: openai.String(fmt.Sprintf("Read the contents of a file. Large files are returned in chunks of at most %d lines "+
+				"or %d KiB; use offset and limit to read further. Binary files are reported, not shown.", defaultReadLines, maxReadBytes>>10)),
 			Parameters: openai.FunctionParameters{
 				"type": "object",
 				"properties": map[string]any{
-					"path": map[string]any{"type": "string", "description": "The absolute or relative path to the file."},
+					"path":   map[string]any{"type": "string", "description": "The absolute or relative path to the file."},
+					"offset": map[string]any{"type": "integer", "description": "1-based line number to start reading from (default 1)."},
+					"limit":  map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum number of lines to read (default %d).", defaultReadLines)},
 				},
 				"required": []string{"path"},
 			},
@@ -29,23 +46,125 @@ func ReadFileToolDef() openai.ChatCompletionToolParam {
 	}
 }
 
-// ReadFileHandler executes the read_file tool.
-func ReadFileHandler(_ context.Context, args map[string]any) (string, error) {
+// ReadFileHandler executes the read_file tool. A file that fits in one chunk
+// is returned verbatim; otherwise the chunk ends with a notice saying which
+// lines were shown and where to continue.
+func ReadFileHandler(ctx context.Context, args map[string]any) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing or invalid 'path' argument")
 	}
+	offset, err := boundedIntArg(args, "offset", 1, math.MaxInt)
+	if err != nil {
+		return "", err
+	}
+	limit, err := boundedIntArg(args, "limit", defaultReadLines, math.MaxInt)
+	if err != nil {
+		return "", err
+	}
 
-	safe, err := safePath(path)
+	safe, err := safePath(ctx, path)
 	if err != nil {
 		return "", err
 	}
 
-	content, err := os.ReadFile(safe)
+	f, err := os.Open(safe)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
-	return string(content), nil
+	defer f.Close()
+
+	br := bufio.NewReaderSize(f, binarySniffBytes)
+	if head, _ := br.Peek(binarySniffBytes); isBinary(head) {
+		info, err := f.Stat()
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		return fmt.Sprintf("%s is a binary or non-UTF-8 file (%d bytes); its contents are not shown.", path, info.Size()), nil
+	}
+
+	chunk, err := readLines(br, offset, limit)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if chunk.total > 0 && offset > chunk.total {
+		return "", fmt.Errorf("offset %d is past the end of %s (%d lines)", offset, path, chunk.total)
+	}
+	if offset == 1 && chunk.last == chunk.total && !chunk.cutLine {
+		return chunk.text, nil
+	}
+
+	var b strings.Builder
+	b.WriteString(chunk.text)
+	if !strings.HasSuffix(chunk.text, "\n") {
+		b.WriteByte('\n')
+	}
+	if chunk.cutLine {
+		fmt.Fprintf(&b, "[line %d is longer than %d KiB and was cut]\n", chunk.last, maxReadBytes>>10)
+	}
+	fmt.Fprintf(&b, "[showing lines %d-%d of %d", offset, chunk.last, chunk.total)
+	if chunk.last < chunk.total {
+		fmt.Fprintf(&b, "; call read_file with offset=%d to continue", chunk.last+1)
+	}
+	b.WriteString("]")
+	return b.String(), nil
+}
+
+type lineChunk struct {
+	text    string
+	last    int // last line included
+	total   int // lines in the file
+	cutLine bool
+}
+
+// readLines collects lines [offset, offset+limit) up to maxReadBytes and counts
+// the rest of the file without keeping it. A single line larger than the byte
+// budget is cut rather than skipped.
+func readLines(br *bufio.Reader, offset, limit int) (lineChunk, error) {
+	var (
+		chunk lineChunk
+		b     strings.Builder
+		full  bool
+	)
+	chunk.last = offset - 1
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			chunk.total++
+			if !full && chunk.total >= offset && chunk.total < offset+limit {
+				switch {
+				case b.Len()+len(line) <= maxReadBytes:
+					b.WriteString(line)
+					chunk.last = chunk.total
+				case b.Len() == 0:
+					b.WriteString(strings.ToValidUTF8(line[:maxReadBytes], ""))
+					chunk.last, chunk.cutLine, full = chunk.total, true, true
+				default:
+					full = true
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return lineChunk{}, err
+		}
+	}
+	chunk.text = b.String()
+	return chunk, nil
+}
+
+// isBinary reports whether the start of a file looks like binary data: it has
+// a NUL byte or is not valid UTF-8 (ignoring a rune cut at the end).
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return !utf8.Valid(head)
 }
 
 // WriteFileToolDef returns the definition for the write_file tool.
@@ -68,7 +187,7 @@ func WriteFileToolDef() openai.ChatCompletionToolParam {
 }
 
 // WriteFileHandler executes the write_file tool.
-func WriteFileHandler(_ context.Context, args map[string]any) (string, error) {
+func WriteFileHandler(ctx context.Context, args map[string]any) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing or invalid 'path' argument")
@@ -78,7 +197,7 @@ func WriteFileHandler(_ context.Context, args map[string]any) (string, error) {
 		return "", fmt.Errorf("missing or invalid 'content' argument")
 	}
 
-	safe, err := safePath(path)
+	safe, err := safePath(ctx, path)
 	if err != nil {
 		return "", err
 	}
@@ -132,7 +251,7 @@ func EditFileToolDef() openai.ChatCompletionToolParam {
 }
 
 // EditFileHandler executes the edit_file tool.
-func EditFileHandler(_ context.Context, args map[string]any) (string, error) {
+func EditFileHandler(ctx context.Context, args map[string]any) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing or invalid 'path' argument")
@@ -146,7 +265,7 @@ func EditFileHandler(_ context.Context, args map[string]any) (string, error) {
 		return "", fmt.Errorf("missing or invalid 'new_text' argument")
 	}
 
-	safe, err := safePath(path)
+	safe, err := safePath(ctx, path)
 	if err != nil {
 		return "", err
 	}
@@ -169,13 +288,13 @@ func EditFileHandler(_ context.Context, args map[string]any) (string, error) {
 }
 
 // ListDirHandler executes the list_dir tool.
-func ListDirHandler(_ context.Context, args map[string]any) (string, error) {
+func ListDirHandler(ctx context.Context, args map[string]any) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing or invalid 'path' argument")
 	}
 
-	safe, err := safePath(path)
+	safe, err := safePath(ctx, path)
 	if err != nil {
 		return "", err
 	}
@@ -204,31 +323,153 @@ func ListDirHandler(_ context.Context, args map[string]any) (string, error) {
 	return result, nil
 }
 
-func safePath(path string) (string, error) {
+// ResolveWorkspacePath resolves path the way the file tools do (relative to
+// the working directory of ctx or the workspace root) and rejects paths that
+// escape the workspace.
+func ResolveWorkspacePath(ctx context.Context, path string) (string, error) {
+	return safePath(ctx, path)
+}
+
+var (
+	additionalDirsMu sync.RWMutex
+	additionalDirs   []string
+)
+
+// SetAdditionalDirectories lets the file tools reach dirs besides the
+// workspace, e.g. a sibling repository. Relative dirs are taken from the
+// working directory; each must exist. It replaces any earlier list.
+func SetAdditionalDirectories(dirs []string) error {
+	resolved := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("additional directory %q: %w", dir, err)
+		}
+		target, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return fmt.Errorf("additional directory %q: %w", dir, err)
+		}
+		resolved = append(resolved, target)
+	}
+	additionalDirsMu.Lock()
+	additionalDirs = resolved
+	additionalDirsMu.Unlock()
+	return nil
+}
+
+// safePath resolves path against the logical working directory (see
+// WithWorkDir), or else the workspace root, and checks, after
+// following symlinks, that it stays inside the workspace or one of the
+// additional directories, so neither ../ nor a link can lead elsewhere.
+func safePath(ctx context.Context, path string) (string, error) {
 	workspace, err := workspaceRoot()
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve workspace: %w", err)
 	}
 
 	workspace = filepath.Clean(workspace)
+	base := workspace
+	if w := workDirFrom(ctx); w != nil {
+		base = w.Dir()
+	}
 	resolved := path
 	if filepath.IsAbs(path) {
 		resolved = filepath.Clean(path)
 	} else {
-		resolved = filepath.Clean(filepath.Join(workspace, path))
+		resolved = filepath.Clean(filepath.Join(base, path))
 	}
 
-	rel, err := filepath.Rel(workspace, resolved)
+	target, err := realPath(resolved)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve path %q: %w", path, err)
 	}
-	if rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
-		return "", fmt.Errorf("path escapes workspace: %s", path)
+	roots := []string{workspace}
+	if realWorkspace, err := filepath.EvalSymlinks(workspace); err == nil {
+		roots[0] = realWorkspace
 	}
+	additionalDirsMu.RLock()
+	roots = append(roots, additionalDirs...)
+	additionalDirsMu.RUnlock()
+	for _, root := range roots {
+		if within(root, target) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("path escapes workspace: %s", path)
+}
 
+// maxSymlinks bounds the links realPath follows, so a loop of links fails
+// instead of spinning.
+const maxSymlinks = 255
+
+// realPath follows the symlinks in path. Files that do not exist yet, e.g.
+// the target of write_file, resolve through their closest existing parent.
+// Every component is looked at with Lstat and a link is replaced by its
+// target even when that does not exist, so a dangling link cannot point a
+// write outside the workspace; a link that cannot be read fails.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	volume := filepath.VolumeName(path)
+	resolved := volume + string(filepath.Separator)
+	pending := splitPath(path[len(volume):])
+	links := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if name == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(next)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Nothing below a missing component exists either.
+			return filepath.Join(append([]string{next}, pending...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks in %s", path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			volume = filepath.VolumeName(target)
+			resolved = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
 	return resolved, nil
 }
 
+// splitPath returns the components of path, without empty ones and ".".
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(path, string(filepath.Separator)) {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
 func workspaceRoot() (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
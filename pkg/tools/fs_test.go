@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -49,6 +51,64 @@ func TestReadFileHandler_ReadsInsideWorkspace(t *testing.T) {
 	})
 }
 
+func TestReadFileHandler_ReportsBinaryFiles(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if err := os.WriteFile("app.bin", []byte{0x7f, 'E', 'L', 'F', 0, 0, 1}, 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+
+		result, err := ReadFileHandler(context.Background(), map[string]any{"path": "app.bin"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(result, "binary") || strings.Contains(result, "ELF") {
+			t.Fatalf("expected a binary notice, got %q", result)
+		}
+	})
+}
+
+func TestReadFileHandler_ReadsChunksWithOffsetAndLimit(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if err := os.WriteFile("app.log", []byte("one\ntwo\nthree\nfour\n"), 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+
+		result, err := ReadFileHandler(context.Background(), map[string]any{
+			"path": "app.log", "offset": float64(2), "limit": float64(2),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "two\nthree\n[showing lines 2-3 of 4; call read_file with offset=4 to continue]"
+		if result != want {
+			t.Fatalf("expected %q, got %q", want, result)
+		}
+
+		if _, err := ReadFileHandler(context.Background(), map[string]any{"path": "app.log", "offset": float64(9)}); err == nil {
+			t.Fatal("expected an error for an offset past the end")
+		}
+	})
+}
+
+func TestReadFileHandler_ChunksLargeFilesByDefault(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		content := strings.Repeat("log line\n", defaultReadLines+10)
+		if err := os.WriteFile("big.log", []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+
+		result, err := ReadFileHandler(context.Background(), map[string]any{"path": "big.log"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		notice := fmt.Sprintf("[showing lines 1-%d of %d; call read_file with offset=%d to continue]",
+			defaultReadLines, defaultReadLines+10, defaultReadLines+1)
+		if !strings.HasSuffix(result, notice) {
+			t.Fatalf("expected chunk notice %q, got suffix %q", notice, result[max(0, len(result)-120):])
+		}
+	})
+}
+
 func TestEditFileHandler_ReplacesFirstMatch(t *testing.T) {
 	withWorkingDir(t, t.TempDir(), func() {
 		target := "edit.txt"
@@ -157,6 +217,82 @@ func TestFSHandlers_RejectPathEscape(t *testing.T) {
 	})
 }
 
+func TestSafePath_FollowsSymlinks(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	withWorkingDir(t, workspace, func() {
+		for _, path := range []string{"link/secret.txt", "link/new.txt", filepath.Join(outside, "secret.txt")} {
+			if _, err := safePath(context.Background(), path); err == nil || !strings.Contains(err.Error(), "path escapes workspace") {
+				t.Errorf("safePath(%q) error = %v, want escape", path, err)
+			}
+		}
+
+		if err := SetAdditionalDirectories([]string{outside}); err != nil {
+			t.Fatalf("SetAdditionalDirectories: %v", err)
+		}
+		t.Cleanup(func() { _ = SetAdditionalDirectories(nil) })
+		out, err := ReadFileHandler(context.Background(), map[string]any{"path": "link/secret.txt"})
+		if err != nil || !strings.Contains(out, "secret") {
+			t.Fatalf("read through allowed directory = %q, %v", out, err)
+		}
+		if _, err := safePath(context.Background(), filepath.Join(outside, "sub", "new.txt")); err != nil {
+			t.Fatalf("new file in allowed directory: %v", err)
+		}
+	})
+
+	if err := SetAdditionalDirectories([]string{filepath.Join(outside, "missing")}); err == nil {
+		t.Fatal("expected error for a missing directory")
+	}
+}
+
+func TestSafePath_RejectsDanglingSymlinkOutside(t *testing.T) {
+	workspace := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	for name, target := range map[string]string{
+		"evil":   filepath.Join(outside, "pwned.txt"),
+		"nested": filepath.Join("..", filepath.Base(filepath.Dir(outside)), "outside", "pwned.txt"),
+		"loop":   "loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(workspace, name)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	if err := os.Symlink("missing.txt", filepath.Join(workspace, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	withWorkingDir(t, workspace, func() {
+		for _, path := range []string{"evil", "nested", "evil/x"} {
+			if _, err := safePath(context.Background(), path); err == nil || !strings.Contains(err.Error(), "path escapes workspace") {
+				t.Errorf("safePath(%q) error = %v, want escape", path, err)
+			}
+		}
+		if _, err := safePath(context.Background(), "loop"); err == nil {
+			t.Error("safePath accepted a symlink loop")
+		}
+		if _, err := WriteFileHandler(context.Background(), map[string]any{"path": "evil", "content": "pwned"}); err == nil {
+			t.Error("write_file followed a dangling symlink outside the workspace")
+		}
+		if _, err := os.Stat(outside); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("outside directory was created: %v", err)
+		}
+
+		if _, err := WriteFileHandler(context.Background(), map[string]any{"path": "inside", "content": "ok"}); err != nil {
+			t.Fatalf("write through a dangling symlink inside the workspace: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(workspace, "missing.txt")); err != nil || string(data) != "ok" {
+			t.Fatalf("missing.txt = %q, %v", data, err)
+		}
+	})
+}
+
 func withWorkingDir(t *testing.T, dir string, fn func()) {
 	t.Helper()
 
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// maxGitDiffTokens caps the diff fed back to the model.
+const maxGitDiffTokens = 12000
+
+// GitDiffToolDef returns the definition for the git_diff tool.
+func GitDiffToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "git_diff",
+			Description: openai.String("Show the uncommitted changes of the repository, or the changes since a revision, as a unified diff."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "Optional file or directory to limit the diff to.",
+					},
+					"staged": map[string]any{
+						"type":        "boolean",
+						"description": "Show the staged changes instead of the unstaged ones.",
+					},
+					"revision": map[string]any{
+						"type":        "string",
+						"description": "Optional revision to compare the work tree with, e.g. HEAD~3 or main.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// GitDiffHandler runs git diff in the workspace root.
+func GitDiffHandler(ctx context.Context, args map[string]any) (string, error) {
+	root, err := workspaceRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	gitArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged, _ := args["staged"].(bool); staged {
+		gitArgs = append(gitArgs, "--cached")
+	}
+	if revision, _ := args["revision"].(string); strings.TrimSpace(revision) != "" {
+		if strings.HasPrefix(revision, "-") {
+			return "", fmt.Errorf("invalid revision %q", revision)
+		}
+		gitArgs = append(gitArgs, revision)
+	}
+	gitArgs = append(gitArgs, "--")
+	if path, _ := args["path"].(string); path != "" {
+		safe, err := safePath(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		gitArgs = append(gitArgs, safe)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git diff: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git diff: %w", err)
+	}
+	if len(out) == 0 {
+		return "(no changes)", nil
+	}
+	return tokens.Truncate(tokens.Default(), string(out), maxGitDiffTokens), nil
+}
@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	maxIssueComments = 20
+	defaultPRLimit   = 20
+)
+
+var repoParam = map[string]any{"type": "string", "description": "Repository as owner/name (default: the configured or origin repository)."}
+
+// GetIssueToolDef returns the definition for the get_issue tool.
+func GetIssueToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "get_issue",
+			Description: openai.String("Fetch an issue of the project's GitHub, GitLab or Gitea repository with its description, labels and recent comments."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"number": map[string]any{"type": "integer", "description": "Issue number."},
+					"repo":   repoParam,
+				},
+				"required": []string{"number"},
+			},
+		},
+	}
+}
+
+// ListPRsToolDef returns the definition for the list_prs tool.
+func ListPRsToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "list_prs",
+			Description: openai.String("List pull requests (merge requests on GitLab), newest first."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"state": map[string]any{"type": "string", "enum": []string{"open", "closed", "all"}, "description": "Default open."},
+					"head":  map[string]any{"type": "string", "description": "Only PRs from this branch (owner:branch for GitHub forks)."},
+					"base":  map[string]any{"type": "string", "description": "Only PRs into this branch."},
+					"limit": map[string]any{"type": "integer", "description": "Maximum PRs to return (default 20, max 100)."},
+					"repo":  repoParam,
+				},
+			},
+		},
+	}
+}
+
+// CreatePRToolDef returns the definition for the create_pr tool.
+func CreatePRToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "create_pr",
+			Description: openai.String(
+				"Open a pull request (a merge request on GitLab). Commit and push the branch with bash (git push -u origin <branch>) first. Requires user approval.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"title": map[string]any{"type": "string"},
+					"body":  map[string]any{"type": "string", "description": "Markdown description; reference the issue, e.g. \"Fixes #12\"."},
+					"head":  map[string]any{"type": "string", "description": "Branch with the changes (default: the current branch)."},
+					"base":  map[string]any{"type": "string", "description": "Branch to merge into (default: the repository's default branch)."},
+					"draft": map[string]any{"type": "boolean"},
+					"repo":  repoParam,
+				},
+				"required": []string{"title"},
+			},
+		},
+	}
+}
+
+// NewGetIssueHandler creates a get_issue handler backed by client.
+func NewGetIssueHandler(client forge.Forge) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		number, err := intArg(args["number"])
+		if err != nil || number <= 0 {
+			return "", fmt.Errorf("missing or invalid 'number' argument")
+		}
+		repo, _ := args["repo"].(string)
+		issue, comments, err := client.GetIssue(ctx, repo, number, maxIssueComments)
+		if err != nil {
+			return "", err
+		}
+
+		var b strings.Builder
+		kind := "Issue"
+		if issue.IsPullRequest {
+			kind = "Pull request"
+		}
+		fmt.Fprintf(&b, "%s #%d: %s\nState: %s\nAuthor: %s\nURL: %s\n", kind, issue.Number, issue.Title, issue.State, issue.Author, issue.URL)
+		if len(issue.Labels) > 0 {
+			fmt.Fprintf(&b, "Labels: %s\n", strings.Join(issue.Labels, ", "))
+		}
+		body := strings.TrimSpace(issue.Body)
+		if body == "" {
+			body = "(no description)"
+		}
+		fmt.Fprintf(&b, "\n%s\n", body)
+		for _, c := range comments {
+			fmt.Fprintf(&b, "\n--- %s commented on %s ---\n%s\n", c.Author, c.CreatedAt.Format("2006-01-02"), strings.TrimSpace(c.Body))
+		}
+		if issue.Comments > len(comments) {
+			fmt.Fprintf(&b, "\n(%d more comments not shown)\n", issue.Comments-len(comments))
+		}
+		return b.String(), nil
+	}
+}
+
+// NewListPRsHandler creates a list_prs handler backed by client.
+func NewListPRsHandler(client forge.Forge) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		opts := forge.ListOptions{Limit: defaultPRLimit}
+		opts.State, _ = args["state"].(string)
+		opts.Head, _ = args["head"].(string)
+		opts.Base, _ = args["base"].(string)
+		if raw, exists := args["limit"]; exists {
+			limit, err := intArg(raw)
+			if err != nil || limit <= 0 {
+				return "", fmt.Errorf("invalid 'limit': expected positive integer")
+			}
+			opts.Limit = limit
+		}
+		repo, _ := args["repo"].(string)
+		prs, err := client.ListPRs(ctx, repo, opts)
+		if err != nil {
+			return "", err
+		}
+		if len(prs) == 0 {
+			return "No pull requests found.", nil
+		}
+
+		var b strings.Builder
+		for _, pr := range prs {
+			state := pr.State
+			if pr.Draft {
+				state += ", draft"
+			}
+			fmt.Fprintf(&b, "#%d %s [%s] %s → %s by %s\n  %s\n", pr.Number, pr.Title, state, pr.Head, pr.Base, pr.Author, pr.URL)
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+}
+
+// NewCreatePRHandler creates a create_pr handler backed by client. Every
+// pull request needs approver's consent; a nil approver denies them all.
+func NewCreatePRHandler(client forge.Forge, approver permission.Approver) Handler {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		var pr forge.NewPullRequest
+		pr.Title, _ = args["title"].(string)
+		if strings.TrimSpace(pr.Title) == "" {
+			return "", fmt.Errorf("missing or invalid 'title' argument")
+		}
+		pr.Body, _ = args["body"].(string)
+		pr.Head, _ = args["head"].(string)
+		pr.Base, _ = args["base"].(string)
+		pr.Draft, _ = args["draft"].(bool)
+		repo, _ := args["repo"].(string)
+		if strings.TrimSpace(pr.Head) == "" {
+			branch, err := currentBranch(ctx)
+			if err != nil {
+				return "", fmt.Errorf("no 'head' given and the current branch is unknown: %w", err)
+			}
+			pr.Head = branch
+		}
+		if repo == "" {
+			repo = client.DefaultRepo()
+		}
+
+		base := pr.Base
+		if base == "" {
+			base = "default branch"
+		}
+		approved, err := approver.Approve(ctx, permission.Request{
+			Tool:    "create_pr",
+			Summary: fmt.Sprintf("open a pull request on %s: %s → %s", repo, pr.Head, base),
+			Detail:  pr.Title + "\n\n" + pr.Body,
+		})
+		if err != nil {
+			return "", fmt.Errorf("approval failed: %w", err)
+		}
+		if !approved {
+			return "Pull request not created: the user did not approve it.", nil
+		}
+
+		created, err := client.CreatePR(ctx, repo, pr)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Opened pull request #%d: %s", created.Number, created.URL), nil
+	}
+}
+
+func currentBranch(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "" || branch == "HEAD" {
+		return "", fmt.Errorf("HEAD is detached")
+	}
+	return branch, nil
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/forge"
+	"github.com/nickdu2009/learn-claude-code/pkg/github"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+func TestGetIssueHandler_FormatsIssue(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/issues/7", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"number": 7, "title": "Crash on empty input", "body": "Run it with no args.", "state": "open",
+			"user": map[string]any{"login": "alice"}, "labels": []map[string]any{{"name": "bug"}},
+		})
+	})
+	handler := NewGetIssueHandler(newGitHubTestClient(t, mux))
+
+	result, err := handler(context.Background(), map[string]any{"number": float64(7)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Issue #7: Crash on empty input", "Author: alice", "Labels: bug", "Run it with no args."} {
+		if !strings.Contains(result, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestListPRsHandler_PassesFilters(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("state") != "all" || q.Get("per_page") != "5" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]any{{
+			"number": 3, "title": "Add docs", "state": "open", "draft": true,
+			"head": map[string]any{"ref": "docs"}, "base": map[string]any{"ref": "main"},
+		}})
+	})
+	handler := NewListPRsHandler(newGitHubTestClient(t, mux))
+
+	result, err := handler(context.Background(), map[string]any{"state": "all", "limit": float64(5)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "#3 Add docs [open, draft] docs → main") {
+		t.Fatalf("unexpected output:\n%s", result)
+	}
+}
+
+func TestCreatePRHandler_RequiresApproval(t *testing.T) {
+	created := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		created++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"number": 12, "html_url": "https://github.com/acme/widgets/pull/12"})
+	})
+	client := newGitHubTestClient(t, mux)
+	args := map[string]any{"title": "Fix crash", "body": "Fixes #7", "head": "fix-7", "base": "main"}
+
+	var requests []permission.Request
+	deny := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+		requests = append(requests, req)
+		return false, nil
+	})
+	result, err := NewCreatePRHandler(client, deny)(context.Background(), args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "not created") || created != 0 {
+		t.Fatalf("expected denial, got %q (created %d)", result, created)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0].Summary, "acme/widgets: fix-7 → main") || !strings.Contains(requests[0].Detail, "Fixes #7") {
+		t.Fatalf("unexpected approval requests: %+v", requests)
+	}
+
+	result, err = NewCreatePRHandler(client, permission.AllowAll)(context.Background(), args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Opened pull request #12: https://github.com/acme/widgets/pull/12" || created != 1 {
+		t.Fatalf("unexpected result %q (created %d)", result, created)
+	}
+}
+
+func newGitHubTestClient(t *testing.T, handler http.Handler) forge.Forge {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return forge.GitHub(github.NewClient(srv.URL, "tok", "acme/widgets"))
+}
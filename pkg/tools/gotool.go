@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/gotool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// GoTestToolDef returns the definition for the go_test tool.
+func GoTestToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "go_test",
+			Description: openai.String("Run go test and report failing tests and build errors with file:line locations."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"packages": map[string]any{
+						"type":        "array",
+						"description": "Package patterns to test (default ./...).",
+						"items":       map[string]any{"type": "string"},
+					},
+					"run": map[string]any{
+						"type":        "string",
+						"description": "Optional -run regular expression to select tests.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// GoVetToolDef returns the definition for the go_vet tool.
+func GoVetToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "go_vet",
+			Description: openai.String("Run go vet and report each warning as file:line:col."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"packages": map[string]any{
+						"type":        "array",
+						"description": "Package patterns to vet (default ./...).",
+						"items":       map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// GofmtToolDef returns the definition for the gofmt tool.
+func GofmtToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        "gofmt",
+			Description: openai.String("List Go files that are not gofmt-formatted, optionally rewriting them in place."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"paths": map[string]any{
+						"type":        "array",
+						"description": "Files or directories to check (default the workspace root).",
+						"items":       map[string]any{"type": "string"},
+					},
+					"write": map[string]any{
+						"type":        "boolean",
+						"description": "Rewrite files in place instead of only listing them.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// GoTestHandler executes the go_test tool.
+func GoTestHandler(ctx context.Context, args map[string]any) (string, error) {
+	packages, err := stringSliceArg(args["packages"])
+	if err != nil {
+		return "", fmt.Errorf("invalid 'packages': %w", err)
+	}
+	run, _ := args["run"].(string)
+
+	root, err := workspaceRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+
+	report, err := gotool.TestWithProgress(ctx, root, packages, run, func(passed, failed, skipped int) {
+		ReportProgress(ctx, Progress{
+			Message: fmt.Sprintf("%d passed, %d failed, %d skipped", passed, failed, skipped),
+			Current: int64(passed + failed + skipped),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return formatTestReport(report), nil
+}
+
+// GoVetHandler executes the go_vet tool.
+func GoVetHandler(ctx context.Context, args map[string]any) (string, error) {
+	packages, err := stringSliceArg(args["packages"])
+	if err != nil {
+		return "", fmt.Errorf("invalid 'packages': %w", err)
+	}
+
+	root, err := workspaceRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+
+	diagnostics, err := gotool.Vet(ctx, root, packages)
+	if err != nil {
+		return "", err
+	}
+	if len(diagnostics) == 0 {
+		return "go vet: no issues", nil
+	}
+	return fmt.Sprintf("go vet: %d issue(s)\n%s", len(diagnostics), formatDiagnostics(diagnostics)), nil
+}
+
+// GofmtHandler executes the gofmt tool.
+func GofmtHandler(ctx context.Context, args map[string]any) (string, error) {
+	paths, err := stringSliceArg(args["paths"])
+	if err != nil {
+		return "", fmt.Errorf("invalid 'paths': %w", err)
+	}
+	write, _ := args["write"].(bool)
+
+	for i, path := range paths {
+		safe, err := safePath(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		paths[i] = safe
+	}
+
+	root, err := workspaceRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+
+	files, diagnostics, err := gotool.Fmt(ctx, root, paths, write)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	switch {
+	case len(files) == 0:
+		b.WriteString("gofmt: all files formatted")
+	case write:
+		fmt.Fprintf(&b, "gofmt: reformatted %d file(s)\n%s", len(files), strings.Join(files, "\n"))
+	default:
+		fmt.Fprintf(&b, "gofmt: %d file(s) need formatting\n%s", len(files), strings.Join(files, "\n"))
+	}
+	if len(diagnostics) > 0 {
+		fmt.Fprintf(&b, "\nsyntax errors:\n%s", formatDiagnostics(diagnostics))
+	}
+	return b.String(), nil
+}
+
+func formatTestReport(report gotool.TestReport) string {
+	var b strings.Builder
+	status := "PASS"
+	if !report.OK() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "go test: %s (passed %d, failed %d, skipped %d)", status, report.Passed, report.Failed, report.Skipped)
+
+	if len(report.BuildErrors) > 0 {
+		fmt.Fprintf(&b, "\n\nbuild errors:\n%s", formatDiagnostics(report.BuildErrors))
+	}
+	for _, failure := range report.Failures {
+		name := failure.Package
+		if failure.Test != "" {
+			name += " " + failure.Test
+		}
+		fmt.Fprintf(&b, "\n\n--- FAIL: %s\n%s", name, strings.TrimRight(failure.Output, "\n"))
+	}
+	if len(report.Other) > 0 {
+		fmt.Fprintf(&b, "\n\nother output:\n%s", strings.Join(report.Other, "\n"))
+	}
+	return b.String()
+}
+
+func formatDiagnostics(diagnostics []gotool.Diagnostic) string {
+	lines := make([]string, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
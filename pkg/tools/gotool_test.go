@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/gotool"
+)
+
+func TestFormatTestReport_ListsFailuresAndBuildErrors(t *testing.T) {
+	report := gotool.TestReport{
+		Passed: 3,
+		Failed: 1,
+		Failures: []gotool.TestFailure{{
+			Package: "example.com/a",
+			Test:    "TestBad",
+			Output:  "    a_test.go:12: got 1, want 2\n",
+		}},
+		BuildErrors: []gotool.Diagnostic{{File: "b/b.go", Line: 3, Column: 2, Message: "undefined: x"}},
+	}
+
+	result := formatTestReport(report)
+	for _, want := range []string{
+		"go test: FAIL (passed 3, failed 1, skipped 0)",
+		"b/b.go:3:2: undefined: x",
+		"--- FAIL: example.com/a TestBad\n    a_test.go:12: got 1, want 2",
+	} {
+		if !strings.Contains(result, want) {
+			t.Fatalf("report missing %q:\n%s", want, result)
+		}
+	}
+}
+
+func TestFormatTestReport_Pass(t *testing.T) {
+	result := formatTestReport(gotool.TestReport{Passed: 2})
+	if result != "go test: PASS (passed 2, failed 0, skipped 0)" {
+		t.Fatalf("unexpected report: %q", result)
+	}
+}
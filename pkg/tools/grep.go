@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	// maxGrepOutputTokens caps search results fed back to the model.
+	maxGrepOutputTokens = 8000
+	maxGrepContext      = 10
+	maxGrepLineChars    = 300
+	maxGrepFileBytes    = 5 << 20
+)
+
+// Grep output modes.
+const (
+	grepModeMatches = "matches"
+	grepModeFiles   = "files"
+	grepModeCount   = "count"
+)
+
+// GrepToolDef returns the definition for the grep tool.
+func GrepToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "grep",
+			Description: openai.String("Search file contents with a regular expression, respecting .gitignore. " +
+				"Output modes: matches (path:line:text, with optional context lines), files (matching paths only) " +
+				"or count (matching lines per file). Prefer this over running grep or rg through bash."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern":     map[string]any{"type": "string", "description": "Regular expression (RE2/Rust regex syntax)."},
+					"path":        map[string]any{"type": "string", "description": "Directory to search, relative to the workspace root. Defaults to the root."},
+					"glob":        map[string]any{"type": "string", "description": "Only search files matching this glob, e.g. \"*.go\" or \"pkg/**/*_test.go\"."},
+					"context":     map[string]any{"type": "integer", "description": fmt.Sprintf("Lines of context around each match in matches mode (default 0, max %d).", maxGrepContext)},
+					"ignore_case": map[string]any{"type": "boolean", "description": "Match case-insensitively."},
+					"output_mode": map[string]any{
+						"type":        "string",
+						"enum":        []string{grepModeMatches, grepModeFiles, grepModeCount},
+						"description": "What to return (default matches).",
+					},
+				},
+				"required": []string{"pattern"},
+			},
+		},
+	}
+}
+
+type grepQuery struct {
+	pattern    string
+	glob       string
+	context    int
+	ignoreCase bool
+	mode       string
+}
+
+// GrepHandler executes the grep tool with ripgrep when it is installed and a
+// built-in search otherwise. Both produce the same output format.
+func GrepHandler(ctx context.Context, args map[string]any) (string, error) {
+	var q grepQuery
+	var ok bool
+	if q.pattern, ok = args["pattern"].(string); !ok || q.pattern == "" {
+		return "", fmt.Errorf("missing or invalid 'pattern' argument")
+	}
+	q.glob, _ = args["glob"].(string)
+	q.ignoreCase, _ = args["ignore_case"].(bool)
+	q.mode = grepModeMatches
+	if mode, ok := args["output_mode"].(string); ok && mode != "" {
+		q.mode = mode
+	}
+	if q.mode != grepModeMatches && q.mode != grepModeFiles && q.mode != grepModeCount {
+		return "", fmt.Errorf("invalid 'output_mode' %q: want matches, files or count", q.mode)
+	}
+	if raw, exists := args["context"]; exists {
+		value, err := intArg(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid 'context': %w", err)
+		}
+		q.context = max(0, min(value, maxGrepContext))
+	}
+	dir := "."
+	if p, ok := args["path"].(string); ok && strings.TrimSpace(p) != "" {
+		dir = p
+	}
+	safe, err := safePath(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	if rg, lookErr := exec.LookPath("rg"); lookErr == nil {
+		result, err = grepRipgrep(ctx, rg, safe, q)
+	} else {
+		result, err = grepBuiltin(ctx, safe, q)
+	}
+	if err != nil {
+		return "", err
+	}
+	if result == "" {
+		return "No matches.", nil
+	}
+	return tokens.Truncate(tokens.Default(), result, maxGrepOutputTokens), nil
+}
+
+func grepRipgrep(ctx context.Context, rg, root string, q grepQuery) (string, error) {
+	args := []string{"--no-heading", "--line-number", "--color", "never", "--sort", "path",
+		"--max-columns", strconv.Itoa(maxGrepLineChars), "--max-columns-preview"}
+	if q.ignoreCase {
+		args = append(args, "--ignore-case")
+	}
+	if q.glob != "" {
+		args = append(args, "--glob", q.glob)
+	}
+	switch q.mode {
+	case grepModeFiles:
+		args = append(args, "--files-with-matches")
+	case grepModeCount:
+		args = append(args, "--count")
+	default:
+		if q.context > 0 {
+			args = append(args, "--context", strconv.Itoa(q.context))
+		}
+	}
+	args = append(args, "--regexp", q.pattern, "--", ".")
+
+	cmd := exec.CommandContext(ctx, rg, args...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 means no matches.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("rg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// Searching "." prefixes every path with "./".
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "./")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func grepBuiltin(ctx context.Context, root string, q grepQuery) (string, error) {
+	pattern := q.pattern
+	if q.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid 'pattern': %w", err)
+	}
+	files, err := fileindex.List(ctx, root)
+	if err != nil {
+		return "", fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var out []string
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if !fileindex.MatchGlob(q.glob, rel) {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if info, err := os.Stat(path); err != nil || info.Size() > maxGrepFileBytes {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data[:min(len(data), binarySniffBytes)]) {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		var hits []int
+		for i, line := range lines {
+			if re.MatchString(line) {
+				hits = append(hits, i)
+			}
+		}
+		if len(hits) == 0 {
+			continue
+		}
+
+		switch q.mode {
+		case grepModeFiles:
+			out = append(out, rel)
+		case grepModeCount:
+			out = append(out, fmt.Sprintf("%s:%d", rel, len(hits)))
+		default:
+			out = appendGrepMatches(out, rel, lines, hits, q.context)
+		}
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// appendGrepMatches formats matches like rg: "path:n:text" for matching lines,
+// "path-n-text" for context lines and "--" between separate groups.
+func appendGrepMatches(out []string, rel string, lines []string, hits []int, context int) []string {
+	isHit := make(map[int]bool, len(hits))
+	for _, h := range hits {
+		isHit[h] = true
+	}
+	last := -1
+	for _, h := range hits {
+		start := max(h-context, last+1)
+		end := min(h+context, len(lines)-1)
+		if context > 0 && start > last+1 && len(out) > 0 {
+			out = append(out, "--")
+		}
+		for i := start; i <= end; i++ {
+			sep := "-"
+			if isHit[i] {
+				sep = ":"
+			}
+			out = append(out, fmt.Sprintf("%s%s%d%s%s", rel, sep, i+1, sep, clipGrepLine(lines[i])))
+		}
+		last = max(last, end)
+	}
+	return out
+}
+
+func clipGrepLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	if runes := []rune(line); len(runes) > maxGrepLineChars {
+		return string(runes[:maxGrepLineChars]) + " [...]"
+	}
+	return line
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeGrepFixtures(t *testing.T) {
+	t.Helper()
+	if err := os.MkdirAll("pkg", 0755); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := map[string]string{
+		"pkg/a.go":  "package pkg\n\nfunc Run() {}\n\nfunc helper() {}\nfunc run2() {}\n",
+		"pkg/b.go":  "package pkg\n\n// Run is documented elsewhere.\n",
+		"README.md": "Run the agent.\n",
+		"image.png": "\x89PNG\x00\x00Run",
+	}
+	for path, content := range fixtures {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGrepBuiltin_OutputModes(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		writeGrepFixtures(t)
+		ctx := context.Background()
+		root, _ := os.Getwd()
+
+		tests := []struct {
+			name string
+			q    grepQuery
+			want string
+		}{
+			{
+				name: "matches with glob",
+				q:    grepQuery{pattern: `func \w+\(`, glob: "*.go", mode: grepModeMatches},
+				want: "pkg/a.go:3:func Run() {}\npkg/a.go:5:func helper() {}\npkg/a.go:6:func run2() {}",
+			},
+			{
+				name: "files ignoring case",
+				q:    grepQuery{pattern: `^RUN|HELPER`, ignoreCase: true, mode: grepModeFiles},
+				want: "README.md\npkg/a.go",
+			},
+			{
+				name: "count",
+				q:    grepQuery{pattern: `Run`, mode: grepModeCount},
+				want: "README.md:1\npkg/a.go:1\npkg/b.go:1",
+			},
+			{
+				name: "context merges adjacent groups",
+				q:    grepQuery{pattern: `^func (Run|run2)`, glob: "pkg/*.go", context: 1, mode: grepModeMatches},
+				want: "pkg/a.go-2-\npkg/a.go:3:func Run() {}\npkg/a.go-4-\npkg/a.go-5-func helper() {}\npkg/a.go:6:func run2() {}",
+			},
+			{
+				name: "context separates distant groups",
+				q:    grepQuery{pattern: `^package|run2`, glob: "pkg/a.go", context: 1, mode: grepModeMatches},
+				want: "pkg/a.go:1:package pkg\npkg/a.go-2-\n--\npkg/a.go-5-func helper() {}\npkg/a.go:6:func run2() {}",
+			},
+		}
+		for _, tt := range tests {
+			got, err := grepBuiltin(ctx, root, tt.q)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Fatalf("%s:\ngot\n%s\nwant\n%s", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestGrepHandler_ValidatesArguments(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		writeGrepFixtures(t)
+		ctx := context.Background()
+
+		if _, err := GrepHandler(ctx, map[string]any{"pattern": "Run", "output_mode": "lines"}); err == nil {
+			t.Fatal("expected an error for an unknown output_mode")
+		}
+		result, err := GrepHandler(ctx, map[string]any{"pattern": "nothing-matches-this"})
+		if err != nil || result != "No matches." {
+			t.Fatalf("result = %q, %v; want No matches.", result, err)
+		}
+		result, err = GrepHandler(ctx, map[string]any{"pattern": "Run", "output_mode": "files"})
+		if err != nil || !strings.Contains(result, "pkg/b.go") || strings.Contains(result, "image.png") {
+			t.Fatalf("result = %q, %v; want text files only", result, err)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultHTTPMaxResponseBytes = 100 * 1024
+	defaultHTTPTimeout          = 30 * time.Second
+	maxHTTPTimeout              = 5 * time.Minute
+	defaultHTTPMaxRedirects     = 10
+	redactedValue               = "[REDACTED]"
+)
+
+var sensitiveHeaderFragments = []string{
+	"authorization", "cookie", "token", "secret", "api-key", "apikey", "password", "session",
+}
+
+// HTTPRequestConfig bounds what a single http_request call may do.
+type HTTPRequestConfig struct {
+	MaxResponseBytes int64
+	DefaultTimeout   time.Duration
+	// AllowedHosts limits the hosts requests and redirects may reach, see
+	// HostAllowed; empty allows every host.
+	AllowedHosts []string
+	// Transport overrides the base transport (tests); TLS options are applied on a clone.
+	Transport *http.Transport
+}
+
+// HTTPRequestToolDef returns the definition for the http_request tool.
+func HTTPRequestToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "http_request",
+			Description: openai.String(
+				"Send an HTTP request and return the status, headers, and (size-limited) body. Sensitive headers are redacted in the output.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"url":    map[string]any{"type": "string", "description": "Absolute http(s) URL."},
+					"method": map[string]any{"type": "string", "description": "HTTP method (default GET)."},
+					"headers": map[string]any{
+						"type":                 "object",
+						"description":          "Request headers as name → value.",
+						"additionalProperties": map[string]any{"type": "string"},
+					},
+					"body":                 map[string]any{"type": "string", "description": "Raw request body."},
+					"follow_redirects":     map[string]any{"type": "boolean", "description": "Follow 3xx redirects (default true)."},
+					"insecure_skip_verify": map[string]any{"type": "boolean", "description": "Skip TLS certificate verification (local dev servers only)."},
+					"timeout_seconds":      map[string]any{"type": "integer", "description": "Request timeout in seconds (default 30)."},
+				},
+				"required": []string{"url"},
+			},
+		},
+	}
+}
+
+// NewHTTPRequestHandler creates a tool handler that performs HTTP requests.
+func NewHTTPRequestHandler(cfg HTTPRequestConfig) Handler {
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = defaultHTTPMaxResponseBytes
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = defaultHTTPTimeout
+	}
+
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		rawURL, ok := args["url"].(string)
+		if !ok || strings.TrimSpace(rawURL) == "" {
+			return "", fmt.Errorf("missing or invalid 'url' argument")
+		}
+		target, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return "", fmt.Errorf("invalid 'url': must be an absolute http(s) URL")
+		}
+		if !HostAllowed(cfg.AllowedHosts, target) {
+			return "", fmt.Errorf("host %s is not in the allowed hosts (%s)", target.Host, strings.Join(cfg.AllowedHosts, ", "))
+		}
+
+		method := http.MethodGet
+		if raw, ok := args["method"].(string); ok && strings.TrimSpace(raw) != "" {
+			method = strings.ToUpper(strings.TrimSpace(raw))
+		}
+		headers, err := stringMapArg(args["headers"])
+		if err != nil {
+			return "", fmt.Errorf("invalid 'headers': %w", err)
+		}
+		body, _ := args["body"].(string)
+
+		followRedirects := true
+		if raw, ok := args["follow_redirects"].(bool); ok {
+			followRedirects = raw
+		}
+		insecure, _ := args["insecure_skip_verify"].(bool)
+
+		timeout := cfg.DefaultTimeout
+		if raw, exists := args["timeout_seconds"]; exists {
+			seconds, err := intArg(raw)
+			if err != nil || seconds <= 0 {
+				return "", fmt.Errorf("invalid 'timeout_seconds': expected positive integer")
+			}
+			timeout = min(time.Duration(seconds)*time.Second, maxHTTPTimeout)
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, method, target.String(), strings.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("build request: %w", err)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		client := newHTTPToolClient(cfg.Transport, cfg.AllowedHosts, followRedirects, insecure)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		received := &progressReader{ctx: ctx, r: resp.Body, total: max(resp.ContentLength, 0)}
+		data, err := io.ReadAll(io.LimitReader(received, cfg.MaxResponseBytes+1))
+		if err != nil {
+			return "", fmt.Errorf("read response body: %w", err)
+		}
+		truncated := int64(len(data)) > cfg.MaxResponseBytes
+		if truncated {
+			data = data[:cfg.MaxResponseBytes]
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s %s\n%s %s\n", method, target.String(), resp.Proto, resp.Status)
+		writeRedactedHeaders(&b, resp.Header)
+		b.WriteString("\n")
+		b.Write(data)
+		if truncated {
+			fmt.Fprintf(&b, "\n... (response truncated at %d bytes)", cfg.MaxResponseBytes)
+		}
+		return b.String(), nil
+	}
+}
+
+// HostAllowed reports whether u may be requested: allowed is empty, or an
+// entry equals its host name, its host:port, or is "*.domain" for a
+// subdomain of domain. Names compare case-insensitively.
+func HostAllowed(allowed []string, u *url.URL) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if entry == host || strings.HasPrefix(entry, "*.") && strings.HasSuffix(host, entry[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func newHTTPToolClient(base *http.Transport, allowed []string, followRedirects, insecure bool) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	if insecure {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !followRedirects {
+				return http.ErrUseLastResponse
+			}
+			if !HostAllowed(allowed, req.URL) {
+				return fmt.Errorf("redirect to %s: host is not in the allowed hosts", req.URL.Host)
+			}
+			if len(via) >= defaultHTTPMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", defaultHTTPMaxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+func writeRedactedHeaders(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if isSensitiveHeader(name) {
+			value = redactedValue
+		}
+		fmt.Fprintf(b, "%s: %s\n", name, value)
+	}
+}
+
+func isSensitiveHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, fragment := range sensitiveHeaderFragments {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringMapArg(v any) (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	values, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected object")
+	}
+	out := make(map[string]string, len(values))
+	for key, raw := range values {
+		text, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("value for %q must be a string", key)
+		}
+		out[key] = text
+	}
+	return out, nil
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRequestHandler_SendsRequestAndRedactsHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("X-Trace") != "abc" || string(body) != `{"a":1}` {
+			t.Errorf("unexpected request: %s %v %s", r.Method, r.Header, body)
+		}
+		w.Header().Set("Set-Cookie", "session=supersecret")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	result, err := NewHTTPRequestHandler(HTTPRequestConfig{})(context.Background(), map[string]any{
+		"url":     server.URL + "/items",
+		"method":  "post",
+		"headers": map[string]any{"X-Trace": "abc", "Authorization": "Bearer secret-token"},
+		"body":    `{"a":1}`,
+	})
+	if err != nil {
+		t.Fatalf("http_request: %v", err)
+	}
+	for _, want := range []string{"201 Created", "Set-Cookie: [REDACTED]", "X-Request-Id: req-1", `{"ok":true}`} {
+		if !strings.Contains(result, want) {
+			t.Fatalf("result missing %q:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "supersecret") || strings.Contains(result, "secret-token") {
+		t.Fatalf("secret leaked into output:\n%s", result)
+	}
+}
+
+func TestHTTPRequestHandler_TruncatesLargeBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 100))
+	}))
+	defer server.Close()
+
+	result, err := NewHTTPRequestHandler(HTTPRequestConfig{MaxResponseBytes: 10})(context.Background(), map[string]any{
+		"url": server.URL,
+	})
+	if err != nil {
+		t.Fatalf("http_request: %v", err)
+	}
+	if !strings.Contains(result, "\n"+strings.Repeat("x", 10)+"\n... (response truncated at 10 bytes)") {
+		t.Fatalf("expected truncated body:\n%s", result)
+	}
+}
+
+func TestHTTPRequestHandler_CanDisableRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		_, _ = io.WriteString(w, "landed")
+	}))
+	defer server.Close()
+
+	handler := NewHTTPRequestHandler(HTTPRequestConfig{})
+	followed, err := handler(context.Background(), map[string]any{"url": server.URL + "/old"})
+	if err != nil {
+		t.Fatalf("http_request: %v", err)
+	}
+	if !strings.Contains(followed, "landed") {
+		t.Fatalf("expected redirect to be followed:\n%s", followed)
+	}
+
+	stopped, err := handler(context.Background(), map[string]any{"url": server.URL + "/old", "follow_redirects": false})
+	if err != nil {
+		t.Fatalf("http_request: %v", err)
+	}
+	if !strings.Contains(stopped, "302 Found") || !strings.Contains(stopped, "Location: /new") {
+		t.Fatalf("expected raw redirect response:\n%s", stopped)
+	}
+}
+
+func TestHTTPRequestHandler_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "tls ok")
+	}))
+	defer server.Close()
+
+	handler := NewHTTPRequestHandler(HTTPRequestConfig{})
+	if _, err := handler(context.Background(), map[string]any{"url": server.URL}); err == nil {
+		t.Fatal("expected certificate error without insecure_skip_verify")
+	}
+	result, err := handler(context.Background(), map[string]any{"url": server.URL, "insecure_skip_verify": true})
+	if err != nil {
+		t.Fatalf("http_request: %v", err)
+	}
+	if !strings.Contains(result, "tls ok") {
+		t.Fatalf("unexpected result:\n%s", result)
+	}
+}
+
+func TestHTTPRequestHandler_RejectsNonHTTPURL(t *testing.T) {
+	_, err := NewHTTPRequestHandler(HTTPRequestConfig{})(context.Background(), map[string]any{"url": "file:///etc/passwd"})
+	if err == nil || !strings.Contains(err.Error(), "url") {
+		t.Fatalf("expected url error, got %v", err)
+	}
+}
+
+func TestHTTPRequestHandler_OnlyReachesAllowedHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://evil.example/", http.StatusFound)
+			return
+		}
+		_, _ = io.WriteString(w, "allowed")
+	}))
+	defer server.Close()
+	addr, _ := url.Parse(server.URL)
+
+	handler := NewHTTPRequestHandler(HTTPRequestConfig{AllowedHosts: []string{addr.Host}})
+	if result, err := handler(context.Background(), map[string]any{"url": server.URL}); err != nil || !strings.Contains(result, "allowed") {
+		t.Fatalf("allowed host: %q, %v", result, err)
+	}
+	if _, err := handler(context.Background(), map[string]any{"url": "http://evil.example/"}); err == nil || !strings.Contains(err.Error(), "not in the allowed hosts") {
+		t.Fatalf("expected the host to be refused, got %v", err)
+	}
+	if _, err := handler(context.Background(), map[string]any{"url": server.URL + "/away"}); err == nil || !strings.Contains(err.Error(), "not in the allowed hosts") {
+		t.Fatalf("expected the redirect to be refused, got %v", err)
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	allowed := []string{"localhost:8080", "API.example.com", "*.internal.test", "::1"}
+	for raw, want := range map[string]bool{
+		"http://localhost:8080/x":       true,
+		"http://localhost:9090/x":       false,
+		"https://api.example.com/v1":    true,
+		"https://api.example.com:444/":  true,
+		"https://example.com/":          false,
+		"http://svc.internal.test/":     true,
+		"http://internal.test/":         false,
+		"http://[::1]:3000/":            true,
+		"http://api.example.com.evil/":  false,
+		"http://evil-api.example.com/":  false,
+		"http://localhost/no-port-8080": false,
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := HostAllowed(allowed, u); got != want {
+			t.Errorf("HostAllowed(%s) = %v, want %v", raw, got, want)
+		}
+	}
+	if !HostAllowed(nil, &url.URL{Scheme: "http", Host: "anything"}) {
+		t.Fatal("an empty list allows every host")
+	}
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultListDepth   = 3
+	maxListDepth       = 10
+	defaultListEntries = 300
+	maxListEntries     = 2000
+)
+
+// collapsedDirs hold vendored code or build output. list_files shows them as a
+// single line with a file count instead of expanding them.
+var collapsedDirs = map[string]bool{
+	"vendor":       true,
+	"node_modules": true,
+	"third_party":  true,
+	"bin":          true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"__pycache__":  true,
+}
+
+// ListFilesToolDef returns the definition for the list_files tool.
+func ListFilesToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "list_files",
+			Description: openai.String("Show a depth-limited tree of the project files under a directory, respecting .gitignore. " +
+				"Vendored and build-output directories are collapsed to a file count. " +
+				"Prefer this over running find or ls -R to get oriented."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"path":        map[string]any{"type": "string", "description": "Directory to list, relative to the workspace root. Defaults to the root."},
+					"depth":       map[string]any{"type": "integer", "description": fmt.Sprintf("How many levels of the tree to show (default %d, max %d). Directories on the last level show only a file count.", defaultListDepth, maxListDepth)},
+					"max_entries": map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum number of lines to return (default %d, max %d).", defaultListEntries, maxListEntries)},
+				},
+			},
+		},
+	}
+}
+
+// ListFilesHandler executes the list_files tool.
+func ListFilesHandler(ctx context.Context, args map[string]any) (string, error) {
+	dir := "."
+	if p, ok := args["path"].(string); ok && strings.TrimSpace(p) != "" {
+		dir = p
+	}
+	depth, err := boundedIntArg(args, "depth", defaultListDepth, maxListDepth)
+	if err != nil {
+		return "", err
+	}
+	maxEntries, err := boundedIntArg(args, "max_entries", defaultListEntries, maxListEntries)
+	if err != nil {
+		return "", err
+	}
+
+	safe, err := safePath(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(safe)
+	if err != nil {
+		return "", fmt.Errorf("failed to list files: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	files, err := fileindex.List(ctx, safe)
+	if err != nil {
+		return "", fmt.Errorf("failed to list files: %w", err)
+	}
+	if len(files) == 0 {
+		return "(no files)", nil
+	}
+
+	root := buildFileTree(files)
+	var lines []string
+	root.render(&lines, "", 0, depth)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d files)\n", dir, len(files))
+	shown := lines
+	if len(lines) > maxEntries {
+		shown = lines[:maxEntries]
+	}
+	for _, line := range shown {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if hidden := len(lines) - len(shown); hidden > 0 {
+		fmt.Fprintf(&b, "... (%d more entries; list a subdirectory or lower depth)\n", hidden)
+	}
+	return b.String(), nil
+}
+
+// boundedIntArg reads an optional integer argument, using def when it is
+// missing or not positive and capping it at limit.
+func boundedIntArg(args map[string]any, name string, def, limit int) (int, error) {
+	raw, exists := args[name]
+	if !exists {
+		return def, nil
+	}
+	value, err := intArg(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid '%s': %w", name, err)
+	}
+	if value < 1 {
+		return def, nil
+	}
+	return min(value, limit), nil
+}
+
+type fileTree struct {
+	dirs  map[string]*fileTree
+	files []string
+	count int
+}
+
+func buildFileTree(files []string) *fileTree {
+	root := &fileTree{}
+	for _, file := range files {
+		node := root
+		node.count++
+		dir, name := path.Split(file)
+		for _, part := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+			if part == "" {
+				continue
+			}
+			if node.dirs == nil {
+				node.dirs = make(map[string]*fileTree)
+			}
+			child, ok := node.dirs[part]
+			if !ok {
+				child = &fileTree{}
+				node.dirs[part] = child
+			}
+			child.count++
+			node = child
+		}
+		node.files = append(node.files, name)
+	}
+	return root
+}
+
+// render appends one line per entry, directories first, indenting two spaces
+// per level. Directories at the depth limit or in collapsedDirs show only
+// their file count.
+func (t *fileTree) render(lines *[]string, indent string, level, depth int) {
+	names := make([]string, 0, len(t.dirs))
+	for name := range t.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := t.dirs[name]
+		if level+1 >= depth || collapsedDirs[name] {
+			*lines = append(*lines, fmt.Sprintf("%s%s/ (%d files)", indent, name, child.count))
+			continue
+		}
+		*lines = append(*lines, indent+name+"/")
+		child.render(lines, indent+"  ", level+1, depth)
+	}
+	sort.Strings(t.files)
+	for _, name := range t.files {
+		*lines = append(*lines, indent+name)
+	}
+}
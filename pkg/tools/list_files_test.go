@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListFilesHandler_RendersDepthLimitedTree(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		for _, rel := range []string{
+			"main.go",
+			"pkg/loop/agent.go",
+			"pkg/loop/deep/x.go",
+			"pkg/tools/fs.go",
+			"dist/app.js",
+			"dist/assets/app.css",
+		} {
+			if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(rel, []byte("package x\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := ListFilesHandler(context.Background(), map[string]any{"depth": float64(2)})
+		if err != nil {
+			t.Fatalf("ListFilesHandler() error = %v", err)
+		}
+		want := strings.Join([]string{
+			". (6 files)",
+			"dist/ (2 files)",
+			"pkg/",
+			"  loop/ (2 files)",
+			"  tools/ (1 files)",
+			"main.go",
+			"",
+		}, "\n")
+		if got != want {
+			t.Fatalf("ListFilesHandler() =\n%s\nwant\n%s", got, want)
+		}
+	})
+}
+
+func TestListFilesHandler_TruncatesEntries(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if err := os.WriteFile(name, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := ListFilesHandler(context.Background(), map[string]any{"max_entries": float64(2)})
+		if err != nil {
+			t.Fatalf("ListFilesHandler() error = %v", err)
+		}
+		if !strings.Contains(got, "b.txt\n... (1 more entries") || strings.Contains(got, "c.txt") {
+			t.Fatalf("ListFilesHandler() = %q, want truncation after two entries", got)
+		}
+	})
+}
+
+func TestListFilesHandler_RejectsPathEscape(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if _, err := ListFilesHandler(context.Background(), map[string]any{"path": "../"}); err == nil {
+			t.Fatal("ListFilesHandler() error = nil, want path escape error")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/lsp"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const maxReferenceResults = 100
+
+// CodeIntelligence answers position-based queries, typically via a language server.
+type CodeIntelligence interface {
+	Root() string
+	Definition(ctx context.Context, path string, line, column int) ([]lsp.Location, error)
+	References(ctx context.Context, path string, line, column int) ([]lsp.Location, error)
+	Hover(ctx context.Context, path string, line, column int) (string, error)
+}
+
+var _ CodeIntelligence = (*lsp.Client)(nil)
+
+// GoToDefinitionToolDef returns the definition for the go_to_definition tool.
+func GoToDefinitionToolDef() openai.ChatCompletionToolParam {
+	return positionToolDef("go_to_definition", "Find where the symbol at a file position is defined.")
+}
+
+// FindReferencesToolDef returns the definition for the find_references tool.
+func FindReferencesToolDef() openai.ChatCompletionToolParam {
+	return positionToolDef("find_references", "List every reference to the symbol at a file position, including its declaration.")
+}
+
+// HoverToolDef returns the definition for the hover tool.
+func HoverToolDef() openai.ChatCompletionToolParam {
+	return positionToolDef("hover", "Show the type signature and documentation of the symbol at a file position.")
+}
+
+func positionToolDef(name, description string) openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        name,
+			Description: openai.String(description),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"path":   map[string]any{"type": "string", "description": "File path relative to the workspace root."},
+					"line":   map[string]any{"type": "integer", "description": "1-based line number."},
+					"column": map[string]any{"type": "integer", "description": "1-based column (character) of the symbol."},
+				},
+				"required": []string{"path", "line", "column"},
+			},
+		},
+	}
+}
+
+// NewGoToDefinitionHandler creates a tool handler that resolves symbol definitions.
+func NewGoToDefinitionHandler(intel CodeIntelligence) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if intel == nil {
+			return "", fmt.Errorf("language server is not configured")
+		}
+		path, line, column, err := positionArgs(ctx, args)
+		if err != nil {
+			return "", err
+		}
+
+		locations, err := intel.Definition(ctx, path, line, column)
+		if err != nil {
+			return "", err
+		}
+		if len(locations) == 0 {
+			return "No definition found.", nil
+		}
+		return formatLocations(intel.Root(), locations, len(locations)), nil
+	}
+}
+
+// NewFindReferencesHandler creates a tool handler that lists symbol references.
+func NewFindReferencesHandler(intel CodeIntelligence) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if intel == nil {
+			return "", fmt.Errorf("language server is not configured")
+		}
+		path, line, column, err := positionArgs(ctx, args)
+		if err != nil {
+			return "", err
+		}
+
+		locations, err := intel.References(ctx, path, line, column)
+		if err != nil {
+			return "", err
+		}
+		if len(locations) == 0 {
+			return "No references found.", nil
+		}
+		return formatLocations(intel.Root(), locations, maxReferenceResults), nil
+	}
+}
+
+// NewHoverHandler creates a tool handler that returns hover documentation.
+func NewHoverHandler(intel CodeIntelligence) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if intel == nil {
+			return "", fmt.Errorf("language server is not configured")
+		}
+		path, line, column, err := positionArgs(ctx, args)
+		if err != nil {
+			return "", err
+		}
+
+		text, err := intel.Hover(ctx, path, line, column)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(text) == "" {
+			return "No hover information.", nil
+		}
+		return text, nil
+	}
+}
+
+func positionArgs(ctx context.Context, args map[string]any) (string, int, int, error) {
+	path, ok := args["path"].(string)
+	if !ok || strings.TrimSpace(path) == "" {
+		return "", 0, 0, fmt.Errorf("missing or invalid 'path' argument")
+	}
+	line, err := intArg(args["line"])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid 'line': %w", err)
+	}
+	column, err := intArg(args["column"])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid 'column': %w", err)
+	}
+
+	safe, err := safePath(ctx, path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return safe, line, column, nil
+}
+
+// formatLocations renders path:line:col followed by the source line so the
+// model does not need a follow-up read_file for simple lookups.
+func formatLocations(root string, locations []lsp.Location, limit int) string {
+	cache := make(map[string][]string)
+
+	var b strings.Builder
+	for i, loc := range locations {
+		if i >= limit {
+			fmt.Fprintf(&b, "... (%d more)\n", len(locations)-limit)
+			break
+		}
+
+		path, err := lsp.URIToPath(loc.URI)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:%d:%d\n", loc.URI, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+			continue
+		}
+		display := path
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			display = filepath.ToSlash(rel)
+		}
+
+		lines, ok := cache[path]
+		if !ok {
+			if data, err := os.ReadFile(path); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+			cache[path] = lines
+		}
+
+		fmt.Fprintf(&b, "%s:%d:%d", display, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+		if loc.Range.Start.Line < len(lines) {
+			fmt.Fprintf(&b, ": %s", strings.TrimSpace(lines[loc.Range.Start.Line]))
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
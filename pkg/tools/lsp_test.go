@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/lsp"
+)
+
+func TestGoToDefinitionHandler_FormatsRelativeLocationWithSource(t *testing.T) {
+	dir := t.TempDir()
+	withWorkingDir(t, dir, func() {
+		if err := os.WriteFile("main.go", []byte("package main\n\nfunc helper() {}\n"), 0o644); err != nil {
+			t.Fatalf("write fixture: %v", err)
+		}
+		root, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("Getwd: %v", err)
+		}
+		intel := &fakeCodeIntelligence{
+			root: root,
+			locations: []lsp.Location{{
+				URI:   lsp.PathToURI(filepath.Join(root, "main.go")),
+				Range: lsp.Range{Start: lsp.Position{Line: 2, Character: 5}},
+			}},
+		}
+
+		result, err := NewGoToDefinitionHandler(intel)(context.Background(), map[string]any{
+			"path":   "main.go",
+			"line":   float64(10),
+			"column": float64(3),
+		})
+		if err != nil {
+			t.Fatalf("go_to_definition: %v", err)
+		}
+		if result != "main.go:3:6: func helper() {}" {
+			t.Fatalf("unexpected result: %q", result)
+		}
+		if intel.gotLine != 10 || intel.gotColumn != 3 || !strings.HasSuffix(intel.gotPath, "main.go") {
+			t.Fatalf("unexpected position passed through: %s:%d:%d", intel.gotPath, intel.gotLine, intel.gotColumn)
+		}
+	})
+}
+
+func TestFindReferencesHandler_NoResults(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		result, err := NewFindReferencesHandler(&fakeCodeIntelligence{})(context.Background(), map[string]any{
+			"path":   "main.go",
+			"line":   float64(1),
+			"column": float64(1),
+		})
+		if err != nil {
+			t.Fatalf("find_references: %v", err)
+		}
+		if result != "No references found." {
+			t.Fatalf("unexpected result: %q", result)
+		}
+	})
+}
+
+func TestHoverHandler_RequiresPosition(t *testing.T) {
+	_, err := NewHoverHandler(&fakeCodeIntelligence{})(context.Background(), map[string]any{"path": "main.go"})
+	if err == nil || !strings.Contains(err.Error(), "line") {
+		t.Fatalf("expected line error, got %v", err)
+	}
+}
+
+type fakeCodeIntelligence struct {
+	root      string
+	locations []lsp.Location
+	hover     string
+
+	gotPath   string
+	gotLine   int
+	gotColumn int
+}
+
+func (f *fakeCodeIntelligence) Root() string { return f.root }
+
+func (f *fakeCodeIntelligence) Definition(_ context.Context, path string, line, column int) ([]lsp.Location, error) {
+	f.gotPath, f.gotLine, f.gotColumn = path, line, column
+	return f.locations, nil
+}
+
+func (f *fakeCodeIntelligence) References(_ context.Context, path string, line, column int) ([]lsp.Location, error) {
+	f.gotPath, f.gotLine, f.gotColumn = path, line, column
+	return f.locations, nil
+}
+
+func (f *fakeCodeIntelligence) Hover(_ context.Context, path string, line, column int) (string, error) {
+	f.gotPath, f.gotLine, f.gotColumn = path, line, column
+	return f.hover, nil
+}
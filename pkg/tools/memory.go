@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/memory"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// MemoryWriteToolDef returns the definition for the memory_write tool.
+func MemoryWriteToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "memory_write",
+			Description: openai.String(
+				"Save a durable fact about this project so it can be recalled in future sessions.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"content": map[string]any{
+						"type":        "string",
+						"description": "The fact to remember, written so it makes sense without the current conversation.",
+					},
+					"tags": map[string]any{
+						"type":        "array",
+						"description": "Optional short keywords used to improve retrieval.",
+						"items":       map[string]any{"type": "string"},
+					},
+				},
+				"required": []string{"content"},
+			},
+		},
+	}
+}
+
+// MemorySearchToolDef returns the definition for the memory_search tool.
+func MemorySearchToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "memory_search",
+			Description: openai.String(
+				"Search facts remembered in earlier sessions. Returns the most relevant entries first.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "What you want to recall.",
+					},
+					"limit": map[string]any{
+						"type":        "integer",
+						"description": "Maximum number of results (default 5).",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// NewMemoryWriteHandler creates a tool handler that persists a memory entry.
+func NewMemoryWriteHandler(svc *memory.Service) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if svc == nil {
+			return "", fmt.Errorf("memory service is not configured")
+		}
+
+		content, ok := args["content"].(string)
+		if !ok || strings.TrimSpace(content) == "" {
+			return "", fmt.Errorf("missing or invalid 'content' argument")
+		}
+		tags, err := stringSliceArg(args["tags"])
+		if err != nil {
+			return "", fmt.Errorf("invalid 'tags': %w", err)
+		}
+
+		entry, err := svc.Remember(ctx, content, tags)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Remembered %s", entry.ID), nil
+	}
+}
+
+// NewMemorySearchHandler creates a tool handler that retrieves relevant memories.
+func NewMemorySearchHandler(svc *memory.Service) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if svc == nil {
+			return "", fmt.Errorf("memory service is not configured")
+		}
+
+		query, ok := args["query"].(string)
+		if !ok || strings.TrimSpace(query) == "" {
+			return "", fmt.Errorf("missing or invalid 'query' argument")
+		}
+		limit := 0
+		if raw, exists := args["limit"]; exists {
+			value, err := intArg(raw)
+			if err != nil {
+				return "", fmt.Errorf("invalid 'limit': %w", err)
+			}
+			limit = value
+		}
+
+		results, err := svc.Search(ctx, query, limit)
+		if err != nil {
+			return "", err
+		}
+		if len(results) == 0 {
+			return "No relevant memories.", nil
+		}
+
+		var b strings.Builder
+		for _, result := range results {
+			fmt.Fprintf(&b, "- (%.2f) %s", result.Score, result.Entry.Content)
+			if len(result.Entry.Tags) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(result.Entry.Tags, ", "))
+			}
+			b.WriteByte('\n')
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+}
+
+func stringSliceArg(v any) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch values := v.(type) {
+	case []string:
+		return append([]string(nil), values...), nil
+	case []any:
+		out := make([]string, 0, len(values))
+		for _, item := range values {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected array of strings")
+			}
+			out = append(out, text)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("expected array")
+	}
+}
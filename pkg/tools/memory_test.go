@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/memory"
+)
+
+func TestMemoryHandlers_WriteThenSearch(t *testing.T) {
+	svc := newMemoryService(t)
+	write := NewMemoryWriteHandler(svc)
+	search := NewMemorySearchHandler(svc)
+
+	if _, err := write(context.Background(), map[string]any{
+		"content": "integration tests write artifacts under .local/test-artifacts",
+		"tags":    []any{"testing"},
+	}); err != nil {
+		t.Fatalf("memory_write: %v", err)
+	}
+
+	result, err := search(context.Background(), map[string]any{"query": "where do testing artifacts go"})
+	if err != nil {
+		t.Fatalf("memory_search: %v", err)
+	}
+	if !strings.Contains(result, ".local/test-artifacts") || !strings.Contains(result, "[testing]") {
+		t.Fatalf("unexpected search result: %s", result)
+	}
+}
+
+func TestMemorySearchHandler_NoMatches(t *testing.T) {
+	search := NewMemorySearchHandler(newMemoryService(t))
+
+	result, err := search(context.Background(), map[string]any{"query": "anything"})
+	if err != nil {
+		t.Fatalf("memory_search: %v", err)
+	}
+	if result != "No relevant memories." {
+		t.Fatalf("unexpected result: %q", result)
+	}
+}
+
+func TestMemoryWriteHandler_RejectsInvalidTags(t *testing.T) {
+	write := NewMemoryWriteHandler(newMemoryService(t))
+
+	_, err := write(context.Background(), map[string]any{
+		"content": "fact",
+		"tags":    []any{float64(1)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "tags") {
+		t.Fatalf("expected tags error, got %v", err)
+	}
+}
+
+func newMemoryService(t *testing.T) *memory.Service {
+	t.Helper()
+
+	repo, err := memory.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRepository: %v", err)
+	}
+	return memory.NewService(repo, nil)
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// MultiEditToolDef returns the definition for the multi_edit tool.
+func MultiEditToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "multi_edit",
+			Description: openai.String("Apply several find/replace edits to one file in order, atomically: " +
+				"if any edit's old_text is not found, nothing is written. Each edit sees the result of the previous ones. " +
+				"Prefer this over several edit_file calls on the same file."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string", "description": "The absolute or relative path to the file."},
+					"edits": map[string]any{
+						"type":        "array",
+						"description": "The edits to apply, in order.",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"old_text":    map[string]any{"type": "string", "description": "The exact text to find."},
+								"new_text":    map[string]any{"type": "string", "description": "The text to replace it with."},
+								"replace_all": map[string]any{"type": "boolean", "description": "Replace every occurrence instead of the first (default false)."},
+							},
+							"required": []string{"old_text", "new_text"},
+						},
+						"minItems": 1,
+					},
+				},
+				"required": []string{"path", "edits"},
+			},
+		},
+	}
+}
+
+type textEdit struct {
+	oldText, newText string
+	replaceAll       bool
+}
+
+// MultiEditHandler executes the multi_edit tool.
+func MultiEditHandler(ctx context.Context, args map[string]any) (string, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing or invalid 'path' argument")
+	}
+	edits, err := parseTextEdits(args["edits"])
+	if err != nil {
+		return "", err
+	}
+
+	safe, err := safePath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(safe)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	src, replacements, err := applyTextEdits(string(content), edits, safe)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(safe, []byte(src)); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return fmt.Sprintf("Applied %d edits (%d replacements) to %s", len(edits), replacements, safe), nil
+}
+
+// applyTextEdits applies edits to src in order and returns the result and the
+// number of replacements. Any edit whose old_text is missing fails them all.
+func applyTextEdits(src string, edits []textEdit, path string) (string, int, error) {
+	replacements := 0
+	for i, edit := range edits {
+		count := strings.Count(src, edit.oldText)
+		if count == 0 {
+			return "", 0, fmt.Errorf("edit %d of %d: old_text not found in %s; no edits were applied", i+1, len(edits), path)
+		}
+		if edit.replaceAll {
+			src = strings.ReplaceAll(src, edit.oldText, edit.newText)
+			replacements += count
+		} else {
+			src = strings.Replace(src, edit.oldText, edit.newText, 1)
+			replacements++
+		}
+	}
+	return src, replacements, nil
+}
+
+func parseTextEdits(raw any) ([]textEdit, error) {
+	items, ok := raw.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("missing or invalid 'edits' argument: want a non-empty array")
+	}
+	edits := make([]textEdit, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: want an object", i+1)
+		}
+		oldText, ok := obj["old_text"].(string)
+		if !ok || oldText == "" {
+			return nil, fmt.Errorf("edit %d: missing or empty 'old_text'", i+1)
+		}
+		newText, ok := obj["new_text"].(string)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: missing or invalid 'new_text'", i+1)
+		}
+		replaceAll, _ := obj["replace_all"].(bool)
+		edits = append(edits, textEdit{oldText: oldText, newText: newText, replaceAll: replaceAll})
+	}
+	return edits, nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, keeping the original permissions, so readers never see a
+// half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMultiEditHandler_AppliesEditsInOrder(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if err := os.WriteFile("main.go", []byte("a := 1\nb := a\nc := a\n"), 0600); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+
+		result, err := MultiEditHandler(context.Background(), map[string]any{
+			"path": "main.go",
+			"edits": []any{
+				map[string]any{"old_text": "a := 1", "new_text": "x := 1"},
+				map[string]any{"old_text": "= a", "new_text": "= x", "replace_all": true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(result, "Applied 2 edits (3 replacements)") {
+			t.Fatalf("unexpected result %q", result)
+		}
+
+		data, _ := os.ReadFile("main.go")
+		if got, want := string(data), "x := 1\nb := x\nc := x\n"; got != want {
+			t.Fatalf("content = %q, want %q", got, want)
+		}
+		if info, _ := os.Stat("main.go"); info.Mode().Perm() != 0600 {
+			t.Fatalf("mode = %v, want 0600 preserved", info.Mode().Perm())
+		}
+	})
+}
+
+func TestMultiEditHandler_IsAllOrNothing(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		original := "alpha beta\n"
+		if err := os.WriteFile("note.txt", []byte(original), 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+
+		_, err := MultiEditHandler(context.Background(), map[string]any{
+			"path": "note.txt",
+			"edits": []any{
+				map[string]any{"old_text": "alpha", "new_text": "ALPHA"},
+				map[string]any{"old_text": "gamma", "new_text": "GAMMA"},
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), "edit 2 of 2") {
+			t.Fatalf("error = %v, want failure on edit 2", err)
+		}
+		data, _ := os.ReadFile("note.txt")
+		if string(data) != original {
+			t.Fatalf("file was modified: %q", data)
+		}
+		entries, _ := os.ReadDir(".")
+		if len(entries) != 1 {
+			t.Fatalf("leftover files: %v", entries)
+		}
+	})
+}
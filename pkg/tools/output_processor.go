@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AllTools is the key of OutputProcessors that applies to every tool.
+const AllTools = "*"
+
+// OutputProcessor rewrites the output of a tool before the model sees it.
+type OutputProcessor func(string) string
+
+// Processor names, as used in the output_processors config.
+const (
+	ProcessStripANSI        = "strip_ansi"
+	ProcessCollapseProgress = "collapse_progress"
+	ProcessDedupeLines      = "dedupe_lines"
+)
+
+var outputProcessors = map[string]OutputProcessor{
+	ProcessStripANSI:        StripANSI,
+	ProcessCollapseProgress: CollapseProgress,
+	ProcessDedupeLines:      DedupeLines,
+}
+
+// OutputProcessors returns a middleware that runs the named processors, in
+// order, over the output of each tool: byTool maps a tool name, or AllTools,
+// to processor names. A tool listed by name does not also get the AllTools
+// processors. Unknown names are ignored; config.Load rejects them.
+func OutputProcessors(byTool map[string][]string) Middleware {
+	return func(name string, next Handler) Handler {
+		names, ok := byTool[name]
+		if !ok {
+			names = byTool[AllTools]
+		}
+		var chain []OutputProcessor
+		for _, n := range names {
+			if p, ok := outputProcessors[n]; ok {
+				chain = append(chain, p)
+			}
+		}
+		if len(chain) == 0 {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			output, err := next(ctx, args)
+			for _, p := range chain {
+				output = p(output)
+			}
+			return output, err
+		}
+	}
+}
+
+// ansiPattern matches CSI sequences (colors, cursor movement), OSC
+// sequences (titles, hyperlinks) and other two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// StripANSI removes terminal escape sequences, which cost tokens and mean
+// nothing to the model.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// progressPattern matches lines that only report progress: go test -v
+// bookkeeping, module downloads, npm timing lines, braille spinners and
+// percentage bars.
+var progressPattern = regexp.MustCompile(`^\s*(?:` +
+	`=== (?:RUN|PAUSE|CONT|NAME)\s|` +
+	`go: (?:downloading|finding|extracting) |` +
+	`npm (?:timing|http fetch|sill|verb) |` +
+	`[⠀-⣿]\s|` +
+	`\[?[=#>\-. ]{4,}\]?\s*\d{1,3}(?:\.\d+)?%` +
+	`)`)
+
+// CollapseProgress keeps what a terminal would show of lines redrawn with
+// carriage returns and drops lines that only report progress, noting how
+// many were dropped.
+func CollapseProgress(s string) string {
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	dropped := 0
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+			line = line[i+1:]
+		}
+		if progressPattern.MatchString(line) {
+			dropped++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if dropped == 0 {
+		return strings.Join(kept, "\n")
+	}
+	return strings.Join(kept, "\n") + fmt.Sprintf("\n[%d progress lines removed]", dropped)
+}
+
+// DedupeLines collapses runs of identical lines into one line followed by
+// how often it repeated.
+func DedupeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && lines[j] == lines[i] {
+			j++
+		}
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(lines[i])
+		if n := j - i - 1; n > 0 && strings.TrimSpace(lines[i]) != "" {
+			fmt.Fprintf(&b, "\n[previous line repeated %d more times]", n)
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOutputProcessors_AppliesPerToolChains(t *testing.T) {
+	registry := New()
+	noisy := "\x1b[32mok\x1b[0m\nsame\nsame\nsame"
+	registry.Register(BashToolDef(), func(context.Context, map[string]any) (string, error) { return noisy, nil })
+	registry.Register(GrepToolDef(), func(context.Context, map[string]any) (string, error) { return noisy, nil })
+	registry.Register(ReadFileToolDef(), func(context.Context, map[string]any) (string, error) { return noisy, nil })
+	processed := registry.WithMiddleware(OutputProcessors(map[string][]string{
+		"bash":   {ProcessStripANSI, ProcessDedupeLines, "unknown"},
+		AllTools: {ProcessStripANSI},
+		"grep":   {},
+	}))
+	ctx := context.Background()
+
+	if out, _ := processed.Dispatch(ctx, "bash", nil); out != "ok\nsame\n[previous line repeated 2 more times]" {
+		t.Fatalf("bash = %q", out)
+	}
+	if out, _ := processed.Dispatch(ctx, "read_file", nil); out != "ok\nsame\nsame\nsame" {
+		t.Fatalf("read_file = %q, want only the * chain", out)
+	}
+	if out, _ := processed.Dispatch(ctx, "grep", nil); out != noisy {
+		t.Fatalf("grep = %q, want it untouched", out)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	in := "\x1b[1;31mFAIL\x1b[0m \x1b]8;;https://example.com\x07link\x1b]8;;\x07 \x1b[2K\x1b[1Gdone"
+	if got := StripANSI(in); got != "FAIL link done" {
+		t.Fatalf("StripANSI = %q", got)
+	}
+}
+
+func TestCollapseProgress(t *testing.T) {
+	in := strings.Join([]string{
+		"go: downloading golang.org/x/text v0.14.0",
+		"=== RUN   TestParse",
+		"--- PASS: TestParse (0.00s)",
+		"Downloading  10%\rDownloading  55%\rDownloaded 3 files",
+		"[=====>     ]  45%",
+		"⠙ reify:lodash: timing reifyNode",
+		"ok  \texample.com/pkg\t0.012s",
+	}, "\n")
+	want := "--- PASS: TestParse (0.00s)\nDownloaded 3 files\nok  \texample.com/pkg\t0.012s\n[4 progress lines removed]"
+	if got := CollapseProgress(in); got != want {
+		t.Fatalf("CollapseProgress =\n%s\nwant\n%s", got, want)
+	}
+	if got := CollapseProgress("plain\noutput"); got != "plain\noutput" {
+		t.Fatalf("plain output changed: %q", got)
+	}
+}
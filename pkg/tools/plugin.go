@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/tokens"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// DefaultPluginDir is where tool executables are looked up, relative to the
+// workspace.
+const DefaultPluginDir = ".agent/tools"
+
+const (
+	describeTimeout          = 10 * time.Second
+	defaultPluginTimeout     = 60 * time.Second
+	maxPluginOutputTokens    = 12000
+	maxPluginDescribeBytes   = 1 << 20
+	pluginDescribeFlag       = "--describe"
+	defaultPluginDescription = "External tool."
+)
+
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Plugin is an external tool executable. Run with --describe it prints its
+// description as JSON:
+//
+//	{
+//	  "name": "jira_issue",
+//	  "description": "Fetch a Jira issue.",
+//	  "parameters": {"type": "object", "properties": {"key": {"type": "string"}}},
+//	  "requires_approval": false,
+//	  "timeout_seconds": 30
+//	}
+//
+// When called, it gets the arguments as a JSON object on stdin, runs in the
+// agent's working directory and prints its result on stdout. A non-zero exit
+// status is reported to the model together with stderr.
+//
+// A .wasm file is a WebAssembly plugin run in-process in a WASI sandbox
+// instead; it may ask for access to the workspace, see Filesystem.
+type Plugin struct {
+	Path             string         `json:"-"`
+	Name             string         `json:"name"`
+	Description      string         `json:"description"`
+	Parameters       map[string]any `json:"parameters"`
+	RequiresApproval bool           `json:"requires_approval"`
+	TimeoutSeconds   int            `json:"timeout_seconds"`
+	// Filesystem is the workspace access a WebAssembly plugin asks for:
+	// FilesystemNone (the default), FilesystemRead or FilesystemWrite. The
+	// plugin does not grant it to itself: every call with access is an
+	// approval request, allowed by the user or a permissions.allow rule of
+	// their settings. Native plugins always run with the user's permissions.
+	Filesystem string `json:"filesystem"`
+}
+
+// LoadPlugins describes every executable in dir. A missing dir yields no
+// plugins. Executables that fail to describe themselves are skipped and
+// reported in the error, so one broken plugin does not hide the others.
+func LoadPlugins(ctx context.Context, dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		plugins []Plugin
+		errs    []error
+		seen    = make(map[string]string)
+	)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || !(isWASM(path) || isExecutable(path, info)) {
+			continue
+		}
+		plugin, err := describePlugin(ctx, path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", entry.Name(), err))
+			continue
+		}
+		if other, dup := seen[plugin.Name]; dup {
+			errs = append(errs, fmt.Errorf("plugin %s: tool %q is already provided by %s", entry.Name(), plugin.Name, other))
+			continue
+		}
+		seen[plugin.Name] = entry.Name()
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, errors.Join(errs...)
+}
+
+func isExecutable(path string, info os.FileInfo) bool {
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd", ".com":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+func describePlugin(ctx context.Context, path string) (Plugin, error) {
+	// Compiling a module can take longer than the timeout, under -race
+	// especially; compiled, it is cached for --describe and the calls.
+	if isWASM(path) {
+		if err := compileWASM(ctx, path); err != nil {
+			return Plugin{}, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+	// Describing needs no capabilities: a WebAssembly plugin gets no mounts.
+	var stdout, stderr bytes.Buffer
+	if err := runPlugin(ctx, Plugin{Path: path}, "", nil, &stdout, &stderr, pluginDescribeFlag); err != nil {
+		return Plugin{}, fmt.Errorf("%s failed: %w %s", pluginDescribeFlag, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() > maxPluginDescribeBytes {
+		return Plugin{}, fmt.Errorf("%s output is too large", pluginDescribeFlag)
+	}
+
+	var plugin Plugin
+	if err := json.Unmarshal(stdout.Bytes(), &plugin); err != nil {
+		return Plugin{}, fmt.Errorf("invalid %s output: %w", pluginDescribeFlag, err)
+	}
+	plugin.Path = path
+	plugin.Name = strings.TrimSpace(plugin.Name)
+	if !pluginNamePattern.MatchString(plugin.Name) {
+		return Plugin{}, fmt.Errorf("invalid tool name %q: use letters, digits, _ and -", plugin.Name)
+	}
+	if plugin.Parameters == nil {
+		plugin.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if typ, _ := plugin.Parameters["type"].(string); typ != "object" {
+		return Plugin{}, fmt.Errorf("parameters must be a JSON schema of type object")
+	}
+	if plugin.TimeoutSeconds < 0 {
+		return Plugin{}, fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if err := checkFilesystem(plugin); err != nil {
+		return Plugin{}, err
+	}
+	return plugin, nil
+}
+
+// ToolDef returns the definition the model sees.
+func (p Plugin) ToolDef() openai.ChatCompletionToolParam {
+	description := strings.TrimSpace(p.Description)
+	if description == "" {
+		description = defaultPluginDescription
+	}
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name:        p.Name,
+			Description: openai.String(description),
+			Parameters:  openai.FunctionParameters(p.Parameters),
+		},
+	}
+}
+
+// NewPluginHandler creates a tool handler that runs the plugin. Plugins that
+// declare requires_approval, or ask for access to the workspace, run only
+// when approver allows them; a nil approver denies them.
+func NewPluginHandler(p Plugin, approver permission.Approver) Handler {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+	timeout := defaultPluginTimeout
+	if p.TimeoutSeconds > 0 {
+		timeout = time.Duration(p.TimeoutSeconds) * time.Second
+	}
+
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		input, err := json.Marshal(args)
+		if err != nil {
+			return "", err
+		}
+		summary := fmt.Sprintf("run plugin %s", filepath.Base(p.Path))
+		access := p.Filesystem == FilesystemRead || p.Filesystem == FilesystemWrite
+		if access {
+			summary += fmt.Sprintf(" with %s access to the workspace", p.Filesystem)
+		}
+		if p.RequiresApproval || access {
+			ok, err := approver.Approve(ctx, permission.Request{
+				Tool:    p.Name,
+				Summary: summary,
+				Detail:  string(input),
+			})
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "Error: the user declined to run " + p.Name, nil
+			}
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var stdout, stderr bytes.Buffer
+		err = runPlugin(ctx, p, cwd, bytes.NewReader(input), &stdout, &stderr)
+		reportExitStatus(ctx, err)
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s timed out after %s", p.Name, timeout)
+		}
+		if err != nil {
+			var exit exitCoder
+			if !errors.As(err, &exit) {
+				return "", fmt.Errorf("run %s: %w", p.Name, err)
+			}
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = strings.TrimSpace(stdout.String())
+			}
+			return "", fmt.Errorf("%s exited with status %d: %s", p.Name, exit.ExitCode(), tokens.Truncate(tokens.Default(), msg, maxPluginOutputTokens))
+		}
+
+		result := strings.TrimSpace(stdout.String())
+		if result == "" {
+			result = "(no output)"
+		}
+		return tokens.Truncate(tokens.Default(), result, maxPluginOutputTokens), nil
+	}
+}
+
+// RegisterPlugins registers every plugin in dir on r and returns their
+// names. Plugins named like a tool r already has are skipped and reported,
+// so a plugin cannot replace a built-in tool.
+func RegisterPlugins(ctx context.Context, r *Registry, dir string, approver permission.Approver) ([]string, error) {
+	plugins, err := LoadPlugins(ctx, dir)
+	errs := []error{err}
+	var names []string
+	for _, p := range plugins {
+		if _, exists := r.handlers[p.Name]; exists {
+			errs = append(errs, fmt.Errorf("plugin %s: a tool named %q already exists", filepath.Base(p.Path), p.Name))
+			continue
+		}
+		r.Register(p.ToolDef(), NewPluginHandler(p, approver))
+		names = append(names, p.Name)
+	}
+	return names, errors.Join(errs...)
+}
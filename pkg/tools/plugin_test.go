@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterPlugins_DescribesAndRunsExecutables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "upper", `if [ "$1" = --describe ]; then
+  echo '{"name":"upper","description":"Uppercase text.","parameters":{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}}'
+  exit 0
+fi
+tr a-z A-Z
+`)
+	writePlugin(t, dir, "broken", "echo not json\n")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	names, err := RegisterPlugins(context.Background(), r, dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "plugin broken") {
+		t.Fatalf("expected the broken plugin to be reported, got %v", err)
+	}
+	if len(names) != 1 || names[0] != "upper" {
+		t.Fatalf("names = %v", names)
+	}
+	defs := r.Definitions()
+	if len(defs) != 1 || defs[0].Function.Name != "upper" {
+		t.Fatalf("definitions = %+v", defs)
+	}
+
+	result, err := r.Dispatch(context.Background(), "upper", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != `{"TEXT":"HI"}` {
+		t.Fatalf("result = %q", result)
+	}
+}
+
+func TestRegisterPlugins_MissingDirIsEmpty(t *testing.T) {
+	names, err := RegisterPlugins(context.Background(), New(), filepath.Join(t.TempDir(), "none"), nil)
+	if err != nil || len(names) != 0 {
+		t.Fatalf("RegisterPlugins = %v, %v", names, err)
+	}
+}
+
+func TestRegisterPlugins_KeepsBuiltinTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "bash", `echo '{"name":"bash"}'`+"\n")
+
+	r := New()
+	r.Register(BashToolDef(), func(context.Context, map[string]any) (string, error) { return "", nil })
+	names, err := RegisterPlugins(context.Background(), r, dir, nil)
+	if err == nil || len(names) != 0 {
+		t.Fatalf("RegisterPlugins = %v, %v", names, err)
+	}
+}
+
+func TestPluginHandler_FailureAndApproval(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "deploy", `if [ "$1" = --describe ]; then
+  echo '{"name":"deploy","requires_approval":true}'
+  exit 0
+fi
+echo "no target" >&2
+exit 3
+`)
+	plugins, err := LoadPlugins(context.Background(), dir)
+	if err != nil || len(plugins) != 1 {
+		t.Fatalf("LoadPlugins = %v, %v", plugins, err)
+	}
+
+	var requests []permission.Request
+	deny := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+		requests = append(requests, req)
+		return false, nil
+	})
+	result, err := NewPluginHandler(plugins[0], deny)(context.Background(), map[string]any{"env": "prod"})
+	if err != nil || !strings.Contains(result, "declined") {
+		t.Fatalf("denied run = %q, %v", result, err)
+	}
+	if len(requests) != 1 || requests[0].Tool != "deploy" || requests[0].Detail != `{"env":"prod"}` {
+		t.Fatalf("requests = %+v", requests)
+	}
+
+	_, err = NewPluginHandler(plugins[0], permission.AllowAll)(context.Background(), map[string]any{})
+	if err == nil || !strings.Contains(err.Error(), "status 3: no target") {
+		t.Fatalf("expected exit status error, got %v", err)
+	}
+}
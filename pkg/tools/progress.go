@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressInterval spaces the updates a single tool call reports; the last
+// one (Current reaching Total) is always delivered.
+const progressInterval = 200 * time.Millisecond
+
+// Progress is an update from a running tool, e.g. bytes downloaded or tests
+// completed.
+type Progress struct {
+	// Tool is the name the call was dispatched under.
+	Tool string
+	// Message describes the state, e.g. "12 passed, 1 failed".
+	Message string
+	// Current counts what is done so far; Total is 0 when unknown.
+	Current int64
+	Total   int64
+}
+
+// ProgressHandler receives the progress of running tools.
+type ProgressHandler func(p Progress)
+
+type progressHandlerKey struct{}
+
+type progressReporterKey struct{}
+
+// progressReporter delivers the progress of one tool call.
+type progressReporter struct {
+	tool    string
+	handler ProgressHandler
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// WithProgressHandler attaches h to ctx: tools dispatched with it report
+// their progress to h while they run. A nil h removes any handler.
+func WithProgressHandler(ctx context.Context, h ProgressHandler) context.Context {
+	return context.WithValue(ctx, progressHandlerKey{}, h)
+}
+
+// withProgress gives the call of the tool name its own reporter, so reports
+// carry the tool name and are rate limited per call.
+func withProgress(ctx context.Context, name string) context.Context {
+	h, _ := ctx.Value(progressHandlerKey{}).(ProgressHandler)
+	if h == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressReporterKey{}, &progressReporter{tool: name, handler: h})
+}
+
+// ReportProgress passes p to the progress handler of ctx, if any, filling in
+// the tool name. Updates closer together than progressInterval are dropped,
+// so tools may report as often as is convenient.
+func ReportProgress(ctx context.Context, p Progress) {
+	r, _ := ctx.Value(progressReporterKey{}).(*progressReporter)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	now := time.Now()
+	final := p.Total > 0 && p.Current >= p.Total
+	if !final && now.Sub(r.last) < progressInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.last = now
+	r.mu.Unlock()
+
+	p.Tool = r.tool
+	r.handler(p)
+}
+
+// progressReader reports the bytes read through it, e.g. a download.
+type progressReader struct {
+	ctx   context.Context
+	r     io.Reader
+	read  int64
+	total int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		ReportProgress(r.ctx, Progress{Message: fmt.Sprintf("%d KiB received", r.read>>10), Current: r.read, Total: r.total})
+	}
+	return n, err
+}
+
+// StatusLine renders tool progress as a single line that is rewritten in
+// place, for terminals.
+type StatusLine struct {
+	w io.Writer
+
+	mu    sync.Mutex
+	shown bool
+}
+
+// NewStatusLine returns a StatusLine writing to w, usually os.Stderr.
+func NewStatusLine(w io.Writer) *StatusLine {
+	return &StatusLine{w: w}
+}
+
+// Update shows p, replacing the previous status. It is a ProgressHandler.
+func (s *StatusLine) Update(p Progress) {
+	line := fmt.Sprintf("[%s] %s", p.Tool, p.Message)
+	if p.Total > 0 {
+		line += fmt.Sprintf(" (%d%%)", p.Current*100/p.Total)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "\r\033[K%s", line)
+	s.shown = true
+}
+
+// Clear removes the status line, if one is shown.
+func (s *StatusLine) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shown {
+		fmt.Fprint(s.w, "\r\033[K")
+		s.shown = false
+	}
+}
+
+// Middleware clears the status line when a tool finishes, so the next
+// output starts on a clean line.
+func (s *StatusLine) Middleware() Middleware {
+	return func(_ string, next Handler) Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			defer s.Clear()
+			return next(ctx, args)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// UT-PROGRESS: 工具经 Dispatch 上报的进度带上工具名，同一调用内过密的更新被合并，完成时的更新总会送达。
+func TestReportProgress_ThroughDispatch(t *testing.T) {
+	registry := New()
+	registry.Register(openai.ChatCompletionToolParam{Function: shared.FunctionDefinitionParam{Name: "fetch"}},
+		func(ctx context.Context, _ map[string]any) (string, error) {
+			for i := int64(1); i <= 4; i++ {
+				ReportProgress(ctx, Progress{Message: "receiving", Current: i, Total: 4})
+			}
+			return "done", nil
+		})
+
+	var got []Progress
+	ctx := WithProgressHandler(context.Background(), func(p Progress) { got = append(got, p) })
+	if _, err := registry.Dispatch(ctx, "fetch", nil); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if len(got) != 2 || got[0].Current != 1 || got[1].Current != 4 {
+		t.Fatalf("progress = %+v, want the first and the final update", got)
+	}
+	if got[0].Tool != "fetch" {
+		t.Fatalf("tool = %q", got[0].Tool)
+	}
+
+	// Without a handler reporting is a no-op.
+	if _, err := registry.Dispatch(context.Background(), "fetch", nil); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+}
+
+func TestStatusLine_RewritesAndClears(t *testing.T) {
+	var out bytes.Buffer
+	line := NewStatusLine(&out)
+	handler := line.Middleware()("go_test", func(context.Context, map[string]any) (string, error) {
+		line.Update(Progress{Tool: "go_test", Message: "3 passed"})
+		line.Update(Progress{Tool: "http_request", Message: "512 KiB received", Current: 512, Total: 1024})
+		return "ok", nil
+	})
+	if _, err := handler(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "\r\033[K[go_test] 3 passed\r\033[K[http_request] 512 KiB received (50%)\r\033[K"
+	if out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+	line.Clear()
+	if strings.Count(out.String(), "\r\033[K") != 3 {
+		t.Fatal("Clear without a status line wrote output")
+	}
+}
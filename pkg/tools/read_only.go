@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReadOnlyTools are the tools that never change the workspace. bash is among
+// them only because ReadOnly lets it run read-only commands alone.
+var ReadOnlyTools = []string{
+	"read_file", "list_dir", "list_files", "grep", "code_search", "git_diff", "compact", "bash",
+	"go_to_definition", "find_references", "hover", "memory_search",
+}
+
+// readOnlyCommands are the programs that only read, whatever their arguments
+// (apart from the ones readOnlyArgs rejects).
+var readOnlyCommands = map[string]bool{
+	"ls": true, "cat": true, "head": true, "tail": true, "wc": true, "nl": true,
+	"grep": true, "egrep": true, "fgrep": true, "rg": true, "find": true, "tree": true,
+	"pwd": true, "cd": true, "echo": true, "printf": true, "true": true, "false": true, "test": true,
+	"file": true, "stat": true, "du": true, "df": true, "sort": true, "uniq": true, "cut": true,
+	"tr": true, "diff": true, "cmp": true, "basename": true, "dirname": true, "realpath": true,
+	"which": true, "date": true, "whoami": true, "uname": true, "jq": true, "column": true,
+	"md5sum": true, "sha1sum": true, "sha256sum": true,
+}
+
+var readOnlyGitCommands = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "blame": true, "grep": true,
+	"ls-files": true, "ls-tree": true, "rev-parse": true, "shortlog": true, "describe": true,
+	"cat-file": true, "rev-list": true, "merge-base": true,
+}
+
+var readOnlyGoCommands = map[string]bool{"list": true, "version": true, "doc": true}
+
+var (
+	quotedWord       = regexp.MustCompile(`'[^']*'|"(?:[^"\\]|\\.)*"`)
+	quotedEscape     = regexp.MustCompile(`\\([$"\\\x60\n])`)
+	unquotedEscape   = regexp.MustCompile(`\\(.)`)
+	quotedMarker     = regexp.MustCompile("\x00([0-9]+)\x00")
+	harmlessRedirect = regexp.MustCompile(`[0-9]?>\s*/dev/null|2>&1`)
+	commandSeparator = regexp.MustCompile(`\|\||&&|[|;\n]`)
+	envAssignment    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+)
+
+// IsReadOnlyCommand conservatively reports whether a bash command only reads.
+// Every command of a pipeline or list must be a known reader; redirections
+// to files, command substitution and background jobs make it a write.
+func IsReadOnlyCommand(command string) bool {
+	if strings.Contains(command, "`") || strings.Contains(command, "$(") {
+		return false
+	}
+	// Quoted words are set aside so that the separators and redirections in
+	// them are not taken for the shell's, and put back unquoted into the
+	// arguments: find . '-delete' deletes just as well.
+	var quoted []string
+	cleaned := quotedWord.ReplaceAllStringFunc(command, func(word string) string {
+		quoted = append(quoted, unquote(word))
+		return "\x00" + strconv.Itoa(len(quoted)-1) + "\x00"
+	})
+	cleaned = harmlessRedirect.ReplaceAllString(cleaned, " ")
+	if strings.ContainsAny(strings.ReplaceAll(cleaned, "&&", ""), ">&") || strings.Contains(cleaned, "<(") {
+		return false
+	}
+
+	commands := 0
+	for _, segment := range commandSeparator.Split(cleaned, -1) {
+		fields := strings.Fields(segment)
+		for len(fields) > 0 && envAssignment.MatchString(fields[0]) {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		for i, field := range fields {
+			field = unquotedEscape.ReplaceAllString(field, "$1")
+			fields[i] = quotedMarker.ReplaceAllStringFunc(field, func(marker string) string {
+				n, _ := strconv.Atoi(strings.Trim(marker, "\x00"))
+				return quoted[n]
+			})
+		}
+		if !readOnlyArgs(filepath.Base(fields[0]), fields[1:]) {
+			return false
+		}
+		commands++
+	}
+	return commands > 0
+}
+
+// unquote returns the value of a quoted shell word.
+func unquote(word string) string {
+	if word[0] == '\'' {
+		return word[1 : len(word)-1]
+	}
+	return quotedEscape.ReplaceAllString(word[1:len(word)-1], "$1")
+}
+
+func readOnlyArgs(program string, args []string) bool {
+	switch program {
+	case "git":
+		// Skip global options such as -C dir or --no-pager; -c could set a
+		// config that runs programs.
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			switch args[0] {
+			case "-c":
+				return false
+			case "-C":
+				args = args[1:]
+			}
+			args = args[1:]
+		}
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--output") {
+				return false
+			}
+		}
+		return len(args) > 0 && readOnlyGitCommands[args[0]]
+	case "go":
+		return len(args) > 0 && readOnlyGoCommands[args[0]]
+	case "find":
+		for _, arg := range args {
+			if arg == "-delete" || strings.HasPrefix(arg, "-exec") || strings.HasPrefix(arg, "-ok") || strings.HasPrefix(arg, "-fprint") || arg == "-fls" {
+				return false
+			}
+		}
+	case "sort", "tree", "rg", "file", "date":
+		// Options that write files, run programs or set the clock.
+		for _, arg := range args {
+			switch {
+			case (program == "sort" || program == "tree") && (strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "--output")),
+				program == "rg" && strings.HasPrefix(arg, "--pre"),
+				program == "file" && arg == "-C",
+				program == "date" && (strings.HasPrefix(arg, "-s") || strings.HasPrefix(arg, "--set")):
+				return false
+			}
+		}
+	case "uniq":
+		// A second operand is the output file.
+		operands := 0
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				operands++
+			}
+		}
+		if operands > 1 {
+			return false
+		}
+	}
+	return readOnlyCommands[program]
+}
+
+// ReadOnly returns a registry with only the ReadOnlyTools of r, whose bash
+// refuses the commands IsReadOnlyCommand does not accept. It backs
+// --read-only, for exploring checkouts the agent must not change.
+func ReadOnly(r *Registry) *Registry {
+	return r.Only(ReadOnlyTools...).WithMiddleware(func(name string, next Handler) Handler {
+		if name != "bash" {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			if command, _ := args["command"].(string); !IsReadOnlyCommand(command) {
+				return "Error: read-only mode: the command may modify the workspace and was not run. " +
+					"Use commands that only read (ls, cat, grep, find, git log/diff/show, ...), one per pipeline stage, without redirections to files.", nil
+			}
+			return next(ctx, args)
+		}
+	})
+}
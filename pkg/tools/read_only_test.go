@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsReadOnlyCommand(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		want    bool
+	}{
+		{"ls -la pkg", true},
+		{"grep -rn 'a|b > c' pkg | sort | uniq -c | head", true},
+		{"git --no-pager log --oneline -5 && git -C sub status", true},
+		{"find . -name '*.go' 2>/dev/null | wc -l", true},
+		{"LC_ALL=C sort go.sum", true},
+		{"go list ./... 2>&1", true},
+		{"rg -o 'func \\w+' pkg", true},
+		{"", false},
+		{"rm -rf build", false},
+		{"echo hi > notes.txt", false},
+		{"cat a >> b", false},
+		{"ls; touch x", false},
+		{"cat $(which rm)", false},
+		{"sleep 10 &", false},
+		{"find . -name '*.tmp' -delete", false},
+		{"find . -exec rm {} ;", false},
+		{"git commit -am wip", false},
+		{"git -c core.pager=sh log", false},
+		{"git diff --output=patch", false},
+		{"go test ./...", false},
+		{"sort -o out.txt in.txt", false},
+		{"uniq in.txt out.txt", false},
+		{"sed -i s/a/b/ f", false},
+		{"find . '-delete'", false},
+		{`find . "-exec" rm -f {} ";"`, false},
+		{"sort '-o' out.txt in.txt", false},
+		{`tree "-o" x`, false},
+		{`find . -\delete`, false},
+		{`git "commit" -m wip`, false},
+		{`grep -n "a; rm -rf x" f`, true},
+		{"/bin/ls", true},
+	} {
+		if got := IsReadOnlyCommand(tt.command); got != tt.want {
+			t.Errorf("IsReadOnlyCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestReadOnly_KeepsReadersAndFiltersBash(t *testing.T) {
+	var ran []string
+	registry := New()
+	registry.Register(BashToolDef(), func(_ context.Context, args map[string]any) (string, error) {
+		ran = append(ran, args["command"].(string))
+		return "ok", nil
+	})
+	registry.Register(ReadFileToolDef(), func(context.Context, map[string]any) (string, error) { return "content", nil })
+	registry.Register(WriteFileToolDef(), WriteFileHandler)
+
+	readOnly := ReadOnly(registry)
+	var names []string
+	for _, def := range readOnly.Definitions() {
+		names = append(names, def.Function.Name)
+	}
+	if strings.Join(names, ",") != "bash,read_file" {
+		t.Fatalf("read-only tools = %v", names)
+	}
+	if _, err := readOnly.Dispatch(context.Background(), "write_file", map[string]any{"path": "x", "content": "y"}); err == nil {
+		t.Fatal("write_file should be unknown in read-only mode")
+	}
+	out, err := readOnly.Dispatch(context.Background(), "bash", map[string]any{"command": "rm -rf pkg"})
+	if err != nil || !strings.Contains(out, "read-only mode") {
+		t.Fatalf("mutating command = %q, %v", out, err)
+	}
+	if _, err := readOnly.Dispatch(context.Background(), "bash", map[string]any{"command": "git status"}); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "git status" {
+		t.Fatalf("commands run = %q", ran)
+	}
+	if len(registry.Definitions()) != 3 {
+		t.Fatal("ReadOnly must not change the original registry")
+	}
+}
+
+func TestGitDiffHandler(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "a.txt")
+	git("commit", "-qm", "init")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	withWorkingDir(t, dir, func() {
+		out, err := GitDiffHandler(context.Background(), map[string]any{"path": "a.txt"})
+		if err != nil || !strings.Contains(out, "-one\n+two") {
+			t.Fatalf("git_diff = %q, %v", out, err)
+		}
+		out, err = GitDiffHandler(context.Background(), map[string]any{"staged": true})
+		if err != nil || out != "(no changes)" {
+			t.Fatalf("staged git_diff = %q, %v", out, err)
+		}
+		if _, err := GitDiffHandler(context.Background(), map[string]any{"revision": "--output=x"}); err == nil {
+			t.Fatal("expected an error for an option as revision")
+		}
+	})
+}
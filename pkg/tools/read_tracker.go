@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// DefaultConflictCheckedTools are the tools ReadTracker guards against
+// overwriting external changes.
+var DefaultConflictCheckedTools = []string{"edit_file", "multi_edit", "write_file"}
+
+// ReadTracker remembers the content hash of every file the agent reads or
+// writes. An edit to a tracked file whose content has since changed on disk,
+// for example because the user edited it meanwhile, is refused until the
+// agent reads the file again. Files the agent never read are not checked.
+type ReadTracker struct {
+	mu     sync.Mutex
+	hashes map[string][sha256.Size]byte
+}
+
+// NewReadTracker creates an empty tracker.
+func NewReadTracker() *ReadTracker {
+	return &ReadTracker{hashes: make(map[string][sha256.Size]byte)}
+}
+
+// Middleware records hashes after read_file and checks them before the
+// editTools (DefaultConflictCheckedTools when empty) run.
+func (t *ReadTracker) Middleware(editTools ...string) Middleware {
+	if len(editTools) == 0 {
+		editTools = DefaultConflictCheckedTools
+	}
+	return func(name string, next Handler) Handler {
+		if name != "read_file" && !slices.Contains(editTools, name) {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			raw, _ := args["path"].(string)
+			path, err := ResolveWorkspacePath(ctx, raw)
+			if raw == "" || err != nil {
+				return next(ctx, args) // let the tool report the invalid path
+			}
+			if name != "read_file" {
+				if err := t.check(path, raw); err != nil {
+					return "", err
+				}
+			}
+			result, err := next(ctx, args)
+			if err == nil {
+				t.record(path)
+			}
+			return result, err
+		}
+	}
+}
+
+// check fails when path was tracked and its content no longer matches.
+func (t *ReadTracker) check(path, display string) error {
+	t.mu.Lock()
+	want, tracked := t.hashes[path]
+	t.mu.Unlock()
+	if !tracked {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%s was deleted since you last read it; check with the user before recreating it", display)
+	case err != nil:
+		return fmt.Errorf("failed to check %s for external changes: %w", display, err)
+	case sha256.Sum256(data) != want:
+		return fmt.Errorf("%s changed on disk since you last read it (probably edited by the user); "+
+			"read it again with read_file and redo the edit against the current content", display)
+	}
+	return nil
+}
+
+func (t *ReadTracker) record(path string) {
+	data, err := os.ReadFile(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		delete(t.hashes, path)
+		return
+	}
+	t.hashes[path] = sha256.Sum256(data)
+}
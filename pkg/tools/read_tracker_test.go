@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTrackedFileRegistry() *Registry {
+	registry := New()
+	registry.Register(ReadFileToolDef(), ReadFileHandler)
+	registry.Register(EditFileToolDef(), EditFileHandler)
+	registry.Register(WriteFileToolDef(), WriteFileHandler)
+	return registry.WithMiddleware(NewReadTracker().Middleware())
+}
+
+func TestReadTracker_RefusesEditAfterExternalChange(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		ctx := context.Background()
+		registry := newTrackedFileRegistry()
+		if err := os.WriteFile("main.go", []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+		if _, err := registry.Dispatch(ctx, "read_file", map[string]any{"path": "main.go"}); err != nil {
+			t.Fatalf("read_file: %v", err)
+		}
+
+		// The user edits the file behind the agent's back.
+		if err := os.WriteFile("main.go", []byte("package main\n\n// user edit\n"), 0644); err != nil {
+			t.Fatalf("failed to simulate external edit: %v", err)
+		}
+		edit := map[string]any{"path": "main.go", "old_text": "package main", "new_text": "package app"}
+		_, err := registry.Dispatch(ctx, "edit_file", edit)
+		if err == nil || !strings.Contains(err.Error(), "changed on disk") {
+			t.Fatalf("edit_file error = %v, want external change refusal", err)
+		}
+		data, _ := os.ReadFile("main.go")
+		if !strings.Contains(string(data), "// user edit") {
+			t.Fatalf("user edit was clobbered: %q", data)
+		}
+
+		// Re-reading clears the conflict.
+		if _, err := registry.Dispatch(ctx, "read_file", map[string]any{"path": "main.go"}); err != nil {
+			t.Fatalf("read_file: %v", err)
+		}
+		if _, err := registry.Dispatch(ctx, "edit_file", edit); err != nil {
+			t.Fatalf("edit_file after re-read: %v", err)
+		}
+	})
+}
+
+func TestReadTracker_AllowsConsecutiveOwnEdits(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		ctx := context.Background()
+		registry := newTrackedFileRegistry()
+		if err := os.WriteFile("note.txt", []byte("a b"), 0644); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+		if _, err := registry.Dispatch(ctx, "read_file", map[string]any{"path": "note.txt"}); err != nil {
+			t.Fatalf("read_file: %v", err)
+		}
+		for _, edit := range []map[string]any{
+			{"path": "note.txt", "old_text": "a", "new_text": "x"},
+			{"path": "note.txt", "old_text": "b", "new_text": "y"},
+		} {
+			if _, err := registry.Dispatch(ctx, "edit_file", edit); err != nil {
+				t.Fatalf("edit_file(%v): %v", edit, err)
+			}
+		}
+		// Files never read are not checked.
+		if _, err := registry.Dispatch(ctx, "write_file", map[string]any{"path": "new.txt", "content": "hi"}); err != nil {
+			t.Fatalf("write_file: %v", err)
+		}
+	})
+}
@@ -3,8 +3,12 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/nickdu2009/learn-claude-code/pkg/redact"
 	"github.com/openai/openai-go"
 )
 
@@ -13,10 +17,15 @@ import (
 // and returns a string result or an error.
 type Handler func(ctx context.Context, args map[string]any) (string, error)
 
+// Middleware wraps the handler of the named tool, e.g. to post-process its
+// output or run side effects after it succeeds.
+type Middleware func(name string, next Handler) Handler
+
 // Registry holds tool definitions and their corresponding handlers.
 type Registry struct {
 	definitions []openai.ChatCompletionToolParam
 	handlers    map[string]Handler
+	middlewares []Middleware
 }
 
 // New creates an empty Registry.
@@ -26,10 +35,98 @@ func New() *Registry {
 	}
 }
 
-// Register adds a tool definition and its handler to the registry.
+// Register adds a tool definition and its handler to the registry. A tool
+// with the same name is replaced, so Definitions never repeats a name.
 func (r *Registry) Register(def openai.ChatCompletionToolParam, handler Handler) {
-	r.definitions = append(r.definitions, def)
-	r.handlers[def.Function.Name] = handler
+	name := def.Function.Name
+	if i := slices.IndexFunc(r.definitions, func(d openai.ChatCompletionToolParam) bool { return d.Function.Name == name }); i >= 0 {
+		// Registries derived with WithMiddleware share the backing array.
+		r.definitions = slices.Clone(r.definitions)
+		r.definitions[i] = def
+	} else {
+		r.definitions = append(r.definitions, def)
+	}
+	r.handlers[name] = handler
+}
+
+// Has reports whether a tool named name is registered.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.handlers[name]
+	return ok
+}
+
+// NamespaceSeparator joins a tool source and a tool name, as in
+// "github__search_issues".
+const NamespaceSeparator = "__"
+
+// Namespaced returns the name of tool name from source, e.g. an MCP server.
+func Namespaced(source, name string) string {
+	return source + NamespaceSeparator + name
+}
+
+// ConflictPolicy decides what RegisterFrom does with a tool named like one
+// the registry already has.
+type ConflictPolicy string
+
+const (
+	// ConflictNamespace registers the tool as Namespaced(source, name). It is
+	// the default.
+	ConflictNamespace ConflictPolicy = "namespace"
+	// ConflictSkip keeps the tool registered first and drops the new one.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictError refuses the new tool with ErrConflict.
+	ConflictError ConflictPolicy = "error"
+)
+
+// ErrConflict is returned under ConflictError for a tool whose name is taken.
+var ErrConflict = errors.New("tool name conflict")
+
+// ConflictPolicies lists the valid policies.
+var ConflictPolicies = []ConflictPolicy{ConflictNamespace, ConflictSkip, ConflictError}
+
+// maxToolName is the longest function name the API accepts.
+const maxToolName = 64
+
+// RegisterFrom registers a tool provided by source under the name
+// ResolveName gives it and returns that name, or "" when it was skipped.
+// The handler gets the arguments unchanged, so it can still call the tool
+// by its own name.
+func (r *Registry) RegisterFrom(source string, def openai.ChatCompletionToolParam, handler Handler, policy ConflictPolicy) (string, error) {
+	name, err := r.ResolveName(source, def.Function.Name, policy)
+	if err != nil || name == "" {
+		return "", err
+	}
+	def.Function.Name = name
+	r.Register(def, handler)
+	return name, nil
+}
+
+// ResolveName returns the name a tool called name from source, e.g. an MCP
+// server, gets on r: name itself when it is free, otherwise what policy
+// says, Namespaced(source, name) or "" to skip the tool.
+func (r *Registry) ResolveName(source, name string, policy ConflictPolicy) (string, error) {
+	if !r.Has(name) {
+		return name, nil
+	}
+	switch policy {
+	case ConflictSkip:
+		return "", nil
+	case ConflictError:
+		return "", fmt.Errorf("%w: %s: a tool named %q already exists", ErrConflict, source, name)
+	case ConflictNamespace, "":
+	default:
+		return "", fmt.Errorf("unknown tool conflict policy %q", policy)
+	}
+	namespaced := Namespaced(source, name)
+	switch {
+	case strings.Contains(source, NamespaceSeparator):
+		return "", fmt.Errorf("tool source %q must not contain %q", source, NamespaceSeparator)
+	case len(namespaced) > maxToolName:
+		return "", fmt.Errorf("%s: tool %q is already taken and %q is longer than %d characters", source, name, namespaced, maxToolName)
+	case r.Has(namespaced):
+		return "", fmt.Errorf("%s: tools %q and %q are both taken", source, name, namespaced)
+	}
+	return namespaced, nil
 }
 
 // Definitions returns the list of tool definitions for the API request.
@@ -37,11 +134,58 @@ func (r *Registry) Definitions() []openai.ChatCompletionToolParam {
 	return r.definitions
 }
 
+// WithMiddleware returns a registry that shares r's tools but dispatches
+// through mw in addition to r's existing middlewares. r itself is unchanged.
+// The first middleware in the chain is the outermost wrapper. Register tools
+// before deriving so both registries see the same set.
+func (r *Registry) WithMiddleware(mw ...Middleware) *Registry {
+	middlewares := make([]Middleware, 0, len(r.middlewares)+len(mw))
+	middlewares = append(middlewares, r.middlewares...)
+	middlewares = append(middlewares, mw...)
+	return &Registry{
+		definitions: slices.Clip(r.definitions),
+		handlers:    r.handlers,
+		middlewares: middlewares,
+	}
+}
+
+// Only returns a registry with just the named tools of r, dispatched through
+// r's middlewares. Tools registered on either registry later are not shared.
+func (r *Registry) Only(names ...string) *Registry {
+	only := &Registry{
+		handlers:    make(map[string]Handler),
+		middlewares: slices.Clip(r.middlewares),
+	}
+	for _, def := range r.definitions {
+		if name := def.Function.Name; slices.Contains(names, name) {
+			only.definitions = append(only.definitions, def)
+			only.handlers[name] = r.handlers[name]
+		}
+	}
+	return only
+}
+
 // Dispatch executes the handler for the given tool name with the provided arguments.
+// Secrets in the tool's output are redacted before any middleware sees it
+// (see redact.Enabled). The tool reports progress (ReportProgress) to the
+// handler of ctx, see WithProgressHandler.
 func (r *Registry) Dispatch(ctx context.Context, name string, args map[string]any) (string, error) {
 	handler, ok := r.handlers[name]
 	if !ok {
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
-	return handler(ctx, args)
+	if redact.Enabled() {
+		handler = redactOutput(handler)
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](name, handler)
+	}
+	return handler(withProgress(ctx, name), args)
+}
+
+func redactOutput(next Handler) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		output, err := next(ctx, args)
+		return redact.Text(output), err
+	}
 }
@@ -2,8 +2,11 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/sandbox"
 )
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -107,3 +110,135 @@ func TestRegistry_AllS02Tools(t *testing.T) {
 		}
 	}
 }
+
+// TestRegistry_RegisterFrom_ResolvesConflicts: 同名工具按策略加命名空间、跳过或报错，Definitions 中不会出现重名。
+func TestRegistry_RegisterFrom_ResolvesConflicts(t *testing.T) {
+	reply := func(out string) Handler {
+		return func(context.Context, map[string]any) (string, error) { return out, nil }
+	}
+	r := New()
+	r.Register(ReadFileToolDef(), reply("builtin"))
+	derived := r.WithMiddleware()
+	r.Register(ReadFileToolDef(), reply("replaced"))
+	if len(r.Definitions()) != 1 || len(derived.Definitions()) != 1 {
+		t.Fatalf("re-registering repeated a name: %d definitions", len(r.Definitions()))
+	}
+
+	if name, err := r.RegisterFrom("files", ReadFileToolDef(), reply("files"), ConflictNamespace); err != nil || name != "files__read_file" {
+		t.Fatalf("namespace = %q, %v", name, err)
+	}
+	if name, err := r.RegisterFrom("other", ReadFileToolDef(), reply("other"), ConflictSkip); err != nil || name != "" {
+		t.Fatalf("skip = %q, %v", name, err)
+	}
+	if _, err := r.RegisterFrom("other", ReadFileToolDef(), reply("other"), ConflictError); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error policy: %v", err)
+	}
+	if _, err := r.RegisterFrom("files", ReadFileToolDef(), reply("files"), ConflictNamespace); err == nil || !strings.Contains(err.Error(), "both taken") {
+		t.Fatalf("second namespace: %v", err)
+	}
+	if name, err := r.RegisterFrom("files", WriteFileToolDef(), reply("write"), ConflictError); err != nil || name != "write_file" {
+		t.Fatalf("free name = %q, %v", name, err)
+	}
+
+	var names []string
+	for _, def := range r.Definitions() {
+		names = append(names, def.Function.Name)
+	}
+	if strings.Join(names, ",") != "read_file,files__read_file,write_file" {
+		t.Fatalf("definitions = %v", names)
+	}
+	if out, _ := r.Dispatch(context.Background(), "files__read_file", nil); out != "files" {
+		t.Fatalf("files__read_file dispatched to %q", out)
+	}
+}
+
+// TestRegistry_WithMiddleware_WrapsInOrder: 中间件按注册顺序由外到内包裹，且不影响原 Registry。
+func TestRegistry_WithMiddleware_WrapsInOrder(t *testing.T) {
+	r := New()
+	r.Register(BashToolDef(), func(_ context.Context, _ map[string]any) (string, error) {
+		return "core", nil
+	})
+
+	tag := func(label string) Middleware {
+		return func(name string, next Handler) Handler {
+			return func(ctx context.Context, args map[string]any) (string, error) {
+				out, err := next(ctx, args)
+				return label + "(" + out + ")", err
+			}
+		}
+	}
+	wrapped := r.WithMiddleware(tag("outer")).WithMiddleware(tag("inner"))
+
+	result, err := wrapped.Dispatch(context.Background(), "bash", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "outer(inner(core))" {
+		t.Errorf("unexpected middleware order: %q", result)
+	}
+
+	plain, err := r.Dispatch(context.Background(), "bash", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain != "core" {
+		t.Errorf("original registry should be unchanged, got %q", plain)
+	}
+}
+
+// TestRegistry_Dispatch_RedactsSecrets: 工具输出中的密钥在任何中间件看到之前即被脱敏。
+func TestRegistry_Dispatch_RedactsSecrets(t *testing.T) {
+	r := New()
+	r.Register(ReadFileToolDef(), func(_ context.Context, _ map[string]any) (string, error) {
+		return "DASHSCOPE_API_KEY=sk-0123456789abcdefABCDEF\nMODEL=qwen-plus", nil
+	})
+	var seen string
+	observed := r.WithMiddleware(func(name string, next Handler) Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			out, err := next(ctx, args)
+			seen = out
+			return out, err
+		}
+	})
+
+	result, err := observed.Dispatch(context.Background(), "read_file", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(result, "sk-0123") || strings.Contains(seen, "sk-0123") {
+		t.Fatalf("secret leaked: result=%q middleware saw=%q", result, seen)
+	}
+	if !strings.Contains(result, "MODEL=qwen-plus") {
+		t.Errorf("non-secret content was altered: %q", result)
+	}
+}
+
+// FUZZ-DISPATCH: 任意 JSON 参数经 Dispatch 送入各工具都不 panic，危险命令到不了执行器。
+func FuzzRegistryDispatch(f *testing.F) {
+	for _, seed := range []string{
+		`{"command":"ls"}`, `{"command":"rm -rf /"}`, `{"command":"r\\m -rf  /"}`, `{"command":123}`,
+		`{"command":["sudo"]}`, `{"path":"../../etc/passwd"}`, `{"path":"README.md","offset":-1,"limit":1e309}`,
+		`{"path":null}`, `{"path":{"nested":true}}`, `{}`, `null`, `{"offset":"10","limit":0.5,"path":"go.mod"}`,
+	} {
+		for tool := range uint8(3) {
+			f.Add(tool, seed)
+		}
+	}
+	f.Fuzz(func(t *testing.T, tool uint8, raw string) {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return
+		}
+		executor := &recordingExecutor{output: "ok"}
+		r := New()
+		r.Register(BashToolDef(), NewBashHandler(executor))
+		r.Register(ReadFileToolDef(), ReadFileHandler)
+		r.Register(ListDirToolDef(), ListDirHandler)
+
+		name := r.Definitions()[int(tool)%len(r.Definitions())].Function.Name
+		_, _ = r.Dispatch(context.Background(), name, args)
+		if executor.command != "" && isDangerous(sandbox.FamilyPOSIX, executor.command) {
+			t.Fatalf("dangerous command reached the executor: %q", executor.command)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxRepeats is how many identical consecutive tool calls RepeatGuard
+// executes before it intervenes.
+const DefaultMaxRepeats = 3
+
+// RepeatGuard stops the model from spinning on the same tool call. Once the
+// same tool has been called with the same arguments maxRepeats times in a
+// row, further identical calls are not executed; the model gets an
+// intervention message telling it to change approach instead. Any different
+// call resets the streak.
+type RepeatGuard struct {
+	maxRepeats int
+
+	mu     sync.Mutex
+	last   string
+	streak int
+}
+
+// NewRepeatGuard creates a guard; maxRepeats <= 0 uses DefaultMaxRepeats.
+func NewRepeatGuard(maxRepeats int) *RepeatGuard {
+	if maxRepeats <= 0 {
+		maxRepeats = DefaultMaxRepeats
+	}
+	return &RepeatGuard{maxRepeats: maxRepeats}
+}
+
+// Middleware applies the guard to every tool.
+func (g *RepeatGuard) Middleware() Middleware {
+	return func(name string, next Handler) Handler {
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			if streak := g.observe(name, args); streak > g.maxRepeats {
+				return fmt.Sprintf("Not executed: you have called %s with the same arguments %d times in a row. "+
+					"You are repeating yourself; change approach. Read the previous result carefully, "+
+					"try a different command or different arguments, or ask the user for help.", name, streak-1), nil
+			}
+			return next(ctx, args)
+		}
+	}
+}
+
+// Reset forgets the current streak, e.g. when the user sends a new message.
+func (g *RepeatGuard) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.last, g.streak = "", 0
+}
+
+// observe records a call and returns how many identical calls in a row it
+// completes, itself included.
+func (g *RepeatGuard) observe(name string, args map[string]any) int {
+	// encoding/json sorts map keys, so equal arguments encode identically.
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		encoded = fmt.Appendf(nil, "%v", args)
+	}
+	key := name + "\x00" + string(encoded)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if key == g.last {
+		g.streak++
+	} else {
+		g.last, g.streak = key, 1
+	}
+	return g.streak
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRepeatGuard_IntervenesAfterIdenticalStreak(t *testing.T) {
+	registry := New()
+	runs := 0
+	registry.Register(BashToolDef(), func(context.Context, map[string]any) (string, error) {
+		runs++
+		return "exit status 1", nil
+	})
+	guarded := registry.WithMiddleware(NewRepeatGuard(2).Middleware())
+	ctx := context.Background()
+	failing := map[string]any{"command": "go test ./..."}
+
+	for i := 0; i < 2; i++ {
+		if out, _ := guarded.Dispatch(ctx, "bash", failing); out != "exit status 1" {
+			t.Fatalf("call %d = %q, want it executed", i+1, out)
+		}
+	}
+	out, err := guarded.Dispatch(ctx, "bash", map[string]any{"command": "go test ./..."})
+	if err != nil || !strings.Contains(out, "repeating yourself") {
+		t.Fatalf("third identical call = %q, %v; want intervention", out, err)
+	}
+	if runs != 2 {
+		t.Fatalf("handler ran %d times, want 2", runs)
+	}
+
+	// A different call resets the streak.
+	if out, _ := guarded.Dispatch(ctx, "bash", map[string]any{"command": "go vet ./..."}); out != "exit status 1" {
+		t.Fatalf("different call = %q, want it executed", out)
+	}
+	if out, _ := guarded.Dispatch(ctx, "bash", failing); out != "exit status 1" {
+		t.Fatalf("call after reset = %q, want it executed", out)
+	}
+}
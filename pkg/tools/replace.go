@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	maxReplaceFileBytes   = 1 << 20
+	maxReplacePreviewRows = 40
+)
+
+// ReplaceInFilesToolDef returns the definition for the replace_in_files tool.
+func ReplaceInFilesToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "replace_in_files",
+			Description: openai.String("Regex search-and-replace across project files (respecting .gitignore). " +
+				"Shows a preview of the affected lines and asks the user for approval before writing; " +
+				"reports the number of replacements per file. Use instead of sed one-liners."),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern":     map[string]any{"type": "string", "description": "RE2 regular expression to search for. Use (?m) for ^/$ per line."},
+					"replacement": map[string]any{"type": "string", "description": "Replacement text; $1 or ${name} insert capture groups."},
+					"glob":        map[string]any{"type": "string", "description": "Path glob selecting files, e.g. \"*.go\" or \"pkg/**/*.go\". Defaults to every file."},
+					"path":        map[string]any{"type": "string", "description": "Directory to search, relative to the workspace root. Defaults to the root."},
+					"dry_run":     map[string]any{"type": "boolean", "description": "Only show the preview and counts; change nothing."},
+				},
+				"required": []string{"pattern", "replacement"},
+			},
+		},
+	}
+}
+
+type fileReplacement struct {
+	rel     string
+	path    string
+	count   int
+	updated string
+	preview []string
+}
+
+// NewReplaceInFilesHandler creates the replace_in_files handler. Writes happen
+// only when approver allows them; a nil approver denies every write.
+func NewReplaceInFilesHandler(approver permission.Approver) Handler {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		pattern, ok := args["pattern"].(string)
+		if !ok || pattern == "" {
+			return "", fmt.Errorf("missing or invalid 'pattern' argument")
+		}
+		replacement, ok := args["replacement"].(string)
+		if !ok {
+			return "", fmt.Errorf("missing or invalid 'replacement' argument")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid 'pattern': %w", err)
+		}
+		glob, _ := args["glob"].(string)
+		dir := "."
+		if p, ok := args["path"].(string); ok && strings.TrimSpace(p) != "" {
+			dir = p
+		}
+		dryRun, _ := args["dry_run"].(bool)
+
+		safe, err := safePath(ctx, dir)
+		if err != nil {
+			return "", err
+		}
+		changes, err := planReplacements(ctx, safe, glob, re, replacement)
+		if err != nil {
+			return "", err
+		}
+		if len(changes) == 0 {
+			return "No matches.", nil
+		}
+
+		total := 0
+		for _, c := range changes {
+			total += c.count
+		}
+		preview := formatReplacePreview(changes)
+		if dryRun {
+			return fmt.Sprintf("Dry run: %d replacements in %d files.\n%s", total, len(changes), preview), nil
+		}
+
+		approved, err := approver.Approve(ctx, permission.Request{
+			Tool:    "replace_in_files",
+			Summary: fmt.Sprintf("replace /%s/ with %q: %d replacements in %d files", pattern, replacement, total, len(changes)),
+			Detail:  preview,
+		})
+		if err != nil {
+			return "", fmt.Errorf("approval failed: %w", err)
+		}
+		if !approved {
+			return "Replace denied: the user did not approve these changes. Nothing was written.", nil
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "Replaced %d occurrences in %d files:\n", total, len(changes))
+		for _, c := range changes {
+			if err := writeFileAtomic(c.path, []byte(c.updated)); err != nil {
+				fmt.Fprintf(&b, "%s: failed: %v\n", c.rel, err)
+				continue
+			}
+			fmt.Fprintf(&b, "%s: %d\n", c.rel, c.count)
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	}
+}
+
+// planReplacements computes the new content of every matching text file
+// under root without writing anything.
+func planReplacements(ctx context.Context, root, glob string, re *regexp.Regexp, replacement string) ([]fileReplacement, error) {
+	files, err := fileindex.List(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var changes []fileReplacement
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !fileindex.MatchGlob(glob, rel) {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxReplaceFileBytes {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data[:min(len(data), binarySniffBytes)]) {
+			continue
+		}
+
+		content := string(data)
+		count := len(re.FindAllStringIndex(content, -1))
+		if count == 0 {
+			continue
+		}
+		updated := re.ReplaceAllString(content, replacement)
+		if updated == content {
+			continue
+		}
+		changes = append(changes, fileReplacement{
+			rel:     rel,
+			path:    path,
+			count:   count,
+			updated: updated,
+			preview: previewLines(content, re, replacement),
+		})
+	}
+	return changes, nil
+}
+
+// previewLines shows each affected line before and after the replacement.
+// Matches that span lines cannot be shown per line and are summarized.
+func previewLines(content string, re *regexp.Regexp, replacement string) []string {
+	var rows []string
+	for i, line := range strings.Split(content, "\n") {
+		if !re.MatchString(line) {
+			continue
+		}
+		rows = append(rows,
+			fmt.Sprintf("  %d - %s", i+1, line),
+			fmt.Sprintf("  %d + %s", i+1, re.ReplaceAllString(line, replacement)))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "  (matches span multiple lines)")
+	}
+	return rows
+}
+
+func formatReplacePreview(changes []fileReplacement) string {
+	var b strings.Builder
+	rows, truncated := 0, false
+	for _, c := range changes {
+		fmt.Fprintf(&b, "%s (%d)\n", c.rel, c.count)
+		for _, row := range c.preview {
+			if rows >= maxReplacePreviewRows {
+				truncated = true
+				break
+			}
+			b.WriteString(row)
+			b.WriteByte('\n')
+			rows++
+		}
+	}
+	if truncated {
+		b.WriteString("  ... (preview truncated)\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+func writeReplaceFixtures(t *testing.T) {
+	t.Helper()
+	if err := os.MkdirAll("pkg", 0755); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := map[string]string{
+		"pkg/a.go":  "func oldName() {}\nvar x = oldName()\n",
+		"pkg/b.go":  "// oldName is documented here\n",
+		"notes.txt": "oldName in prose\n",
+	}
+	for path, content := range fixtures {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplaceInFilesHandler_AppliesApprovedChanges(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		writeReplaceFixtures(t)
+		var asked permission.Request
+		approver := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+			asked = req
+			return true, nil
+		})
+
+		result, err := NewReplaceInFilesHandler(approver)(context.Background(), map[string]any{
+			"pattern": `\boldName\b`, "replacement": "newName", "glob": "*.go",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(asked.Detail, "1 - func oldName() {}") || !strings.Contains(asked.Detail, "1 + func newName() {}") {
+			t.Fatalf("approval preview missing affected lines:\n%s", asked.Detail)
+		}
+		for _, want := range []string{"Replaced 3 occurrences in 2 files", "pkg/a.go: 2", "pkg/b.go: 1"} {
+			if !strings.Contains(result, want) {
+				t.Fatalf("result %q missing %q", result, want)
+			}
+		}
+		data, _ := os.ReadFile("pkg/a.go")
+		if string(data) != "func newName() {}\nvar x = newName()\n" {
+			t.Fatalf("pkg/a.go = %q", data)
+		}
+		if data, _ := os.ReadFile("notes.txt"); !strings.Contains(string(data), "oldName") {
+			t.Fatalf("notes.txt should not match the glob, got %q", data)
+		}
+	})
+}
+
+func TestReplaceInFilesHandler_DeniedOrDryRunWritesNothing(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		writeReplaceFixtures(t)
+		handler := NewReplaceInFilesHandler(nil)
+
+		result, err := handler(context.Background(), map[string]any{"pattern": "oldName", "replacement": "newName"})
+		if err != nil || !strings.Contains(result, "Replace denied") {
+			t.Fatalf("result = %q, %v; want denial", result, err)
+		}
+		result, err = handler(context.Background(), map[string]any{"pattern": "oldName", "replacement": "newName", "dry_run": true})
+		if err != nil || !strings.HasPrefix(result, "Dry run: 4 replacements in 3 files.") {
+			t.Fatalf("dry run result = %q, %v", result, err)
+		}
+		if data, _ := os.ReadFile("pkg/a.go"); strings.Contains(string(data), "newName") {
+			t.Fatalf("file was modified: %q", data)
+		}
+	})
+}
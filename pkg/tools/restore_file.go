@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// FileRestorer reverts a file (absolute path) to its last checkpoint and
+// describes what it did.
+type FileRestorer interface {
+	RestoreFile(path string) (string, error)
+}
+
+// RestoreFileToolDef returns the definition for the restore_file tool.
+func RestoreFileToolDef() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "restore_file",
+			Description: openai.String(
+				"Revert a file to its content before your most recent write_file/edit_file on it. Call again to step further back.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string"},
+				},
+				"required": []string{"path"},
+			},
+		},
+	}
+}
+
+// NewRestoreFileHandler creates a tool handler backed by a checkpoint store.
+func NewRestoreFileHandler(restorer FileRestorer) Handler {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if restorer == nil {
+			return "", fmt.Errorf("file checkpoints are not configured")
+		}
+		path, ok := args["path"].(string)
+		if !ok || strings.TrimSpace(path) == "" {
+			return "", fmt.Errorf("missing or invalid 'path' argument")
+		}
+		safe, err := safePath(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		return restorer.RestoreFile(safe)
+	}
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreFileHandler_ResolvesWorkspacePath(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		restorer := &recordingRestorer{}
+
+		result, err := NewRestoreFileHandler(restorer)(context.Background(), map[string]any{"path": "main.go"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !filepath.IsAbs(restorer.path) || filepath.Base(restorer.path) != "main.go" {
+			t.Fatalf("restorer got %q, want absolute workspace path", restorer.path)
+		}
+		if result != "restored" {
+			t.Fatalf("unexpected result %q", result)
+		}
+
+		_, err = NewRestoreFileHandler(restorer)(context.Background(), map[string]any{"path": "../x"})
+		if err == nil || !strings.Contains(err.Error(), "path escapes workspace") {
+			t.Fatalf("expected path escape error, got %v", err)
+		}
+	})
+}
+
+type recordingRestorer struct{ path string }
+
+func (r *recordingRestorer) RestoreFile(path string) (string, error) {
+	r.path = path
+	return "restored", nil
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+)
+
+// CacheInputs lists the files a call's result depends on.
+type CacheInputs func(ctx context.Context, args map[string]any) ([]string, error)
+
+// WorkspaceInputs is every file of the workspace (see fileindex.List), for
+// tools whose answer may come from anywhere, such as code_search or go_vet.
+func WorkspaceInputs(ctx context.Context, _ map[string]any) ([]string, error) {
+	root, err := workspaceRoot()
+	if err != nil {
+		return nil, err
+	}
+	files, err := fileindex.List(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	for i, rel := range files {
+		files[i] = filepath.Join(root, filepath.FromSlash(rel))
+	}
+	return files, nil
+}
+
+// DefaultCachedTools are the code-analysis tools whose results only depend
+// on the workspace files.
+var DefaultCachedTools = map[string]CacheInputs{
+	"code_search":      WorkspaceInputs,
+	"go_vet":           WorkspaceInputs,
+	"go_to_definition": WorkspaceInputs,
+	"find_references":  WorkspaceInputs,
+	"hover":            WorkspaceInputs,
+}
+
+// ResultCache remembers the results of analysis tools for a session, keyed
+// on the arguments and the content hash of the input files. Asking again
+// before any input changed returns the stored result at once, without
+// running the tool (or paying for the embeddings of code_search) again.
+// Failed calls are not cached.
+type ResultCache struct {
+	mu      sync.Mutex
+	results map[string]cachedResult
+	// hashes memoizes file hashes by path, size and modification time, so
+	// a check reads only the files that changed.
+	hashes map[string]fileHash
+}
+
+type cachedResult struct {
+	fingerprint string
+	output      string
+}
+
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NewResultCache returns an empty cache.
+func NewResultCache() *ResultCache {
+	return &ResultCache{results: make(map[string]cachedResult), hashes: make(map[string]fileHash)}
+}
+
+// Middleware caches the tools of cached, each with the inputs its results
+// depend on; nil means DefaultCachedTools. Other tools pass through.
+func (c *ResultCache) Middleware(cached map[string]CacheInputs) Middleware {
+	if cached == nil {
+		cached = DefaultCachedTools
+	}
+	return func(name string, next Handler) Handler {
+		inputs, ok := cached[name]
+		if !ok {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			// encoding/json sorts map keys, so equal arguments encode identically.
+			encoded, err := json.Marshal(args)
+			if err != nil {
+				return next(ctx, args)
+			}
+			key := name + "\x00" + string(encoded)
+			files, err := inputs(ctx, args)
+			if err != nil {
+				return next(ctx, args)
+			}
+			fingerprint, err := c.fingerprint(files)
+			if err != nil {
+				return next(ctx, args)
+			}
+
+			c.mu.Lock()
+			hit, ok := c.results[key]
+			c.mu.Unlock()
+			if ok && hit.fingerprint == fingerprint {
+				return hit.output, nil
+			}
+
+			output, err := next(ctx, args)
+			if err == nil && !strings.HasPrefix(output, "Error:") {
+				c.mu.Lock()
+				c.results[key] = cachedResult{fingerprint: fingerprint, output: output}
+				c.mu.Unlock()
+			}
+			return output, err
+		}
+	}
+}
+
+// fingerprint hashes the paths and contents of files. Missing files count
+// as absent rather than failing, since an input may be deleted.
+func (c *ResultCache) fingerprint(files []string) (string, error) {
+	h := sha256.New()
+	for _, path := range files {
+		sum, err := c.hashFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", path, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *ResultCache) hashFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "-", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	memo, ok := c.hashes[path]
+	c.mu.Unlock()
+	if ok && memo.size == info.Size() && memo.modTime.Equal(info.ModTime()) {
+		return memo.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	c.mu.Lock()
+	c.hashes[path] = fileHash{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	c.mu.Unlock()
+	return sum, nil
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// UT-RESULT-CACHE: 相同参数且输入文件内容未变时直接返回缓存结果，文件改动后重新执行。
+func TestResultCache_ReusesResultsUntilInputsChange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(file, []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inputs := func(context.Context, map[string]any) ([]string, error) { return []string{file}, nil }
+
+	runs := 0
+	cache := NewResultCache()
+	handler := cache.Middleware(map[string]CacheInputs{"go_vet": inputs})("go_vet", func(context.Context, map[string]any) (string, error) {
+		runs++
+		return "go vet: no issues", nil
+	})
+	call := func(args map[string]any) {
+		t.Helper()
+		if out, err := handler(context.Background(), args); err != nil || out != "go vet: no issues" {
+			t.Fatalf("call = %q, %v", out, err)
+		}
+	}
+
+	call(map[string]any{"packages": []any{"./..."}})
+	call(map[string]any{"packages": []any{"./..."}})
+	if runs != 1 {
+		t.Fatalf("runs = %d after a repeated call, want 1", runs)
+	}
+	call(map[string]any{"packages": []any{"./a"}})
+	if runs != 2 {
+		t.Fatalf("runs = %d after different arguments, want 2", runs)
+	}
+
+	// Same size, new content and modification time.
+	if err := os.WriteFile(file, []byte("package b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	call(map[string]any{"packages": []any{"./..."}})
+	if runs != 3 {
+		t.Fatalf("runs = %d after the input changed, want 3", runs)
+	}
+}
+
+func TestResultCache_SkipsFailuresAndOtherTools(t *testing.T) {
+	cache := NewResultCache()
+	runs := 0
+	failing := cache.Middleware(nil)("code_search", func(context.Context, map[string]any) (string, error) {
+		runs++
+		return "", errors.New("index unavailable")
+	})
+	uncached := cache.Middleware(nil)("bash", func(context.Context, map[string]any) (string, error) {
+		runs++
+		return "ok", nil
+	})
+	for range 2 {
+		_, _ = failing(context.Background(), map[string]any{"query": "dispatch"})
+		_, _ = uncached(context.Background(), map[string]any{"command": "date"})
+	}
+	if runs != 4 {
+		t.Fatalf("runs = %d, want every call executed", runs)
+	}
+}
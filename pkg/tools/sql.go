@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sqldb"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const maxSQLCellWidth = 80
+
+// SQLQueryToolDef returns the definition for the sql_query tool; connection
+// names come from the project config.
+func SQLQueryToolDef(connections []string) openai.ChatCompletionToolParam {
+	connection := map[string]any{"type": "string", "description": "Name of a configured database connection."}
+	if len(connections) > 0 {
+		connection["enum"] = connections
+	}
+
+	return openai.ChatCompletionToolParam{
+		Type: "function",
+		Function: shared.FunctionDefinitionParam{
+			Name: "sql_query",
+			Description: openai.String(
+				"Run a SQL statement against a configured database. Read queries run directly; statements that modify data require user approval.",
+			),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"connection": connection,
+					"query":      map[string]any{"type": "string", "description": "A single SQL statement."},
+					"max_rows":   map[string]any{"type": "integer", "description": "Maximum rows to return (default 100)."},
+				},
+				"required": []string{"connection", "query"},
+			},
+		},
+	}
+}
+
+// NewSQLQueryHandler creates a tool handler that queries the registry's
+// connections. Non read-only statements are executed only when approver
+// allows them; a nil approver denies every write.
+func NewSQLQueryHandler(reg *sqldb.Registry, approver permission.Approver) Handler {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		if reg == nil {
+			return "", fmt.Errorf("no databases are configured")
+		}
+
+		name, ok := args["connection"].(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			return "", fmt.Errorf("missing or invalid 'connection' argument")
+		}
+		query, ok := args["query"].(string)
+		if !ok || strings.TrimSpace(query) == "" {
+			return "", fmt.Errorf("missing or invalid 'query' argument")
+		}
+		maxRows := 0
+		if raw, exists := args["max_rows"]; exists {
+			value, err := intArg(raw)
+			if err != nil {
+				return "", fmt.Errorf("invalid 'max_rows': %w", err)
+			}
+			maxRows = value
+		}
+
+		if sqldb.IsReadOnly(query) {
+			result, err := reg.Query(ctx, name, query, maxRows)
+			if err != nil {
+				return "", err
+			}
+			return formatSQLResult(result), nil
+		}
+
+		approved, err := approver.Approve(ctx, permission.Request{
+			Tool:    "sql_query",
+			Summary: fmt.Sprintf("modify database %q", name),
+			Detail:  query,
+			Command: query,
+		})
+		if err != nil {
+			return "", fmt.Errorf("approval failed: %w", err)
+		}
+		if !approved {
+			return "Write denied: the statement modifies data and was not approved. Use a read-only query instead.", nil
+		}
+
+		result, err := reg.Exec(ctx, name, query)
+		if err != nil {
+			return "", err
+		}
+		return formatSQLResult(result), nil
+	}
+}
+
+// RegisterDatabases registers sql_query on r for the connections declared
+// in databases and returns their registry, which the caller closes. No
+// databases registers nothing and returns a nil registry. The drivers are
+// the application's: a connection whose driver is not linked in fails on
+// its first query.
+func RegisterDatabases(r *Registry, databases map[string]config.Database, approver permission.Approver) (*sqldb.Registry, error) {
+	if len(databases) == 0 {
+		return nil, nil
+	}
+	reg, err := sqldb.NewRegistry(databases)
+	if err != nil {
+		return nil, err
+	}
+	r.Register(SQLQueryToolDef(reg.Names()), NewSQLQueryHandler(reg, approver))
+	return reg, nil
+}
+
+func formatSQLResult(result sqldb.Result) string {
+	if result.IsWrite {
+		if result.RowsAffected < 0 {
+			return "OK"
+		}
+		return fmt.Sprintf("OK, %d row(s) affected", result.RowsAffected)
+	}
+	if len(result.Columns) == 0 {
+		return "OK"
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(result.Columns, " | "))
+	b.WriteByte('\n')
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = truncateSQLCell(cell)
+		}
+		b.WriteString(strings.Join(cells, " | "))
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "(%d row(s)", len(result.Rows))
+	if result.Truncated {
+		b.WriteString(", truncated")
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func truncateSQLCell(cell string) string {
+	cell = strings.ReplaceAll(cell, "\n", `\n`)
+	if len([]rune(cell)) <= maxSQLCellWidth {
+		return cell
+	}
+	return string([]rune(cell)[:maxSQLCellWidth-3]) + "..."
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/sqldb"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLQueryHandler_ReadQueryReturnsTable(t *testing.T) {
+	handler := NewSQLQueryHandler(newSQLTestRegistry(t), nil)
+
+	result, err := handler(context.Background(), map[string]any{"connection": "app", "query": "SELECT id, name FROM users"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"id | name", "1 | alice", "(1 row(s))"} {
+		if !strings.Contains(result, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestSQLQueryHandler_WriteRequiresApproval(t *testing.T) {
+	var requests []permission.Request
+	deny := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+		requests = append(requests, req)
+		return false, nil
+	})
+	handler := NewSQLQueryHandler(newSQLTestRegistry(t), deny)
+
+	result, err := handler(context.Background(), map[string]any{"connection": "app", "query": "DELETE FROM users"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "Write denied") {
+		t.Fatalf("expected denial, got %q", result)
+	}
+	if len(requests) != 1 || requests[0].Detail != "DELETE FROM users" {
+		t.Fatalf("unexpected approval requests: %+v", requests)
+	}
+}
+
+func TestSQLQueryHandler_ApprovedWriteExecutes(t *testing.T) {
+	handler := NewSQLQueryHandler(newSQLTestRegistry(t), permission.AllowAll)
+
+	result, err := handler(context.Background(), map[string]any{"connection": "app", "query": "UPDATE users SET name = 'x'"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "OK, 1 row(s) affected" {
+		t.Fatalf("unexpected result: %q", result)
+	}
+}
+
+func TestSQLQueryToolDef_ListsConnections(t *testing.T) {
+	def := SQLQueryToolDef([]string{"app", "analytics"})
+	props := def.Function.Parameters["properties"].(map[string]any)
+	connection := props["connection"].(map[string]any)
+	if enum, ok := connection["enum"].([]string); !ok || len(enum) != 2 {
+		t.Fatalf("expected connection enum, got %#v", connection["enum"])
+	}
+}
+
+func TestRegisterDatabases_QueriesSQLite(t *testing.T) {
+	t.Setenv("APP_DB", filepath.Join(t.TempDir(), "app.db"))
+	approveWrites := true
+	var approvals []string
+	approver := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+		approvals = append(approvals, req.Command)
+		return approveWrites, nil
+	})
+	r := New()
+	databases, err := RegisterDatabases(r, map[string]config.Database{"app": {Driver: "sqlite", DSN: "file:$APP_DB"}}, approver)
+	if err != nil {
+		t.Fatalf("RegisterDatabases: %v", err)
+	}
+	t.Cleanup(func() { _ = databases.Close() })
+
+	run := func(query string) string {
+		t.Helper()
+		result, err := r.Dispatch(context.Background(), "sql_query", map[string]any{"connection": "app", "query": query})
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return result
+	}
+	run("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	if got := run("INSERT INTO users (name) VALUES ('alice'), ('bob')"); got != "OK, 2 row(s) affected" {
+		t.Fatalf("insert = %q", got)
+	}
+	approveWrites = false
+	if got := run("DELETE FROM users"); !strings.Contains(got, "Write denied") {
+		t.Fatalf("delete = %q, want it denied", got)
+	}
+	if got := run("SELECT id, name FROM users ORDER BY id"); got != "id | name\n1 | alice\n2 | bob\n(2 row(s))" {
+		t.Fatalf("select = %q", got)
+	}
+	if len(approvals) != 3 {
+		t.Fatalf("approvals = %q, want one per write", approvals)
+	}
+}
+
+func TestRegisterDatabases_NoneRegistersNothing(t *testing.T) {
+	r := New()
+	databases, err := RegisterDatabases(r, nil, nil)
+	if err != nil || databases != nil || r.Has("sql_query") {
+		t.Fatalf("RegisterDatabases = %v, %v, registered %t", databases, err, r.Has("sql_query"))
+	}
+}
+
+func newSQLTestRegistry(t *testing.T) *sqldb.Registry {
+	t.Helper()
+
+	reg, err := sqldb.NewRegistry(map[string]config.Database{"app": {Driver: "toolsfake", DSN: "memory"}})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { _ = reg.Close() })
+	return reg
+}
+
+func init() {
+	sql.Register("toolsfake", sqlFakeDriver{})
+}
+
+// sqlFakeDriver answers every query with one (id, name) row and every exec with one affected row.
+type sqlFakeDriver struct{}
+
+func (sqlFakeDriver) Open(string) (driver.Conn, error) { return sqlFakeConn{}, nil }
+
+type sqlFakeConn struct{}
+
+func (sqlFakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (sqlFakeConn) Close() error                        { return nil }
+func (sqlFakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (sqlFakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (sqlFakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &sqlFakeRows{}, nil
+}
+
+type sqlFakeRows struct{ done bool }
+
+func (r *sqlFakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *sqlFakeRows) Close() error      { return nil }
+func (r *sqlFakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = int64(1), "alice"
+	return nil
+}
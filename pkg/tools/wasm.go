@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Workspace access of a WebAssembly plugin.
+const (
+	FilesystemNone  = "none"
+	FilesystemRead  = "read"
+	FilesystemWrite = "write"
+)
+
+// wasmCache keeps compiled modules, so a plugin is compiled once per
+// process rather than on every call.
+var wasmCache = wazero.NewCompilationCache()
+
+func wasmRuntimeConfig() wazero.RuntimeConfig {
+	return wazero.NewRuntimeConfig().
+		WithCompilationCache(wasmCache).
+		WithCloseOnContextDone(true)
+}
+
+// compileWASM compiles the module at path into wasmCache.
+func compileWASM(ctx context.Context, path string) error {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, wasmRuntimeConfig())
+	defer rt.Close(context.WithoutCancel(ctx))
+	if _, err := rt.CompileModule(ctx, wasm); err != nil {
+		return fmt.Errorf("compile %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func isWASM(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".wasm")
+}
+
+func checkFilesystem(p Plugin) error {
+	switch p.Filesystem {
+	case "", FilesystemNone, FilesystemRead, FilesystemWrite:
+	default:
+		return fmt.Errorf("invalid filesystem %q: use %s, %s or %s", p.Filesystem, FilesystemNone, FilesystemRead, FilesystemWrite)
+	}
+	if p.Filesystem != "" && p.Filesystem != FilesystemNone && !isWASM(p.Path) {
+		return fmt.Errorf("filesystem only applies to .wasm plugins; native plugins have full access")
+	}
+	return nil
+}
+
+// wasmExitError reports a WebAssembly plugin that exited with a non-zero
+// status, like exec.ExitError does for a process.
+type wasmExitError struct {
+	code int
+}
+
+func (e *wasmExitError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// ExitCode returns the status the module passed to proc_exit.
+func (e *wasmExitError) ExitCode() int { return e.code }
+
+// runPlugin runs p with args, wiring up stdin, stdout and stderr. A native
+// plugin runs as a process in workdir. A WebAssembly plugin runs in-process
+// in a WASI sandbox: it gets workdir mounted as / according to its
+// Filesystem, which the caller has had approved (an empty workdir mounts
+// nothing), no environment
+// variables, and no way to start processes or open sockets. A non-zero
+// exit status is returned as an error with an ExitCode method.
+func runPlugin(ctx context.Context, p Plugin, workdir string, stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	if !isWASM(p.Path) {
+		cmd := exec.CommandContext(ctx, p.Path, args...)
+		cmd.Dir = workdir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+		return cmd.Run()
+	}
+
+	wasm, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, wasmRuntimeConfig())
+	defer rt.Close(context.WithoutCancel(ctx))
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		return fmt.Errorf("compile %s: %w", filepath.Base(p.Path), err)
+	}
+	fsConfig := wazero.NewFSConfig()
+	if workdir != "" {
+		switch p.Filesystem {
+		case FilesystemRead:
+			fsConfig = fsConfig.WithReadOnlyDirMount(workdir, "/")
+		case FilesystemWrite:
+			fsConfig = fsConfig.WithDirMount(workdir, "/")
+		}
+	}
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{filepath.Base(p.Path)}, args...)...).
+		WithStdin(stdin).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+
+	_, err = rt.InstantiateModule(ctx, compiled, config)
+	var exit *sys.ExitError
+	if errors.As(err, &exit) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &wasmExitError{code: int(exit.ExitCode())}
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+// wasmPluginSource is a WASI plugin that asks for write access and, when
+// called, reports what it can see of the workspace and the environment.
+const wasmPluginSource = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--describe" {
+		fmt.Print("{\"name\":\"count_lines\",\"filesystem\":\"write\"}")
+		return
+	}
+	_, readErr := os.ReadFile("/marker.txt")
+	writeErr := os.WriteFile("/written.txt", []byte("x"), 0o644)
+	fmt.Printf("read=%t write=%t secret=%q", readErr == nil, writeErr == nil, os.Getenv("AGENT_SECRET"))
+	if len(os.Args) > 1 {
+		os.Exit(3)
+	}
+}
+`
+
+var (
+	wasmPluginOnce sync.Once
+	wasmPlugin     []byte
+	wasmPluginErr  error
+)
+
+// buildWASMPlugin compiles wasmPluginSource for wasip1 once per test run.
+func buildWASMPlugin(t *testing.T) []byte {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+	wasmPluginOnce.Do(func() {
+		dir := t.TempDir()
+		files := map[string]string{"go.mod": "module example.com/countlines\n\ngo 1.21\n", "main.go": wasmPluginSource}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				wasmPluginErr = err
+				return
+			}
+		}
+		cmd := exec.Command(goBin, "build", "-o", "plugin.wasm", ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "CGO_ENABLED=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			wasmPluginErr = errors.New(string(out))
+			return
+		}
+		wasmPlugin, wasmPluginErr = os.ReadFile(filepath.Join(dir, "plugin.wasm"))
+	})
+	if wasmPluginErr != nil {
+		t.Fatalf("build wasm plugin: %v", wasmPluginErr)
+	}
+	return wasmPlugin
+}
+
+func TestPluginHandler_WASMMountsWorkspaceOnceApproved(t *testing.T) {
+	module := buildWASMPlugin(t)
+	t.Setenv("AGENT_SECRET", "sk-secret")
+	dir := t.TempDir()
+	// Modules need not be executable.
+	if err := os.WriteFile(filepath.Join(dir, "count_lines.wasm"), module, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plugins, err := LoadPlugins(context.Background(), dir)
+	if err != nil || len(plugins) != 1 || plugins[0].Filesystem != FilesystemWrite {
+		t.Fatalf("LoadPlugins = %v, %v", plugins, err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		filesystem string
+		approve    bool
+		want       string
+	}{
+		{"none", FilesystemNone, false, `read=false write=false secret=""`},
+		{"read", FilesystemRead, true, `read=true write=false secret=""`},
+		{"write", FilesystemWrite, true, `read=true write=true secret=""`},
+		{"write declined", FilesystemWrite, false, "Error: the user declined to run count_lines"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			if err := os.WriteFile(filepath.Join(workspace, "marker.txt"), []byte("x"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Chdir(workspace)
+
+			var requests []string
+			approver := permission.ApproverFunc(func(_ context.Context, req permission.Request) (bool, error) {
+				requests = append(requests, req.Summary)
+				return tc.approve, nil
+			})
+			p := plugins[0]
+			p.Filesystem = tc.filesystem
+			result, err := NewPluginHandler(p, approver)(context.Background(), map[string]any{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.want {
+				t.Fatalf("result = %q, want %q", result, tc.want)
+			}
+			if want := "run plugin count_lines.wasm with " + tc.filesystem + " access to the workspace"; tc.filesystem != FilesystemNone && (len(requests) != 1 || requests[0] != want) {
+				t.Fatalf("requests = %q, want %q", requests, want)
+			}
+			if tc.filesystem == FilesystemNone && len(requests) != 0 {
+				t.Fatalf("asked to run without access: %q", requests)
+			}
+			_, statErr := os.Stat(filepath.Join(workspace, "written.txt"))
+			if written := statErr == nil; written != (tc.filesystem == FilesystemWrite && tc.approve) {
+				t.Fatalf("workspace written = %t", written)
+			}
+		})
+	}
+}
+
+func TestRunPlugin_WASMReportsExitStatus(t *testing.T) {
+	module := buildWASMPlugin(t)
+	path := filepath.Join(t.TempDir(), "count_lines.wasm")
+	if err := os.WriteFile(path, module, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, status := WithExitStatus(context.Background())
+	err := runPlugin(ctx, Plugin{Path: path}, "", nil, nil, nil, "fail")
+	var exit exitCoder
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Fatalf("err = %v, want exit status 3", err)
+	}
+	reportExitStatus(ctx, err)
+	if code, ok := status(); !ok || code != 3 {
+		t.Fatalf("status = %d, %t", code, ok)
+	}
+}
+
+func TestRunPlugin_WASMRejectsInvalidModules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.wasm")
+	if err := os.WriteFile(path, []byte("\x00asm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runPlugin(context.Background(), Plugin{Path: path}, "", nil, nil, nil); err == nil || !strings.Contains(err.Error(), "compile broken.wasm") {
+		t.Fatalf("err = %v, want a compile error", err)
+	}
+}
+
+func TestLoadPlugins_FilesystemOnlyForWASM(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "native", `echo '{"name":"native","filesystem":"read"}'`+"\n")
+
+	if _, err := LoadPlugins(context.Background(), dir); err == nil || !strings.Contains(err.Error(), "only applies to .wasm") {
+		t.Fatalf("expected a filesystem error, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type workDirKey struct{}
+
+// cwdMarker ends the output of bash commands run with a WorkDir, followed by
+// the directory the command finished in.
+const cwdMarker = "__AGENT_CWD__="
+
+// WorkDir is the agent's logical working directory. bash commands start in
+// it and a cd in one command carries over to the next; the file tools
+// resolve relative paths against it.
+type WorkDir struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewWorkDir returns a WorkDir starting at dir.
+func NewWorkDir(dir string) *WorkDir {
+	return &WorkDir{dir: filepath.Clean(dir)}
+}
+
+// Dir returns the current directory.
+func (w *WorkDir) Dir() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dir
+}
+
+// Set moves to dir.
+func (w *WorkDir) Set(dir string) {
+	w.mu.Lock()
+	w.dir = filepath.Clean(dir)
+	w.mu.Unlock()
+}
+
+// WithWorkDir returns a context in which the tools track w. Without one,
+// bash runs in the process working directory and relative paths resolve
+// against the workspace root.
+func WithWorkDir(ctx context.Context, w *WorkDir) context.Context {
+	return context.WithValue(ctx, workDirKey{}, w)
+}
+
+func workDirFrom(ctx context.Context) *WorkDir {
+	w, _ := ctx.Value(workDirKey{}).(*WorkDir)
+	return w
+}
+
+// trackCwd makes a POSIX command report the directory it ends in, keeping
+// its exit status.
+func trackCwd(command string) string {
+	return fmt.Sprintf("{ %s\n}; __agent_status=$?; printf '\\n%s%%s\\n' \"$PWD\"; exit $__agent_status", command, cwdMarker)
+}
+
+// followCwd strips the marker trackCwd added from out and moves w to the
+// reported directory when it exists inside the workspace; directories of
+// other machines, e.g. a Docker container, are ignored. It returns the
+// output and a note when the directory changed.
+func followCwd(ctx context.Context, w *WorkDir, out string) (string, string) {
+	i := strings.LastIndex(out, cwdMarker)
+	if i < 0 {
+		return out, ""
+	}
+	dir := strings.TrimSpace(out[i+len(cwdMarker):])
+	out = strings.TrimSuffix(out[:i], "\n")
+	if dir == "" || filepath.Clean(dir) == w.Dir() {
+		return out, ""
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return out, ""
+	}
+	if _, err := safePath(ctx, dir); err != nil {
+		return out, ""
+	}
+	w.Set(dir)
+	return out, fmt.Sprintf("(working directory is now %s)", displayPath(dir))
+}
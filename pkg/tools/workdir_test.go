@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UT-WORKDIR: bash 中的 cd 跨调用生效，文件工具按当前目录解析相对路径。
+func TestWorkDir_FollowsCdAcrossToolCalls(t *testing.T) {
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(workspace, "pkg", "loop")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "a.txt"), []byte("in loop"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	withWorkingDir(t, workspace, func() {
+		workDir := NewWorkDir(workspace)
+		ctx := WithWorkDir(context.Background(), workDir)
+
+		out, err := BashHandler(ctx, map[string]any{"command": "cd pkg/loop && echo moved"})
+		if err != nil {
+			t.Fatalf("bash: %v", err)
+		}
+		if out != "moved\n(working directory is now pkg/loop)" {
+			t.Fatalf("output = %q", out)
+		}
+		if workDir.Dir() != sub {
+			t.Fatalf("work dir = %q, want %q", workDir.Dir(), sub)
+		}
+
+		if out, _ := BashHandler(ctx, map[string]any{"command": "pwd"}); out != sub {
+			t.Fatalf("pwd = %q, want %q", out, sub)
+		}
+		if out, err := ReadFileHandler(ctx, map[string]any{"path": "a.txt"}); err != nil || !strings.Contains(out, "in loop") {
+			t.Fatalf("relative read = %q, %v", out, err)
+		}
+
+		// A failing command keeps its exit status; leaving the workspace is ignored.
+		ctx, exitStatus := WithExitStatus(ctx)
+		if _, err := BashHandler(ctx, map[string]any{"command": "cd / && exit 4"}); err != nil {
+			t.Fatalf("bash: %v", err)
+		}
+		if code, _ := exitStatus(); code != 4 {
+			t.Fatalf("exit status = %d, want 4", code)
+		}
+		if workDir.Dir() != sub {
+			t.Fatalf("work dir left the workspace: %q", workDir.Dir())
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+	"github.com/nickdu2009/learn-claude-code/pkg/textdiff"
+)
+
+// WriteGate asks the user to review every file write (write_file, edit_file,
+// multi_edit) as a diff before it is applied. The reviewer may approve it,
+// deny it, approve all further changes to that file ("always") or edit the
+// proposed content; an edited change is written as the user left it and the
+// model is told what was changed.
+type WriteGate struct {
+	approver permission.Approver
+
+	mu     sync.Mutex
+	always map[string]bool
+}
+
+// NewWriteGate creates a gate; a nil approver denies every write.
+func NewWriteGate(approver permission.Approver) *WriteGate {
+	if approver == nil {
+		approver = permission.DenyAll
+	}
+	return &WriteGate{approver: approver, always: make(map[string]bool)}
+}
+
+// Middleware gates the file-writing tools; other tools pass through.
+func (g *WriteGate) Middleware() Middleware {
+	return func(name string, next Handler) Handler {
+		if name != "write_file" && name != "edit_file" && name != "multi_edit" {
+			return next
+		}
+		return func(ctx context.Context, args map[string]any) (string, error) {
+			path, before, proposed, ok := proposeWrite(ctx, name, args)
+			// Invalid arguments or edits that do not apply: let the tool
+			// report the error. Writes that change nothing need no review.
+			if !ok || before == proposed {
+				return next(ctx, args)
+			}
+			g.mu.Lock()
+			always := g.always[path]
+			g.mu.Unlock()
+			if always {
+				return next(ctx, args)
+			}
+
+			rel := displayPath(path)
+			summary := fmt.Sprintf("modify %s", rel)
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				summary = fmt.Sprintf("create %s", rel)
+			}
+			added, deleted := textdiff.Stats(before, proposed)
+			verdict, err := permission.Review(ctx, g.approver, permission.Request{
+				Tool:    name,
+				Summary: fmt.Sprintf("%s (+%d -%d)", summary, added, deleted),
+				Change: &permission.FileChange{
+					Path:     rel,
+					Diff:     textdiff.Unified("a/"+rel, "b/"+rel, before, proposed, textdiff.DefaultContext),
+					Proposed: proposed,
+				},
+			})
+			if err != nil {
+				return "", fmt.Errorf("approval failed: %w", err)
+			}
+			if !verdict.Approved {
+				return fmt.Sprintf("Write denied: the user did not approve this change to %s. Nothing was written.", rel), nil
+			}
+			if verdict.Always {
+				g.mu.Lock()
+				g.always[path] = true
+				g.mu.Unlock()
+			}
+			if verdict.Edited == nil || *verdict.Edited == proposed {
+				return next(ctx, args)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return "", fmt.Errorf("failed to create parent directories: %w", err)
+			}
+			if err := writeFileAtomic(path, []byte(*verdict.Edited)); err != nil {
+				return "", fmt.Errorf("failed to write file: %w", err)
+			}
+			return fmt.Sprintf("The user edited your change before applying it; %s now has the user's version. "+
+				"Differences from what you proposed:\n%s", rel,
+				textdiff.Unified("proposed/"+rel, "applied/"+rel, proposed, *verdict.Edited, textdiff.DefaultContext)), nil
+		}
+	}
+}
+
+// proposeWrite computes the content a write tool would leave in its file.
+func proposeWrite(ctx context.Context, name string, args map[string]any) (path, before, proposed string, ok bool) {
+	raw, isString := args["path"].(string)
+	if !isString {
+		return "", "", "", false
+	}
+	path, err := safePath(ctx, raw)
+	if err != nil {
+		return "", "", "", false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !(name == "write_file" && errors.Is(err, fs.ErrNotExist)) {
+		return "", "", "", false
+	}
+	before = string(content)
+
+	switch name {
+	case "write_file":
+		proposed, ok = args["content"].(string)
+	case "edit_file":
+		oldText, okOld := args["old_text"].(string)
+		newText, okNew := args["new_text"].(string)
+		if okOld && okNew && strings.Contains(before, oldText) {
+			proposed, ok = strings.Replace(before, oldText, newText, 1), true
+		}
+	case "multi_edit":
+		edits, err := parseTextEdits(args["edits"])
+		if err == nil {
+			proposed, _, err = applyTextEdits(before, edits, path)
+			ok = err == nil
+		}
+	}
+	return path, before, proposed, ok
+}
+
+// displayPath shows path relative to the workspace when possible.
+func displayPath(path string) string {
+	if root, err := workspaceRoot(); err == nil {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
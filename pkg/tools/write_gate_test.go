@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/permission"
+)
+
+// scriptedReviewer answers reviews with the given verdicts in order.
+type scriptedReviewer struct {
+	verdicts []permission.Verdict
+	requests []permission.Request
+}
+
+func (r *scriptedReviewer) Approve(ctx context.Context, req permission.Request) (bool, error) {
+	v, err := r.Review(ctx, req)
+	return v.Approved, err
+}
+
+func (r *scriptedReviewer) Review(_ context.Context, req permission.Request) (permission.Verdict, error) {
+	r.requests = append(r.requests, req)
+	v := r.verdicts[0]
+	r.verdicts = r.verdicts[1:]
+	return v, nil
+}
+
+func gatedRegistry(reviewer permission.Approver) *Registry {
+	r := New()
+	r.Register(WriteFileToolDef(), WriteFileHandler)
+	r.Register(EditFileToolDef(), EditFileHandler)
+	r.Register(MultiEditToolDef(), MultiEditHandler)
+	return r.WithMiddleware(NewWriteGate(reviewer).Middleware())
+}
+
+func TestWriteGate_ShowsDiffAndHonorsVerdicts(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if err := os.WriteFile("a.txt", []byte("one\ntwo\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		reviewer := &scriptedReviewer{verdicts: []permission.Verdict{{}, {Approved: true, Always: true}}}
+		registry := gatedRegistry(reviewer)
+		ctx := context.Background()
+		edit := map[string]any{"path": "a.txt", "old_text": "two", "new_text": "TWO"}
+
+		out, err := registry.Dispatch(ctx, "edit_file", edit)
+		if err != nil || !strings.Contains(out, "Write denied") {
+			t.Fatalf("denied edit = %q, %v", out, err)
+		}
+		if data, _ := os.ReadFile("a.txt"); string(data) != "one\ntwo\n" {
+			t.Fatalf("denied edit changed the file: %q", data)
+		}
+		req := reviewer.requests[0]
+		if req.Summary != "modify a.txt (+1 -1)" || !strings.Contains(req.Change.Diff, "-two\n+TWO\n") {
+			t.Fatalf("unexpected request: %+v", req)
+		}
+
+		if _, err := registry.Dispatch(ctx, "edit_file", edit); err != nil {
+			t.Fatalf("approved edit: %v", err)
+		}
+		// "Always" covers later writes to the same file without asking.
+		if _, err := registry.Dispatch(ctx, "write_file", map[string]any{"path": "a.txt", "content": "replaced\n"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(reviewer.requests) != 2 {
+			t.Fatalf("reviewed %d times, want 2", len(reviewer.requests))
+		}
+		if data, _ := os.ReadFile("a.txt"); string(data) != "replaced\n" {
+			t.Fatalf("a.txt = %q", data)
+		}
+	})
+}
+
+func TestWriteGate_WritesUserEditedContent(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		edited := "package main\n\nfunc main() {}\n"
+		reviewer := &scriptedReviewer{verdicts: []permission.Verdict{{Approved: true, Edited: &edited}}}
+		registry := gatedRegistry(reviewer)
+
+		out, err := registry.Dispatch(context.Background(), "write_file",
+			map[string]any{"path": "cmd/main.go", "content": "package main\n"})
+		if err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join("cmd", "main.go")); string(data) != edited {
+			t.Fatalf("main.go = %q, want the user's version", data)
+		}
+		if !strings.Contains(out, "The user edited your change") || !strings.Contains(out, "+func main() {}") {
+			t.Fatalf("model was not told about the edit: %q", out)
+		}
+		if req := reviewer.requests[0]; req.Summary != "create cmd/main.go (+1 -0)" || req.Change.Proposed != "package main\n" {
+			t.Fatalf("unexpected request: %+v", req)
+		}
+	})
+}
+
+func TestWriteGate_PassesThroughInvalidAndNoopWrites(t *testing.T) {
+	withWorkingDir(t, t.TempDir(), func() {
+		if err := os.WriteFile("a.txt", []byte("same\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		reviewer := &scriptedReviewer{}
+		registry := gatedRegistry(reviewer)
+		ctx := context.Background()
+
+		if _, err := registry.Dispatch(ctx, "edit_file", map[string]any{"path": "a.txt", "old_text": "missing", "new_text": "x"}); err == nil {
+			t.Fatal("expected the tool's own not-found error")
+		}
+		if _, err := registry.Dispatch(ctx, "write_file", map[string]any{"path": "a.txt", "content": "same\n"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(reviewer.requests) != 0 {
+			t.Fatalf("reviewed %d times, want 0", len(reviewer.requests))
+		}
+	})
+}
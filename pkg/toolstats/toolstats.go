@@ -0,0 +1,234 @@
+// Package toolstats aggregates the audit logs of every session (see package
+// audit) into per-tool usage: how often the model calls each tool, how
+// often the call fails, how much output it returns and how long it takes.
+// It shows which tools the model leans on and which keep failing.
+//
+//	report, err := toolstats.Load(filepath.Join(root, audit.DefaultDir), since)
+//	report.WriteText(os.Stdout)
+package toolstats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+)
+
+const (
+	// FailingMinCalls and FailingRate decide when a tool counts as
+	// consistently failing: at least that many calls, at least that share
+	// of them failed.
+	FailingMinCalls = 5
+	FailingRate     = 0.5
+
+	maxErrorChars = 120
+)
+
+// Tool is the usage of one tool.
+type Tool struct {
+	Name     string `json:"name"`
+	Calls    int    `json:"calls"`
+	Sessions int    `json:"sessions"`
+	// Failed counts calls whose handler returned an error or whose command
+	// exited non-zero.
+	Failed       int     `json:"failed"`
+	FailureRate  float64 `json:"failure_rate"`
+	AvgOutput    int     `json:"avg_output_bytes"`
+	AvgMS        int64   `json:"avg_ms"`
+	P95MS        int64   `json:"p95_ms"`
+	MaxMS        int64   `json:"max_ms"`
+	LastError    string  `json:"last_error,omitempty"`
+	LastFailedAt string  `json:"last_failed_at,omitempty"`
+}
+
+// Failing reports whether the tool fails consistently.
+func (t Tool) Failing() bool {
+	return t.Calls >= FailingMinCalls && t.FailureRate >= FailingRate
+}
+
+// Report is the usage of every tool, most called first.
+type Report struct {
+	Sessions int       `json:"sessions"`
+	Calls    int       `json:"calls"`
+	From     time.Time `json:"from,omitzero"`
+	To       time.Time `json:"to,omitzero"`
+	Tools    []Tool    `json:"tools"`
+}
+
+// Load aggregates the audit logs in dir, skipping records older than
+// since (zero for all). A missing dir is an empty report. Logs that cannot
+// be read, e.g. encrypted with another AGENT_STORAGE_KEY, are reported in
+// the error; the report still covers the others.
+func Load(dir string, since time.Time) (Report, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return Report{}, err
+	}
+	var (
+		records []audit.Record
+		errs    []error
+	)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// A torn last line still yields the records before it.
+		recs, err := audit.ReadRecords(f)
+		f.Close()
+		if err != nil && len(recs) == 0 {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		for _, rec := range recs {
+			if since.IsZero() || !rec.Time.Before(since) {
+				records = append(records, rec)
+			}
+		}
+	}
+	return Aggregate(records), errors.Join(errs...)
+}
+
+// Aggregate computes the report of records.
+func Aggregate(records []audit.Record) Report {
+	type acc struct {
+		tool      Tool
+		sessions  map[string]bool
+		output    int
+		durations []int64
+		lastFail  time.Time
+	}
+	byName := map[string]*acc{}
+	sessions := map[string]bool{}
+	report := Report{Tools: []Tool{}}
+	for _, rec := range records {
+		a := byName[rec.Tool]
+		if a == nil {
+			a = &acc{tool: Tool{Name: rec.Tool}, sessions: map[string]bool{}}
+			byName[rec.Tool] = a
+		}
+		a.tool.Calls++
+		a.sessions[rec.Session] = true
+		a.output += rec.OutputSize
+		a.durations = append(a.durations, rec.DurationMS)
+		if failed(rec) {
+			a.tool.Failed++
+			if !rec.Time.Before(a.lastFail) {
+				a.lastFail = rec.Time
+				a.tool.LastError = failure(rec)
+			}
+		}
+
+		sessions[rec.Session] = true
+		report.Calls++
+		if report.From.IsZero() || rec.Time.Before(report.From) {
+			report.From = rec.Time
+		}
+		if rec.Time.After(report.To) {
+			report.To = rec.Time
+		}
+	}
+	report.Sessions = len(sessions)
+
+	for _, a := range byName {
+		t := a.tool
+		t.Sessions = len(a.sessions)
+		t.FailureRate = float64(t.Failed) / float64(t.Calls)
+		t.AvgOutput = a.output / t.Calls
+		slices.Sort(a.durations)
+		var total int64
+		for _, ms := range a.durations {
+			total += ms
+		}
+		t.AvgMS = total / int64(t.Calls)
+		t.P95MS = a.durations[(len(a.durations)*95+99)/100-1]
+		t.MaxMS = a.durations[len(a.durations)-1]
+		if !a.lastFail.IsZero() {
+			t.LastFailedAt = a.lastFail.UTC().Format(time.RFC3339)
+		}
+		report.Tools = append(report.Tools, t)
+	}
+	slices.SortFunc(report.Tools, func(a, b Tool) int {
+		if a.Calls != b.Calls {
+			return b.Calls - a.Calls
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return report
+}
+
+func failed(rec audit.Record) bool {
+	return rec.Status == "error" || (rec.ExitCode != nil && *rec.ExitCode != 0)
+}
+
+// failure is the first line of the error of a failed call.
+func failure(rec audit.Record) string {
+	msg := rec.Error
+	if msg == "" && rec.ExitCode != nil {
+		msg = fmt.Sprintf("exit status %d", *rec.ExitCode)
+	}
+	msg, _, _ = strings.Cut(strings.TrimSpace(msg), "\n")
+	if len(msg) > maxErrorChars {
+		msg = msg[:maxErrorChars] + "..."
+	}
+	return msg
+}
+
+// WriteText prints a table of the tools followed by the consistently
+// failing ones with their last error.
+func (r Report) WriteText(w io.Writer) error {
+	if r.Calls == 0 {
+		_, err := fmt.Fprintln(w, "no tool calls recorded")
+		return err
+	}
+	fmt.Fprintf(w, "%d tool calls in %d sessions, %s to %s\n\n", r.Calls, r.Sessions,
+		r.From.Local().Format(time.DateOnly), r.To.Local().Format(time.DateOnly))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tCALLS\tSHARE\tSESSIONS\tFAILED\tAVG OUTPUT\tAVG TIME\tP95 TIME")
+	for _, t := range r.Tools {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%d\t%d (%.0f%%)\t%s\t%s\t%s\n", t.Name, t.Calls,
+			100*float64(t.Calls)/float64(r.Calls), t.Sessions, t.Failed, 100*t.FailureRate,
+			byteSize(t.AvgOutput), ms(t.AvgMS), ms(t.P95MS))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var failing []Tool
+	for _, t := range r.Tools {
+		if t.Failing() {
+			failing = append(failing, t)
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nconsistently failing (%d+ calls, %.0f%%+ failed):\n", FailingMinCalls, 100*FailingRate)
+	for _, t := range failing {
+		fmt.Fprintf(w, "  %s: %d of %d failed; last: %s\n", t.Name, t.Failed, t.Calls, t.LastError)
+	}
+	return nil
+}
+
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func ms(n int64) string {
+	return (time.Duration(n) * time.Millisecond).String()
+}
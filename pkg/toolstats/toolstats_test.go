@@ -0,0 +1,101 @@
+package toolstats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/atrest"
+	"github.com/nickdu2009/learn-claude-code/pkg/audit"
+)
+
+func TestLoad_AggregatesToolsAcrossSessions(t *testing.T) {
+	t.Setenv(atrest.KeyEnv, "")
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	code := func(n int) *int { return &n }
+	logs := map[string][]audit.Record{
+		"s1": {
+			{Time: start, Tool: "bash", OutputSize: 100, DurationMS: 100, ExitCode: code(0), Status: "ok"},
+			{Time: start.Add(time.Minute), Tool: "bash", OutputSize: 300, DurationMS: 900, ExitCode: code(2), Status: "ok"},
+			{Time: start.Add(2 * time.Minute), Tool: "read_file", OutputSize: 2048, DurationMS: 1, Status: "ok"},
+		},
+		"s2": {
+			{Time: start.Add(time.Hour), Tool: "bash", OutputSize: 200, DurationMS: 200, ExitCode: code(0), Status: "ok"},
+		},
+		"old": {
+			{Time: start.Add(-48 * time.Hour), Tool: "bash", Status: "error", Error: "ignored"},
+		},
+	}
+	for session, records := range logs {
+		logger, err := audit.Open(dir, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			if err := logger.Write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		logger.Close()
+	}
+	for i := range FailingMinCalls {
+		logger, _ := audit.Open(dir, "s3")
+		_ = logger.Write(audit.Record{Time: start.Add(time.Duration(i) * time.Second), Tool: "http_request", Status: "error", Error: "dial tcp: timeout " + string(rune('a'+i)) + "\nmore"})
+		logger.Close()
+	}
+
+	report, err := Load(dir, start.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 3 || report.Calls != 4+FailingMinCalls || !report.To.Equal(start.Add(time.Hour)) {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := report.Tools[0]; got.Name != "http_request" || !got.Failing() || got.LastError != "dial tcp: timeout e" {
+		t.Fatalf("most called = %+v", got)
+	}
+	bash := report.Tools[1]
+	if bash.Name != "bash" || bash.Calls != 3 || bash.Sessions != 2 || bash.Failed != 1 || bash.AvgOutput != 200 ||
+		bash.AvgMS != 400 || bash.P95MS != 900 || bash.LastError != "exit status 2" || bash.Failing() {
+		t.Fatalf("bash = %+v", bash)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"9 tool calls in 3 sessions", "read_file", "2.0 KB", "1 (33%)", "http_request: 5 of 5 failed; last: dial tcp: timeout e"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("text lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "bash: ") {
+		t.Fatalf("bash listed as failing:\n%s", out.String())
+	}
+}
+
+func TestLoad_ReportsUnreadableLogsAndKeepsTheRest(t *testing.T) {
+	t.Setenv(atrest.KeyEnv, "")
+	dir := t.TempDir()
+	logger, _ := audit.Open(dir, "good")
+	_ = logger.Write(audit.Record{Time: time.Now(), Tool: "grep", Status: "ok"})
+	logger.Close()
+	if err := os.WriteFile(filepath.Join(dir, "bad.jsonl"), []byte("not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Load(dir, time.Time{})
+	if err == nil || !strings.Contains(err.Error(), "bad.jsonl") {
+		t.Fatalf("err = %v", err)
+	}
+	if report.Calls != 1 || report.Tools[0].Name != "grep" {
+		t.Fatalf("report = %+v", report)
+	}
+
+	if report, err := Load(filepath.Join(dir, "missing"), time.Time{}); err != nil || report.Calls != 0 {
+		t.Fatalf("missing dir: %+v, %v", report, err)
+	}
+}
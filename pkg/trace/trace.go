@@ -0,0 +1,80 @@
+// Package trace correlates the observability outputs of one agent run. A run
+// ID names the whole run (printed when the process exits) and a span ID names
+// each turn of the agent loop; both travel in the context so log lines, audit
+// records, LLM debug dumps and metric exemplars can carry them:
+//
+//	ctx = trace.WithRun(ctx, trace.NewRunID())
+//	defer fmt.Fprintln(os.Stderr, "run id:", trace.FromContext(ctx).RunID)
+//
+// loop.Run starts a span per turn.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// IDs identifies where in a run something happened. Either may be empty.
+type IDs struct {
+	RunID  string `json:"run_id,omitempty"`
+	SpanID string `json:"span_id,omitempty"`
+}
+
+// String renders the IDs as log fields, "run=<id> span=<id>", leaving out
+// the empty ones.
+func (ids IDs) String() string {
+	switch {
+	case ids.RunID == "" && ids.SpanID == "":
+		return ""
+	case ids.SpanID == "":
+		return "run=" + ids.RunID
+	case ids.RunID == "":
+		return "span=" + ids.SpanID
+	}
+	return "run=" + ids.RunID + " span=" + ids.SpanID
+}
+
+type idsKey struct{}
+
+// NewRunID returns a random 16-hex-digit run ID.
+func NewRunID() string { return randomHex(8) }
+
+// NewSpanID returns a random 8-hex-digit span ID.
+func NewSpanID() string { return randomHex(4) }
+
+// WithRun starts run runID in ctx, outside any span.
+func WithRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, idsKey{}, IDs{RunID: runID})
+}
+
+// EnsureRun returns ctx unchanged when it already carries a run, and starts
+// a new one otherwise.
+func EnsureRun(ctx context.Context) context.Context {
+	if FromContext(ctx).RunID != "" {
+		return ctx
+	}
+	return WithRun(ctx, NewRunID())
+}
+
+// WithSpan starts a new span in the run of ctx.
+func WithSpan(ctx context.Context) context.Context {
+	ids := FromContext(ctx)
+	ids.SpanID = NewSpanID()
+	return context.WithValue(ctx, idsKey{}, ids)
+}
+
+// FromContext returns the IDs carried by ctx, or zero IDs.
+func FromContext(ctx context.Context) IDs {
+	if ctx == nil {
+		return IDs{}
+	}
+	ids, _ := ctx.Value(idsKey{}).(IDs)
+	return ids
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,30 @@
+package trace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithSpan_KeepsRunAndRenewsSpan(t *testing.T) {
+	ctx := WithRun(context.Background(), "run-1")
+	first, second := FromContext(WithSpan(ctx)), FromContext(WithSpan(ctx))
+	if first.RunID != "run-1" || second.RunID != "run-1" {
+		t.Fatalf("spans left the run: %+v %+v", first, second)
+	}
+	if first.SpanID == "" || first.SpanID == second.SpanID {
+		t.Fatalf("span IDs = %q, %q", first.SpanID, second.SpanID)
+	}
+	if got := first.String(); got != "run=run-1 span="+first.SpanID {
+		t.Fatalf("String() = %q", got)
+	}
+
+	if EnsureRun(ctx) != ctx {
+		t.Fatal("EnsureRun replaced an existing run")
+	}
+	if ids := FromContext(EnsureRun(context.Background())); len(ids.RunID) != 16 || ids.SpanID != "" {
+		t.Fatalf("EnsureRun on an empty context = %+v", ids)
+	}
+	if FromContext(context.Background()).String() != "" {
+		t.Fatal("a context without a run renders IDs")
+	}
+}
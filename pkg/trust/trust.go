@@ -0,0 +1,261 @@
+// Package trust records the projects whose own code the user agreed to
+// run. A repository can ship tool executables in .agent/tools, which the
+// agent runs with --describe at startup, and MCP servers in
+// .agent/config.json, which it starts. The same config can schedule
+// prompts the daemon runs unattended and name webhooks that hear about
+// every run. A freshly cloned repository must not run anything before the
+// user has looked at it, so plugins, project servers, schedules and
+// webhooks only take effect once the user trusts the project. Servers of
+// the user and local settings are the user's own and always start.
+//
+// Trust covers the project as it was when granted: Fingerprint hashes what
+// would run, and adding or changing any of it asks again.
+//
+//	fp, err := trust.Fingerprint(root, cfg)
+//	if !trust.Trusted(root, fp) {
+//		// ask the user, then
+//		err = trust.Grant(root, fp)
+//	}
+//
+// Grants live in the user's home directory, out of reach of the project.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/config"
+	"github.com/nickdu2009/learn-claude-code/pkg/tools"
+)
+
+// RelativePath is the trust store, relative to the user's home directory.
+const RelativePath = ".agent/trusted.json"
+
+// Project is the trust granted to one project.
+type Project struct {
+	Fingerprint string    `json:"fingerprint"`
+	TrustedAt   time.Time `json:"trusted_at"`
+}
+
+type store struct {
+	// Projects is keyed by the absolute path of the project root.
+	Projects map[string]Project `json:"projects"`
+}
+
+// plugins lists the files in the plugin dir of root that LoadPlugins may
+// run, sorted by name.
+func plugins(root string) ([]string, error) {
+	dir := filepath.Join(root, tools.DefaultPluginDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Programs lists what the project at root would run, for the user to
+// review: its plugins and the project servers, schedules and webhooks of
+// cfg.
+func Programs(root string, cfg config.Config) ([]string, error) {
+	names, err := plugins(root)
+	if err != nil {
+		return nil, err
+	}
+	var programs []string
+	for _, name := range names {
+		programs = append(programs, filepath.Join(tools.DefaultPluginDir, name))
+	}
+	project := projectServers(cfg.MCP)
+	for _, name := range slices.Sorted(maps.Keys(project)) {
+		server := project[name]
+		programs = append(programs, fmt.Sprintf("mcp server %s: %s", name, strings.Join(append([]string{server.Command}, server.Args...), " ")))
+	}
+	for _, s := range cfg.Schedules {
+		programs = append(programs, fmt.Sprintf("schedule %s (%s): %s", s.Name, s.Cron, s.Prompt))
+	}
+	for _, w := range cfg.Webhooks {
+		target := w.URL
+		if target == "" {
+			target = "$" + w.URLEnv
+		}
+		programs = append(programs, "webhook: "+target)
+	}
+	return programs, nil
+}
+
+// Fingerprint hashes the name, mode and content of every plugin of root
+// and the project servers, schedules and webhooks of cfg. It is "" when
+// there is nothing to run, which needs no trust.
+func Fingerprint(root string, cfg config.Config) (string, error) {
+	names, err := plugins(root)
+	if err != nil {
+		return "", err
+	}
+	project := projectServers(cfg.MCP)
+	unattended := len(cfg.Schedules) > 0 || len(cfg.Webhooks) > 0
+	if len(names) == 0 && len(project) == 0 && !unattended {
+		return "", nil
+	}
+	h := sha256.New()
+	// json.Marshal sorts map keys, so equal servers hash alike.
+	data, err := json.Marshal(project)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	h.Write([]byte{0})
+	// Hashed only when present, so earlier grants stay valid.
+	if unattended {
+		data, err := json.Marshal([]any{cfg.Schedules, cfg.Webhooks})
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	for _, name := range names {
+		path := filepath.Join(root, tools.DefaultPluginDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%o\x00", name, info.Mode().Perm())
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func projectServers(servers config.MCP) map[string]config.MCPServer {
+	project := map[string]config.MCPServer{}
+	for name, server := range servers.Servers {
+		if server.Project {
+			project[name] = server
+		}
+	}
+	return project
+}
+
+// Trusted reports whether the user trusts root as fingerprinted. An empty
+// fingerprint is always trusted.
+func Trusted(root, fingerprint string) bool {
+	if fingerprint == "" {
+		return true
+	}
+	key, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	s, err := load()
+	return err == nil && s.Projects[key].Fingerprint == fingerprint
+}
+
+// Grant records that the user trusts root as fingerprinted.
+func Grant(root, fingerprint string) error {
+	key, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	s, err := load()
+	if err != nil {
+		return err
+	}
+	s.Projects[key] = Project{Fingerprint: fingerprint, TrustedAt: time.Now().UTC()}
+	return s.save()
+}
+
+// Revoke forgets the trust granted to root.
+func Revoke(root string) error {
+	key, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	s, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := s.Projects[key]; !ok {
+		return nil
+	}
+	delete(s.Projects, key)
+	return s.save()
+}
+
+func path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, RelativePath), nil
+}
+
+func load() (store, error) {
+	s := store{Projects: map[string]Project{}}
+	p, err := path()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if s.Projects == nil {
+		s.Projects = map[string]Project{}
+	}
+	return s, nil
+}
+
+func (s store) save() error {
+	p, err := path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("write %s: %w", p, err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", p, err)
+	}
+	return os.Rename(tmp, p)
+}
@@ -0,0 +1,143 @@
+// Package watch runs a check command whenever the workspace changes and,
+// when it fails, hands the failure to the agent to fix: fix-on-save without
+// leaving the editor.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nickdu2009/learn-claude-code/pkg/fileindex"
+	"github.com/nickdu2009/learn-claude-code/pkg/loop"
+)
+
+const (
+	// DefaultInterval is how often the workspace is polled.
+	DefaultInterval = time.Second
+	// DefaultDebounce is the quiet period after the last change before the
+	// check runs, so a burst of saves triggers one run.
+	DefaultDebounce  = 500 * time.Millisecond
+	maxListedChanges = 10
+)
+
+// Options configure Run.
+type Options struct {
+	Root string
+	// Command is run through bash in Root; exit status 0 means the
+	// workspace is fine.
+	Command string
+	// Timeout bounds one check (default: loop's test timeout).
+	Timeout  time.Duration
+	Interval time.Duration
+	Debounce time.Duration
+	// Fix starts an agent turn with prompt. It is called when a check fails
+	// after a change that did not come from Fix itself; nil only reports.
+	Fix func(ctx context.Context, prompt string) error
+	// OnCheck, if set, is told about every check.
+	OnCheck func(Check)
+}
+
+// Check is the outcome of one run of the command.
+type Check struct {
+	At      time.Time
+	Changes []fileindex.Change
+	Passed  bool
+	Output  string
+	// AfterFix is true for the check that verifies the agent's fix.
+	AfterFix bool
+	// FixErr is set when the agent turn before this check failed.
+	FixErr error
+}
+
+// Run watches until ctx is done. Changes the agent makes while fixing are
+// verified once but never trigger another fix, so a failure the agent cannot
+// fix waits for the next change from the user instead of looping.
+func Run(ctx context.Context, opts Options) error {
+	if strings.TrimSpace(opts.Command) == "" {
+		return fmt.Errorf("watch: a check command is required")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Debounce < 0 {
+		opts.Debounce = 0
+	} else if opts.Debounce == 0 {
+		opts.Debounce = DefaultDebounce
+	}
+
+	watcher := fileindex.NewWatcher(opts.Root, opts.Interval)
+	if _, err := watcher.Poll(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	var (
+		pending    []fileindex.Change
+		lastChange time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		changes, err := watcher.Poll(ctx)
+		if err != nil {
+			continue
+		}
+		if len(changes) > 0 {
+			pending = append(pending, changes...)
+			lastChange = time.Now()
+		}
+		if len(pending) == 0 || time.Since(lastChange) < opts.Debounce {
+			continue
+		}
+
+		check := runCheck(ctx, opts, pending)
+		pending = nil
+		report(opts, check)
+		if check.Passed || opts.Fix == nil || ctx.Err() != nil {
+			continue
+		}
+
+		fixErr := opts.Fix(ctx, fixPrompt(opts.Command, check))
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Absorb the agent's own edits so they do not count as new changes.
+		fixed, _ := watcher.Poll(ctx)
+		verify := runCheck(ctx, opts, fixed)
+		verify.AfterFix, verify.FixErr = true, fixErr
+		report(opts, verify)
+	}
+}
+
+func runCheck(ctx context.Context, opts Options, changes []fileindex.Change) Check {
+	result := loop.RunTestCommand(ctx, loop.FixUntilGreenOptions{TestCommand: opts.Command, Workdir: opts.Root, Timeout: opts.Timeout})
+	return Check{At: time.Now(), Changes: changes, Passed: result.Passed, Output: result.Output}
+}
+
+func report(opts Options, check Check) {
+	if opts.OnCheck != nil {
+		opts.OnCheck(check)
+	}
+}
+
+func fixPrompt(command string, check Check) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The check `%s` failed after these files changed:\n", command)
+	for i, c := range check.Changes {
+		if i == maxListedChanges {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(check.Changes)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- %s (%s)\n", c.Path, c.Op)
+	}
+	fmt.Fprintf(&b, "\n<check-output>\n%s\n</check-output>\n\n", check.Output)
+	b.WriteString("Fix the code so that the check passes. The user is editing these files right now: " +
+		"keep the fix minimal and do not undo their changes unless they are the bug.")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_FixesFailingCheckAndVerifies(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	checks := make(chan Check, 4)
+	var prompts []string
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, Options{
+			Root:     root,
+			Command:  "test ! -f broken",
+			Interval: 10 * time.Millisecond,
+			Debounce: 20 * time.Millisecond,
+			Fix: func(_ context.Context, prompt string) error {
+				prompts = append(prompts, prompt)
+				return os.Remove(filepath.Join(root, "broken"))
+			},
+			OnCheck: func(c Check) { checks <- c },
+		})
+	}()
+
+	// Give Run time to take its baseline before breaking the workspace.
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(root, "broken"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-checks
+	if first.Passed || first.AfterFix || len(first.Changes) != 1 || first.Changes[0].Path != "broken" {
+		t.Fatalf("first check = %+v", first)
+	}
+	verify := <-checks
+	if !verify.Passed || !verify.AfterFix {
+		t.Fatalf("verification check = %+v", verify)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "`test ! -f broken` failed") || !strings.Contains(prompts[0], "- broken (created)") {
+		t.Fatalf("prompts = %q", prompts)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	select {
+	case c := <-checks:
+		t.Fatalf("unexpected extra check %+v", c)
+	default:
+	}
+}
+
+func TestRun_RequiresCommand(t *testing.T) {
+	if err := Run(context.Background(), Options{Root: t.TempDir()}); err == nil {
+		t.Fatal("expected an error without a command")
+	}
+}